@@ -16,14 +16,52 @@
 
 import logging
 
-from emodelrunner.GUI_utils.interface import GUI
-from emodelrunner.parsing_utilities import get_parser_args, set_verbosity
+from emodelrunner.GUI_utils.headless import read_session, run_headless
+from emodelrunner.parsing_utilities import get_gui_parser_args, set_verbosity
 
 logger = logging.getLogger(__name__)
 
+
+def main(args):
+    """Open the GUI, or save its figure panels if a screenshot directory is given.
+
+    Args:
+        args (argparse.Namespace): config_path and the GUI display settings,
+            see parsing_utilities.add_gui_arguments
+    """
+    config_path = args.config_path
+    if args.session is not None and config_path is None:
+        config_path = read_session(args.session)["config_path"]
+
+    if args.screenshot is not None:
+        session = None
+        if args.session is not None:
+            session = read_session(args.session)
+        run_headless(
+            config_path,
+            args.screenshot,
+            session=session,
+            image_format=args.image_format,
+            theme=args.theme,
+        )
+    else:
+        # tkinter and the TkAgg backend are only needed to open the window
+        # pylint: disable=import-outside-toplevel
+        from emodelrunner.GUI_utils.interface import GUI
+
+        gui = GUI(
+            fps=args.fps,
+            config_path=config_path,
+            theme=args.theme,
+            scaling=args.scaling,
+        )
+        if args.session is not None:
+            gui.set_session(read_session(args.session))
+        gui.root.mainloop()
+
+
 if __name__ == "__main__":
-    args = get_parser_args()
-    set_verbosity(args.verbosity)
+    args = get_gui_parser_args()
+    set_verbosity(args.verbosity, args.log_level, args.log_file)
 
-    gui = GUI(config_path=args.config_path)
-    gui.root.mainloop()
+    main(args)
@@ -0,0 +1,193 @@
+"""Window animating the membrane potential on the morphology after a run."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# pylint: disable=wrong-import-position, too-many-ancestors, import-error
+import tkinter as tk
+from tkinter import ttk
+import matplotlib
+
+matplotlib.use("TkAgg")
+from matplotlib import cm
+from matplotlib.backends.backend_tkagg import FigureCanvasTkAgg
+from matplotlib.colors import Normalize
+from matplotlib.figure import Figure
+
+from emodelrunner.GUI_utils.plotshape import get_color_from_cmap, get_morph_lines
+
+
+def get_frame_step(record_dt, speed, fps):
+    """Return the number of recorded samples between two displayed frames.
+
+    Args:
+        record_dt (float): time step of the recordings (ms)
+        speed (float): simulated time displayed per second of animation (ms)
+        fps (int): frames per second of the animation
+
+    Returns:
+        int: number of recorded samples between two frames (at least 1)
+    """
+    return max(1, int(round(speed / (fps * record_dt))))
+
+
+class WindowMorphologyAnimation(tk.Toplevel):
+    """Window animating the voltage of the last run as colors on the morphology.
+
+    Attributes:
+        time (ndarray): recorded time (ms)
+        voltage (ndarray): recorded voltage (mV) of each segment,
+            with shape (n_times, n_segments)
+        frame_step (int): number of recorded samples between two displayed frames
+        refresh_ms (int): time between two displayed frames (ms, real time)
+        val_min (int): minimum voltage for colormap
+        val_max (int): maximum voltage for colormap
+        cmap (matplotlib.colors.Colormap): colormap
+        playing (bool): True while the animation is played
+        lines (list of matplotlib.lines.Line2D): lines of the segments
+    """
+
+    def __init__(
+        self,
+        parent,
+        simulation,
+        background,
+        plot_3d=False,
+        fps=15,
+        speed=100,
+        val_min=-80,
+        val_max=30,
+        cmap=cm.plasma,
+    ):
+        """Constructor.
+
+        Args:
+            parent (tk.Tk): root of the GUI
+            simulation (NeuronSimulation): simulation with the voltage of all the segments
+                recorded during the last run
+            background (str): background color of the window
+            plot_3d (bool): set to True to plot the cell shape in 3D
+            fps (int): frames per second of the animation
+            speed (float): simulated time displayed per second of animation (ms)
+            val_min (int): minimum voltage for colormap
+            val_max (int): maximum voltage for colormap
+            cmap (matplotlib.colors.Colormap): colormap
+        """
+        tk.Toplevel.__init__(self, parent)
+        self.title("Voltage on morphology")
+        self.configure(background=background)
+        self.protocol("WM_DELETE_WINDOW", self.close)
+
+        self.time, self.voltage = simulation.get_morphology_voltage()
+        self.frame_step = get_frame_step(simulation.morph_record_dt, speed, fps)
+        self.refresh_ms = int(1000 / fps)
+        self.val_min = val_min
+        self.val_max = val_max
+        self.cmap = cmap
+        self.playing = False
+
+        # figure, with the same axes as the morphology figures of the main window
+        fig = Figure(figsize=(5, 5))
+        if plot_3d:
+            ax = fig.add_subplot(111, projection="3d")
+        else:
+            ax = fig.add_subplot(111)
+            ax.set_aspect(aspect=1)
+        get_morph_lines(
+            ax=ax,
+            sim=simulation.sim,
+            do_plot=True,
+            cmap=None,
+            plot_3d=plot_3d,
+            xaxis=2,
+            yaxis=0,
+            zaxis=1,
+        )
+        self.lines = list(ax.lines)
+        fig.colorbar(
+            cm.ScalarMappable(norm=Normalize(val_min, val_max), cmap=cmap),
+            ax=ax,
+            label="v [mV]",
+        )
+
+        self.canva = FigureCanvasTkAgg(fig, self)
+        self.canva.get_tk_widget().grid(
+            row=0, column=0, columnspan=3, sticky=(tk.W, tk.E, tk.N, tk.S)
+        )
+
+        # controls
+        self.play_button = ttk.Button(self, text="Play", command=self.toggle_play)
+        # ttk.Scale sets float values
+        self.time_var = tk.DoubleVar()
+        self.time_slider = ttk.Scale(
+            self,
+            from_=0,
+            to=len(self.time) - 1,
+            orient=tk.HORIZONTAL,
+            variable=self.time_var,
+            command=lambda _: self.show_frame(self.time_var.get()),
+        )
+        self.time_label = ttk.Label(self, width=12)
+
+        self.play_button.grid(row=1, column=0)
+        self.time_slider.grid(row=1, column=1, sticky=(tk.W, tk.E))
+        self.time_label.grid(row=1, column=2)
+
+        self.columnconfigure(1, weight=1)
+        self.rowconfigure(0, weight=1)
+
+        self.show_frame(0)
+
+    def show_frame(self, idx):
+        """Color the morphology with the voltage at a recorded time.
+
+        Args:
+            idx (int): index of the recorded time
+        """
+        idx = min(int(idx), len(self.time) - 1)
+        for line, val in zip(self.lines, self.voltage[idx]):
+            line.set_color(
+                get_color_from_cmap(val, self.val_min, self.val_max, self.cmap)
+            )
+        self.time_label.config(text=f"t = {self.time[idx]:.1f} ms")
+        self.canva.draw_idle()
+
+    def toggle_play(self):
+        """Play or pause the animation."""
+        self.playing = not self.playing
+        self.play_button.config(text="Pause" if self.playing else "Play")
+        if self.playing:
+            # restart from the beginning at the end of the run
+            if int(self.time_var.get()) >= len(self.time) - 1:
+                self.time_var.set(0)
+            self.next_frame()
+
+    def next_frame(self):
+        """Display the next frame, and schedule the following one while playing."""
+        if not self.playing:
+            return
+        idx = int(self.time_var.get()) + self.frame_step
+        if idx >= len(self.time) - 1:
+            idx = len(self.time) - 1
+            self.playing = False
+            self.play_button.config(text="Play")
+        self.time_var.set(idx)
+        self.show_frame(idx)
+        if self.playing:
+            self.after(self.refresh_ms, self.next_frame)
+
+    def close(self):
+        """Stop the animation and close the window."""
+        self.playing = False
+        self.destroy()
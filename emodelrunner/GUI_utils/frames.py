@@ -31,7 +31,15 @@ except ImportError:
         NavigationToolbar2Tk as NavigationToolbar2TkAgg,
     )
 
-from emodelrunner.GUI_utils.plotshape import get_morph_lines
+from emodelrunner.GUI_utils.plotshape import (
+    SYNAPSE_COLOR_MODES,
+    get_morph_lines,
+    get_synapse_colors,
+)
+from emodelrunner.GUI_utils.simulator import (
+    frequency_to_interval,
+    interval_to_frequency,
+)
 from emodelrunner.GUI_utils.style import get_style_cst
 
 
@@ -139,6 +147,15 @@ class FrameSetIntFromEntry(ttk.Frame):
                 "Must be an int.",
             )
 
+    def refresh(self, gui, attr_name):
+        """Display the current value of the simulation attribute.
+
+        Args:
+            gui (GUI): main class containing main frames and simulation
+            attr_name (str): attribute of gui.simulation that can be changed with the entry
+        """
+        self.sv.set(str(int(getattr(gui.simulation, attr_name))))
+
     def disable(self):
         """Set entry state to disabled."""
         self.entry.state(["disabled"])
@@ -251,6 +268,15 @@ class FrameStepStimulus(ttk.Frame):
                 "Must be a float.",
             )
 
+    def set_custom_value(self, value):
+        """Select the custom stimulus and display the given value.
+
+        Args:
+            value (float): step amplitude (nA)
+        """
+        self.step_stim.set(self.custom_step.cget("value"))
+        self.sv.set(str(value))
+
 
 class FrameHoldStimulus(ttk.Frame):
     """Frame containing holding stimulus value input."""
@@ -355,6 +381,15 @@ class FrameHoldStimulus(ttk.Frame):
                 "Must be a float.",
             )
 
+    def set_custom_value(self, value):
+        """Select the custom stimulus and display the given value.
+
+        Args:
+            value (float): holding amplitude (nA)
+        """
+        self.hold_stim.set(self.custom_hold.cget("value"))
+        self.sv.set(str(value))
+
 
 class FrameStepProtocol(ttk.Frame):
     """Frame containing step stimulus-related input."""
@@ -402,6 +437,104 @@ class FrameStepProtocol(ttk.Frame):
         for i in range(6):
             self.rowconfigure(i, weight=1)
 
+    def refresh(self, gui):
+        """Display the current step and holding stimuli of the simulation.
+
+        Args:
+            gui (GUI): main class containing main frames and simulation
+        """
+        # read the values first, since updating an entry updates the simulation
+        step_stim = gui.simulation.step_stim
+        hypamp = gui.simulation.hypamp
+
+        self.frame_step_stim.set_custom_value(step_stim)
+        self.frame_step_delay.refresh(gui, "step_delay")
+        self.frame_step_duration.refresh(gui, "step_duration")
+        self.frame_hold_stim.set_custom_value(hypamp)
+        self.frame_hold_step_delay.refresh(gui, "hold_step_delay")
+        self.frame_hold_step_duration.refresh(gui, "hold_step_duration")
+
+
+class FrameProtocolEditor(ttk.Frame):
+    """Frame to load the stimuli of a StepProtocol and to save the edited stimuli."""
+
+    def __init__(self, parent, gui):
+        """Constructor.
+
+        Args:
+            parent (ttk.Frame): parent frame in which to embed this frame
+            gui (GUI): main class containing main frames and simulation
+        """
+        ttk.Frame.__init__(self, parent, style="TFrame")
+
+        style_dict = get_style_cst()
+
+        self.label_load = ttk.Label(self, text="Step protocol:")
+        self.protocol_name = tk.StringVar()
+        self.combobox = ttk.Combobox(
+            self,
+            textvariable=self.protocol_name,
+            values=list(gui.simulation.step_protocols.keys()),
+            font=style_dict["base_font"],
+        )
+        self.load_button = ttk.Button(
+            self, text="Load", command=lambda: self.load_protocol(gui)
+        )
+        self.save_button = ttk.Button(
+            self, text="Save", command=lambda: self.save_protocol(gui)
+        )
+
+        self.label_load.grid(row=0, column=0, sticky=tk.W)
+        self.combobox.grid(row=0, column=1, sticky=(tk.W, tk.E))
+        self.load_button.grid(row=0, column=2)
+        self.save_button.grid(row=0, column=3)
+
+        self.columnconfigure(1, weight=1)  # only combobox grows
+
+    def load_protocol(self, gui):
+        """Load the stimuli of the selected StepProtocol.
+
+        Args:
+            gui (GUI): main class containing main frames and simulation
+        """
+        protocol_name = self.protocol_name.get()
+        if protocol_name not in gui.simulation.step_protocols:
+            tk.messagebox.showerror(
+                "Unknown protocol",
+                f"No StepProtocol named {protocol_name} in the protocols file.",
+            )
+            return
+
+        gui.simulation.apply_step_protocol(protocol_name)
+        gui.refresh_protocol_config()
+        gui.config_has_changed()
+
+    def save_protocol(self, gui):
+        """Save the edited stimuli as a StepProtocol in the protocols file.
+
+        Args:
+            gui (GUI): main class containing main frames and simulation
+        """
+        protocol_name = self.protocol_name.get()
+        if protocol_name == "":
+            tk.messagebox.showerror(
+                "No protocol name", "Enter a protocol name to save."
+            )
+            return
+        if protocol_name in gui.simulation.step_protocols:
+            overwrite = tk.messagebox.askyesno(
+                "Overwrite protocol", f"Overwrite protocol {protocol_name}?"
+            )
+            if not overwrite:
+                return
+
+        try:
+            gui.simulation.save_step_protocol(protocol_name)
+        except ValueError as exc:
+            tk.messagebox.showerror("Cannot save protocol", str(exc))
+            return
+        self.combobox["values"] = list(gui.simulation.step_protocols.keys())
+
 
 class FrameProtocols(ttk.LabelFrame):
     """Frame containing protocol-related inputs."""
@@ -416,17 +549,29 @@ class FrameProtocols(ttk.LabelFrame):
         """
         ttk.LabelFrame.__init__(self, parent, style="Boxed.TFrame", labelwidget=title)
 
+        self.frame_protocol_editor = FrameProtocolEditor(self, gui)
         self.frame_sim_duration = FrameSetIntFromEntry(
             self, gui, "total_duration", "Simulation time [ms]"
         )
         self.frame_step_protocol = FrameStepProtocol(self, gui)
 
-        self.frame_sim_duration.grid(row=0, column=0, sticky=(tk.W, tk.E))
-        self.frame_step_protocol.grid(row=1, column=0, sticky=(tk.W, tk.E, tk.N, tk.S))
+        self.frame_protocol_editor.grid(row=0, column=0, sticky=(tk.W, tk.E))
+        self.frame_sim_duration.grid(row=1, column=0, sticky=(tk.W, tk.E))
+        self.frame_step_protocol.grid(row=2, column=0, sticky=(tk.W, tk.E, tk.N, tk.S))
 
         self.columnconfigure(0, weight=1)
         self.rowconfigure(0, weight=1)
-        self.rowconfigure(1, weight=12)
+        self.rowconfigure(1, weight=1)
+        self.rowconfigure(2, weight=12)
+
+    def refresh(self, gui):
+        """Display the current protocol configuration of the simulation.
+
+        Args:
+            gui (GUI): main class containing main frames and simulation
+        """
+        self.frame_sim_duration.refresh(gui, "total_duration")
+        self.frame_step_protocol.refresh(gui)
 
 
 class FrameConfig(ttk.Frame):
@@ -449,16 +594,109 @@ class FrameConfig(ttk.Frame):
         )
         self.frame_protocols = FrameProtocols(self, gui, title_protocols)
 
+        title_conductances = ttk.Label(self, text="Channel conductances")
+        self.frame_conductances = FrameConductances(self, gui, title_conductances)
+
         self.frame_config_fig.grid(row=0, column=0, sticky=(tk.W, tk.E, tk.N, tk.S))
         self.frame_protocols.grid(row=1, column=0, sticky=(tk.W, tk.E, tk.N, tk.S))
+        self.frame_conductances.grid(row=2, column=0, sticky=(tk.W, tk.E, tk.N, tk.S))
 
         self.columnconfigure(0, weight=1)
         self.rowconfigure(0, weight=1)
         self.rowconfigure(1, weight=10)
+        self.rowconfigure(2, weight=1)
+
+
+class FrameConductances(ttk.LabelFrame):
+    """Frame containing sliders scaling the channel conductances of the cell.
+
+    Releasing a slider re-instantiates the cell and re-runs the simulation.
+    """
+
+    def __init__(self, parent, gui, title, max_scale=3.0):
+        """Constructor.
+
+        Args:
+            parent (ttk.Frame): parent frame in which to embed this frame
+            gui (GUI): main class containing main frames and simulation
+            title (ttk.Label): frame title to display
+            max_scale (float): maximum scaling factor that can be set with the sliders
+        """
+        ttk.LabelFrame.__init__(self, parent, style="Boxed.TFrame", labelwidget=title)
+
+        # scaling factor of each conductance
+        self.scale_vars = {}
+        # label displaying the scaling factor of each conductance
+        self.value_labels = {}
+
+        for row, name in enumerate(gui.simulation.get_conductance_names()):
+            self.scale_vars[name] = tk.DoubleVar()
+            self.scale_vars[name].set(gui.simulation.conductance_scales.get(name, 1.0))
+
+            label = ttk.Label(self, text=name)
+            self.value_labels[name] = ttk.Label(self, width=5)
+            slider = ttk.Scale(
+                self,
+                from_=0.0,
+                to=max_scale,
+                orient=tk.HORIZONTAL,
+                variable=self.scale_vars[name],
+                command=lambda _, name=name: self.display_value(name),
+            )
+            # only re-run once the user has chosen the value
+            slider.bind(
+                "<ButtonRelease-1>",
+                lambda _, name=name: gui.set_conductance_scale(
+                    name, self.scale_vars[name].get()
+                ),
+            )
+            self.display_value(name)
+
+            label.grid(row=row, column=0, sticky=tk.W)
+            slider.grid(row=row, column=1, sticky=(tk.W, tk.E))
+            self.value_labels[name].grid(row=row, column=2, sticky=tk.E)
+
+        self.reset_button = ttk.Button(
+            self, text="Reset", command=lambda: self.reset(gui)
+        )
+        self.reset_button.grid(
+            row=len(self.scale_vars), column=0, columnspan=3, sticky=tk.E
+        )
+
+        self.columnconfigure(1, weight=1)  # only slider column grows
+
+    def display_value(self, name):
+        """Display the scaling factor of a conductance next to its slider.
+
+        Args:
+            name (str): name of the conductance
+        """
+        self.value_labels[name].config(text=f"x{self.scale_vars[name].get():.2f}")
+
+    def refresh(self, gui):
+        """Display the current conductance scaling factors of the simulation.
+
+        Args:
+            gui (GUI): main class containing main frames and simulation
+        """
+        for name, scale_var in self.scale_vars.items():
+            scale_var.set(gui.simulation.conductance_scales.get(name, 1.0))
+            self.display_value(name)
+
+    def reset(self, gui):
+        """Reset all the conductances to their optimised values and re-run.
+
+        Args:
+            gui (GUI): main class containing main frames and simulation
+        """
+        gui.simulation.conductance_scales = {}
+        self.refresh(gui)
+        gui.config_has_changed()
+        gui.start()
 
 
 class FrameButtons(ttk.Frame):
-    """Frame containing buttons to (re-)start and pause simulation."""
+    """Frame containing buttons to (re-)start and pause simulation, and to handle traces."""
 
     def __init__(self, parent, gui):
         """Constructor.
@@ -489,24 +727,70 @@ class FrameButtons(ttk.Frame):
             style="ControlSimul.TButton",
         )
 
+        self.export_button = ttk.Button(
+            self, text="Export", command=gui.export_traces, style="ControlSimul.TButton"
+        )
+
+        self.clear_button = ttk.Button(
+            self, text="Clear", command=gui.clear_traces, style="ControlSimul.TButton"
+        )
+
+        self.cancel_button = ttk.Button(
+            self,
+            text="Cancel",
+            command=gui.cancel,
+            state=tk.DISABLED,
+            style="ControlSimul.TButton",
+        )
+
+        self.animate_button = ttk.Button(
+            self,
+            text="Animate",
+            command=gui.animate_morphology,
+            style="ControlSimul.TButton",
+        )
+
+        # simulation progress (%)
+        self.progress_bar = ttk.Progressbar(
+            self, orient=tk.HORIZONTAL, mode="determinate", maximum=100
+        )
+
         self.start_button.grid(row=0, column=0)
         self.pause_button.grid(row=0, column=1)
         self.continue_button.grid(row=0, column=2)
+        self.cancel_button.grid(row=0, column=3)
+        self.export_button.grid(row=0, column=4)
+        self.clear_button.grid(row=0, column=5)
+        self.animate_button.grid(row=0, column=6)
+        self.progress_bar.grid(row=1, column=0, columnspan=7, sticky=(tk.W, tk.E))
 
     def simul_running(self):
-        """Disable continue button, enable pause button."""
+        """Disable continue & animate buttons, enable pause & cancel buttons."""
         self.pause_button["state"] = tk.NORMAL
         self.continue_button["state"] = tk.DISABLED
+        self.cancel_button["state"] = tk.NORMAL
+        self.animate_button["state"] = tk.DISABLED
 
     def simul_on_pause(self):
-        """Disable pause button, enable continue button."""
+        """Disable pause button, enable continue & cancel buttons."""
         self.pause_button["state"] = tk.DISABLED
         self.continue_button["state"] = tk.NORMAL
+        self.cancel_button["state"] = tk.NORMAL
 
     def simul_ended(self):
-        """Disable pause & continue buttons."""
+        """Disable pause, continue & cancel buttons, enable animate button."""
         self.pause_button["state"] = tk.DISABLED
         self.continue_button["state"] = tk.DISABLED
+        self.cancel_button["state"] = tk.DISABLED
+        self.animate_button["state"] = tk.NORMAL
+
+    def set_progress(self, progress):
+        """Display the progress of the simulation.
+
+        Args:
+            progress (float): progress of the simulation (%)
+        """
+        self.progress_bar["value"] = progress
 
 
 class FrameFigures(ttk.Frame):
@@ -521,6 +805,9 @@ class FrameFigures(ttk.Frame):
         figsize="medium",
         val_min=-80,
         val_max=30,
+        kept_traces=None,
+        on_pick=None,
+        syn_color_by="type",
     ):
         """Constructor.
 
@@ -532,6 +819,12 @@ class FrameFigures(ttk.Frame):
             figsize (str): figures size. can be "small", "medium", or "large".
             val_min (int): minimum voltage for colormap
             val_max (int): maximum voltage for colormap
+            kept_traces (list of dicts): traces of the previous runs to overlay
+                on the voltage figure. Each trace has a 'label', a 'time' and a 'voltage'.
+            on_pick (callable): function called with the index of the segment
+                clicked on the synapse figure. Segments cannot be picked if None.
+            syn_color_by (str): synapse property giving the colors of the synapses,
+                among SYNAPSE_COLOR_MODES
         """
         ttk.Frame.__init__(self, parent, style="TFrame")
 
@@ -540,6 +833,7 @@ class FrameFigures(ttk.Frame):
         self.zaxis = 1  # y
         self.plot_3d = plot_3d
         self.figsize = figsize
+        self.syn_color_by = syn_color_by
 
         self.val_min = val_min
         self.val_max = val_max
@@ -620,6 +914,10 @@ class FrameFigures(ttk.Frame):
         if self.plot_3d:
             self.get_interactive_3d_rotation(self.canva_morph_syn, self.ax_morph_syn)
 
+        # to place recording sites and stimuli by clicking on the segments
+        if on_pick is not None:
+            self.set_segment_picking(self.canva_morph_syn, self.ax_morph_syn, on_pick)
+
         # ---
         # figure for voltage evolution
         # ---
@@ -627,6 +925,11 @@ class FrameFigures(ttk.Frame):
         self.ax_volt = fig_volt.add_subplot(111)
         self.set_axis(x_max=simulation.protocol.total_duration)
 
+        # line of the current run, and lines of the previous runs
+        self.volt_line = None
+        self.kept_volt_lines = []
+        self.plot_kept_traces(kept_traces)
+
         # set fig size
         self.set_fig_volt_display(fig_volt)
 
@@ -711,6 +1014,27 @@ class FrameFigures(ttk.Frame):
         canva.mpl_connect("button_release_event", ax._button_release)
         canva.mpl_connect("motion_notify_event", ax._on_move)
 
+    @staticmethod
+    def set_segment_picking(canva, ax, on_pick, pickradius=3):
+        """Connect events to canva to call on_pick when a segment is clicked.
+
+        Args:
+            canva (matplotlib.backends.backend_tkagg.FigureCanvasTkAgg): canva
+            ax (matplotlib.axes.Axes): axes with one line per segment
+            on_pick (callable): function called with the index of the clicked segment
+            pickradius (float): distance (in points) from a line within which it is picked
+        """
+        # the lines are the segments, in the order of the morphology plot
+        segment_lines = list(ax.lines)
+        for line in segment_lines:
+            line.set_picker(pickradius)
+
+        def pick_segment(event):
+            if event.artist in segment_lines:
+                on_pick(segment_lines.index(event.artist))
+
+        canva.mpl_connect("pick_event", pick_segment)
+
     def set_axis(self, x_min=0, x_max=3000, y_min=-90, y_max=40):
         """Set the voltage figure's axis.
 
@@ -766,15 +1090,16 @@ class FrameFigures(ttk.Frame):
         t, v = simulation.get_voltage()
 
         # update data in Line2D
-        if self.ax_volt.lines:
-            line = self.ax_volt.lines[0]
-            line.set_xdata(t)
-            line.set_ydata(v)
+        if self.volt_line is not None:
+            self.volt_line.set_xdata(t)
+            self.volt_line.set_ydata(v)
         else:
-            (line,) = self.ax_volt.plot(t, v)
+            (self.volt_line,) = self.ax_volt.plot(
+                t, v, color=matplotlib.rcParams["lines.color"], label="current run"
+            )
 
         # draw voltage plot to canva
-        self.ax_volt.draw_artist(line)
+        self.ax_volt.draw_artist(self.volt_line)
         self.canva_volt.blit(self.ax_volt.bbox)
 
         # do not blit too much on top of figure, or else
@@ -810,7 +1135,7 @@ class FrameFigures(ttk.Frame):
         root.update()
 
     def update_syn_display(self, root, simulation, size_scatter=6):
-        """Update the display of the synapses on the right figure.
+        """Update the display of the synapses, recording sites and stimuli on the right figure.
 
         Args:
             root (tk.Tk): root of the GUI
@@ -836,8 +1161,7 @@ class FrameFigures(ttk.Frame):
                 data = np.array(data)
                 syn_x = data[:, self.xaxis]
                 syn_y = data[:, self.yaxis]
-                syn_cols = data[:, 3]
-                syn_colors = ["red" if x == 1 else "orange" for x in syn_cols]
+                syn_colors = get_synapse_colors(simulation, mtype, self.syn_color_by)
                 if self.plot_3d:
                     syn_z = data[:, self.zaxis]
                     syn_scatterplot[mtype] = self.ax_morph_syn.scatter(
@@ -855,14 +1179,60 @@ class FrameFigures(ttk.Frame):
             else:
                 syn_scatterplot[mtype] = None
 
-        # draw selected synapses
+        # draw selected synapses, or all of them to show which ones are activated
+        show_all = self.syn_color_by == "activation"
         for mtype in simulation.available_pre_mtypes:
-            if mtype in simulation.pre_mtypes and syn_scatterplot[mtype]:
+            is_shown = show_all or mtype in simulation.pre_mtypes
+            if is_shown and syn_scatterplot[mtype]:
                 syn_scatterplot[mtype].set_visible(True)
                 self.ax_morph_syn.draw_artist(syn_scatterplot[mtype])
             elif syn_scatterplot[mtype]:
                 syn_scatterplot[mtype].set_visible(False)
 
+        # draw recording sites
+        if simulation.rec_display_data:
+            data = np.array(simulation.rec_display_data)
+            if self.plot_3d:
+                rec_scatterplot = self.ax_morph_syn.scatter(
+                    xs=data[:, self.xaxis],
+                    ys=data[:, self.yaxis],
+                    zs=data[:, self.zaxis],
+                    s=size_scatter * 8,
+                    c="blue",
+                    marker="*",
+                )
+            else:
+                rec_scatterplot = self.ax_morph_syn.scatter(
+                    x=data[:, self.xaxis],
+                    y=data[:, self.yaxis],
+                    s=size_scatter * 8,
+                    c="blue",
+                    marker="*",
+                )
+            self.ax_morph_syn.draw_artist(rec_scatterplot)
+
+        # draw stimuli location
+        if simulation.stim_display_data is not None:
+            data = simulation.stim_display_data
+            if self.plot_3d:
+                stim_scatterplot = self.ax_morph_syn.scatter(
+                    xs=[data[self.xaxis]],
+                    ys=[data[self.yaxis]],
+                    zs=[data[self.zaxis]],
+                    s=size_scatter * 8,
+                    c="green",
+                    marker="^",
+                )
+            else:
+                stim_scatterplot = self.ax_morph_syn.scatter(
+                    x=[data[self.xaxis]],
+                    y=[data[self.yaxis]],
+                    s=size_scatter * 8,
+                    c="green",
+                    marker="^",
+                )
+            self.ax_morph_syn.draw_artist(stim_scatterplot)
+
         # 3d does not support blitting
         if self.plot_3d:
             self.canva_morph_syn.draw()
@@ -873,11 +1243,62 @@ class FrameFigures(ttk.Frame):
         # update tkinter display
         root.update()
 
-    def restart_volt(self):
-        """Clean the voltage figure."""
-        (line,) = self.ax_volt.plot([], [])
-        self.ax_volt.draw_artist(line)
-        self.canva_volt.draw_idle()
+    def plot_kept_traces(self, kept_traces):
+        """Replace the traces of the previous runs on the voltage figure.
+
+        Args:
+            kept_traces (list of dicts): traces of the previous runs to overlay.
+                Each trace has a 'label', a 'time' and a 'voltage'.
+        """
+        for line in self.kept_volt_lines:
+            line.remove()
+        self.kept_volt_lines = []
+
+        for i, trace in enumerate(kept_traces or []):
+            # keep the first color of the cycle for the current run
+            (line,) = self.ax_volt.plot(
+                trace["time"],
+                trace["voltage"],
+                color=f"C{i % 9 + 1}",
+                alpha=0.6,
+                label=trace["label"],
+            )
+            self.kept_volt_lines.append(line)
+
+        if self.kept_volt_lines:
+            self.ax_volt.legend(loc="upper right", fontsize="x-small")
+        elif self.ax_volt.get_legend() is not None:
+            self.ax_volt.get_legend().remove()
+
+    def restart_volt(self, kept_traces=None):
+        """Clean the voltage figure.
+
+        Args:
+            kept_traces (list of dicts): traces of the previous runs to keep displayed
+        """
+        if self.volt_line is not None:
+            self.volt_line.set_xdata([])
+            self.volt_line.set_ydata([])
+        self.plot_kept_traces(kept_traces)
+        self.canva_volt.draw()
+
+    def save_volt_figure(self, path):
+        """Save the voltage figure.
+
+        Args:
+            path (str): path to the image file. The format is deduced from the extension.
+        """
+        self.canva_volt.figure.savefig(path)
+
+    def update_time_axis(self, total_duration):
+        """Fit the time axis of the voltage figure to the simulation duration.
+
+        Args:
+            total_duration (float): duration of the simulation (ms)
+        """
+        self.ax_volt.set_xlim([0, total_duration])
+        # redraw everything, since blitting only updates the inside of the axes
+        self.canva_volt.draw()
 
 
 class FrameMain(ttk.Frame):
@@ -893,7 +1314,14 @@ class FrameMain(ttk.Frame):
         ttk.Frame.__init__(self, parent, style="TFrame")
         self.frame_buttons = FrameButtons(self, gui)
         self.frame_figures = FrameFigures(
-            self, gui.simulation, gui.plot_3d, gui.toolbar_on, gui.figsize
+            self,
+            gui.simulation,
+            gui.plot_3d,
+            gui.toolbar_on,
+            gui.figsize,
+            kept_traces=gui.kept_traces,
+            on_pick=gui.pick_location,
+            syn_color_by=gui.syn_color_by,
         )
 
         self.frame_buttons.grid(row=0, column=0)
@@ -932,22 +1360,66 @@ class FrameMain(ttk.Frame):
         """
         self.frame_figures.update_syn_display(root, simulation)
 
-    def restart_volt(self):
-        """Clean voltage figure."""
-        self.frame_figures.restart_volt()
+    def restart_volt(self, kept_traces=None):
+        """Clean voltage figure.
+
+        Args:
+            kept_traces (list of dicts): traces of the previous runs to keep displayed
+        """
+        self.frame_figures.restart_volt(kept_traces)
+
+    def set_syn_color_by(self, root, simulation, syn_color_by):
+        """Change the synapse property giving the colors of the synapses and redraw them.
+
+        Args:
+            root (tk.Tk): root of the GUI
+            simulation (NeuronSimulation): contains simulation (and cell) data
+            syn_color_by (str): synapse property, among SYNAPSE_COLOR_MODES
+        """
+        self.frame_figures.syn_color_by = syn_color_by
+        self.frame_figures.update_syn_display(root, simulation)
+
+    def clear_kept_traces(self):
+        """Remove the traces of the previous runs from the voltage figure."""
+        self.frame_figures.plot_kept_traces([])
+        self.frame_figures.canva_volt.draw()
+
+    def save_volt_figure(self, path):
+        """Save the voltage figure.
+
+        Args:
+            path (str): path to the image file. The format is deduced from the extension.
+        """
+        self.frame_figures.save_volt_figure(path)
+
+    def update_time_axis(self, total_duration):
+        """Fit the time axis of the voltage figure to the simulation duration.
+
+        Args:
+            total_duration (float): duration of the simulation (ms)
+        """
+        self.frame_figures.update_time_axis(total_duration)
 
     def simul_on_pause(self):
-        """Disable pause button, enable continue button."""
+        """Disable pause button, enable continue & cancel buttons."""
         self.frame_buttons.simul_on_pause()
 
     def simul_running(self):
-        """Disable continue button, enable pause button."""
+        """Disable continue button, enable pause & cancel buttons."""
         self.frame_buttons.simul_running()
 
     def simul_ended(self):
-        """Disable pause & continue buttons."""
+        """Disable pause, continue & cancel buttons."""
         self.frame_buttons.simul_ended()
 
+    def set_progress(self, progress):
+        """Display the progress of the simulation.
+
+        Args:
+            progress (float): progress of the simulation (%)
+        """
+        self.frame_buttons.set_progress(progress)
+
 
 class FrameSynapses(ttk.LabelFrame):
     """Frame containing all inputs."""
@@ -968,7 +1440,7 @@ class FrameSynapses(ttk.LabelFrame):
         self.labels = []
         self.labels.append(ttk.Label(self, text="Synapses"))
         self.labels.append(ttk.Label(self, text="Start time [ms]"))
-        self.labels.append(ttk.Label(self, text="Spike Interval [ms]"))
+        self.labels.append(ttk.Label(self, text="Frequency [Hz]"))
         self.labels.append(ttk.Label(self, text="Spike number"))
         self.labels.append(ttk.Label(self, text="Noise"))
 
@@ -984,54 +1456,93 @@ class FrameSynapses(ttk.LabelFrame):
         self.mtype_buttons = []
         self.var_list = []  # 0 or 1 for each button / checkbox values
         self.id_list = []
-        self.svs = []  # entries (start, interval, number, noise) values
+        self.svs = []  # entries (start, frequency, number, noise) values
 
-        # list of lists : start, interval, number and noise
+        # list of lists : start, frequency, number and noise
         self.entries = [[] for x in range(4)]
 
+        # excitatory / inhibitory groups of mtypes
+        self.groups = gui.simulation.get_pre_mtype_groups()
+        self.group_vars = {}
+        self.group_buttons = {}
+
         # -- create buttons & parameter entries --
-        for i, id_ in enumerate(gui.simulation.available_pre_mtypes.keys()):
-            # pre-cell m-types
-            self.var_list.append(tk.IntVar())
-            self.var_list[i].set(0)
-            self.id_list.append(id_)
-            self.mtype_buttons.append(
-                ttk.Checkbutton(
-                    self,
-                    text=gui.simulation.available_pre_mtypes[id_],
-                    variable=self.var_list[i],
-                    command=lambda: self.load_current_mtype_list(gui),
-                    offvalue=0,
-                    onvalue=1,
-                )
+        row = 1
+        for group_name, mtypes in self.groups.items():
+            if not mtypes:
+                continue
+
+            # group checkbox enabling all the mtypes of the group
+            self.group_vars[group_name] = tk.IntVar()
+            self.group_vars[group_name].set(0)
+            self.group_buttons[group_name] = ttk.Checkbutton(
+                self,
+                text=group_name.capitalize(),
+                variable=self.group_vars[group_name],
+                command=lambda name=group_name: self.toggle_group(gui, name),
+                offvalue=0,
+                onvalue=1,
+            )
+            self.group_buttons[group_name].grid(
+                row=row, column=0, columnspan=5, sticky=(tk.W, tk.E), padx=2
             )
-            # synapse start, interval, number, noise
-            for j, entry in enumerate(self.entries):
-                self.svs.append(tk.StringVar())
-                entry.append(
-                    ttk.Entry(
+            self.rowconfigure(row, weight=1)
+            row += 1
+
+            for id_ in mtypes:
+                i = len(self.id_list)
+                # pre-cell m-types
+                self.var_list.append(tk.IntVar())
+                self.var_list[i].set(0)
+                self.id_list.append(id_)
+                self.mtype_buttons.append(
+                    ttk.Checkbutton(
                         self,
-                        textvariable=self.svs[4 * i + j],
-                        font=style_dict["base_font"],
-                        width=style_dict["entry_width"],
+                        text=gui.simulation.available_pre_mtypes[id_],
+                        variable=self.var_list[i],
+                        command=lambda: self.load_current_mtype_list(gui),
+                        offvalue=0,
+                        onvalue=1,
                     )
                 )
-                entry[i].config(validate="key", validatecommand=(self.reg, "%P"))
-                entry[i].state(["disabled"])
+                # synapse start, frequency, number, noise
+                for j, entry in enumerate(self.entries):
+                    self.svs.append(tk.StringVar())
+                    entry.append(
+                        ttk.Entry(
+                            self,
+                            textvariable=self.svs[4 * i + j],
+                            font=style_dict["base_font"],
+                            width=style_dict["entry_width"],
+                        )
+                    )
+                    entry[i].config(validate="key", validatecommand=(self.reg, "%P"))
+                    entry[i].state(["disabled"])
 
-            # set string variables
-            self.set_svs(gui, i)
+                # set string variables
+                self.set_svs(gui, i)
 
-        # -- add buttons & entries on the grid --
-        for i, (b, e1, e2, e3, e4) in enumerate(
-            zip(*[self.mtype_buttons] + self.entries)
-        ):
-            b.grid(row=i + 1, column=0, sticky=(tk.W, tk.E), padx=2)
-            e1.grid(row=i + 1, column=1, sticky=(tk.E), padx=2)
-            e2.grid(row=i + 1, column=2, sticky=(tk.E), padx=2)
-            e3.grid(row=i + 1, column=3, sticky=(tk.E), padx=2)
-            e4.grid(row=i + 1, column=4, sticky=(tk.E), padx=2)
-            self.rowconfigure(i + 1, weight=1)
+                # -- add button & entries on the grid --
+                self.mtype_buttons[i].grid(
+                    row=row, column=0, sticky=(tk.W, tk.E), padx=(16, 2)
+                )
+                for j, entry in enumerate(self.entries):
+                    entry[i].grid(row=row, column=j + 1, sticky=(tk.E), padx=2)
+                self.rowconfigure(row, weight=1)
+                row += 1
+
+    def toggle_group(self, gui, group_name):
+        """Enable/disable all the mtypes of a group.
+
+        Args:
+            gui (GUI): main class containing main frames and simulation
+            group_name (str): name of the group ('excitatory' or 'inhibitory')
+        """
+        value = self.group_vars[group_name].get()
+        for i, id_ in enumerate(self.id_list):
+            if id_ in self.groups[group_name]:
+                self.var_list[i].set(value)
+        self.load_current_mtype_list(gui)
 
     def toggle_button(self):
         """Enable/disable entries depending on the button status."""
@@ -1053,7 +1564,7 @@ class FrameSynapses(ttk.LabelFrame):
         # pylint: disable=cell-var-from-loop
         default_var = [
             gui.simulation.syn_start,
-            gui.simulation.syn_interval,
+            interval_to_frequency(gui.simulation.syn_interval),
             gui.simulation.syn_nmb_of_spikes,
             gui.simulation.syn_noise,
         ]
@@ -1066,6 +1577,35 @@ class FrameSynapses(ttk.LabelFrame):
                 ]: self.load_current_mtype_list(gui),
             )
 
+    def refresh(self, gui):
+        """Display the current synapse configuration of the simulation.
+
+        Args:
+            gui (GUI): main class containing main frames and simulation
+        """
+        # read the values first, since updating an entry updates the simulation
+        pre_mtypes = list(gui.simulation.pre_mtypes)
+        netstim_params = dict(gui.simulation.netstim_params)
+        default_params = [
+            gui.simulation.syn_start,
+            gui.simulation.syn_interval,
+            gui.simulation.syn_nmb_of_spikes,
+            gui.simulation.syn_noise,
+        ]
+
+        for i, id_ in enumerate(self.id_list):
+            self.var_list[i].set(int(id_ in pre_mtypes))
+        for i, id_ in enumerate(self.id_list):
+            params = list(netstim_params.get(id_, default_params))
+            params[1] = interval_to_frequency(params[1])
+            for j, param in enumerate(params):
+                self.svs[4 * i + j].set(str(param))
+        for group_name, group_var in self.group_vars.items():
+            group_mtypes = self.groups[group_name]
+            group_var.set(int(all(id_ in pre_mtypes for id_ in group_mtypes)))
+
+        self.load_current_mtype_list(gui)
+
     @staticmethod
     def check_variable(x):
         """Returns the variable if it is a positive int. Returns 0 if not.
@@ -1105,7 +1645,7 @@ class FrameSynapses(ttk.LabelFrame):
             if var.get():
                 gui.simulation.pre_mtypes.append(idx)
                 v1 = self.check_variable(e1.get())
-                v2 = self.check_variable(e2.get())
+                v2 = frequency_to_interval(self.check_variable(e2.get()))
                 v3 = self.check_variable(e3.get())
                 v4 = self.check_variable(e4.get())
                 params = [v1, v2, v3, v4]
@@ -1117,6 +1657,71 @@ class FrameSynapses(ttk.LabelFrame):
         gui.config_has_changed()
 
 
+class FrameFactsheet(ttk.LabelFrame):
+    """Frame displaying the factsheet features of the last run."""
+
+    # displayed features: (key, label, unit)
+    features = [
+        ("spike_count", "Spike count", ""),
+        ("input_resistance", "Input resistance", "MOhm"),
+        ("rheobase", "Rheobase", "nA"),
+        ("AP_half_width", "AP half-width", "ms"),
+    ]
+
+    def __init__(self, parent, title):
+        """Constructor.
+
+        Args:
+            parent (ttk.Frame): parent frame in which to embed this frame
+            title (ttk.Label): frame title to display
+        """
+        ttk.LabelFrame.__init__(self, parent, style="Boxed.TFrame", labelwidget=title)
+
+        self.value_labels = {}
+        for row, (key, label, unit) in enumerate(self.features):
+            text = f"{label} [{unit}]:" if unit else f"{label}:"
+            ttk.Label(self, text=text).grid(row=row, column=0, sticky=tk.W)
+            self.value_labels[key] = ttk.Label(self, text="-")
+            self.value_labels[key].grid(row=row, column=1, sticky=tk.E)
+
+        self.columnconfigure(1, weight=1)
+
+    @staticmethod
+    def format_value(key, value):
+        """Format a feature value to be displayed.
+
+        Args:
+            key (str): name of the feature
+            value: value of the feature. For the rheobase, (lower, upper) bounds.
+
+        Returns:
+            str: the formatted value, '-' if unknown
+        """
+        if value is None:
+            return "-"
+        if key == "rheobase":
+            lower, upper = value
+            if lower is not None and upper is not None:
+                return f"{lower:.3g} - {upper:.3g}"
+            if upper is not None:
+                return f"<= {upper:.3g}"
+            if lower is not None:
+                return f"> {lower:.3g}"
+            return "-"
+        if key == "spike_count":
+            return str(value)
+        return f"{value:.3g}"
+
+    def display(self, features):
+        """Display the features of the last run.
+
+        Args:
+            features (dict): feature values of the last run
+        """
+        for key, label in self.value_labels.items():
+            label.config(text=self.format_value(key, features.get(key)))
+
+
 class FrameConfigFig(ttk.LabelFrame):
     """Frame containing choices for figure display, such as 2d/3d or enabling toolbar."""
 
@@ -1162,6 +1767,64 @@ class FrameConfigFig(ttk.LabelFrame):
             onvalue=1,
         )
 
+        # keep traces checkbutton
+        self.keep_traces_var = tk.IntVar()
+        self.keep_traces_var.set(int(gui.keep_traces))
+        self.keep_traces_button = ttk.Checkbutton(
+            self,
+            text="keep previous traces",
+            variable=self.keep_traces_var,
+            command=lambda: self.load_keep_traces_value(gui),
+            offvalue=0,
+            onvalue=1,
+        )
+
+        # record voltage on morphology checkbutton
+        self.record_morphology_var = tk.IntVar()
+        self.record_morphology_var.set(int(gui.simulation.record_morphology))
+        self.record_morphology_button = ttk.Checkbutton(
+            self,
+            text="record voltage on morphology (for animation)",
+            variable=self.record_morphology_var,
+            command=lambda: self.load_record_morphology_value(gui),
+            offvalue=0,
+            onvalue=1,
+        )
+
+        # what a click on a segment of the synapse figure does
+        self.pick_mode_var = tk.StringVar()
+        self.pick_mode_var.set(gui.pick_mode)
+        self.pick_mode_label = ttk.Label(self, text="Click on a segment to:")
+        self.pick_mode_buttons = [
+            ttk.Radiobutton(
+                self,
+                text=text,
+                variable=self.pick_mode_var,
+                value=value,
+                command=lambda: self.load_pick_mode_value(gui),
+            )
+            for text, value in [
+                ("do nothing", "none"),
+                ("add/remove a recording", "recording"),
+                ("move the stimuli", "stimulus"),
+            ]
+        ]
+
+        # synapse colors
+        self.syn_color_by_var = tk.StringVar()
+        self.syn_color_by_var.set(gui.syn_color_by)
+        self.syn_color_by_label = ttk.Label(self, text="Color synapses by:")
+        self.syn_color_by_combobox = ttk.Combobox(
+            self,
+            textvariable=self.syn_color_by_var,
+            values=SYNAPSE_COLOR_MODES,
+            state="readonly",
+            font=get_style_cst()["base_font"],
+        )
+        self.syn_color_by_combobox.bind(
+            "<<ComboboxSelected>>", lambda _: self.load_syn_color_by_value(gui)
+        )
+
         # figsize choice
         self.figsize_var = tk.StringVar()
         self.figsize_var.set(str(gui.figsize))
@@ -1198,16 +1861,47 @@ class FrameConfigFig(ttk.LabelFrame):
         self.figsize_small_button.grid(row=4, column=0, sticky=(tk.W, tk.E))
         self.figsize_medium_button.grid(row=4, column=1, sticky=(tk.W, tk.E))
         self.figsize_large_button.grid(row=4, column=2, sticky=(tk.W, tk.E))
+        self.keep_traces_button.grid(
+            row=5, column=0, columnspan=3, sticky=(tk.W, tk.E)
+        )
+        self.record_morphology_button.grid(
+            row=6, column=0, columnspan=3, sticky=(tk.W, tk.E)
+        )
+        self.pick_mode_label.grid(row=7, column=0, columnspan=3, sticky=(tk.W, tk.E))
+        for i, button in enumerate(self.pick_mode_buttons):
+            button.grid(row=8, column=i, sticky=(tk.W, tk.E))
+        self.syn_color_by_label.grid(row=9, column=0, sticky=(tk.W, tk.E))
+        self.syn_color_by_combobox.grid(
+            row=9, column=1, columnspan=2, sticky=(tk.W, tk.E)
+        )
 
         self.rowconfigure(0, weight=1)
         self.rowconfigure(1, weight=1)
         self.rowconfigure(2, weight=1)
         self.rowconfigure(3, weight=1)
         self.rowconfigure(4, weight=1)
+        self.rowconfigure(5, weight=1)
+        self.rowconfigure(6, weight=1)
+        self.rowconfigure(7, weight=1)
+        self.rowconfigure(8, weight=1)
+        self.rowconfigure(9, weight=1)
         self.columnconfigure(0, weight=1)
         self.columnconfigure(1, weight=1)
         self.columnconfigure(2, weight=1)
 
+    def refresh(self, gui):
+        """Display the current display configuration of the gui.
+
+        Args:
+            gui (GUI): main class containing main frames and simulation
+        """
+        self.plot_3d_var.set(int(gui.plot_3d))
+        self.toolbar_var.set(int(gui.toolbar_on))
+        self.figsize_var.set(str(gui.figsize))
+        self.keep_traces_var.set(int(gui.keep_traces))
+        self.pick_mode_var.set(gui.pick_mode)
+        self.syn_color_by_var.set(gui.syn_color_by)
+
     def load_toolbar_value(self, gui):
         """Change toolbar value in gui and reload figure frame.
 
@@ -1221,6 +1915,42 @@ class FrameConfigFig(ttk.LabelFrame):
 
         gui.reload_figure_frame()
 
+    def load_keep_traces_value(self, gui):
+        """Change whether the traces of the previous runs are kept on the voltage figure.
+
+        Args:
+            gui (GUI): main class containing main frames and simulation
+        """
+        gui.keep_traces = bool(self.keep_traces_var.get())
+
+    def load_pick_mode_value(self, gui):
+        """Change what a click on a segment of the synapse figure does.
+
+        Args:
+            gui (GUI): main class containing main frames and simulation
+        """
+        gui.pick_mode = self.pick_mode_var.get()
+
+    def load_syn_color_by_value(self, gui):
+        """Change the synapse property giving the colors of the synapses.
+
+        Args:
+            gui (GUI): main class containing main frames and simulation
+        """
+        gui.syn_color_by = self.syn_color_by_var.get()
+        gui.frames["FrameMain"].set_syn_color_by(
+            gui.root, gui.simulation, gui.syn_color_by
+        )
+
+    def load_record_morphology_value(self, gui):
+        """Change whether the voltage of all the segments is recorded.
+
+        Args:
+            gui (GUI): main class containing main frames and simulation
+        """
+        gui.simulation.record_morphology = bool(self.record_morphology_var.get())
+        gui.config_has_changed()
+
     def load_plot_3d_value(self, gui):
         """Change figure display in gui and reload figure frame.
 
@@ -0,0 +1,210 @@
+"""Headless mode saving the figure panels of the GUI to image files."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import logging
+from pathlib import Path
+
+import numpy as np
+from matplotlib import cm, rcParams
+from matplotlib.figure import Figure
+
+from emodelrunner.GUI_utils.plotshape import get_morph_lines, get_synapse_colors
+from emodelrunner.GUI_utils.simulator import NeuronSimulation
+from emodelrunner.GUI_utils.style import set_matplotlib_style
+
+logger = logging.getLogger(__name__)
+
+# same axes as in the GUI: z on the x-axis, x on the y-axis
+XAXIS = 2
+YAXIS = 0
+ZAXIS = 1
+
+
+def plot_voltage_morphology(ax, simulation, val_min=-80, val_max=30):
+    """Plot the morphology colored by the current voltage of each segment.
+
+    Args:
+        ax (matplotlib.axes.Axes): axis
+        simulation (NeuronSimulation): contains simulation (and cell) data
+        val_min (int): minimum voltage for colormap
+        val_max (int): maximum voltage for colormap
+    """
+    ax.set_aspect(aspect=1)
+    get_morph_lines(
+        ax=ax,
+        sim=simulation.sim,
+        val_min=val_min,
+        val_max=val_max,
+        cmap=cm.plasma,
+        do_plot=True,
+        xaxis=XAXIS,
+        yaxis=YAXIS,
+        zaxis=ZAXIS,
+    )
+
+
+def plot_synapse_morphology(ax, simulation, size_scatter=6, color_by="type"):
+    """Plot the morphology with the selected synapses, the recording sites and the stimuli.
+
+    Args:
+        ax (matplotlib.axes.Axes): axis
+        simulation (NeuronSimulation): contains simulation (and cell) data,
+            with the synapse and recording display data loaded
+        size_scatter (int): size of synapses for scatter plot
+        color_by (str): synapse property giving the colors of the synapses,
+            among SYNAPSE_COLOR_MODES. The synapses of all the mtypes are displayed
+            when colored by activation, only the ones of the selected mtypes otherwise.
+    """
+    ax.set_aspect(aspect=1)
+    get_morph_lines(
+        ax=ax,
+        sim=simulation.sim,
+        do_plot=True,
+        cmap=None,
+        xaxis=XAXIS,
+        yaxis=YAXIS,
+        zaxis=ZAXIS,
+    )
+
+    if color_by == "activation":
+        mtypes = simulation.available_pre_mtypes
+    else:
+        mtypes = simulation.pre_mtypes
+    for mtype in mtypes:
+        data = np.array(simulation.syn_display_data[mtype])
+        if data.size:
+            colors = get_synapse_colors(simulation, mtype, color_by)
+            ax.scatter(data[:, XAXIS], data[:, YAXIS], s=size_scatter, c=colors)
+
+    if simulation.rec_display_data:
+        data = np.array(simulation.rec_display_data)
+        ax.scatter(
+            data[:, XAXIS], data[:, YAXIS], s=size_scatter * 8, c="blue", marker="*"
+        )
+
+    if simulation.stim_display_data is not None:
+        data = simulation.stim_display_data
+        ax.scatter(
+            [data[XAXIS]], [data[YAXIS]], s=size_scatter * 8, c="green", marker="^"
+        )
+
+
+def plot_voltage(ax, simulation):
+    """Plot the somatic voltage.
+
+    Args:
+        ax (matplotlib.axes.Axes): axis
+        simulation (NeuronSimulation): contains simulation (and cell) data
+    """
+    t, v = simulation.get_voltage()
+    ax.plot(t, v, color=rcParams["lines.color"])
+    ax.set_xlim([0, simulation.protocol.total_duration])
+    ax.set_ylim([-90, 40])
+    ax.set_xlabel("t [ms]")
+    ax.set_ylabel("v [mV]")
+
+
+def run_simulation(simulation):
+    """Run the whole simulation.
+
+    Args:
+        simulation (NeuronSimulation): instantiated simulation
+    """
+    simulation.advance()
+
+
+def save_gui_figures(simulation, output_dir, image_format="png"):
+    """Save the figure panels of the GUI to image files.
+
+    Args:
+        simulation (NeuronSimulation): simulation after the run,
+            with the synapse and recording display data loaded
+        output_dir (str): directory in which to save the images
+        image_format (str): format of the images, e.g. 'png', 'svg' or 'pdf'
+
+    Returns:
+        list of Path: paths to the saved images
+    """
+    output_dir = Path(output_dir)
+    output_dir.mkdir(parents=True, exist_ok=True)
+
+    panels = [
+        ("morphology_voltage", (4, 4), plot_voltage_morphology),
+        ("morphology_synapses", (4, 4), plot_synapse_morphology),
+        ("voltage", (6, 2.5), plot_voltage),
+    ]
+    paths = []
+    for name, figsize, plot_function in panels:
+        fig = Figure(figsize=figsize)
+        ax = fig.add_subplot(111)
+        plot_function(ax, simulation)
+        fig.tight_layout()
+
+        path = output_dir / f"{name}.{image_format}"
+        fig.savefig(path)
+        logger.info("Saved %s", path)
+        paths.append(path)
+
+    return paths
+
+
+def read_session(path):
+    """Read a GUI session file.
+
+    Args:
+        path (str): path to the session json file
+
+    Returns:
+        dict: state of the GUI
+    """
+    with open(path, "r", encoding="utf-8") as session_file:
+        return json.load(session_file)
+
+
+def run_headless(
+    config_path, output_dir, session=None, image_format="png", theme="light"
+):
+    """Load a config, run its default protocol and save the figure panels of the GUI.
+
+    No window is opened, so that it can be used without X server,
+    e.g. to generate documentation figures or to smoke-test the display code.
+
+    Args:
+        config_path (str): path to the config file used by NeuronSimulation
+        output_dir (str): directory in which to save the images
+        session (dict): GUI session whose stimuli and synapse settings are used
+            instead of the default ones. Optional.
+        image_format (str): format of the images, e.g. 'png', 'svg' or 'pdf'
+        theme (str): colors theme. can be "light" or "dark".
+
+    Returns:
+        list of Path: paths to the saved images
+    """
+    set_matplotlib_style(theme)
+
+    simulation = NeuronSimulation(config_path=config_path)
+    if session is not None:
+        simulation.set_state(session)
+    simulation.load_cell_sim()
+    simulation.load_protocol()
+    simulation.instantiate()
+    simulation.load_synapse_display_data()
+    simulation.load_recording_display_data()
+
+    run_simulation(simulation)
+
+    return save_gui_figures(simulation, output_dir, image_format)
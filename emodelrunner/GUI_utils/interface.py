@@ -15,39 +15,86 @@
 # limitations under the License.
 
 # pylint: disable=import-error
+import json
+import os
 import tkinter as tk
-from tkinter import ttk
+from tkinter import filedialog, messagebox, ttk
 import time
 
 from emodelrunner.GUI_utils.simulator import NeuronSimulation
-from emodelrunner.GUI_utils.frames import FrameMain, FrameConfig, FrameSynapses
-from emodelrunner.GUI_utils.style import define_style, set_matplotlib_style
+from emodelrunner.factsheets.physiology_features import estimate_rheobase
+from emodelrunner.GUI_utils.animation import WindowMorphologyAnimation
+from emodelrunner.GUI_utils.headless import read_session
+from emodelrunner.GUI_utils.frames import (
+    FrameMain,
+    FrameConfig,
+    FrameSynapses,
+    FrameFactsheet,
+)
+from emodelrunner.GUI_utils.style import (
+    define_style,
+    get_scaling_factor,
+    get_style_cst,
+    set_matplotlib_style,
+    set_tk_scaling,
+)
 
 
 class GUI:
     """GUI class. Contains all frames and simulation.
 
     Attributes:
+        config_path (str): path to the config file used by NeuronSimulation
         simulation (NeuronSimulation): contains BluePyOpt simulation (and cell) data
         play (bool): if True, runs the simulation
         refresh_display_dt (float): timestep (s) for the display of figures
+        last_refresh_time (float): wall-clock time (s) of the last display update
+        last_check_t (float): simulation time (ms) of the last voltage change check
         plot_3d (bool): set to True to plot the cell shapes in 3D
         toolbar_on (bool): set to True to display the matplotlib toolbars
         figsize (str): figures size. can be "small", "medium", or "large".
+        keep_traces (bool): set to True to keep the traces of the previous runs
+            on the voltage figure
+        kept_traces (list of dicts): traces of the previous runs displayed on the voltage figure.
+            Each trace has a 'label', a 'time' and a 'voltage'.
+        run_label (str): label describing the stimuli of the current run
+        rheobase_steps (dict): step amplitudes (nA) of the runs that made the cell spike
+            ('spiking') or not ('silent'), for each rheobase condition of the simulation
+        theme (str): colors theme. can be "light" or "dark".
+        pick_mode (str): what a click on a segment of the synapse figure does.
+            can be "none", "recording" (add or remove a recording site)
+            or "stimulus" (move the step and holding stimuli).
+        syn_color_by (str): synapse property giving the colors of the synapses,
+            among SYNAPSE_COLOR_MODES
+        scaling (float): factor by which the widgets and figures are scaled
         root (tk.Tk): root of the GUI
         style (ttk.Style): style of the tkinter objects
         frames (dict of ttk.Frames): main frames embedded in root
         reload (bool): if True, the simulation has to be reloaded
+        cancel_requested (bool): if True, the running simulation has to be aborted
     """
 
-    def __init__(self, fps=15, config_path="config/config_allsteps.ini"):
+    def __init__(
+        self,
+        fps=15,
+        config_path="config/config_allsteps.ini",
+        theme="light",
+        scaling=None,
+    ):
         """Constructor.
 
         Args:
             fps (int): frames per second for the figure display
             config_path (str): path to the config file used by NeuronSimulation
+            theme (str): colors theme. can be "light" or "dark".
+            scaling (float): factor by which the widgets and figures are scaled.
+                If None, it is computed from the resolution of the screen.
+
+        Raises:
+            ValueError: if fps is not strictly positive
         """
         # init simulation
+        self.config_path = config_path
         self.simulation = NeuronSimulation(config_path=config_path)
 
         # load cell, simulation, and protocol(s)
@@ -56,29 +103,48 @@ class GUI:
 
         self.simulation.instantiate()
         self.simulation.load_synapse_display_data()
+        self.simulation.load_recording_display_data()
 
         # display params
         self.play = False
         self.refresh_display_dt = self.get_refresh_from_fps(fps)
+        self.last_refresh_time = time.time()
+        self.last_check_t = 0
         self.plot_3d = False
         self.toolbar_on = False
         self.figsize = "medium"
+        self.keep_traces = False
+        self.kept_traces = []
+        self.run_label = self.get_run_label()
+        self.rheobase_steps = {}
+        self.theme = theme
+        self.pick_mode = "none"
+        self.syn_color_by = "type"
 
         # Tkinter
         self.root = tk.Tk()
         self.root.title("ME-type models launcher & visualisation interface")
 
+        # scale fonts, widgets and figures on high resolution screens
+        self.scaling = get_scaling_factor(self.root, scaling)
+        set_tk_scaling(self.root, self.scaling)
+
         # ttk style
         self.style = ttk.Style()
         self.style.theme_use("clam")  # to be able to change background, etc. on macos
-        define_style(self.style)
-        set_matplotlib_style()
+        define_style(self.style, self.theme, self.scaling)
+        set_matplotlib_style(self.theme, self.scaling)
+        self.root.configure(
+            background=get_style_cst(self.theme, self.scaling)["background"]
+        )
 
         # frames
         self.frames = {}
         self.create_frames()
+        self.create_menu()
 
         self.reload = False
+        self.cancel_requested = False
 
     @staticmethod
     def get_refresh_from_fps(fps):
@@ -87,9 +153,14 @@ class GUI:
         Args:
             fps (float): number of frames per second to be displayed
 
+        Raises:
+            ValueError: if fps is not strictly positive
+
         Returns:
             float: refresh time (s)
         """
+        if fps <= 0:
+            raise ValueError(f"The number of frames per second {fps} should be > 0.")
         return 1.0 / fps
 
     def create_frames(self):
@@ -100,23 +171,191 @@ class GUI:
         title_synapses = ttk.Label(self.root, text="Synapse Stimuli configuration")
         self.frames["FrameSynapses"] = FrameSynapses(self.root, self, title_synapses)
 
+        title_factsheet = ttk.Label(self.root, text="Factsheet of the last run")
+        self.frames["FrameFactsheet"] = FrameFactsheet(self.root, title_factsheet)
+
         self.frames["FrameConfig"].grid(
-            row=0, column=0, sticky=(tk.W, tk.E, tk.N, tk.S), padx=2, pady=2
+            row=0, column=0, rowspan=2, sticky=(tk.W, tk.E, tk.N, tk.S), padx=2, pady=2
         )
         self.frames["FrameMain"].grid(
-            row=0, column=1, sticky=(tk.W, tk.E, tk.N, tk.S), pady=2
+            row=0, column=1, rowspan=2, sticky=(tk.W, tk.E, tk.N, tk.S), pady=2
         )
         self.frames["FrameSynapses"].grid(
             row=0, column=2, sticky=(tk.W, tk.E, tk.N, tk.S), padx=2, pady=2
         )
+        self.frames["FrameFactsheet"].grid(
+            row=1, column=2, sticky=(tk.W, tk.E, tk.N, tk.S), padx=2, pady=2
+        )
 
         self.root.columnconfigure(0, weight=1)
         self.root.columnconfigure(1, weight=1)
         self.root.columnconfigure(2, weight=1)
         self.root.rowconfigure(0, weight=1)
 
+    def create_menu(self):
+        """Create the menu to save and load sessions, and to save the recording sites."""
+        menubar = tk.Menu(self.root)
+        session_menu = tk.Menu(menubar, tearoff=0)
+        session_menu.add_command(label="Save session...", command=self.save_session)
+        session_menu.add_command(label="Load session...", command=self.load_session)
+        menubar.add_cascade(label="Session", menu=session_menu)
+        config_menu = tk.Menu(menubar, tearoff=0)
+        config_menu.add_command(
+            label="Save recording sites to config...", command=self.save_sites
+        )
+        menubar.add_cascade(label="Config", menu=config_menu)
+        self.root.config(menu=menubar)
+
+    def get_session(self):
+        """Get the current state of the GUI.
+
+        Returns:
+            dict containing the config path, the stimuli and synapse settings
+            of the simulation, and the display settings
+        """
+        session = {"config_path": self.config_path}
+        session.update(self.simulation.get_state())
+        session["display"] = {
+            "plot_3d": self.plot_3d,
+            "toolbar_on": self.toolbar_on,
+            "figsize": self.figsize,
+            "keep_traces": self.keep_traces,
+            "syn_color_by": self.syn_color_by,
+        }
+        return session
+
+    def set_session(self, session):
+        """Restore a state of the GUI.
+
+        Args:
+            session (dict): state of the GUI, as returned by get_session
+
+        Raises:
+            ValueError: if the session has been saved with another config file
+        """
+        if session["config_path"] != self.config_path:
+            raise ValueError(
+                f"The session has been saved with the config {session['config_path']}. "
+                "Launch the GUI with this config to restore it."
+            )
+
+        self.simulation.set_state(session)
+        for attr, value in session["display"].items():
+            setattr(self, attr, value)
+
+        self.frames["FrameConfig"].frame_config_fig.refresh(self)
+        self.frames["FrameConfig"].frame_conductances.refresh(self)
+        self.refresh_protocol_config()
+        self.frames["FrameSynapses"].refresh(self)
+        self.config_has_changed()
+        self.reload_figure_frame()
+
+    @staticmethod
+    def read_session(path):
+        """Read a session file.
+
+        Args:
+            path (str): path to the session json file
+
+        Returns:
+            dict: state of the GUI
+        """
+        return read_session(path)
+
+    def write_session(self, path):
+        """Write the current state of the GUI in a session file.
+
+        Args:
+            path (str): path to the session json file
+        """
+        with open(path, "w", encoding="utf-8") as session_file:
+            json.dump(self.get_session(), session_file, indent=4)
+
+    def save_session(self):
+        """Ask for a session file and save the current state of the GUI into it."""
+        path = filedialog.asksaveasfilename(
+            parent=self.root,
+            title="Save session",
+            defaultextension=".json",
+            filetypes=[("JSON file", "*.json")],
+        )
+        # user cancelled
+        if not path:
+            return
+        self.write_session(path)
+
+    def load_session(self):
+        """Ask for a session file and restore the state of the GUI from it."""
+        path = filedialog.askopenfilename(
+            parent=self.root,
+            title="Load session",
+            filetypes=[("JSON file", "*.json")],
+        )
+        # user cancelled
+        if not path:
+            return
+        try:
+            self.set_session(self.read_session(path))
+        except ValueError as exc:
+            messagebox.showerror("Cannot load session", str(exc))
+
+    def save_sites(self):
+        """Ask for a config file and save the recording sites and stimulus location in it."""
+        path = filedialog.asksaveasfilename(
+            parent=self.root,
+            title="Save recording sites to config",
+            initialfile=os.path.basename(self.config_path),
+            defaultextension=".ini",
+            filetypes=[("Config file", "*.ini")],
+        )
+        # user cancelled
+        if not path:
+            return
+        self.simulation.save_sites_to_config(path)
+
+    def pick_location(self, segment_index):
+        """Add or remove a recording site, or move the stimuli, at a clicked segment.
+
+        Args:
+            segment_index (int): index of the segment, in the order of the morphology plot
+        """
+        if self.pick_mode == "none" or self.play:
+            return
+        try:
+            location = self.simulation.get_segment_location(segment_index)
+        except ValueError as exc:
+            messagebox.showerror("Cannot pick this segment", str(exc))
+            return
+
+        if self.pick_mode == "recording":
+            self.simulation.toggle_recording_location(location)
+        else:
+            self.simulation.stimulus_location = location
+
+        # the protocol has to be reloaded with the new locations
+        self.keep_current_trace()
+        self.reload_params()
+        self.simulation.load_recording_display_data()
+        self.frames["FrameMain"].update_syn_display(self.root, self.simulation)
+
+    def refresh_protocol_config(self):
+        """Display the current protocol configuration of the simulation."""
+        self.frames["FrameConfig"].frame_protocols.refresh(self)
+
+    def get_progress(self):
+        """Get the progress of the simulation.
+
+        Returns:
+            float: progress of the simulation (%)
+        """
+        tstop = self.simulation.sim.neuron.h.tstop
+        if tstop <= 0:
+            return 0.0
+        return min(100.0, 100.0 * self.simulation.sim.neuron.h.t / tstop)
+
     def update_figures(self):
-        """Update the figures."""
+        """Update the figures and the progress bar."""
+        self.frames["FrameMain"].set_progress(self.get_progress())
         self.frames["FrameMain"].display(self.root, self.simulation)
 
     def check_v_change(self):
@@ -129,9 +368,72 @@ class GUI:
         """
         return self.frames["FrameMain"].check_change(self.root, self.simulation)
 
+    def get_run_label(self):
+        """Get a label describing the stimuli of the current simulation.
+
+        Returns:
+            str: label of the run
+        """
+        label = (
+            f"step {self.simulation.step_stim} nA, holding {self.simulation.hypamp} nA"
+        )
+        if self.simulation.pre_mtypes:
+            label += f", {len(self.simulation.pre_mtypes)} synapse type(s)"
+        for name, scale in sorted(self.simulation.conductance_scales.items()):
+            if scale != 1.0:
+                label += f", {name} x{scale:.2f}"
+        return label
+
+    def keep_current_trace(self):
+        """Store the trace of the last run, if any, to overlay it on the next runs."""
+        if self.keep_traces and self.simulation.sim.neuron.h.t > 0:
+            t, v = self.simulation.get_voltage()
+            self.kept_traces.append(
+                {
+                    "label": f"run {len(self.kept_traces) + 1}: {self.run_label}",
+                    "time": t,
+                    "voltage": v,
+                }
+            )
+
+    def update_factsheet(self):
+        """Display the factsheet features of the last run.
+
+        The rheobase is bracketed using all the runs with the same holding stimulus,
+        step timing, conductance scales and synapses.
+        """
+        features = self.simulation.get_step_features()
+
+        steps = self.rheobase_steps.setdefault(
+            self.simulation.get_rheobase_condition(), {"spiking": [], "silent": []}
+        )
+        if features["spike_count"] > 0:
+            steps["spiking"].append(self.simulation.step_stim)
+        else:
+            steps["silent"].append(self.simulation.step_stim)
+        features["rheobase"] = estimate_rheobase(steps["spiking"], steps["silent"])
+
+        self.frames["FrameFactsheet"].display(features)
+
     def clear_voltage_figure(self):
         """Clear the voltage figure."""
-        self.frames["FrameMain"].restart_volt()
+        self.frames["FrameMain"].restart_volt(self.kept_traces)
+
+    def clear_traces(self):
+        """Remove the traces of the previous runs from the voltage figure."""
+        self.kept_traces = []
+        self.frames["FrameMain"].clear_kept_traces()
+
+    def set_conductance_scale(self, conductance_name, scale):
+        """Scale a channel conductance, then re-instantiate the cell and re-run.
+
+        Args:
+            conductance_name (str): name of the conductance, e.g. 'gNaTgbar_NaTg'
+            scale (float): scaling factor. 1 to use the optimised conductance.
+        """
+        self.simulation.set_conductance_scale(conductance_name, scale)
+        self.config_has_changed()
+        self.start()
 
     def config_has_changed(self):
         """Stop the simulation when the user has changed configuration."""
@@ -141,44 +443,61 @@ class GUI:
     def run_simul(self, check_dt=1.5):
         """Main loop for running simulation.
 
+        The figures are updated from the simulation callback while it is running.
+
         Args:
             check_dt (float): check for significant voltage change every check_dt (ms)
                 (simulation time)
         """
         # for refreshing rate
-        t1 = time.time()
+        self.last_refresh_time = time.time()
         # for fine display of peaks
-        last_t = self.simulation.sim.neuron.h.t  # = 0
+        self.last_check_t = self.simulation.sim.neuron.h.t  # = 0
 
-        while (
-            self.play
-            and self.simulation.sim.neuron.h.t
-            < self.simulation.sim.neuron.h.tstop - self.simulation.sim.neuron.h.dt / 2
-        ):
-            self.simulation.sim.neuron.h.fadvance()
-            if self.simulation.sim.neuron.h.t > last_t + check_dt:
-                last_t = self.simulation.sim.neuron.h.t
-                # check for big change in voltage. Update display if big change found.
-                updated = self.check_v_change()
-                if updated:
-                    t1 = time.time()
-            if time.time() - t1 > self.refresh_display_dt:
-                self.update_figures()
-                last_t = self.simulation.sim.neuron.h.t
-                t1 = time.time()
+        if self.play:
+            self.simulation.advance(lambda: self.simul_callback(check_dt))
+
+        # the user cancelled the run while the figures were updated
+        if self.cancel_requested:
+            self.abort_simul()
+            return
 
         self.update_figures()
         self.play = False
 
-        if (
-            self.simulation.sim.neuron.h.t
-            >= self.simulation.sim.neuron.h.tstop - self.simulation.sim.neuron.h.dt / 2
-        ):
+        if self.simulation.is_finished():
+            self.update_factsheet()
             # change buttons state
             self.end_simul()
 
+    def simul_callback(self, check_dt):
+        """Update the display during the run, at most every refresh_display_dt (s).
+
+        Args:
+            check_dt (float): check for significant voltage change every check_dt (ms)
+                (simulation time)
+
+        Returns:
+            bool: False if the run has to stop, i.e. if it was paused or cancelled
+        """
+        t = self.simulation.sim.neuron.h.t
+        if t > self.last_check_t + check_dt:
+            self.last_check_t = t
+            # check for big change in voltage. Update display if big change found.
+            if self.check_v_change():
+                self.last_refresh_time = time.time()
+        if time.time() - self.last_refresh_time > self.refresh_display_dt:
+            self.update_figures()
+            self.last_check_t = t
+            self.last_refresh_time = time.time()
+        return self.play
+
     def start(self):
         """Start the simulation from beginning. Reload simulation config if needed."""
+        # store last trace before it is destroyed
+        self.keep_current_trace()
+        self.run_label = self.get_run_label()
+
         # if config has changed: reload cell, sim, protocol, and figure frame
         if self.reload:
             self.reload_params()
@@ -212,6 +531,10 @@ class GUI:
 
         # clear voltage data
         self.clear_voltage_figure()
+        # the simulation duration may have changed
+        self.frames["FrameMain"].update_time_axis(
+            self.simulation.protocol.total_duration
+        )
         # display figures after simulation has been reset
         self.frames["FrameMain"].display(self.root, self.simulation)
 
@@ -228,7 +551,7 @@ class GUI:
         # reload figure frame
         self.frames["FrameMain"] = FrameMain(self.root, self)
         self.frames["FrameMain"].grid(
-            row=0, column=1, sticky=(tk.W, tk.E, tk.N, tk.S), pady=2
+            row=0, column=1, rowspan=2, sticky=(tk.W, tk.E, tk.N, tk.S), pady=2
         )
         self.frames["FrameMain"].update_syn_display(self.root, self.simulation)
 
@@ -241,6 +564,31 @@ class GUI:
 
         self.play = False
 
+    def cancel(self):
+        """Cancel the simulation.
+
+        If the simulation is running, it is aborted at the end of the current time step.
+        """
+        if self.play:
+            # let run_simul exit its loop before destroying the simulation
+            self.cancel_requested = True
+            self.play = False
+        else:
+            self.abort_simul()
+
+    def abort_simul(self):
+        """Abort the simulation and reset it to its initial state."""
+        self.cancel_requested = False
+        self.play = False
+
+        self.simulation.destroy()
+        self.simulation.sim.neuron.h.t = 0
+        self.simulation.instantiate()
+
+        self.clear_voltage_figure()
+        self.frames["FrameMain"].set_progress(0)
+        self.end_simul()
+
     def continue_simul(self):
         """Unpause the simulation."""
         # change buttons state
@@ -250,6 +598,46 @@ class GUI:
         self.play = True
         self.run_simul()
 
+    def export_traces(self):
+        """Save the displayed voltage trace as an image (png or svg) or as csv."""
+        path = filedialog.asksaveasfilename(
+            parent=self.root,
+            title="Export traces",
+            defaultextension=".png",
+            filetypes=[
+                ("PNG image", "*.png"),
+                ("SVG image", "*.svg"),
+                ("CSV file", "*.csv"),
+            ],
+        )
+        # user cancelled
+        if not path:
+            return
+
+        if path.lower().endswith(".csv"):
+            self.simulation.save_voltage_csv(path)
+        else:
+            self.frames["FrameMain"].save_volt_figure(path)
+
+    def animate_morphology(self):
+        """Open a window animating the voltage of the last run on the morphology."""
+        if (
+            self.simulation.morph_recordings is None
+            or self.simulation.sim.neuron.h.t == 0
+        ):
+            messagebox.showerror(
+                "Cannot animate",
+                "Check 'record voltage on morphology' in the display configuration, "
+                "then run the simulation.",
+            )
+            return
+        WindowMorphologyAnimation(
+            self.root,
+            self.simulation,
+            get_style_cst(self.theme, self.scaling)["background"],
+            plot_3d=self.plot_3d,
+        )
+
     def end_simul(self):
         """End the simulation."""
         self.frames["FrameMain"].simul_ended()
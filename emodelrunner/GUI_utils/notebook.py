@@ -0,0 +1,247 @@
+"""Jupyter notebook interface, based on ipywidgets."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# pylint: disable=import-error
+import io
+
+import ipywidgets as widgets
+from matplotlib.figure import Figure
+
+from emodelrunner.GUI_utils.headless import plot_synapse_morphology
+from emodelrunner.GUI_utils.simulator import NeuronSimulation
+
+# simulation attributes that can be set from the stimuli widgets, with their description
+STIMULUS_ATTRIBUTES = {
+    "step_stim": "Step [nA]",
+    "step_delay": "Step delay [ms]",
+    "step_duration": "Step duration [ms]",
+    "hypamp": "Holding [nA]",
+    "hold_step_delay": "Holding delay [ms]",
+    "hold_step_duration": "Holding duration [ms]",
+    "total_duration": "Total duration [ms]",
+}
+
+CUSTOM_PROTOCOL = "custom"
+
+
+def figure_to_png(fig):
+    """Render a figure as png.
+
+    Args:
+        fig (matplotlib.figure.Figure): figure to render
+
+    Returns:
+        bytes: the png image
+    """
+    buffer = io.BytesIO()
+    fig.savefig(buffer, format="png")
+    return buffer.getvalue()
+
+
+class NotebookGUI:
+    """Notebook interface exposing the same controls as the tkinter GUI.
+
+    It does not need any X server, and can thus be used on remote clusters and in JupyterHub.
+
+    Attributes:
+        simulation (NeuronSimulation): contains BluePyOpt simulation (and cell) data
+        n_updates (int): number of times the voltage figure is updated during a run
+        stim_widgets (dict): FloatText widgets for each stimulus attribute of the simulation
+        protocol_widget (ipywidgets.Dropdown): choice of the StepProtocol to load
+        syn_widgets (dict): for each pre-synaptic mtype, checkbox and
+            IntText widgets (start, interval, number, noise)
+        run_button (ipywidgets.Button): button to run the simulation
+        progress (ipywidgets.FloatProgress): progress of the simulation (%)
+        fig_volt (matplotlib.figure.Figure): figure of the somatic voltage
+        ax_volt (matplotlib.axes.Axes): axis of the somatic voltage
+        volt_line (matplotlib.lines.Line2D): line of the somatic voltage
+        volt_image (ipywidgets.Image): displayed voltage figure
+        morph_image (ipywidgets.Image): displayed morphology with synapses figure
+        widget (ipywidgets.VBox): the whole interface, to be displayed in the notebook
+    """
+
+    def __init__(self, config_path="config/config_allsteps.ini", n_updates=20):
+        """Constructor.
+
+        Args:
+            config_path (str): path to the config file used by NeuronSimulation
+            n_updates (int): number of times the voltage figure is updated during a run
+        """
+        self.simulation = NeuronSimulation(config_path=config_path)
+        self.simulation.load_cell_sim()
+        self.simulation.load_protocol()
+        self.simulation.instantiate()
+        self.simulation.load_synapse_display_data()
+        self.simulation.load_recording_display_data()
+
+        self.n_updates = n_updates
+
+        # stimuli
+        self.protocol_widget = widgets.Dropdown(
+            options=[CUSTOM_PROTOCOL] + list(self.simulation.step_protocols.keys()),
+            value=CUSTOM_PROTOCOL,
+            description="Protocol",
+        )
+        self.protocol_widget.observe(self.load_step_protocol, names="value")
+        self.stim_widgets = {
+            attr: widgets.FloatText(
+                value=getattr(self.simulation, attr), description=description
+            )
+            for attr, description in STIMULUS_ATTRIBUTES.items()
+        }
+
+        # synapses
+        self.syn_widgets = {}
+        for mtype, mtype_name in self.simulation.available_pre_mtypes.items():
+            self.syn_widgets[mtype] = [
+                widgets.Checkbox(value=False, description=mtype_name)
+            ]
+            for value in [
+                self.simulation.syn_start,
+                self.simulation.syn_interval,
+                self.simulation.syn_nmb_of_spikes,
+                self.simulation.syn_noise,
+            ]:
+                self.syn_widgets[mtype].append(
+                    widgets.IntText(value=value, layout=widgets.Layout(width="80px"))
+                )
+
+        # run
+        self.run_button = widgets.Button(description="Run")
+        self.run_button.on_click(lambda _: self.run())
+        self.progress = widgets.FloatProgress(value=0, min=0, max=100)
+
+        # figures
+        self.fig_volt = Figure(figsize=(6, 2.5))
+        self.ax_volt = self.fig_volt.add_subplot(111)
+        self.ax_volt.set_xlabel("t [ms]")
+        self.ax_volt.set_ylabel("v [mV]")
+        self.ax_volt.set_ylim([-90, 40])
+        self.fig_volt.subplots_adjust(bottom=0.2)
+        (self.volt_line,) = self.ax_volt.plot([], [])
+        self.volt_image = widgets.Image(format="png")
+        self.morph_image = widgets.Image(format="png")
+        self.update_volt_figure()
+        self.update_morph_figure()
+
+        self.widget = self.create_layout()
+
+    def create_layout(self):
+        """Arrange the widgets.
+
+        Returns:
+            ipywidgets.VBox: the whole interface
+        """
+        syn_header = widgets.HBox(
+            [
+                widgets.Label(value=label, layout=widgets.Layout(width=width))
+                for label, width in [
+                    ("Synapses", "300px"),
+                    ("Start [ms]", "80px"),
+                    ("Interval [ms]", "80px"),
+                    ("Number", "80px"),
+                    ("Noise", "80px"),
+                ]
+            ]
+        )
+        syn_rows = [widgets.HBox(row) for row in self.syn_widgets.values()]
+
+        stimuli = widgets.VBox(
+            [self.protocol_widget] + list(self.stim_widgets.values())
+        )
+        synapses = widgets.VBox([syn_header] + syn_rows)
+
+        return widgets.VBox(
+            [
+                widgets.HBox([stimuli, self.morph_image]),
+                synapses,
+                widgets.HBox([self.run_button, self.progress]),
+                self.volt_image,
+            ]
+        )
+
+    def load_step_protocol(self, change):
+        """Fill the stimuli widgets with the stimuli of the chosen StepProtocol.
+
+        Args:
+            change (dict): ipywidgets change, containing the name of the chosen protocol
+        """
+        if change["new"] == CUSTOM_PROTOCOL:
+            return
+        self.simulation.apply_step_protocol(change["new"])
+        for attr, widget in self.stim_widgets.items():
+            widget.value = getattr(self.simulation, attr)
+
+    def load_widget_values(self):
+        """Set the stimuli and synapse parameters of the simulation from the widgets."""
+        for attr, widget in self.stim_widgets.items():
+            setattr(self.simulation, attr, widget.value)
+
+        self.simulation.pre_mtypes = []
+        self.simulation.netstim_params = {}
+        for mtype, (checkbox, *entries) in self.syn_widgets.items():
+            if checkbox.value:
+                self.simulation.pre_mtypes.append(mtype)
+                # {mtypeidx:[start, interval, number, noise]}
+                self.simulation.netstim_params[mtype] = [
+                    entry.value for entry in entries
+                ]
+
+    def reload_simulation(self):
+        """Reload cell, protocol and simulation with the parameters of the widgets."""
+        self.load_widget_values()
+
+        self.simulation.destroy()
+        self.simulation.load_cell_sim()
+        self.simulation.load_protocol()
+        self.simulation.sim.neuron.h.t = 0
+        self.simulation.instantiate()
+
+        self.ax_volt.set_xlim([0, self.simulation.protocol.total_duration])
+        self.update_morph_figure()
+
+    def run(self):
+        """Run the simulation, updating the voltage figure and the progress bar."""
+        self.run_button.disabled = True
+        self.reload_simulation()
+
+        update_dt = self.simulation.sim.neuron.h.tstop / self.n_updates
+        self.simulation.advance(self.update_volt_figure, update_dt)
+
+        self.update_volt_figure()
+        self.run_button.disabled = False
+
+    def update_volt_figure(self):
+        """Update the somatic voltage figure and the progress bar."""
+        h = self.simulation.sim.neuron.h
+        t, v = self.simulation.get_voltage()
+        self.volt_line.set_xdata(t)
+        self.volt_line.set_ydata(v)
+        self.volt_image.value = figure_to_png(self.fig_volt)
+        if h.tstop > 0:
+            self.progress.value = min(100.0, 100.0 * h.t / h.tstop)
+
+    def update_morph_figure(self, size_scatter=6):
+        """Draw the morphology with the selected synapses and the recording sites.
+
+        Args:
+            size_scatter (int): size of synapses for scatter plot
+        """
+        fig = Figure(figsize=(4, 4))
+        ax = fig.add_subplot(111)
+        plot_synapse_morphology(ax, self.simulation, size_scatter)
+        fig.subplots_adjust(right=0.98, top=0.98, bottom=0.15, left=0.20)
+        self.morph_image.value = figure_to_png(fig)
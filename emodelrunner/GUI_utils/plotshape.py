@@ -18,9 +18,12 @@
 
 # pylint: disable=too-many-arguments, too-many-locals, too-many-branches, no-member, import-error
 from __future__ import unicode_literals  # for micrometer display
-from matplotlib import cm
+from matplotlib import cm, rcParams
 from neuron.gui2.utilities import _segment_3d_pts
 
+# synapse properties by which the synapses can be colored on the morphology
+SYNAPSE_COLOR_MODES = ["type", "mtype", "weight", "activation"]
+
 
 def auto_aspect(ax):
     """Sets the x, y, and z range symmetric around the center.
@@ -55,6 +58,48 @@ def get_color_from_cmap(val, val_min, val_max, cmap):
     return cmap((min(max(val, val_min), val_max) - val_min) / (val_range))
 
 
+def get_synapse_colors(simulation, pre_mtype, color_by="type", cmap=cm.viridis):
+    """Return the colors of the displayed synapses of a pre-synaptic mtype.
+
+    Args:
+        simulation (NeuronSimulation): contains simulation (and cell) data,
+            with the synapse display data loaded
+        pre_mtype (int): pre-synaptic mtype id of the synapses
+        color_by (str): synapse property giving the colors. Can be
+            "type" (red if excitatory, orange if inhibitory),
+            "mtype" (one color per pre-synaptic mtype),
+            "weight" (summed weight of the synapses displayed at the same position)
+            or "activation" (red if the synapses receive spikes, grey otherwise)
+        cmap (matplotlib.colors.Colormap): colormap of the weights
+
+    Raises:
+        ValueError: if color_by is not a synapse color mode
+
+    Returns:
+        list: color of each displayed synapse
+    """
+    data = simulation.syn_display_data[pre_mtype]
+    if color_by == "type":
+        return ["red" if syn_data[3] == 1 else "orange" for syn_data in data]
+    if color_by == "mtype":
+        index = list(simulation.available_pre_mtypes).index(pre_mtype)
+        return [cm.tab20(index % 20)] * len(data)
+    if color_by == "weight":
+        # same color scale for all the mtypes
+        weights = sum(simulation.syn_weights.values(), [])
+        return [
+            get_color_from_cmap(weight, min(weights), max(weights), cmap)
+            for weight in simulation.syn_weights[pre_mtype]
+        ]
+    if color_by == "activation":
+        color = "red" if simulation.is_stimulated(pre_mtype) else "grey"
+        return [color] * len(data)
+    raise ValueError(
+        f"Unknown synapse color mode: {color_by}. "
+        f"Should be one of {', '.join(SYNAPSE_COLOR_MODES)}."
+    )
+
+
 def plot_shape(ax, xaxis, yaxis, zaxis, plot_3d, data, linewidth):
     """Plot shape and return line.
 
@@ -77,7 +122,7 @@ def plot_shape(ax, xaxis, yaxis, zaxis, plot_3d, data, linewidth):
             data[zaxis],
             "-",
             linewidth=linewidth,
-            color="black",
+            color=rcParams["text.color"],
         )
     else:
         (line,) = ax.plot(
@@ -85,7 +130,7 @@ def plot_shape(ax, xaxis, yaxis, zaxis, plot_3d, data, linewidth):
             data[yaxis],
             "-",
             linewidth=linewidth,
-            color="black",
+            color=rcParams["text.color"],
         )
 
     return line
@@ -14,6 +14,7 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
+import configparser
 import json
 import os
 import numpy as np
@@ -22,7 +23,15 @@ from bluepyopt import ephys
 
 from emodelrunner.recordings import RecordingCustom
 from emodelrunner.cell import CellModelCustom
+from emodelrunner.configuration import PackageType
+from emodelrunner.factsheets.physiology_features import extract_step_features
 from emodelrunner.synapses.stimuli import NrnNetStimStimulusCustom
+from emodelrunner.locations import (
+    NrnSectionCompLocation,
+    SOMA_LOC,
+    get_section_location_name,
+    parse_section_location,
+)
 from emodelrunner.load import (
     load_config,
     load_syn_mechs,
@@ -30,10 +39,24 @@ from emodelrunner.load import (
     load_mechanisms,
     get_morph_args,
     get_release_params,
+    get_syn_setup_params,
+    get_synplas_morph_args,
 )
 from emodelrunner.morphology import create_morphology
+from emodelrunner.run_synplas import _set_global_params
 from emodelrunner.synapses.create_locations import get_syn_locs
 
+# simulation attributes defining the step and holding stimuli
+STIMULUS_PARAMS = [
+    "step_stim",
+    "step_delay",
+    "step_duration",
+    "hypamp",
+    "hold_step_delay",
+    "hold_step_duration",
+    "total_duration",
+]
+
 
 def section_coordinate_3d(sec, seg_pos):
     """Returns the 3d coordinates of a point in a section.
@@ -104,6 +127,51 @@ def get_pos_and_color(sec, seg_pos, syn_type):
     return pos
 
 
+def frequency_to_interval(frequency):
+    """Convert a stimulation frequency into the interval between two spikes.
+
+    Args:
+        frequency (float): stimulation frequency (Hz)
+
+    Returns:
+        float: interval between two spikes (ms). 0 if the frequency is 0.
+    """
+    if frequency <= 0:
+        return 0
+    return 1000.0 / frequency
+
+
+def interval_to_frequency(interval):
+    """Convert the interval between two spikes into a stimulation frequency.
+
+    Args:
+        interval (float): interval between two spikes (ms)
+
+    Returns:
+        int: stimulation frequency (Hz), rounded to be displayed in the GUI entries.
+        0 if the interval is 0.
+    """
+    if interval <= 0:
+        return 0
+    return int(round(1000.0 / interval))
+
+
+def get_conductance_name(param_name):
+    """Return the name of the channel conductance set by an optimised parameter.
+
+    Args:
+        param_name (str): name of the parameter, e.g. 'gNaTgbar_NaTg.axonal'
+
+    Returns:
+        str: name of the conductance without the location, e.g. 'gNaTgbar_NaTg',
+        or None if the parameter is not a channel conductance
+    """
+    name = param_name.split(".")[0]
+    if name.startswith("g") and "bar_" in name:
+        return name
+    return None
+
+
 def get_step_data(steps, step, default_step):
     """Extract step data from StepProtocol json dict and add amplitude to a step list.
 
@@ -178,6 +246,10 @@ def get_holding_data(holdings, stimulus_data, total_duration, default_holding):
 class NeuronSimulation:
     """Class containing BPO cell, simulation & protocol.
 
+    Can be used with sscx, thalamus and synplas packages.
+    For synplas packages, the post-synaptic cell is loaded with its GluSynapses,
+    that can be stimulated like any other synapses.
+
     Attributes:
         config (dict): dictionary containing configuration data
         cell_path (str): path to cell repo. should be "."
@@ -190,6 +262,8 @@ class NeuronSimulation:
         step_duration (float): duration of step stimulus (ms)
         hold_step_delay (float): delay of holding stimulus (ms)
         hold_step_duration (float): duration of holding stimulus (ms)
+        step_protocols (dict): definitions of the StepProtocols of the protocols file
+            (empty if the package has no protocols file)
         available_pre_mtypes (dict): all synapses pre_mtypes
             {mtypeidx: mtype_name, ...}
         pre_mtypes (list of int): selected pre_mtypes to run
@@ -206,9 +280,26 @@ class NeuronSimulation:
             the cell's free parameters
         sim (ephys.simulators.NrnSimulator): BluePyOpt simulator
             can access neuron data from it
+        syn_setup_params (dict): extra parameters to setup the GluSynapses
+            of synplas packages. None for other packages.
         syn_display_data (dict): synapse data (position and type) for display
             syn_display_data[pre_mtype] = [x,y,z,type],
             type=0 if inhib, type=1 if excit
+        syn_weights (dict): weight of the synapses displayed at each position of
+            syn_display_data, summed over the synapses at the same position
+        rec_display_data (list): position [x,y,z] of each recording site for display
+        stim_display_data (list): position [x,y,z] of the stimuli for display,
+            or None if it has no 3d position
+        recording_locations (list of str): recorded locations besides the soma,
+            e.g. 'dend[3](0.5)'
+        stimulus_location (str): location of the step and holding stimuli,
+            e.g. 'dend[3](0.5)'. The soma is used if empty.
+        conductance_scales (dict): factor by which each channel conductance is scaled
+            {conductance_name: scale}. Conductances not in the dict are not scaled.
+        record_morphology (bool): set to True to record the voltage of all the segments
+        morph_record_dt (float): time step (ms) of the recordings of all the segments
+        morph_recordings (dict): neuron Vectors recording the 'time' and the 'voltage'
+            of each segment, in the order of the morphology plot. None if not recorded.
     """
 
     def __init__(self, config_path="config/config_allsteps.ini"):
@@ -218,6 +309,7 @@ class NeuronSimulation:
             config_path (str):path to the config file
         """
         # load config file
+        self.config_path = config_path
         self.config = load_config(config_path=config_path)
         self.cell_path = self.config.get("Paths", "memodel_dir")
 
@@ -230,7 +322,22 @@ class NeuronSimulation:
         self.cell = None
         self.release_params = None
         self.sim = None
+        self.syn_setup_params = None
         self.syn_display_data = None
+        self.syn_weights = None
+        self.rec_display_data = []
+        self.stim_display_data = None
+        self.conductance_scales = {}
+        # synplas packages have no recordings nor stimulus location in their config
+        self.recording_locations = json.loads(
+            self.config.get("Recordings", "locations", fallback="[]")
+        )
+        self.stimulus_location = self.config.get(
+            "Protocol", "gui_stimulus_location", fallback=""
+        )
+        self.record_morphology = False
+        self.morph_record_dt = 0.5
+        self.morph_recordings = None
 
     def load_protocol_params(
         self,
@@ -258,19 +365,26 @@ class NeuronSimulation:
             default_step (float): default value for custom step amplitude (nA)
             default_holding (float): default value for custom holding amplitude (nA)
         """
-        prot_path = self.config.get("Paths", "prot_path")
-        with open(prot_path, "r", encoding="utf-8") as protocol_file:
-            protocol_data = json.load(protocol_file)
+        # synplas packages have no protocols file
+        prot_path = self.config.get("Paths", "prot_path", fallback=None)
+        if prot_path is not None:
+            with open(prot_path, "r", encoding="utf-8") as protocol_file:
+                protocol_data = json.load(protocol_file)
+        else:
+            protocol_data = {}
         if "__comment" in protocol_data:
             del protocol_data["__comment"]
 
         # list of all steps and hold amps found in all stepprotocols in prot file
         steps = []
         holdings = []
+        # step protocols that can be loaded in the protocol editor
+        self.step_protocols = {}
 
-        for prot_data in protocol_data.values():
+        for prot_name, prot_data in protocol_data.items():
             # update default delays / durations and update steps and holdings lists
             if prot_data["type"] == "StepProtocol":
+                self.step_protocols[prot_name] = prot_data
                 total_duration, step_delay, step_duration = get_step_data(
                     steps=steps,
                     step=prot_data["stimuli"]["step"],
@@ -296,6 +410,127 @@ class NeuronSimulation:
         self.hold_step_delay = hold_step_delay
         self.hold_step_duration = hold_step_duration
 
+    def apply_step_protocol(self, protocol_name):
+        """Set the step and holding stimuli to the ones of a StepProtocol of the protocols file.
+
+        Args:
+            protocol_name (str): name of the StepProtocol
+        """
+        stimuli = self.step_protocols[protocol_name]["stimuli"]
+
+        step = stimuli["step"]
+        # only one step can be displayed: use the last one, as in get_step_data
+        if isinstance(step, list):
+            step = step[-1]
+        self.step_stim = step["amp"] if step["amp"] is not None else 0
+        self.step_delay = step["delay"]
+        self.step_duration = step["duration"]
+        self.total_duration = step["totduration"]
+
+        if "holding" in stimuli and stimuli["holding"]["amp"] is not None:
+            self.hypamp = stimuli["holding"]["amp"]
+        else:
+            self.hypamp = 0
+        self.hold_step_delay, self.hold_step_duration = get_holding_data(
+            [], stimuli, self.total_duration, 0
+        )
+
+    def get_step_protocol_definition(self):
+        """Return the current step and holding stimuli as a StepProtocol definition.
+
+        Returns:
+            dict: StepProtocol definition, as found in the protocols file
+        """
+        return {
+            "type": "StepProtocol",
+            "stimuli": {
+                "step": {
+                    "delay": self.step_delay,
+                    "amp": self.step_stim,
+                    "duration": self.step_duration,
+                    "totduration": self.total_duration,
+                },
+                "holding": {
+                    "delay": self.hold_step_delay,
+                    "amp": self.hypamp,
+                    "duration": self.hold_step_duration,
+                    "totduration": self.total_duration,
+                },
+            },
+        }
+
+    def save_step_protocol(self, protocol_name, prot_path=None):
+        """Save the current step and holding stimuli as a StepProtocol in the protocols file.
+
+        Args:
+            protocol_name (str): name of the StepProtocol.
+                Replaces the protocol with the same name if any.
+            prot_path (str): path to the protocols file.
+                The protocols file of the config is used if None.
+
+        Raises:
+            ValueError: if prot_path is None and the config has no protocols file
+        """
+        if prot_path is None:
+            prot_path = self.config.get("Paths", "prot_path", fallback=None)
+        if prot_path is None:
+            raise ValueError("No protocols file to save the protocol into.")
+        with open(prot_path, "r", encoding="utf-8") as protocol_file:
+            protocol_data = json.load(protocol_file)
+
+        protocol_definition = self.get_step_protocol_definition()
+        protocol_data[protocol_name] = protocol_definition
+        self.step_protocols[protocol_name] = protocol_definition
+
+        with open(prot_path, "w", encoding="utf-8") as protocol_file:
+            json.dump(protocol_data, protocol_file, indent=4)
+
+    def get_state(self):
+        """Return the stimuli and synapse settings, e.g. to save a GUI session.
+
+        Returns:
+            dict containing the 'stimuli' settings, and the 'synapses' settings
+            giving the netstim params [start, interval, number, noise] of each enabled mtype,
+            the 'conductance_scales', the 'recording_locations' and the 'stimulus_location'
+        """
+        return {
+            "stimuli": {param: getattr(self, param) for param in STIMULUS_PARAMS},
+            # json keys have to be str
+            "synapses": {
+                str(mtype): self.netstim_params[mtype] for mtype in self.pre_mtypes
+            },
+            "conductance_scales": dict(self.conductance_scales),
+            "recording_locations": list(self.recording_locations),
+            "stimulus_location": self.stimulus_location,
+        }
+
+    def set_state(self, state):
+        """Set the stimuli and synapse settings, e.g. to restore a GUI session.
+
+        Args:
+            state (dict): stimuli and synapse settings, as returned by get_state
+
+        Raises:
+            ValueError: if a synapse mtype is not connected to the cell
+        """
+        for mtype in state["synapses"]:
+            if int(mtype) not in self.available_pre_mtypes:
+                raise ValueError(f"No synapse with pre-synaptic mtype {mtype}.")
+
+        for param in STIMULUS_PARAMS:
+            setattr(self, param, state["stimuli"][param])
+        self.pre_mtypes = [int(mtype) for mtype in state["synapses"]]
+        self.netstim_params = {
+            int(mtype): list(params) for mtype, params in state["synapses"].items()
+        }
+        # states saved before conductances could be scaled have no conductance_scales
+        self.conductance_scales = dict(state.get("conductance_scales", {}))
+        # nor recording and stimulus locations before the sites could be picked
+        self.recording_locations = list(
+            state.get("recording_locations", self.recording_locations)
+        )
+        self.stimulus_location = state.get("stimulus_location", self.stimulus_location)
+
     def load_synapse_params(
         self, syn_start=0, syn_interval=0, syn_nmb_of_spikes=0, syn_noise=0
     ):
@@ -324,12 +559,15 @@ class NeuronSimulation:
         """Load the list of pre mtype cells to which are connected the synapses.
 
         Returns:
-            dict: mtypes of cells connected to the synapses
+            dict: mtypes of cells connected to the synapses.
+                Empty if the package has no synapses (e.g. some thalamus packages).
         """
         mtype_path = os.path.join(
             self.config.get("Paths", "syn_dir"),
-            self.config.get("Paths", "syn_mtype_map"),
+            self.config.get("Paths", "syn_mtype_map", fallback="mtype_map.tsv"),
         )
+        if not os.path.isfile(mtype_path):
+            return {}
         with open(mtype_path, "r", encoding="utf-8") as mtype_file:
             raw_mtypes = mtype_file.readlines()
 
@@ -342,6 +580,88 @@ class NeuronSimulation:
 
         return mtypes
 
+    def get_pre_mtype_groups(self):
+        """Split the pre-synaptic mtypes connected to the cell into excitatory and inhibitory.
+
+        The mtypes having no synapse on the cell are left out.
+
+        Returns:
+            dict: list of mtypes (int) for the 'excitatory' and the 'inhibitory' groups
+        """
+        syn_types = {}
+        for mech in self.cell.mechanisms:
+            if hasattr(mech, "pprocesses"):
+                for syn in mech.synapses_data:
+                    syn_types.setdefault(syn["pre_mtype"], syn["synapse_type"])
+
+        groups = {"excitatory": [], "inhibitory": []}
+        for mtype in self.available_pre_mtypes:
+            if mtype in syn_types:
+                # excitatory if synapse type >= 100, as in get_pos_and_color
+                if syn_types[mtype] >= 100:
+                    groups["excitatory"].append(mtype)
+                else:
+                    groups["inhibitory"].append(mtype)
+        return groups
+
+    def is_stimulated(self, pre_mtype):
+        """Returns True if the synapses of a pre-synaptic mtype receive spikes.
+
+        Args:
+            pre_mtype (int): pre-synaptic mtype id
+
+        Returns:
+            bool: True if the mtype is selected with a strictly positive number of spikes
+        """
+        if pre_mtype not in self.netstim_params:
+            return False
+        # netstim params are [start, interval, number, noise]
+        return self.netstim_params[pre_mtype][2] > 0
+
+    def get_conductance_names(self):
+        """Return the channel conductances of the optimised parameters.
+
+        Returns:
+            list of str: sorted names of the conductances, without location
+        """
+        names = {
+            get_conductance_name(param_name) for param_name in self.release_params
+        }
+        names.discard(None)
+        return sorted(names)
+
+    def set_conductance_scale(self, conductance_name, scale):
+        """Set the factor by which a channel conductance is scaled.
+
+        The new value is used at the next instantiation.
+
+        Args:
+            conductance_name (str): name of the conductance, e.g. 'gNaTgbar_NaTg'
+            scale (float): scaling factor. 1 to use the optimised conductance.
+
+        Raises:
+            ValueError: if the conductance is not an optimised parameter of the cell,
+                or if scale is negative
+        """
+        if conductance_name not in self.get_conductance_names():
+            raise ValueError(f"No conductance named {conductance_name}.")
+        if scale < 0:
+            raise ValueError(f"Conductance scale should be positive, got {scale}.")
+        self.conductance_scales[conductance_name] = scale
+
+    def get_scaled_release_params(self):
+        """Return the optimised parameters with the channel conductances scaled.
+
+        Returns:
+            dict: optimised parameters, with conductances multiplied by their scale
+        """
+        scaled_params = {}
+        for param_name, value in self.release_params.items():
+            conductance_name = get_conductance_name(param_name)
+            scale = self.conductance_scales.get(conductance_name, 1.0)
+            scaled_params[param_name] = value * scale
+        return scaled_params
+
     def get_syn_stim(self):
         """Create synapse stimuli.
 
@@ -367,14 +687,26 @@ class NeuronSimulation:
             name="soma", seclist_name="somatic", sec_index=0, comp_x=0.5
         )
 
-        rec = RecordingCustom(name=protocol_name, location=soma_loc, variable="v")
+        # the somatic recording stays the first one, used for the voltage figure
+        recs = [RecordingCustom(name=protocol_name, location=soma_loc, variable="v")]
+        for location in self.recording_locations:
+            section_location = self.get_section_location(location)
+            recs.append(
+                RecordingCustom(
+                    name=f"{protocol_name}.{section_location.name}",
+                    location=section_location,
+                    variable="v",
+                )
+            )
+
+        stim_loc = self.get_section_location(self.stimulus_location)
 
         # create step stimulus
         stim = ephys.stimuli.NrnSquarePulse(
             step_amplitude=self.step_stim,
             step_delay=self.step_delay,
             step_duration=self.step_duration,
-            location=soma_loc,
+            location=stim_loc,
             total_duration=self.total_duration,
         )
 
@@ -383,7 +715,7 @@ class NeuronSimulation:
             step_amplitude=self.hypamp,
             step_delay=self.hold_step_delay,
             step_duration=self.hold_step_duration,
-            location=soma_loc,
+            location=stim_loc,
             total_duration=self.total_duration,
         )
 
@@ -392,8 +724,24 @@ class NeuronSimulation:
         if syn_stim is not None:
             stims.append(syn_stim)
 
-        self.protocol = ephys.protocols.SweepProtocol(
-            protocol_name, stims, [rec], False
+        self.protocol = ephys.protocols.SweepProtocol(protocol_name, stims, recs, False)
+
+    def get_syn_setup_params(self):
+        """Load the parameters used to setup the GluSynapses of synplas packages.
+
+        Returns:
+            dict: glusynapse setup related parameters, or None if not a synplas package
+        """
+        if self.config.package_type != PackageType.synplas:
+            return None
+
+        syn_dir = self.config.get("Paths", "syn_dir")
+        return get_syn_setup_params(
+            os.path.join(syn_dir, "syn_extra_params.json"),
+            os.path.join(syn_dir, "cpre_cpost.json"),
+            self.config.get("Paths", "synplas_fit_params_path"),
+            self.config.getint("Cell", "gid"),
+            self.config.getboolean("SynapsePlasticity", "invivo"),
         )
 
     def create_cell_custom(self):
@@ -405,14 +753,20 @@ class NeuronSimulation:
         # pylint: disable=too-many-locals
         emodel = self.config.get("Cell", "emodel")
         gid = self.config.getint("Cell", "gid")
+        is_synplas = self.config.package_type == PackageType.synplas
 
         # load mechanisms
         unopt_params_path = self.config.get("Paths", "unoptimized_params_path")
         mechs = load_mechanisms(unopt_params_path)
 
         # add synapses mechs
-        seed = self.config.getint("Synapses", "seed")
-        rng_settings_mode = self.config.get("Synapses", "rng_settings_mode")
+        if is_synplas:
+            # use the same synapse seeds as the synapse plasticity runs
+            seed = self.config.getint("SynapsePlasticity", "base_seed")
+            rng_settings_mode = "Compatibility"
+        else:
+            seed = self.config.getint("Synapses", "seed")
+            rng_settings_mode = self.config.get("Synapses", "rng_settings_mode")
         syn_data_path = os.path.join(
             self.config.get("Paths", "syn_dir"),
             self.config.get("Paths", "syn_data_file"),
@@ -423,16 +777,19 @@ class NeuronSimulation:
         )
         # always load synapse data for synapse display.
         # -> do not need to reload syn data each time user toggles synapse checkbox
-        mechs += [
-            load_syn_mechs(
-                seed,
-                rng_settings_mode,
-                syn_data_path,
-                syn_conf_path,
-                self.pre_mtypes,
-                self.netstim_params,
-            )
-        ]
+        if os.path.isfile(syn_data_path):
+            mechs += [
+                load_syn_mechs(
+                    seed,
+                    rng_settings_mode,
+                    syn_data_path,
+                    syn_conf_path,
+                    self.pre_mtypes,
+                    self.netstim_params,
+                    use_glu_synapse=is_synplas,
+                    syn_setup_params=self.syn_setup_params,
+                )
+            ]
 
         # load parameters
         params = load_unoptimized_parameters(
@@ -442,7 +799,10 @@ class NeuronSimulation:
         )
 
         # load morphology
-        morph_config = get_morph_args(self.config)
+        if is_synplas:
+            morph_config = get_synplas_morph_args(self.config)
+        else:
+            morph_config = get_morph_args(self.config)
         morph = create_morphology(morph_config, self.config.package_type)
 
         # create cell
@@ -452,24 +812,37 @@ class NeuronSimulation:
             mechs=mechs,
             params=params,
             gid=gid,
+            fixhp=is_synplas,
         )
 
         return cell
 
     def load_cell_sim(self):
         """Load BPO cell & simulation."""
+        self.syn_setup_params = self.get_syn_setup_params()
         self.cell = self.create_cell_custom()
         self.release_params = get_release_params(self.config)
+        # synplas packages have no Sim section
         self.sim = ephys.simulators.NrnSimulator(
-            dt=self.config.getfloat("Sim", "dt"), cvode_active=False
+            dt=self.config.getfloat("Sim", "dt", fallback=0.025), cvode_active=False
         )
 
+        if self.syn_setup_params is not None:
+            # set fitted glusynapse parameters
+            if self.syn_setup_params["fit_params"]:
+                _set_global_params(self.syn_setup_params["fit_params"], self.sim)
+            # enable in vivo mode (global)
+            if self.syn_setup_params["invivo"]:
+                self.sim.neuron.h.cao_CR_GluSynapse = 1.2  # mM
+
     def load_synapse_display_data(self):
-        """Load dict containing x,y,z of each synapse & inhib/excit."""
+        """Load dict containing x,y,z of each synapse & inhib/excit, and their weights."""
         # self.syn_display_data[pre_mtype] = [x,y,z,type], type=0 if inhib, type=1 if excit
         self.syn_display_data = {}
+        self.syn_weights = {}
         for key in self.available_pre_mtypes:
             self.syn_display_data[key] = []
+            self.syn_weights[key] = []
 
         for mech in self.cell.mechanisms:
             if hasattr(mech, "pprocesses"):
@@ -484,22 +857,190 @@ class NeuronSimulation:
                     syn_display_data = get_pos_and_color(
                         syn_section, seg_pos, syn["synapse_type"]
                     )
-                    if (
-                        syn_display_data is not None
-                        and syn_display_data not in self.syn_display_data[pre_mtype]
-                    ):
-                        self.syn_display_data[pre_mtype].append(syn_display_data)
+                    if syn_display_data is None:
+                        continue
+                    displayed = self.syn_display_data[pre_mtype]
+                    if syn_display_data not in displayed:
+                        displayed.append(syn_display_data)
+                        self.syn_weights[pre_mtype].append(syn["weight"])
+                    else:
+                        index = displayed.index(syn_display_data)
+                        self.syn_weights[pre_mtype][index] += syn["weight"]
+
+    def load_recording_display_data(self):
+        """Load list containing x,y,z of each recording site, and of the stimuli."""
+        self.rec_display_data = []
+        for recording in self.protocol.recordings:
+            seg = recording.location.instantiate(sim=self.sim, icell=self.cell.icell)
+            pos = section_coordinate_3d(seg.sec, seg.x)
+            if pos is not None:
+                self.rec_display_data.append(pos)
+
+        seg = self.get_section_location(self.stimulus_location).instantiate(
+            sim=self.sim, icell=self.cell.icell
+        )
+        self.stim_display_data = section_coordinate_3d(seg.sec, seg.x)
+
+    @staticmethod
+    def get_section_location(location):
+        """Return the BluePyOpt location of a section location.
+
+        Args:
+            location (str): location, e.g. 'dend[3](0.5)'. The soma if empty.
+
+        Returns:
+            bluepyopt.ephys.locations.Location: the location
+        """
+        if not location:
+            return SOMA_LOC
+        section_location = parse_section_location(location)
+        return NrnSectionCompLocation(
+            name=get_section_location_name(**section_location), **section_location
+        )
+
+    def get_segment_location(self, segment_index):
+        """Return the location of a segment, e.g. picked on the morphology plot.
+
+        Args:
+            segment_index (int): index of the segment, in the order of the morphology plot
+
+        Raises:
+            ValueError: if the segment is not in a section array of the cell
+
+        Returns:
+            str: location of the segment, e.g. 'dend[3](0.5)'
+        """
+        segments = [seg for sec in self.sim.neuron.h.allsec() for seg in sec]
+        seg = segments[segment_index]
+        # e.g. 'cADpyr_L5TPC[0].dend[3]'
+        location = f"{seg.sec.name().split('.')[-1]}({seg.x:g})"
+        # check that the section can be found from the cell
+        parse_section_location(location)
+        return location
+
+    def toggle_recording_location(self, location):
+        """Add a recording site, or remove it if it is already recorded.
+
+        Args:
+            location (str): location, e.g. 'dend[3](0.5)'
+
+        Returns:
+            bool: True if the recording site has been added, False if removed
+        """
+        if location in self.recording_locations:
+            self.recording_locations.remove(location)
+            return False
+        self.recording_locations.append(location)
+        return True
+
+    def save_sites_to_config(self, output_path):
+        """Write the config file with the recording sites and the stimulus location.
+
+        The recording sites are written in the Recordings section,
+        and the stimulus location in the Protocol section.
+
+        Args:
+            output_path (str): path to the written config file.
+                Can be the path of the loaded config file.
+        """
+        config = configparser.ConfigParser(interpolation=None)
+        config.optionxform = str
+        config.read(self.config_path)
+        for section in ["Protocol", "Recordings"]:
+            if not config.has_section(section):
+                config.add_section(section)
+        config.set("Recordings", "locations", json.dumps(self.recording_locations))
+        config.set("Protocol", "gui_stimulus_location", self.stimulus_location)
+        with open(output_path, "w", encoding="utf-8") as config_file:
+            config.write(config_file)
 
     def instantiate(self):
         """Instantiate cell, simulation & protocol."""
-        self.cell.freeze(self.release_params)
+        self.cell.freeze(self.get_scaled_release_params())
         self.cell.instantiate(sim=self.sim)
         self.protocol.instantiate(sim=self.sim, icell=self.cell.icell)
         self.sim.neuron.h.tstop = self.protocol.total_duration
+        if self.record_morphology:
+            self.setup_morphology_recordings()
         self.sim.neuron.h.stdinit()
 
+    def is_finished(self):
+        """Returns True if the simulation has reached its end."""
+        h = self.sim.neuron.h
+        return h.t >= h.tstop - h.dt / 2
+
+    def advance(self, callback=None, callback_dt=None):
+        """Run the instantiated simulation, calling callback at intermediate times.
+
+        The run is driven by NEURON with continuerun. The callback is called
+        by events of the solver, rescheduled every callback_dt, and the traces
+        are recorded while running, so that the callback can stream them,
+        e.g. to update figures or a progress bar.
+
+        Args:
+            callback (callable): function without argument called during the run.
+                The run stops at the end of the current time step if it returns False.
+            callback_dt (float): simulation time (ms) between two calls of callback.
+                If None, callback is called after each time step.
+
+        Returns:
+            bool: True if the simulation has reached its end
+        """
+        h = self.sim.neuron.h
+        if callback is not None:
+            cvode = h.CVode()
+            interval = h.dt if callback_dt is None else callback_dt
+
+            def on_event():
+                """Call the callback, then schedule its next call or stop the run."""
+                if callback() is False:
+                    h.stoprun = 1
+                elif h.t + interval < h.tstop:
+                    cvode.event(h.t + interval, on_event)
+
+            cvode.event(h.t + interval, on_event)
+
+        h.continuerun(h.tstop)
+        return self.is_finished()
+
+    def setup_morphology_recordings(self):
+        """Record the voltage of all the segments, to animate it on the morphology.
+
+        The segments are recorded in the same order as the lines of the morphology plot.
+        """
+        h = self.sim.neuron.h
+        time_vector = h.Vector()
+        time_vector.record(h._ref_t, self.morph_record_dt)  # pylint: disable=W0212
+        voltage_vectors = []
+        for sec in h.allsec():
+            for seg in sec:
+                voltage_vector = h.Vector()
+                # pylint: disable=W0212
+                voltage_vector.record(seg._ref_v, self.morph_record_dt)
+                voltage_vectors.append(voltage_vector)
+        self.morph_recordings = {"time": time_vector, "voltage": voltage_vectors}
+
+    def get_morphology_voltage(self):
+        """Returns the voltage of all the segments recorded during the last run.
+
+        Returns:
+            a tuple containing
+
+            - ndarray: the time (ms)
+            - ndarray: the voltage (mV) of each segment, with shape (n_times, n_segments)
+
+        Raises:
+            ValueError: if the voltage of the segments has not been recorded
+        """
+        if self.morph_recordings is None:
+            raise ValueError("The voltage of the segments has not been recorded.")
+        time = np.array(self.morph_recordings["time"])
+        voltage = np.array([np.array(vec) for vec in self.morph_recordings["voltage"]])
+        return time, voltage.T
+
     def destroy(self):
         """Destroy cell & protocol."""
+        self.morph_recordings = None
         self.protocol.destroy(sim=self.sim)
         self.cell.destroy(sim=self.sim)
         self.cell.unfreeze(self.release_params.keys())
@@ -519,3 +1060,55 @@ class NeuronSimulation:
         key = list(responses.keys())[0]
         resp = responses[key]
         return np.array(resp["time"]), np.array(resp["voltage"])
+
+    def get_step_features(self):
+        """Extract the factsheet features of the somatic voltage response to the step.
+
+        Returns:
+            dict containing the 'spike_count', the 'input_resistance' (MOhm)
+            and the 'AP_half_width' (ms) of the last run
+        """
+        t, v = self.get_voltage()
+        return extract_step_features(
+            t, v, self.step_stim, self.step_delay, self.step_duration
+        )
+
+    def get_rheobase_condition(self):
+        """Return a key identifying the conditions, other than the step amplitude, of a run.
+
+        Runs with the same key can be compared to estimate the rheobase.
+
+        Returns:
+            str: the holding stimulus, the step timing, the conductance scales
+            and the enabled synapses of the simulation
+        """
+        return json.dumps(
+            {
+                "hypamp": self.hypamp,
+                "hold_step_delay": self.hold_step_delay,
+                "hold_step_duration": self.hold_step_duration,
+                "step_delay": self.step_delay,
+                "step_duration": self.step_duration,
+                "conductance_scales": self.conductance_scales,
+                "synapses": {
+                    str(mtype): self.netstim_params.get(mtype)
+                    for mtype in self.pre_mtypes
+                },
+            },
+            sort_keys=True,
+        )
+
+    def save_voltage_csv(self, path):
+        """Save the voltage response as csv.
+
+        Args:
+            path (str or Path): path to the csv file
+        """
+        t, v = self.get_voltage()
+        np.savetxt(
+            path,
+            np.transpose(np.vstack((t, v))),
+            delimiter=",",
+            header="t [ms],v [mV]",
+            comments="",
+        )
@@ -16,18 +16,80 @@
 
 import matplotlib as mpl
 
+# reference resolution at which the GUI has been designed (pixels per inch)
+BASE_DPI = 96.0
+
+# colors used for the background, the text, the highlighted items
+# and the disabled items of each theme
+THEMES = {
+    "light": {
+        "background": "#FFFFFF",
+        "foreground": "#050A58",
+        "accent": "#0B83CD",
+        "disabled": "#888888",
+        "entry_foreground": "black",
+    },
+    "dark": {
+        "background": "#333333",
+        "foreground": "#F2F2F2",
+        "accent": "#15D3FF",
+        "disabled": "#888888",
+        "entry_foreground": "#F2F2F2",
+    },
+}
+
+
+def get_scaling_factor(root, scaling=None):
+    """Get the factor by which the widgets and figures should be scaled.
 
-def get_style_cst():
+    Args:
+        root (tk.Tk): root of the GUI
+        scaling (float): scaling factor forced by the user.
+            If None, it is computed from the resolution of the screen.
+
+    Returns:
+        float: scaling factor
+    """
+    if scaling is not None:
+        if scaling <= 0:
+            raise ValueError(f"Scaling factor should be positive. Got {scaling}")
+        return scaling
+    return max(1.0, root.winfo_fpixels("1i") / BASE_DPI)
+
+
+def set_tk_scaling(root, scaling):
+    """Scale the tkinter fonts and widgets.
+
+    Args:
+        root (tk.Tk): root of the GUI
+        scaling (float): scaling factor
+    """
+    # tk scaling is the number of pixels per point (1/72 inch)
+    root.tk.call("tk", "scaling", scaling * BASE_DPI / 72.0)
+
+
+def get_style_cst(theme="light", scaling=1.0):
     """Returns dict containing style vars such as colors.
 
+    Args:
+        theme (str): colors theme. can be "light" or "dark".
+        scaling (float): factor by which the fonts and widths are scaled
+
     Returns:
         dict: style colors, font and width
     """
+    if theme not in THEMES:
+        raise ValueError(f"Unknown theme {theme}. Should be one of {list(THEMES)}")
+
     style_dict = {}
     # font & width. has to be an attribute to be accessible.
     # somehow, entry font & width cannot be configurated with style.
+    # fonts are given in points, and are thus scaled by tk scaling.
     style_dict["base_font"] = "Helvetica 10"
+    style_dict["title_font"] = "Helvetica 16 bold"
     style_dict["entry_width"] = 8
+    style_dict["padding"] = int(round(6 * scaling))
+    style_dict["borderwidth"] = int(round(2 * scaling))
 
     # BBP colors
     style_dict["light_blue"] = "#15D3FF"
@@ -38,105 +100,136 @@ def get_style_cst():
     style_dict["deep_grey"] = "#333333"
     style_dict["white"] = "#FFFFFF"
 
+    style_dict.update(THEMES[theme])
+
     return style_dict
 
 
-def set_matplotlib_style():
-    """Configure ticks & labels size."""
-    mpl.rcParams["lines.color"] = get_style_cst()["blue"]
+def set_matplotlib_style(theme="light", scaling=1.0):
+    """Configure ticks & labels size, colors and figure resolution.
+
+    Args:
+        theme (str): colors theme. can be "light" or "dark".
+        scaling (float): factor by which the figures are scaled
+    """
+    style_dict = get_style_cst(theme, scaling)
+    mpl.rcParams["lines.color"] = style_dict["accent"]
     mpl.rcParams["axes.labelsize"] = 8
     mpl.rcParams["xtick.labelsize"] = 8
     mpl.rcParams["ytick.labelsize"] = 8
+    mpl.rcParams["figure.dpi"] = 100 * scaling
+
+    for param in ["figure.facecolor", "axes.facecolor", "savefig.facecolor"]:
+        mpl.rcParams[param] = style_dict["background"]
+    for param in [
+        "axes.edgecolor",
+        "axes.labelcolor",
+        "text.color",
+        "xtick.color",
+        "ytick.color",
+    ]:
+        mpl.rcParams[param] = style_dict["foreground"]
 
 
-def define_style(style):
+def define_style(style, theme="light", scaling=1.0):
     """Define the style for ttk objects.
 
     Args:
         style (ttk.Style): style
+        theme (str): colors theme. can be "light" or "dark".
+        scaling (float): factor by which the paddings and borders are scaled
     """
-    style_dict = get_style_cst()
+    style_dict = get_style_cst(theme, scaling)
 
     style.configure(
         "TButton",
-        background=style_dict["white"],
+        background=style_dict["background"],
+        foreground=style_dict["foreground"],
         font=style_dict["base_font"],
     )
 
     style.configure(
         "ControlSimul.TButton",
-        padding=6,
+        padding=style_dict["padding"],
         relief="solid",
-        background=style_dict["white"],
-        foreground=style_dict["deep_blue"],
-        font="Helvetica 16 bold",
-        borderwidth=2,
-        highlightbackground=style_dict["deep_blue"],  # border color?
+        background=style_dict["background"],
+        foreground=style_dict["foreground"],
+        font=style_dict["title_font"],
+        borderwidth=style_dict["borderwidth"],
+        highlightbackground=style_dict["foreground"],  # border color?
     )
 
     style.map(
         "ControlSimul.TButton",
         foreground=[
-            ("pressed", "!disabled", style_dict["blue"]),
-            ("disabled", style_dict["grey"]),
+            ("pressed", "!disabled", style_dict["accent"]),
+            ("disabled", style_dict["disabled"]),
         ],
     )
 
-    style.configure("TFrame", background=style_dict["white"])
+    style.configure("TFrame", background=style_dict["background"])
     style.configure(
         "Boxed.TFrame",
-        background=style_dict["white"],
+        background=style_dict["background"],
         relief="solid",
-        bordercolor=style_dict["deep_blue"],
+        bordercolor=style_dict["foreground"],
         borderwidth=4,
     )
 
     style.configure(
         "TRadiobutton",
-        background=style_dict["white"],
+        background=style_dict["background"],
         relief="flat",
         cursor="dot",
         borderwidth=0,
-        selectcolor=style_dict["blue"],
+        selectcolor=style_dict["accent"],
         font=style_dict["base_font"],
     )
 
     style.map(
         "TRadiobutton",
         foreground=[
-            ("selected", style_dict["blue"]),
-            ("!selected", style_dict["deep_blue"]),
+            ("selected", style_dict["accent"]),
+            ("!selected", style_dict["foreground"]),
         ],
     )
 
     style.configure(
         "TLabel",
-        foreground=style_dict["deep_blue"],
-        background=style_dict["white"],
+        foreground=style_dict["foreground"],
+        background=style_dict["background"],
         font=style_dict["base_font"],
     )
 
     style.configure(
         "TEntry",
-        foreground="black",
-        background=style_dict["white"],
+        foreground=style_dict["entry_foreground"],
+        background=style_dict["background"],
+        fieldbackground=style_dict["background"],
     )
 
     style.map(
         "TEntry",
-        highlightcolor=[("focus", style_dict["blue"])],
-        bordercolor=[("focus", style_dict["blue"])],
+        highlightcolor=[("focus", style_dict["accent"])],
+        bordercolor=[("focus", style_dict["accent"])],
     )
 
     style.configure(
         "TCheckbutton",
-        foreground=style_dict["deep_blue"],
-        background=style_dict["white"],
+        foreground=style_dict["foreground"],
+        background=style_dict["background"],
         font=style_dict["base_font"],
     )
 
     style.map(
         "TCombobox",
-        fieldbackground=[("!disabled", style_dict["white"])],
-        background=[("!disabled", style_dict["white"])],
+        foreground=[("!disabled", style_dict["entry_foreground"])],
+        fieldbackground=[("!disabled", style_dict["background"])],
+        background=[("!disabled", style_dict["background"])],
+    )
+
+    style.configure(
+        "Horizontal.TScale",
+        background=style_dict["background"],
+        troughcolor=style_dict["background"],
     )
@@ -0,0 +1,409 @@
+"""Experimental Arbor backend running the current injection protocols of a cell."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import json
+import logging
+import time
+from pathlib import Path
+
+import numpy as np
+from bluepyopt import ephys
+
+from emodelrunner.configuration import PackageType
+from emodelrunner.create_cells import create_cell_using_config
+from emodelrunner.load import get_release_params
+from emodelrunner.netpyne_export import (
+    compress_values,
+    get_section_mechanisms,
+    get_section_name,
+)
+from emodelrunner.neuroml_export import get_mean_value, get_sections
+from emodelrunner.output import write_responses
+from emodelrunner.regression import compare_responses, load_reference
+
+logger = logging.getLogger(__name__)
+
+# initial calcium concentrations of NEURON (mM), for the Nernst reversal potential
+CA_INT_CON = 5e-5
+CA_EXT_CON = 2.0
+
+# NEURON specific capacitance (uF/cm2) to Arbor specific capacitance (F/m2)
+CM_FACTOR = 0.01
+
+
+def get_arbor():
+    """Import Arbor.
+
+    Raises:
+        RuntimeError: if Arbor cannot be imported
+
+    Returns:
+        module: arbor
+    """
+    try:
+        # pylint: disable=import-outside-toplevel
+        import arbor
+    except ImportError as exc:
+        raise RuntimeError(
+            "arbor cannot be imported. "
+            "Install it with 'pip install emodelrunner[arbor]'."
+        ) from exc
+    return arbor
+
+
+def get_catalogue(arbor):
+    """Return the catalogue of the default and BBP mechanisms of Arbor.
+
+    Args:
+        arbor (module): arbor
+
+    Returns:
+        arbor.catalogue: catalogue of mechanisms
+    """
+    catalogue = arbor.default_catalogue()
+    catalogue.extend(arbor.bbp_catalogue(), "")
+    return catalogue
+
+
+def get_step_stimuli(protocol_definition):
+    """Return the current clamps of a step protocol with absolute amplitudes.
+
+    Args:
+        protocol_definition (dict): definition of a protocol of the protocols file
+
+    Raises:
+        ValueError: if the protocol is not a StepProtocol
+
+    Returns:
+        tuple: list of the delay (ms), duration (ms) and amplitude (nA)
+            of each clamp, with the holding current, and duration of the protocol (ms)
+    """
+    if protocol_definition.get("type") != "StepProtocol":
+        raise ValueError(
+            f"{protocol_definition.get('type')} protocols are not supported by Arbor."
+        )
+    stimuli = protocol_definition["stimuli"]
+    steps = stimuli["step"]
+    if isinstance(steps, dict):
+        steps = [steps]
+
+    clamps = [(step["delay"], step["duration"], step["amp"]) for step in steps]
+    tstop = max(step["totduration"] for step in steps)
+    holding = stimuli.get("holding")
+    if holding is not None:
+        clamps.append((holding["delay"], holding["duration"], holding["amp"]))
+        tstop = max(tstop, holding["totduration"])
+    return clamps, tstop
+
+
+def get_section_properties(sections, h):
+    """Return the properties of each section of a NEURON cell.
+
+    The values varying along a section are replaced by their mean over the section.
+
+    Args:
+        sections (list of neuron.nrn.Section): sections, each parent being first
+        h (neuron.hoc.HocObject): neuron hoc interpreter
+
+    Returns:
+        tuple: properties of each section (dict keyed by section name), with
+            the 3d points, the parent section, capacitance, axial resistivity,
+            mechanisms and ions,
+            and names of the mechanisms whose values vary along a section (set)
+    """
+    properties = {}
+    approximated = set()
+    for section in sections:
+        mechs, ions = get_section_mechanisms(section, h)
+        mean_mechs = {}
+        for mech_name, params in mechs.items():
+            mean_mechs[mech_name] = {}
+            for param_name, value in params.items():
+                mean_mechs[mech_name][param_name], varies = get_mean_value(value)
+                if varies:
+                    approximated.add(mech_name)
+        cm, varies = get_mean_value(compress_values([seg.cm for seg in section]))
+        if varies:
+            approximated.add("cm")
+
+        parent_seg = section.parentseg()
+        n3d = int(h.n3d(sec=section))
+        length = h.arc3d(n3d - 1, sec=section) or 1.0
+        properties[get_section_name(section)] = {
+            "points": [
+                (
+                    h.x3d(idx, sec=section),
+                    h.y3d(idx, sec=section),
+                    h.z3d(idx, sec=section),
+                    h.diam3d(idx, sec=section) / 2.0,
+                )
+                for idx in range(n3d)
+            ],
+            "arc": [h.arc3d(idx, sec=section) / length for idx in range(n3d)],
+            "parent": None if parent_seg is None else get_section_name(parent_seg.sec),
+            "parent_x": None if parent_seg is None else parent_seg.x,
+            "cm": cm,
+            "Ra": section.Ra,
+            "mechs": mean_mechs,
+            "ions": {
+                ion_name: get_mean_value(values["e"])[0]
+                for ion_name, values in ions.items()
+                if "e" in values
+            },
+        }
+    return properties, approximated
+
+
+def create_morphology(properties, arbor):
+    """Create the Arbor morphology of a cell, with a region for each section.
+
+    Each section is given its own tag. Since Arbor attaches the segments
+    at the end of their parent segment, a child section is attached at the end
+    of the segment of its parent section that is the closest to its NEURON
+    parent position, e.g. the middle of the soma.
+
+    Args:
+        properties (dict): properties of each section, see get_section_properties
+        arbor (module): arbor
+
+    Returns:
+        tuple: morphology (arbor.morphology) and region of each section (dict)
+    """
+    tree = arbor.segment_tree()
+    segment_ends = {}
+    regions = {}
+    for tag, (name, section) in enumerate(properties.items(), start=1):
+        parent = arbor.mnpos
+        ends = segment_ends.get(section["parent"])
+        if ends:
+            distances = [abs(end_x - section["parent_x"]) for _, end_x in ends]
+            parent = ends[int(np.argmin(distances))][0]
+        points = section["points"]
+        segment_ends[name] = []
+        for idx in range(1, len(points)):
+            # Arbor does not need the duplicated points
+            if points[idx] == points[idx - 1] and idx < len(points) - 1:
+                continue
+            parent = tree.append(
+                parent,
+                arbor.mpoint(*points[idx - 1]),
+                arbor.mpoint(*points[idx]),
+                tag=tag,
+            )
+            segment_ends[name].append((parent, section["arc"][idx]))
+        regions[name] = f"(tag {tag})"
+    return arbor.morphology(tree), regions
+
+
+def create_decor(properties, catalogue, v_init, celsius, arbor):
+    """Create the Arbor decor painting the properties and mechanisms of the sections.
+
+    Args:
+        properties (dict): properties of each section, see get_section_properties
+        catalogue (arbor.catalogue): catalogue of the available mechanisms
+        v_init (float): initial voltage (mV)
+        celsius (float): temperature (celsius)
+        arbor (module): arbor
+
+    Returns:
+        tuple: decor (arbor.decor), and the reason why each mechanism
+            that is not in the decor is missing (dict)
+    """
+    decor = arbor.decor()
+    decor.set_property(Vm=v_init, tempK=celsius + 273.15)
+    decor.set_ion(
+        "ca",
+        int_con=CA_INT_CON,
+        ext_con=CA_EXT_CON,
+        method=arbor.mechanism("nernst/x=ca"),
+    )
+
+    missing = {}
+    for name, section in properties.items():
+        region = f'"{name}"'
+        decor.paint(region, cm=section["cm"] * CM_FACTOR, rL=section["Ra"])
+        for ion_name, reversal in section["ions"].items():
+            if ion_name != "ca":
+                decor.paint(region, ion_name=ion_name, rev_pot=reversal)
+        for mech_name, params in section["mechs"].items():
+            if mech_name not in catalogue:
+                missing[mech_name] = "not in the Arbor catalogue"
+                continue
+            arbor_params = catalogue[mech_name].parameters
+            decor.paint(
+                region,
+                arbor.density(
+                    mech_name,
+                    {
+                        param_name: value
+                        for param_name, value in params.items()
+                        if param_name in arbor_params
+                    },
+                ),
+            )
+    return decor, missing
+
+
+def run_arbor_protocol(
+    morphology, regions, properties, clamps, tstop, dt, max_extent, arbor, **kwargs
+):
+    """Run a step protocol with Arbor, and return the voltage at the soma.
+
+    Args:
+        morphology (arbor.morphology): morphology of the cell
+        regions (dict): region of each section, the first section being the soma
+        properties (dict): properties of each section, see get_section_properties
+        clamps (list): delay (ms), duration (ms) and amplitude (nA) of each clamp
+        tstop (float): duration of the simulation (ms)
+        dt (float): time step (ms)
+        max_extent (float): maximal length of the compartments (um)
+        arbor (module): arbor
+        kwargs: catalogue, v_init and celsius, see create_decor
+
+    Returns:
+        dict: time and voltage at the soma
+    """
+    # pylint: disable=too-many-arguments
+    labels = dict(regions)
+    soma_name = next(iter(regions))
+    labels["soma_center"] = f'(on-components 0.5 (region "{soma_name}"))'
+
+    decor, _ = create_decor(
+        properties, kwargs["catalogue"], kwargs["v_init"], kwargs["celsius"], arbor
+    )
+    for idx, (delay, duration, amplitude) in enumerate(clamps):
+        decor.place(
+            '"soma_center"', arbor.iclamp(delay, duration, amplitude), f"clamp_{idx}"
+        )
+    decor.discretization(arbor.cv_policy_max_extent(max_extent))
+
+    cell = arbor.cable_cell(morphology, decor, arbor.label_dict(labels))
+    model = arbor.single_cell_model(cell)
+    model.properties.catalogue = kwargs["catalogue"]
+    model.probe("voltage", '"soma_center"', tag="soma.v", frequency=1.0 / dt)
+    model.run(tfinal=tstop, dt=dt)
+
+    trace = model.traces[0]
+    return {"time": np.array(trace.time), "voltage": np.array(trace.value)}
+
+
+def run_arbor(
+    config, output_dir="arbor_recordings", max_extent=10.0, reference_dir=None
+):
+    """Run the step protocols of a config with Arbor.
+
+    The cell is instantiated with NEURON, and translated to Arbor section
+    by section: the morphology, capacitance, axial resistivity, reversal potentials
+    and the mechanisms of the Arbor default and BBP catalogues. The values varying
+    along a section are replaced by their mean over the section, and the
+    calcium reversal potential follows the Nernst equation. Only the StepProtocol
+    protocols, with absolute amplitudes, are run. The voltage traces are written
+    as in python_recordings, with a report of the translation, of the run time
+    of each protocol, and optionally of the comparison with the traces
+    of reference_dir, e.g. the NEURON traces of python_recordings.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+        output_dir (str or Path): directory in which to write the traces and the report
+        max_extent (float): maximal length of the Arbor compartments (um)
+        reference_dir (str or Path): directory of the traces to compare with.
+            Not used if None.
+
+    Raises:
+        ValueError: if the config is a synplas config, or if no protocol can be run
+
+    Returns:
+        dict: report with the mechanisms missing in Arbor, the approximated mechanisms,
+            the skipped protocols, the run times and the comparison
+    """
+    # pylint: disable=too-many-locals
+    if config.package_type == PackageType.synplas:
+        raise ValueError("The Arbor backend does not support the synplas packages.")
+    arbor = get_arbor()
+    catalogue = get_catalogue(arbor)
+
+    with open(config.get("Paths", "prot_path"), "r", encoding="utf-8") as prot_file:
+        protocol_definitions = json.load(prot_file)
+    report = {"skipped_protocols": {}, "run_times": {}}
+    protocols = {}
+    for protocol_name, definition in protocol_definitions.items():
+        if protocol_name == "__comment":
+            continue
+        try:
+            protocols[protocol_name] = get_step_stimuli(definition)
+        except ValueError as exc:
+            report["skipped_protocols"][protocol_name] = str(exc)
+    if not protocols:
+        raise ValueError("No protocol of the config can be run with Arbor.")
+
+    cell = create_cell_using_config(config)
+    release_params = get_release_params(config)
+    sim = ephys.simulators.NrnSimulator(dt=config.getfloat("Sim", "dt"))
+    cell.freeze(release_params)
+    cell.instantiate(sim=sim)
+    h = sim.neuron.h
+    # 3d points of the sections without any, e.g. the stub axon
+    h.define_shape()
+    properties, approximated = get_section_properties(get_sections(cell.icell), h)
+    v_init, celsius = h.v_init, h.celsius
+    cell.destroy(sim=sim)
+    cell.unfreeze(release_params.keys())
+
+    morphology, regions = create_morphology(properties, arbor)
+    _, report["missing_mechanisms"] = create_decor(
+        properties, catalogue, v_init, celsius, arbor
+    )
+    report["approximated_mechanisms"] = sorted(approximated)
+    for mech_name, reason in report["missing_mechanisms"].items():
+        logger.warning("Mechanism %s not run with Arbor: %s", mech_name, reason)
+
+    responses = {}
+    mtype = config.get("Morphology", "mtype")
+    for protocol_name, (clamps, tstop) in protocols.items():
+        start = time.time()
+        responses[f"{mtype}.{protocol_name}.soma.v"] = run_arbor_protocol(
+            morphology,
+            regions,
+            properties,
+            clamps,
+            tstop,
+            config.getfloat("Sim", "dt"),
+            max_extent,
+            arbor,
+            catalogue=catalogue,
+            v_init=v_init,
+            celsius=celsius,
+        )
+        report["run_times"][protocol_name] = time.time() - start
+        logger.info("Protocol %s run with Arbor", protocol_name)
+
+    if reference_dir is not None:
+        # only the traces of the protocols run with Arbor are compared
+        reference = {
+            name: ref_response
+            for name, ref_response in load_reference(reference_dir).items()
+            if name in responses
+        }
+        report["comparison"] = compare_responses(responses, reference)
+
+    output_dir = Path(output_dir)
+    output_dir.mkdir(parents=True, exist_ok=True)
+    write_responses(responses, output_dir)
+    with open(output_dir / "arbor_report.json", "w", encoding="utf-8") as report_file:
+        json.dump(report, report_file, indent=4)
+
+    return report
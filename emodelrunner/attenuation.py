@@ -0,0 +1,191 @@
+"""Attenuation of the backpropagating action potentials and of the EPSPs."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import logging
+
+import numpy as np
+
+from emodelrunner.results import detect_spikes
+from emodelrunner.subthreshold import get_voltage_base
+
+logger = logging.getLogger(__name__)
+
+
+def get_trace(response):
+    """Return the time and voltage of a response as arrays.
+
+    Args:
+        response (dict): voltage response, with its 'time' and 'voltage'
+
+    Returns:
+        tuple: time (ms) and voltage (mV) of the response
+    """
+    return (
+        np.asarray(response["time"], dtype=float),
+        np.asarray(response["voltage"], dtype=float),
+    )
+
+
+def get_peak(time, voltage, start, end):
+    """Return the amplitude and the time of the voltage peak in a time window.
+
+    The amplitude is relative to the voltage base before the window.
+
+    Args:
+        time (numpy.ndarray): time of the trace (ms)
+        voltage (numpy.ndarray): voltage of the trace (mV)
+        start (float): start of the window, e.g. of the stimulus (ms)
+        end (float): end of the window (ms)
+
+    Returns:
+        tuple: amplitude (mV) and time (ms) of the peak, None if the window is empty
+    """
+    window = (time >= start) & (time <= end)
+    if not np.any(window):
+        return None, None
+    peak_idx = np.argmax(voltage[window])
+    amplitude = voltage[window][peak_idx] - get_voltage_base(time, voltage, start)
+    return float(amplitude), float(time[window][peak_idx])
+
+
+def get_bap_attenuation(
+    responses, soma_key, dendrite_keys, stim_start, spike_threshold=-20.0, window=10.0
+):
+    """Compute the attenuation of the first action potential in the dendrites.
+
+    Args:
+        responses (dict): responses of the protocols
+        soma_key (str): key of the soma voltage
+        dendrite_keys (dict): distance from the soma (um) of each dendritic
+            voltage key
+        stim_start (float): time at which the somatic step starts (ms)
+        spike_threshold (float): voltage threshold for spike detection (mV)
+        window (float): time after the somatic spike in which the dendritic
+            peaks are searched (ms)
+
+    Returns:
+        dict containing the somatic amplitude and, sorted by distance,
+        the distances, dendritic amplitudes, attenuations (dendritic amplitude
+        over somatic amplitude) and latencies of the peaks from the somatic one,
+        None if the soma does not spike
+    """
+    # pylint: disable=too-many-locals
+    time, voltage = get_trace(responses[soma_key])
+    spikes = detect_spikes(time, voltage, spike_threshold)
+    spikes = spikes[spikes >= stim_start]
+    if len(spikes) == 0:
+        return None
+    end = spikes[0] + window
+    soma_amplitude, soma_peak_time = get_peak(time, voltage, stim_start, end)
+
+    attenuation = {
+        "soma_amplitude": soma_amplitude,
+        "distances": [],
+        "amplitudes": [],
+        "attenuation": [],
+        "latencies": [],
+    }
+    for key, distance in sorted(dendrite_keys.items(), key=lambda item: item[1]):
+        if responses.get(key) is None:
+            logger.warning("No response for %s.", key)
+            continue
+        amplitude, peak_time = get_peak(*get_trace(responses[key]), stim_start, end)
+        attenuation["distances"].append(distance)
+        attenuation["amplitudes"].append(amplitude)
+        attenuation["attenuation"].append(amplitude / soma_amplitude)
+        attenuation["latencies"].append(peak_time - soma_peak_time)
+
+    return attenuation
+
+
+def get_epsp_attenuation(responses, sites, stim_start):
+    """Compute the attenuation of the EPSPs from the injection sites to the soma.
+
+    Args:
+        responses (dict): responses of the protocols
+        sites (list): distance from the soma (um), key of the soma voltage
+            and key of the local voltage of each injection site
+        stim_start (float): time at which the EPSP-like current starts (ms)
+
+    Returns:
+        dict containing, for each site, the distances, the local and somatic
+        EPSP amplitudes and the attenuations (somatic over local amplitude)
+    """
+    attenuation = {
+        "distances": [],
+        "local_amplitudes": [],
+        "soma_amplitudes": [],
+        "attenuation": [],
+    }
+    for site in sites:
+        soma_response = responses.get(site["soma_key"])
+        local_response = responses.get(site["local_key"])
+        if soma_response is None or local_response is None:
+            logger.warning("No response for the site at %s um.", site["distance"])
+            continue
+        soma_time, soma_voltage = get_trace(soma_response)
+        local_time, local_voltage = get_trace(local_response)
+        soma_amplitude, _ = get_peak(soma_time, soma_voltage, stim_start, soma_time[-1])
+        local_amplitude, _ = get_peak(
+            local_time, local_voltage, stim_start, local_time[-1]
+        )
+        attenuation["distances"].append(site["distance"])
+        attenuation["local_amplitudes"].append(local_amplitude)
+        attenuation["soma_amplitudes"].append(soma_amplitude)
+        attenuation["attenuation"].append(soma_amplitude / local_amplitude)
+
+    return attenuation
+
+
+def compute_attenuations(responses, attenuation_protocols):
+    """Compute the attenuation of each bAP and EPSP attenuation protocol.
+
+    Args:
+        responses (dict): responses of the protocols
+        attenuation_protocols (dict): type and recordings of each attenuation
+            protocol, see ProtocolBuilder.get_attenuation_protocols
+
+    Returns:
+        dict: the attenuation of each protocol name,
+            see get_bap_attenuation and get_epsp_attenuation
+    """
+    attenuations = {}
+    for name, protocol in attenuation_protocols.items():
+        if protocol["type"] == "bAP":
+            attenuation = get_bap_attenuation(
+                responses,
+                protocol["soma_key"],
+                protocol["dendrite_keys"],
+                protocol["stim_start"],
+            )
+            if attenuation is None:
+                logger.warning("%s: the soma does not spike, no bAP to measure.", name)
+                continue
+        else:
+            attenuation = get_epsp_attenuation(
+                responses, protocol["sites"], protocol["stim_start"]
+            )
+        attenuation["type"] = protocol["type"]
+        attenuations[name] = attenuation
+        logger.info(
+            "%s: %s attenuation %s at %s um",
+            name,
+            protocol["type"],
+            [round(value, 3) for value in attenuation["attenuation"]],
+            attenuation["distances"],
+        )
+
+    return attenuations
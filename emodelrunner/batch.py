@@ -0,0 +1,283 @@
+"""Batch runs of cells and protocols, distributed over the ranks of an MPI job."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import logging
+import subprocess
+import sys
+import time
+from pathlib import Path
+
+import emodelrunner
+from emodelrunner.json_utilities import NpEncoder
+from emodelrunner.provenance import get_emodelrunner_git_sha
+
+logger = logging.getLogger(__name__)
+
+# number of lines of the output of a failed job kept in the report
+N_ERROR_LINES = 5
+
+
+def get_mpi_comm():
+    """Return the MPI communicator of all the ranks.
+
+    Raises:
+        RuntimeError: if mpi4py cannot be imported
+
+    Returns:
+        mpi4py.MPI.Comm: the world communicator
+    """
+    try:
+        # pylint: disable=import-outside-toplevel
+        from mpi4py import MPI
+    except ImportError as exc:
+        raise RuntimeError(
+            "mpi4py cannot be imported. "
+            "Install it with 'pip install emodelrunner[mpi]'."
+        ) from exc
+    return MPI.COMM_WORLD
+
+
+def load_batch_definition(batch_path):
+    """Load the cells and the protocols files of a batch.
+
+    Args:
+        batch_path (str or Path): path to the json file defining the batch, e.g.
+            {"cells": [{"package_dir": "sscx_sample_dir",
+            "config_path": "config/config_allsteps.ini"}],
+            "protocols": ["config/protocols/singlestep.json"]}
+
+    Raises:
+        ValueError: if a cell has no package directory or config path,
+            or if two cells have the same name
+
+    Returns:
+        dict: the cells and the protocols files of the batch
+    """
+    with open(batch_path, "r", encoding="utf-8") as batch_file:
+        batch_definition = json.load(batch_file)
+
+    cells = batch_definition.get("cells")
+    if not isinstance(cells, list) or not cells:
+        raise ValueError(f"No cells to run are defined in {batch_path}.")
+
+    names = set()
+    for cell in cells:
+        for key in ["package_dir", "config_path"]:
+            if key not in cell:
+                raise ValueError(f"The cell {cell} of {batch_path} has no {key}.")
+        name = cell.get("name", Path(cell["package_dir"]).name)
+        if name in names:
+            raise ValueError(
+                f"Two cells of {batch_path} are named {name}. "
+                "Give them different names with the 'name' key."
+            )
+        names.add(name)
+
+    protocols = batch_definition.get("protocols", [])
+    if not isinstance(protocols, list):
+        raise ValueError(f"The protocols of {batch_path} should be a list.")
+
+    return {"cells": cells, "protocols": protocols}
+
+
+def get_batch_jobs(batch_definition, batch_dir="."):
+    """Return the jobs of a batch, one for each cell and protocols file.
+
+    Args:
+        batch_definition (dict): the cells and the protocols files of the batch
+        batch_dir (str or Path): the directory the relative package directories
+            are resolved from, usually the one of the batch file
+
+    Returns:
+        list of dict: the name, package directory, config path, protocols file
+            and config overrides of each job. The protocols file is None
+            if the one of the config is used.
+    """
+    jobs = []
+    for cell in batch_definition["cells"]:
+        package_dir = Path(batch_dir) / cell["package_dir"]
+        cell_name = cell.get("name", Path(cell["package_dir"]).name)
+        # the protocols files are relative to the cell package directory
+        for prot_path in batch_definition["protocols"] or [None]:
+            if prot_path is None:
+                name = cell_name
+            else:
+                name = f"{cell_name}.{Path(prot_path).stem}"
+            jobs.append(
+                {
+                    "name": name,
+                    "package_dir": str(package_dir),
+                    "config_path": cell["config_path"],
+                    "prot_path": prot_path,
+                    "config_overrides": cell.get("config_overrides", []),
+                }
+            )
+
+    return jobs
+
+
+def get_job_command(job, job_dir):
+    """Return the command running a job in its own process.
+
+    Args:
+        job (dict): the job, as returned by get_batch_jobs
+        job_dir (Path): the absolute path of the output directory of the job
+
+    Returns:
+        list of str: the command, to be run from the package directory of the job
+    """
+    command = [
+        sys.executable,
+        "-m",
+        "emodelrunner.cli",
+        "run",
+        "--config_path",
+        job["config_path"],
+    ]
+    config_overrides = list(job["config_overrides"]) + [f"Paths.output_dir={job_dir}"]
+    if job["prot_path"] is not None:
+        config_overrides.append(f"Paths.prot_path={job['prot_path']}")
+    for config_override in config_overrides:
+        command += ["--set", config_override]
+
+    return command
+
+
+def run_job(job, output_dir, timeout=None):
+    """Run a job in its own process, so that no NEURON state is shared between jobs.
+
+    The output of the job is written in output_dir/job_name/run.log.
+
+    Args:
+        job (dict): the job, as returned by get_batch_jobs
+        output_dir (Path): the absolute path of the output directory of the batch
+        timeout (float): the maximum duration of the job (s). No limit if None.
+
+    Returns:
+        dict: the job with its status ("done", "failed" or "timeout"), exit code,
+            duration (s), output directory and the last lines of its output if it failed
+    """
+    job_dir = output_dir / job["name"]
+    job_dir.mkdir(parents=True, exist_ok=True)
+
+    result = dict(job, job_dir=str(job_dir), returncode=None, error="")
+    start = time.time()
+    with open(job_dir / "run.log", "w", encoding="utf-8") as log_file:
+        try:
+            process = subprocess.run(
+                get_job_command(job, job_dir),
+                cwd=job["package_dir"],
+                stdout=log_file,
+                stderr=subprocess.STDOUT,
+                timeout=timeout,
+                check=False,
+            )
+        except subprocess.TimeoutExpired:
+            result["status"] = "timeout"
+            result["error"] = f"The job did not finish within {timeout} s."
+        except OSError as exc:
+            result["status"] = "failed"
+            result["error"] = str(exc)
+        else:
+            result["returncode"] = process.returncode
+            result["status"] = "done" if process.returncode == 0 else "failed"
+    result["duration"] = time.time() - start
+
+    if result["status"] == "failed" and not result["error"]:
+        with open(job_dir / "run.log", "r", encoding="utf-8") as log_file:
+            lines = [line.rstrip() for line in log_file if line.strip()]
+        result["error"] = "\n".join(lines[-N_ERROR_LINES:])
+
+    return result
+
+
+def get_batch_report(results, n_ranks):
+    """Return the consolidated report of a batch.
+
+    Args:
+        results (list of dict): the results of all the jobs, as returned by run_job
+        n_ranks (int): the number of ranks the jobs were distributed over
+
+    Returns:
+        dict: the number of jobs of each status, the failed jobs and all the jobs
+    """
+    results = sorted(results, key=lambda result: result["name"])
+    statuses = [result["status"] for result in results]
+    return {
+        "emodelrunner_version": getattr(emodelrunner, "__version__", None),
+        "emodelrunner_git_sha": get_emodelrunner_git_sha(),
+        "n_ranks": n_ranks,
+        "n_jobs": len(results),
+        "n_done": statuses.count("done"),
+        "n_failed": statuses.count("failed"),
+        "n_timeout": statuses.count("timeout"),
+        "failures": [result for result in results if result["status"] != "done"],
+        "jobs": results,
+    }
+
+
+def run_batch(batch_path, output_dir, comm=None, timeout=None):
+    """Run the jobs of a batch, distributed over the ranks of an MPI job.
+
+    The jobs are assigned to the ranks in a round-robin way, and each rank
+    runs its jobs one after the other. The outputs of each job are written
+    in output_dir/job_name, and the consolidated report in
+    output_dir/batch_report.json by the rank 0.
+
+    Args:
+        batch_path (str or Path): path to the json file defining the batch
+        output_dir (str or Path): directory in which to write the outputs of the batch
+        comm (mpi4py.MPI.Comm): the communicator of the ranks, as returned by
+            get_mpi_comm. The jobs are run in the current process only if None.
+        timeout (float): the maximum duration of each job (s). No limit if None.
+
+    Returns:
+        dict: the consolidated report on the rank 0, None on the other ranks
+    """
+    rank = 0 if comm is None else comm.Get_rank()
+    n_ranks = 1 if comm is None else comm.Get_size()
+
+    batch_definition = load_batch_definition(batch_path)
+    jobs = get_batch_jobs(batch_definition, Path(batch_path).parent)
+    output_dir = Path(output_dir).resolve()
+
+    rank_jobs = jobs[rank::n_ranks]
+    logger.info("Rank %d running %d of %d jobs.", rank, len(rank_jobs), len(jobs))
+    results = []
+    for job in rank_jobs:
+        results.append(run_job(job, output_dir, timeout))
+        logger.info("Job %s %s.", job["name"], results[-1]["status"])
+
+    if comm is not None:
+        rank_results = comm.gather(results, root=0)
+        if rank != 0:
+            return None
+        results = [result for results_ in rank_results for result in results_]
+
+    report = get_batch_report(results, n_ranks)
+    output_dir.mkdir(parents=True, exist_ok=True)
+    with open(output_dir / "batch_report.json", "w", encoding="utf-8") as report_file:
+        json.dump(report, report_file, indent=4, cls=NpEncoder)
+
+    if report["failures"]:
+        logger.warning(
+            "%d of %d batch jobs did not succeed.",
+            len(report["failures"]),
+            report["n_jobs"],
+        )
+
+    return report
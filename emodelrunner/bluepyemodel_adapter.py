@@ -0,0 +1,255 @@
+"""Cell packages and runs of the e-models of BluePyEModel access points."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import json
+import logging
+import shutil
+import subprocess
+import sys
+from pathlib import Path
+
+from emodelrunner.bluepyemodel_recipes import (
+    convert_fitness_calculator_configuration,
+    write_package_config,
+)
+from emodelrunner.environment import setup_environment
+
+logger = logging.getLogger(__name__)
+
+ACCESS_POINT_TYPES = ["local", "nexus"]
+
+
+def get_access_point(access_point_type, emodel, **kwargs):
+    """Get a BluePyEModel access point to an e-model.
+
+    Args:
+        access_point_type (str): "local" or "nexus"
+        emodel (str): name of the e-model
+        kwargs: other arguments of the BluePyEModel access point, e.g. emodel_dir,
+            recipes_path and iteration_tag for a local access point, or project,
+            organisation and endpoint for a Nexus access point
+
+    Raises:
+        ValueError: if the access point type is not supported
+        RuntimeError: if BluePyEModel cannot be imported
+
+    Returns:
+        bluepyemodel.access_point.access_point.DataAccessPoint: the access point
+    """
+    if access_point_type not in ACCESS_POINT_TYPES:
+        raise ValueError(
+            f"Access point type {access_point_type} is not supported. "
+            f"Choose from {ACCESS_POINT_TYPES}."
+        )
+    try:
+        # pylint: disable=import-outside-toplevel
+        from bluepyemodel.access_point import get_access_point as get_bpem_access_point
+    except ImportError as exc:
+        raise RuntimeError(
+            "BluePyEModel cannot be imported. "
+            "Install it with 'pip install emodelrunner[bluepyemodel]'."
+        ) from exc
+
+    kwargs = {key: value for key, value in kwargs.items() if value is not None}
+    return get_bpem_access_point(access_point_type, emodel, **kwargs)
+
+
+def convert_model_configuration(model_configuration):
+    """Convert a BluePyEModel model configuration to unoptimized parameters.
+
+    Args:
+        model_configuration (dict): model configuration, with the lists of
+            mechanisms, distributions and parameters
+
+    Returns:
+        dict: mechanisms, distributions and parameters, in the format
+            of the unoptimized parameters file of the cell packages
+    """
+    mechanisms = {}
+    for mechanism in model_configuration["mechanisms"]:
+        mechs = mechanisms.setdefault(mechanism["location"], {"mech": []})["mech"]
+        if mechanism["name"] not in mechs:
+            mechs.append(mechanism["name"])
+
+    distributions = {}
+    for distribution in model_configuration.get("distributions", []):
+        if distribution["name"] == "uniform":
+            continue
+        definition = {"fun": distribution["function"]}
+        if distribution.get("parameters"):
+            definition["parameters"] = list(distribution["parameters"])
+        distributions[distribution["name"]] = definition
+
+    parameters = {}
+    for parameter in model_configuration["parameters"]:
+        definition = {"name": parameter["name"], "val": parameter["value"]}
+        distribution = parameter.get("distribution", "uniform")
+        if distribution != "uniform":
+            definition["dist"] = distribution
+        parameters.setdefault(parameter["location"], []).append(definition)
+
+    return {
+        "mechanisms": mechanisms,
+        "distributions": distributions,
+        "parameters": parameters,
+    }
+
+
+def get_mechanisms_dir(access_point):
+    """Get the directory of the mod files of an access point.
+
+    Args:
+        access_point (DataAccessPoint): BluePyEModel access point
+
+    Returns:
+        Path: directory of the mod files, that may not exist
+    """
+    if hasattr(access_point, "get_mechanisms_directory"):
+        mechanisms_dir = access_point.get_mechanisms_directory()
+        if mechanisms_dir is not None:
+            return Path(mechanisms_dir)
+    return Path(getattr(access_point, "emodel_dir", ".")) / "mechanisms"
+
+
+def write_access_point_package(
+    access_point,
+    output_dir,
+    package_type="sscx",
+    template_dir=None,
+    main_protocol_definitions=None,
+    skip_unsupported=False,
+):
+    """Write a cell package running the e-model of a BluePyEModel access point.
+
+    The unoptimized parameters and the morphology come from the model configuration
+    of the access point, the optimized parameters from its e-model, and the protocols
+    and features from its fitness calculator configuration, converted as in
+    convert_fitness_calculator_configuration. The mod files of the access point
+    are copied, to be compiled by 'emodelrunner setup'.
+
+    Args:
+        access_point (DataAccessPoint): BluePyEModel access point,
+            e.g. from get_access_point
+        output_dir (str or Path): directory of the cell package
+        package_type (str): package type of the config, e.g. "sscx" or "hippocampus"
+        template_dir (str or Path): cell package whose missing files are copied.
+            Not used if None.
+        main_protocol_definitions (dict): see convert_fitness_calculator_configuration
+        skip_unsupported (bool): see convert_fitness_calculator_configuration
+
+    Raises:
+        ValueError: if the access point has no optimized e-model
+        FileNotFoundError: if the morphology is missing
+
+    Returns:
+        Path: path to the config of the cell package, relative to which
+            the package has to be run
+    """
+    # pylint: disable=too-many-arguments, too-many-locals
+    emodel_name = access_point.emodel_metadata.emodel
+    model_configuration = access_point.get_model_configuration().as_dict()
+    emodel = access_point.get_emodel()
+    if emodel is None:
+        raise ValueError(f"No optimized e-model {emodel_name} in the access point.")
+    fitness_calculator_configuration = (
+        access_point.get_fitness_calculator_configuration().as_dict()
+    )
+
+    morphology = model_configuration["morphology"]
+    morph_path = Path(morphology["path"])
+    if not morph_path.is_file():
+        raise FileNotFoundError(f"{morph_path} not found.")
+
+    output_dir = Path(output_dir)
+    config_dir = output_dir / "config"
+    protocol_definitions, feature_definitions = (
+        convert_fitness_calculator_configuration(
+            fitness_calculator_configuration,
+            main_protocol_definitions,
+            skip_unsupported,
+        )
+    )
+    paths = {
+        "unoptimized_params_path": config_dir / "params" / f"{emodel_name}.json",
+        "params_path": config_dir / "params" / "final.json",
+        "prot_path": config_dir / "protocols" / f"{emodel_name}.json",
+        "features_path": config_dir / "features" / f"{emodel_name}.json",
+    }
+    for key, definitions in [
+        ("unoptimized_params_path", convert_model_configuration(model_configuration)),
+        ("params_path", {emodel_name: {"params": dict(emodel.parameters)}}),
+        ("prot_path", protocol_definitions),
+        ("features_path", feature_definitions),
+    ]:
+        paths[key].parent.mkdir(parents=True, exist_ok=True)
+        with open(paths[key], "w", encoding="utf-8") as json_file:
+            json.dump(definitions, json_file, indent=4)
+
+    paths["morph_path"] = output_dir / "morphology" / morph_path.name
+    paths["morph_path"].parent.mkdir(parents=True, exist_ok=True)
+    shutil.copyfile(morph_path, paths["morph_path"])
+
+    return write_package_config(
+        output_dir,
+        emodel_name,
+        morphology.get("name") or morph_path.stem,
+        paths,
+        package_type=package_type,
+        template_dir=template_dir,
+        mechanisms_dir=get_mechanisms_dir(access_point),
+    )
+
+
+def run_access_point(access_point, output_dir, cache_dir=None, **kwargs):
+    """Write the cell package of an access point, compile its mechanisms and run it.
+
+    Args:
+        access_point (DataAccessPoint): BluePyEModel access point
+        output_dir (str or Path): directory of the cell package
+        cache_dir (str): cache directory of the compiled mechanisms.
+            See environment.get_cache_dir for the default.
+        kwargs: other arguments of write_access_point_package
+
+    Raises:
+        RuntimeError: if the run fails
+
+    Returns:
+        Path: path to the config of the cell package
+    """
+    config_path = write_access_point_package(access_point, output_dir, **kwargs)
+    output_dir = Path(output_dir)
+    setup_environment(output_dir, cache_dir=cache_dir)
+
+    config_path = config_path.resolve().relative_to(output_dir.resolve())
+    process = subprocess.run(
+        [
+            sys.executable,
+            "-m",
+            "emodelrunner.cli",
+            "run",
+            "--config_path",
+            config_path.as_posix(),
+        ],
+        cwd=output_dir,
+        check=False,
+    )
+    if process.returncode != 0:
+        raise RuntimeError(
+            f"The run of {config_path} in {output_dir} failed "
+            f"with exit code {process.returncode}."
+        )
+    return output_dir / config_path
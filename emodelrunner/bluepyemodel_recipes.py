@@ -0,0 +1,666 @@
+"""Conversion of the BluePyEModel recipes to EModelRunner protocols and features files."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import configparser
+import copy
+import json
+import logging
+import shutil
+from pathlib import Path
+
+from emodelrunner.registry import TEMPLATE_IGNORE_PATTERNS, copy_missing_files
+
+logger = logging.getLogger(__name__)
+
+# names of the protocols used by the Main protocol in BluePyEModel and EModelRunner
+MAIN_PROTOCOL_NAMES = {
+    "RMPProtocol": "RMP",
+    "RinProtocol": "Rin",
+    "SearchHoldingCurrent": "RinHoldCurrent",
+    "SearchThresholdCurrent": "Threshold",
+}
+
+EXTRA_RECORDING_TYPES = ["somadistance", "somadistanceapic", "nrnseclistcomp"]
+
+# protocols computing the resting potential, holding and threshold currents.
+# BluePyEModel defines them in its code, with settings that are not in the recipes.
+DEFAULT_MAIN_PROTOCOL_DEFINITIONS = {
+    "RMP": {
+        "type": "StepProtocol",
+        "stimuli": {
+            "step": {
+                "delay": 700.0,
+                "amp": 0.0,
+                "duration": 1000.0,
+                "totduration": 1700.0,
+            }
+        },
+    },
+    "Rin": {
+        "type": "StepProtocol",
+        "stimuli": {
+            "step": {
+                "delay": 700.0,
+                "amp": -0.02,
+                "duration": 1000.0,
+                "totduration": 1900.0,
+            },
+            "holding": {
+                "delay": 0.0,
+                "amp": None,
+                "duration": 1900.0,
+                "totduration": 1900.0,
+            },
+        },
+    },
+    "RinHoldcurrent": {
+        "type": "RatSSCxRinHoldcurrentProtocol",
+        "holdi_precision": 0.1,
+        "holdi_max_depth": 7,
+    },
+    "ThresholdDetection": {
+        "type": "RatSSCxThresholdDetectionProtocol",
+        "step_template": {
+            "type": "StepProtocol",
+            "stimuli": {
+                "step": {
+                    "delay": 700.0,
+                    "amp": None,
+                    "duration": 2000.0,
+                    "totduration": 3000.0,
+                },
+                "holding": {
+                    "delay": 0.0,
+                    "amp": None,
+                    "duration": 3000.0,
+                    "totduration": 3000.0,
+                },
+            },
+        },
+    },
+}
+
+# features needed by the Main protocol, see set_sscx_main_protocol_efeatures
+MAIN_PROTOCOL_FEATURES = [
+    ("RMP", "voltage_base"),
+    ("Rin", "ohmic_input_resistance_vb_ssse"),
+    ("Rin", "voltage_base"),
+]
+
+
+def load_recipe(recipes_path, emodel):
+    """Return the recipe of an e-model in a BluePyEModel recipes file.
+
+    Args:
+        recipes_path (str or Path): path to the recipes file
+        emodel (str): name of the e-model
+
+    Raises:
+        ValueError: if the e-model is not in the recipes
+
+    Returns:
+        dict: recipe of the e-model
+    """
+    with open(recipes_path, "r", encoding="utf-8") as recipes_file:
+        recipes = json.load(recipes_file)
+    if emodel not in recipes:
+        raise ValueError(f"{emodel} not found in {recipes_path}.")
+
+    return recipes[emodel]
+
+
+def get_recipe_morphology(recipe, emodel_dir="."):
+    """Return the name and the path of the morphology of a BluePyEModel recipe.
+
+    The morphology is the first one of the 'morphology' list of [name, file name]
+    of the recipe, in its 'morph_path' directory.
+
+    Args:
+        recipe (dict): recipe of an e-model
+        emodel_dir (str or Path): directory the paths of the recipe are relative to
+
+    Returns:
+        tuple: name and path of the morphology, or (None, None) if the recipe
+            has no morphology
+    """
+    morphologies = recipe.get("morphology")
+    if not morphologies:
+        return None, None
+    name, file_name = morphologies[0]
+    return name, Path(emodel_dir) / recipe.get("morph_path", "") / file_name
+
+
+def read_recipe(recipes_path, emodel, emodel_dir="."):
+    """Return the paths to the files of an e-model in a BluePyEModel recipes file.
+
+    Args:
+        recipes_path (str or Path): path to the recipes file
+        emodel (str): name of the e-model
+        emodel_dir (str or Path): directory the paths of the recipe are relative to
+
+    Raises:
+        ValueError: if the e-model is not in the recipes
+
+    Returns:
+        dict: paths to the protocols, features and unoptimized parameters files,
+            to the optimized parameters ('final', final.json by default as in
+            BluePyEModel) and to the morphology, None for the files that are not
+            in the recipe
+    """
+    recipe = load_recipe(recipes_path, emodel)
+    recipe_paths = {
+        key: Path(emodel_dir) / recipe[recipe_key] if recipe_key in recipe else None
+        for key, recipe_key in [
+            ("protocols", "protocol"),
+            ("features", "features"),
+            ("params", "params"),
+        ]
+    }
+    recipe_paths["final"] = Path(emodel_dir) / recipe.get("final", "final.json")
+    recipe_paths["morphology"] = get_recipe_morphology(recipe, emodel_dir)[1]
+    return recipe_paths
+
+
+def is_fitness_calculator_configuration(definitions):
+    """Return whether definitions are a BluePyEModel fitness calculator configuration.
+
+    Args:
+        definitions (dict): content of a json file
+
+    Returns:
+        bool: True if the definitions contain the lists of protocols and efeatures
+    """
+    return isinstance(definitions.get("protocols"), list) and isinstance(
+        definitions.get("efeatures"), list
+    )
+
+
+def convert_step_stimulus(stimulus, threshold_based):
+    """Convert a BluePyEModel step stimulus.
+
+    Args:
+        stimulus (dict): BluePyEModel stimulus
+        threshold_based (bool): whether the amplitude is relative to the threshold current
+
+    Raises:
+        ValueError: if the stimulus is not a step
+
+    Returns:
+        dict: EModelRunner step stimulus
+    """
+    missing_keys = {"delay", "duration", "totduration"} - set(stimulus)
+    if missing_keys:
+        raise ValueError(f"Only step stimuli are supported. {missing_keys} missing.")
+
+    step = {
+        "delay": stimulus["delay"],
+        "amp": None if threshold_based else stimulus.get("amp"),
+        "duration": stimulus["duration"],
+        "totduration": stimulus["totduration"],
+    }
+    if threshold_based:
+        step["thresh_perc"] = stimulus["thresh_perc"]
+    return step
+
+
+def convert_recording(recording):
+    """Convert a BluePyEModel recording to an EModelRunner extra recording.
+
+    Args:
+        recording (dict): BluePyEModel recording
+
+    Returns:
+        dict: EModelRunner extra recording, or None if the recording is the somatic
+            voltage, always recorded by EModelRunner, or if it is not supported
+    """
+    if recording["type"] in EXTRA_RECORDING_TYPES:
+        extra_recording = {
+            key: value
+            for key, value in recording.items()
+            if key not in ["variable", "location"]
+        }
+        extra_recording["var"] = recording["variable"]
+        return extra_recording
+
+    if recording.get("location") == "soma" and recording.get("variable") == "v":
+        return None
+
+    logger.warning("Recording %s not supported. Skipping it.", recording["name"])
+    return None
+
+
+def convert_protocol(protocol):
+    """Convert a BluePyEModel protocol.
+
+    Args:
+        protocol (dict): protocol of a fitness calculator configuration
+
+    Raises:
+        ValueError: if the protocol has no stimulus
+
+    Returns:
+        dict: EModelRunner protocol definition
+    """
+    if not protocol["stimuli"]:
+        raise ValueError("The protocol has no stimulus.")
+
+    threshold_based = protocol.get("protocol_type") == "ThresholdBasedProtocol"
+    if any(stimulus.get("thresh_perc") is None for stimulus in protocol["stimuli"]):
+        threshold_based = False
+    steps = [
+        convert_step_stimulus(stimulus, threshold_based)
+        for stimulus in protocol["stimuli"]
+    ]
+
+    stochasticity = protocol.get("stochasticity")
+    if stochasticity is not None:
+        for step in steps:
+            step["stochkv_det"] = not stochasticity
+
+    stimuli = {"step": steps[0] if len(steps) == 1 else steps}
+    holding_current = protocol["stimuli"][0].get("holding_current")
+    if not threshold_based and holding_current is not None:
+        totduration = steps[0]["totduration"]
+        stimuli["holding"] = {
+            "delay": 0.0,
+            "amp": holding_current,
+            "duration": totduration,
+            "totduration": totduration,
+        }
+
+    definition = {
+        "type": "StepThresholdProtocol" if threshold_based else "StepProtocol",
+        "stimuli": stimuli,
+    }
+
+    extra_recordings = [
+        convert_recording(recording) for recording in protocol.get("recordings", [])
+    ]
+    extra_recordings = [recording for recording in extra_recordings if recording]
+    if extra_recordings:
+        definition["extra_recordings"] = extra_recordings
+
+    return definition
+
+
+def get_feature_std(efeature):
+    """Return the standard deviation of a BluePyEModel efeature, as BluePyEModel does.
+
+    Args:
+        efeature (dict): efeature of a fitness calculator configuration
+
+    Returns:
+        float: standard deviation
+    """
+    std = efeature.get("original_std", efeature.get("std"))
+    threshold_std = efeature.get("threshold_efeature_std")
+    if threshold_std:
+        std = max(std, abs(threshold_std * efeature["mean"]))
+    if not std:
+        std = efeature.get("default_std_value", 1e-3)
+    return std
+
+
+def convert_efeature(efeature):
+    """Convert a BluePyEModel efeature.
+
+    Args:
+        efeature (dict): efeature of a fitness calculator configuration
+
+    Returns:
+        tuple: protocol name, recording name and EModelRunner feature definition
+    """
+    feature = {
+        "feature": efeature["efel_feature_name"],
+        "val": [efeature["mean"], get_feature_std(efeature)],
+    }
+    efel_settings = efeature.get("efel_settings", {})
+    if "strict_stiminterval" in efel_settings:
+        feature["strict_stim"] = efel_settings["strict_stiminterval"]
+    if "Threshold" in efel_settings:
+        feature["threshold"] = efel_settings["Threshold"]
+
+    protocol_name = MAIN_PROTOCOL_NAMES.get(
+        efeature["protocol_name"], efeature["protocol_name"]
+    )
+    return protocol_name, efeature["recording_name"], feature
+
+
+def move_holding_voltage_feature(feature_definitions):
+    """Use the holding voltage targeted by BluePyEModel as voltage base of Rin.
+
+    BluePyEModel searches the holding current giving the steady_state_voltage_stimint
+    of SearchHoldingCurrent, EModelRunner the one giving the voltage_base of Rin.
+
+    Args:
+        feature_definitions (dict): EModelRunner feature definitions, updated in place
+    """
+    holding_features = feature_definitions.get("RinHoldCurrent", {}).get("soma.v", [])
+    holding_voltage_features = [
+        feature
+        for feature in holding_features
+        if feature["feature"] == "steady_state_voltage_stimint"
+    ]
+    for feature in holding_voltage_features:
+        holding_features.remove(feature)
+
+    rin_features = feature_definitions.setdefault("Rin", {}).setdefault("soma.v", [])
+    if holding_voltage_features and not any(
+        feature["feature"] == "voltage_base" for feature in rin_features
+    ):
+        rin_features.append({**holding_voltage_features[0], "feature": "voltage_base"})
+
+
+def convert_fitness_calculator_configuration(
+    fitness_calculator_configuration,
+    main_protocol_definitions=None,
+    skip_unsupported=False,
+):
+    """Convert a BluePyEModel fitness calculator configuration.
+
+    If some protocols are relative to the threshold current, they are run by a Main
+    protocol, with the RMP, Rin, RinHoldcurrent and ThresholdDetection protocols
+    of main_protocol_definitions.
+
+    Args:
+        fitness_calculator_configuration (dict): fitness calculator configuration,
+            with the lists of protocols and efeatures
+        main_protocol_definitions (dict): definitions of the protocols computing
+            the resting potential, holding and threshold currents.
+            DEFAULT_MAIN_PROTOCOL_DEFINITIONS is used if None.
+        skip_unsupported (bool): set to True to skip the protocols that cannot be
+            converted, and their efeatures, instead of raising a ValueError
+
+    Raises:
+        ValueError: if a protocol cannot be converted, or if a feature needed
+            by the Main protocol is missing
+
+    Returns:
+        tuple: EModelRunner protocol definitions and feature definitions
+    """
+    protocol_definitions = {}
+    for protocol in fitness_calculator_configuration["protocols"]:
+        try:
+            protocol_definitions[protocol["name"]] = convert_protocol(protocol)
+        except ValueError as exc:
+            if not skip_unsupported:
+                raise ValueError(f"Protocol {protocol['name']}: {exc}") from exc
+            logger.warning("Protocol %s skipped: %s", protocol["name"], exc)
+
+    feature_definitions = {}
+    for efeature in fitness_calculator_configuration["efeatures"]:
+        protocol_name, recording_name, feature = convert_efeature(efeature)
+        if (
+            protocol_name not in protocol_definitions
+            and protocol_name not in MAIN_PROTOCOL_NAMES.values()
+        ):
+            logger.warning(
+                "Protocol %s not found. Skipping its features.", protocol_name
+            )
+            continue
+        feature_definitions.setdefault(protocol_name, {}).setdefault(
+            recording_name, []
+        ).append(feature)
+
+    threshold_based = any(
+        definition["type"] == "StepThresholdProtocol"
+        for definition in protocol_definitions.values()
+    )
+    if not threshold_based:
+        for protocol_name in MAIN_PROTOCOL_NAMES.values():
+            if feature_definitions.pop(protocol_name, None):
+                logger.info(
+                    "No protocol relative to the threshold current. "
+                    "Skipping the features of %s.",
+                    protocol_name,
+                )
+        return protocol_definitions, feature_definitions
+
+    move_holding_voltage_feature(feature_definitions)
+    for protocol_name, feature_name in MAIN_PROTOCOL_FEATURES:
+        features = feature_definitions.get(protocol_name, {}).get("soma.v", [])
+        if not any(feature["feature"] == feature_name for feature in features):
+            raise ValueError(
+                f"The {feature_name} feature of {protocol_name} is needed "
+                "by the Main protocol."
+            )
+
+    if main_protocol_definitions is None:
+        main_protocol_definitions = DEFAULT_MAIN_PROTOCOL_DEFINITIONS
+    main_protocol_definitions = copy.deepcopy(main_protocol_definitions)
+    main_protocol_definitions["Main"] = {
+        "type": "RatSSCxMainProtocol",
+        "other_protocols": list(protocol_definitions),
+        "pre_protocols": [],
+    }
+    main_protocol_definitions.update(protocol_definitions)
+
+    return main_protocol_definitions, feature_definitions
+
+
+def convert_recipe(
+    recipes_path,
+    emodel,
+    output_dir,
+    emodel_dir=".",
+    main_protocol_definitions=None,
+    skip_unsupported=False,
+):
+    """Write the EModelRunner protocols and features files of a BluePyEModel e-model.
+
+    The features file of the recipe can be a fitness calculator configuration,
+    containing both the protocols and the efeatures, which is converted.
+    Else, the protocols and features files are in the legacy format that
+    EModelRunner reads, and they are copied.
+
+    Args:
+        recipes_path (str or Path): path to the BluePyEModel recipes file
+        emodel (str): name of the e-model
+        output_dir (str or Path): directory in which to write the files
+        emodel_dir (str or Path): directory the paths of the recipe are relative to
+        main_protocol_definitions (dict): see convert_fitness_calculator_configuration
+        skip_unsupported (bool): see convert_fitness_calculator_configuration
+
+    Raises:
+        ValueError: if the recipe has no features file, or has no protocols file
+            and its features file is not a fitness calculator configuration
+
+    Returns:
+        tuple: paths to the written protocols and features files
+    """
+    recipe_paths = read_recipe(recipes_path, emodel, emodel_dir)
+    if recipe_paths["features"] is None:
+        raise ValueError(f"The recipe of {emodel} has no features file.")
+    with open(recipe_paths["features"], "r", encoding="utf-8") as features_file:
+        feature_definitions = json.load(features_file)
+
+    if is_fitness_calculator_configuration(feature_definitions):
+        protocol_definitions, feature_definitions = (
+            convert_fitness_calculator_configuration(
+                feature_definitions, main_protocol_definitions, skip_unsupported
+            )
+        )
+    elif recipe_paths["protocols"] is not None:
+        with open(recipe_paths["protocols"], "r", encoding="utf-8") as protocols_file:
+            protocol_definitions = json.load(protocols_file)
+    else:
+        raise ValueError(f"The recipe of {emodel} has no protocols file.")
+
+    output_dir = Path(output_dir)
+    protocols_path = output_dir / "protocols" / f"{emodel}.json"
+    features_path = output_dir / "features" / f"{emodel}.json"
+    for path, definitions in [
+        (protocols_path, protocol_definitions),
+        (features_path, feature_definitions),
+    ]:
+        path.parent.mkdir(parents=True, exist_ok=True)
+        with open(path, "w", encoding="utf-8") as json_file:
+            json.dump(definitions, json_file, indent=4)
+
+    return protocols_path, features_path
+
+
+def write_package_config(
+    output_dir,
+    emodel,
+    mtype,
+    paths,
+    package_type="sscx",
+    template_dir=None,
+    mechanisms_dir=None,
+):
+    """Complete a cell package with the files needed to run it, and write its config.
+
+    The files of template_dir missing in the package and the mod files of
+    mechanisms_dir are copied, an empty units file is written if there is none,
+    and the output and synapses directories are created.
+
+    Args:
+        output_dir (Path): directory of the cell package
+        emodel (str): name of the e-model
+        mtype (str): name of the morphology, used in the output file names
+        paths (dict): path to the morphology, unoptimized and optimized parameters,
+            protocols and features files, keyed by their key in the [Paths] section.
+            The paths outside of the package are made absolute.
+        package_type (str): package type of the config
+        template_dir (str or Path): cell package whose missing files are copied.
+            Not used if None.
+        mechanisms_dir (str or Path): directory of the mod files. Not used if None.
+
+    Returns:
+        Path: path to the config of the cell package
+    """
+    # pylint: disable=too-many-arguments
+    output_dir = Path(output_dir)
+    if template_dir is not None:
+        copy_missing_files(
+            Path(template_dir),
+            output_dir,
+            shutil.ignore_patterns(*TEMPLATE_IGNORE_PATTERNS),
+        )
+    if (
+        mechanisms_dir is not None
+        and Path(mechanisms_dir).is_dir()
+        and not (output_dir / "mechanisms").exists()
+    ):
+        shutil.copytree(mechanisms_dir, output_dir / "mechanisms")
+    units_path = output_dir / "config" / "features" / "units.json"
+    if not units_path.exists():
+        units_path.parent.mkdir(parents=True, exist_ok=True)
+        units_path.write_text("{}", encoding="utf-8")
+    for dir_name in ["python_recordings", "synapses"]:
+        (output_dir / dir_name).mkdir(exist_ok=True)
+
+    paths_section = {"memodel_dir": "."}
+    for key, path in paths.items():
+        path = Path(path).resolve()
+        try:
+            paths_section[key] = path.relative_to(output_dir.resolve()).as_posix()
+        except ValueError:
+            paths_section[key] = str(path)
+
+    config = configparser.ConfigParser(interpolation=None)
+    config.optionxform = str
+    config.read_dict(
+        {
+            "Package": {"type": package_type},
+            "Cell": {"emodel": emodel},
+            "Morphology": {"mtype": mtype},
+            "Paths": paths_section,
+        }
+    )
+
+    config_path = output_dir / "config" / f"config_{emodel}.ini"
+    with open(config_path, "w", encoding="utf-8") as config_file:
+        config.write(config_file)
+    logger.info("Cell package of %s written in %s", emodel, output_dir)
+    return config_path
+
+
+def write_recipe_package(
+    recipes_path,
+    emodel,
+    output_dir,
+    emodel_dir=".",
+    package_type="sscx",
+    template_dir=None,
+    main_protocol_definitions=None,
+    skip_unsupported=False,
+):
+    """Write a cell package running an e-model of BluePyEModel recipes, with its config.
+
+    The recipe gives the morphology, the unoptimized parameters (with the mechanisms),
+    the protocols and the features. The optimized parameters are read from the
+    'final' file of the recipe. The protocols and features are converted as in
+    convert_recipe, and the mod files of the mechanisms directory of emodel_dir
+    are copied, to be compiled by 'emodelrunner setup'. The other files needed
+    by the package type, e.g. the hoc templates of the sscx packages,
+    are copied from template_dir.
+
+    Args:
+        recipes_path (str or Path): path to the BluePyEModel recipes file
+        emodel (str): name of the e-model
+        output_dir (str or Path): directory of the cell package
+        emodel_dir (str or Path): directory the paths of the recipe are relative to
+        package_type (str): package type of the config, e.g. "sscx" or "hippocampus"
+        template_dir (str or Path): cell package whose missing files are copied.
+            Not used if None.
+        main_protocol_definitions (dict): see convert_fitness_calculator_configuration
+        skip_unsupported (bool): see convert_fitness_calculator_configuration
+
+    Raises:
+        ValueError: if the recipe has no morphology or no parameters file
+        FileNotFoundError: if a file of the recipe is missing
+
+    Returns:
+        Path: path to the config of the cell package, relative to which
+            the package has to be run
+    """
+    # pylint: disable=too-many-arguments, too-many-locals
+    recipe = load_recipe(recipes_path, emodel)
+    recipe_paths = read_recipe(recipes_path, emodel, emodel_dir)
+    mtype, _ = get_recipe_morphology(recipe, emodel_dir)
+    for key in ["morphology", "params"]:
+        if recipe_paths[key] is None:
+            raise ValueError(f"The recipe of {emodel} has no {key}.")
+    for key in ["morphology", "params", "final"]:
+        if not recipe_paths[key].is_file():
+            raise FileNotFoundError(f"{recipe_paths[key]} not found.")
+
+    output_dir = Path(output_dir)
+    protocols_path, features_path = convert_recipe(
+        recipes_path,
+        emodel,
+        output_dir / "config",
+        emodel_dir=emodel_dir,
+        main_protocol_definitions=main_protocol_definitions,
+        skip_unsupported=skip_unsupported,
+    )
+    return write_package_config(
+        output_dir,
+        emodel,
+        mtype or emodel,
+        {
+            "morph_path": recipe_paths["morphology"],
+            "unoptimized_params_path": recipe_paths["params"],
+            "params_path": recipe_paths["final"],
+            "prot_path": protocols_path,
+            "features_path": features_path,
+        },
+        package_type=package_type,
+        template_dir=template_dir,
+        mechanisms_dir=Path(emodel_dir) / "mechanisms",
+    )
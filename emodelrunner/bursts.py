@@ -0,0 +1,147 @@
+"""Burst features of the low-threshold burst protocols of the thalamus packages."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import logging
+
+import numpy as np
+
+from emodelrunner.results import detect_spikes
+
+logger = logging.getLogger(__name__)
+
+BURST_FEATURES = [
+    "burst_latency",
+    "burst_n_spikes",
+    "burst_duration",
+    "intraburst_frequency",
+]
+
+
+def get_burst_features(
+    time,
+    voltage,
+    hyperpolarization_start,
+    release_time,
+    window_end,
+    spike_threshold=-20.0,
+    max_isi=10.0,
+):
+    """Compute the features of the burst following the release of a pre-step.
+
+    The burst is made of the first spike after the release and of the spikes
+    following it with an interspike interval shorter than max_isi.
+
+    Args:
+        time (numpy.ndarray): time of the trace (ms)
+        voltage (numpy.ndarray): voltage of the trace (mV)
+        hyperpolarization_start (float): time at which the pre-step starts (ms)
+        release_time (float): time at which the hyperpolarizing pre-step ends (ms)
+        window_end (float): end of the window in which the burst is searched (ms)
+        spike_threshold (float): voltage threshold for spike detection (mV)
+        max_isi (float): maximum interspike interval within the burst (ms)
+
+    Returns:
+        dict containing the 'hyperpolarized_voltage' (mV), i.e. the mean voltage
+        during the last 10% of the pre-step, the number of spikes in the window
+        ('n_spikes'), the 'burst_latency' from the release (ms),
+        the 'burst_n_spikes', the 'burst_duration' (ms)
+        and the 'intraburst_frequency' (Hz).
+        The burst features are None if there is no spike in the window,
+        and the duration and frequency are None if the burst has a single spike.
+    """
+    time = np.asarray(time, dtype=float)
+    voltage = np.asarray(voltage, dtype=float)
+    # pylint: disable=too-many-arguments
+    pre_step_end = release_time - 0.1 * (release_time - hyperpolarization_start)
+    before = (time >= pre_step_end) & (time < release_time)
+    hyperpolarized_voltage = (
+        float(np.mean(voltage[before])) if np.any(before) else None
+    )
+
+    spike_times = detect_spikes(time, voltage, spike_threshold)
+    in_window = (spike_times >= release_time) & (spike_times < window_end)
+    spike_times = spike_times[in_window]
+    features = {
+        "hyperpolarized_voltage": hyperpolarized_voltage,
+        "n_spikes": len(spike_times),
+    }
+    features.update({name: None for name in BURST_FEATURES})
+    if len(spike_times) == 0:
+        return features
+
+    n_burst_spikes = 1
+    while (
+        n_burst_spikes < len(spike_times)
+        and spike_times[n_burst_spikes] - spike_times[n_burst_spikes - 1] <= max_isi
+    ):
+        n_burst_spikes += 1
+    burst_spikes = spike_times[:n_burst_spikes]
+
+    features["burst_latency"] = float(burst_spikes[0] - release_time)
+    features["burst_n_spikes"] = n_burst_spikes
+    if n_burst_spikes > 1:
+        features["burst_duration"] = float(burst_spikes[-1] - burst_spikes[0])
+        features["intraburst_frequency"] = float(
+            1000.0 * (n_burst_spikes - 1) / features["burst_duration"]
+        )
+    return features
+
+
+def compute_burst_features(responses, burst_protocols, spike_threshold=-20.0):
+    """Compute the burst features of each burst protocol.
+
+    Args:
+        responses (dict): responses of the protocols
+        burst_protocols (dict): key of the soma voltage, type of burst, start
+            and end of the pre-step and end of the burst window of each burst protocol,
+            see ProtocolBuilder.get_burst_protocols
+        spike_threshold (float): voltage threshold for spike detection (mV)
+
+    Returns:
+        dict: the 'type' of burst ('low_threshold' or 'rebound') and the burst
+            features of each burst protocol name, see get_burst_features
+    """
+    bursts = {}
+    for name, burst_protocol in burst_protocols.items():
+        response = responses.get(burst_protocol["voltage_key"])
+        if response is None:
+            logger.warning("%s: no voltage response, skipping its bursts.", name)
+            continue
+
+        bursts[name] = {"type": burst_protocol["type"]}
+        bursts[name].update(
+            get_burst_features(
+                response["time"],
+                response["voltage"],
+                burst_protocol["hyperpolarization_start"],
+                burst_protocol["release_time"],
+                burst_protocol["window_end"],
+                spike_threshold=spike_threshold,
+            )
+        )
+        if bursts[name]["burst_n_spikes"] is None:
+            logger.warning("%s: no %s burst.", name, burst_protocol["type"])
+        else:
+            logger.info(
+                "%s: %s burst of %d spikes, %.4g ms after the release",
+                name,
+                burst_protocol["type"],
+                bursts[name]["burst_n_spikes"],
+                bursts[name]["burst_latency"],
+            )
+
+    return bursts
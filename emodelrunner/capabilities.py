@@ -0,0 +1,936 @@
+"""Discovery of the package, protocol, stimulus and recording types supported by the runner.
+
+The descriptions are plain dicts that can be dumped to json,
+e.g. to build the forms of a GUI or of a config generator.
+"""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import copy
+
+from emodelrunner.configuration import PackageType, get_config_schema
+from emodelrunner.protocols.protocols_func import seclist_to_sec
+
+
+def parameter(value_type, description, required=True, choices=None):
+    """Return the description of a parameter.
+
+    Args:
+        value_type (str): type of the value, e.g. "float" or "str"
+        description (str): description of the parameter, with its unit if any
+        required (bool): whether the parameter has to be given
+        choices (list): allowed values. Any value of the type is allowed if None.
+
+    Returns:
+        dict: description of the parameter
+    """
+    parameter_description = {
+        "type": value_type,
+        "description": description,
+        "required": required,
+    }
+    if choices is not None:
+        parameter_description["choices"] = choices
+    return parameter_description
+
+
+STEP_TIMING_PARAMETERS = {
+    "delay": parameter("float", "start of the step (ms)"),
+    "duration": parameter("float", "duration of the step (ms)"),
+    "totduration": parameter("float", "total duration of the protocol (ms)"),
+}
+
+RAMP_TIMING_PARAMETERS = {
+    "ramp_delay": parameter("float", "start of the ramp (ms)"),
+    "ramp_duration": parameter("float", "duration of the ramp (ms)"),
+    "totduration": parameter("float", "total duration of the protocol (ms)"),
+}
+
+SINUSOID_TIMING_PARAMETERS = {
+    "delay": parameter("float", "start of the sinusoid (ms)"),
+    "duration": parameter("float", "duration of the sinusoid (ms)"),
+    "totduration": parameter("float", "total duration of the protocol (ms)"),
+}
+
+SYNAPSE_CONDITIONING_PARAMETERS = {
+    "holding_current": parameter(
+        "float",
+        "current injected in the soma during the whole protocol (nA)",
+        required=False,
+    ),
+    "conditioning": parameter(
+        "dict",
+        "pre-step at the soma from 0 ms, ending before syn_start: "
+        "{'type': 'current', 'amp': nA, 'duration': ms} or "
+        "{'type': 'voltage', 'voltage': mV, 'duration': ms}, "
+        "with 'exclude': true to remove it from the traces",
+        required=False,
+    ),
+}
+
+RECORDING_SAMPLING_PARAMETERS = {
+    "record_dt": parameter(
+        "float",
+        "interval between the recorded samples (ms). "
+        "The sampling of the protocol is used if neither record_dt "
+        "nor record_every is given, every 0.1 ms by default",
+        required=False,
+    ),
+    "record_every": parameter(
+        "int",
+        "number of integration steps between the recorded samples, "
+        "instead of record_dt",
+        required=False,
+    ),
+}
+
+STOCHKV_DET_PARAMETER = parameter(
+    "bool",
+    "whether the StochKv channels are deterministic. "
+    "Overridden by the config of the run if set there.",
+    required=False,
+)
+
+STIMULUS_TYPES = {
+    "step": {
+        "description": "square current pulse injected in the soma",
+        "parameters": {
+            "amp": parameter(
+                "float or str",
+                "amplitude of the step (nA). "
+                "Null in the protocols whose amplitude is set by the Main protocol. "
+                "Can be relative to the threshold current in sscx and hippocampus "
+                "packages, e.g. '150%thresh', see threshold_current_source "
+                "in [Protocol].",
+            ),
+            **STEP_TIMING_PARAMETERS,
+            "stochkv_det": STOCHKV_DET_PARAMETER,
+        },
+    },
+    "threshold_step": {
+        "description": "square current pulse injected in the soma, "
+        "with an amplitude relative to the threshold current of the cell",
+        "parameters": {
+            "thresh_perc": parameter(
+                "float", "amplitude of the step (% of the threshold current)"
+            ),
+            **STEP_TIMING_PARAMETERS,
+            "stochkv_det": STOCHKV_DET_PARAMETER,
+        },
+    },
+    "series_step": {
+        "description": "square current pulse injected in the soma, "
+        "repeated with each amplitude of a f-I curve or subthreshold protocol",
+        "parameters": {
+            **STEP_TIMING_PARAMETERS,
+            "stochkv_det": STOCHKV_DET_PARAMETER,
+        },
+    },
+    "search_step": {
+        "description": "square current pulse injected in the soma, "
+        "whose amplitude is searched",
+        "parameters": {**STEP_TIMING_PARAMETERS},
+    },
+    "holding": {
+        "description": "holding current injected in the soma",
+        "parameters": {
+            "amp": parameter(
+                "float",
+                "amplitude of the holding current (nA). "
+                "Null in the protocols whose amplitude is set by the Main protocol.",
+            ),
+            **STEP_TIMING_PARAMETERS,
+        },
+    },
+    "hyperpolarization": {
+        "description": "hyperpolarizing pre-step injected in the soma, "
+        "de-inactivating the T-type calcium channels before a low-threshold burst",
+        "parameters": {
+            "amp": parameter("float", "amplitude of the pre-step (nA)"),
+            **STEP_TIMING_PARAMETERS,
+        },
+    },
+    "burst_step": {
+        "description": "depolarizing square current pulse injected in the soma "
+        "at the end of the hyperpolarizing pre-step",
+        "parameters": {
+            "amp": parameter("float", "amplitude of the step (nA)"),
+            "duration": parameter("float", "duration of the step (ms)"),
+        },
+    },
+    "ramp": {
+        "description": "current ramp injected in the soma",
+        "parameters": {
+            "ramp_amplitude_start": parameter(
+                "float", "amplitude at the start of the ramp (nA)"
+            ),
+            "ramp_amplitude_end": parameter(
+                "float", "amplitude at the end of the ramp (nA)"
+            ),
+            **RAMP_TIMING_PARAMETERS,
+        },
+    },
+    "threshold_ramp": {
+        "description": "current ramp injected in the soma, "
+        "with amplitudes relative to the threshold current of the cell",
+        "parameters": {
+            "thresh_perc_start": parameter(
+                "float",
+                "amplitude at the start of the ramp (% of the threshold current)",
+            ),
+            "thresh_perc_end": parameter(
+                "float", "amplitude at the end of the ramp (% of the threshold current)"
+            ),
+            **RAMP_TIMING_PARAMETERS,
+        },
+    },
+    "vecstim": {
+        "description": "random spike train activating all the synapses, "
+        "or spikes of the presynaptic cells read from a file",
+        "parameters": {
+            "syn_start": parameter("float", "start of the spike train (ms)"),
+            "syn_stop": parameter("float", "end of the spike train (ms)"),
+            "syn_stim_seed": parameter(
+                "int", "seed of the random spike times", required=False
+            ),
+            "vecstim_random": parameter(
+                "str",
+                "random number generator of the spike times",
+                required=False,
+                choices=["python", "neuron"],
+            ),
+            "spike_file": parameter(
+                "str",
+                "spikes file of the presynaptic cells: SONATA spikes (.h5), "
+                "NWB units table (.nwb) or text file with a time (ms) and a gid "
+                "per line. Replaces the random spike times if given.",
+                required=False,
+            ),
+            "spike_population": parameter(
+                "str",
+                "node population of the SONATA spikes, if the file has several",
+                required=False,
+            ),
+            **SYNAPSE_CONDITIONING_PARAMETERS,
+        },
+    },
+    "spike_generators": {
+        "description": "inhomogeneous Poisson or burst spike trains drawn for each "
+        "synapse by the generator of its group",
+        "parameters": {
+            "syn_start": parameter(
+                "float", "start of the spike trains (ms)", required=False
+            ),
+            "syn_stop": parameter("float", "end of the spike trains (ms)"),
+            "syn_stim_seed": parameter(
+                "int",
+                "seed of the spike trains. A random seed is drawn if not given.",
+                required=False,
+            ),
+            "generators": parameter(
+                "dict",
+                "generator of each synapse group, keyed by pre_mtype id or 'default': "
+                "{'type': 'poisson', 'rate': Hz} or "
+                "{'type': 'poisson', 'rate_times': ms, 'rates': Hz} or "
+                "{'type': 'burst', 'burst_interval': ms, 'n_spikes': int, "
+                "'spike_interval': ms, 'noise': 0-1}",
+            ),
+            **SYNAPSE_CONDITIONING_PARAMETERS,
+        },
+    },
+    "netstim": {
+        "description": "regular or noisy spike train activating all the synapses",
+        "parameters": {
+            "syn_start": parameter("float", "start of the spike train (ms)"),
+            "syn_stop": parameter("float", "end of the spike train (ms)"),
+            "syn_nmb_of_spikes": parameter("int", "number of spikes"),
+            "syn_interval": parameter("float", "interval between the spikes (ms)"),
+            "syn_noise": parameter(
+                "float", "fraction of randomness of the interval, between 0 and 1"
+            ),
+            "syn_stim_seed": parameter(
+                "int",
+                "seed of the noisy intervals. The generator of NEURON is used "
+                "if not given.",
+                required=False,
+            ),
+            **SYNAPSE_CONDITIONING_PARAMETERS,
+        },
+    },
+    "sinusoid": {
+        "description": "sinusoidal current injected in the soma",
+        "parameters": {
+            "amp": parameter("float", "amplitude of the sinusoid (nA)"),
+            "frequency": parameter("float", "frequency of the sinusoid (Hz)"),
+            "offset": parameter(
+                "float",
+                "constant current added during the sinusoid (nA). Defaults to 0.",
+                required=False,
+            ),
+            **SINUSOID_TIMING_PARAMETERS,
+        },
+    },
+    "chirp": {
+        "description": "sinusoidal current injected in the soma, with a frequency "
+        "increasing (or decreasing) linearly from freq_start to freq_end (ZAP)",
+        "parameters": {
+            "amp": parameter("float", "amplitude of the chirp (nA)"),
+            "freq_start": parameter(
+                "float", "frequency at the start of the chirp (Hz)"
+            ),
+            "freq_end": parameter("float", "frequency at the end of the chirp (Hz)"),
+            "offset": parameter(
+                "float",
+                "constant current added during the chirp (nA). Defaults to 0.",
+                required=False,
+            ),
+            **SINUSOID_TIMING_PARAMETERS,
+        },
+    },
+    "noise": {
+        "description": "Ornstein-Uhlenbeck noise current injected in the soma, "
+        "mimicking in vivo-like synaptic bombardment",
+        "parameters": {
+            "mean": parameter("float", "mean of the current (nA)"),
+            "sigma": parameter("float", "standard deviation of the current (nA)"),
+            "tau": parameter("float", "time constant of the noise (ms)"),
+            "seed": parameter(
+                "int",
+                "seed of the noise. A random seed is drawn if not given. "
+                "The seeds used are stored in the provenance of the run.",
+                required=False,
+            ),
+            "delay": parameter("float", "start of the noise (ms)"),
+            "duration": parameter("float", "duration of the noise (ms)"),
+            "totduration": parameter("float", "total duration of the protocol (ms)"),
+        },
+    },
+    "waveform": {
+        "description": "arbitrary current waveform read from a file, "
+        "e.g. a recorded stimulus, resampled at the time step of the simulation",
+        "parameters": {
+            "path": parameter(
+                "str",
+                "npy, csv or text file with the time (ms) and the current (nA) "
+                "of each sample, or only the current",
+            ),
+            "delay": parameter("float", "start of the waveform (ms)"),
+            "totduration": parameter("float", "total duration of the protocol (ms)"),
+            "dt": parameter(
+                "float",
+                "time step of the samples (ms), when the file has only the current",
+                required=False,
+            ),
+            "scale": parameter(
+                "float",
+                "factor applied to the current, e.g. 0.001 for a current in pA",
+                required=False,
+            ),
+        },
+    },
+    "conductance": {
+        "description": "conductances injected in the soma with their reversal "
+        "potentials (dynamic clamp), e.g. to emulate synaptic bombardment",
+        "parameters": {
+            "delay": parameter("float", "start of the conductances (ms)"),
+            "duration": parameter("float", "duration of the conductances (ms)"),
+            "totduration": parameter("float", "total duration of the protocol (ms)"),
+            "<name>": parameter(
+                "dict",
+                "each conductance, e.g. 'exc' and 'inh', with its 'reversal' "
+                "potential (mV) and its values (uS), given as a 'values' list sampled "
+                "at 'dt' (ms), a 'path' to a text file of values, or the 'mean', "
+                "'sigma', 'tau' and optional 'seed' of an Ornstein-Uhlenbeck process",
+                required=False,
+            ),
+        },
+    },
+    "vclamp": {
+        "description": "somatic voltage clamp with a command voltage made of "
+        "successive steps, recording the clamp current",
+        "parameters": {
+            "steps": parameter(
+                "list",
+                "steps of the command voltage, each with its 'voltage' (mV) and its "
+                "'duration' (ms). The voltage of one step can be a list, or a dict "
+                "with the 'start', 'stop' (included) and 'step' of evenly spaced "
+                "voltages, to run one sweep per voltage.",
+            ),
+            "series_resistance": parameter(
+                "float", "series resistance of the clamp (MOhm)", required=False
+            ),
+        },
+    },
+    "epsp": {
+        "description": "EPSP-like current, rising and decaying exponentially, "
+        "injected in the dendrites",
+        "parameters": {
+            "amp": parameter("float", "peak amplitude of the current (nA)"),
+            "tau_rise": parameter("float", "rise time constant (ms)"),
+            "tau_decay": parameter(
+                "float", "decay time constant (ms), larger than tau_rise"
+            ),
+            "delay": parameter("float", "start of the current (ms)"),
+            "duration": parameter(
+                "float", "duration of the current, after which it is cut (ms)"
+            ),
+            "totduration": parameter("float", "total duration of the protocol (ms)"),
+        },
+    },
+    "pulse": {
+        "description": "train of current pulses injected in the soma, "
+        "defined in the stimuli file of synplas packages "
+        "or built from their [Pairing] config section",
+        "parameters": {
+            "Pattern": parameter("str", "shape of the stimulus", choices=["Pulse"]),
+            "Delay": parameter("float", "start of the pulse train (ms)"),
+            "Duration": parameter("float", "duration of the pulse train (ms)"),
+            "AmpStart": parameter("float", "amplitude of the pulses (nA)"),
+            "Frequency": parameter("float", "frequency of the pulses (Hz)"),
+            "Width": parameter("float", "duration of each pulse (ms)"),
+        },
+    },
+}
+
+
+def stimulus_entry(key, stimulus_type, required=True, multiple=False):
+    """Return the description of an entry of the stimuli of a protocol.
+
+    Args:
+        key (str): key of the entry in the stimuli of the protocol definition.
+            None if the parameters are directly in the stimuli.
+        stimulus_type (str): key of the stimulus type in STIMULUS_TYPES
+        required (bool): whether the entry has to be given
+        multiple (bool): whether the entry can be a list of stimuli
+
+    Returns:
+        dict: description of the entry
+    """
+    return {
+        "key": key,
+        "stimulus_type": stimulus_type,
+        "required": required,
+        "multiple": multiple,
+    }
+
+
+DISTANCES_PARAMETERS = {
+    "distances": parameter("list", "distances from the soma (um)"),
+    "seclist_name": parameter(
+        "str",
+        "section list of the distances, e.g. 'basal'. "
+        "Defaults to 'apical', on the path to the apical point if it is known.",
+        required=False,
+    ),
+}
+
+
+PROTOCOL_TYPES = {
+    "StepProtocol": {
+        "description": "step current injection, with an optional holding current. "
+        "Thalamus packages only use the first step.",
+        "packages": ["sscx", "thalamus", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [
+            stimulus_entry("step", "step", multiple=True),
+            stimulus_entry("holding", "holding", required=False),
+        ],
+        "parameters": {},
+    },
+    "StepThresholdProtocol": {
+        "description": "step current injection relative to the threshold current, "
+        "on top of the holding current, both computed by the Main protocol "
+        "or by a CurrentSearchProtocol in sscx and hippocampus packages",
+        "packages": ["sscx", "thalamus", "hippocampus"],
+        "requires_main": True,
+        "stimuli": [stimulus_entry("step", "threshold_step", multiple=True)],
+        "parameters": {},
+    },
+    "BurstProtocol": {
+        "description": "low-threshold burst protocol: hyperpolarizing pre-step "
+        "followed by an optional depolarizing step. Without step, the rebound burst "
+        "after the release of the pre-step is analysed. A null holding amplitude "
+        "uses the holding current of the cell computed by the Main protocol.",
+        "packages": ["thalamus"],
+        "requires_main": False,
+        "stimuli": [
+            stimulus_entry("hyperpolarization", "hyperpolarization"),
+            stimulus_entry("step", "burst_step", required=False),
+            stimulus_entry("holding", "holding", required=False),
+        ],
+        "parameters": {
+            "burst_window": parameter(
+                "float",
+                "duration after the release of the pre-step in which the burst "
+                "is analysed (ms). Defaults to 100.",
+                required=False,
+            ),
+        },
+    },
+    "RampProtocol": {
+        "description": "ramp current injection, with an optional holding current",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [
+            stimulus_entry("ramp", "ramp"),
+            stimulus_entry("holding", "holding", required=False),
+        ],
+        "parameters": {},
+    },
+    "RampThresholdProtocol": {
+        "description": "ramp current injection relative to the threshold current, "
+        "on top of the holding current, both computed by the Main protocol "
+        "or by a CurrentSearchProtocol",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": True,
+        "stimuli": [stimulus_entry("ramp", "threshold_ramp")],
+        "parameters": {},
+    },
+    "SinusoidProtocol": {
+        "description": "sinusoidal current injection, with an optional holding "
+        "current, e.g. to measure the impedance of the cell at a given frequency",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [
+            stimulus_entry("sinusoid", "sinusoid"),
+            stimulus_entry("holding", "holding", required=False),
+        ],
+        "parameters": {},
+    },
+    "ChirpProtocol": {
+        "description": "chirp (ZAP) current injection, with an optional holding "
+        "current, e.g. to measure the impedance profile of the cell",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [
+            stimulus_entry("chirp", "chirp"),
+            stimulus_entry("holding", "holding", required=False),
+        ],
+        "parameters": {},
+    },
+    "NoiseProtocol": {
+        "description": "Ornstein-Uhlenbeck noise current injection, with an optional "
+        "holding current, to probe the cell in in vivo-like conditions",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [
+            stimulus_entry("noise", "noise"),
+            stimulus_entry("holding", "holding", required=False),
+        ],
+        "parameters": {},
+    },
+    "WaveformProtocol": {
+        "description": "injection of a current waveform read from a file, "
+        "with an optional holding current",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [
+            stimulus_entry("waveform", "waveform"),
+            stimulus_entry("holding", "holding", required=False),
+        ],
+        "parameters": {},
+    },
+    "ConductanceClampProtocol": {
+        "description": "injection of conductances with their reversal potentials, "
+        "from arrays or Ornstein-Uhlenbeck processes, with an optional holding current",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [
+            stimulus_entry("conductance", "conductance"),
+            stimulus_entry("holding", "holding", required=False),
+        ],
+        "parameters": {},
+    },
+    "FICurveProtocol": {
+        "description": "family of step current injections of increasing amplitude, "
+        "one step protocol named <name>_<index> per amplitude, whose firing rates "
+        "give the f-I curve of the cell, fitted and plotted after the run",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [
+            stimulus_entry("step", "series_step"),
+            stimulus_entry("holding", "holding", required=False),
+        ],
+        "parameters": {
+            "amplitudes": parameter(
+                "list or dict",
+                "amplitudes of the steps (nA), as a list or as a dict with the "
+                "'start', 'stop' (included) and 'step' of evenly spaced amplitudes. "
+                "The amplitudes of the list can be relative to the threshold current, "
+                "e.g. '150%thresh'.",
+            ),
+            "spike_threshold": parameter(
+                "float", "voltage threshold for spike detection (mV)", required=False
+            ),
+        },
+    },
+    "VoltageClampProtocol": {
+        "description": "voltage clamp sweeps, one sweep protocol named "
+        "<name>_<index> per voltage of the swept step, whose peak clamp currents "
+        "give the activation or inactivation curve of the channels",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [stimulus_entry("vclamp", "vclamp")],
+        "parameters": {
+            "analysis": parameter(
+                "dict",
+                "the 'type' of curve, 'activation' or 'inactivation', the optional "
+                "index of the 'step' in which the peak current is measured, the "
+                "'reversal' potential (mV) needed by the activation curves, and the "
+                "'transient' (ms, 0.5 by default) skipped at the start of the step",
+                required=False,
+            ),
+        },
+    },
+    "SubthresholdProtocol": {
+        "description": "series of small step current injections, usually "
+        "hyperpolarizing, one step protocol named <name>_<index> per amplitude, "
+        "giving the input resistance, membrane time constant and sag ratio "
+        "of the cell, stored in the run summary and in the factsheets",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [
+            stimulus_entry("step", "series_step"),
+            stimulus_entry("holding", "holding", required=False),
+        ],
+        "parameters": {
+            "amplitudes": parameter(
+                "list or dict",
+                "amplitudes of the steps (nA), as a list or as a dict with the "
+                "'start', 'stop' (included) and 'step' of evenly spaced amplitudes. "
+                "The amplitudes of the list can be relative to the threshold current, "
+                "e.g. '-20%thresh'.",
+            ),
+            "spike_threshold": parameter(
+                "float",
+                "voltage threshold (mV) of the spike detection, "
+                "discarding the steps eliciting spikes",
+                required=False,
+            ),
+        },
+    },
+    "BAPProtocol": {
+        "description": "short somatic step current eliciting an action potential, "
+        "recorded in the dendrites at the given distances from the soma to measure "
+        "the attenuation of the backpropagating action potential, stored in the "
+        "run summary. The step amplitude must be in nA.",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [
+            stimulus_entry("step", "step"),
+            stimulus_entry("holding", "holding", required=False),
+        ],
+        "parameters": DISTANCES_PARAMETERS,
+    },
+    "EPSPAttenuationProtocol": {
+        "description": "EPSP-like current injected in the dendrites at each of the "
+        "given distances from the soma, one protocol named <name>_<index> per "
+        "distance, recording at the soma and at the injection site to measure "
+        "the attenuation of the EPSP, stored in the run summary",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [stimulus_entry("epsp", "epsp")],
+        "parameters": DISTANCES_PARAMETERS,
+    },
+    "CurrentSearchProtocol": {
+        "description": "bisection search of the holding current reaching a target "
+        "voltage and of the rheobase, used by the threshold-based protocols "
+        "instead of the Main protocol",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [stimulus_entry("step", "search_step")],
+        "parameters": {
+            "holding_voltage": parameter(
+                "float",
+                "voltage to hold the cell at (mV). No holding current if not given",
+                required=False,
+            ),
+            "holding_precision": parameter(
+                "float", "precision of the holding voltage (mV)", required=False
+            ),
+            "threshold_precision": parameter(
+                "float", "precision of the rheobase (nA)", required=False
+            ),
+            "max_depth": parameter(
+                "int", "maximum number of bisection steps", required=False
+            ),
+            "max_holding_current": parameter(
+                "float", "maximum absolute holding current (nA)", required=False
+            ),
+            "max_threshold_current": parameter(
+                "float", "maximum rheobase (nA)", required=False
+            ),
+            "spike_threshold": parameter(
+                "float", "voltage threshold for spike detection (mV)", required=False
+            ),
+        },
+    },
+    "Vecstim": {
+        "description": "synapses activated by a random spike train",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [stimulus_entry(None, "vecstim")],
+        "parameters": {},
+    },
+    "Netstim": {
+        "description": "synapses activated by a regular or noisy spike train",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [stimulus_entry(None, "netstim")],
+        "parameters": {},
+    },
+    "SpikeGenerators": {
+        "description": "synapses activated by inhomogeneous Poisson or burst spike "
+        "trains, configured per synapse group",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [stimulus_entry(None, "spike_generators")],
+        "parameters": {},
+    },
+    "ChainProtocol": {
+        "description": "protocols run back-to-back on the same instantiated cell, "
+        "each phase starting from the state of the cell at the end of the previous "
+        "one, e.g. the plasticity of the synapses. Each phase is named "
+        "<name>_<phase> in the outputs.",
+        "packages": ["sscx", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [],
+        "parameters": {
+            "phases": parameter(
+                "dict",
+                "definition of each phase, keyed by phase name in the order of "
+                "the run. Each phase is a single sweep, e.g. a step or a synapse "
+                "protocol.",
+            ),
+        },
+    },
+    "RatSSCxThresholdDetectionProtocol": {
+        "description": "search of the threshold current of the cell, run by the "
+        "Main protocol. Named ThresholdDetection in sscx and hippocampus packages, "
+        "and ThresholdDetection_dep and ThresholdDetection_hyp in thalamus packages.",
+        "packages": ["sscx", "thalamus", "hippocampus"],
+        "requires_main": True,
+        "stimuli": [],
+        "parameters": {
+            "step_template": parameter(
+                "dict",
+                "StepProtocol definition of the step used to search the threshold",
+            ),
+        },
+    },
+    "RatSSCxRinHoldcurrentProtocol": {
+        "description": "search of the holding current and input resistance "
+        "of the cell, run by the Main protocol. Named RinHoldcurrent in sscx "
+        "and hippocampus packages, and RinHoldcurrent_dep and RinHoldcurrent_hyp "
+        "in thalamus packages.",
+        "packages": ["sscx", "thalamus", "hippocampus"],
+        "requires_main": True,
+        "stimuli": [],
+        "parameters": {
+            "holdi_precision": parameter(
+                "float", "precision of the holding current search"
+            ),
+            "holdi_max_depth": parameter(
+                "int", "maximum depth of the holding current bisection search"
+            ),
+            "holdi_estimate_multiplier": parameter(
+                "float",
+                "factor of the holding current estimate giving the lower bound "
+                "of the search. Used in thalamus packages only.",
+                required=False,
+            ),
+        },
+    },
+    "RatSSCxMainProtocol": {
+        "description": "protocol named Main, computing the resting membrane "
+        "potential, the holding and threshold currents, "
+        "then running the other protocols",
+        "packages": ["sscx", "thalamus", "hippocampus"],
+        "requires_main": False,
+        "stimuli": [],
+        "parameters": {
+            "other_protocols": parameter(
+                "list of str", "names of the protocols run after the threshold search"
+            ),
+            "pre_protocols": parameter(
+                "list of str",
+                "names of the protocols run before the threshold search",
+                required=False,
+            ),
+        },
+    },
+}
+
+RECORDING_TYPES = {
+    "somadistance": {
+        "description": "recording at a distance from the soma in a section list",
+        "packages": ["sscx", "hippocampus"],
+        "parameters": {
+            "name": parameter("str", "name of the location, used in the output names"),
+            "var": parameter(
+                "str", "recorded range variable, e.g. v, cai, NaTg.m or currents"
+            ),
+            "somadistance": parameter("float", "distance from the soma (um)"),
+            "seclist_name": parameter("str", "name of the section list, e.g. basal"),
+            **RECORDING_SAMPLING_PARAMETERS,
+        },
+    },
+    "somadistanceapic": {
+        "description": "recording at a distance from the soma, "
+        "on the path to the apical point of the morphology",
+        "packages": ["sscx", "hippocampus"],
+        "parameters": {
+            "name": parameter("str", "name of the location, used in the output names"),
+            "var": parameter(
+                "str", "recorded range variable, e.g. v, cai, NaTg.m or currents"
+            ),
+            "somadistance": parameter("float", "distance from the soma (um)"),
+            "seclist_name": parameter(
+                "str", "name of the section list", choices=list(seclist_to_sec)
+            ),
+            **RECORDING_SAMPLING_PARAMETERS,
+        },
+    },
+    "nrnseclistcomp": {
+        "description": "recording at a position in a section of a section list",
+        "packages": ["sscx", "hippocampus"],
+        "parameters": {
+            "name": parameter("str", "name of the location, used in the output names"),
+            "var": parameter(
+                "str", "recorded range variable, e.g. v, cai, NaTg.m or currents"
+            ),
+            "seclist_name": parameter("str", "name of the section list, e.g. somatic"),
+            "sec_index": parameter("int", "index of the section in the section list"),
+            "comp_x": parameter("float", "position in the section, between 0 and 1"),
+            **RECORDING_SAMPLING_PARAMETERS,
+        },
+    },
+    "section": {
+        "description": "recording at a position in a section of a section array, "
+        "e.g. dend[3](0.5)",
+        "packages": ["sscx", "hippocampus"],
+        "parameters": {
+            "name": parameter(
+                "str",
+                "name of the location, used in the output names. "
+                "Encodes the section and the position if not given, e.g. dend3_x0p5",
+                required=False,
+            ),
+            "var": parameter(
+                "str", "recorded range variable, e.g. v, cai, NaTg.m or currents"
+            ),
+            "sec_name": parameter("str", "name of the section array, e.g. dend"),
+            "sec_index": parameter("int", "index of the section in the section array"),
+            "comp_x": parameter("float", "position in the section, between 0 and 1"),
+            **RECORDING_SAMPLING_PARAMETERS,
+        },
+    },
+}
+
+
+def get_package_types():
+    """Return the supported package types.
+
+    Returns:
+        list of str: package types, e.g. "sscx"
+    """
+    return [package_type.value for package_type in PackageType]
+
+
+def filter_by_package(types, package_type):
+    """Return a copy of the types that are supported by a package type.
+
+    Args:
+        types (dict): descriptions of the types, with the supported packages
+        package_type (str): package type. All the types are returned if None.
+
+    Raises:
+        ValueError: if the package type is not supported
+
+    Returns:
+        dict: descriptions of the types supported by the package type
+    """
+    if package_type is not None:
+        package_type = PackageType(package_type).value
+    return {
+        name: copy.deepcopy(description)
+        for name, description in types.items()
+        if package_type is None or package_type in description["packages"]
+    }
+
+
+def get_protocol_types(package_type=None):
+    """Return the protocol types that can be set as "type" in the protocols file.
+
+    Args:
+        package_type (str): package type. All the protocol types are returned if None.
+
+    Returns:
+        dict: description, supported packages, stimuli and parameters
+            of each protocol type. The stimuli refer to get_stimulus_types.
+    """
+    return filter_by_package(PROTOCOL_TYPES, package_type)
+
+
+def get_stimulus_types():
+    """Return the stimulus types used by the protocol types.
+
+    Returns:
+        dict: description and parameters of each stimulus type
+    """
+    return copy.deepcopy(STIMULUS_TYPES)
+
+
+def get_recording_types(package_type=None):
+    """Return the recording types that can be set in the extra_recordings of a protocol.
+
+    The voltage at the soma is always recorded.
+
+    Args:
+        package_type (str): package type. All the recording types are returned if None.
+
+    Returns:
+        dict: description, supported packages and parameters of each recording type
+    """
+    return filter_by_package(RECORDING_TYPES, package_type)
+
+
+def get_config_schemas():
+    """Return the description of the config sections of each package type.
+
+    Returns:
+        dict: for each package type, the expected values, whether they are required
+            and the default values of the keys of each config section
+    """
+    return {
+        package_type: get_config_schema(package_type)
+        for package_type in get_package_types()
+    }
+
+
+def get_capabilities():
+    """Return all the package, protocol, stimulus and recording types.
+
+    Returns:
+        dict: package types, descriptions of the protocol, stimulus
+            and recording types, and the config schema of each package type
+    """
+    return {
+        "package_types": get_package_types(),
+        "protocol_types": get_protocol_types(),
+        "stimulus_types": get_stimulus_types(),
+        "recording_types": get_recording_types(),
+        "config_schemas": get_config_schemas(),
+    }
@@ -285,9 +285,13 @@ class CellModelCustom(ephys.models.CellModel):
         Args:
             sim (bluepyopt.ephys.NrnSimulator): neuron simulator
         """
-        # pylint: disable=unnecessary-comprehension
         super().instantiate(sim)
 
+        self.fix_hyperpolarization()
+
+    def fix_hyperpolarization(self):
+        """Uninsert SK_E2 from the somatic and axonal sections if fixhp is True."""
+        # pylint: disable=unnecessary-comprehension
         # Hyperpolarization workaround
         somatic = [x for x in self.icell.somatic]
         axonal = [x for x in self.icell.axonal]
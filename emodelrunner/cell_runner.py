@@ -0,0 +1,568 @@
+"""Python API running protocols on a cell package without config file."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import logging
+from pathlib import Path
+
+import numpy as np
+from bluepyopt import ephys
+
+from emodelrunner.configuration import PackageType
+from emodelrunner.create_cells import create_cell
+from emodelrunner.load import load_emodel_params
+from emodelrunner.morphology import create_morphology
+from emodelrunner.morphology.formats import get_neuron_morphology_path
+from emodelrunner.protocols.reader import ProtocolParser
+from emodelrunner.units import responses_with_units, to_magnitude
+
+logger = logging.getLogger(__name__)
+
+MORPHOLOGY_SUFFIXES = [".asc", ".swc", ".h5"]
+# duration simulated after the end of the stimulus, when no total duration is given
+DEFAULT_POST_STIM_DURATION = 200.0
+
+
+class ProtocolSpec:
+    """Protocol defined in python, converted to a definition of the protocols file.
+
+    The amplitudes, times and frequencies can be given as numbers,
+    in nA, ms and Hz, or as pint quantities.
+
+    Attributes:
+        name (str): name of the protocol, used in the names of the recordings
+        delay (float): start of the stimulus (ms)
+        duration (float): duration of the stimulus (ms)
+        total_duration (float): total duration of the protocol (ms)
+        holding (float): holding current injected during the whole protocol (nA)
+    """
+
+    protocol_type = None
+    stimulus_key = None
+
+    def __init__(self, name, delay, duration, total_duration=None, holding=0.0):
+        """Constructor.
+
+        Args:
+            name (str): name of the protocol, used in the names of the recordings
+            delay (float): start of the stimulus (ms)
+            duration (float): duration of the stimulus (ms)
+            total_duration (float): total duration of the protocol (ms).
+                Defaults to 200 ms after the end of the stimulus.
+            holding (float): holding current injected during the whole protocol (nA)
+        """
+        self.name = name
+        self.delay = to_magnitude(delay, "ms")
+        self.duration = to_magnitude(duration, "ms")
+        if total_duration is None:
+            total_duration = self.delay + self.duration + DEFAULT_POST_STIM_DURATION
+        self.total_duration = to_magnitude(total_duration, "ms")
+        self.holding = to_magnitude(holding, "nA")
+
+    def stimulus_definition(self):
+        """Return the definition of the stimulus, without its timing.
+
+        Returns:
+            dict: parameters of the stimulus in the protocols file
+        """
+        raise NotImplementedError
+
+    def definition(self):
+        """Return the definition of the protocol, as in the protocols file.
+
+        Returns:
+            dict: definition of the protocol
+        """
+        stimuli = {
+            self.stimulus_key: {
+                "delay": self.delay,
+                "duration": self.duration,
+                "totduration": self.total_duration,
+                **self.stimulus_definition(),
+            }
+        }
+        if self.holding:
+            stimuli["holding"] = {
+                "amp": self.holding,
+                "delay": 0.0,
+                "duration": self.total_duration,
+                "totduration": self.total_duration,
+            }
+        return {"type": self.protocol_type, "stimuli": stimuli}
+
+
+class StepProtocol(ProtocolSpec):
+    """Step current injection.
+
+    Attributes:
+        amp (float): amplitude of the step (nA)
+    """
+
+    protocol_type = "StepProtocol"
+    stimulus_key = "step"
+
+    def __init__(
+        self, amp, delay, duration, total_duration=None, holding=0.0, name="Step"
+    ):
+        """Constructor.
+
+        Args:
+            amp (float): amplitude of the step (nA)
+            delay (float): start of the step (ms)
+            duration (float): duration of the step (ms)
+            total_duration (float): total duration of the protocol (ms)
+            holding (float): holding current (nA)
+            name (str): name of the protocol
+        """
+        # pylint: disable=too-many-arguments
+        super().__init__(name, delay, duration, total_duration, holding)
+        self.amp = to_magnitude(amp, "nA")
+
+    def stimulus_definition(self):
+        """Return the amplitude of the step.
+
+        Returns:
+            dict: parameters of the step in the protocols file
+        """
+        return {"amp": self.amp}
+
+
+class RampProtocol(ProtocolSpec):
+    """Ramp current injection.
+
+    Attributes:
+        amp_start (float): amplitude at the start of the ramp (nA)
+        amp_end (float): amplitude at the end of the ramp (nA)
+    """
+
+    protocol_type = "RampProtocol"
+    stimulus_key = "ramp"
+
+    def __init__(
+        self,
+        amp_start,
+        amp_end,
+        delay,
+        duration,
+        total_duration=None,
+        holding=0.0,
+        name="Ramp",
+    ):
+        """Constructor.
+
+        Args:
+            amp_start (float): amplitude at the start of the ramp (nA)
+            amp_end (float): amplitude at the end of the ramp (nA)
+            delay (float): start of the ramp (ms)
+            duration (float): duration of the ramp (ms)
+            total_duration (float): total duration of the protocol (ms)
+            holding (float): holding current (nA)
+            name (str): name of the protocol
+        """
+        # pylint: disable=too-many-arguments
+        super().__init__(name, delay, duration, total_duration, holding)
+        self.amp_start = to_magnitude(amp_start, "nA")
+        self.amp_end = to_magnitude(amp_end, "nA")
+
+    def definition(self):
+        """Return the definition of the ramp protocol, as in the protocols file.
+
+        Returns:
+            dict: definition of the protocol
+        """
+        definition = super().definition()
+        # the ramp timing has its own keys
+        ramp = definition["stimuli"]["ramp"]
+        ramp["ramp_delay"] = ramp.pop("delay")
+        ramp["ramp_duration"] = ramp.pop("duration")
+        return definition
+
+    def stimulus_definition(self):
+        """Return the amplitudes of the ramp.
+
+        Returns:
+            dict: parameters of the ramp in the protocols file
+        """
+        return {
+            "ramp_amplitude_start": self.amp_start,
+            "ramp_amplitude_end": self.amp_end,
+        }
+
+
+class SinusoidProtocol(ProtocolSpec):
+    """Sinusoidal current injection.
+
+    Attributes:
+        amp (float): amplitude of the sinusoid (nA)
+        frequency (float): frequency of the sinusoid (Hz)
+        offset (float): constant current added during the sinusoid (nA)
+    """
+
+    protocol_type = "SinusoidProtocol"
+    stimulus_key = "sinusoid"
+
+    def __init__(
+        self,
+        amp,
+        frequency,
+        delay,
+        duration,
+        offset=0.0,
+        total_duration=None,
+        holding=0.0,
+        name="Sinusoid",
+    ):
+        """Constructor.
+
+        Args:
+            amp (float): amplitude of the sinusoid (nA)
+            frequency (float): frequency of the sinusoid (Hz)
+            delay (float): start of the sinusoid (ms)
+            duration (float): duration of the sinusoid (ms)
+            offset (float): constant current added during the sinusoid (nA)
+            total_duration (float): total duration of the protocol (ms)
+            holding (float): holding current (nA)
+            name (str): name of the protocol
+        """
+        # pylint: disable=too-many-arguments
+        super().__init__(name, delay, duration, total_duration, holding)
+        self.amp = to_magnitude(amp, "nA")
+        self.frequency = to_magnitude(frequency, "Hz")
+        self.offset = to_magnitude(offset, "nA")
+
+    def stimulus_definition(self):
+        """Return the parameters of the sinusoid.
+
+        Returns:
+            dict: parameters of the sinusoid in the protocols file
+        """
+        return {"amp": self.amp, "frequency": self.frequency, "offset": self.offset}
+
+
+class ChirpProtocol(ProtocolSpec):
+    """Chirp (ZAP) current injection.
+
+    Attributes:
+        amp (float): amplitude of the chirp (nA)
+        freq_start (float): frequency at the start of the chirp (Hz)
+        freq_end (float): frequency at the end of the chirp (Hz)
+        offset (float): constant current added during the chirp (nA)
+    """
+
+    protocol_type = "ChirpProtocol"
+    stimulus_key = "chirp"
+
+    def __init__(
+        self,
+        amp,
+        freq_start,
+        freq_end,
+        delay,
+        duration,
+        offset=0.0,
+        total_duration=None,
+        holding=0.0,
+        name="Chirp",
+    ):
+        """Constructor.
+
+        Args:
+            amp (float): amplitude of the chirp (nA)
+            freq_start (float): frequency at the start of the chirp (Hz)
+            freq_end (float): frequency at the end of the chirp (Hz)
+            delay (float): start of the chirp (ms)
+            duration (float): duration of the chirp (ms)
+            offset (float): constant current added during the chirp (nA)
+            total_duration (float): total duration of the protocol (ms)
+            holding (float): holding current (nA)
+            name (str): name of the protocol
+        """
+        # pylint: disable=too-many-arguments
+        super().__init__(name, delay, duration, total_duration, holding)
+        self.amp = to_magnitude(amp, "nA")
+        self.freq_start = to_magnitude(freq_start, "Hz")
+        self.freq_end = to_magnitude(freq_end, "Hz")
+        self.offset = to_magnitude(offset, "nA")
+
+    def stimulus_definition(self):
+        """Return the parameters of the chirp.
+
+        Returns:
+            dict: parameters of the chirp in the protocols file
+        """
+        return {
+            "amp": self.amp,
+            "freq_start": self.freq_start,
+            "freq_end": self.freq_end,
+            "offset": self.offset,
+        }
+
+
+class NoiseProtocol(ProtocolSpec):
+    """Ornstein-Uhlenbeck noise current injection.
+
+    Attributes:
+        mean (float): mean of the current (nA)
+        sigma (float): standard deviation of the current (nA)
+        tau (float): time constant of the noise (ms)
+        seed (int): seed of the noise. A random seed is drawn at each run if None.
+    """
+
+    protocol_type = "NoiseProtocol"
+    stimulus_key = "noise"
+
+    def __init__(
+        self,
+        mean,
+        sigma,
+        tau,
+        delay,
+        duration,
+        seed=None,
+        total_duration=None,
+        holding=0.0,
+        name="Noise",
+    ):
+        """Constructor.
+
+        Args:
+            mean (float): mean of the current (nA)
+            sigma (float): standard deviation of the current (nA)
+            tau (float): time constant of the noise (ms)
+            delay (float): start of the noise (ms)
+            duration (float): duration of the noise (ms)
+            seed (int): seed of the noise
+            total_duration (float): total duration of the protocol (ms)
+            holding (float): holding current (nA)
+            name (str): name of the protocol
+        """
+        # pylint: disable=too-many-arguments
+        super().__init__(name, delay, duration, total_duration, holding)
+        self.mean = to_magnitude(mean, "nA")
+        self.sigma = to_magnitude(sigma, "nA")
+        self.tau = to_magnitude(tau, "ms")
+        self.seed = seed
+
+    def stimulus_definition(self):
+        """Return the parameters of the noise.
+
+        Returns:
+            dict: parameters of the noise in the protocols file
+        """
+        definition = {"mean": self.mean, "sigma": self.sigma, "tau": self.tau}
+        if self.seed is not None:
+            definition["seed"] = self.seed
+        return definition
+
+
+def find_single_file(directory, suffixes, exclude=()):
+    """Return the only file of a directory with one of the given suffixes.
+
+    Args:
+        directory (Path): directory in which to look for the file
+        suffixes (list of str): allowed suffixes, e.g. ['.json']
+        exclude (list of str): names of the files to ignore
+
+    Raises:
+        ValueError: if there is no such file, or more than one
+
+    Returns:
+        Path: path to the file
+    """
+    paths = sorted(
+        path
+        for path in Path(directory).glob("*")
+        if path.suffix.lower() in suffixes and path.name not in exclude
+    )
+    if len(paths) != 1:
+        raise ValueError(
+            f"Expected exactly one {'/'.join(suffixes)} file in {directory}, "
+            f"found {len(paths)}. Please give its path."
+        )
+    return paths[0]
+
+
+class CellRunner:
+    """Runs protocols defined in python on the cell of a sscx cell package.
+
+    The cell is built once from the files of the package, without config file,
+    and each run returns the recordings as numpy arrays.
+    The mechanisms of the package have to be compiled in the package directory,
+    e.g. with 'emodelrunner setup'.
+
+    Attributes:
+        cell_dir (Path): directory of the cell package
+        emodel (str): name of the e-model
+        mtype (str): prefix of the recording names
+        cell (CellModelCustom): cell model
+        release_params (dict): optimized parameters of the e-model
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        dt (float): time step of the simulations and of the currents (ms)
+    """
+
+    def __init__(
+        self,
+        cell_dir,
+        emodel=None,
+        morph_path=None,
+        unoptimized_params_path=None,
+        params_path="config/params/final.json",
+        gid=0,
+        mtype="_",
+        celsius=34,
+        v_init=-80,
+        do_replace_axon=True,
+        instantiation="hoc",
+        dt=0.025,
+        cvode_active=False,
+    ):
+        """Constructor.
+
+        The paths are relative to the cell package directory.
+
+        Args:
+            cell_dir (str or Path): directory of the cell package
+            emodel (str): name of the e-model. Defaults to the only e-model
+                of the optimized parameters file.
+            morph_path (str): path to the morphology. Defaults to the only
+                morphology in the morphology directory. The h5 morphologies
+                are converted to asc in the converted_morphology directory.
+            unoptimized_params_path (str): path to the unoptimized parameters,
+                defining the mechanisms. Defaults to the only other json file
+                in the directory of the optimized parameters.
+            params_path (str): path to the optimized parameters
+            gid (int): id of the cell
+            mtype (str): prefix of the recording names
+            celsius (float): temperature (celsius)
+            v_init (float): initial voltage (mV)
+            do_replace_axon (bool): whether to replace the axon by a stub axon
+            instantiation (str): "hoc" or "python", see create_cells.create_cell
+            dt (float): time step (ms)
+            cvode_active (bool): whether to use the variable time step
+
+        Raises:
+            ValueError: if a path cannot be found, or if the e-model cannot be
+                chosen from the optimized parameters file
+        """
+        # pylint: disable=too-many-arguments, too-many-locals
+        self.cell_dir = Path(cell_dir)
+        self.mtype = mtype
+        self.dt = dt
+
+        params_path = self.cell_dir / params_path
+        if emodel is None:
+            with open(params_path, "r", encoding="utf-8") as params_file:
+                emodels = list(json.load(params_file))
+            if len(emodels) != 1:
+                raise ValueError(
+                    f"{params_path} contains {len(emodels)} e-models. "
+                    "Please give the e-model name."
+                )
+            emodel = emodels[0]
+        self.emodel = emodel
+
+        if morph_path is None:
+            morph_path = find_single_file(
+                self.cell_dir / "morphology", MORPHOLOGY_SUFFIXES
+            )
+        else:
+            morph_path = self.cell_dir / morph_path
+        morph_path = get_neuron_morphology_path(
+            morph_path, self.cell_dir / "converted_morphology"
+        )
+        if unoptimized_params_path is None:
+            unoptimized_params_path = find_single_file(
+                params_path.parent, [".json"], exclude=[params_path.name]
+            )
+        else:
+            unoptimized_params_path = self.cell_dir / unoptimized_params_path
+
+        morph = create_morphology(
+            {"morph_path": str(morph_path), "do_replace_axon": do_replace_axon},
+            PackageType.sscx,
+        )
+        self.cell = create_cell(
+            str(unoptimized_params_path),
+            emodel,
+            False,
+            morph,
+            gid,
+            v_init=v_init,
+            celsius=celsius,
+            instantiation=instantiation,
+        )
+        self.release_params = load_emodel_params(emodel, str(params_path))
+
+        self.sim = ephys.simulators.NrnSimulator(dt=dt, cvode_active=cvode_active)
+        self.sim.mechanisms_directory = str(self.cell_dir)
+
+    def create_protocol(self, protocol, extra_recordings=None):
+        """Create the bluepyopt protocol of a python protocol.
+
+        Args:
+            protocol (ProtocolSpec): protocol, e.g. StepProtocol
+            extra_recordings (list of dict): extra recordings,
+                with the structure of the extra recordings of the protocols file
+
+        Returns:
+            bluepyopt.ephys.protocols.SweepProtocol: the protocol
+        """
+        definition = protocol.definition()
+        if extra_recordings:
+            definition["extra_recordings"] = extra_recordings
+        return ProtocolParser().parse_sscx_protocol_definitions(
+            {protocol.name: definition}, prefix=self.mtype
+        )[protocol.name]
+
+    def run(self, protocol, extra_recordings=None, units=False):
+        """Run a protocol on the cell.
+
+        Args:
+            protocol (ProtocolSpec): protocol, e.g.
+                StepProtocol(amp=0.2, delay=100, duration=500)
+            extra_recordings (list of dict): extra recordings,
+                with the structure of the extra recordings of the protocols file
+            units (bool): whether to return the recordings as pint quantities
+
+        Returns:
+            dict: recordings keyed by name, e.g. '_.Step.soma.v', with the time (ms)
+                and the recorded values in the 'voltage' array, and the injected
+                current keyed by e.g. 'current__.Step', with the time (ms)
+                and the current (nA) arrays
+        """
+        ephys_protocol = self.create_protocol(protocol, extra_recordings)
+
+        logger.info("Running the %s protocol on %s", protocol.name, self.emodel)
+        responses = ephys_protocol.run(
+            cell_model=self.cell,
+            param_values=self.release_params,
+            sim=self.sim,
+            isolate=False,
+        )
+
+        # responses are None when their location could not be instantiated
+        recordings = {
+            name: None
+            if response is None
+            else {
+                "time": np.asarray(response["time"]),
+                "voltage": np.asarray(response["voltage"]),
+            }
+            for name, response in responses.items()
+        }
+        recordings.update(ephys_protocol.generate_current(dt=self.dt))
+
+        return responses_with_units(recordings) if units else recordings
@@ -0,0 +1,186 @@
+"""Save and restore the state of long simulations, to resume interrupted runs."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import logging
+import os
+import shutil
+from pathlib import Path
+
+import numpy as np
+
+logger = logging.getLogger(__name__)
+
+CHECKPOINT_INFO_FILE = "checkpoint.json"
+CHECKPOINT_STATE_FILE = "state.dat"
+CHECKPOINT_RECORDINGS_FILE = "recordings.npz"
+
+
+def get_checkpoint_times(stop, interval):
+    """Return the times at which to save a checkpoint.
+
+    Args:
+        stop (float): end of the simulation (ms)
+        interval (float): interval between the checkpoints (ms)
+
+    Returns:
+        list of float: checkpoint times before the end of the simulation (ms)
+    """
+    if not interval or interval <= 0:
+        return []
+    return [float(time) for time in np.arange(interval, stop, interval)]
+
+
+def get_recording_vectors(recordings):
+    """Return the neuron Vectors of the recordings, keyed by a unique name.
+
+    The vectors are those of bluepyopt CompRecording (tvector and varvector)
+    and of SynapseRecordingCustom (tvector and varvectors).
+
+    Args:
+        recordings (list): recordings of the protocol
+
+    Returns:
+        dict: neuron Vector of each recorded variable, keyed by
+            'recording index.attribute name' (and '.synapse index' for the synapses)
+    """
+    vectors = {}
+    for i, recording in enumerate(recordings):
+        for attribute in ["tvector", "varvector"]:
+            vector = getattr(recording, attribute, None)
+            if vector is not None:
+                vectors[f"{i}.{attribute}"] = vector
+        for j, vector in enumerate(getattr(recording, "varvectors", None) or []):
+            vectors[f"{i}.varvectors.{j}"] = vector
+    return vectors
+
+
+def save_checkpoint(sim, recordings, checkpoint_dir, info):
+    """Save the state of the simulation and the recorded values.
+
+    The files are first written in a temporary directory, that replaces
+    the previous checkpoint once complete, so that an interruption
+    while saving does not corrupt the last checkpoint.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        recordings (list): recordings of the protocol
+        checkpoint_dir (str or Path): directory of the checkpoint
+        info (dict): information needed to resume the run, e.g. whether the synapses
+            have been fast-forwarded. The time of the simulation is added to it.
+    """
+    h = sim.neuron.h
+    checkpoint_dir = Path(checkpoint_dir)
+    tmp_dir = checkpoint_dir.with_name(checkpoint_dir.name + ".tmp")
+    if tmp_dir.exists():
+        shutil.rmtree(tmp_dir)
+    tmp_dir.mkdir(parents=True)
+
+    state = h.SaveState()
+    state.save()
+    state_file = h.File()
+    state_file.wopen(str(tmp_dir / CHECKPOINT_STATE_FILE))
+    state.fwrite(state_file)
+    state_file.close()
+
+    np.savez(
+        tmp_dir / CHECKPOINT_RECORDINGS_FILE,
+        **{
+            key: np.array(vector)
+            for key, vector in get_recording_vectors(recordings).items()
+        },
+    )
+
+    with open(tmp_dir / CHECKPOINT_INFO_FILE, "w", encoding="utf-8") as info_file:
+        json.dump(dict(info, time=float(h.t)), info_file, indent=4)
+
+    if checkpoint_dir.exists():
+        shutil.rmtree(checkpoint_dir)
+    os.replace(tmp_dir, checkpoint_dir)
+    logger.info("Checkpoint saved at %s ms in %s", h.t, checkpoint_dir)
+
+
+def load_checkpoint_info(checkpoint_dir):
+    """Load the information of the last checkpoint.
+
+    Args:
+        checkpoint_dir (str or Path): directory of the checkpoint
+
+    Returns:
+        dict: information of the checkpoint, or None if there is no checkpoint
+    """
+    info_path = Path(checkpoint_dir) / CHECKPOINT_INFO_FILE
+    if not info_path.is_file():
+        return None
+    with open(info_path, "r", encoding="utf-8") as info_file:
+        return json.load(info_file)
+
+
+def restore_checkpoint(sim, recordings, checkpoint_dir):
+    """Restore the state of the simulation and the recorded values.
+
+    The simulation should be initialized, with the same model, stimuli
+    and recordings as when the checkpoint was saved. The recording continues
+    after the restored values.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        recordings (list): recordings of the protocol
+        checkpoint_dir (str or Path): directory of the checkpoint
+
+    Raises:
+        ValueError: if the recordings differ from the ones of the checkpoint
+
+    Returns:
+        dict: information of the checkpoint
+    """
+    h = sim.neuron.h
+    checkpoint_dir = Path(checkpoint_dir)
+
+    state = h.SaveState()
+    state_file = h.File()
+    state_file.ropen(str(checkpoint_dir / CHECKPOINT_STATE_FILE))
+    state.fread(state_file)
+    state_file.close()
+    # also restore the events in the queue, e.g. the pre-synaptic spikes
+    state.restore(1)
+    if h.cvode_active():
+        h.CVode().re_init()
+
+    vectors = get_recording_vectors(recordings)
+    with np.load(checkpoint_dir / CHECKPOINT_RECORDINGS_FILE) as recorded:
+        if set(recorded.files) != set(vectors):
+            raise ValueError(
+                f"The recordings of the checkpoint in {checkpoint_dir} "
+                "differ from the ones of the protocol."
+            )
+        for key, vector in vectors.items():
+            vector.from_python(recorded[key])
+
+    info = load_checkpoint_info(checkpoint_dir)
+    logger.info("Resuming from the checkpoint at %s ms in %s", h.t, checkpoint_dir)
+    return info
+
+
+def remove_checkpoint(checkpoint_dir):
+    """Remove the checkpoint of a completed run.
+
+    Args:
+        checkpoint_dir (str or Path): directory of the checkpoint
+    """
+    if Path(checkpoint_dir).exists():
+        shutil.rmtree(checkpoint_dir)
+        logger.debug("Checkpoint %s removed", checkpoint_dir)
@@ -0,0 +1,605 @@
+"""Command line interface, with one subcommand per task."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import configparser
+import json
+import logging
+import sys
+from pathlib import Path
+
+from schema import SchemaError
+
+from emodelrunner.arbor_backend import run_arbor
+from emodelrunner.batch import get_mpi_comm, run_batch
+from emodelrunner.bluepyemodel_adapter import (
+    get_access_point,
+    run_access_point,
+    write_access_point_package,
+)
+from emodelrunner.bluepyemodel_recipes import convert_recipe, write_recipe_package
+from emodelrunner.capabilities import get_capabilities
+from emodelrunner.config_check import validate_config
+from emodelrunner.create_hoc import export_hoc_package
+from emodelrunner.configuration import PackageType
+from emodelrunner.environment import setup_environment
+from emodelrunner.factsheets.output import (
+    write_emodel_json,
+    write_metype_json_from_config,
+)
+from emodelrunner.load import get_morph_path, load_config
+from emodelrunner.logging_utilities import neuron_output_to_logger
+from emodelrunner.network import run_network
+from emodelrunner.pair import run as run_pair
+from emodelrunner.parsing_utilities import get_cli_parser, set_verbosity
+from emodelrunner.plotting import plot_output_dir
+from emodelrunner.protocols.reader import ProtocolParser
+from emodelrunner.registry import fetch_emodel
+from emodelrunner.regression import run_regression
+from emodelrunner.run import main as run_emodel
+from emodelrunner.run_pairsim import run as run_pairsim
+from emodelrunner.run_synplas import run as run_synplas
+from emodelrunner.sensitivity import load_sensitivity_definition, run_sensitivity
+from emodelrunner.sweep import load_sweep_definition, run_sweep
+from emodelrunner.trials import run_trials
+
+logger = logging.getLogger(__name__)
+
+# errors reported without traceback, with exit code 1
+USER_ERRORS = (
+    FileNotFoundError,
+    FileExistsError,
+    ValueError,
+    RuntimeError,
+    SchemaError,
+    configparser.Error,
+)
+
+
+def run_command(args):
+    """Run the protocols of a sscx, thalamus or hippocampus package, or synplas simulation.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    config = load_config(
+        config_path=args.config_path, config_overrides=args.config_overrides
+    )
+    with neuron_output_to_logger():
+        if config.package_type == PackageType.synplas:
+            run_synplas(
+                config_path=args.config_path, config_overrides=args.config_overrides
+            )
+        else:
+            run_emodel(
+                config_path=args.config_path, config_overrides=args.config_overrides
+            )
+
+
+def run_pairsim_command(args):
+    """Run the pair simulation of a synplas or sscx package.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+
+    Raises:
+        ValueError: if the config is not a synplas or sscx config
+    """
+    config = load_config(
+        config_path=args.config_path, config_overrides=args.config_overrides
+    )
+    if config.package_type == PackageType.synplas:
+        run_func = run_pairsim
+    elif config.package_type == PackageType.sscx:
+        run_func = run_pair
+    else:
+        raise ValueError("run-pairsim needs a synplas or sscx config.")
+    with neuron_output_to_logger():
+        run_func(config_path=args.config_path, config_overrides=args.config_overrides)
+
+
+def run_arbor_command(args):
+    """Run the step protocols of a config with Arbor.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    config = load_config(
+        config_path=args.config_path, config_overrides=args.config_overrides
+    )
+    with neuron_output_to_logger():
+        report = run_arbor(
+            config,
+            output_dir=args.output_dir,
+            max_extent=args.max_extent,
+            reference_dir=args.reference_dir,
+        )
+    for protocol_name, run_time in report["run_times"].items():
+        print(f"{protocol_name}: {run_time:.2f} s")
+    if "comparison" in report and not report["comparison"]["passed"]:
+        logger.warning(
+            "The Arbor traces differ from the ones of %s.", args.reference_dir
+        )
+
+
+def validate_config_command(args):
+    """Check that a config file is valid.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    load_config(
+        config_path=args.config_path, config_overrides=args.config_overrides
+    )
+    print(f"{args.config_path} is valid.")
+
+
+def validate_command(args):
+    """Check a config file and the consistency of the files it refers to.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    with neuron_output_to_logger():
+        validate_config(
+            config_path=args.config_path,
+            config_overrides=args.config_overrides,
+            instantiate=args.instantiate,
+            mechanisms_dir=args.mechanisms_dir,
+        )
+    if args.instantiate:
+        print(f"{args.config_path} is valid and its cell can be instantiated.")
+    else:
+        print(f"{args.config_path} is valid.")
+
+
+def list_protocols_command(args):
+    """Print the name and type of the protocols of the protocols file of a config.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    config = load_config(
+        config_path=args.config_path, config_overrides=args.config_overrides
+    )
+    protocol_definitions = ProtocolParser.load_protocol_json(
+        config.get("Paths", "prot_path")
+    )
+    for protocol_name, protocol_definition in protocol_definitions.items():
+        if isinstance(protocol_definition, dict):
+            print(f"{protocol_name}\t{protocol_definition.get('type', '')}")
+
+
+def factsheet_command(args):
+    """Write the me-type and e-model factsheets of a sscx, thalamus or hippocampus package.
+
+    The protocol used for the physiology features has to be run beforehand.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+
+    Raises:
+        ValueError: if the config is a synplas config
+        FileNotFoundError: if the voltage of the protocol has not been written by a run
+    """
+    config = load_config(
+        config_path=args.config_path, config_overrides=args.config_overrides
+    )
+    if config.package_type == PackageType.synplas:
+        raise ValueError(
+            "The factsheets can only be written for sscx, thalamus "
+            "and hippocampus packages."
+        )
+
+    mtype = config.get("Morphology", "mtype")
+    voltage_path = (
+        Path(config.get("Paths", "output_dir"))
+        / f"{mtype}.{args.protocol_key}.soma.v.dat"
+    )
+    if not voltage_path.is_file():
+        raise FileNotFoundError(
+            f"{voltage_path} not found. Run a config with the "
+            f"{args.protocol_key} protocol first."
+        )
+
+    output_dir = Path(args.output_dir)
+    write_metype_json_from_config(
+        config,
+        voltage_path,
+        get_morph_path(config),
+        output_dir / "me_type_factsheet.json",
+        args.protocol_key,
+    )
+
+    json_dicts = []
+    for path_key in [
+        "features_path",
+        "units_path",
+        "unoptimized_params_path",
+        "params_path",
+    ]:
+        with open(config.get("Paths", path_key), "r", encoding="utf-8") as json_file:
+            json_dicts.append(json.load(json_file))
+    # z-scores of the model features, if written by the run
+    target_comparison = None
+    comparison_path = Path(config.get("Paths", "output_dir")) / "target_comparison.json"
+    if comparison_path.is_file():
+        with open(comparison_path, "r", encoding="utf-8") as comparison_file:
+            target_comparison = json.load(comparison_file)
+    write_emodel_json(
+        config.get("Cell", "emodel"),
+        mtype,
+        *json_dicts,
+        output_dir / "e_model_factsheet.json",
+        target_comparison,
+    )
+
+
+def export_hoc_command(args):
+    """Write a self-contained hoc package of a sscx config.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    config = load_config(
+        config_path=args.config_path, config_overrides=args.config_overrides
+    )
+    with neuron_output_to_logger():
+        output_dir = export_hoc_package(config, args.output_dir)
+    print(output_dir)
+
+
+def setup_command(args):
+    """Check NEURON and compile the mechanisms of a cell package through the cache.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    link_path = setup_environment(
+        args.package_dir, args.mechanisms_dir, args.cache_dir, args.coreneuron
+    )
+    print(f"The mechanisms are compiled and linked in {link_path}.")
+
+
+def fetch_command(args):
+    """Download a cell package from a registry.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    config_paths = fetch_emodel(
+        args.model_id,
+        args.registry_url,
+        args.output_dir,
+        registry_type=args.registry_type,
+        template_dir=args.template_dir,
+        template_config=args.template_config,
+    )
+    for config_path in config_paths:
+        print(config_path)
+
+
+def convert_recipe_command(args):
+    """Write the protocols and features files of a BluePyEModel e-model.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    protocols_path, features_path = convert_recipe(
+        args.recipes_path,
+        args.emodel,
+        args.output_dir,
+        emodel_dir=args.emodel_dir,
+        skip_unsupported=args.skip_unsupported,
+    )
+    print(f"prot_path = {protocols_path}")
+    print(f"features_path = {features_path}")
+
+
+def package_recipe_command(args):
+    """Write a cell package running a BluePyEModel e-model, and print its config path.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    config_path = write_recipe_package(
+        args.recipes_path,
+        args.emodel,
+        args.output_dir,
+        emodel_dir=args.emodel_dir,
+        package_type=args.package_type,
+        template_dir=args.template_dir,
+        skip_unsupported=args.skip_unsupported,
+    )
+    print(config_path)
+
+
+def package_access_point_command(args):
+    """Write, and optionally run, the cell package of a BluePyEModel access point.
+
+    The path to the config of the cell package is printed.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    access_point = get_access_point(
+        args.access_point,
+        args.emodel,
+        etype=args.etype,
+        ttype=args.ttype,
+        iteration_tag=args.iteration_tag,
+        emodel_dir=args.emodel_dir,
+        recipes_path=args.recipes_path,
+        project=args.project,
+        organisation=args.organisation,
+        endpoint=args.endpoint,
+    )
+    package_kwargs = {
+        "package_type": args.package_type,
+        "template_dir": args.template_dir,
+        "skip_unsupported": args.skip_unsupported,
+    }
+    if args.run:
+        config_path = run_access_point(
+            access_point, args.output_dir, cache_dir=args.cache_dir, **package_kwargs
+        )
+    else:
+        config_path = write_access_point_package(
+            access_point, args.output_dir, **package_kwargs
+        )
+    print(config_path)
+
+
+def regression_command(args):
+    """Compare the responses of a config with reference outputs.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+
+    Raises:
+        RuntimeError: if a response differs from its reference
+    """
+    tolerances = {
+        "voltage_rms": args.voltage_rms,
+        "spike_time_shift": args.spike_time_shift,
+        "scalar_rtol": args.scalar_rtol,
+    }
+    with neuron_output_to_logger():
+        report = run_regression(
+            args.config_path,
+            args.reference_dir,
+            tolerances=tolerances,
+            report_path=args.report_path,
+            config_overrides=args.config_overrides,
+        )
+
+    failed = [
+        name
+        for name, comparison in report["responses"].items()
+        if not comparison["passed"]
+    ]
+    if failed:
+        raise RuntimeError(
+            f"{len(failed)} of {len(report['responses'])} responses differ "
+            f"from the reference: {', '.join(failed)}"
+        )
+    print(f"The {len(report['responses'])} responses match the reference.")
+
+
+def sweep_command(args):
+    """Run a sscx or thalamus config for each combination of the swept values.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    sweep_definition = load_sweep_definition(args.sweep_path)
+    table = run_sweep(
+        args.config_path,
+        sweep_definition["parameters"],
+        args.output_dir,
+        mode=sweep_definition["mode"],
+        n_processes=args.n_processes,
+        config_overrides=args.config_overrides,
+    )
+
+    n_done = int((table["status"] == "done").sum())
+    print(
+        f"{n_done} of {len(table)} sweep runs done. "
+        f"Summary written in {Path(args.output_dir) / 'sweep.csv'}."
+    )
+
+
+def trials_command(args):
+    """Run trials of a stochastic config in parallel, and average their traces.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    average = run_trials(
+        args.config_path,
+        args.n_trials,
+        args.output_dir,
+        seed=args.seed,
+        n_processes=args.n_processes,
+        config_overrides=args.config_overrides,
+        dt=args.dt,
+        plot=not args.no_plot,
+    )
+    n_traces = sum("time" in response for response in average.values())
+    print(
+        f"{n_traces} traces averaged over the trials. "
+        f"Averages written in {Path(args.output_dir) / 'average'}."
+    )
+
+
+def sensitivity_command(args):
+    """Rank the parameters of a sscx or thalamus config by the e-feature sensitivity.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    _, ranking = run_sensitivity(
+        args.config_path,
+        load_sensitivity_definition(args.sensitivity_path),
+        args.output_dir,
+        n_processes=args.n_processes,
+        config_overrides=args.config_overrides,
+    )
+
+    print(ranking.to_string(index=False))
+    print(f"Sensitivities written in {Path(args.output_dir) / 'sensitivity.csv'}.")
+
+
+def network_command(args):
+    """Simulate a few connected cells of a SONATA circuit.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    with neuron_output_to_logger():
+        _, spikes = run_network(
+            args.config_path,
+            args.circuit_config,
+            args.node_population,
+            args.output_dir,
+            node_set=args.node_set,
+            node_ids=args.node_ids,
+            spikes_path=args.spikes_path,
+            spike_population=args.spike_population,
+            tstop=args.tstop,
+            config_overrides=args.config_overrides,
+        )
+
+    n_spikes = sum(len(spike_times) for spike_times in spikes.values())
+    print(
+        f"{len(spikes)} cells simulated, with {n_spikes} spikes. "
+        f"Outputs written in {args.output_dir}."
+    )
+
+
+def batch_command(args):
+    """Run each protocols file on each cell package of a batch.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+
+    Raises:
+        RuntimeError: if a job of the batch did not succeed
+    """
+    comm = get_mpi_comm() if args.mpi else None
+    report = run_batch(args.batch_path, args.output_dir, comm, args.timeout)
+    if report is None:
+        # only the rank 0 reports
+        return
+
+    report_path = Path(args.output_dir) / "batch_report.json"
+    if report["failures"]:
+        raise RuntimeError(
+            f"{len(report['failures'])} of {report['n_jobs']} batch jobs "
+            f"did not succeed: {', '.join(job['name'] for job in report['failures'])}. "
+            f"See {report_path}."
+        )
+    print(
+        f"The {report['n_jobs']} batch jobs succeeded. "
+        f"Report written in {report_path}."
+    )
+
+
+def plot_command(args):
+    """Plot the standard figures of the outputs of a finished run.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    paths = plot_output_dir(
+        args.output_dir, file_format=args.file_format, step_prefix=args.step_prefix
+    )
+    for path in paths:
+        print(path)
+
+
+def capabilities_command(args):
+    """Print the supported types and the config schemas as json.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    # pylint: disable=unused-argument
+    print(json.dumps(get_capabilities(), indent=4))
+
+
+def gui_command(args):
+    """Open the GUI.
+
+    Args:
+        args (argparse.Namespace): parsed arguments
+    """
+    # tkinter is only needed by this subcommand
+    # pylint: disable=import-outside-toplevel
+    from emodelrunner.GUI import main as gui_main
+
+    gui_main(args)
+
+
+COMMANDS = {
+    "run": run_command,
+    "run-pairsim": run_pairsim_command,
+    "run-arbor": run_arbor_command,
+    "validate-config": validate_config_command,
+    "validate": validate_command,
+    "list-protocols": list_protocols_command,
+    "factsheet": factsheet_command,
+    "export-hoc": export_hoc_command,
+    "setup": setup_command,
+    "fetch": fetch_command,
+    "convert-recipe": convert_recipe_command,
+    "package-recipe": package_recipe_command,
+    "package-access-point": package_access_point_command,
+    "regression": regression_command,
+    "sweep": sweep_command,
+    "trials": trials_command,
+    "sensitivity": sensitivity_command,
+    "network": network_command,
+    "batch": batch_command,
+    "plot": plot_command,
+    "capabilities": capabilities_command,
+    "gui": gui_command,
+}
+
+
+def main(argv=None):
+    """Entry point of the emodelrunner command.
+
+    Args:
+        argv (list of str): arguments. The command line arguments are used if None.
+
+    Returns:
+        int: exit code. 0 on success, 1 on invalid input.
+            argparse exits with code 2 on invalid arguments.
+    """
+    args = get_cli_parser().parse_args(argv)
+    set_verbosity(args.verbosity, args.log_level, args.log_file)
+
+    try:
+        COMMANDS[args.command](args)
+    except USER_ERRORS as exc:
+        logger.error("%s", exc, exc_info=args.verbosity >= 2)
+        return 1
+
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
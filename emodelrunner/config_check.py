@@ -0,0 +1,266 @@
+"""Consistency checks of the files referenced by a config, and dry-run instantiation."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import json
+import logging
+import os
+
+from bluepyopt import ephys
+
+from emodelrunner.capabilities import get_protocol_types
+from emodelrunner.configuration import InvalidConfigError, PackageType
+from emodelrunner.create_cells import create_cell_using_config
+from emodelrunner.environment import get_mechanism_names, get_mod_files
+from emodelrunner.load import get_morph_path, get_release_params, load_config
+from emodelrunner.protocols.reader import ProtocolParser
+
+logger = logging.getLogger(__name__)
+
+# mechanisms available in NEURON without compiling any mod file
+BUILTIN_MECHANISMS = {"pas", "hh", "extracellular", "fastpas", "capacitance"}
+
+
+def check_emodel_params(config):
+    """Check that the optimized parameters file contains the e-models of the config.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        list of str: description of each problem
+    """
+    params_path = config.get("Paths", "params_path")
+    try:
+        with open(params_path, "r", encoding="utf-8") as params_file:
+            params = json.load(params_file)
+    except (OSError, ValueError) as exc:
+        return [f"Paths.params_path: cannot read {params_path}: {exc}"]
+
+    errors = []
+    for key in ["emodel", "precell_emodel"]:
+        emodel = config.get("Cell", key, fallback="")
+        if emodel and "params" not in params.get(emodel, {}):
+            errors.append(
+                f"Cell.{key}: no parameters for {emodel!r} in {params_path}. "
+                f"Available e-models: {', '.join(sorted(params))}"
+            )
+    return errors
+
+
+def check_mechanisms(config, mechanisms_dir="mechanisms"):
+    """Check that the mechanisms of the cells are defined in the mod files.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+        mechanisms_dir (str or Path): directory containing the mod files
+
+    Returns:
+        list of str: description of each problem
+    """
+    try:
+        defined_names = set(get_mechanism_names(get_mod_files(mechanisms_dir)))
+    except FileNotFoundError as exc:
+        return [f"{exc} Use --mechanisms_dir to give the directory of the mod files."]
+
+    errors = []
+    for key in ["unoptimized_params_path", "precell_unoptimized_params_path"]:
+        params_path = config.get("Paths", key, fallback="")
+        if not params_path:
+            continue
+        try:
+            with open(params_path, "r", encoding="utf-8") as params_file:
+                mech_definitions = json.load(params_file)["mechanisms"]
+        except (OSError, ValueError, KeyError) as exc:
+            errors.append(
+                f"Paths.{key}: cannot read the mechanisms of {params_path}: {exc}"
+            )
+            continue
+
+        for sectionlist, channels in mech_definitions.items():
+            for channel in channels["mech"]:
+                if channel not in defined_names | BUILTIN_MECHANISMS:
+                    errors.append(
+                        f"Paths.{key}: mechanism {channel!r} of {sectionlist} "
+                        f"is not defined in the mod files of {mechanisms_dir}"
+                    )
+    return errors
+
+
+def check_morphology(config):
+    """Check that the morphologies of the config can be loaded by NEURON.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        list of str: description of each problem
+    """
+    errors = []
+    precells = [False]
+    if config.get("Paths", "precell_morph_path", fallback=""):
+        precells.append(True)
+    for precell in precells:
+        key = "precell_morph_path" if precell else "morph_path"
+        try:
+            get_morph_path(config, precell=precell)
+        except (OSError, ValueError, KeyError) as exc:
+            errors.append(f"Paths.{key}: {exc}")
+    return errors
+
+
+def check_protocols(config):
+    """Check the types of the protocols and the protocols run by the Main protocol.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        list of str: description of each problem
+    """
+    prot_path = config.get("Paths", "prot_path", fallback="")
+    if not prot_path:
+        return []
+    try:
+        protocol_definitions = ProtocolParser.load_protocol_json(prot_path)
+    except (OSError, ValueError) as exc:
+        return [f"Paths.prot_path: cannot read {prot_path}: {exc}"]
+
+    protocol_types = get_protocol_types(config.package_type)
+    errors = []
+    for name, definition in protocol_definitions.items():
+        protocol_type = definition.get("type")
+        if protocol_type is None:
+            continue
+        if protocol_type not in protocol_types:
+            errors.append(
+                f"protocol {name!r} of {prot_path}: type {protocol_type!r} is not "
+                f"supported by {config.package_type} packages. "
+                "See 'emodelrunner capabilities' for the supported types."
+            )
+        elif protocol_types[protocol_type]["requires_main"] and (
+            "Main" not in protocol_definitions
+        ):
+            errors.append(
+                f"protocol {name!r} of {prot_path}: type {protocol_type!r} "
+                "needs a protocol named Main computing the threshold current"
+            )
+
+    main_definition = protocol_definitions.get("Main", {})
+    for key in ["pre_protocols", "other_protocols"]:
+        for name in main_definition.get(key, []):
+            if name not in protocol_definitions:
+                errors.append(
+                    f"protocol 'Main' of {prot_path}: {key} refers to "
+                    f"{name!r}, that is not defined"
+                )
+    return errors
+
+
+def check_synapses(config):
+    """Check that the synapse files exist when the synapses are added.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        list of str: description of each problem
+    """
+    add_synapses = config.package_type == PackageType.synplas or config.getboolean(
+        "Synapses", "add_synapses", fallback=False
+    )
+    if not add_synapses:
+        return []
+
+    syn_dir = config.get("Paths", "syn_dir")
+    errors = []
+    for key in ["syn_data_file", "syn_conf_file"]:
+        path = os.path.join(syn_dir, config.get("Paths", key))
+        if not os.path.isfile(path):
+            errors.append(f"Paths.{key}: {path} does not exist")
+    return errors
+
+
+def instantiate_cell(config):
+    """Instantiate the cell of the config in NEURON without running any simulation.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Raises:
+        ValueError: if the package type is not sscx, thalamus or hippocampus
+    """
+    if config.package_type == PackageType.synplas:
+        raise ValueError(
+            "The instantiation is only checked for sscx, thalamus and hippocampus "
+            "packages, "
+            f"not {config.package_type}."
+        )
+    cell = create_cell_using_config(config)
+    release_params = get_release_params(config)
+    sim = ephys.simulators.NrnSimulator()
+    cell.freeze(release_params)
+    try:
+        cell.instantiate(sim=sim)
+        cell.destroy(sim=sim)
+    finally:
+        cell.unfreeze(release_params.keys())
+
+
+def validate_config(
+    config_path,
+    config_overrides=None,
+    instantiate=False,
+    mechanisms_dir="mechanisms",
+):
+    """Validate a config and the consistency of the files it refers to.
+
+    Args:
+        config_path (str or Path): path to the configuration file
+        config_overrides (list of str): values overriding the ones of the file,
+            given as 'section.key=value', e.g. 'Cell.celsius=36'
+        instantiate (bool): whether to also instantiate the cell in NEURON,
+            without running any simulation. The mechanisms have to be compiled.
+        mechanisms_dir (str or Path): directory containing the mod files
+
+    Raises:
+        InvalidConfigError: listing all the problems found
+
+    Returns:
+        configparser.ConfigParser: the validated configuration
+    """
+    config = load_config(config_path=config_path, config_overrides=config_overrides)
+
+    errors = (
+        check_emodel_params(config)
+        + check_mechanisms(config, mechanisms_dir)
+        + check_morphology(config)
+        + check_protocols(config)
+        + check_synapses(config)
+    )
+    if errors:
+        raise InvalidConfigError(config_path, errors)
+
+    if instantiate:
+        logger.info("Instantiating the cell of %s", config_path)
+        try:
+            instantiate_cell(config)
+        except (RuntimeError, ValueError, KeyError, OSError) as exc:
+            raise InvalidConfigError(
+                config_path, [f"the cell cannot be instantiated: {exc}"]
+            ) from exc
+
+    return config
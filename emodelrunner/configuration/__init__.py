@@ -15,8 +15,11 @@
 # limitations under the License.
 
 from emodelrunner.configuration.validator import (
+    get_config_schema,
     get_validated_config,
     ConfigValidator,
+    InvalidConfigError,
+    HippocampusConfigValidator,
     SSCXConfigValidator,
     SynplasConfigValidator,
     ThalamusConfigValidator,
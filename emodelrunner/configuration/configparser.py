@@ -25,6 +25,7 @@ class PackageType(Enum):
     sscx = "sscx"
     thalamus = "thalamus"
     synplas = "synplas"
+    hippocampus = "hippocampus"
 
 
 class EModelConfigParser(ConfigParser):
@@ -14,18 +14,171 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
+import json
 import logging
 import pprint
 from pathlib import Path
 from abc import ABC
 from ast import literal_eval
 
-from schema import Schema, And, Or
+from schema import Schema, SchemaError, And, Or
 
 from emodelrunner.configuration.configparser import EModelConfigParser
+from emodelrunner.locations import SECTION_LOCATION_PATTERN, SECTIONLIST_IDS
+from emodelrunner.nwb_output import OUTPUT_FORMATS
+from emodelrunner.pharmacology import parse_channel_blocks
+from emodelrunner.recordings import RECORDING_VARIABLE_PATTERN
 
 logger = logging.getLogger(__name__)
 
+# description of the named validation rules, used in the error messages
+RULE_DESCRIPTIONS = {
+    "int_expression": "an integer",
+    "positive_int_expression": "a strictly positive integer",
+    "float_or_int_expression": "a number",
+    "boolean_expression": "a boolean, e.g. True or False",
+    "list_of_nonempty_str": "a list of non-empty strings",
+    "section_location": "a section location, e.g. dend[3](0.5)",
+    "list_of_section_locations": 'a list of section locations, e.g. ["dend[3](0.5)"]',
+    "list_of_recording_variables": 'a list of range variables, e.g. ["cai", "NaTg.m"]',
+    "recording_variables_by_location": "a dict of lists of range variables "
+    'keyed by section location, e.g. {"dend[3](0.5)": ["cai"]}',
+    "list_of_positions": "a list of [x, y, z] positions",
+    "list_of_ints": "a list of integers",
+    "list_of_numbers": "a list of numbers",
+    "dict_of_numbers": 'a dict of numbers, e.g. {"v": 0.1}',
+    "list_of_section_types": "a list of section types among "
+    + ", ".join(SECTIONLIST_IDS),
+    "distance_range": "an empty list or a [min, max] distance range",
+    "channel_blocks": "channel blocks, e.g. 'gSKv3_1bar=0, gNaTgbar*0.5'",
+    "existing_path": "an existing path",
+    "str": "a string",
+}
+
+
+class InvalidConfigError(SchemaError):
+    """Error listing all the problems of a config file."""
+
+    def __init__(self, config_path, config_errors):
+        """Constructor.
+
+        Args:
+            config_path (str or Path): path to the configuration file
+            config_errors (list of str): description of each problem
+        """
+        self.config_errors = config_errors
+        message = "\n".join(
+            [f"Invalid config file {config_path}:"]
+            + [f"  - {config_error}" for config_error in config_errors]
+        )
+        super().__init__(message)
+
+
+def describe_rule(rule):
+    """Return a human readable description of a validation rule.
+
+    Args:
+        rule: validation rule of the schema, e.g. Or("neuron", "coreneuron")
+
+    Returns:
+        str: description of the values accepted by the rule
+    """
+    if isinstance(rule, Or):
+        return "one of " + ", ".join(
+            repr(arg) if isinstance(arg, str) else describe_rule(arg)
+            for arg in rule.args
+        )
+    if isinstance(rule, And) and rule.args == (str, len):
+        return "a non-empty string"
+    return RULE_DESCRIPTIONS.get(getattr(rule, "__name__", None), "a valid value")
+
+
+def to_config_value(value):
+    """Convert a value of a json or yaml config to its string in a .ini config.
+
+    Args:
+        value: value of the json or yaml config
+
+    Returns:
+        str: the value, with lists written as json
+    """
+    if isinstance(value, str):
+        return value
+    if isinstance(value, (list, dict)):
+        return json.dumps(value)
+    if value is None:
+        return ""
+    return str(value)
+
+
+def load_config_dict(config_path):
+    """Load a json or yaml config as a dict of sections.
+
+    Args:
+        config_path (Path): path to the .json, .yaml or .yml configuration file.
+
+    Raises:
+        RuntimeError: if the yaml config is given and PyYAML cannot be imported
+        ValueError: if the config is not a mapping of sections
+
+    Returns:
+        dict: the values of the config as strings, for each section
+    """
+    with open(config_path, "r", encoding="utf-8") as config_file:
+        if config_path.suffix == ".json":
+            config_dict = json.load(config_file)
+        else:
+            try:
+                # only needed by the yaml configs
+                # pylint: disable=import-outside-toplevel
+                import yaml
+            except ImportError as exc:
+                raise RuntimeError(
+                    "PyYAML cannot be imported. "
+                    "Install it with 'pip install emodelrunner[yaml]'."
+                ) from exc
+            config_dict = yaml.safe_load(config_file)
+
+    if not isinstance(config_dict, dict) or not all(
+        isinstance(section, dict) for section in config_dict.values()
+    ):
+        raise ValueError(
+            f"The config file {config_path} should map each section "
+            "to its keys and values."
+        )
+
+    return {
+        section: {key: to_config_value(value) for key, value in values.items()}
+        for section, values in config_dict.items()
+    }
+
+
+def parse_config_overrides(config_overrides):
+    """Parse the values overriding the ones of a config file.
+
+    Args:
+        config_overrides (list of str): overrides given as 'section.key=value',
+            e.g. 'Cell.celsius=36'
+
+    Raises:
+        ValueError: if an override does not have the 'section.key=value' format
+
+    Returns:
+        dict: the overriding values for each section
+    """
+    overrides = {}
+    for config_override in config_overrides or []:
+        name, separator, value = config_override.partition("=")
+        section, _, key = name.strip().partition(".")
+        if not separator or not section or not key:
+            raise ValueError(
+                f"Could not parse the config override {config_override!r}. "
+                "Expected 'section.key=value', e.g. 'Cell.celsius=36'."
+            )
+        overrides.setdefault(section, {})[key] = value.strip()
+
+    return overrides
+
 
 class ConfigValidator(ABC):
     """Validates the config through a validation schema.
@@ -65,6 +218,18 @@ class ConfigValidator(ABC):
         """
         return cls.evaluates_to(n, int)
 
+    @classmethod
+    def positive_int_expression(cls, n):
+        """Check if n evaluates to a strictly positive int literal.
+
+        Args:
+            n (str): the parameter value to be evaluated
+
+        Returns:
+            bool: true if the expression n evaluates to an int above 0, false otherwise
+        """
+        return cls.evaluates_to(n, int) and literal_eval(n) > 0
+
     @classmethod
     def float_or_int_expression(cls, n):
         """Check if n evaluates to a float or an integer literal.
@@ -92,6 +257,200 @@ class ConfigValidator(ABC):
         list_instance = literal_eval(list_instance)
         return all(isinstance(s, str) and len(s) for s in list_instance)
 
+    @staticmethod
+    def section_location(location):
+        """Check if the input is a section location, e.g. 'dend[3](0.5)'.
+
+        Args:
+            location (str): a section location.
+
+        Returns:
+            bool: true if the input is a section location.
+        """
+        return SECTION_LOCATION_PATTERN.match(location.replace(" ", "")) is not None
+
+    @classmethod
+    def list_of_section_locations(cls, list_instance):
+        """Check if the input is a list of section locations, e.g. 'dend[3](0.5)'.
+
+        Args:
+            list_instance (str): a string that evaluates to list.
+
+        Returns:
+            bool: true if the expression evaluates to a list of section locations.
+        """
+        return cls.list_of_nonempty_str(list_instance) and all(
+            SECTION_LOCATION_PATTERN.match(location.replace(" ", ""))
+            for location in literal_eval(list_instance)
+        )
+
+    @classmethod
+    def list_of_recording_variables(cls, list_instance):
+        """Check if the input is a list of range variables, e.g. 'cai' or 'NaTg.m'.
+
+        Args:
+            list_instance (str): a string that evaluates to list.
+
+        Returns:
+            bool: true if the expression evaluates to a list of range variables.
+        """
+        return cls.list_of_nonempty_str(list_instance) and all(
+            RECORDING_VARIABLE_PATTERN.match(variable)
+            for variable in literal_eval(list_instance)
+        )
+
+    @classmethod
+    def recording_variables_by_location(cls, dict_instance):
+        """Check if the input is a dict of lists of range variables keyed by location.
+
+        Args:
+            dict_instance (str): a string that evaluates to dict.
+
+        Returns:
+            bool: true if the expression evaluates to a dict of lists of range
+                variables keyed by section locations, e.g. 'dend[3](0.5)'.
+        """
+        dict_instance = literal_eval(dict_instance)
+        return isinstance(dict_instance, dict) and all(
+            isinstance(location, str)
+            and cls.section_location(location)
+            and isinstance(variables, list)
+            and cls.list_of_recording_variables(repr(variables))
+            for location, variables in dict_instance.items()
+        )
+
+    @staticmethod
+    def list_of_positions(list_instance):
+        """Check if the input is a list of x, y, z coordinates.
+
+        Args:
+            list_instance (str): a string that evaluates to list.
+
+        Returns:
+            bool: true if the expression evaluates to a list of lists of 3 numbers.
+        """
+        return all(
+            isinstance(position, list)
+            and len(position) == 3
+            and all(isinstance(coord, (int, float)) for coord in position)
+            for position in literal_eval(list_instance)
+        )
+
+    @staticmethod
+    def list_of_ints(list_instance):
+        """Check if the input is a list of integers.
+
+        Args:
+            list_instance (str): a string that evaluates to list.
+
+        Returns:
+            bool: true if the expression evaluates to a list of integers.
+        """
+        list_instance = literal_eval(list_instance)
+        return isinstance(list_instance, list) and all(
+            isinstance(i, int) for i in list_instance
+        )
+
+    @staticmethod
+    def list_of_numbers(list_instance):
+        """Check if the input is a list of numbers.
+
+        Args:
+            list_instance (str): a string that evaluates to list.
+
+        Returns:
+            bool: true if the expression evaluates to a list of numbers.
+        """
+        list_instance = literal_eval(list_instance)
+        return isinstance(list_instance, list) and all(
+            isinstance(x, (int, float)) for x in list_instance
+        )
+
+    @staticmethod
+    def dict_of_numbers(dict_instance):
+        """Check if the input is a json dict of numbers keyed by strings.
+
+        The dict is parsed as json, as when the config is read.
+
+        Args:
+            dict_instance (str): a string that is a json dict.
+
+        Returns:
+            bool: true if the string is a json dict of numbers.
+        """
+        try:
+            dict_instance = json.loads(dict_instance)
+        except ValueError:
+            return False
+        return isinstance(dict_instance, dict) and all(
+            isinstance(key, str) and isinstance(value, (int, float))
+            for key, value in dict_instance.items()
+        )
+
+    @staticmethod
+    def list_of_section_types(list_instance):
+        """Check if the input is a list of section types, e.g. 'apical_dendrite'.
+
+        Args:
+            list_instance (str): a string that evaluates to list.
+
+        Returns:
+            bool: true if the expression evaluates to a list of section types.
+        """
+        list_instance = literal_eval(list_instance)
+        return isinstance(list_instance, list) and all(
+            section_type in SECTIONLIST_IDS for section_type in list_instance
+        )
+
+    @staticmethod
+    def distance_range(list_instance):
+        """Check if the input is empty or a [min, max] range of distances.
+
+        Args:
+            list_instance (str): a string that evaluates to list.
+
+        Returns:
+            bool: true if the expression evaluates to an empty list
+                or to 2 positive numbers in increasing order.
+        """
+        list_instance = literal_eval(list_instance)
+        if list_instance == []:
+            return True
+        return (
+            isinstance(list_instance, list)
+            and len(list_instance) == 2
+            and all(isinstance(d, (int, float)) for d in list_instance)
+            and 0 <= list_instance[0] <= list_instance[1]
+        )
+
+    @staticmethod
+    def channel_blocks(blocks):
+        """Check if the input is a list of channel blocks, e.g. 'gNaTgbar*0.5'.
+
+        Args:
+            blocks (str): comma-separated blocks
+
+        Returns:
+            bool: true if each block has the 'name=value' or 'name*factor' format.
+        """
+        try:
+            parse_channel_blocks(blocks)
+        except ValueError:
+            return False
+        return True
+
+    @staticmethod
+    def existing_path(path):
+        """Check if the path exists.
+
+        Args:
+            path (str): path to a file or a directory.
+
+        Returns:
+            bool: true if the path exists.
+        """
+        return Path(path).exists()
+
     @staticmethod
     def boolean_expression(bool_input):
         """Checks if the expression has an expected boolean value.
@@ -106,11 +465,14 @@ class ConfigValidator(ABC):
         """
         return bool_input in ["True", "False", "true", "false", "1", "0"]
 
-    def validate_from_file(self, config_path):
+    def validate_from_file(self, config_path, config_overrides=None):
         """Validates the config at the given path and returns it.
 
         Args:
-            config_path (str or Path): path to the .ini configuration file.
+            config_path (str or Path): path to the .ini, .json or .yaml
+                configuration file.
+            config_overrides (list of str): values overriding the ones of the file,
+                given as 'section.key=value'
 
         Returns:
             configparser.ConfigParser: the validated config.
@@ -118,12 +480,16 @@ class ConfigValidator(ABC):
         Raises:
             FileNotFoundError: if config_path does not exist.
         """
-        config = self._get_unvalidated_config(config_path)
+        config = self._get_unvalidated_config(config_path, config_overrides)
 
         confdict = {
             section: dict(config.items(section)) for section in config.sections()
         }
 
+        config_errors = self.get_config_errors(confdict)
+        if config_errors:
+            raise InvalidConfigError(config_path, config_errors)
+
         validated_conf = self.config_validator_schema.validate(confdict)
         logger.info("The loaded parameters are:")
         logger.info(pprint.pformat(validated_conf))
@@ -132,11 +498,77 @@ class ConfigValidator(ABC):
 
         return config
 
-    def _get_unvalidated_config(self, config_path):
+    def get_config_errors(self, confdict):
+        """Return the problems of a config, checking each key against the schema.
+
+        Args:
+            confdict (dict): the values of the config for each section
+
+        Returns:
+            list of str: description of the unknown sections and keys,
+                of the missing ones and of the invalid values
+        """
+        schema = self.config_validator_schema.schema
+        config_errors = [
+            f"unknown section [{section}], expected one of {', '.join(schema)}"
+            for section in confdict
+            if section not in schema
+        ]
+
+        for section, rules in schema.items():
+            if section not in confdict:
+                config_errors.append(f"missing required section [{section}]")
+                continue
+            for key in confdict[section]:
+                if key not in rules:
+                    config_errors.append(
+                        f"unknown key '{key}' in section [{section}], "
+                        f"expected one of {', '.join(rules)}"
+                    )
+            for key, rule in rules.items():
+                if key not in confdict[section]:
+                    config_errors.append(
+                        f"missing required key '{key}' in section [{section}]"
+                    )
+                    continue
+                value = confdict[section][key]
+                try:
+                    Schema(rule).validate(value)
+                except SchemaError:
+                    config_errors.append(
+                        f"invalid value {value!r} for key '{key}' in section "
+                        f"[{section}], expected {describe_rule(rule)}"
+                    )
+
+        return config_errors
+
+    def get_schema(self):
+        """Return the description of the sections and keys of the config.
+
+        Returns:
+            dict: for each key of each section, the description of the expected
+                values, whether the key is required and its default value if any
+        """
+        return {
+            section: {
+                key: {
+                    "expected": describe_rule(rule),
+                    "required": key not in self.default_values.get(section, {}),
+                    "default": self.default_values.get(section, {}).get(key),
+                }
+                for key, rule in rules.items()
+            }
+            for section, rules in self.config_validator_schema.schema.items()
+        }
+
+    def _get_unvalidated_config(self, config_path, config_overrides=None):
         """Returns the config at the given path and fill unset values with default values.
 
         Args:
-            config_path (str or Path): path to the .ini configuration file.
+            config_path (str or Path): path to the .ini, .json or .yaml
+                configuration file.
+            config_overrides (list of str): values overriding the ones of the file,
+                given as 'section.key=value'
 
         Returns:
             configparser.ConfigParser: the config.
@@ -144,14 +576,20 @@ class ConfigValidator(ABC):
         Raises:
             FileNotFoundError: if config_path does not exist.
         """
-        if not Path(config_path).exists():
+        config_path = Path(config_path)
+        if not config_path.exists():
             raise FileNotFoundError(f"config file at {config_path} is not found.")
         config = EModelConfigParser()
 
         # set defaults
         config.read_dict(self.default_values)
 
-        config.read(config_path)
+        if config_path.suffix in [".json", ".yaml", ".yml"]:
+            config.read_dict(load_config_dict(config_path))
+        else:
+            config.read(config_path)
+
+        config.read_dict(parse_config_overrides(config_overrides))
         return config
 
 
@@ -164,27 +602,159 @@ class SSCXConfigValidator(ConfigValidator):
             "celsius": "34",
             "v_init": "-80",
             "gid": "0",
+            # presynaptic cell of the pair simulation (run-pairsim). It has the e-model
+            # of the cell if empty. Only the synapses from precell_gid connect
+            # the pair, all the synapses if empty
+            "precell_emodel": "",
+            "precell_gid": "",
+            # can be "hoc" (hoc cell template) or "python" (NEURON python API)
+            "instantiation": "hoc",
         },
         "Protocol": {
             # -1 means there is no apical point
             "apical_point_isec": "-1",
+            # can be "features" or "search"
+            "threshold_current_source": "features",
+            # location of the step and holding stimuli of the GUI,
+            # e.g. 'dend[3](0.5)'. The soma is used if empty
+            "gui_stimulus_location": "",
         },
         "Morphology": {
             "do_replace_axon": "True",
+            # name of the morphology if morph_path is a directory
+            # or a h5 container of morphologies
+            "morph_name": "",
+            # used with precell_morph_path, morph_name is used if both are empty
+            "precell_morph_name": "",
+            # axon replacement: "stub" (do_replace_axon), "full" (no replacement)
+            # or "ais" (AIS of ais_path). do_replace_axon is used if empty
+            "axon_type": "",
+            # factor applied to the diameters of the dendrites
+            "dendrite_diameter_scale": "1.0",
+            # dendritic sections starting beyond this path distance from the soma (um)
+            # are deleted. No pruning if empty
+            "prune_distance": "",
+            # functions modifying the morphology, given as 'module.path:function_name'
+            "modifiers": "[]",
             # is only used for naming the output files
             "mtype": "",
         },
         "Sim": {
             "cvode_active": "False",
+            # absolute and relative tolerances of the variable time step
+            "cvode_atol": "0.001",
+            "cvode_rtol": "0",
+            # scale of the absolute tolerance of some states, e.g. {"v": 0.1}
+            "cvode_atolscale": "{}",
+            # with the variable time step, write the traces resampled
+            # at dt instead of at the steps of the solver
+            "resample_output": "True",
             "dt": "0.025",
+            "simulator": "neuron",  # can be "neuron" or "coreneuron"
+            "coreneuron_gpu": "False",
+            # simulated time between the progress reports (ms). 0 for no report
+            "progress_interval": "0",
+            # stochastic channels, e.g. StochKv, stochastic in every protocol,
+            # seeded with the gid plus channel_seed, and run n_trials times
+            "stochastic_channels": "False",
+            "channel_seed": "0",
+            "n_trials": "1",
+            # global seed from which the seeds of the synapses, stochastic channels,
+            # noise stimuli and spike trains are derived. Not used if empty
+            "seed": "",
         },
         "Synapses": {
             "add_synapses": "False",
             "seed": "846515",
             "rng_settings_mode": "Random123",  # can be "Random123" or "Compatibility"
+            # factor applied to the weights of all the synapses
+            "weight_scale": "1.0",
+            # SONATA edge population, when syn_data_file is a SONATA edges file.
+            # All the populations of the file are used if empty.
+            "edge_population": "",
+            # activate only the synapses from these pre_mtype ids,
+            # on these section types, e.g. ["apical_dendrite"],
+            # and within this [min, max] path distance from the soma (um).
+            # All the synapses are activated if empty.
+            "pre_mtypes": "[]",
+            "section_types": "[]",
+            "path_distance_range": "[]",
             # name to use for the hoc synapse template
             "hoc_synapse_template_name": "hoc_synapses",
         },
+        "Analysis": {
+            # hooks given as 'module.path:function_name'
+            "hooks": "[]",
+            # eFEL features extracted from the somatic voltage of every protocol,
+            # e.g. ["Spikecount", "ISI_CV", "AP_amplitude"]
+            "efeatures": "[]",
+            # compare the features of the features file with their experimental
+            # mean and std, and write the z-scores in target_comparison.json
+            "compare_to_targets": "False",
+            "max_zscore": "3",  # maximum absolute z-score of a passing feature
+            "plot_responses": "False",
+            "plot_format": "png",  # can be "png" or "svg"
+            "output_format": "dat",  # can be "dat", "h5", "nwb" or "npy"
+            # gzip compression level of the h5 output, from 1 to 9. 0 for none
+            "h5_compression": "4",
+        },
+        "Pharmacology": {
+            # conductances set or scaled at runtime, e.g. 'gSKv3_1bar=0, gNaTgbar*0.5'
+            "block": "",
+        },
+        "Recordings": {
+            # recorded at every protocol, given as e.g. 'dend[3](0.5)'
+            "locations": "[]",
+            # range variables, e.g. "v", "cai", "nai" or the state m
+            # of the NaTg mechanism as "NaTg.m" (or "m_NaTg"),
+            # or "currents" for all the ionic currents, e.g. "ina" and "ina_NaTg"
+            "variables": '["v"]',
+            # variables recorded at a single location, e.g. {"dend[3](0.5)": ["cai"]}
+            "location_variables": "{}",
+            # interval between the recorded samples (ms), or number of integration
+            # steps between them. Sampling of each protocol if both are empty
+            "record_dt": "",
+            "record_every": "",
+        },
+        "Extracellular": {
+            # protocols at which the extracellular potential is recorded
+            "protocols": "[]",
+            # x, y, z coordinates of each electrode (um)
+            "electrode_positions": "[]",
+            "sigma": "0.3",  # extracellular conductivity (S/m)
+            "method": "linesource",  # can be "linesource" or "pointsource"
+        },
+        "SynapseRecordings": {
+            # protocols at which the variables of the synapses are recorded
+            "protocols": "[]",
+            # e.g. ["g", "i"], or ["rho_GB", "Use_TM"] for GluSynapse
+            "variables": '["g", "i"]',
+            # ids and pre_mtype ids of the recorded synapses, all if empty
+            "synapse_ids": "[]",
+            "pre_mtypes": "[]",
+            # interval between the recorded samples (ms), or number of
+            # integration steps between them. Every step if both are empty
+            "record_dt": "",
+            "record_every": "",
+        },
+        "Minis": {
+            # protocols during which the synapses release spontaneously
+            "protocols": "[]",
+            # spontaneous release rate of each synapse (Hz),
+            # and the ones of the synapses of some pre_mtype ids, e.g. {"1": 0.02}
+            "rate": "0.01",
+            "pre_mtype_rates": "{}",
+            "seed": "0",
+        },
+        "Pair": {
+            # current pulses making the presynaptic cell of run-pairsim spike
+            "spike_times": "[100.0]",  # (ms)
+            "spike_amplitude": "3.0",  # (nA)
+            "spike_width": "2.0",  # (ms)
+            "tstop": "300.0",  # (ms)
+            # window after each presynaptic spike where the EPSP peak is searched (ms)
+            "epsp_window": "20.0",
+        },
         "Paths": {
             "memodel_dir": ".",
             "output_dir": "%(memodel_dir)s/python_recordings",
@@ -204,6 +774,21 @@ class SSCXConfigValidator(ConfigValidator):
             "syn_conf_file": "synconf.txt",
             "syn_hoc_file": "synapses.hoc",
             "syn_mtype_map": "mtype_map.tsv",
+            # SONATA nodes file of the pre-synaptic cells, to read their mtypes
+            "syn_source_nodes_path": "",
+            # spontaneous release rate of individual synapses,
+            # given as 'synapse_id rate' lines, overriding the [Minis] rates
+            "minis_rates_path": "",
+            # json file of the AIS replacing the axon if axon_type is "ais"
+            "ais_path": "",
+            # morphology and parameters of the presynaptic cell of run-pairsim,
+            # the ones of the cell if empty
+            "precell_morph_path": "",
+            "precell_unoptimized_params_path": "",
+            # morphologies converted to asc, when not in the asc or swc format
+            "converted_morph_dir": "%(memodel_dir)s/converted_morphology",
+            # json file updated with the progress of the run. Not written if empty
+            "status_path": "",
             "simul_hoc_file": "createsimulation.hoc",
             "cell_hoc_file": "cell.hoc",
             "run_hoc_file": "run.hoc",
@@ -222,47 +807,126 @@ class SSCXConfigValidator(ConfigValidator):
                     "v_init": self.float_or_int_expression,
                     "gid": self.int_expression,
                     "emodel": And(str, len),
+                    "instantiation": Or("hoc", "python"),
+                    "precell_emodel": str,
+                    "precell_gid": Or("", self.int_expression),
                 },
                 "Protocol": {
                     "apical_point_isec": self.int_expression,
+                    "threshold_current_source": Or("features", "search"),
+                    "gui_stimulus_location": Or("", self.section_location),
                 },
                 "Morphology": {
                     "mtype": And(str, len),
                     "do_replace_axon": self.boolean_expression,
+                    "morph_name": str,
+                    "precell_morph_name": str,
+                    "axon_type": Or("", "stub", "full", "ais"),
+                    "dendrite_diameter_scale": self.float_or_int_expression,
+                    "prune_distance": Or("", self.float_or_int_expression),
+                    "modifiers": self.list_of_nonempty_str,
                 },
                 "Sim": {
                     "cvode_active": self.boolean_expression,
+                    "cvode_atol": self.float_or_int_expression,
+                    "cvode_rtol": self.float_or_int_expression,
+                    "cvode_atolscale": self.dict_of_numbers,
+                    "resample_output": self.boolean_expression,
                     "dt": self.float_or_int_expression,
+                    "simulator": Or("neuron", "coreneuron"),
+                    "coreneuron_gpu": self.boolean_expression,
+                    "progress_interval": self.float_or_int_expression,
+                    "stochastic_channels": self.boolean_expression,
+                    "channel_seed": self.int_expression,
+                    "n_trials": self.positive_int_expression,
+                    "seed": Or("", self.int_expression),
                 },
                 "Synapses": {
                     "add_synapses": self.boolean_expression,
                     "seed": self.int_expression,
                     "rng_settings_mode": Or("Random123", "Compatibility"),
+                    "weight_scale": self.float_or_int_expression,
+                    "edge_population": str,
+                    "pre_mtypes": self.list_of_ints,
+                    "section_types": self.list_of_section_types,
+                    "path_distance_range": self.distance_range,
                     "hoc_synapse_template_name": And(str, len),
                 },
+                "Analysis": {
+                    "hooks": self.list_of_nonempty_str,
+                    "efeatures": self.list_of_nonempty_str,
+                    "compare_to_targets": self.boolean_expression,
+                    "max_zscore": self.float_or_int_expression,
+                    "plot_responses": self.boolean_expression,
+                    "plot_format": Or("png", "svg"),
+                    "output_format": Or(*OUTPUT_FORMATS),
+                    "h5_compression": Or(*[str(level) for level in range(10)]),
+                },
+                "Pharmacology": {"block": self.channel_blocks},
+                "Recordings": {
+                    "locations": self.list_of_section_locations,
+                    "variables": self.list_of_recording_variables,
+                    "location_variables": self.recording_variables_by_location,
+                    "record_dt": Or("", self.float_or_int_expression),
+                    "record_every": Or("", self.positive_int_expression),
+                },
+                "Extracellular": {
+                    "protocols": self.list_of_nonempty_str,
+                    "electrode_positions": self.list_of_positions,
+                    "sigma": self.float_or_int_expression,
+                    "method": Or("linesource", "pointsource"),
+                },
+                "SynapseRecordings": {
+                    "protocols": self.list_of_nonempty_str,
+                    "variables": self.list_of_nonempty_str,
+                    "synapse_ids": self.list_of_ints,
+                    "pre_mtypes": self.list_of_ints,
+                    "record_dt": Or("", self.float_or_int_expression),
+                    "record_every": Or("", self.positive_int_expression),
+                },
+                "Minis": {
+                    "protocols": self.list_of_nonempty_str,
+                    "rate": self.float_or_int_expression,
+                    "pre_mtype_rates": self.dict_of_numbers,
+                    "seed": self.int_expression,
+                },
+                "Pair": {
+                    "spike_times": self.list_of_numbers,
+                    "spike_amplitude": self.float_or_int_expression,
+                    "spike_width": self.float_or_int_expression,
+                    "tstop": self.float_or_int_expression,
+                    "epsp_window": self.float_or_int_expression,
+                },
                 "Paths": {
-                    "morph_path": lambda n: Path(n).exists(),
-                    "prot_path": lambda n: Path(n).exists(),
-                    "features_path": lambda n: Path(n).exists(),
-                    "unoptimized_params_path": lambda n: Path(n).exists(),
-                    "memodel_dir": lambda n: Path(n).exists(),
-                    "output_dir": lambda n: Path(n).exists(),
-                    "params_path": lambda n: Path(n).exists(),
-                    "units_path": lambda n: Path(n).exists(),
-                    "templates_dir": lambda n: Path(n).exists(),
-                    "cell_template_path": lambda n: Path(n).exists(),
-                    "run_hoc_template_path": lambda n: Path(n).exists(),
-                    "createsimulation_template_path": lambda n: Path(n).exists(),
-                    "synapses_template_path": lambda n: Path(n).exists(),
-                    "main_protocol_template_path": lambda n: Path(n).exists(),
-                    "features_hoc_template_path": lambda n: Path(n).exists(),
-                    "replace_axon_hoc_path": lambda n: Path(n).exists(),
-                    "syn_dir_for_hoc": lambda n: Path(n).exists(),
-                    "syn_dir": lambda n: Path(n).exists(),
+                    "morph_path": self.existing_path,
+                    "prot_path": self.existing_path,
+                    "features_path": self.existing_path,
+                    "unoptimized_params_path": self.existing_path,
+                    "memodel_dir": self.existing_path,
+                    "output_dir": self.existing_path,
+                    "params_path": self.existing_path,
+                    "units_path": self.existing_path,
+                    "templates_dir": self.existing_path,
+                    "cell_template_path": self.existing_path,
+                    "run_hoc_template_path": self.existing_path,
+                    "createsimulation_template_path": self.existing_path,
+                    "synapses_template_path": self.existing_path,
+                    "main_protocol_template_path": self.existing_path,
+                    "features_hoc_template_path": self.existing_path,
+                    "replace_axon_hoc_path": self.existing_path,
+                    "syn_dir_for_hoc": self.existing_path,
+                    "syn_dir": self.existing_path,
                     "syn_data_file": And(str, len),
                     "syn_conf_file": And(str, len),
                     "syn_hoc_file": And(str, len),
                     "syn_mtype_map": And(str, len),
+                    "syn_source_nodes_path": Or("", self.existing_path),
+                    "minis_rates_path": Or("", self.existing_path),
+                    "ais_path": Or("", self.existing_path),
+                    "precell_morph_path": Or("", self.existing_path),
+                    "precell_unoptimized_params_path": Or("", self.existing_path),
+                    "converted_morph_dir": And(str, len),
+                    "status_path": str,
                     "simul_hoc_file": And(str, len),
                     "cell_hoc_file": And(str, len),
                     "run_hoc_file": And(str, len),
@@ -282,24 +946,140 @@ class ThalamusConfigValidator(ConfigValidator):
             "celsius": "34",
             "v_init": "-80",
             "gid": "0",
+            # can be "hoc" (hoc cell template) or "python" (NEURON python API)
+            "instantiation": "hoc",
         },
         "Protocol": {
             # -1 means there is no apical point
             "apical_point_isec": "-1",
+            # can be "features" or "search"
+            "threshold_current_source": "features",
+            # location of the step and holding stimuli of the GUI,
+            # e.g. 'dend[3](0.5)'. The soma is used if empty
+            "gui_stimulus_location": "",
         },
         "Morphology": {
             "do_replace_axon": "True",
+            # name of the morphology if morph_path is a directory
+            # or a h5 container of morphologies
+            "morph_name": "",
+            # axon replacement: "stub" (do_replace_axon), "full" (no replacement)
+            # or "ais" (AIS of ais_path). do_replace_axon is used if empty
+            "axon_type": "",
+            # factor applied to the diameters of the dendrites
+            "dendrite_diameter_scale": "1.0",
+            # dendritic sections starting beyond this path distance from the soma (um)
+            # are deleted. No pruning if empty
+            "prune_distance": "",
+            # functions modifying the morphology, given as 'module.path:function_name'
+            "modifiers": "[]",
             # is only used for naming the output files
             "mtype": "",
         },
         "Sim": {
             "cvode_active": "False",
+            # absolute and relative tolerances of the variable time step
+            "cvode_atol": "0.001",
+            "cvode_rtol": "0",
+            # scale of the absolute tolerance of some states, e.g. {"v": 0.1}
+            "cvode_atolscale": "{}",
+            # with the variable time step, write the traces resampled
+            # at dt instead of at the steps of the solver
+            "resample_output": "True",
             "dt": "0.025",
+            "simulator": "neuron",  # can be "neuron" or "coreneuron"
+            "coreneuron_gpu": "False",
+            # simulated time between the progress reports (ms). 0 for no report
+            "progress_interval": "0",
+            # stochastic channels, e.g. StochKv, stochastic in every protocol,
+            # seeded with the gid plus channel_seed, and run n_trials times
+            "stochastic_channels": "False",
+            "channel_seed": "0",
+            "n_trials": "1",
+            # global seed from which the seeds of the synapses, stochastic channels,
+            # noise stimuli and spike trains are derived. Not used if empty
+            "seed": "",
         },
         "Synapses": {
             "add_synapses": "False",
             "seed": "846515",
             "rng_settings_mode": "Random123",  # can be "Random123" or "Compatibility"
+            # factor applied to the weights of all the synapses
+            "weight_scale": "1.0",
+            # SONATA edge population, when syn_data_file is a SONATA edges file.
+            # All the populations of the file are used if empty.
+            "edge_population": "",
+            # activate only the synapses from these pre_mtype ids,
+            # on these section types, e.g. ["apical_dendrite"],
+            # and within this [min, max] path distance from the soma (um).
+            # All the synapses are activated if empty.
+            "pre_mtypes": "[]",
+            "section_types": "[]",
+            "path_distance_range": "[]",
+        },
+        "Analysis": {
+            # hooks given as 'module.path:function_name'
+            "hooks": "[]",
+            # eFEL features extracted from the somatic voltage of every protocol,
+            # e.g. ["Spikecount", "ISI_CV", "AP_amplitude"]
+            "efeatures": "[]",
+            # compare the features of the features file with their experimental
+            # mean and std, and write the z-scores in target_comparison.json
+            "compare_to_targets": "False",
+            "max_zscore": "3",  # maximum absolute z-score of a passing feature
+            "plot_responses": "False",
+            "plot_format": "png",  # can be "png" or "svg"
+            "output_format": "dat",  # can be "dat", "h5", "nwb" or "npy"
+            # gzip compression level of the h5 output, from 1 to 9. 0 for none
+            "h5_compression": "4",
+        },
+        "Pharmacology": {
+            # conductances set or scaled at runtime, e.g. 'gSKv3_1bar=0, gNaTgbar*0.5'
+            "block": "",
+        },
+        "Recordings": {
+            # recorded at every protocol, given as e.g. 'dend[3](0.5)'
+            "locations": "[]",
+            # range variables, e.g. "v", "cai", "nai" or the state m
+            # of the NaTg mechanism as "NaTg.m" (or "m_NaTg"),
+            # or "currents" for all the ionic currents, e.g. "ina" and "ina_NaTg"
+            "variables": '["v"]',
+            # variables recorded at a single location, e.g. {"dend[3](0.5)": ["cai"]}
+            "location_variables": "{}",
+            # interval between the recorded samples (ms), or number of integration
+            # steps between them. Sampling of each protocol if both are empty
+            "record_dt": "",
+            "record_every": "",
+        },
+        "Extracellular": {
+            # protocols at which the extracellular potential is recorded
+            "protocols": "[]",
+            # x, y, z coordinates of each electrode (um)
+            "electrode_positions": "[]",
+            "sigma": "0.3",  # extracellular conductivity (S/m)
+            "method": "linesource",  # can be "linesource" or "pointsource"
+        },
+        "SynapseRecordings": {
+            # protocols at which the variables of the synapses are recorded
+            "protocols": "[]",
+            # e.g. ["g", "i"], or ["rho_GB", "Use_TM"] for GluSynapse
+            "variables": '["g", "i"]',
+            # ids and pre_mtype ids of the recorded synapses, all if empty
+            "synapse_ids": "[]",
+            "pre_mtypes": "[]",
+            # interval between the recorded samples (ms), or number of
+            # integration steps between them. Every step if both are empty
+            "record_dt": "",
+            "record_every": "",
+        },
+        "Minis": {
+            # protocols during which the synapses release spontaneously
+            "protocols": "[]",
+            # spontaneous release rate of each synapse (Hz),
+            # and the ones of the synapses of some pre_mtype ids, e.g. {"1": 0.02}
+            "rate": "0.01",
+            "pre_mtype_rates": "{}",
+            "seed": "0",
         },
         "Paths": {
             "memodel_dir": ".",
@@ -310,6 +1090,17 @@ class ThalamusConfigValidator(ConfigValidator):
             "syn_data_file": "synapses.tsv",
             "syn_conf_file": "synconf.txt",
             "syn_mtype_map": "mtype_map.tsv",
+            # SONATA nodes file of the pre-synaptic cells, to read their mtypes
+            "syn_source_nodes_path": "",
+            # spontaneous release rate of individual synapses,
+            # given as 'synapse_id rate' lines, overriding the [Minis] rates
+            "minis_rates_path": "",
+            # json file of the AIS replacing the axon if axon_type is "ais"
+            "ais_path": "",
+            # morphologies converted to asc, when not in the asc or swc format
+            "converted_morph_dir": "%(memodel_dir)s/converted_morphology",
+            # json file updated with the progress of the run. Not written if empty
+            "status_path": "",
         },
     }
 
@@ -323,36 +1114,103 @@ class ThalamusConfigValidator(ConfigValidator):
                     "v_init": self.float_or_int_expression,
                     "gid": self.int_expression,
                     "emodel": And(str, len),
+                    "instantiation": Or("hoc", "python"),
                 },
                 "Protocol": {
                     "apical_point_isec": self.int_expression,
+                    "threshold_current_source": Or("features", "search"),
+                    "gui_stimulus_location": Or("", self.section_location),
                 },
                 "Morphology": {
                     "mtype": And(str, len),
                     "do_replace_axon": self.boolean_expression,
+                    "morph_name": str,
+                    "axon_type": Or("", "stub", "full", "ais"),
+                    "dendrite_diameter_scale": self.float_or_int_expression,
+                    "prune_distance": Or("", self.float_or_int_expression),
+                    "modifiers": self.list_of_nonempty_str,
                 },
                 "Sim": {
                     "cvode_active": self.boolean_expression,
+                    "cvode_atol": self.float_or_int_expression,
+                    "cvode_rtol": self.float_or_int_expression,
+                    "cvode_atolscale": self.dict_of_numbers,
+                    "resample_output": self.boolean_expression,
                     "dt": self.float_or_int_expression,
+                    "simulator": Or("neuron", "coreneuron"),
+                    "coreneuron_gpu": self.boolean_expression,
+                    "progress_interval": self.float_or_int_expression,
+                    "stochastic_channels": self.boolean_expression,
+                    "channel_seed": self.int_expression,
+                    "n_trials": self.positive_int_expression,
+                    "seed": Or("", self.int_expression),
                 },
                 "Synapses": {
                     "add_synapses": self.boolean_expression,
                     "seed": self.int_expression,
                     "rng_settings_mode": Or("Random123", "Compatibility"),
+                    "weight_scale": self.float_or_int_expression,
+                    "edge_population": str,
+                    "pre_mtypes": self.list_of_ints,
+                    "section_types": self.list_of_section_types,
+                    "path_distance_range": self.distance_range,
+                },
+                "Analysis": {
+                    "hooks": self.list_of_nonempty_str,
+                    "efeatures": self.list_of_nonempty_str,
+                    "compare_to_targets": self.boolean_expression,
+                    "max_zscore": self.float_or_int_expression,
+                    "plot_responses": self.boolean_expression,
+                    "plot_format": Or("png", "svg"),
+                    "output_format": Or(*OUTPUT_FORMATS),
+                    "h5_compression": Or(*[str(level) for level in range(10)]),
+                },
+                "Pharmacology": {"block": self.channel_blocks},
+                "Recordings": {
+                    "locations": self.list_of_section_locations,
+                    "variables": self.list_of_recording_variables,
+                    "location_variables": self.recording_variables_by_location,
+                    "record_dt": Or("", self.float_or_int_expression),
+                    "record_every": Or("", self.positive_int_expression),
+                },
+                "Extracellular": {
+                    "protocols": self.list_of_nonempty_str,
+                    "electrode_positions": self.list_of_positions,
+                    "sigma": self.float_or_int_expression,
+                    "method": Or("linesource", "pointsource"),
+                },
+                "SynapseRecordings": {
+                    "protocols": self.list_of_nonempty_str,
+                    "variables": self.list_of_nonempty_str,
+                    "synapse_ids": self.list_of_ints,
+                    "pre_mtypes": self.list_of_ints,
+                    "record_dt": Or("", self.float_or_int_expression),
+                    "record_every": Or("", self.positive_int_expression),
+                },
+                "Minis": {
+                    "protocols": self.list_of_nonempty_str,
+                    "rate": self.float_or_int_expression,
+                    "pre_mtype_rates": self.dict_of_numbers,
+                    "seed": self.int_expression,
                 },
                 "Paths": {
-                    "morph_path": lambda n: Path(n).exists(),
-                    "prot_path": lambda n: Path(n).exists(),
-                    "features_path": lambda n: Path(n).exists(),
-                    "unoptimized_params_path": lambda n: Path(n).exists(),
-                    "memodel_dir": lambda n: Path(n).exists(),
-                    "output_dir": lambda n: Path(n).exists(),
-                    "params_path": lambda n: Path(n).exists(),
-                    "units_path": lambda n: Path(n).exists(),
-                    "syn_dir": lambda n: Path(n).exists(),
+                    "morph_path": self.existing_path,
+                    "prot_path": self.existing_path,
+                    "features_path": self.existing_path,
+                    "unoptimized_params_path": self.existing_path,
+                    "memodel_dir": self.existing_path,
+                    "output_dir": self.existing_path,
+                    "params_path": self.existing_path,
+                    "units_path": self.existing_path,
+                    "syn_dir": self.existing_path,
                     "syn_data_file": And(str, len),
                     "syn_conf_file": And(str, len),
                     "syn_mtype_map": And(str, len),
+                    "syn_source_nodes_path": Or("", self.existing_path),
+                    "minis_rates_path": Or("", self.existing_path),
+                    "ais_path": Or("", self.existing_path),
+                    "converted_morph_dir": And(str, len),
+                    "status_path": str,
                 },
             }
         )
@@ -374,13 +1232,76 @@ class SynplasConfigValidator(ConfigValidator):
             "pairsim_output_path": "%(memodel_dir)s/output.h5",
             "pairsim_precell_output_path": "%(memodel_dir)s/output_precell.h5",
             "syn_prop_path": "%(syn_dir)s/synapse_properties.json",
+            "checkpoint_dir": "%(memodel_dir)s/checkpoint",
+            # json file of the AIS replacing the axon if axon_type is "ais"
+            "ais_path": "",
+            # morphologies converted to asc, when not in the asc or swc format
+            "converted_morph_dir": "%(memodel_dir)s/converted_morphology",
+            # json file updated with the progress of the run. Not written if empty
+            "status_path": "",
         },
         "Morphology": {
             "do_replace_axon": "True",
+            # name of the morphology if morph_path is a directory
+            # or a h5 container of morphologies
+            "morph_name": "",
+            "precell_morph_name": "",
+            # axon replacement: "stub" (do_replace_axon), "full" (no replacement)
+            # or "ais" (AIS of ais_path). do_replace_axon is used if empty
+            "axon_type": "",
+            # factor applied to the diameters of the dendrites
+            "dendrite_diameter_scale": "1.0",
+            # dendritic sections starting beyond this path distance from the soma (um)
+            # are deleted. No pruning if empty
+            "prune_distance": "",
+            # functions modifying the morphology, given as 'module.path:function_name'
+            "modifiers": "[]",
         },
         "Synapses": {
             "seed": "846515",
             "rng_settings_mode": "Random123",  # can be "Random123" or "Compatibility"
+            # factor applied to the weights of all the synapses
+            "weight_scale": "1.0",
+        },
+        "Sim": {
+            "simulator": "neuron",  # can be "neuron" or "coreneuron"
+            "coreneuron_gpu": "False",
+            # simulated time between the progress reports (ms). 0 for no report
+            "progress_interval": "0",
+            # global seed from which the seeds of the synapses are derived.
+            # Not used if empty
+            "seed": "",
+        },
+        "SynapsePlasticity": {
+            # interval between the saved simulation states (ms). 0 for no checkpoint
+            "checkpoint_interval": "0",
+            # if True, the run starts from the checkpoint in checkpoint_dir, if any
+            "resume": "False",
+            # interval between the recorded samples (ms), or number of
+            # integration steps between them. Every step if both are empty
+            "record_dt": "",
+            "record_every": "",
+        },
+        "Pairing": {
+            # if True, the spike train, the pulses, tstop and fastforward
+            # are built from the pairing parameters below
+            "enabled": "False",
+            "frequency": "1.0",  # pairing frequency (Hz)
+            "n_pairings": "5",  # number of pairings of each repetition
+            "n_repetitions": "10",
+            "repetition_period": "10.0",  # (s)
+            # delay between the pre-synaptic and the post-synaptic spikes (ms)
+            "delta_t": "10.0",
+            "order": "pre_post",  # can be "pre_post" or "post_pre"
+            # delay of the post-synaptic spike after the pulse onset (ms)
+            "postspike_latency": "4.0",
+            "pulse_amplitude": "0.6",  # (nA)
+            "pulse_width": "3.0",  # (ms)
+            # connectivity tests before (C01) and after (C02) the pairings
+            "baseline_duration": "5.0",  # (min)
+            "test_duration": "5.0",  # (min)
+            "test_period": "5.0",  # (s)
+            "test_start": "1000.0",  # first spike of each test (ms)
         },
     }
 
@@ -399,26 +1320,36 @@ class SynplasConfigValidator(ConfigValidator):
                 },
                 "Morphology": {
                     "do_replace_axon": self.boolean_expression,
+                    "morph_name": str,
+                    "precell_morph_name": str,
+                    "axon_type": Or("", "stub", "full", "ais"),
+                    "dendrite_diameter_scale": self.float_or_int_expression,
+                    "prune_distance": Or("", self.float_or_int_expression),
+                    "modifiers": self.list_of_nonempty_str,
                 },
                 "Paths": {
-                    "morph_path": lambda n: Path(n).exists(),
-                    "precell_morph_path": lambda n: Path(n).exists(),
-                    "unoptimized_params_path": lambda n: Path(n).exists(),
-                    "memodel_dir": lambda n: Path(n).exists(),
-                    "params_path": lambda n: Path(n).exists(),
-                    "precell_unoptimized_params_path": lambda n: Path(n).exists(),
-                    "synplas_fit_params_path": lambda n: Path(n).exists(),
-                    "syn_dir": lambda n: Path(n).exists(),
+                    "morph_path": self.existing_path,
+                    "precell_morph_path": self.existing_path,
+                    "unoptimized_params_path": self.existing_path,
+                    "memodel_dir": self.existing_path,
+                    "params_path": self.existing_path,
+                    "precell_unoptimized_params_path": self.existing_path,
+                    "synplas_fit_params_path": self.existing_path,
+                    "syn_dir": self.existing_path,
                     "syn_data_file": And(str, len),
                     "syn_conf_file": And(str, len),
-                    "stimuli_path": lambda n: Path(n).exists(),
-                    "spiketrain_path": lambda n: Path(n).exists(),
-                    "syn_prop_path": lambda n: Path(n).exists(),
+                    "stimuli_path": self.existing_path,
+                    "spiketrain_path": self.existing_path,
+                    "syn_prop_path": self.existing_path,
                     # cannot validate output paths before the files are created,
                     # so check that it is a str
                     "synplas_output_path": And(str, len),
                     "pairsim_output_path": And(str, len),
                     "pairsim_precell_output_path": And(str, len),
+                    "checkpoint_dir": And(str, len),
+                    "ais_path": Or("", self.existing_path),
+                    "converted_morph_dir": And(str, len),
+                    "status_path": str,
                 },
                 "Protocol": {
                     "tstop": self.float_or_int_expression,
@@ -429,39 +1360,121 @@ class SynplasConfigValidator(ConfigValidator):
                 "Synapses": {
                     "seed": self.int_expression,
                     "rng_settings_mode": Or("Random123", "Compatibility"),
+                    "weight_scale": self.float_or_int_expression,
+                },
+                "Sim": {
+                    "simulator": Or("neuron", "coreneuron"),
+                    "coreneuron_gpu": self.boolean_expression,
+                    "progress_interval": self.float_or_int_expression,
+                    "seed": Or("", self.int_expression),
                 },
                 "SynapsePlasticity": {
                     "fastforward": self.float_or_int_expression,
                     "invivo": self.boolean_expression,
                     "base_seed": self.int_expression,
                     "synrec": self.list_of_nonempty_str,
+                    "checkpoint_interval": self.float_or_int_expression,
+                    "resume": self.boolean_expression,
+                    "record_dt": Or("", self.float_or_int_expression),
+                    "record_every": Or("", self.positive_int_expression),
+                },
+                "Pairing": {
+                    "enabled": self.boolean_expression,
+                    "frequency": self.float_or_int_expression,
+                    "n_pairings": self.int_expression,
+                    "n_repetitions": self.int_expression,
+                    "repetition_period": self.float_or_int_expression,
+                    "delta_t": self.float_or_int_expression,
+                    "order": Or("pre_post", "post_pre"),
+                    "postspike_latency": self.float_or_int_expression,
+                    "pulse_amplitude": self.float_or_int_expression,
+                    "pulse_width": self.float_or_int_expression,
+                    "baseline_duration": self.float_or_int_expression,
+                    "test_duration": self.float_or_int_expression,
+                    "test_period": self.float_or_int_expression,
+                    "test_start": self.float_or_int_expression,
                 },
             }
         )
 
 
-def get_validated_config(config_path):
+class HippocampusConfigValidator(ConfigValidator):
+    """Validates the Hippocampus config through a validation schema.
+
+    The hippocampus configs of CA1 pyramidal cells and interneurons have
+    the sections and keys of the thalamus configs, with the resting potential
+    of the CA1 cells as default initial voltage.
+    """
+
+    default_values = {
+        section: dict(values)
+        for section, values in ThalamusConfigValidator.default_values.items()
+    }
+    default_values["Package"] = {"type": "hippocampus"}
+    default_values["Cell"]["v_init"] = "-65"
+
+    def __init__(self):
+        """Define the schema through validation rules."""
+        schema = dict(ThalamusConfigValidator().config_validator_schema.schema)
+        schema["Package"] = {"type": lambda n: n.lower() == "hippocampus"}
+        self.config_validator_schema = Schema(schema)
+
+
+def get_validator(package_type):
+    """Returns the config validator of a package type.
+
+    Args:
+        package_type (str): package type, e.g. "sscx"
+
+    Raises:
+        ValueError: if the package type is not supported
+
+    Returns:
+        ConfigValidator: the config validator
+    """
+    validators = {
+        "sscx": SSCXConfigValidator,
+        "thalamus": ThalamusConfigValidator,
+        "synplas": SynplasConfigValidator,
+        "hippocampus": HippocampusConfigValidator,
+    }
+    if package_type not in validators:
+        raise ValueError(f"Unsupported config type: {package_type}")
+
+    return validators[package_type]()
+
+
+def get_config_schema(package_type):
+    """Returns the description of the config sections of a package type.
+
+    Args:
+        package_type (str): package type, e.g. "sscx"
+
+    Returns:
+        dict: for each key of each section, the description of the expected
+            values, whether the key is required and its default value if any
+    """
+    return get_validator(package_type).get_schema()
+
+
+def get_validated_config(config_path, config_overrides=None):
     """Returns the validated config for the specified package type.
 
     Args:
-        config_path (str or Path): path to the configuration file.
+        config_path (str or Path): path to the .ini, .json or .yaml configuration file.
+        config_overrides (list of str): values overriding the ones of the file,
+            given as 'section.key=value', e.g. 'Cell.celsius=36'
 
     Returns:
         configparser.ConfigParser: loaded config object
     """
     # pylint: disable=protected-access
-    unvalidated_config = ConfigValidator()._get_unvalidated_config(config_path)
+    unvalidated_config = ConfigValidator()._get_unvalidated_config(
+        config_path, config_overrides
+    )
 
     package_type = unvalidated_config.get("Package", "type").lower()
+    conf_validator = get_validator(package_type)
 
-    if package_type == "sscx":
-        conf_validator = SSCXConfigValidator()
-    elif package_type == "thalamus":
-        conf_validator = ThalamusConfigValidator()
-    elif package_type == "synplas":
-        conf_validator = SynplasConfigValidator()
-    else:
-        raise ValueError(f"Unsupported config type: {package_type}")
-
-    validated_config = conf_validator.validate_from_file(config_path)
+    validated_config = conf_validator.validate_from_file(config_path, config_overrides)
     return validated_config
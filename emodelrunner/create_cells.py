@@ -22,10 +22,12 @@ from emodelrunner.load import (
     load_mechanisms,
     load_syn_mechs,
     load_unoptimized_parameters,
+    get_stochastic_args,
     get_synplas_morph_args,
     get_syn_mech_args,
 )
 from emodelrunner.morphology import create_morphology
+from emodelrunner.python_cell import PythonCellModel
 from emodelrunner.configuration import PackageType
 
 
@@ -41,6 +43,8 @@ def create_cell(
     syn_setup_params=None,
     v_init=-80,
     celsius=34,
+    instantiation="hoc",
+    stochastic_args=None,
 ):
     """Create a cell.
 
@@ -57,13 +61,26 @@ def create_cell(
             when using GluSynapseCustom
         v_init (int): initial voltage (mV)
         celsius (int): cell temperature (celsius)
+        instantiation (str): "hoc" to instantiate the cell with a hoc template,
+            or "python" to build it with the NEURON python API only
+        stochastic_args (dict): whether the stochastic channels are stochastic
+            in every protocol, and their seed. Deterministic if None.
+
+    Raises:
+        ValueError: if the instantiation is not supported
 
     Returns:
         CellModelCustom: cell model
     """
     # pylint: disable=too-many-arguments, too-many-locals
     # load mechanisms
-    mechs = load_mechanisms(unopt_params_path)
+    if stochastic_args is None:
+        stochastic_args = {}
+    mechs = load_mechanisms(
+        unopt_params_path,
+        stochastic=stochastic_args.get("stochastic", False),
+        channel_seed=stochastic_args.get("channel_seed", 0),
+    )
 
     # add synapses mechs
     if add_synapses:
@@ -75,6 +92,10 @@ def create_cell(
                 os.path.join(syn_mech_args["syn_dir"], syn_mech_args["syn_conf_file"]),
                 use_glu_synapse=use_glu_synapse,
                 syn_setup_params=syn_setup_params,
+                weight_scale=syn_mech_args.get("weight_scale", 1.0),
+                sonata_args=syn_mech_args.get("sonata_args"),
+                pre_mtypes=syn_mech_args.get("pre_mtypes"),
+                selection=syn_mech_args.get("selection"),
             )
         ]
 
@@ -82,7 +103,13 @@ def create_cell(
     params = load_unoptimized_parameters(unopt_params_path, v_init, celsius)
 
     # create cell
-    cell = CellModelCustom(
+    if instantiation == "hoc":
+        cell_class = CellModelCustom
+    elif instantiation == "python":
+        cell_class = PythonCellModel
+    else:
+        raise ValueError(f"unsupported cell instantiation: {instantiation}")
+    cell = cell_class(
         name=emodel,
         morph=morph,
         mechs=mechs,
@@ -114,7 +141,11 @@ def create_cell_using_config(config):
     syn_mech_args = get_syn_mech_args(config)
 
     # get morphology config data
-    if config.package_type in [PackageType.sscx, PackageType.thalamus]:
+    if config.package_type in [
+        PackageType.sscx,
+        PackageType.thalamus,
+        PackageType.hippocampus,
+    ]:
         morph = create_morphology(get_morph_args(config), config.package_type)
     else:
         raise ValueError(f"unsupported package type: {config.package_type}")
@@ -128,6 +159,8 @@ def create_cell_using_config(config):
         syn_mech_args,
         v_init=config.getfloat("Cell", "v_init"),
         celsius=config.getfloat("Cell", "celsius"),
+        instantiation=config.get("Cell", "instantiation"),
+        stochastic_args=get_stochastic_args(config),
     )
 
 
@@ -14,15 +14,20 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
+import copy
 import json
+import logging
 import os
 import shutil
+from pathlib import Path
 
+from emodelrunner.configuration import PackageType
 from emodelrunner.load import (
     load_config,
     get_release_params,
     get_syn_mech_args,
     get_hoc_paths_args,
+    get_morph_path,
 )
 from emodelrunner.create_cells import create_cell_using_config
 from emodelrunner.create_hoc_tools import (
@@ -32,6 +37,17 @@ from emodelrunner.create_hoc_tools import (
     create_main_protocol_hoc,
 )
 from emodelrunner.parsing_utilities import get_parser_args, set_verbosity
+from emodelrunner.seeds import get_global_seed, seed_protocol_definitions
+
+logger = logging.getLogger(__name__)
+
+# script compiling the mechanisms and running the hoc simulation of a hoc package
+RUN_HOC_SCRIPT = """#!/bin/sh
+if [ ! -f "x86_64/special" ]; then
+    nrnivmodl mechanisms
+fi
+nrniv run.hoc
+"""
 
 
 def write_hoc(hoc_dir, hoc_file_name, hoc):
@@ -81,11 +97,13 @@ def write_hocs(
         )
 
 
-def get_hoc(config):
+def get_hoc(config, morph_path=None):
     """Return the hoc scripts as strings.
 
     Args:
         config (configparser.ConfigParser): configuration
+        morph_path (str): path to the morphology loaded by the hoc scripts.
+            The morphology of the config is used if None.
 
     Raises:
         KeyError: If no voltage_base feature is found for Rin in feature file
@@ -112,7 +130,7 @@ def get_hoc(config):
 
     constants_args = {
         "emodel": config.get("Cell", "emodel"),
-        "morph_path": config.get("Paths", "morph_path"),
+        "morph_path": get_morph_path(config) if morph_path is None else morph_path,
         "gid": config.getint("Cell", "gid"),
         "dt": config.getfloat("Sim", "dt"),
         "celsius": config.getfloat("Cell", "celsius"),
@@ -126,6 +144,8 @@ def get_hoc(config):
         protocol_definitions = json.load(protocol_file)
     if "__comment" in protocol_definitions:
         del protocol_definitions["__comment"]
+    # same seeds as in the python run
+    seed_protocol_definitions(protocol_definitions, get_global_seed(config))
 
     # handle MainProtocol case
     if "Main" in protocol_definitions.keys():
@@ -220,11 +240,76 @@ def copy_features_hoc(config):
     shutil.copy(features_original_path, features_new_path)
 
 
+def export_hoc_package(config, output_dir):
+    """Write a self-contained hoc package of the cell of a sscx config.
+
+    The package contains the hoc scripts, with the optimized parameter values
+    of the e-model, the morphology, the mod files, the synapse files when the synapses
+    are added, and a run_hoc.sh script compiling the mechanisms and running
+    the simulation, so that the cell can be run with NEURON only, without python.
+    The optimized parameters are also written in parameters.json.
+
+    Args:
+        config (configparser.ConfigParser): configuration of a sscx package
+        output_dir (str or Path): directory of the hoc package. Must not exist.
+
+    Raises:
+        ValueError: if the config is not a sscx config
+        FileExistsError: if output_dir already exists
+
+    Returns:
+        Path: directory of the hoc package
+    """
+    if config.package_type != PackageType.sscx:
+        raise ValueError("Only the sscx packages can be exported to hoc.")
+    output_dir = Path(output_dir)
+    if output_dir.exists():
+        raise FileExistsError(f"{output_dir} already exists.")
+
+    morph_path = Path(get_morph_path(config))
+    (output_dir / "morphology").mkdir(parents=True)
+    shutil.copy(morph_path, output_dir / "morphology")
+
+    (output_dir / "mechanisms").mkdir()
+    mechanisms_dir = Path(config.get("Paths", "memodel_dir")) / "mechanisms"
+    for mod_path in mechanisms_dir.glob("*.mod"):
+        shutil.copy(mod_path, output_dir / "mechanisms")
+
+    # the paths written in the hoc scripts are relative to the hoc package
+    hoc_config = copy.deepcopy(config)
+    hoc_config.set("Paths", "syn_dir_for_hoc", "synapses")
+    cell_hoc, syn_hoc, simul_hoc, run_hoc, main_protocol_hoc = get_hoc(
+        hoc_config, morph_path=f"morphology/{morph_path.name}"
+    )
+
+    hoc_paths = get_hoc_paths_args(hoc_config)
+    hoc_paths["hoc_dir"] = output_dir
+    hoc_paths["syn_dir"] = output_dir / "synapses"
+    if syn_hoc is not None:
+        shutil.copytree(config.get("Paths", "syn_dir"), hoc_paths["syn_dir"])
+    if main_protocol_hoc is not None:
+        shutil.copy(
+            config.get("Paths", "features_hoc_template_path"),
+            output_dir / config.get("Paths", "features_hoc_file"),
+        )
+    write_hocs(hoc_paths, cell_hoc, simul_hoc, run_hoc, syn_hoc, main_protocol_hoc)
+
+    with open(output_dir / "parameters.json", "w", encoding="utf-8") as params_file:
+        json.dump(get_release_params(config), params_file, indent=4)
+    (output_dir / "run_hoc.sh").write_text(RUN_HOC_SCRIPT, encoding="utf-8")
+    (output_dir / "hoc_recordings").mkdir()
+
+    logger.info("Hoc package written in %s", output_dir)
+    return output_dir
+
+
 if __name__ == "__main__":
     args = get_parser_args()
-    set_verbosity(args.verbosity)
+    set_verbosity(args.verbosity, args.log_level, args.log_file)
 
-    config_ = load_config(config_path=args.config_path)
+    config_ = load_config(
+        config_path=args.config_path, config_overrides=args.config_overrides
+    )
 
     cell_hoc_, syn_hoc_, simul_hoc_, run_hoc_, main_protocol_hoc_ = get_hoc(
         config=config_
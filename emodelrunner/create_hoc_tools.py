@@ -25,6 +25,24 @@ from bluepyopt.ephys.create_hoc import (
 )
 
 from emodelrunner import __version__
+from emodelrunner.locations import get_section_location_name
+from emodelrunner.protocols.protocols_func import parse_relative_amplitude
+
+
+def get_extra_rec_name(extra_rec):
+    """Return the name of an extra recording, used as hoc variable name.
+
+    Args:
+        extra_rec (dict): extra recording definition
+
+    Returns:
+        str: the name of the extra recording
+    """
+    if "name" not in extra_rec and extra_rec["type"] == "section":
+        return get_section_location_name(
+            extra_rec["sec_name"], extra_rec["sec_index"], extra_rec["comp_x"]
+        )
+    return extra_rec["name"]
 
 
 class HocStimuliCreator:
@@ -141,8 +159,8 @@ class HocStimuliCreator:
             extra_recs (list): list of dicts defining the extra recordings
         """
         for extra_rec in extra_recs:
-            name = extra_rec["name"]
-            seclist_name = extra_rec["seclist_name"]
+            name = get_extra_rec_name(extra_rec)
+            seclist_name = extra_rec.get("seclist_name")
             var = extra_rec["var"]
 
             if name not in self.extra_recs_vars.split(", "):
@@ -178,6 +196,16 @@ class HocStimuliCreator:
                         secref.sec {name}.record(&{var}(comp_x), 0.1)
                     """
 
+                elif extra_rec["type"] == "section":
+                    sec_name = extra_rec["sec_name"]
+                    sec_index = extra_rec["sec_index"]
+                    comp_x = extra_rec["comp_x"]
+
+                    self.extra_recs += f"""
+                        {name} = new Vector()
+                        cell.{sec_name}[{sec_index}] {name}.record(&{var}({comp_x}), 0.1)
+                    """
+
     @staticmethod
     def add_save_recordings_hoc(mtype, prot_name, prot):
         """Add this to the hoc file to save the recordings.
@@ -200,7 +228,7 @@ class HocStimuliCreator:
         if "extra_recordings" in prot:
             for extra_rec in prot["extra_recordings"]:
                 var = extra_rec["var"]
-                name = extra_rec["name"]
+                name = get_extra_rec_name(extra_rec)
                 save_recs += f"""
                     sprint(fpath.s, "hoc_recordings/{mtype}.{prot_name}.{name}.{var}.dat")
                     timevoltage = new Matrix(time.size(), 2)
@@ -229,8 +257,11 @@ class HocStimuliCreator:
             if i + 1 > self.max_steps:
                 self.max_steps = i + 1
 
+            thresh_perc = parse_relative_amplitude(step["amp"])
             if step["amp"] is None:
                 amp = f"{step['thresh_perc'] / 100.} * threshold_current"
+            elif thresh_perc is not None:
+                amp = f"{thresh_perc / 100.} * threshold_current"
             else:
                 amp = step["amp"]
 
@@ -487,9 +518,18 @@ def create_synapse_hoc(
         dt (float): timestep (ms)
         synapses_template_name (str): template name of the synapse class
 
+    Raises:
+        ValueError: if the synapses are read from a SONATA edges file
+
     Returns:
         str: hoc script with the synapse class template
     """
+    if syn_mech_args["syn_data_file"].endswith(".h5"):
+        raise ValueError(
+            "The hoc synapses can only be created from a synapses tsv file. "
+            "Write it with emodelrunner.sonata.write_synapses_tsv."
+        )
+
     # load template
     with open(template_path, "r", encoding="utf-8") as template_file:
         template = template_file.read()
@@ -16,11 +16,57 @@
 
 from bluepyopt import ephys
 
+from emodelrunner.recordings import RecordingCustom
 from emodelrunner.synapses.recordings import SynapseRecordingCustom
 
 
+def get_soma_recording(name, soma_loc, sampling=None):
+    """Return the recording of the soma voltage.
+
+    Args:
+        name (str): name of the recording
+        soma_loc (bluepyopt.ephys.locations.NrnSeclistCompLocation):
+            location of the soma
+        sampling (dict): interval between the recorded samples (record_dt, in ms)
+            or number of integration steps between them (record_every).
+            The voltage is recorded at every step if None or if both are None.
+
+    Returns:
+        bluepyopt.ephys.recordings.CompRecording: the soma recording
+    """
+    sampling = {
+        key: value for key, value in (sampling or {}).items() if value is not None
+    }
+    if sampling:
+        return RecordingCustom(name=name, location=soma_loc, variable="v", **sampling)
+    return ephys.recordings.CompRecording(name=name, location=soma_loc, variable="v")
+
+
+def get_synapse_recordings(syn_locs, synrecs, sampling=None):
+    """Return the recordings of the synapse variables.
+
+    Args:
+        syn_locs (list of bluepyopt.ephys.locations.NrnPointProcessLocation):
+            location of the synapses
+        synrecs (list of str): the synapse variables to record
+        sampling (dict): interval between the recorded samples (record_dt, in ms)
+            or number of integration steps between them (record_every).
+            The variables are recorded at every step if None or if both are None.
+
+    Returns:
+        list of SynapseRecordingCustom: the synapse recordings
+    """
+    return [
+        SynapseRecordingCustom(
+            name=synrec, location=syn_loc, variable=synrec, **(sampling or {})
+        )
+        for syn_loc in syn_locs
+        for synrec in synrecs
+    ]
+
+
 def get_pairsim_recordings(
-    soma_loc, syn_locs, synrecs, presyn_prot_name, postsyn_prot_name
+    soma_loc, syn_locs, synrecs, presyn_prot_name, postsyn_prot_name, sampling=None
 ):
     """Return the precell and the postcell recordings for a pair simulation.
 
@@ -32,6 +78,7 @@ def get_pairsim_recordings(
         synrecs (list of str): the extra synapse variables to record
         presyn_prot_name (str): presynaptic protocol name
         postsyn_prot_name (str): postsynaptic protocol name
+        sampling (dict): sampling of the recordings, see get_soma_recording
 
     Returns:
         a tuple containing
@@ -39,19 +86,8 @@ def get_pairsim_recordings(
         - list of recordings: presynaptic recordings
         - list of recordings: postsynaptic recordings
     """
-    presyn_rec = ephys.recordings.CompRecording(
-        name=presyn_prot_name, location=soma_loc, variable="v"
-    )
-    presyn_recs = [presyn_rec]
-    postsyn_rec = ephys.recordings.CompRecording(
-        name=postsyn_prot_name, location=soma_loc, variable="v"
-    )
-    postsyn_recs = [postsyn_rec]
-
-    for syn_loc in syn_locs:
-        for synrec in synrecs:
-            postsyn_recs.append(
-                SynapseRecordingCustom(name=synrec, location=syn_loc, variable=synrec)
-            )
+    presyn_recs = [get_soma_recording(presyn_prot_name, soma_loc, sampling)]
+    postsyn_recs = [get_soma_recording(postsyn_prot_name, soma_loc, sampling)]
+    postsyn_recs += get_synapse_recordings(syn_locs, synrecs, sampling)
 
     return (presyn_recs, postsyn_recs)
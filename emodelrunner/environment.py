@@ -0,0 +1,275 @@
+"""Setup of the simulation environment: NEURON check and cache of compiled mechanisms."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import hashlib
+import logging
+import os
+import platform
+import re
+import shutil
+import subprocess
+import sys
+from pathlib import Path
+
+logger = logging.getLogger(__name__)
+
+CACHE_ENV_VARIABLE = "EMODELRUNNER_CACHE"
+
+# run in the package directory, where NEURON loads the compiled mechanisms from
+VERIFY_MECHANISMS_SCRIPT = """
+import sys
+from neuron import h
+
+loaded = set()
+name = h.ref("")
+# 0 for density mechanisms, 1 for point processes and artificial cells
+for mech_kind in [0, 1]:
+    mech_type = h.MechanismType(mech_kind)
+    for idx in range(int(mech_type.count())):
+        mech_type.select(idx)
+        mech_type.selected(name)
+        loaded.add(name[0])
+missing = [mech_name for mech_name in sys.argv[1:] if mech_name not in loaded]
+print(" ".join(missing))
+sys.exit(1 if missing else 0)
+"""
+
+
+def get_cache_dir(cache_dir=None):
+    """Return the directory in which the compiled mechanisms are cached.
+
+    Args:
+        cache_dir (str): cache directory. If None, the EMODELRUNNER_CACHE environment
+            variable is used, and $XDG_CACHE_HOME/emodelrunner (or ~/.cache/emodelrunner)
+            if it is not set.
+
+    Returns:
+        Path: cache directory
+    """
+    if cache_dir is not None:
+        return Path(cache_dir)
+    if CACHE_ENV_VARIABLE in os.environ:
+        return Path(os.environ[CACHE_ENV_VARIABLE])
+    xdg_cache_dir = os.environ.get("XDG_CACHE_HOME", Path.home() / ".cache")
+    return Path(xdg_cache_dir) / "emodelrunner"
+
+
+def check_neuron():
+    """Check that NEURON can be imported and that nrnivmodl is available.
+
+    Raises:
+        RuntimeError: if NEURON or nrnivmodl is missing
+
+    Returns:
+        str: NEURON version
+    """
+    try:
+        # pylint: disable=import-outside-toplevel
+        import neuron
+    except ImportError as exc:
+        raise RuntimeError(
+            "NEURON cannot be imported. Install it with 'pip install NEURON'."
+        ) from exc
+
+    if shutil.which("nrnivmodl") is None:
+        raise RuntimeError("nrnivmodl is not found. Add the NEURON binaries to PATH.")
+
+    return neuron.__version__
+
+
+def get_mod_files(mechanisms_dir):
+    """Return the mod files of a directory.
+
+    Args:
+        mechanisms_dir (str or Path): directory containing the mod files
+
+    Raises:
+        FileNotFoundError: if there is no mod file in the directory
+
+    Returns:
+        list of Path: mod files, sorted by name
+    """
+    mod_files = sorted(Path(mechanisms_dir).glob("*.mod"))
+    if not mod_files:
+        raise FileNotFoundError(f"No mod file found in {mechanisms_dir}.")
+    return mod_files
+
+
+def get_mechanism_names(mod_files):
+    """Return the names of the mechanisms defined in mod files.
+
+    Args:
+        mod_files (list of Path): mod files
+
+    Returns:
+        list of str: names of the density mechanisms, point processes and artificial cells
+    """
+    pattern = re.compile(
+        r"^\s*(?:SUFFIX|POINT_PROCESS|ARTIFICIAL_CELL)\s+(\w+)", re.MULTILINE
+    )
+    names = []
+    for mod_file in mod_files:
+        names.extend(pattern.findall(mod_file.read_text(encoding="utf-8")))
+    return names
+
+
+def get_mechanisms_hash(mod_files, neuron_version, coreneuron=False):
+    """Return the cache key of mod files compiled with a NEURON version.
+
+    Args:
+        mod_files (list of Path): mod files
+        neuron_version (str): NEURON version
+        coreneuron (bool): whether the mechanisms are compiled for CoreNEURON
+
+    Returns:
+        str: hash of the NEURON version and of the names and contents of the mod files
+    """
+    digest = hashlib.sha256(neuron_version.encode())
+    if coreneuron:
+        digest.update(b"coreneuron")
+    for mod_file in mod_files:
+        digest.update(mod_file.name.encode())
+        digest.update(mod_file.read_bytes())
+    return digest.hexdigest()[:16]
+
+
+def compile_mechanisms_in_cache(
+    mod_files, cache_dir, neuron_version, coreneuron=False
+):
+    """Compile the mod files in the cache, unless they have already been compiled.
+
+    Args:
+        mod_files (list of Path): mod files
+        cache_dir (Path): cache directory
+        neuron_version (str): NEURON version
+        coreneuron (bool): whether to also compile the mechanisms for CoreNEURON
+
+    Raises:
+        RuntimeError: if nrnivmodl fails
+
+    Returns:
+        Path: directory containing the compiled mechanisms
+    """
+    mechanisms_hash = get_mechanisms_hash(mod_files, neuron_version, coreneuron)
+    build_dir = cache_dir / "mechanisms" / mechanisms_hash
+    # nrnivmodl names its output directory after the machine architecture
+    compiled_dir = build_dir / platform.machine()
+    if (compiled_dir / "special").is_file():
+        logger.info("Using the mechanisms compiled in %s", compiled_dir)
+        return compiled_dir
+
+    # remove the leftovers of an interrupted compilation
+    if build_dir.exists():
+        shutil.rmtree(build_dir)
+    mod_dir = build_dir / "mechanisms"
+    mod_dir.mkdir(parents=True)
+    for mod_file in mod_files:
+        shutil.copy(mod_file, mod_dir)
+
+    logger.info("Compiling %d mod files in %s", len(mod_files), build_dir)
+    command = ["nrnivmodl", "mechanisms"]
+    if coreneuron:
+        command.insert(1, "-coreneuron")
+    result = subprocess.run(
+        command,
+        cwd=build_dir,
+        capture_output=True,
+        text=True,
+        check=False,
+    )
+    logger.debug(result.stdout)
+    if result.returncode != 0 or not (compiled_dir / "special").is_file():
+        raise RuntimeError(
+            f"The compilation of the mechanisms failed:\n{result.stderr}"
+        )
+
+    return compiled_dir
+
+
+def link_compiled_mechanisms(compiled_dir, package_dir):
+    """Link the compiled mechanisms in the package directory, where NEURON loads them from.
+
+    Args:
+        compiled_dir (Path): directory containing the compiled mechanisms
+        package_dir (str or Path): cell package directory
+
+    Returns:
+        Path: the link
+    """
+    link_path = Path(package_dir) / compiled_dir.name
+    if link_path.is_symlink():
+        link_path.unlink()
+    elif link_path.exists():
+        logger.info("Replacing the mechanisms compiled in %s", link_path)
+        shutil.rmtree(link_path)
+    link_path.symlink_to(compiled_dir.resolve(), target_is_directory=True)
+    return link_path
+
+
+def verify_mechanisms(package_dir, mechanism_names):
+    """Check in a new process that NEURON loads the mechanisms in the package directory.
+
+    Args:
+        package_dir (str or Path): cell package directory
+        mechanism_names (list of str): names of the mechanisms that should be loaded
+
+    Raises:
+        RuntimeError: if some mechanisms are not loaded
+    """
+    result = subprocess.run(
+        [sys.executable, "-c", VERIFY_MECHANISMS_SCRIPT] + list(mechanism_names),
+        cwd=package_dir,
+        capture_output=True,
+        text=True,
+        check=False,
+    )
+    if result.returncode != 0:
+        raise RuntimeError(
+            f"NEURON does not load the mechanisms {result.stdout.strip()} "
+            f"in {package_dir}.\n{result.stderr}"
+        )
+
+
+def setup_environment(
+    package_dir=".", mechanisms_dir="mechanisms", cache_dir=None, coreneuron=False
+):
+    """Check NEURON, and compile the mechanisms of a cell package through the cache.
+
+    The compiled mechanisms are shared by the packages having the same mod files,
+    so that this is the only step needed to prepare e.g. a container image.
+
+    Args:
+        package_dir (str or Path): cell package directory
+        mechanisms_dir (str or Path): directory containing the mod files,
+            relative to package_dir
+        cache_dir (str): cache directory. See get_cache_dir for the default.
+        coreneuron (bool): whether to also compile the mechanisms for CoreNEURON
+
+    Returns:
+        Path: link to the compiled mechanisms in the package directory
+    """
+    neuron_version = check_neuron()
+    logger.info("NEURON %s found", neuron_version)
+
+    mod_files = get_mod_files(Path(package_dir) / mechanisms_dir)
+    compiled_dir = compile_mechanisms_in_cache(
+        mod_files, get_cache_dir(cache_dir), neuron_version, coreneuron
+    )
+    link_path = link_compiled_mechanisms(compiled_dir, package_dir)
+    verify_mechanisms(package_dir, get_mechanism_names(mod_files))
+    logger.info("Mechanisms loaded from %s", link_path)
+
+    return link_path
@@ -0,0 +1,137 @@
+"""BluePyOpt evaluator using the protocols and features of a config."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import logging
+
+from bluepyopt import ephys
+
+from emodelrunner.configuration import PackageType
+from emodelrunner.create_cells import create_cell_using_config
+from emodelrunner.features import define_efeatures
+from emodelrunner.load import get_prot_args
+from emodelrunner.protocols.create_protocols import ProtocolBuilder
+
+logger = logging.getLogger(__name__)
+
+
+def get_fitness_protocols(ephys_protocols):
+    """Return the protocols to be run by the evaluator.
+
+    Args:
+        ephys_protocols (bluepyopt.ephys.protocols.SequenceProtocol): the configured protocols
+
+    Returns:
+        dict: protocols by name
+    """
+    return {protocol.name: protocol for protocol in ephys_protocols.protocols}
+
+
+def get_all_subprotocols(fitness_protocols):
+    """Return the fitness protocols and all the protocols they contain.
+
+    Args:
+        fitness_protocols (dict): protocols by name
+
+    Returns:
+        dict: protocols and subprotocols by name
+    """
+    subprotocols = {}
+    for protocol in fitness_protocols.values():
+        subprotocols.update(protocol.subprotocols())
+    return subprotocols
+
+
+def define_fitness_calculator(efeatures):
+    """Define the fitness calculator, with one objective per efeature.
+
+    Args:
+        efeatures (dict): eFELFeatures by name
+
+    Returns:
+        bluepyopt.ephys.objectivescalculators.ObjectivesCalculator: fitness calculator
+    """
+    objectives = [
+        ephys.objectives.SingletonObjective(feature_name, feature)
+        for feature_name, feature in efeatures.items()
+    ]
+    return ephys.objectivescalculators.ObjectivesCalculator(objectives)
+
+
+def create_evaluator(config, isolate_protocols=False, timeout=None):
+    """Create a BluePyOpt evaluator from the protocols and features of a config.
+
+    The evaluator runs the same protocols as the run script, and scores the responses
+    with the features of the features file, so that the model can be re-optimized
+    without duplicating the protocol definitions.
+    Features of protocols that are not in the protocols file are skipped.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+        isolate_protocols (bool): whether to run each protocol in a separate process
+        timeout (float): duration in seconds after which an isolated run is stopped
+
+    Raises:
+        ValueError: if the package type is not supported
+
+    Returns:
+        bluepyopt.ephys.evaluators.CellEvaluator: evaluator whose parameters are
+            the non-frozen parameters of the cell model
+    """
+    cell = create_cell_using_config(config)
+
+    sim = ephys.simulators.NrnSimulator(
+        dt=config.getfloat("Sim", "dt"),
+        cvode_active=config.getboolean("Sim", "cvode_active"),
+    )
+
+    add_synapses = config.getboolean("Synapses", "add_synapses")
+    prot_args = get_prot_args(config)
+    if config.package_type == PackageType.sscx:
+        protocols = ProtocolBuilder.using_sscx_protocols(add_synapses, prot_args, cell)
+    elif config.package_type == PackageType.thalamus:
+        protocols = ProtocolBuilder.using_thalamus_protocols(
+            add_synapses, prot_args, cell
+        )
+    elif config.package_type == PackageType.hippocampus:
+        protocols = ProtocolBuilder.using_hippocampus_protocols(
+            add_synapses, prot_args, cell
+        )
+    else:
+        raise ValueError(f"unsupported package type: {config.package_type}")
+    fitness_protocols = get_fitness_protocols(protocols.get_ephys_protocols())
+
+    efeatures = define_efeatures(
+        get_all_subprotocols(fitness_protocols),
+        prot_args["features_path"],
+        prot_args["mtype"],
+        skip_missing=True,
+    )
+    if not efeatures:
+        logger.warning("No feature found for the configured protocols.")
+
+    param_names = [
+        param_name for param_name, param in cell.params.items() if not param.frozen
+    ]
+
+    return ephys.evaluators.CellEvaluator(
+        cell_model=cell,
+        param_names=param_names,
+        fitness_protocols=fitness_protocols,
+        fitness_calculator=define_fitness_calculator(efeatures),
+        isolate_protocols=isolate_protocols,
+        sim=sim,
+        timeout=timeout,
+    )
@@ -0,0 +1,395 @@
+"""Extracellular potential computed from the membrane currents of the cell."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import logging
+
+import numpy as np
+from bluepyopt import ephys
+
+from emodelrunner.units import UNITS, to_magnitude
+
+logger = logging.getLogger(__name__)
+
+EXTRACELLULAR_METHODS = ["linesource", "pointsource"]
+EXTRACELLULAR_SUFFIX = ".extracellular"
+
+
+def check_electrode_positions(electrode_positions):
+    """Return the electrode positions as a (n_electrodes, 3) array.
+
+    Args:
+        electrode_positions (list): x, y, z coordinates of each electrode (um)
+
+    Raises:
+        ValueError: if the positions are not a list of 3D coordinates
+
+    Returns:
+        numpy.ndarray: the electrode positions
+    """
+    positions = np.asarray(electrode_positions, dtype=float)
+    if positions.ndim != 2 or positions.shape[1] != 3 or len(positions) == 0:
+        raise ValueError(
+            "The electrode positions should be a non-empty list of [x, y, z] "
+            f"coordinates, got {electrode_positions}."
+        )
+    return positions
+
+
+def compute_extracellular_potential(transformation_matrix, membrane_currents):
+    """Compute the extracellular potential from the membrane currents.
+
+    Args:
+        transformation_matrix (numpy.ndarray): (n_electrodes, n_segments) matrix
+            of the electrode (mV / nA)
+        membrane_currents (numpy.ndarray): (n_segments, n_times) membrane currents (nA)
+
+    Raises:
+        ValueError: if the shapes of the arrays do not match
+
+    Returns:
+        numpy.ndarray: (n_electrodes, n_times) extracellular potential (mV)
+    """
+    if transformation_matrix.shape[1] != membrane_currents.shape[0]:
+        raise ValueError(
+            f"The electrode sees {transformation_matrix.shape[1]} segments, "
+            f"but the currents of {membrane_currents.shape[0]} segments were recorded."
+        )
+    return transformation_matrix @ membrane_currents
+
+
+def interpolate_segment_points(points, arc_lengths, nseg):
+    """Return the start and end points of the segments of a section.
+
+    Args:
+        points (numpy.ndarray): (n3d, 3) 3D points of the section (um)
+        arc_lengths (numpy.ndarray): arc length of each 3D point (um)
+        nseg (int): number of segments of the section
+
+    Returns:
+        tuple containing the (nseg, 3) start and end points of the segments (um)
+    """
+    bounds = np.linspace(0, arc_lengths[-1], nseg + 1)
+    coords = np.column_stack(
+        [np.interp(bounds, arc_lengths, points[:, axis]) for axis in range(3)]
+    )
+    return coords[:-1], coords[1:]
+
+
+def get_segment_geometry(sim, icell):
+    """Return the geometry of the segments of the instantiated cell.
+
+    The segments are in the order of the sections of the 'all' section list,
+    which is also the order of the recorded membrane currents.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        icell (neuron cell): cell instantiation in simulator
+
+    Returns:
+        tuple containing the (n_segments, 3) start and end points of the segments
+        and their (n_segments,) diameters (um)
+    """
+    # give 3D points to the sections without any, e.g. the replaced axon
+    sim.neuron.h.define_shape()
+
+    starts, ends, diameters = [], [], []
+    for section in icell.all:
+        n3d = int(section.n3d())
+        points = np.array(
+            [[section.x3d(i), section.y3d(i), section.z3d(i)] for i in range(n3d)]
+        )
+        arc_lengths = np.array([section.arc3d(i) for i in range(n3d)])
+        section_starts, section_ends = interpolate_segment_points(
+            points, arc_lengths, section.nseg
+        )
+        starts.append(section_starts)
+        ends.append(section_ends)
+        diameters += [seg.diam for seg in section]
+
+    return np.concatenate(starts), np.concatenate(ends), np.array(diameters)
+
+
+def point_source_matrix(electrode_positions, starts, ends, sigma, min_distances):
+    """Return the transformation matrix of the point-source approximation.
+
+    The current of each segment is put at the middle of the segment.
+
+    Args:
+        electrode_positions (numpy.ndarray): (n_electrodes, 3) electrode positions (um)
+        starts (numpy.ndarray): (n_segments, 3) start points of the segments (um)
+        ends (numpy.ndarray): (n_segments, 3) end points of the segments (um)
+        sigma (float): extracellular conductivity (S/m)
+        min_distances (numpy.ndarray): (n_segments,) minimal distance between
+            an electrode and each segment, usually its radius (um)
+
+    Returns:
+        numpy.ndarray: (n_electrodes, n_segments) transformation matrix (mV / nA)
+    """
+    midpoints = (starts + ends) / 2.0
+    distances = np.linalg.norm(
+        electrode_positions[:, np.newaxis, :] - midpoints[np.newaxis, :, :], axis=2
+    )
+    distances = np.maximum(distances, min_distances[np.newaxis, :])
+    # nA / (S/m * um) = mV
+    return 1.0 / (4.0 * np.pi * sigma * distances)
+
+
+def line_source_matrix(electrode_positions, starts, ends, sigma, min_distances):
+    """Return the transformation matrix of the line-source approximation.
+
+    The current of each segment is uniformly distributed along the segment.
+    The segments of zero length are treated as point sources.
+
+    Args:
+        electrode_positions (numpy.ndarray): (n_electrodes, 3) electrode positions (um)
+        starts (numpy.ndarray): (n_segments, 3) start points of the segments (um)
+        ends (numpy.ndarray): (n_segments, 3) end points of the segments (um)
+        sigma (float): extracellular conductivity (S/m)
+        min_distances (numpy.ndarray): (n_segments,) minimal distance between
+            an electrode and the axis of each segment, usually its radius (um)
+
+    Returns:
+        numpy.ndarray: (n_electrodes, n_segments) transformation matrix (mV / nA)
+    """
+    axes = ends - starts
+    lengths = np.linalg.norm(axes, axis=1)
+    has_length = lengths > 0
+    unit_axes = np.zeros_like(axes)
+    unit_axes[has_length] = axes[has_length] / lengths[has_length, np.newaxis]
+
+    relative_positions = electrode_positions[:, np.newaxis, :] - starts[np.newaxis]
+    # distance from the start of the segment along its axis, and to its axis
+    longitudinal = np.einsum("esk,sk->es", relative_positions, unit_axes)
+    perpendicular = np.sqrt(
+        np.maximum(np.sum(relative_positions**2, axis=2) - longitudinal**2, 0)
+    )
+    perpendicular = np.maximum(perpendicular, min_distances[np.newaxis, :])
+
+    matrix = point_source_matrix(
+        electrode_positions, starts, ends, sigma, min_distances
+    )
+    # integral of 1 / distance along the segment, divided by its length
+    integral = np.arcsinh(longitudinal / perpendicular) - np.arcsinh(
+        (longitudinal - lengths[np.newaxis, :]) / perpendicular
+    )
+    matrix[:, has_length] = integral[:, has_length] / (
+        4.0 * np.pi * sigma * lengths[np.newaxis, has_length]
+    )
+    return matrix
+
+
+class ExtracellularRecording(ephys.recordings.Recording):
+    """Extracellular potential at electrode sites.
+
+    The membrane currents of all the segments of the cell are recorded
+    at each time step, and multiplied by the transformation matrix
+    of the electrodes, computed from the geometry of the instantiated cell
+    in an infinite homogeneous medium.
+
+    Attributes:
+        name (str): name of this object
+        electrode_positions (numpy.ndarray): x, y, z coordinates of the electrodes (um)
+        sigma (float): extracellular conductivity (S/m)
+        method (str): method used to compute the potential
+        transformation_matrix (numpy.ndarray): (n_electrodes, n_segments) matrix
+            of the electrodes (mV / nA)
+        imem_vectors (list of neuron Vector): vectors recording the membrane currents
+        tvector (neuron Vector): vector recording the time (ms)
+        instantiated (bool): whether the object has been instantiated or not
+    """
+
+    methods = EXTRACELLULAR_METHODS
+
+    def __init__(
+        self, name=None, electrode_positions=None, sigma=0.3, method="linesource"
+    ):
+        """Constructor.
+
+        Args:
+            name (str): name of this object
+            electrode_positions (list or pint.Quantity): x, y, z coordinates
+                of each electrode (um)
+            sigma (float or pint.Quantity): extracellular conductivity (S/m)
+            method (str): method used to compute the potential
+
+        Raises:
+            ValueError: if the method is not supported
+        """
+        super().__init__(name=name)
+        if method not in self.methods:
+            raise ValueError(
+                f"Unsupported method: {method}. Choose from {self.methods}."
+            )
+
+        self.electrode_positions = check_electrode_positions(
+            to_magnitude(electrode_positions, UNITS["positions"])
+        )
+        self.sigma = to_magnitude(sigma, UNITS["conductivity"])
+        self.method = method
+
+        self.transformation_matrix = None
+        self.imem_vectors = None
+        self.tvector = None
+        self.instantiated = False
+
+    def get_transformation_matrix(self, sim, icell):
+        """Compute the transformation matrix of the electrodes for the instantiated cell
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            icell (neuron cell): cell instantiation in simulator
+
+        Returns:
+            numpy.ndarray: (n_electrodes, n_segments) matrix of the electrodes (mV / nA)
+        """
+        starts, ends, diameters = get_segment_geometry(sim, icell)
+        source_matrix = (
+            line_source_matrix if self.method == "linesource" else point_source_matrix
+        )
+        return source_matrix(
+            self.electrode_positions, starts, ends, self.sigma, diameters / 2.0
+        )
+
+    def instantiate(self, sim=None, icell=None):
+        """Instantiate recording.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            icell (neuron cell): cell instantiation in simulator
+        """
+        logger.debug(
+            "Adding extracellular recording at %d electrodes",
+            len(self.electrode_positions),
+        )
+
+        self.transformation_matrix = self.get_transformation_matrix(sim, icell)
+
+        # i_membrane_ is only available with fast_imem
+        sim.neuron.h.CVode().use_fast_imem(1)
+        # same segment order as the transformation matrix
+        self.imem_vectors = []
+        for section in icell.all:
+            for seg in section:
+                vector = sim.neuron.h.Vector()
+                vector.record(seg._ref_i_membrane_)  # pylint: disable=protected-access
+                self.imem_vectors.append(vector)
+
+        self.tvector = sim.neuron.h.Vector()
+        self.tvector.record(sim.neuron.h._ref_t)  # pylint: disable=protected-access
+
+        self.instantiated = True
+
+    @property
+    def response(self):
+        """Return the extracellular potential.
+
+        Returns:
+            dict containing the time (ms), the electrode positions (um)
+            and the (n_electrodes, n_times) extracellular potential (mV)
+        """
+        if not self.instantiated:
+            return None
+
+        membrane_currents = np.array([np.array(vector) for vector in self.imem_vectors])
+        return {
+            "time": np.array(self.tvector),
+            "positions": self.electrode_positions,
+            "potential": compute_extracellular_potential(
+                self.transformation_matrix, membrane_currents
+            ),
+        }
+
+    def destroy(self, sim=None):
+        """Destroy recording.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        """
+        # pylint: disable=unused-argument
+        self.imem_vectors = None
+        self.tvector = None
+        self.transformation_matrix = None
+        self.instantiated = False
+
+    def __str__(self):
+        """String representation."""
+        return (
+            f"{self.name}: extracellular potential "
+            f"at {len(self.electrode_positions)} electrodes"
+        )
+
+
+def add_extracellular_recording(ephys_protocols, protocol_name, recording):
+    """Add an extracellular recording to one of the protocols.
+
+    Args:
+        ephys_protocols (bluepyopt.ephys.protocols.SequenceProtocol): the protocols
+        protocol_name (str): name of the protocol to record from
+        recording (ExtracellularRecording): the recording to add
+
+    Raises:
+        ValueError: if the protocol does not exist or has no recordings
+    """
+    subprotocols = ephys_protocols.subprotocols()
+    if protocol_name not in subprotocols:
+        raise ValueError(
+            f"Protocol {protocol_name} not found. Choose from {list(subprotocols)}."
+        )
+    protocol = subprotocols[protocol_name]
+    if not hasattr(protocol, "recordings"):
+        raise ValueError(
+            f"Protocol {protocol_name} has no recordings. "
+            "Choose one of its subprotocols instead."
+        )
+    protocol.recordings.append(recording)
+
+
+def add_extracellular_recordings(ephys_protocols, extracellular_args, prefix=""):
+    """Add the extracellular recordings of the configuration to the protocols.
+
+    Args:
+        ephys_protocols (bluepyopt.ephys.protocols.SequenceProtocol): the protocols
+        extracellular_args (dict): extracellular configuration data
+            See load.get_extracellular_args for details
+        prefix (str): prefix used in naming responses, features, recordings, etc.
+
+    Returns:
+        list of str: names of the added recordings
+    """
+    names = []
+    for protocol_name in extracellular_args["protocols"]:
+        recording = ExtracellularRecording(
+            name=f"{prefix}.{protocol_name}{EXTRACELLULAR_SUFFIX}",
+            electrode_positions=extracellular_args["electrode_positions"],
+            sigma=extracellular_args["sigma"],
+            method=extracellular_args["method"],
+        )
+        add_extracellular_recording(ephys_protocols, protocol_name, recording)
+        names.append(recording.name)
+    return names
+
+
+def pop_extracellular_responses(responses):
+    """Remove the extracellular responses from the responses and return them.
+
+    Args:
+        responses (dict): responses of the protocols, keyed by recording name
+
+    Returns:
+        dict: extracellular responses, keyed by recording name
+    """
+    names = [name for name in responses if name.endswith(EXTRACELLULAR_SUFFIX)]
+    return {name: responses.pop(name) for name in names}
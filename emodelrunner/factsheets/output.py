@@ -19,11 +19,23 @@ import logging
 from pathlib import Path
 import numpy as np
 
+from emodelrunner.configuration import PackageType
 from emodelrunner.json_utilities import NpEncoder
-from emodelrunner.factsheets.morphology_features import SSCXMorphologyFactsheetBuilder
-from emodelrunner.factsheets.physiology_features import physiology_factsheet_info
+from emodelrunner.factsheets.morphology_features import (
+    HippocampusMorphologyFactsheetBuilder,
+    SSCXMorphologyFactsheetBuilder,
+    ThalamusMorphologyFactsheetBuilder,
+)
+from emodelrunner.factsheets.physiology_features import (
+    burst_factsheet_info,
+    impedance_factsheet_info,
+    latency_factsheet_info,
+    physiology_factsheet_info,
+    subthreshold_factsheet_info,
+)
 from emodelrunner.factsheets.experimental_features import get_exp_features_data
 from emodelrunner.factsheets.ion_channel_mechanisms import get_mechanisms_data
+from emodelrunner.factsheets.target_comparison import target_comparison_factsheet_info
 
 logger = logging.getLogger(__name__)
 
@@ -35,10 +47,17 @@ def write_metype_json(
     stim_duration,
     morphology_path,
     output_path,
+    latency_curve=None,
+    subthreshold=None,
+    impedance=None,
+    bursts=None,
+    morph_factsheet_builder_class=SSCXMorphologyFactsheetBuilder,
 ):
-    """Write the me-type factsheet json file of SSCX packages.
+    """Write the me-type factsheet json file of SSCX, thalamus or hippocampus packages.
 
-    The output metype factsheet contains anatomy, physiology and morphology data.
+    The output metype factsheet contains anatomy, physiology and morphology data,
+    and the first spike latency curve, the subthreshold features,
+    the impedance profiles and the burst features if given.
 
     Args:
         data_path (str): path to the trace data (usually output of emodelrunner run)
@@ -47,14 +66,24 @@ def write_metype_json(
         stim_duration (float): stimulus duration (ms)
         morphology_path (str or Path): Path to the morphology file.
         output_path (str): path to the metype factsheet output
+        latency_curve (dict): first spike latency versus step amplitude,
+            as found in the run summary
+        subthreshold (dict): features of each subthreshold protocol,
+            as found in the run summary
+        impedance (dict): ZAP analysis of each chirp protocol,
+            as found in the run summary
+        bursts (dict): features of each burst protocol, as found in the run summary
+        morph_factsheet_builder_class (class): morphology factsheet builder
+            of the package, e.g. HippocampusMorphologyFactsheetBuilder
     """
+    # pylint: disable=too-many-arguments
     morphology_path = Path(morphology_path)
     output_path = Path(output_path)
     output_path.parent.mkdir(parents=True, exist_ok=True)
     # load time, voltage
     data = np.loadtxt(data_path)
 
-    morph_factsheet_builder = SSCXMorphologyFactsheetBuilder(morph_path=morphology_path)
+    morph_factsheet_builder = morph_factsheet_builder_class(morph_path=morphology_path)
     anatomy = morph_factsheet_builder.factsheet_dict()
 
     physiology = physiology_factsheet_info(
@@ -67,6 +96,14 @@ def write_metype_json(
     morphology = {"name": "Morphology name", "value": morphology_path.stem}
 
     output = [anatomy, physiology, morphology]
+    if latency_curve is not None:
+        output.append(latency_factsheet_info(latency_curve))
+    for protocol_name, properties in (subthreshold or {}).items():
+        output.append(subthreshold_factsheet_info(protocol_name, properties))
+    for protocol_name, analysis in (impedance or {}).items():
+        output.append(impedance_factsheet_info(protocol_name, analysis))
+    for protocol_name, burst in (bursts or {}).items():
+        output.append(burst_factsheet_info(protocol_name, burst))
 
     with open(output_path, "w", encoding="utf-8") as out_file:
         json.dump(output, out_file, indent=4, cls=NpEncoder)
@@ -74,7 +111,15 @@ def write_metype_json(
 
 
 def write_etype_factsheet(
-    data_path, current_amplitude, stim_start, stim_duration, output_path
+    data_path,
+    current_amplitude,
+    stim_start,
+    stim_duration,
+    output_path,
+    latency_curve=None,
+    subthreshold=None,
+    impedance=None,
+    bursts=None,
 ):
     """Write the e-type factsheet json file.
 
@@ -84,7 +129,15 @@ def write_etype_factsheet(
         stim_start (float): time at which the stimulus begins (ms)
         stim_duration (float): stimulus duration (ms)
         output_path (str): path to the etype factsheet output
+        latency_curve (dict): first spike latency versus step amplitude,
+            as found in the run summary
+        subthreshold (dict): features of each subthreshold protocol,
+            as found in the run summary
+        impedance (dict): ZAP analysis of each chirp protocol,
+            as found in the run summary
+        bursts (dict): features of each burst protocol, as found in the run summary
     """
+    # pylint: disable=too-many-arguments
     data = np.loadtxt(data_path)
 
     physiology = physiology_factsheet_info(
@@ -95,10 +148,22 @@ def write_etype_factsheet(
         stim_duration=stim_duration,
     )
 
+    output = [physiology]
+    if latency_curve is not None:
+        output.append(latency_factsheet_info(latency_curve))
+    for protocol_name, properties in (subthreshold or {}).items():
+        output.append(subthreshold_factsheet_info(protocol_name, properties))
+    for protocol_name, analysis in (impedance or {}).items():
+        output.append(impedance_factsheet_info(protocol_name, analysis))
+    for protocol_name, burst in (bursts or {}).items():
+        output.append(burst_factsheet_info(protocol_name, burst))
+    if len(output) == 1:
+        output = physiology
+
     output_path = Path(output_path)
     output_path.parent.mkdir(parents=True, exist_ok=True)
     with open(output_path, "w", encoding="utf-8") as out_file:
-        json.dump(physiology, out_file, indent=4, cls=NpEncoder)
+        json.dump(output, out_file, indent=4, cls=NpEncoder)
 
 
 def get_stim_params_from_config_for_physiology_factsheet(prot_path, protocol_key):
@@ -144,12 +209,20 @@ def write_metype_json_from_config(
 ):
     """Write the me-type factsheet json file from config input.
 
+    The first spike latency curve, the subthreshold features, the impedance profiles
+    and the burst features are added if they are found in the summary of the run.
+    The amplitude of a step set by the Main protocol, e.g. in thalamus packages,
+    is read from the latency curve of the run summary.
+
     Args:
         config (configparser.ConfigParser): configuration
         voltage_path (str): path to the trace data (usually output of emodelrunner run)
         morphology_path (str): Path to the morphology file.
         output_path (str): path to the metype factsheet output
         protocol_key (str): name of the protocol used for physiology features extraction
+
+    Raises:
+        ValueError: if the step amplitude is neither in the protocol nor in the summary
     """
     # get protocol data
     prot_path = config.get("Paths", "prot_path")
@@ -159,6 +232,30 @@ def write_metype_json_from_config(
     )
     current_amplitude, stim_start, stim_duration = stim_params
 
+    summary = {}
+    summary_path = Path(config.get("Paths", "output_dir")) / "summary.json"
+    if summary_path.is_file():
+        with open(summary_path, "r", encoding="utf-8") as summary_file:
+            summary = json.load(summary_file)
+
+    if current_amplitude is None:
+        latency_curve = summary.get("latency_curve", {})
+        if protocol_key in latency_curve.get("protocol", []):
+            idx = latency_curve["protocol"].index(protocol_key)
+            current_amplitude = latency_curve["step_amplitude"][idx]
+        else:
+            raise ValueError(
+                f"The step amplitude of {protocol_key} is set by the Main protocol "
+                f"and is not in {summary_path}. Run the config first."
+            )
+
+    if config.package_type == PackageType.thalamus:
+        morph_factsheet_builder_class = ThalamusMorphologyFactsheetBuilder
+    elif config.package_type == PackageType.hippocampus:
+        morph_factsheet_builder_class = HippocampusMorphologyFactsheetBuilder
+    else:
+        morph_factsheet_builder_class = SSCXMorphologyFactsheetBuilder
+
     write_metype_json(
         voltage_path,
         current_amplitude,
@@ -166,6 +263,11 @@ def write_metype_json_from_config(
         stim_duration,
         morphology_path,
         output_path,
+        summary.get("latency_curve"),
+        summary.get("subthreshold"),
+        summary.get("impedance"),
+        summary.get("bursts"),
+        morph_factsheet_builder_class,
     )
 
 
@@ -177,10 +279,13 @@ def write_emodel_json(
     unoptimized_params_dict,
     optimized_params_dict,
     output_path,
+    target_comparison=None,
 ):
     """Write the e-model factsheet json file.
 
-    The output metype factsheet contains experimental features and channel mechanisms data.
+    The output metype factsheet contains experimental features and channel mechanisms
+    data, and the comparison of the model features with the experimental features
+    if given.
 
     Args:
         emodel (str): name of the emodel
@@ -192,6 +297,8 @@ def write_emodel_json(
         optimized_params_dict (dict): contains the optimized parameters,
             as well as the original morphology path
         output_path (str): path to the e-model factsheet output
+        target_comparison (dict): z-scores of the model features with respect to
+            the experimental features, as written in target_comparison.json by the run
     """
     output_path = Path(output_path)
     output_path.parent.mkdir(parents=True, exist_ok=True)
@@ -210,6 +317,8 @@ def write_emodel_json(
         exp_features,
         channel_mechanisms,
     ]
+    if target_comparison is not None:
+        output.append(target_comparison_factsheet_info(target_comparison))
 
     with open(output_path, "w", encoding="utf-8") as out_file:
         json.dump(output, out_file, indent=4, cls=NpEncoder)
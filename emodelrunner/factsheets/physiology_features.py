@@ -105,3 +105,199 @@ def physiology_factsheet_info(
     )
     factsheet_info = physiology_features_wrapper(voltage_base, input_resistance, dct)
     return {"name": "Physiology", "values": factsheet_info}
+
+
+def latency_factsheet_info(latency_curve):
+    """Provides the first spike latency versus step amplitude curve for the factsheet.
+
+    Args:
+        latency_curve (dict): contains the 'step_amplitude' (nA)
+            and the first spike 'latency' (ms) of each step protocol
+
+    Returns:
+        dict containing the latency data
+    """
+    values = [
+        {
+            "name": "latency to first spike",
+            "step_amplitude": amplitude,
+            "value": latency,
+            "unit": "ms",
+        }
+        for amplitude, latency in zip(
+            latency_curve["step_amplitude"], latency_curve["latency"]
+        )
+    ]
+    return {"name": "Latency", "values": values}
+
+
+def subthreshold_factsheet_info(protocol_name, subthreshold):
+    """Provides the features of a subthreshold protocol for the factsheet.
+
+    Args:
+        protocol_name (str): name of the subthreshold protocol
+        subthreshold (dict): contains the 'step_amplitude' (nA) of each step
+            and the 'mean' input resistance, time constant and sag ratio of the steps
+
+    Returns:
+        dict containing the subthreshold data
+    """
+    mean = subthreshold["mean"]
+    values = [
+        {
+            "name": "input resistance",
+            "value": mean["input_resistance"],
+            "unit": "MOhm",
+        },
+        {
+            "name": "membrane time constant",
+            "value": mean["time_constant"],
+            "unit": "ms",
+        },
+        {"name": "sag ratio", "value": mean["sag_ratio"], "unit": ""},
+    ]
+    return {
+        "name": "Subthreshold",
+        "protocol": protocol_name,
+        "step_amplitudes": subthreshold["step_amplitude"],
+        "values": values,
+    }
+
+
+def burst_factsheet_info(protocol_name, burst):
+    """Provides the features of a burst protocol for the factsheet.
+
+    Args:
+        protocol_name (str): name of the burst protocol
+        burst (dict): contains the 'type' of burst and its features,
+            see bursts.get_burst_features
+
+    Returns:
+        dict containing the burst data
+    """
+    values = [
+        {
+            "name": "hyperpolarized voltage",
+            "value": burst["hyperpolarized_voltage"],
+            "unit": "mV",
+        },
+        {"name": "burst latency", "value": burst["burst_latency"], "unit": "ms"},
+        {
+            "name": "number of spikes in burst",
+            "value": burst["burst_n_spikes"],
+            "unit": "",
+        },
+        {"name": "burst duration", "value": burst["burst_duration"], "unit": "ms"},
+        {
+            "name": "intraburst frequency",
+            "value": burst["intraburst_frequency"],
+            "unit": "Hz",
+        },
+    ]
+    name = "Rebound burst" if burst["type"] == "rebound" else "Low-threshold burst"
+    return {"name": name, "protocol": protocol_name, "values": values}
+
+
+def impedance_factsheet_info(protocol_name, impedance):
+    """Provides the impedance profile and resonance of a chirp protocol for factsheets.
+
+    Args:
+        protocol_name (str): name of the chirp protocol
+        impedance (dict): contains the impedance 'profile', the 'resonance_frequency'
+            (Hz), the 'max_impedance' (MOhm) and the 'Q_factor' of the protocol
+
+    Returns:
+        dict containing the impedance data
+    """
+    values = [
+        {
+            "name": "resonance frequency",
+            "value": impedance["resonance_frequency"],
+            "unit": "Hz",
+        },
+        {
+            "name": "maximum impedance",
+            "value": impedance["max_impedance"],
+            "unit": "MOhm",
+        },
+        {"name": "Q-factor", "value": impedance["Q_factor"], "unit": ""},
+    ]
+    return {
+        "name": "Impedance",
+        "protocol": protocol_name,
+        "profile": {
+            "frequency": impedance["profile"]["frequency"],
+            "impedance": impedance["profile"]["impedance"],
+            "units": {"frequency": "Hz", "impedance": "MOhm"},
+        },
+        "values": values,
+    }
+
+
+def extract_step_features(time, voltage, step_amplitude, stim_start, stim_duration):
+    """Extract the spike count, input resistance and AP half-width of a step response.
+
+    Args:
+        time (list): time corresponding to the voltage data of the trace (ms)
+        voltage (list): voltage data of the trace (mV)
+        step_amplitude (float): current amplitude of the step stimulus (nA)
+        stim_start (float): time at which the step begins (ms)
+        stim_duration (float): step duration (ms)
+
+    Returns:
+        dict containing the 'spike_count', the 'input_resistance' (MOhm)
+        and the mean 'AP_half_width' (ms). The input resistance is None
+        if the cell spikes or if the step amplitude is 0,
+        and the AP half-width is None if the cell does not spike.
+    """
+    trace = {}
+    trace["T"] = time
+    trace["V"] = voltage
+    trace["stim_start"] = [stim_start]
+    trace["stim_end"] = [stim_start + stim_duration]
+
+    efel_results = efel.getFeatureValues(
+        [trace], ["Spikecount_stimint", "AP_duration_half_width"]
+    )[0]
+
+    spike_count = 0
+    if efel_results["Spikecount_stimint"] is not None:
+        spike_count = int(efel_results["Spikecount_stimint"][0])
+
+    ap_half_width = None
+    if spike_count > 0 and efel_results["AP_duration_half_width"] is not None:
+        ap_half_width = float(efel_results["AP_duration_half_width"].mean())
+
+    input_resistance = None
+    if spike_count == 0 and step_amplitude != 0:
+        trace["stimulus_current"] = [step_amplitude]
+        efel_results = efel.getFeatureValues(
+            [trace], ["ohmic_input_resistance_vb_ssse"]
+        )[0]
+        if efel_results["ohmic_input_resistance_vb_ssse"] is not None:
+            input_resistance = float(efel_results["ohmic_input_resistance_vb_ssse"][0])
+
+    return {
+        "spike_count": spike_count,
+        "input_resistance": input_resistance,
+        "AP_half_width": ap_half_width,
+    }
+
+
+def estimate_rheobase(spiking_steps, silent_steps):
+    """Bracket the rheobase from the step amplitudes that made the cell spike or not.
+
+    Args:
+        spiking_steps (list of floats): step amplitudes that made the cell spike (nA)
+        silent_steps (list of floats): step amplitudes that did not make the cell spike (nA)
+
+    Returns:
+        a tuple containing
+
+        - float: highest step amplitude (nA) below the rheobase, or None if unknown
+        - float: lowest step amplitude (nA) above the rheobase, or None if unknown
+    """
+    upper = min(spiking_steps) if spiking_steps else None
+    below = [step for step in silent_steps if upper is None or step < upper]
+    lower = max(below) if below else None
+    return lower, upper
@@ -0,0 +1,151 @@
+"""Comparison of the model features with the experimental targets."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import logging
+from pathlib import Path
+
+from emodelrunner.json_utilities import NpEncoder
+from emodelrunner.results import parse_output_filename
+
+logger = logging.getLogger(__name__)
+
+
+def get_zscore(value, mean, std):
+    """Return the signed distance of a model value to the target, in stds.
+
+    Args:
+        value (float): model value, None if it could not be computed
+        mean (float): experimental mean
+        std (float): experimental standard deviation
+
+    Returns:
+        float: z-score, None if the value is None or if the std is 0
+    """
+    if value is None or not std:
+        return None
+    return (value - mean) / std
+
+
+def compare_feature(feature, responses, max_zscore=3.0):
+    """Compare the model value of a feature with its experimental target.
+
+    Args:
+        feature (bluepyopt.ephys.efeatures.eFELFeature): feature with
+            its experimental mean and std
+        responses (dict): responses of the protocols
+        max_zscore (float): maximum absolute z-score of a passing feature
+
+    Returns:
+        dict: protocol, location and name of the feature, experimental mean and std,
+            model value, z-score and whether the feature passes
+    """
+    metadata = parse_output_filename(feature.recording_names[""])
+    value = feature.calculate_feature(responses)
+    if value is not None:
+        value = float(value)
+    zscore = get_zscore(value, feature.exp_mean, feature.exp_std)
+    if zscore is None:
+        passed = value is not None and value == feature.exp_mean
+    else:
+        passed = abs(zscore) <= max_zscore
+
+    return {
+        "protocol": metadata["protocol"],
+        "location": f"{metadata['location']}.{metadata['variable']}",
+        "feature": feature.efel_feature_name,
+        "mean": feature.exp_mean,
+        "std": feature.exp_std,
+        "model value": value,
+        "z-score": zscore,
+        "passed": passed,
+    }
+
+
+def compare_to_targets(efeatures, responses, max_zscore=3.0):
+    """Compare the model features with the experimental targets of the optimization.
+
+    Args:
+        efeatures (dict): eFELFeatures by name, as defined from the features file
+        responses (dict): responses of the protocols
+        max_zscore (float): maximum absolute z-score of a passing feature
+
+    Returns:
+        dict: the comparison of each feature and a pass/fail summary
+    """
+    features = [
+        compare_feature(feature, responses, max_zscore)
+        for feature in efeatures.values()
+    ]
+    failed = [feature for feature in features if not feature["passed"]]
+    zscores = [
+        abs(feature["z-score"])
+        for feature in features
+        if feature["z-score"] is not None
+    ]
+
+    return {
+        "max z-score": max_zscore,
+        "summary": {
+            "n_features": len(features),
+            "n_passed": len(features) - len(failed),
+            "n_failed": len(failed),
+            "max_abs_zscore": max(zscores, default=None),
+            "failed": [
+                ".".join((feature["protocol"], feature["location"], feature["feature"]))
+                for feature in failed
+            ],
+        },
+        "features": features,
+    }
+
+
+def target_comparison_factsheet_info(comparison):
+    """Return the comparison with the experimental targets as a factsheet entry.
+
+    Args:
+        comparison (dict): comparison, as returned by compare_to_targets
+
+    Returns:
+        dict containing the name, the z-score table and the pass/fail summary
+    """
+    return {
+        "name": "Comparison with the experimental features",
+        "max z-score": comparison["max z-score"],
+        "summary": comparison["summary"],
+        "values": comparison["features"],
+    }
+
+
+def write_target_comparison(comparison, output_dir, filename="target_comparison.json"):
+    """Write the comparison with the experimental targets.
+
+    Args:
+        comparison (dict): comparison, as returned by compare_to_targets
+        output_dir (str): path to the output repository
+        filename (str): name of the json file
+    """
+    output_path = Path(output_dir) / filename
+    with open(output_path, "w", encoding="utf-8") as out_file:
+        json.dump(comparison, out_file, indent=4, cls=NpEncoder)
+
+    summary = comparison["summary"]
+    logger.info(
+        "%s of the %s features are within %s stds of the experimental mean.",
+        summary["n_passed"],
+        summary["n_features"],
+        comparison["max z-score"],
+    )
@@ -17,6 +17,8 @@
 import json
 import logging
 
+import efel
+import numpy as np
 from bluepyopt.ephys.efeatures import eFELFeature
 
 logger = logging.getLogger(__name__)
@@ -99,13 +101,15 @@ def get_feature(
     return feature_name, feature
 
 
-def define_efeatures(main_protocol, features_path, prefix=""):
+def define_efeatures(main_protocol, features_path, prefix="", skip_missing=False):
     """Define the efeatures.
 
     Args:
         main_protocol (ephys.protocols.Protocol): Main Protocol containing all the protocols
         features_path (str): path to features file
         prefix (str): prefix used in naming responses, features, recordings, etc.
+        skip_missing (bool): set to True to skip the features of the protocols
+            that are not in main_protocol instead of raising a KeyError
 
     Returns:
         dict: efeatures
@@ -116,9 +120,19 @@ def define_efeatures(main_protocol, features_path, prefix=""):
     if "__comment" in feature_definitions:
         del feature_definitions["__comment"]
 
+    if hasattr(main_protocol, "subprotocols"):
+        protocol_names = main_protocol.subprotocols().keys()
+    else:
+        protocol_names = main_protocol.keys()
+
     efeatures = {}
 
     for protocol_name, locations in feature_definitions.items():
+        if skip_missing and protocol_name not in protocol_names:
+            logger.warning(
+                "Protocol %s not found. Skipping its features.", protocol_name
+            )
+            continue
         for recording_name, feature_configs in locations.items():
             for feature_config in feature_configs:
 
@@ -132,3 +146,151 @@ def define_efeatures(main_protocol, features_path, prefix=""):
                 efeatures[feature_name] = feature
 
     return efeatures
+
+
+def load_stored_current(features_path, feature_name="bpo_threshold_current"):
+    """Return the current stored in the features file, e.g. the threshold current.
+
+    Args:
+        features_path (str): path to features file
+        feature_name (str): name of the current feature,
+            e.g. "bpo_threshold_current" or "bpo_holding_current"
+
+    Returns:
+        float: the mean value of the first feature found (nA), or None if not found
+    """
+    with open(features_path, "r", encoding="utf-8") as features_file:
+        feature_definitions = json.load(features_file)
+
+    if "__comment" in feature_definitions:
+        del feature_definitions["__comment"]
+
+    for locations in feature_definitions.values():
+        for feature_configs in locations.values():
+            for feature_config in feature_configs:
+                if feature_config["feature"] == feature_name:
+                    return feature_config["val"][0]
+
+    return None
+
+
+def get_protocols_efeatures(protocol_definitions):
+    """Return the eFEL features to extract for each protocol.
+
+    The features are given as a list under the 'efeatures' key of each protocol definition,
+    e.g. "Step_150": {"type": "StepProtocol", "stimuli": {...}, "efeatures": ["Spikecount"]}
+
+    Args:
+        protocol_definitions (dict): protocols as loaded from the protocols json file
+
+    Raises:
+        ValueError: if the efeatures of a protocol are not given as a list of feature names
+
+    Returns:
+        dict: list of eFEL feature names for each protocol having some
+    """
+    protocols_efeatures = {}
+    for protocol_name, protocol_definition in protocol_definitions.items():
+        if not isinstance(protocol_definition, dict):
+            continue
+        if "efeatures" not in protocol_definition:
+            continue
+
+        feature_names = protocol_definition["efeatures"]
+        if not isinstance(feature_names, list) or not all(
+            isinstance(feature_name, str) for feature_name in feature_names
+        ):
+            raise ValueError(
+                f"The efeatures of protocol {protocol_name} should be a list of feature names."
+            )
+        protocols_efeatures[protocol_name] = feature_names
+
+    return protocols_efeatures
+
+
+def add_config_efeatures(protocols_efeatures, feature_names, responses, prefix=""):
+    """Add the eFEL features of the config to every protocol with a somatic voltage.
+
+    Args:
+        protocols_efeatures (dict): list of eFEL feature names for each protocol,
+            as given in the protocols file
+        feature_names (list of str): eFEL features to extract for every protocol
+        responses (dict): responses of the protocols
+        prefix (str): prefix used in naming responses, features, recordings, etc.
+
+    Returns:
+        dict: list of eFEL feature names for each protocol,
+            the ones of the protocols file coming first
+    """
+    protocols_efeatures = {
+        protocol_name: list(names)
+        for protocol_name, names in protocols_efeatures.items()
+    }
+    if not feature_names:
+        return protocols_efeatures
+
+    start, end = f"{prefix}.", ".soma.v"
+    for key, response in responses.items():
+        if response is None or not key.startswith(start) or not key.endswith(end):
+            continue
+        names = protocols_efeatures.setdefault(key[len(start) : -len(end)], [])
+        names.extend(name for name in feature_names if name not in names)
+
+    return protocols_efeatures
+
+
+def extract_protocols_efeatures(
+    responses, protocols_efeatures, stim_windows=None, prefix="", stim_amplitudes=None
+):
+    """Extract the eFEL features attached to each protocol from the somatic responses.
+
+    Args:
+        responses (dict): responses of the protocols
+        protocols_efeatures (dict): list of eFEL feature names for each protocol
+        stim_windows (dict): (start, end) of the stimulus (ms) for each protocol name.
+            The whole trace is used for protocols without stimulus window.
+        prefix (str): prefix used in naming responses, features, recordings, etc.
+        stim_amplitudes (dict): step amplitude (nA) for each protocol name,
+            used by the features needing the stimulus current,
+            e.g. ohmic_input_resistance_vb_ssse
+
+    Returns:
+        dict: values of each feature for each protocol
+    """
+    if stim_windows is None:
+        stim_windows = {}
+    if stim_amplitudes is None:
+        stim_amplitudes = {}
+
+    efeatures = {}
+    for protocol_name, feature_names in protocols_efeatures.items():
+        response = responses.get(f"{prefix}.{protocol_name}.soma.v")
+        if response is None:
+            logger.warning(
+                "No somatic voltage response found for protocol %s. "
+                "Skipping its efeatures.",
+                protocol_name,
+            )
+            continue
+
+        time = np.asarray(response["time"])
+        stim_start, stim_end = stim_windows.get(protocol_name, (time[0], time[-1]))
+        trace = {
+            "T": time,
+            "V": np.asarray(response["voltage"]),
+            "stim_start": [stim_start],
+            "stim_end": [stim_end],
+        }
+
+        if protocol_name in stim_amplitudes:
+            efel.setDoubleSetting("stimulus_current", stim_amplitudes[protocol_name])
+        efel_results = efel.getFeatureValues(
+            [trace], feature_names, raise_warnings=False
+        )[0]
+        efel.reset()
+        efeatures[protocol_name] = {
+            feature_name: None if values is None else list(values)
+            for feature_name, values in efel_results.items()
+        }
+
+    return efeatures
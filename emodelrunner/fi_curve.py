@@ -0,0 +1,197 @@
+"""Frequency-current (f-I) curve of the f-I curve protocols."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import logging
+from pathlib import Path
+
+import numpy as np
+
+from emodelrunner.json_utilities import NpEncoder
+from emodelrunner.results import detect_spikes, parse_output_filename
+
+logger = logging.getLogger(__name__)
+
+
+def get_firing_rate(time, voltage, stim_start=None, stim_end=None, threshold=-20.0):
+    """Return the number of spikes and the firing rate during the stimulus.
+
+    Args:
+        time (numpy.ndarray): time of the trace (ms)
+        voltage (numpy.ndarray): voltage of the trace (mV)
+        stim_start (float): time at which the stimulus starts (ms).
+            The start of the trace is used if None.
+        stim_end (float): time at which the stimulus ends (ms).
+            The end of the trace is used if None.
+        threshold (float): voltage threshold for spike detection (mV)
+
+    Returns:
+        tuple: the number of spikes and the firing rate (Hz)
+    """
+    time = np.asarray(time)
+    if stim_start is None:
+        stim_start = time[0]
+    if stim_end is None:
+        stim_end = time[-1]
+
+    spikes = detect_spikes(time, voltage, threshold)
+    spike_count = int(np.sum((spikes >= stim_start) & (spikes < stim_end)))
+    firing_rate = 1000.0 * spike_count / (stim_end - stim_start)
+    return spike_count, firing_rate
+
+
+def fit_fi_curve(amplitudes, firing_rates):
+    """Fit a line to the suprathreshold part of the f-I curve.
+
+    Only the steps eliciting spikes are fitted, since the firing rate
+    is 0 below the rheobase whatever the amplitude.
+
+    Args:
+        amplitudes (list): step amplitudes (nA)
+        firing_rates (list): firing rate of each step (Hz)
+
+    Returns:
+        dict containing the 'gain' (slope, Hz/nA), the 'intercept' (Hz),
+        the 'rheobase' (nA) at which the fitted line reaches 0 Hz,
+        the 'r_squared' of the fit and the number of fitted points.
+        The values are None if less than 2 steps elicit spikes.
+    """
+    amplitudes = np.asarray(amplitudes, dtype=float)
+    firing_rates = np.asarray(firing_rates, dtype=float)
+    suprathreshold = firing_rates > 0
+
+    fit = {
+        "gain": None,
+        "intercept": None,
+        "rheobase": None,
+        "r_squared": None,
+        "n_points": int(np.sum(suprathreshold)),
+    }
+    if fit["n_points"] < 2 or np.ptp(amplitudes[suprathreshold]) == 0:
+        return fit
+
+    x = amplitudes[suprathreshold]
+    y = firing_rates[suprathreshold]
+    gain, intercept = np.polyfit(x, y, 1)
+    residuals = y - (gain * x + intercept)
+    total = np.sum((y - np.mean(y)) ** 2)
+
+    fit["gain"] = float(gain)
+    fit["intercept"] = float(intercept)
+    if gain != 0:
+        fit["rheobase"] = float(-intercept / gain)
+    fit["r_squared"] = 1.0 if total == 0 else float(1 - np.sum(residuals**2) / total)
+    return fit
+
+
+def get_fi_curve(responses, step_amplitudes, stim_windows=None, spike_threshold=-20.0):
+    """Compute the firing rate versus the step amplitude of the steps of a protocol.
+
+    Args:
+        responses (dict): responses of the protocols
+        step_amplitudes (dict): step amplitude (nA) for each step protocol name
+        stim_windows (dict): (start, end) of the stimulus (ms) for each protocol name
+        spike_threshold (float): voltage threshold for spike detection (mV)
+
+    Returns:
+        dict containing the 'protocol' names, the 'step_amplitude' (nA),
+        the 'spike_count' and the 'firing_rate' (Hz), sorted by step amplitude,
+        and the linear 'fit' of the suprathreshold steps
+    """
+    if stim_windows is None:
+        stim_windows = {}
+
+    points = []
+    for key, resp in responses.items():
+        if not isinstance(resp, dict) or "voltage" not in resp:
+            continue
+        metadata = parse_output_filename(key)
+        if metadata["location"] != "soma" or metadata["variable"] != "v":
+            continue
+        protocol_name = metadata["protocol"]
+        if step_amplitudes.get(protocol_name) is None:
+            continue
+
+        stim_start, stim_end = stim_windows.get(protocol_name, (None, None))
+        spike_count, firing_rate = get_firing_rate(
+            resp["time"], resp["voltage"], stim_start, stim_end, spike_threshold
+        )
+        points.append(
+            (step_amplitudes[protocol_name], protocol_name, spike_count, firing_rate)
+        )
+
+    points.sort(key=lambda point: point[0])
+    fi_curve = {
+        "protocol": [point[1] for point in points],
+        "step_amplitude": [point[0] for point in points],
+        "spike_count": [point[2] for point in points],
+        "firing_rate": [point[3] for point in points],
+    }
+    fi_curve["fit"] = fit_fi_curve(fi_curve["step_amplitude"], fi_curve["firing_rate"])
+    return fi_curve
+
+
+def compute_fi_curves(responses, fi_curve_protocols, stim_windows=None):
+    """Compute the f-I curve of each f-I curve protocol.
+
+    Args:
+        responses (dict): responses of the protocols
+        fi_curve_protocols (dict): step amplitudes and spike threshold
+            of each f-I curve protocol, see ProtocolBuilder.get_fi_curve_protocols
+        stim_windows (dict): (start, end) of the stimulus (ms) for each protocol name
+
+    Returns:
+        dict: the f-I curve of each f-I curve protocol name, see get_fi_curve
+    """
+    fi_curves = {}
+    for name, fi_curve_protocol in fi_curve_protocols.items():
+        fi_curves[name] = get_fi_curve(
+            responses,
+            fi_curve_protocol["step_amplitudes"],
+            stim_windows,
+            fi_curve_protocol["spike_threshold"],
+        )
+        fit = fi_curves[name]["fit"]
+        if fit["rheobase"] is None:
+            logger.warning(
+                "%s: the f-I curve could not be fitted, "
+                "e.g. because less than 2 steps elicit spikes.",
+                name,
+            )
+        else:
+            logger.info(
+                "%s: gain %.4g Hz/nA, rheobase %.4g nA (r^2 = %.3f)",
+                name,
+                fit["gain"],
+                fit["rheobase"],
+                fit["r_squared"],
+            )
+
+    return fi_curves
+
+
+def write_fi_curves(fi_curves, output_dir, filename="fi_curve.json"):
+    """Write the f-I curves as json.
+
+    Args:
+        fi_curves (dict): the f-I curve of each protocol, as returned by
+            compute_fi_curves
+        output_dir (str): path to the output directory
+        filename (str): name of the json file
+    """
+    output_path = Path(output_dir) / filename
+    with open(output_path, "w", encoding="utf-8") as out_file:
+        json.dump(fi_curves, out_file, indent=4, cls=NpEncoder)
@@ -0,0 +1,156 @@
+"""Hooks running user-defined analyses on the responses after the simulation."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import importlib
+import logging
+
+from emodelrunner.results import parse_output_filename
+
+logger = logging.getLogger(__name__)
+
+_hooks = []
+
+
+def register_hook(hook):
+    """Register a hook to be called with the responses of each protocol.
+
+    The hook is called as hook(protocol_name, responses, output_dir),
+    with responses containing only the responses of the protocol.
+    Can be used as a decorator.
+
+    Args:
+        hook (callable): the hook to register
+
+    Raises:
+        TypeError: if hook is not callable
+
+    Returns:
+        callable: the registered hook
+    """
+    if not callable(hook):
+        raise TypeError(f"Hook {hook} is not callable.")
+    if hook not in _hooks:
+        _hooks.append(hook)
+    return hook
+
+
+def unregister_hook(hook):
+    """Unregister a hook.
+
+    Args:
+        hook (callable): the hook to unregister
+    """
+    if hook in _hooks:
+        _hooks.remove(hook)
+
+
+def clear_hooks():
+    """Unregister all the hooks."""
+    _hooks.clear()
+
+
+def get_hooks():
+    """Return the registered hooks.
+
+    Returns:
+        list: the registered hooks, in registration order
+    """
+    return list(_hooks)
+
+
+def load_hook(hook_path):
+    """Import a hook given as 'module.path:function_name'.
+
+    Args:
+        hook_path (str): import path of the hook
+
+    Raises:
+        ValueError: if hook_path is not of the form 'module.path:function_name'
+
+    Returns:
+        callable: the imported hook
+    """
+    module_name, sep, function_name = hook_path.partition(":")
+    if not sep or not module_name or not function_name:
+        raise ValueError(
+            f"Hook {hook_path} should be of the form 'module.path:function_name'."
+        )
+    module = importlib.import_module(module_name)
+    return getattr(module, function_name)
+
+
+def load_hooks(hook_paths):
+    """Import the hooks given as 'module.path:function_name', without registering them.
+
+    Args:
+        hook_paths (list of str): import paths of the hooks
+
+    Returns:
+        list: the imported hooks
+    """
+    return [load_hook(hook_path) for hook_path in hook_paths]
+
+
+def register_hooks_from_paths(hook_paths):
+    """Import and register the hooks given as 'module.path:function_name'.
+
+    Args:
+        hook_paths (list of str): import paths of the hooks
+    """
+    for hook in load_hooks(hook_paths):
+        register_hook(hook)
+
+
+def get_protocol_responses(responses):
+    """Group the responses by protocol name.
+
+    Responses that do not belong to any protocol (e.g. holding current) are left out.
+
+    Args:
+        responses (dict): responses of the protocols
+
+    Returns:
+        dict: responses of each protocol
+    """
+    protocol_responses = {}
+    for key, response in responses.items():
+        protocol_name = parse_output_filename(key)["protocol"]
+        if protocol_name is None:
+            continue
+        protocol_responses.setdefault(protocol_name, {})[key] = response
+    return protocol_responses
+
+
+def run_hooks(responses, output_dir, hooks=None):
+    """Call the registered hooks and the given ones with the responses of each protocol.
+
+    The given hooks, e.g. the ones of a config, are only run by this call,
+    and are not registered for the next runs.
+
+    Args:
+        responses (dict): responses of the protocols
+        output_dir (str): path to the output directory, where hooks can write their outputs
+        hooks (list of callable): hooks to run after the registered ones
+    """
+    # dict keys, to run each hook once, in order
+    run_hooks_list = list(dict.fromkeys(_hooks + list(hooks or [])))
+    if not run_hooks_list:
+        return
+
+    for protocol_name, protocol_responses in get_protocol_responses(responses).items():
+        for hook in run_hooks_list:
+            logger.debug("Running hook %s on protocol %s", hook, protocol_name)
+            hook(protocol_name, protocol_responses, output_dir)
@@ -0,0 +1,170 @@
+"""Impedance amplitude profile (ZAP analysis) of the chirp protocols."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import logging
+
+import numpy as np
+
+from emodelrunner.results import detect_spikes
+
+logger = logging.getLogger(__name__)
+
+
+def get_impedance_profile(time, voltage, current, freq_min, freq_max):
+    """Compute the impedance amplitude and phase in a frequency band.
+
+    The impedance is the ratio of the Fourier transforms of the voltage
+    and of the injected current, after removal of their mean.
+
+    Args:
+        time (numpy.ndarray): regularly sampled time of the current (ms)
+        voltage (numpy.ndarray): voltage at these times (mV)
+        current (numpy.ndarray): injected current at these times (nA)
+        freq_min (float): lowest frequency of the profile (Hz)
+        freq_max (float): highest frequency of the profile (Hz)
+
+    Returns:
+        dict containing the 'frequency' (Hz), the 'impedance' amplitude (MOhm)
+        and the 'phase' (rad) of the voltage with respect to the current
+    """
+    dt = (time[1] - time[0]) / 1000.0
+    voltage_fft = np.fft.rfft(voltage - np.mean(voltage))
+    current_fft = np.fft.rfft(current - np.mean(current))
+    frequencies = np.fft.rfftfreq(len(time), dt)
+
+    band = (frequencies >= freq_min) & (frequencies <= freq_max) & (frequencies > 0)
+    band &= current_fft != 0
+    impedance = voltage_fft[band] / current_fft[band]
+
+    return {
+        "frequency": frequencies[band].tolist(),
+        "impedance": np.abs(impedance).tolist(),
+        "phase": np.angle(impedance).tolist(),
+    }
+
+
+def get_resonance(frequencies, impedance):
+    """Return the resonance frequency and the Q-factor of an impedance profile.
+
+    The Q-factor is the ratio of the maximum impedance to the impedance
+    at the lowest frequency of the profile, i.e. 1 for a non-resonant cell.
+
+    Args:
+        frequencies (list): frequencies of the profile (Hz)
+        impedance (list): impedance amplitude at each frequency (MOhm)
+
+    Returns:
+        dict containing the 'resonance_frequency' (Hz), the 'max_impedance' (MOhm),
+        the 'low_frequency_impedance' (MOhm) and the 'Q_factor'.
+        The values are None if the profile is empty.
+    """
+    if len(impedance) == 0:
+        return {
+            "resonance_frequency": None,
+            "max_impedance": None,
+            "low_frequency_impedance": None,
+            "Q_factor": None,
+        }
+
+    max_idx = int(np.argmax(impedance))
+    low_frequency_impedance = float(impedance[int(np.argmin(frequencies))])
+    return {
+        "resonance_frequency": float(frequencies[max_idx]),
+        "max_impedance": float(impedance[max_idx]),
+        "low_frequency_impedance": low_frequency_impedance,
+        "Q_factor": float(impedance[max_idx]) / low_frequency_impedance,
+    }
+
+
+def get_zap_analysis(response, current, stim_window, freq_band, spike_threshold=-20.0):
+    """Compute the impedance profile and the resonance of a chirp response.
+
+    The voltage is interpolated at the times of the current,
+    so that responses recorded with the variable time step can be analysed.
+
+    Args:
+        response (dict): voltage response, with its 'time' and 'voltage'
+        current (dict): injected current, with its 'time' and 'current'
+        stim_window (tuple): start and end of the chirp (ms)
+        freq_band (tuple): start and end frequencies of the chirp (Hz),
+            in any order
+        spike_threshold (float): voltage threshold for spike detection (mV)
+
+    Returns:
+        dict containing the impedance 'profile', see get_impedance_profile,
+        and the resonance, see get_resonance
+    """
+    time = np.asarray(current["time"], dtype=float)
+    during = (time >= stim_window[0]) & (time < stim_window[1])
+    time = time[during]
+    voltage = np.interp(
+        time,
+        np.asarray(response["time"], dtype=float),
+        np.asarray(response["voltage"], dtype=float),
+    )
+    if len(detect_spikes(time, voltage, spike_threshold)) > 0:
+        logger.warning(
+            "The chirp response spikes: its impedance profile is not linear."
+        )
+
+    profile = get_impedance_profile(
+        time,
+        voltage,
+        np.asarray(current["current"], dtype=float)[during],
+        min(freq_band),
+        max(freq_band),
+    )
+    analysis = get_resonance(profile["frequency"], profile["impedance"])
+    analysis["profile"] = profile
+    return analysis
+
+
+def compute_zap_analyses(responses, currents, chirp_protocols):
+    """Compute the impedance profile of each chirp protocol.
+
+    Args:
+        responses (dict): responses of the protocols
+        currents (dict): currents injected by the protocols
+        chirp_protocols (dict): keys of the voltage and of the current,
+            stimulus window and frequency band of each chirp protocol,
+            see ProtocolBuilder.get_chirp_protocols
+
+    Returns:
+        dict: the ZAP analysis of each chirp protocol name, see get_zap_analysis
+    """
+    analyses = {}
+    for name, chirp_protocol in chirp_protocols.items():
+        response = responses.get(chirp_protocol["voltage_key"])
+        current = currents.get(chirp_protocol["current_key"])
+        if response is None or current is None:
+            logger.warning("%s: no response or current to analyse.", name)
+            continue
+
+        analyses[name] = get_zap_analysis(
+            response,
+            current,
+            chirp_protocol["stim_window"],
+            chirp_protocol["freq_band"],
+        )
+        if analyses[name]["resonance_frequency"] is not None:
+            logger.info(
+                "%s: resonance frequency %.3g Hz, Q-factor %.3g",
+                name,
+                analyses[name]["resonance_frequency"],
+                analyses[name]["Q_factor"],
+            )
+
+    return analyses
@@ -0,0 +1,165 @@
+"""Extracellular action potentials computed with LFPy."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import logging
+
+import numpy as np
+from bluepyopt import ephys
+
+from emodelrunner import extracellular
+from emodelrunner.configuration.configparser import PackageType
+from emodelrunner.create_cells import create_cell_using_config
+from emodelrunner.extracellular import EXTRACELLULAR_SUFFIX, add_extracellular_recording
+
+# kept importable from this module
+from emodelrunner.extracellular import (  # pylint: disable=unused-import
+    check_electrode_positions,
+    compute_extracellular_potential,
+)
+from emodelrunner.load import get_prot_args, get_release_params
+from emodelrunner.protocols.create_protocols import ProtocolBuilder
+
+logger = logging.getLogger(__name__)
+
+LFPY_METHODS = ["linesource", "pointsource", "root_as_point"]
+
+
+class ExtracellularRecording(extracellular.ExtracellularRecording):
+    """Extracellular potential at electrode sites, computed with LFPy.
+
+    The membrane currents of all the segments of the cell are recorded
+    at each time step, and multiplied by the transformation matrix
+    of an LFPy electrode, computed from the geometry of the instantiated cell.
+
+    Attributes:
+        name (str): name of this object
+        electrode_positions (numpy.ndarray): x, y, z coordinates of the electrodes (um)
+        sigma (float): extracellular conductivity (S/m)
+        method (str): LFPy method used to compute the potential
+        transformation_matrix (numpy.ndarray): (n_electrodes, n_segments) matrix
+            of the electrode (mV / nA)
+        imem_vectors (list of neuron Vector): vectors recording the membrane currents
+        tvector (neuron Vector): vector recording the time (ms)
+        instantiated (bool): whether the object has been instantiated or not
+    """
+
+    methods = LFPY_METHODS
+
+    def get_transformation_matrix(self, sim, icell):
+        """Compute the transformation matrix of the electrode for the instantiated cell.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            icell (neuron cell): cell instantiation in simulator
+
+        Returns:
+            numpy.ndarray: (n_electrodes, n_segments) matrix of the electrode (mV / nA)
+        """
+        # pylint: disable=import-outside-toplevel
+        import LFPy
+
+        # LFPy only reads the geometry of the sections,
+        # the simulation is run by bluepyopt
+        lfpy_cell = LFPy.Cell(
+            morphology=icell.all,
+            delete_sections=False,
+            nsegs_method=None,
+            dt=sim.dt,
+            tstop=sim.dt,
+            pt3d=False,
+        )
+        electrode = LFPy.RecExtElectrode(
+            lfpy_cell,
+            sigma=self.sigma,
+            x=self.electrode_positions[:, 0],
+            y=self.electrode_positions[:, 1],
+            z=self.electrode_positions[:, 2],
+            method=self.method,
+        )
+        return np.asarray(electrode.get_transformation_matrix())
+
+
+def compute_extracellular_signals(
+    config, protocol_name, electrode_positions, sigma=0.3, method="linesource"
+):
+    """Run a protocol and compute the extracellular potential with LFPy.
+
+    All the protocols are run, since most of them depend on the holding
+    and threshold currents found by the Main protocol.
+
+    Args:
+        config (configparser.ConfigParser): configuration, as returned by load_config
+        protocol_name (str): name of the protocol to record from
+        electrode_positions (list or pint.Quantity): x, y, z coordinates
+            of each electrode (um)
+        sigma (float or pint.Quantity): extracellular conductivity (S/m)
+        method (str): LFPy method, one of "linesource", "pointsource"
+            or "root_as_point"
+
+    Raises:
+        ValueError: if the package type is not supported
+        RuntimeError: if the protocol did not run,
+            e.g. because the threshold current was not found
+
+    Returns:
+        dict: extracellular responses, keyed by recording name. The Rin protocol
+            template, for example, is recorded under the name "Rin.extracellular".
+    """
+    cell = create_cell_using_config(config)
+    release_params = get_release_params(config)
+
+    cvode_active = config.getboolean("Sim", "cvode_active")
+    dt = config.getfloat("Sim", "dt")
+    sim = ephys.simulators.NrnSimulator(dt=dt, cvode_active=cvode_active)
+
+    add_synapses = config.getboolean("Synapses", "add_synapses")
+    prot_args = get_prot_args(config)
+
+    if config.package_type == PackageType.sscx:
+        protocols = ProtocolBuilder.using_sscx_protocols(add_synapses, prot_args, cell)
+    elif config.package_type == PackageType.thalamus:
+        protocols = ProtocolBuilder.using_thalamus_protocols(
+            add_synapses, prot_args, cell
+        )
+    elif config.package_type == PackageType.hippocampus:
+        protocols = ProtocolBuilder.using_hippocampus_protocols(
+            add_synapses, prot_args, cell
+        )
+    else:
+        raise ValueError(f"unsupported package type: {config.package_type}")
+    ephys_protocols = protocols.get_ephys_protocols()
+
+    recording = ExtracellularRecording(
+        name=f"{protocol_name}{EXTRACELLULAR_SUFFIX}",
+        electrode_positions=electrode_positions,
+        sigma=sigma,
+        method=method,
+    )
+    add_extracellular_recording(ephys_protocols, protocol_name, recording)
+
+    logger.info("Computing the extracellular signals of %s...", protocol_name)
+    responses = ephys_protocols.run(
+        cell_model=cell, param_values=release_params, sim=sim, isolate=False
+    )
+
+    extracellular_responses = {
+        name: response
+        for name, response in responses.items()
+        if name.endswith(EXTRACELLULAR_SUFFIX) and response is not None
+    }
+    if not extracellular_responses:
+        raise RuntimeError(f"Protocol {protocol_name} did not run.")
+    return extracellular_responses
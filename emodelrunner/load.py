@@ -17,24 +17,39 @@
 import collections
 
 import json
+from pathlib import Path
 
 from bluepyopt import ephys
 
 from emodelrunner.synapses.mechanism import NrnMODPointProcessMechanismCustom
-from emodelrunner.locations import multi_locations
+from emodelrunner.locations import (
+    SECTIONLIST_IDS,
+    multi_locations,
+    parse_section_location,
+)
 from emodelrunner.configuration import get_validated_config, PackageType
+from emodelrunner.morphology.formats import get_neuron_morphology_path
+from emodelrunner.pharmacology import parse_channel_blocks
+from emodelrunner.seeds import apply_global_seed, get_global_seed
+from emodelrunner.sonata import load_edge_file_synapses
+from emodelrunner.stochastic import NrnMODMechanismCustom
 
 
-def load_config(config_path):
+def load_config(config_path, config_overrides=None):
     """Returns the validated configuration file.
 
     Args:
         config_path (str or Path): path to the configuration file.
+        config_overrides (list of str): values overriding the ones of the file,
+            given as 'section.key=value', e.g. 'Cell.celsius=36'
 
     Returns:
-        configparser.ConfigParser: loaded config object
+        configparser.ConfigParser: loaded config object, with the seeds derived
+            from the global seed of the [Sim] section, if any
     """
-    return get_validated_config(config_path)
+    config = get_validated_config(config_path, config_overrides)
+    apply_global_seed(config)
+    return config
 
 
 def get_hoc_paths_args(config):
@@ -73,6 +88,210 @@ def get_prot_args(config):
         "mtype": config.get("Morphology", "mtype"),
         "prot_path": config.get("Paths", "prot_path"),
         "features_path": config.get("Paths", "features_path"),
+        "extra_recordings": get_section_recording_definitions(config),
+        "threshold_current_source": config.get("Protocol", "threshold_current_source"),
+        "seed": get_global_seed(config),
+    }
+
+
+def get_recording_sampling_args(config, section):
+    """Get the sampling of the recordings of a section of the configuration.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+        section (str): section of the configuration, e.g. "Recordings"
+
+    Returns:
+        dict: interval between the recorded samples (record_dt, in ms) and
+            number of integration steps between them (record_every),
+            None if not set
+    """
+    record_dt = config.get(section, "record_dt", fallback="")
+    record_every = config.get(section, "record_every", fallback="")
+    return {
+        "record_dt": float(record_dt) if record_dt else None,
+        "record_every": int(record_every) if record_every else None,
+    }
+
+
+def get_section_recording_definitions(config):
+    """Get the definitions of the recordings at the sections of the configuration.
+
+    Each variable is recorded at each location, and the variables
+    of location_variables at their own location only, with the sampling
+    of the configuration, if any.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        list of dict: extra recording definitions of type "section"
+    """
+    locations = json.loads(config.get("Recordings", "locations"))
+    variables = json.loads(config.get("Recordings", "variables"))
+    location_variables = json.loads(
+        config.get("Recordings", "location_variables", fallback="{}")
+    )
+    sampling = {
+        key: value
+        for key, value in get_recording_sampling_args(config, "Recordings").items()
+        if value is not None
+    }
+
+    recorded = [(location, var) for location in locations for var in variables]
+    recorded += [
+        (location, var)
+        for location, location_vars in location_variables.items()
+        for var in location_vars
+    ]
+    # dict keys, to record each variable once at each location, in order
+    return [
+        {"type": "section", "var": var, **parse_section_location(location), **sampling}
+        for location, var in dict.fromkeys(recorded)
+    ]
+
+
+def get_extracellular_args(config):
+    """Get the dict containing the extracellular recording configuration data.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Raises:
+        ValueError: if protocols are given without any electrode position
+
+    Returns:
+        dict: extracellular recording related configuration data
+    """
+    extracellular_args = {
+        "protocols": json.loads(config.get("Extracellular", "protocols")),
+        "electrode_positions": json.loads(
+            config.get("Extracellular", "electrode_positions")
+        ),
+        "sigma": config.getfloat("Extracellular", "sigma"),
+        "method": config.get("Extracellular", "method"),
+    }
+    if (
+        extracellular_args["protocols"]
+        and not extracellular_args["electrode_positions"]
+    ):
+        raise ValueError(
+            "Electrode positions should be given to record the extracellular potential."
+        )
+    return extracellular_args
+
+
+def get_channel_blocks(config):
+    """Get the channel blocks from the configuration object.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        list of dict: name, operation ('set' or 'scale') and value of each block
+    """
+    return parse_channel_blocks(config.get("Pharmacology", "block", fallback=""))
+
+
+def get_stochastic_args(config):
+    """Get the stochastic channel configuration data.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Raises:
+        ValueError: if the stochastic channels are used with the variable time step
+
+    Returns:
+        dict: whether the stochastic channels are stochastic in every protocol,
+            their seed and the number of trials of the protocols
+    """
+    stochastic_args = {
+        "stochastic": config.getboolean("Sim", "stochastic_channels", fallback=False),
+        "channel_seed": config.getint("Sim", "channel_seed", fallback=0),
+        "n_trials": config.getint("Sim", "n_trials", fallback=1),
+    }
+    if stochastic_args["stochastic"] and config.getboolean("Sim", "cvode_active"):
+        raise ValueError(
+            "The stochastic channels cannot be used with the variable time step. "
+            "Set cvode_active to False."
+        )
+    return stochastic_args
+
+
+def get_synapse_recording_args(config):
+    """Get the dict containing the synapse recording configuration data.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        dict: synapse recording related configuration data,
+            with None synapse_ids and pre_mtypes if all the synapses are recorded
+    """
+    return {
+        "protocols": json.loads(
+            config.get("SynapseRecordings", "protocols", fallback="[]")
+        ),
+        "variables": json.loads(
+            config.get("SynapseRecordings", "variables", fallback='["g", "i"]')
+        ),
+        "synapse_ids": json.loads(
+            config.get("SynapseRecordings", "synapse_ids", fallback="[]")
+        )
+        or None,
+        "pre_mtypes": json.loads(
+            config.get("SynapseRecordings", "pre_mtypes", fallback="[]")
+        )
+        or None,
+        **get_recording_sampling_args(config, "SynapseRecordings"),
+    }
+
+
+def get_minis_args(config):
+    """Get the dict containing the spontaneous minis configuration data.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        dict: spontaneous minis related configuration data,
+            with the pre_mtype rates keyed by int pre_mtype ids
+    """
+    pre_mtype_rates = json.loads(config.get("Minis", "pre_mtype_rates", fallback="{}"))
+    return {
+        "protocols": json.loads(config.get("Minis", "protocols", fallback="[]")),
+        "rate": config.getfloat("Minis", "rate", fallback=0.01),
+        "pre_mtype_rates": {
+            int(pre_mtype): float(rate) for pre_mtype, rate in pre_mtype_rates.items()
+        },
+        "rates_path": config.get("Paths", "minis_rates_path", fallback=""),
+        "seed": config.getint("Minis", "seed", fallback=0),
+        "base_seed": config.getint("Synapses", "seed"),
+        "gid": config.getint("Cell", "gid", fallback=1),
+    }
+
+
+def get_synapse_selection(config):
+    """Get the section types and the path distance range of the activated synapses.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        dict: sectionlist ids of the section types, and [min, max] path distance
+            from the soma (um). Each one is None if all the synapses are activated.
+    """
+    section_types = json.loads(config.get("Synapses", "section_types", fallback="[]"))
+    path_distance_range = json.loads(
+        config.get("Synapses", "path_distance_range", fallback="[]")
+    )
+    return {
+        "sectionlist_ids": [
+            SECTIONLIST_IDS[section_type] for section_type in section_types
+        ]
+        or None,
+        "path_distance_range": path_distance_range or None,
     }
 
 
@@ -91,6 +310,75 @@ def get_syn_mech_args(config):
         "syn_conf_file": config.get("Paths", "syn_conf_file"),
         "syn_data_file": config.get("Paths", "syn_data_file"),
         "syn_dir": config.get("Paths", "syn_dir"),
+        "weight_scale": config.getfloat("Synapses", "weight_scale", fallback=1.0),
+        # activate only a subset of the synapses, if any selection is given
+        "pre_mtypes": json.loads(config.get("Synapses", "pre_mtypes", fallback="[]"))
+        or None,
+        "selection": get_synapse_selection(config),
+        # only used when the synapses are read from a SONATA edges file
+        "sonata_args": {
+            # gids are node ids + 1, as in neurodamus
+            "node_id": config.getint("Cell", "gid", fallback=1) - 1,
+            "morph_path": config.get("Paths", "morph_path", fallback=None),
+            "morph_name": config.get("Morphology", "morph_name", fallback=""),
+            "edge_population": config.get("Synapses", "edge_population", fallback=""),
+            "source_nodes_path": config.get(
+                "Paths", "syn_source_nodes_path", fallback=""
+            ),
+        },
+    }
+
+
+def get_morph_path(config, precell=False):
+    """Get the path to a morphology file NEURON can load from the configuration object.
+
+    The morphologies in other formats than asc and swc, e.g. h5,
+    and the ones of directories or h5 containers are converted to asc
+    in converted_morph_dir.
+
+    Args:
+        config (configparser.ConfigParser): configuration object.
+        precell (bool): True to get the precell morphology of the pair simulations.
+            The morphology of the cell is used if precell_morph_path is empty.
+
+    Returns:
+        str: path to the asc or swc morphology file
+    """
+    if precell and config.get("Paths", "precell_morph_path"):
+        morph_path = config.get("Paths", "precell_morph_path")
+        morph_name = config.get("Morphology", "precell_morph_name", fallback="")
+    else:
+        morph_path = config.get("Paths", "morph_path")
+        morph_name = config.get("Morphology", "morph_name", fallback="")
+
+    return str(
+        get_neuron_morphology_path(
+            morph_path,
+            config.get("Paths", "converted_morph_dir", fallback="converted_morphology"),
+            morph_name,
+        )
+    )
+
+
+def get_morph_modifier_args(config):
+    """Get the axon type and the morphology modifiers from the configuration object.
+
+    Args:
+        config (configparser.ConfigParser): configuration object.
+
+    Returns:
+        dict: axon type, AIS path, dendrite diameter scale, pruning distance
+            (None for no pruning) and import paths of the modifiers
+    """
+    prune_distance = config.get("Morphology", "prune_distance", fallback="")
+    return {
+        "axon_type": config.get("Morphology", "axon_type", fallback=""),
+        "ais_path": config.get("Paths", "ais_path", fallback=""),
+        "dendrite_diameter_scale": config.getfloat(
+            "Morphology", "dendrite_diameter_scale", fallback=1.0
+        ),
+        "prune_distance": float(prune_distance) if prune_distance else None,
+        "modifiers": json.loads(config.get("Morphology", "modifiers", fallback="[]")),
     }
 
 
@@ -104,11 +392,12 @@ def get_morph_args(config):
         dict: dictionary containing morphology arguments.
     """
     morph_args = {}
-    morph_args["morph_path"] = config.get("Paths", "morph_path")
+    morph_args["morph_path"] = get_morph_path(config)
     morph_args["do_replace_axon"] = config.getboolean("Morphology", "do_replace_axon")
 
     if config.package_type == PackageType.sscx:
         morph_args["axon_hoc_path"] = config.get("Paths", "replace_axon_hoc_path")
+    morph_args.update(get_morph_modifier_args(config))
 
     return morph_args
 
@@ -123,15 +412,10 @@ def get_synplas_morph_args(config, precell=False):
     Returns:
         dict: dictionary containing morphology arguments.
     """
-    # load morphology path
-    if precell:
-        morph_path = config.get("Paths", "precell_morph_path")
-    else:
-        morph_path = config.get("Paths", "morph_path")
-
     return {
-        "morph_path": morph_path,
+        "morph_path": get_morph_path(config, precell),
         "do_replace_axon": config.getboolean("Morphology", "do_replace_axon"),
+        **get_morph_modifier_args(config),
     }
 
 
@@ -175,6 +459,88 @@ def load_emodel_params(emodel, params_path):
     return param_dict
 
 
+def get_pair_args(config):
+    """Get the dict containing the pair simulation configuration data of sscx packages.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        dict: pair simulation related configuration data
+    """
+    # the e-model and the parameters of the cell are used if the precell has none
+    precell_emodel = config.get("Cell", "precell_emodel")
+    if not precell_emodel:
+        precell_emodel = config.get("Cell", "emodel")
+    unopt_params_path = config.get("Paths", "precell_unoptimized_params_path")
+    if not unopt_params_path:
+        unopt_params_path = config.get("Paths", "unoptimized_params_path")
+    # all the synapses connect the pair if no precell_gid is given
+    precell_gid = config.get("Cell", "precell_gid")
+
+    pair_args = {
+        key: config.getfloat("Pair", key)
+        for key in ["spike_amplitude", "spike_width", "tstop", "epsp_window"]
+    }
+    pair_args["spike_times"] = json.loads(config.get("Pair", "spike_times"))
+    pair_args["precell_emodel"] = precell_emodel
+    pair_args["precell_unoptimized_params_path"] = unopt_params_path
+    pair_args["precell_gid"] = int(precell_gid) if precell_gid else None
+    return pair_args
+
+
+def get_pairing_args(config):
+    """Get the dict containing the pairing protocol configuration data.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        dict: pairing protocol related configuration data
+    """
+    pairing_args = {
+        key: config.getfloat("Pairing", key)
+        for key in [
+            "frequency",
+            "repetition_period",
+            "delta_t",
+            "postspike_latency",
+            "pulse_amplitude",
+            "pulse_width",
+            "baseline_duration",
+            "test_duration",
+            "test_period",
+            "test_start",
+        ]
+    }
+    pairing_args["enabled"] = config.getboolean("Pairing", "enabled")
+    pairing_args["n_pairings"] = config.getint("Pairing", "n_pairings")
+    pairing_args["n_repetitions"] = config.getint("Pairing", "n_repetitions")
+    pairing_args["order"] = config.get("Pairing", "order")
+    return pairing_args
+
+
+def get_checkpoint_args(config):
+    """Get the dict containing the checkpoint configuration data.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        dict: interval between the checkpoints (ms), checkpoint directory
+            and whether to resume from the checkpoint,
+            or None if the checkpoints are disabled
+    """
+    interval = config.getfloat("SynapsePlasticity", "checkpoint_interval")
+    if interval <= 0:
+        return None
+    return {
+        "interval": interval,
+        "dir": config.get("Paths", "checkpoint_dir"),
+        "resume": config.getboolean("SynapsePlasticity", "resume"),
+    }
+
+
 def get_syn_setup_params(
     syn_extra_params_path,
     cpre_cpost_path,
@@ -221,11 +587,12 @@ def get_release_params(config, precell=False):
     Args:
         config (configparser.ConfigParser): configuration
         precell (bool): True to load precell optimized parameters. False to get usual parameters.
+            The parameters of the cell e-model are used if precell_emodel is empty.
 
     Returns:
         dict: optimized parameters
     """
-    if precell:
+    if precell and config.get("Cell", "precell_emodel"):
         emodel = config.get("Cell", "precell_emodel")
     else:
         emodel = config.get("Cell", "emodel")
@@ -236,14 +603,17 @@ def get_release_params(config, precell=False):
     return release_params
 
 
-def load_mechanisms(mechs_path):
+def load_mechanisms(mechs_path, stochastic=False, channel_seed=0):
     """Define mechanisms.
 
     Args:
         mechs_path (str): path to the unoptimized parameters json file
+        stochastic (bool): whether the stochastic channels, e.g. StochKv,
+            are stochastic in every protocol instead of deterministic
+        channel_seed (int): seed of the stochastic channels, added to the gid
 
     Returns:
-        list of ephys.mechanisms.NrnMODMechanism from file
+        list of NrnMODMechanismCustom from file
     """
     with open(mechs_path, "r", encoding="utf-8") as mechs_file:
         mechs = json.load(mechs_file)
@@ -256,12 +626,12 @@ def load_mechanisms(mechs_path):
 
         for channel in channels["mech"]:
             mechanisms_list.append(
-                ephys.mechanisms.NrnMODMechanism(
+                NrnMODMechanismCustom(
                     name=f"{channel}.{sectionlist}",
-                    mod_path=None,
                     suffix=channel,
                     locations=seclist_locs,
-                    preloaded=True,
+                    deterministic=not (stochastic and "Stoch" in channel),
+                    seed=channel_seed,
                 )
             )
 
@@ -414,6 +784,9 @@ def load_syn_mechs(
     stim_params=None,
     use_glu_synapse=False,
     syn_setup_params=None,
+    weight_scale=1.0,
+    sonata_args=None,
+    selection=None,
 ):
     """Load synapse mechanisms.
 
@@ -421,7 +794,8 @@ def load_syn_mechs(
         seed (int): random number generator seed number
         rng_settings_mode (str): mode of the random number generator
             Can be "Random123" or "Compatibility"
-        syn_data_path (str): path to the (tsv) synapses data file
+        syn_data_path (str): path to the synapses data file,
+            either a tsv file or a SONATA edges (.h5) file
         syn_conf_path (str): path to the synapse configuration data file
         pre_mtypes (list of ints): activate only synapses whose pre_mtype
             is in this list. if None, all synapses are activated
@@ -431,12 +805,21 @@ def load_syn_mechs(
         use_glu_synapse (bool): if True, instantiate synapses to use GluSynapse
         syn_setup_params (dict): contains extra parameters to setup synapses
             when using GluSynapseCustom
+        weight_scale (float): factor applied to the weights of all the synapses
+        sonata_args (dict): node_id, morph_path, edge_population and
+            source_nodes_path used to read a SONATA edges file.
+            See sonata.load_edge_file_synapses.
+        selection (dict): sectionlist_ids, path_distance_range and pre_cell_ids
+            of the activated synapses. See get_synapse_selection.
 
     Returns:
         NrnMODPointProcessMechanismCustom: the synapses mechanisms
     """
+    # pylint: disable=too-many-arguments
     # load synapse file data
-    synapses_data = load_synapses_tsv_data(syn_data_path)
+    synapses_data = load_synapses_data(syn_data_path, sonata_args)
+    for syn in synapses_data:
+        syn["weight"] *= weight_scale
 
     # load synapse configuration
     synconf_dict = load_synapse_configuration_data(syn_conf_path)
@@ -451,9 +834,37 @@ def load_syn_mechs(
         stim_params,
         use_glu_synapse=use_glu_synapse,
         syn_setup_params=syn_setup_params,
+        **(selection or {}),
     )
 
 
+def load_synapses_data(syn_data_path, sonata_args=None):
+    """Load the synapse data from a tsv file or from a SONATA edges file.
+
+    Args:
+        syn_data_path (str): path to the synapses data file.
+            It is read as a SONATA edges file if its extension is .h5
+        sonata_args (dict): node_id, morph_path, edge_population and
+            source_nodes_path used to read a SONATA edges file
+
+    Raises:
+        ValueError: if a SONATA edges file is given without sonata_args
+
+    Returns:
+        list of dicts containing each data for one synapse
+    """
+    if Path(syn_data_path).suffix != ".h5":
+        return load_synapses_tsv_data(syn_data_path)
+
+    if sonata_args is None or sonata_args.get("morph_path") is None:
+        raise ValueError(
+            f"The node id and the morphology of the cell are needed "
+            f"to read the synapses of {syn_data_path}."
+        )
+    synapses, _ = load_edge_file_synapses(syn_data_path, **sonata_args)
+    return synapses
+
+
 def load_synapses_tsv_data(tsv_path):
     """Load synapse data from tsv.
 
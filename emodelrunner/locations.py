@@ -15,6 +15,7 @@
 # limitations under the License.
 
 import logging
+import re
 
 from bluepyopt import ephys
 
@@ -25,6 +26,135 @@ SOMA_LOC = ephys.locations.NrnSeclistCompLocation(
     name="soma", seclist_name="somatic", sec_index=0, comp_x=0.5
 )
 
+# sectionlist_id of each section type, as used in the synapses tsv file
+SECTIONLIST_IDS = {"soma": 0, "basal_dendrite": 1, "apical_dendrite": 2, "axon": 3}
+
+# e.g. 'dend[3](0.5)'
+SECTION_LOCATION_PATTERN = re.compile(
+    r"^(?P<sec_name>[A-Za-z_]+)\[(?P<sec_index>\d+)\]\((?P<comp_x>\d*\.?\d+)\)$"
+)
+# e.g. 'dend3_x0p5', encoding the section and the position in the output names
+SECTION_LOCATION_NAME_PATTERN = re.compile(
+    r"^(?P<sec_name>[A-Za-z_]+?)(?P<sec_index>\d+)_x(?P<comp_x>\d+(p\d+)?)$"
+)
+
+
+def parse_section_location(location):
+    """Parse a location given as a section and a position, e.g. 'dend[3](0.5)'.
+
+    Args:
+        location (str): section array name, section index and position
+            between 0 and 1, as 'sec_name[sec_index](comp_x)'
+
+    Raises:
+        ValueError: if the location does not have the expected format
+
+    Returns:
+        dict: sec_name, sec_index and comp_x of the location
+    """
+    match = SECTION_LOCATION_PATTERN.match(location.replace(" ", ""))
+    if match is None:
+        raise ValueError(
+            f"Invalid location: {location}. Expected e.g. 'dend[3](0.5)'."
+        )
+    comp_x = float(match["comp_x"])
+    if not 0 <= comp_x <= 1:
+        raise ValueError(f"The position in {location} should be between 0 and 1.")
+    return {
+        "sec_name": match["sec_name"],
+        "sec_index": int(match["sec_index"]),
+        "comp_x": comp_x,
+    }
+
+
+def get_section_location_name(sec_name, sec_index, comp_x):
+    """Return the name of a section location, used in the output names.
+
+    The name does not contain dots, since they separate the fields of the output names.
+
+    Args:
+        sec_name (str): name of the section array, e.g. 'dend'
+        sec_index (int): index of the section in the section array
+        comp_x (float): position in the section, between 0 and 1
+
+    Returns:
+        str: name of the location, e.g. 'dend3_x0p5'
+    """
+    return f"{sec_name}{sec_index}_x{comp_x:g}".replace(".", "p")
+
+
+def parse_section_location_name(name):
+    """Return the section and the position encoded in the name of a location.
+
+    Args:
+        name (str): name of the location, e.g. 'dend3_x0p5'
+
+    Returns:
+        dict: section, e.g. 'dend[3]', and position, e.g. 0.5,
+            or None if the name does not encode a section location
+    """
+    match = SECTION_LOCATION_NAME_PATTERN.match(name)
+    if match is None:
+        return None
+    return {
+        "section": f"{match['sec_name']}[{match['sec_index']}]",
+        "position": float(match["comp_x"].replace("p", ".")),
+    }
+
+
+class NrnSectionCompLocation(ephys.locations.Location):
+    """Compartment of a section given by its section array and its index.
+
+    Attributes:
+        name (str): name of the location
+        sec_name (str): name of the section array, e.g. 'dend' or 'axon'
+        sec_index (int): index of the section in the section array
+        comp_x (float): position in the section, between 0 and 1
+        comment (str): comment
+    """
+
+    def __init__(self, name, sec_name, sec_index, comp_x, comment=""):
+        """Constructor.
+
+        Args:
+            name (str): name of the location
+            sec_name (str): name of the section array, e.g. 'dend' or 'axon'
+            sec_index (int): index of the section in the section array
+            comp_x (float): position in the section, between 0 and 1
+            comment (str): comment
+        """
+        super().__init__(name, comment=comment)
+        self.sec_name = sec_name
+        self.sec_index = sec_index
+        self.comp_x = comp_x
+
+    def instantiate(self, sim=None, icell=None):
+        """Find the instantiated compartment.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            icell (neuron cell): cell instantiation in simulator
+
+        Raises:
+            EPhysLocInstantiateException: if the section does not exist in the cell,
+                e.g. after the axon replacement
+
+        Returns:
+            neuron Segment: the compartment
+        """
+        # pylint: disable=unused-argument
+        try:
+            section = getattr(icell, self.sec_name)[self.sec_index]
+        except (AttributeError, IndexError) as exc:
+            raise ephys.locations.EPhysLocInstantiateException(
+                f"{self.sec_name}[{self.sec_index}] does not exist in the cell"
+            ) from exc
+        return section(self.comp_x)
+
+    def __str__(self):
+        """String representation."""
+        return f"{self.sec_name}[{self.sec_index}]({self.comp_x})"
+
 
 def multi_locations(sectionlist):
     """Define locations.
@@ -0,0 +1,83 @@
+"""Logging utilities functions."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import ctypes
+import logging
+import os
+import sys
+import threading
+from contextlib import contextmanager
+
+# file descriptor of the standard output of the process
+STDOUT_FD = 1
+
+neuron_logger = logging.getLogger("neuron")
+
+
+def flush_c_stdout():
+    """Flush the stdio buffers of the C libraries, e.g. the printf output of NEURON."""
+    try:
+        ctypes.CDLL(None).fflush(None)
+    except (OSError, AttributeError):
+        pass
+
+
+def log_lines(read_fd, level):
+    """Log each non-empty line read from a file descriptor, until its end.
+
+    Args:
+        read_fd (int): file descriptor to read from
+        level (int): logging level of the lines
+    """
+    with os.fdopen(read_fd, "r", errors="replace") as pipe:
+        for line in pipe:
+            line = line.rstrip()
+            if line:
+                neuron_logger.log(level, line)
+
+
+@contextmanager
+def neuron_output_to_logger(level=logging.INFO):
+    """Redirect the standard output of the process to the "neuron" logger.
+
+    NEURON prints from its C code and through sys.stdout. Both are written to
+    the standard output file descriptor, which is redirected to a pipe
+    read line by line by a thread, so that the messages of NEURON are filtered
+    and formatted like the other logs.
+    The logs must not be written to stdout, else they would be logged again.
+
+    Args:
+        level (int): logging level of the NEURON messages
+    """
+    sys.stdout.flush()
+    flush_c_stdout()
+
+    read_fd, write_fd = os.pipe()
+    saved_stdout_fd = os.dup(STDOUT_FD)
+    reader = threading.Thread(target=log_lines, args=(read_fd, level), daemon=True)
+    reader.start()
+    os.dup2(write_fd, STDOUT_FD)
+    os.close(write_fd)
+
+    try:
+        yield
+    finally:
+        sys.stdout.flush()
+        flush_c_stdout()
+        # closes the last write end of the pipe, which ends the reader thread
+        os.dup2(saved_stdout_fd, STDOUT_FD)
+        os.close(saved_stdout_fd)
+        reader.join()
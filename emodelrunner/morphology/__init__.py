@@ -15,6 +15,14 @@
 # limitations under the License.
 
 from emodelrunner.morphology.builder import create_morphology
+from emodelrunner.morphology.modifiers import (
+    clear_morph_modifiers,
+    prune_dendrites,
+    register_morph_modifier,
+    replace_axon_with_ais,
+    scale_dendrite_diameters,
+    unregister_morph_modifier,
+)
 from emodelrunner.morphology.morphology import (
     SSCXNrnFileMorphology,
     ThalamusNrnFileMorphology,
@@ -14,6 +14,9 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
+from bluepyopt import ephys
+
+from emodelrunner.morphology.modifiers import get_morph_modifiers
 from emodelrunner.morphology.morphology import (
     SSCXNrnFileMorphology,
     ThalamusNrnFileMorphology,
@@ -37,6 +40,12 @@ def get_axon_hoc(axon_hoc_path):
 def create_morphology(morph_args, package_type):
     """Creates the morphology object.
 
+    The axon is replaced by the stub axon if axon_type is 'stub',
+    kept if it is 'full' and replaced by the AIS of ais_path if it is 'ais'.
+    do_replace_axon is used if axon_type is not given.
+    The morphology is also modified by the modifiers of the configuration
+    and the registered ones, see emodelrunner.morphology.modifiers.
+
     Args:
         morph_args (dict): morphology-related configuration
         package_type (Enum): enum denoting the package type
@@ -51,17 +60,37 @@ def create_morphology(morph_args, package_type):
         replace_axon_hoc = get_axon_hoc(morph_args["axon_hoc_path"])
     except KeyError:
         replace_axon_hoc = None
+    do_replace_axon = morph_args["do_replace_axon"]
+    if morph_args.get("axon_type"):
+        do_replace_axon = morph_args["axon_type"] == "stub"
+    morph_modifiers, morph_modifiers_hoc = get_morph_modifiers(morph_args)
+    if not morph_modifiers:
+        morph_modifiers = None
+
     if package_type in [PackageType.sscx, PackageType.synplas]:
         morph = SSCXNrnFileMorphology(
             morph_args["morph_path"],
-            do_replace_axon=morph_args["do_replace_axon"],
+            do_replace_axon=do_replace_axon,
+            replace_axon_hoc=replace_axon_hoc,
+            morph_modifiers=morph_modifiers,
+            morph_modifiers_hoc=morph_modifiers_hoc,
+        )
+    elif package_type == PackageType.hippocampus:
+        # the default stub axon of BluePyOpt: two 30 um sections, without myelin
+        morph = ephys.morphologies.NrnFileMorphology(
+            morph_args["morph_path"],
+            do_replace_axon=do_replace_axon,
             replace_axon_hoc=replace_axon_hoc,
+            morph_modifiers=morph_modifiers,
+            morph_modifiers_hoc=morph_modifiers_hoc,
         )
     elif package_type == PackageType.thalamus:
         morph = ThalamusNrnFileMorphology(
             morph_args["morph_path"],
-            do_replace_axon=morph_args["do_replace_axon"],
+            do_replace_axon=do_replace_axon,
             replace_axon_hoc=replace_axon_hoc,
+            morph_modifiers=morph_modifiers,
+            morph_modifiers_hoc=morph_modifiers_hoc,
         )
     else:
         raise ValueError(f"unsupported package type: {package_type}")
@@ -0,0 +1,135 @@
+"""Loading and conversion of the morphologies in the formats readable by MorphIO."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import logging
+from pathlib import Path
+
+import h5py
+import morphio
+
+logger = logging.getLogger(__name__)
+
+# formats that NEURON, and the hoc cell template, can load directly
+NEURON_EXTENSIONS = (".asc", ".swc")
+
+
+def is_h5_container(morph_path):
+    """Return True if a h5 file contains several morphologies, as in circuits.
+
+    A h5 morphology has its 'points' and 'structure' at the root of the file,
+    whereas a container has one group per morphology.
+
+    Args:
+        morph_path (str or Path): path to the h5 file
+
+    Returns:
+        bool: whether the file is a container of morphologies
+    """
+    with h5py.File(morph_path, "r") as h5_file:
+        return "structure" not in h5_file
+
+
+def is_collection(morph_path):
+    """Return True if the path is a directory or a h5 container of morphologies.
+
+    Args:
+        morph_path (str or Path): path to a morphology file, a directory
+            or a h5 container of morphologies
+
+    Returns:
+        bool: whether a morphology name is needed to load a morphology of the path
+    """
+    morph_path = Path(morph_path)
+    if morph_path.is_dir():
+        return True
+    return morph_path.suffix.lower() == ".h5" and is_h5_container(morph_path)
+
+
+def load_morphology(morph_path, morph_name="", mutable=False):
+    """Load a morphology in any format readable by MorphIO.
+
+    Args:
+        morph_path (str or Path): path to a morphology file, or to a directory
+            or a h5 container of morphologies
+        morph_name (str): name of the morphology in the directory or the container
+        mutable (bool): whether to return a mutable morphology
+
+    Raises:
+        ValueError: if a directory or a container is given without morphology name
+
+    Returns:
+        morphio.Morphology or morphio.mut.Morphology: the morphology
+    """
+    if is_collection(morph_path):
+        if not morph_name:
+            raise ValueError(
+                f"{morph_path} contains several morphologies: "
+                "the name of the morphology to load should be given."
+            )
+        return morphio.Collection(str(morph_path)).load(morph_name, mutable=mutable)
+    if mutable:
+        return morphio.mut.Morphology(str(morph_path))
+    return morphio.Morphology(str(morph_path))
+
+
+def convert_morphology(morph_path, output_path, morph_name=""):
+    """Write a morphology in the format given by the extension of the output path.
+
+    Args:
+        morph_path (str or Path): path to a morphology file, or to a directory
+            or a h5 container of morphologies
+        output_path (str or Path): path of the converted morphology,
+            e.g. 'morphology/cell.asc'
+        morph_name (str): name of the morphology in the directory or the container
+
+    Returns:
+        Path: path of the converted morphology
+    """
+    output_path = Path(output_path)
+    output_path.parent.mkdir(parents=True, exist_ok=True)
+    load_morphology(morph_path, morph_name, mutable=True).write(str(output_path))
+    logger.info("Converted %s %s to %s", morph_path, morph_name, output_path)
+    return output_path
+
+
+def get_neuron_morphology_path(morph_path, output_dir, morph_name=""):
+    """Return the path to a morphology file that NEURON can load, converting if needed.
+
+    The asc and swc files are used as they are. The other formats, e.g. h5,
+    and the morphologies of directories or h5 containers are converted
+    to asc files in output_dir, unless they have already been converted.
+
+    Args:
+        morph_path (str or Path): path to a morphology file, or to a directory
+            or a h5 container of morphologies
+        output_dir (str or Path): directory of the converted morphologies
+        morph_name (str): name of the morphology in the directory or the container
+
+    Returns:
+        Path: path to the asc or swc morphology file
+    """
+    morph_path = Path(morph_path)
+    if morph_path.suffix.lower() in NEURON_EXTENSIONS:
+        return morph_path
+
+    output_path = Path(output_dir) / f"{morph_name or morph_path.stem}.asc"
+    # already converted since the last change of the source
+    if (
+        output_path.is_file()
+        and output_path.stat().st_mtime >= morph_path.stat().st_mtime
+    ):
+        return output_path
+    return convert_morphology(morph_path, output_path, morph_name)
@@ -0,0 +1,239 @@
+"""Modifiers of the morphology, applied before the mechanisms are inserted."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import logging
+
+from emodelrunner.hooks import load_hook
+from emodelrunner.morphology.morphology import create_section_array
+
+logger = logging.getLogger(__name__)
+
+DENDRITE_SECLISTS = ("basal", "apical")
+
+# registered modifiers and their hoc code, None if they have none
+_morph_modifiers = {}
+
+
+def register_morph_modifier(modifier, hoc=None):
+    """Register a function modifying every morphology created afterwards.
+
+    The modifier is called as modifier(sim=sim, icell=icell) once the morphology
+    is loaded and its axon replaced, before the mechanisms are inserted.
+    Can be used as a decorator.
+
+    Args:
+        modifier (callable): the modifier to register
+        hoc (str): hoc code doing the same modification in the hoc template,
+            appended to the replace_axon hoc code
+
+    Raises:
+        TypeError: if modifier is not callable
+
+    Returns:
+        callable: the registered modifier
+    """
+    if not callable(modifier):
+        raise TypeError(f"Morphology modifier {modifier} is not callable.")
+    _morph_modifiers[modifier] = hoc
+    return modifier
+
+
+def unregister_morph_modifier(modifier):
+    """Unregister a morphology modifier.
+
+    Args:
+        modifier (callable): the modifier to unregister
+    """
+    _morph_modifiers.pop(modifier, None)
+
+
+def clear_morph_modifiers():
+    """Unregister all the morphology modifiers."""
+    _morph_modifiers.clear()
+
+
+def get_registered_morph_modifiers():
+    """Return the registered morphology modifiers and their hoc code.
+
+    Returns:
+        dict: hoc code, None if not given, of each modifier, in registration order
+    """
+    return dict(_morph_modifiers)
+
+
+def scale_dendrite_diameters(factor, seclist_names=DENDRITE_SECLISTS):
+    """Return a modifier multiplying the diameters of the dendrites by a factor.
+
+    Args:
+        factor (float): scaling factor of the diameters
+        seclist_names (tuple): names of the scaled section lists
+
+    Returns:
+        callable: the morphology modifier
+    """
+
+    def scale(sim=None, icell=None):
+        # pylint: disable=unused-argument
+        for seclist_name in seclist_names:
+            for section in getattr(icell, seclist_name):
+                for seg in section:
+                    seg.diam = seg.diam * factor
+        logger.debug("Scaled the diameters of %s by %s", seclist_names, factor)
+
+    return scale
+
+
+def prune_dendrites(max_distance, seclist_names=DENDRITE_SECLISTS):
+    """Return a modifier deleting the dendritic sections beyond a distance.
+
+    The sections starting farther than max_distance from the soma are deleted,
+    so that the remaining sections all start within max_distance.
+
+    Args:
+        max_distance (float): path distance from the soma (um)
+        seclist_names (tuple): names of the pruned section lists
+
+    Returns:
+        callable: the morphology modifier
+    """
+
+    def prune(sim=None, icell=None):
+        soma_center = icell.soma[0](0.5)
+        pruned = [
+            section
+            for seclist_name in seclist_names
+            for section in getattr(icell, seclist_name)
+            if sim.neuron.h.distance(soma_center, section(0.0)) > max_distance
+        ]
+        for section in pruned:
+            sim.neuron.h.delete_section(sec=section)
+        logger.debug(
+            "Pruned %d sections of %s beyond %s um",
+            len(pruned),
+            seclist_names,
+            max_distance,
+        )
+
+    return prune
+
+
+def load_ais(ais_path):
+    """Load the axon initial segment, and optionally the myelin, from a json file.
+
+    The file has the structure
+    {"ais": {"length": 60.0, "diameters": [1.9, 1.7, ...]},
+    "myelin": {"length": 1000.0, "diameter": 1.0}},
+    with one diameter (um) per segment of the AIS and an optional myelin.
+
+    Args:
+        ais_path (str): path to the json file
+
+    Raises:
+        ValueError: if the AIS has no diameter or a non-positive length
+
+    Returns:
+        dict: the AIS and the myelin definitions
+    """
+    with open(ais_path, "r", encoding="utf-8") as ais_file:
+        ais_definition = json.load(ais_file)
+
+    ais = ais_definition["ais"]
+    if not ais["diameters"] or ais["length"] <= 0:
+        raise ValueError(
+            f"The AIS of {ais_path} should have diameters and a positive length."
+        )
+    return ais_definition
+
+
+def replace_axon_with_ais(ais_path):
+    """Return a modifier replacing the axon by an AIS given in a file.
+
+    The AIS is a single axon section with one segment per diameter of the file,
+    followed by a myelin section if the file defines it.
+    Should be used without do_replace_axon.
+
+    Args:
+        ais_path (str): path to the json file defining the AIS, see load_ais
+
+    Returns:
+        callable: the morphology modifier
+    """
+    ais_definition = load_ais(ais_path)
+
+    def replace_axon(sim=None, icell=None):
+        for section in icell.axonal:
+            sim.neuron.h.delete_section(sec=section)
+
+        create_section_array(sim, icell, "axon", 1)
+        ais = icell.axon[0]
+        ais.L = ais_definition["ais"]["length"]
+        ais.nseg = len(ais_definition["ais"]["diameters"])
+        for seg, diam in zip(ais, ais_definition["ais"]["diameters"]):
+            seg.diam = diam
+        icell.axonal.append(sec=ais)
+        icell.all.append(sec=ais)
+        ais.connect(icell.soma[0], 1.0, 0.0)
+
+        if "myelin" in ais_definition:
+            create_section_array(sim, icell, "myelin", 1)
+            myelin = icell.myelin[0]
+            myelin.nseg = 5
+            myelin.L = ais_definition["myelin"]["length"]
+            myelin.diam = ais_definition["myelin"]["diameter"]
+            icell.myelinated.append(sec=myelin)
+            icell.all.append(sec=myelin)
+            myelin.connect(ais, 1.0, 0.0)
+
+        logger.debug("Replaced the axon with the AIS of %s", ais_path)
+
+    return replace_axon
+
+
+def get_morph_modifiers(morph_args):
+    """Return the morphology modifiers of the configuration and the registered ones.
+
+    The AIS replacing the axon comes first, then the scaling of the dendrites,
+    their pruning, the modifiers of the configuration and the registered ones.
+
+    Args:
+        morph_args (dict): morphology-related configuration
+
+    Raises:
+        ValueError: if the axon type is 'ais' without ais_path
+
+    Returns:
+        tuple: the list of modifiers, and the list of their hoc code,
+            None if some modifiers have no hoc code
+    """
+    modifiers = {}
+    if morph_args.get("axon_type") == "ais":
+        if not morph_args.get("ais_path"):
+            raise ValueError("The 'ais' axon type needs the ais_path of the AIS.")
+        modifiers[replace_axon_with_ais(morph_args["ais_path"])] = None
+    if morph_args.get("dendrite_diameter_scale", 1.0) != 1.0:
+        scale = scale_dendrite_diameters(morph_args["dendrite_diameter_scale"])
+        modifiers[scale] = None
+    if morph_args.get("prune_distance") is not None:
+        modifiers[prune_dendrites(morph_args["prune_distance"])] = None
+    for modifier_path in morph_args.get("modifiers", []):
+        modifiers[load_hook(modifier_path)] = None
+    modifiers.update(get_registered_morph_modifiers())
+
+    hoc = list(modifiers.values())
+    if any(modifier_hoc is None for modifier_hoc in hoc):
+        hoc = None
+    return list(modifiers), hoc
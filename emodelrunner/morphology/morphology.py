@@ -22,6 +22,22 @@ from bluepyopt import ephys
 logger = logging.getLogger(__name__)
 
 
+def create_section_array(sim, icell, name, size):
+    """Create an array of sections in a cell, replacing the existing array.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        icell (neuron cell): cell instantiation in simulator,
+            either a hoc template or a python cell
+        name (str): name of the section array, e.g. 'axon'
+        size (int): number of sections
+    """
+    if hasattr(icell, "create_section_array"):
+        icell.create_section_array(name, size)
+    else:
+        sim.neuron.h.execute(f"create {name}[{size}]", icell)
+
+
 class SSCXNrnFileMorphology(ephys.morphologies.NrnFileMorphology):
     """Custom Morphology.
 
@@ -81,7 +97,7 @@ class SSCXNrnFileMorphology(ephys.morphologies.NrnFileMorphology):
             sim.neuron.h.delete_section(sec=section)
 
         #  new axon array
-        sim.neuron.h.execute("create axon[2]", icell)
+        create_section_array(sim, icell, "axon", 2)
 
         L_real = 0
         count = 0
@@ -101,7 +117,7 @@ class SSCXNrnFileMorphology(ephys.morphologies.NrnFileMorphology):
         icell.axon[0].connect(icell.soma[0], 1.0, 0.0)
         icell.axon[1].connect(icell.axon[0], 1.0, 0.0)
 
-        sim.neuron.h.execute("create myelin[1]", icell)
+        create_section_array(sim, icell, "myelin", 1)
         icell.myelinated.append(sec=icell.myelin[0])
         icell.all.append(sec=icell.myelin[0])
         icell.myelin[0].nseg = 5
@@ -187,7 +203,7 @@ class ThalamusNrnFileMorphology(ephys.morphologies.NrnFileMorphology):
             sim.neuron.h.delete_section(sec=section)
 
         #  new axon array
-        sim.neuron.h.execute("create axon[2]", icell)
+        create_section_array(sim, icell, "axon", 2)
 
         L_real = 0
         count = 0
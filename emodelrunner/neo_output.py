@@ -0,0 +1,240 @@
+"""Conversion of the recorded and injected traces to Neo objects."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import numpy as np
+
+from emodelrunner.nwb_output import VARIABLE_UNITS, get_sampling
+from emodelrunner.output import get_trace_data
+from emodelrunner.results import detect_spikes, parse_output_filename
+from emodelrunner.units import SCALAR_RESPONSE_UNITS, UNITS, is_quantity, to_magnitude
+
+# NEURON units written as quantities units
+NEO_UNITS = {"mA/cm2": "mA/cm**2"}
+
+
+def get_variable_units(variable):
+    """Return the units of a recorded variable, as understood by quantities.
+
+    Args:
+        variable (str): recorded variable, e.g. 'v' or 'ina'
+
+    Returns:
+        str: units of the variable, 'dimensionless' if unknown
+    """
+    if variable == "v":
+        return UNITS["voltage"]
+    units = VARIABLE_UNITS.get(variable, "dimensionless")
+    return NEO_UNITS.get(units, units)
+
+
+def create_signal(name, time, data, units, annotations):
+    """Create a Neo signal, regularly sampled if the time steps are constant.
+
+    Args:
+        name (str): name of the signal
+        time (numpy.ndarray): time of the samples (ms)
+        data (numpy.ndarray): values, with one column per channel
+        units (str): units of the values
+        annotations (dict): annotations of the signal, e.g. the protocol
+
+    Returns:
+        neo.AnalogSignal or neo.IrregularlySampledSignal: the signal
+    """
+    # pylint: disable=import-outside-toplevel
+    import neo
+    import quantities as pq
+
+    sampling = get_sampling(time)
+    if "rate" in sampling:
+        return neo.AnalogSignal(
+            data,
+            units=units,
+            t_start=sampling["starting_time"] * pq.s,
+            sampling_rate=sampling["rate"] * pq.Hz,
+            name=name,
+            **annotations,
+        )
+    return neo.IrregularlySampledSignal(
+        np.asarray(time, dtype=float) * pq.ms,
+        data,
+        units=units,
+        time_units="ms",
+        name=name,
+        **annotations,
+    )
+
+
+def is_scalar(response):
+    """Return True if the response is a scalar, e.g. the holding current.
+
+    Args:
+        response: any response, with or without units
+
+    Returns:
+        bool: whether the response is a single number
+    """
+    if is_quantity(response):
+        return np.ndim(response.magnitude) == 0
+    return isinstance(response, (int, float, np.number))
+
+
+def add_signal(segment, signal):
+    """Add a regularly or irregularly sampled signal to a segment.
+
+    Args:
+        segment (neo.Segment): the segment of the protocol
+        signal (neo.AnalogSignal or neo.IrregularlySampledSignal): the signal
+    """
+    # pylint: disable=import-outside-toplevel
+    import neo
+
+    if isinstance(signal, neo.AnalogSignal):
+        segment.analogsignals.append(signal)
+    else:
+        segment.irregularlysampledsignals.append(signal)
+
+
+def get_segment(block, protocol):
+    """Return the segment of a protocol, and create it if needed.
+
+    Args:
+        block (neo.Block): the block of the run
+        protocol (str): name of the protocol
+
+    Returns:
+        neo.Segment: the segment of the protocol
+    """
+    # pylint: disable=import-outside-toplevel
+    import neo
+
+    for segment in block.segments:
+        if segment.name == protocol:
+            return segment
+    segment = neo.Segment(name=protocol, index=len(block.segments))
+    block.segments.append(segment)
+    return segment
+
+
+def add_response(block, key, response, spike_threshold):
+    """Add a recorded response to the segment of its protocol.
+
+    The voltage traces also get the spike train of their threshold crossings.
+    Lists of responses, e.g. one per synapse, are stored as one signal
+    with one channel per response.
+
+    Args:
+        block (neo.Block): the block of the run
+        key (str): response key
+        response: the response, with its 'time' and 'voltage'
+        spike_threshold (float): voltage threshold for spike detection (mV)
+    """
+    # pylint: disable=import-outside-toplevel
+    import neo
+    import quantities as pq
+
+    metadata = parse_output_filename(key)
+    variable, time, data = get_trace_data(key, response, metadata["variable"])
+    time = np.asarray(to_magnitude(time, UNITS["time"]), dtype=float)
+    data = np.asarray(to_magnitude(data, UNITS["voltage"]))
+    if data.ndim == 1:
+        data = data[:, np.newaxis]
+
+    annotations = {
+        "key": key,
+        "protocol": metadata["protocol"] or key,
+        "location": metadata["location"] or "soma",
+        "variable": variable,
+    }
+    segment = get_segment(block, annotations["protocol"])
+    add_signal(
+        segment,
+        create_signal(key, time, data, get_variable_units(variable), annotations),
+    )
+
+    if variable == "v" and data.shape[1] == 1 and len(time) > 0:
+        segment.spiketrains.append(
+            neo.SpikeTrain(
+                detect_spikes(time, data[:, 0], spike_threshold) * pq.ms,
+                t_start=time[0] * pq.ms,
+                t_stop=time[-1] * pq.ms,
+                name=key,
+                threshold=spike_threshold,
+                **annotations,
+            )
+        )
+
+
+def responses_to_neo(
+    responses, currents=None, name="", spike_threshold=-20.0, annotations=None
+):
+    """Convert the responses of a run to a Neo block, e.g. for Elephant analyses.
+
+    The block has one segment per protocol, containing the recorded signals,
+    the injected currents and the spike trains of the voltage traces.
+    Traces recorded with the variable time step are irregularly sampled signals.
+    The scalar responses, e.g. the holding current, are annotations of the block.
+
+    Args:
+        responses (dict): responses keyed by recording name, as returned by run,
+            with or without units
+        currents (dict): injected currents keyed by name, if any,
+            with structure "key": {"time": time, "current": current}
+        name (str): name of the block, e.g. the e-model
+        spike_threshold (float): voltage threshold for spike detection (mV)
+        annotations (dict): additional annotations of the block, e.g. the provenance
+
+    Returns:
+        neo.Block: the block of the run
+    """
+    # pylint: disable=import-outside-toplevel
+    import neo
+
+    block = neo.Block(name=name, **(annotations or {}))
+    currents = dict(currents or {})
+    for key, response in responses.items():
+        # e.g. the threshold current when no spike is found
+        if response is None:
+            continue
+        if key.startswith("current_"):
+            # e.g. the currents returned with the recordings by CellRunner
+            currents[key] = response
+        elif is_scalar(response):
+            unit = SCALAR_RESPONSE_UNITS.get(key.split(".")[-1])
+            if unit is None:
+                value = getattr(response, "magnitude", response)
+            else:
+                value = to_magnitude(response, unit)
+            block.annotate(**{key: float(value)})
+        else:
+            add_response(block, key, response, spike_threshold)
+
+    for key, current in currents.items():
+        metadata = parse_output_filename(key)
+        protocol = metadata["protocol"] or key
+        time = np.asarray(to_magnitude(current["time"], UNITS["time"]), dtype=float)
+        data = np.asarray(to_magnitude(current["current"], UNITS["current"]))
+        add_signal(
+            get_segment(block, protocol),
+            create_signal(
+                key,
+                time,
+                data[:, np.newaxis],
+                UNITS["current"],
+                {"key": key, "protocol": protocol, "variable": "current"},
+            ),
+        )
+
+    return block
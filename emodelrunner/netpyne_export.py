@@ -0,0 +1,255 @@
+"""Export of the instantiated cell as a NetPyNE cell rule."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import logging
+from pathlib import Path
+
+from bluepyopt import ephys
+
+from emodelrunner.create_cells import create_cell_using_config
+from emodelrunner.load import get_release_params, load_config
+from emodelrunner.logging_utilities import neuron_output_to_logger
+from emodelrunner.parsing_utilities import get_netpyne_parser_args, set_verbosity
+
+logger = logging.getLogger(__name__)
+
+# section lists of the cell template exported as NetPyNE secLists
+SECTIONLISTS = ["all", "somatic", "axonal", "basal", "apical", "myelinated"]
+
+# NetPyNE ion variables with the corresponding NEURON variable names
+ION_VARIABLES = {"e": "e{}", "i": "{}i", "o": "{}o"}
+
+
+def get_section_name(section):
+    """Return the NetPyNE name of a section, e.g. soma_0 for cADpyr_L4UPC[0].soma[0].
+
+    Args:
+        section (neuron.nrn.Section): section of the cell
+
+    Returns:
+        str: name of the section
+    """
+    name = section.name().split(".")[-1]
+    return name.replace("[", "_").replace("]", "")
+
+
+def compress_values(values):
+    """Return a single value if the values of all the segments are equal.
+
+    Args:
+        values (list): values of a variable in each segment of a section
+
+    Returns:
+        float or list of float: the value if uniform, else the value of each segment
+    """
+    values = [float(value) for value in values]
+    if all(value == values[0] for value in values):
+        return values[0]
+    return values
+
+
+def get_mechanism_parameter_names(mech_name, h):
+    """Return the names of the parameters of a density mechanism, without suffix.
+
+    Args:
+        mech_name (str): name of the mechanism, e.g. NaTg
+        h (neuron.hoc.HocObject): neuron hoc interpreter
+
+    Returns:
+        list of str: names of the parameters, e.g. gNaTgbar
+    """
+    # 1 is for the variables declared in the PARAMETER block
+    mech_standard = h.MechanismStandard(mech_name, 1)
+    name = h.ref("")
+    param_names = []
+    for idx in range(int(mech_standard.count())):
+        mech_standard.name(name, idx)
+        param_names.append(name[0][: -len(mech_name) - 1])
+    return param_names
+
+
+def get_section_geometry(section, h):
+    """Return the geometry of a section in the NetPyNE format.
+
+    Args:
+        section (neuron.nrn.Section): section of the cell
+        h (neuron.hoc.HocObject): neuron hoc interpreter
+
+    Returns:
+        dict: geometry of the section
+    """
+    geom = {
+        "L": section.L,
+        "nseg": section.nseg,
+        "Ra": section.Ra,
+        "cm": compress_values([seg.cm for seg in section]),
+    }
+    n3d = int(h.n3d(sec=section))
+    if n3d:
+        geom["pt3d"] = [
+            [
+                h.x3d(idx, sec=section),
+                h.y3d(idx, sec=section),
+                h.z3d(idx, sec=section),
+                h.diam3d(idx, sec=section),
+            ]
+            for idx in range(n3d)
+        ]
+    else:
+        # e.g. the stub axon replacing the axon of the morphology
+        geom["diam"] = section.diam
+    return geom
+
+
+def get_section_topology(section, h):
+    """Return the connection of a section to its parent in the NetPyNE format.
+
+    Args:
+        section (neuron.nrn.Section): section of the cell
+        h (neuron.hoc.HocObject): neuron hoc interpreter
+
+    Returns:
+        dict: parent section and connection points. Empty for the root section.
+    """
+    parent_seg = section.parentseg()
+    if parent_seg is None:
+        return {}
+    return {
+        "parentSec": get_section_name(parent_seg.sec),
+        "parentX": parent_seg.x,
+        "childX": h.section_orientation(sec=section),
+    }
+
+
+def get_section_mechanisms(section, h):
+    """Return the density mechanisms and ions of a section in the NetPyNE format.
+
+    Only the parameters of the mechanisms are exported, not their state variables.
+
+    Args:
+        section (neuron.nrn.Section): section of the cell
+        h (neuron.hoc.HocObject): neuron hoc interpreter
+
+    Returns:
+        tuple: mechanisms (dict) and ions (dict) of the section
+    """
+    psection = section.psection()
+
+    mechs = {}
+    for mech_name, mech_values in psection["density_mechs"].items():
+        param_names = get_mechanism_parameter_names(mech_name, h)
+        mechs[mech_name] = {
+            param_name: compress_values(values)
+            for param_name, values in mech_values.items()
+            if param_name in param_names
+        }
+
+    ions = {}
+    for ion_name, ion_values in psection["ions"].items():
+        ions[ion_name] = {
+            key: compress_values(ion_values[var.format(ion_name)])
+            for key, var in ION_VARIABLES.items()
+            if var.format(ion_name) in ion_values
+        }
+
+    return mechs, ions
+
+
+def create_netpyne_cell_rule(icell, h, cell_type=""):
+    """Convert an instantiated cell into a NetPyNE cell rule.
+
+    Args:
+        icell (neuron.hoc.HocObject): instantiated cell template, e.g. cell.icell
+        h (neuron.hoc.HocObject): neuron hoc interpreter
+        cell_type (str): cell type used in the conditions of the rule
+
+    Returns:
+        dict: cell rule, to be added to netParams.cellParams
+    """
+    secs = {}
+    for section in icell.all:
+        mechs, ions = get_section_mechanisms(section, h)
+        secs[get_section_name(section)] = {
+            "geom": get_section_geometry(section, h),
+            "topol": get_section_topology(section, h),
+            "mechs": mechs,
+            "ions": ions,
+        }
+
+    sec_lists = {
+        sectionlist: [
+            get_section_name(section) for section in getattr(icell, sectionlist)
+        ]
+        for sectionlist in SECTIONLISTS
+        if hasattr(icell, sectionlist)
+    }
+
+    return {
+        "conds": {"cellType": cell_type},
+        "secs": secs,
+        "secLists": sec_lists,
+        "globals": {"celsius": h.celsius, "v_init": h.v_init},
+    }
+
+
+def export_netpyne_cell_rule(config_path, output_path):
+    """Instantiate the cell of a config and write it as a NetPyNE cell rule json file.
+
+    The rule can be loaded in a network model with
+    netParams.cellParams[label] = json.load(rule_file).
+
+    Args:
+        config_path (str): path to config file
+        output_path (str): path to the json file to write
+
+    Returns:
+        dict: cell rule
+    """
+    config = load_config(config_path=config_path)
+
+    cell = create_cell_using_config(config)
+    release_params = get_release_params(config)
+    sim = ephys.simulators.NrnSimulator(
+        dt=config.getfloat("Sim", "dt"),
+        cvode_active=config.getboolean("Sim", "cvode_active"),
+    )
+    # global parameters such as celsius and v_init are set at instantiation
+    cell.freeze(release_params)
+    cell.instantiate(sim=sim)
+
+    cell_rule = create_netpyne_cell_rule(
+        cell.icell, sim.neuron.h, config.get("Cell", "emodel")
+    )
+
+    cell.destroy(sim=sim)
+    cell.unfreeze(release_params.keys())
+
+    output_path = Path(output_path)
+    output_path.parent.mkdir(parents=True, exist_ok=True)
+    with open(output_path, "w", encoding="utf-8") as output_file:
+        json.dump(cell_rule, output_file, indent=4)
+    logger.info("NetPyNE cell rule written in %s", output_path)
+
+    return cell_rule
+
+
+if __name__ == "__main__":
+    args = get_netpyne_parser_args()
+    set_verbosity(args.verbosity, args.log_level, args.log_file)
+
+    with neuron_output_to_logger():
+        export_netpyne_cell_rule(args.config_path, args.output_path)
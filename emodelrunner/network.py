@@ -0,0 +1,480 @@
+"""Simulation of a few connected cells of a SONATA circuit."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import json
+import logging
+import os
+import time
+from pathlib import Path
+
+import numpy as np
+from bluepyopt import ephys
+
+from emodelrunner.configuration import PackageType
+from emodelrunner.create_cells import create_cell
+from emodelrunner.json_utilities import NpEncoder
+from emodelrunner.load import (
+    get_morph_args,
+    get_stochastic_args,
+    load_config,
+    load_emodel_params,
+    load_synapse_configuration_data,
+)
+from emodelrunner.morphology import create_morphology
+from emodelrunner.morphology.formats import get_neuron_morphology_path
+from emodelrunner.output import write_responses
+from emodelrunner.progress import finish_progress_report
+from emodelrunner.provenance import get_provenance, write_provenance
+from emodelrunner.results import detect_spikes
+from emodelrunner.simulators import create_simulator
+from emodelrunner.sonata import (
+    get_afferent_synapses,
+    get_morphology_section_locations,
+    get_node_data,
+)
+from emodelrunner.synapses.mechanism import NrnMODPointProcessMechanismCustom
+from emodelrunner.synapses.spike_trains import load_spike_trains
+from emodelrunner.synapses.stimuli import NrnVecStimStimulusCustom
+
+logger = logging.getLogger(__name__)
+
+# threshold of the NetCons connecting the soma of a cell to its synapses (mV)
+SPIKE_THRESHOLD = -30.0
+
+
+def get_network_node_ids(circuit_config, node_population, node_set="", node_ids=None):
+    """Return the node ids of the cells of the network.
+
+    Args:
+        circuit_config (libsonata.CircuitConfig): circuit config
+        node_population (str): name of the node population
+        node_set (str): name of a node set of the node sets file of the circuit
+        node_ids (list of int): ids of the nodes, used if no node set is given
+
+    Raises:
+        ValueError: if both or none of node_set and node_ids are given,
+            or if there is no node in the network
+
+    Returns:
+        list of int: sorted node ids
+    """
+    if bool(node_set) == bool(node_ids):
+        raise ValueError("Give either a node set or node ids for the network.")
+    if node_set:
+        # pylint: disable=import-error,import-outside-toplevel
+        import libsonata
+
+        node_sets = libsonata.NodeSets.from_file(circuit_config.node_sets_path)
+        node_ids = node_sets.materialize(
+            node_set, circuit_config.node_population(node_population)
+        ).flatten()
+
+    node_ids = sorted({int(node_id) for node_id in node_ids})
+    if not node_ids:
+        raise ValueError(f"The node set {node_set} of {node_population} is empty.")
+    return node_ids
+
+
+def split_synapses(synapses, node_population, node_ids, input_population=""):
+    """Split the afferent synapses of a cell into internal and external synapses.
+
+    Args:
+        synapses (list of dicts): data of each synapse, as returned by
+            sonata.get_afferent_synapses
+        node_population (str): name of the node population of the network
+        node_ids (list of int): node ids of the cells of the network
+        input_population (str): node population of the replayed spikes.
+            The external synapses of the other populations are left out.
+            All the external synapses are kept if empty.
+
+    Returns:
+        a tuple containing
+
+        - list of dicts: synapses whose presynaptic cell is in the network
+        - list of dicts: synapses whose presynaptic cell is outside of the network
+    """
+    node_ids = set(node_ids)
+    internal = []
+    external = []
+    for synapse in synapses:
+        if (
+            synapse["pre_population"] == node_population
+            and synapse["pre_cell_id"] - 1 in node_ids
+        ):
+            internal.append(synapse)
+        elif not input_population or synapse["pre_population"] == input_population:
+            external.append(synapse)
+    return internal, external
+
+
+def create_network_cell(config, circuit_config, node_population, node_id, network):
+    """Create a cell of the network from its node in the circuit.
+
+    The e-model, the morphology and the afferent synapses are the ones of the node.
+    The mechanisms, the synapse configuration and the other settings are the ones
+    of the config of the template cell package.
+
+    Args:
+        config (configparser.ConfigParser): configuration of the template package
+        circuit_config (libsonata.CircuitConfig): circuit config
+        node_population (str): name of the node population
+        node_id (int): id of the node in the population
+        network (dict): node ids of the network and population of the input spikes,
+            see run_network
+
+    Returns:
+        a tuple containing
+
+        - CellModelCustom: cell model, with its internal_synapses mechanism,
+          and its external_synapses mechanism if spikes are replayed
+        - dict: emodel, mtype, gid and number of internal and external synapses
+    """
+    # pylint: disable=too-many-locals
+    node_data = get_node_data(circuit_config, node_population, node_id)
+    gid = node_id + 1
+
+    morph_args = get_morph_args(config)
+    morph_args["morph_path"] = str(
+        get_neuron_morphology_path(
+            node_data["morph_path"],
+            config.get("Paths", "converted_morph_dir"),
+            node_data["morph_name"],
+        )
+    )
+    cell = create_cell(
+        config.get("Paths", "unoptimized_params_path"),
+        # each cell has its own hoc template
+        f"{node_data['emodel']}_{gid}",
+        False,
+        create_morphology(morph_args, config.package_type),
+        gid,
+        v_init=config.getfloat("Cell", "v_init"),
+        celsius=config.getfloat("Cell", "celsius"),
+        instantiation=config.get("Cell", "instantiation"),
+        stochastic_args=get_stochastic_args(config),
+    )
+
+    synapses, _ = get_afferent_synapses(
+        circuit_config,
+        node_population,
+        node_id,
+        get_morphology_section_locations(
+            node_data["morph_path"], node_data["morph_name"]
+        ),
+    )
+    weight_scale = config.getfloat("Synapses", "weight_scale")
+    for synapse in synapses:
+        synapse["weight"] *= weight_scale
+    internal, external = split_synapses(
+        synapses, node_population, network["node_ids"], network["input_population"]
+    )
+
+    synconf_dict = load_synapse_configuration_data(
+        os.path.join(
+            config.get("Paths", "syn_dir"), config.get("Paths", "syn_conf_file")
+        )
+    )
+    mechanisms = [("internal_synapses", internal)]
+    if network["replay"]:
+        mechanisms.append(("external_synapses", external))
+    for name, mechanism_synapses in mechanisms:
+        cell.mechanisms.append(
+            NrnMODPointProcessMechanismCustom(
+                name,
+                mechanism_synapses,
+                synconf_dict,
+                config.getint("Synapses", "seed"),
+                config.get("Synapses", "rng_settings_mode"),
+            )
+        )
+
+    cell_data = {
+        "gid": gid,
+        "emodel": node_data["emodel"],
+        "mtype": node_data["mtype"],
+        "n_internal_synapses": len(internal),
+        "n_external_synapses": len(external),
+    }
+    return cell, cell_data
+
+
+def get_mechanism(cell, name):
+    """Return a mechanism of a cell model by name.
+
+    Args:
+        cell (CellModelCustom): cell model
+        name (str): name of the mechanism
+
+    Returns:
+        the mechanism, or None if the cell has no such mechanism
+    """
+    for mechanism in cell.mechanisms:
+        if mechanism.name == name:
+            return mechanism
+    return None
+
+
+def connect_cells(cells, sim):
+    """Connect the soma of each cell to the internal synapses it projects to.
+
+    Args:
+        cells (dict): instantiated cell model of each node id
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+
+    Returns:
+        list: the NetCons, to be kept during the simulation
+    """
+    netcons = []
+    for cell in cells.values():
+        for synapse in get_mechanism(cell, "internal_synapses").pprocesses:
+            precell = cells[synapse.pre_cell_id - 1]
+            # M. Hines magic to return a variable by reference to a python function
+            netcon = sim.neuron.h.ref(None)
+            precell.icell.getCell().connect2target(synapse.hsynapse, netcon)
+            netcon = netcon[0]
+            netcon.weight[0] = synapse.weight
+            netcon.delay = synapse.delay
+            netcons.append(netcon)
+    return netcons
+
+
+def simulate_network(
+    cells, param_values, sim, tstop, cvode_active, spike_trains=None, prefix=""
+):
+    """Simulate the connected cells, replaying the input spikes if any.
+
+    Args:
+        cells (dict): cell model of each node id
+        param_values (dict): optimized parameters of each node id
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        tstop (float): duration of the simulation (ms)
+        cvode_active (bool): whether to use variable time step
+        spike_trains (dict): spike times of the presynaptic cells outside
+            of the network {gid: spike_times}, replayed on the external synapses
+        prefix (str): prefix of the recording names
+
+    Returns:
+        dict: somatic voltage of each cell, keyed by recording name
+            '{prefix}.{node_id}.soma.v'
+    """
+    # pylint: disable=too-many-arguments
+    stimuli = []
+    try:
+        for node_id, cell in cells.items():
+            cell.freeze(param_values[node_id])
+            cell.instantiate(sim=sim)
+        netcons = connect_cells(cells, sim)
+
+        if spike_trains is not None:
+            for cell in cells.values():
+                location = ephys.locations.NrnPointProcessLocation(
+                    "external_synapses", get_mechanism(cell, "external_synapses")
+                )
+                stimulus = NrnVecStimStimulusCustom(
+                    locations=[location],
+                    start=0.0,
+                    stop=tstop,
+                    pre_spike_trains=spike_trains,
+                )
+                stimulus.instantiate(sim=sim, icell=cell.icell)
+                stimuli.append(stimulus)
+
+        time = sim.neuron.h.Vector()
+        time.record(sim.neuron.h._ref_t)  # pylint: disable=protected-access
+        voltages = {}
+        for node_id, cell in cells.items():
+            voltages[node_id] = sim.neuron.h.Vector()
+            voltages[node_id].record(cell.icell.soma[0](0.5)._ref_v)
+
+        logger.info(
+            "Simulating %d cells connected by %d synapses...", len(cells), len(netcons)
+        )
+        sim.run(tstop, cvode_active=cvode_active)
+
+        return {
+            f"{prefix}.{node_id}.soma.v": {
+                "time": np.array(time),
+                "voltage": np.array(voltage),
+            }
+            for node_id, voltage in voltages.items()
+        }
+    finally:
+        for stimulus in stimuli:
+            stimulus.destroy(sim=sim)
+        for node_id, cell in cells.items():
+            cell.destroy(sim=sim)
+            cell.unfreeze(param_values[node_id].keys())
+
+
+def get_network_spikes(responses, cells_data, prefix=""):
+    """Detect the spikes of each cell of the network.
+
+    Args:
+        responses (dict): somatic voltages, as returned by simulate_network
+        cells_data (dict): data of each node id, with its gid
+        prefix (str): prefix of the recording names
+
+    Returns:
+        dict: spike times of each cell {gid: spike_times}
+    """
+    spikes = {}
+    for node_id, cell_data in cells_data.items():
+        response = responses[f"{prefix}.{node_id}.soma.v"]
+        spikes[cell_data["gid"]] = detect_spikes(
+            response["time"], response["voltage"], SPIKE_THRESHOLD
+        )
+    return spikes
+
+
+def write_spikes(spikes, output_path):
+    """Write the spikes in the out.dat format of neurodamus, sorted by time.
+
+    Args:
+        spikes (dict): spike times of each cell {gid: spike_times}
+        output_path (str or Path): path to the text file
+    """
+    all_spikes = sorted(
+        (float(time), gid)
+        for gid, spike_times in spikes.items()
+        for time in spike_times
+    )
+    with open(output_path, "w", encoding="utf-8") as spikes_file:
+        spikes_file.write("/scatter\n")
+        for time, gid in all_spikes:
+            spikes_file.write(f"{time:.3f}\t{gid}\n")
+
+
+def run_network(
+    config_path,
+    circuit_config_path,
+    node_population,
+    output_dir,
+    node_set="",
+    node_ids=None,
+    spikes_path="",
+    spike_population="",
+    tstop=1000.0,
+    config_overrides=None,
+):
+    """Simulate a few cells of a SONATA circuit with their interconnections.
+
+    The synapses between the cells of the network are connected to the soma
+    of their presynaptic cell. The spikes of the presynaptic cells outside
+    of the network can be replayed on the other synapses from a spikes file.
+
+    Args:
+        config_path (str): path to the config of a sscx template cell package,
+            whose parameters file contains the e-models of the nodes
+        circuit_config_path (str): path to the SONATA circuit config
+        node_population (str): name of the node population
+        output_dir (str): directory in which to write the traces, the spikes
+            and the summary of the network
+        node_set (str): name of the node set of the cells
+        node_ids (list of int): ids of the cells, used if no node set is given
+        spikes_path (str): path to the spikes file replayed on the external synapses.
+            See synapses.spike_trains.load_spike_trains for the formats.
+            The external synapses are not instantiated if empty.
+        spike_population (str): node population of the spikes file
+        tstop (float): duration of the simulation (ms)
+        config_overrides (list of str): values overriding the ones of the config file,
+            given as 'section.key=value', e.g. 'Cell.celsius=36'
+
+    Raises:
+        ValueError: if the config is not a sscx config, or if an e-model of the nodes
+            is not in the parameters file
+
+    Returns:
+        a tuple containing
+
+        - dict: somatic voltage of each cell, keyed by recording name
+        - dict: spike times of each cell {gid: spike_times}
+    """
+    # pylint: disable=too-many-arguments, too-many-locals, import-outside-toplevel
+    # pylint: disable=import-error
+    import libsonata
+
+    start_time = time.perf_counter()
+    config = load_config(config_path=config_path, config_overrides=config_overrides)
+    if config.package_type != PackageType.sscx:
+        raise ValueError("The network needs the config of a sscx template package.")
+
+    circuit_config = libsonata.CircuitConfig.from_file(circuit_config_path)
+    network = {
+        "node_ids": get_network_node_ids(
+            circuit_config, node_population, node_set, node_ids
+        ),
+        "input_population": spike_population,
+        "replay": bool(spikes_path),
+    }
+    spike_trains = (
+        load_spike_trains(spikes_path, spike_population) if spikes_path else None
+    )
+
+    cells = {}
+    cells_data = {}
+    param_values = {}
+    params_path = config.get("Paths", "params_path")
+    for node_id in network["node_ids"]:
+        cells[node_id], cells_data[node_id] = create_network_cell(
+            config, circuit_config, node_population, node_id, network
+        )
+        try:
+            param_values[node_id] = load_emodel_params(
+                cells_data[node_id]["emodel"], params_path
+            )
+        except KeyError as exc:
+            raise ValueError(
+                f"The e-model {cells_data[node_id]['emodel']} of node {node_id} "
+                f"is not in {params_path}."
+            ) from exc
+
+    cvode_active = config.getboolean("Sim", "cvode_active")
+    sim = create_simulator(config, cvode_active, config.getfloat("Sim", "dt"))
+    responses = simulate_network(
+        cells,
+        param_values,
+        sim,
+        tstop,
+        cvode_active,
+        spike_trains=spike_trains,
+        prefix=node_population,
+    )
+    finish_progress_report(sim)
+    spikes = get_network_spikes(responses, cells_data, prefix=node_population)
+
+    output_dir = Path(output_dir)
+    output_dir.mkdir(parents=True, exist_ok=True)
+    write_responses(responses, output_dir)
+    write_spikes(spikes, output_dir / "out.dat")
+    for cell_data in cells_data.values():
+        cell_data["n_spikes"] = len(spikes[cell_data["gid"]])
+    summary = {
+        "circuit_config": str(Path(circuit_config_path).resolve()),
+        "node_population": node_population,
+        "tstop": tstop,
+        "cells": {str(node_id): data for node_id, data in cells_data.items()},
+        "provenance": get_provenance(config),
+    }
+    with open(output_dir / "network.json", "w", encoding="utf-8") as summary_file:
+        json.dump(summary, summary_file, indent=4, cls=NpEncoder)
+    write_provenance(
+        summary["provenance"],
+        output_dir / "provenance.json",
+        wall_time=time.perf_counter() - start_time,
+    )
+
+    return responses, spikes
@@ -0,0 +1,642 @@
+"""Export of the instantiated cell to NeuroML2, with a LEMS simulation."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import json
+import logging
+import re
+import shutil
+import xml.etree.ElementTree as ET
+from pathlib import Path
+from xml.dom import minidom
+
+import numpy as np
+from bluepyopt import ephys
+
+from emodelrunner.create_cells import create_cell_using_config
+from emodelrunner.load import get_release_params, load_config
+from emodelrunner.logging_utilities import neuron_output_to_logger
+from emodelrunner.netpyne_export import (
+    SECTIONLISTS,
+    compress_values,
+    get_section_mechanisms,
+    get_section_name,
+)
+from emodelrunner.parsing_utilities import get_neuroml_parser_args, set_verbosity
+
+logger = logging.getLogger(__name__)
+
+NEUROML_NAMESPACE = "http://www.neuroml.org/schema/neuroml2"
+LEMS_INCLUDES = ["Cells.xml", "Networks.xml", "Simulation.xml"]
+
+# standard NeuroML segment groups, with their section lists and NeuroLex ids
+NEUROLEX_GROUPS = {
+    "soma_group": (["somatic"], "sao864921383"),
+    "axon_group": (["axonal", "myelinated"], "sao1770195789"),
+    "dendrite_group": (["basal", "apical"], "sao1211023249"),
+}
+
+# threshold used by NeuroML to detect the spikes of the cell (mV)
+SPIKE_THRESHOLD = -20.0
+
+# ion of the channels without species
+NON_SPECIFIC = "non_specific"
+
+
+def get_neuroml_id(name):
+    """Return a valid NeuroML id from a name, e.g. an e-model name.
+
+    Args:
+        name (str): name to convert
+
+    Returns:
+        str: name with its invalid characters replaced by underscores
+    """
+    neuroml_id = re.sub(r"\W", "_", name)
+    if not re.match(r"[a-zA-Z_]", neuroml_id):
+        neuroml_id = f"_{neuroml_id}"
+    return neuroml_id
+
+
+def format_quantity(value, unit):
+    """Return a NeuroML physical quantity, e.g. '-80.0 mV'.
+
+    Args:
+        value (float): value of the quantity
+        unit (str): NeuroML unit, e.g. mV or S_per_cm2
+
+    Returns:
+        str: quantity
+    """
+    return f"{float(value)!r} {unit}"
+
+
+def get_mean_value(value):
+    """Return the value of a section, and whether it varies along the section.
+
+    Args:
+        value (float or list of float): value, or value of each segment,
+            as returned by compress_values
+
+    Returns:
+        tuple: mean value (float) and True if the value varies along the section
+    """
+    if isinstance(value, list):
+        return float(np.mean(value)), True
+    return float(value), False
+
+
+def get_sections(icell):
+    """Return the sections of a cell, each parent being before its children.
+
+    Args:
+        icell (neuron.hoc.HocObject): instantiated cell template
+
+    Returns:
+        list of neuron.nrn.Section: sections of the cell
+    """
+    stack = [section for section in icell.all if section.parentseg() is None]
+    stack.reverse()
+    sections = []
+    while stack:
+        section = stack.pop()
+        sections.append(section)
+        stack.extend(reversed(section.children()))
+    return sections
+
+
+def get_parent_segment(parent_segments, x):
+    """Return the NeuroML segment at a position along its section.
+
+    Args:
+        parent_segments (list): id, start and end of each segment of the section,
+            the start and end being fractions of the section length
+        x (float): position along the section, e.g. the parent position of a child
+
+    Returns:
+        tuple: id of the segment (int) and position along the segment (float)
+    """
+    segment_id, start, end = parent_segments[-1]
+    for segment in parent_segments:
+        if x <= segment[2]:
+            segment_id, start, end = segment
+            break
+    if end <= start:
+        return segment_id, 1.0
+    return segment_id, min(max((x - start) / (end - start), 0.0), 1.0)
+
+
+def get_point_attributes(section, idx, h):
+    """Return the NeuroML attributes of a 3d point of a section.
+
+    Args:
+        section (neuron.nrn.Section): section of the cell
+        idx (int): index of the 3d point
+        h (neuron.hoc.HocObject): neuron hoc interpreter
+
+    Returns:
+        dict: coordinates and diameter of the point (um)
+    """
+    return {
+        "x": repr(h.x3d(idx, sec=section)),
+        "y": repr(h.y3d(idx, sec=section)),
+        "z": repr(h.z3d(idx, sec=section)),
+        "diameter": repr(h.diam3d(idx, sec=section)),
+    }
+
+
+def create_morphology(sections, h):
+    """Create the NeuroML morphology of the sections of a cell.
+
+    A segment is created between each pair of consecutive 3d points of a section,
+    and each section is a segment group named like in the NetPyNE export.
+
+    Args:
+        sections (list of neuron.nrn.Section): sections, each parent being first
+        h (neuron.hoc.HocObject): neuron hoc interpreter
+
+    Returns:
+        xml.etree.ElementTree.Element: morphology
+    """
+    morphology = ET.Element("morphology", id="morphology")
+    section_segments = {}
+    segment_id = 0
+    for section in sections:
+        name = get_section_name(section)
+        n3d = int(h.n3d(sec=section))
+        length = h.arc3d(n3d - 1, sec=section) or 1.0
+        section_segments[name] = []
+        for idx in range(1, n3d):
+            segment = ET.SubElement(
+                morphology, "segment", id=str(segment_id), name=f"{name}_{idx - 1}"
+            )
+            parent_seg = section.parentseg()
+            if idx > 1:
+                ET.SubElement(segment, "parent", segment=str(segment_id - 1))
+            elif parent_seg is not None:
+                parent_id, fraction = get_parent_segment(
+                    section_segments[get_section_name(parent_seg.sec)], parent_seg.x
+                )
+                ET.SubElement(
+                    segment,
+                    "parent",
+                    segment=str(parent_id),
+                    fractionAlong=repr(fraction),
+                )
+            if idx == 1:
+                ET.SubElement(
+                    segment, "proximal", **get_point_attributes(section, 0, h)
+                )
+            ET.SubElement(segment, "distal", **get_point_attributes(section, idx, h))
+            section_segments[name].append(
+                (
+                    segment_id,
+                    h.arc3d(idx - 1, sec=section) / length,
+                    h.arc3d(idx, sec=section) / length,
+                )
+            )
+            segment_id += 1
+
+    for name, segments in section_segments.items():
+        group = ET.SubElement(morphology, "segmentGroup", id=name)
+        for segment in segments:
+            ET.SubElement(group, "member", segment=str(segment[0]))
+
+    return morphology
+
+
+def add_sectionlist_groups(morphology, icell):
+    """Add the segment groups of the section lists and the standard NeuroML groups.
+
+    Args:
+        morphology (xml.etree.ElementTree.Element): morphology of the cell
+        icell (neuron.hoc.HocObject): instantiated cell template
+    """
+    sectionlists = {
+        sectionlist: [
+            get_section_name(section) for section in getattr(icell, sectionlist)
+        ]
+        for sectionlist in SECTIONLISTS
+        if hasattr(icell, sectionlist)
+    }
+    for sectionlist, names in sectionlists.items():
+        group = ET.SubElement(morphology, "segmentGroup", id=sectionlist)
+        for name in names:
+            ET.SubElement(group, "include", segmentGroup=name)
+
+    for group_id, (group_sectionlists, neurolex_id) in NEUROLEX_GROUPS.items():
+        group_sectionlists = [
+            sectionlist
+            for sectionlist in group_sectionlists
+            if sectionlists.get(sectionlist)
+        ]
+        if group_sectionlists:
+            group = ET.SubElement(
+                morphology, "segmentGroup", id=group_id, neuroLexId=neurolex_id
+            )
+            for sectionlist in group_sectionlists:
+                ET.SubElement(group, "include", segmentGroup=sectionlist)
+
+
+def group_sections(values):
+    """Group the sections having the same value.
+
+    Args:
+        values (dict): value of each section, keyed by section name
+
+    Returns:
+        list: tuples of a value and of the names of the sections having this value
+    """
+    groups = {}
+    for name, value in values.items():
+        groups.setdefault(value, []).append(name)
+    return list(groups.items())
+
+
+def add_value_groups(morphology, prefix, values, all_names):
+    """Add a segment group for each value of a parameter, and return their ids.
+
+    Args:
+        morphology (xml.etree.ElementTree.Element): morphology of the cell
+        prefix (str): prefix of the ids of the segment groups
+        values (dict): value of each section, keyed by section name
+        all_names (list of str): names of all the sections of the cell
+
+    Returns:
+        list: tuples of a value and of the id of the segment group
+            of the sections having this value. 'all' is used for all the sections.
+    """
+    groups = group_sections(values)
+    if len(groups) == 1 and set(groups[0][1]) == set(all_names):
+        return [(groups[0][0], "all")]
+
+    value_groups = []
+    for idx, (value, names) in enumerate(groups):
+        group_id = f"{prefix}_group_{idx}"
+        group = ET.SubElement(morphology, "segmentGroup", id=group_id)
+        for name in names:
+            ET.SubElement(group, "include", segmentGroup=name)
+        value_groups.append((value, group_id))
+    return value_groups
+
+
+def get_channel_species(channel_path, channel_name):
+    """Return the ion of a NeuroML ion channel.
+
+    Args:
+        channel_path (Path): path to the NeuroML file of the channel
+        channel_name (str): id of the channel in the file
+
+    Raises:
+        ValueError: if the channel is not in the file
+
+    Returns:
+        str: species of the channel, or 'non_specific'
+    """
+    for element in ET.parse(channel_path).getroot().iter():
+        if element.get("id") == channel_name:
+            return element.get("species") or NON_SPECIFIC
+    raise ValueError(f"No ion channel {channel_name} in {channel_path}.")
+
+
+def get_channel_parameter_names(param_names):
+    """Return the names of the conductance and reversal parameters of a mechanism.
+
+    Args:
+        param_names (list of str): names of the parameters, e.g. gIhbar and ehcn
+
+    Returns:
+        tuple: name of the conductance, e.g. gNaTgbar or g for pas, and name of the
+            reversal potential, e.g. ehcn or e for pas. None if there is no such
+            parameter.
+    """
+    conductance = next(
+        (
+            name
+            for name in param_names
+            if name.startswith("g") and name.endswith("bar")
+        ),
+        "g" if "g" in param_names else None,
+    )
+    reversal = next((name for name in param_names if name.startswith("e")), None)
+    return conductance, reversal
+
+
+def get_channel_densities(mech_name, species, section_mechs):
+    """Return the conductance and reversal potential of a channel in each section.
+
+    Args:
+        mech_name (str): name of the mechanism
+        species (str): ion of the channel, or 'non_specific'
+        section_mechs (dict): mechanisms and ions of each section, keyed by
+            section name, as returned by get_section_mechanisms
+
+    Raises:
+        ValueError: if the channel has no conductance or reversal potential
+
+    Returns:
+        tuple: conductance and reversal potential (tuple) of each section (dict),
+            and True if a value varies along a section
+    """
+    values = {}
+    approximated = False
+    for name, (mechs, ions) in section_mechs.items():
+        if mech_name not in mechs:
+            continue
+        conductance_name, reversal_name = get_channel_parameter_names(
+            list(mechs[mech_name])
+        )
+        if conductance_name is None:
+            raise ValueError("no conductance parameter, e.g. a concentration model")
+        if species != NON_SPECIFIC and "e" in ions.get(species, {}):
+            reversal = ions[species]["e"]
+        elif reversal_name is not None:
+            reversal = mechs[mech_name][reversal_name]
+        else:
+            raise ValueError("no reversal potential")
+
+        conductance, varies = get_mean_value(mechs[mech_name][conductance_name])
+        reversal, reversal_varies = get_mean_value(reversal)
+        values[name] = (conductance, reversal)
+        approximated = approximated or varies or reversal_varies
+    return values, approximated
+
+
+def create_neuroml_cell(icell, h, cell_id, output_dir, channels_dir=None):
+    """Convert an instantiated cell into a NeuroML cell.
+
+    The passive mechanism is converted to a passive channel. The other
+    ion channels have to be given as NeuroML files named {mechanism}.channel.nml
+    in channels_dir, that are copied in output_dir. The mechanisms that cannot be
+    converted, e.g. the ones without NeuroML file and the concentration models,
+    are listed in the report. The values varying along a section are replaced by
+    their mean over the section.
+
+    Args:
+        icell (neuron.hoc.HocObject): instantiated cell template, e.g. cell.icell
+        h (neuron.hoc.HocObject): neuron hoc interpreter
+        cell_id (str): id of the NeuroML cell
+        output_dir (Path): directory in which the channel files are copied
+        channels_dir (str or Path): directory of the NeuroML channel files.
+            Only the passive mechanism is converted if None.
+
+    Returns:
+        tuple: NeuroML document (xml.etree.ElementTree.Element) and report (dict)
+    """
+    # pylint: disable=too-many-locals
+    # 3d points of the sections without any, e.g. the stub axon
+    h.define_shape()
+    sections = get_sections(icell)
+    all_names = [get_section_name(section) for section in sections]
+    section_mechs = {
+        get_section_name(section): get_section_mechanisms(section, h)
+        for section in sections
+    }
+
+    neuroml = ET.Element("neuroml", xmlns=NEUROML_NAMESPACE, id=cell_id)
+    cell = ET.Element("cell", id=cell_id)
+    morphology = create_morphology(sections, h)
+    add_sectionlist_groups(morphology, icell)
+    cell.append(morphology)
+    biophysics = ET.SubElement(cell, "biophysicalProperties", id="biophysics")
+    membrane = ET.SubElement(biophysics, "membraneProperties")
+
+    report = {
+        "converted_mechanisms": [],
+        "not_converted_mechanisms": {},
+        "approximated_mechanisms": [],
+        "celsius": h.celsius,
+    }
+    mech_names = sorted({name for mechs, _ in section_mechs.values() for name in mechs})
+    for mech_name in mech_names:
+        channel_path = None
+        if mech_name == "pas":
+            species = NON_SPECIFIC
+            ET.SubElement(neuroml, "ionChannelPassive", id="pas")
+        elif channels_dir is None:
+            report["not_converted_mechanisms"][mech_name] = "no channels directory"
+            continue
+        else:
+            channel_path = Path(channels_dir) / f"{mech_name}.channel.nml"
+            if not channel_path.is_file():
+                reason = f"{channel_path.name} not found"
+                report["not_converted_mechanisms"][mech_name] = reason
+                continue
+            species = get_channel_species(channel_path, mech_name)
+
+        try:
+            values, approximated = get_channel_densities(
+                mech_name, species, section_mechs
+            )
+        except ValueError as exc:
+            report["not_converted_mechanisms"][mech_name] = str(exc)
+            continue
+
+        if channel_path is not None:
+            shutil.copy(channel_path, output_dir / channel_path.name)
+            ET.SubElement(neuroml, "include", href=channel_path.name)
+        for (conductance, reversal), group_id in add_value_groups(
+            morphology, mech_name, values, all_names
+        ):
+            ET.SubElement(
+                membrane,
+                "channelDensity",
+                id=f"{mech_name}_{group_id}",
+                ionChannel=mech_name,
+                condDensity=format_quantity(conductance, "S_per_cm2"),
+                erev=format_quantity(reversal, "mV"),
+                segmentGroup=group_id,
+                ion=species,
+            )
+        report["converted_mechanisms"].append(mech_name)
+        if approximated:
+            report["approximated_mechanisms"].append(mech_name)
+
+    ET.SubElement(membrane, "spikeThresh", value=format_quantity(SPIKE_THRESHOLD, "mV"))
+    capacitances = {}
+    for section in sections:
+        capacitances[get_section_name(section)], varies = get_mean_value(
+            compress_values([seg.cm for seg in section])
+        )
+        if varies and "cm" not in report["approximated_mechanisms"]:
+            report["approximated_mechanisms"].append("cm")
+    for capacitance, group_id in add_value_groups(
+        morphology, "cm", capacitances, all_names
+    ):
+        ET.SubElement(
+            membrane,
+            "specificCapacitance",
+            value=format_quantity(capacitance, "uF_per_cm2"),
+            segmentGroup=group_id,
+        )
+    ET.SubElement(membrane, "initMembPotential", value=format_quantity(h.v_init, "mV"))
+
+    intracellular = ET.SubElement(biophysics, "intracellularProperties")
+    resistivities = {get_section_name(section): section.Ra for section in sections}
+    for resistivity, group_id in add_value_groups(
+        morphology, "Ra", resistivities, all_names
+    ):
+        ET.SubElement(
+            intracellular,
+            "resistivity",
+            value=format_quantity(resistivity, "ohm_cm"),
+            segmentGroup=group_id,
+        )
+
+    # the channels and includes have to be before the cell
+    neuroml.append(cell)
+    for mech_name, reason in report["not_converted_mechanisms"].items():
+        logger.warning("Mechanism %s not exported to NeuroML: %s", mech_name, reason)
+
+    return neuroml, report
+
+
+def create_lems_simulation(cell_id, cell_file_name, step, dt):
+    """Create a NeuroML network injecting a step current in the cell, and its LEMS file.
+
+    Args:
+        cell_id (str): id of the NeuroML cell
+        cell_file_name (str): name of the NeuroML file of the cell
+        step (dict): amplitude (nA), delay (ms), duration (ms)
+            and totduration (ms) of the step
+        dt (float): time step of the simulation (ms)
+
+    Returns:
+        tuple: NeuroML network document and LEMS simulation
+            (xml.etree.ElementTree.Element)
+    """
+    network_id = f"{cell_id}_network"
+    neuroml = ET.Element("neuroml", xmlns=NEUROML_NAMESPACE, id=network_id)
+    ET.SubElement(neuroml, "include", href=cell_file_name)
+    ET.SubElement(
+        neuroml,
+        "pulseGenerator",
+        id="step",
+        delay=format_quantity(step["delay"], "ms"),
+        duration=format_quantity(step["duration"], "ms"),
+        amplitude=format_quantity(step["amp"], "nA"),
+    )
+    network = ET.SubElement(neuroml, "network", id=network_id)
+    ET.SubElement(network, "population", id="population", component=cell_id, size="1")
+    ET.SubElement(network, "explicitInput", target="population[0]", input="step")
+
+    lems = ET.Element("Lems")
+    ET.SubElement(lems, "Target", component="simulation")
+    for include in LEMS_INCLUDES + [f"{network_id}.net.nml"]:
+        ET.SubElement(lems, "Include", file=include)
+    simulation = ET.SubElement(
+        lems,
+        "Simulation",
+        id="simulation",
+        length=format_quantity(step["totduration"], "ms"),
+        step=format_quantity(dt, "ms"),
+        target=network_id,
+    )
+    output_file = ET.SubElement(
+        simulation, "OutputFile", id="voltage", fileName=f"{cell_id}.soma.v.dat"
+    )
+    ET.SubElement(output_file, "OutputColumn", id="v", quantity="population[0]/v")
+
+    return neuroml, lems
+
+
+def write_xml(element, path):
+    """Write an xml document with indentation.
+
+    Args:
+        element (xml.etree.ElementTree.Element): root of the document
+        path (Path): path to the file to write
+    """
+    xml = minidom.parseString(ET.tostring(element, encoding="unicode"))
+    with open(path, "w", encoding="utf-8") as xml_file:
+        xml_file.write(xml.toprettyxml(indent="    "))
+
+
+def export_neuroml_cell(config_path, output_dir, channels_dir=None, step=None):
+    """Instantiate the cell of a config and write it as a NeuroML2 cell.
+
+    The cell is written in {emodel}.cell.nml in output_dir, with the report
+    of the conversion in neuroml_report.json. See create_neuroml_cell for the
+    conversion of the mechanisms. If step is given, a network injecting
+    a step current in the cell and its LEMS simulation are also written,
+    to be run e.g. with 'pynml LEMS_{emodel}.xml'.
+
+    Args:
+        config_path (str): path to config file
+        output_dir (str or Path): directory in which to write the NeuroML files
+        channels_dir (str or Path): directory of the NeuroML channel files
+        step (dict): amplitude (nA), delay (ms), duration (ms)
+            and totduration (ms) of the step of the LEMS simulation
+
+    Returns:
+        dict: report of the conversion, with the converted, not converted
+            and approximated mechanisms
+    """
+    config = load_config(config_path=config_path)
+
+    cell = create_cell_using_config(config)
+    release_params = get_release_params(config)
+    sim = ephys.simulators.NrnSimulator(
+        dt=config.getfloat("Sim", "dt"),
+        cvode_active=config.getboolean("Sim", "cvode_active"),
+    )
+    # global parameters such as celsius and v_init are set at instantiation
+    cell.freeze(release_params)
+    cell.instantiate(sim=sim)
+
+    output_dir = Path(output_dir)
+    output_dir.mkdir(parents=True, exist_ok=True)
+    cell_id = get_neuroml_id(config.get("Cell", "emodel"))
+    neuroml, report = create_neuroml_cell(
+        cell.icell, sim.neuron.h, cell_id, output_dir, channels_dir
+    )
+
+    cell.destroy(sim=sim)
+    cell.unfreeze(release_params.keys())
+
+    cell_path = output_dir / f"{cell_id}.cell.nml"
+    write_xml(neuroml, cell_path)
+    report["cell_path"] = str(cell_path)
+
+    if step is not None:
+        network, lems = create_lems_simulation(
+            cell_id, cell_path.name, step, config.getfloat("Sim", "dt")
+        )
+        write_xml(network, output_dir / f"{cell_id}_network.net.nml")
+        report["lems_path"] = str(output_dir / f"LEMS_{cell_id}.xml")
+        write_xml(lems, report["lems_path"])
+
+    with open(output_dir / "neuroml_report.json", "w", encoding="utf-8") as report_file:
+        json.dump(report, report_file, indent=4)
+    logger.info("NeuroML cell written in %s", cell_path)
+
+    return report
+
+
+if __name__ == "__main__":
+    args = get_neuroml_parser_args()
+    set_verbosity(args.verbosity, args.log_level, args.log_file)
+
+    step_ = None
+    if args.step_amplitude is not None:
+        step_ = {
+            "amp": args.step_amplitude,
+            "delay": args.step_delay,
+            "duration": args.step_duration,
+            "totduration": args.total_duration,
+        }
+    with neuron_output_to_logger():
+        export_neuroml_cell(args.config_path, args.output_dir, args.channels_dir, step_)
@@ -0,0 +1,273 @@
+"""Output of the recorded and injected traces as NWB files."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import datetime
+import json
+import logging
+import uuid
+
+import numpy as np
+
+from emodelrunner.json_utilities import NpEncoder
+from emodelrunner.output import get_trace_data
+from emodelrunner.results import parse_output_filename
+from emodelrunner.units import get_variable_unit
+
+logger = logging.getLogger(__name__)
+
+OUTPUT_FORMATS = ["dat", "h5", "nwb", "npy"]
+
+# NEURON units of the recorded variables, other than the voltage
+VARIABLE_UNITS = {
+    "i": "nA",
+    "ina": "mA/cm2",
+    "ik": "mA/cm2",
+    "ica": "mA/cm2",
+    "ihcn": "mA/cm2",
+    "cai": "mM",
+    "g": "uS",
+}
+
+
+def get_sampling(time):
+    """Return the sampling arguments of a NWB time series.
+
+    Args:
+        time (numpy.ndarray): time of the samples (ms)
+
+    Returns:
+        dict: starting time and rate (Hz) if the samples are regularly spaced,
+            timestamps (s) otherwise
+    """
+    time = np.asarray(time, dtype=float)
+    if len(time) > 1:
+        steps = np.diff(time)
+        if np.allclose(steps, steps[0], rtol=1e-6, atol=1e-9) and steps[0] > 0:
+            return {"starting_time": time[0] / 1000.0, "rate": 1000.0 / steps[0]}
+    return {"timestamps": time / 1000.0}
+
+
+def create_nwb_file(emodel, cell_id, mtype="", provenance=None):
+    """Create an in-memory NWB file for the traces of a cell.
+
+    Args:
+        emodel (str): name of the e-model
+        cell_id (int): id (gid) of the cell
+        mtype (str): morphological type of the cell
+        provenance (dict): provenance of the run, stored as json in the notes
+
+    Returns:
+        pynwb.NWBFile: the NWB file
+    """
+    # pylint: disable=import-outside-toplevel
+    from pynwb import NWBFile
+    from pynwb.file import Subject
+
+    nwbfile = NWBFile(
+        session_description=f"EModelRunner simulation of the {emodel} e-model",
+        identifier=str(uuid.uuid4()),
+        session_start_time=datetime.datetime.now(datetime.timezone.utc),
+        notes=None if provenance is None else json.dumps(provenance, cls=NpEncoder),
+    )
+    nwbfile.subject = Subject(
+        subject_id=str(cell_id),
+        description=f"simulated cell {cell_id} of m-type {mtype or 'unknown'} "
+        f"with the {emodel} e-model",
+    )
+    return nwbfile
+
+
+def get_electrode(nwbfile, location):
+    """Return the intracellular electrode of a location, and create it if needed.
+
+    Args:
+        nwbfile (pynwb.NWBFile): the NWB file
+        location (str): name of the location, e.g. 'soma'
+
+    Returns:
+        pynwb.icephys.IntracellularElectrode: the electrode
+    """
+    if location in nwbfile.icephys_electrodes:
+        return nwbfile.icephys_electrodes[location]
+    if "EModelRunner" in nwbfile.devices:
+        device = nwbfile.devices["EModelRunner"]
+    else:
+        device = nwbfile.create_device(
+            name="EModelRunner", description="NEURON simulation"
+        )
+    return nwbfile.create_icephys_electrode(
+        name=location,
+        description=f"simulated electrode at the {location}",
+        device=device,
+    )
+
+
+def add_responses(nwbfile, responses):
+    """Add the recorded responses to the acquisitions of a NWB file.
+
+    The voltage traces are current clamp series, the other recordings
+    (e.g. synaptic currents) are time series in NEURON units. Lists of responses,
+    e.g. one per synapse, are stored as one time series with one column
+    per response. The scalar responses, e.g. the holding current,
+    are stored as scratch data.
+
+    Args:
+        nwbfile (pynwb.NWBFile): the NWB file
+        responses (dict): responses keyed by recording name
+    """
+    # pylint: disable=import-outside-toplevel
+    from pynwb import TimeSeries
+    from pynwb.icephys import CurrentClampSeries
+
+    for sweep_number, (key, response) in enumerate(responses.items()):
+        # Some responses are None when spike is not found
+        if response is None:
+            continue
+        if isinstance(response, (float, np.floating)):
+            nwbfile.add_scratch(
+                np.array([response]), name=key, description=f"{key} (nA)"
+            )
+            continue
+
+        metadata = parse_output_filename(key)
+        protocol = metadata["protocol"] or key
+        variable, time, data = get_trace_data(key, response, metadata["variable"])
+        time = np.array(time)
+        data = np.array(data)
+
+        if variable == "v" and data.ndim == 1:
+            electrode = get_electrode(nwbfile, metadata["location"] or "soma")
+            nwbfile.add_acquisition(
+                CurrentClampSeries(
+                    name=key,
+                    data=data,
+                    conversion=1e-3,  # mV
+                    electrode=electrode,
+                    stimulus_description=protocol,
+                    sweep_number=sweep_number,
+                    **get_sampling(time),
+                )
+            )
+        else:
+            unit = VARIABLE_UNITS.get(variable) or get_variable_unit(variable)
+            nwbfile.add_acquisition(
+                TimeSeries(
+                    name=key,
+                    data=data,
+                    unit=unit or "NEURON units",
+                    description=f"{variable} recorded during the {protocol} protocol",
+                    **get_sampling(time),
+                )
+            )
+
+
+def add_currents(nwbfile, currents):
+    """Add the injected currents to the stimuli of a NWB file.
+
+    Args:
+        nwbfile (pynwb.NWBFile): the NWB file
+        currents (dict): currents keyed by name,
+            with structure "key": {"time": time, "current": current}
+    """
+    # pylint: disable=import-outside-toplevel
+    from pynwb.icephys import CurrentClampStimulusSeries
+
+    for sweep_number, (key, current) in enumerate(currents.items()):
+        metadata = parse_output_filename(key)
+        nwbfile.add_stimulus(
+            CurrentClampStimulusSeries(
+                name=key,
+                data=np.array(current["current"]),
+                conversion=1e-9,  # nA
+                electrode=get_electrode(nwbfile, "soma"),
+                stimulus_description=metadata["protocol"] or key,
+                sweep_number=sweep_number,
+                **get_sampling(current["time"]),
+            )
+        )
+
+
+def add_extracellular(nwbfile, responses):
+    """Add the extracellular potentials to the acquisitions of a NWB file.
+
+    Each potential is stored as a time series with one column per electrode,
+    with the electrode positions in its comments.
+
+    Args:
+        nwbfile (pynwb.NWBFile): the NWB file
+        responses (dict): extracellular responses keyed by recording name,
+            with structure "key": {"time": time, "positions": positions,
+            "potential": potential}
+    """
+    # pylint: disable=import-outside-toplevel
+    from pynwb import TimeSeries
+
+    for key, response in responses.items():
+        if response is None:
+            continue
+        positions = np.asarray(response["positions"]).tolist()
+        nwbfile.add_acquisition(
+            TimeSeries(
+                name=key,
+                data=np.transpose(response["potential"]),
+                unit="mV",
+                description=f"extracellular potential at {len(positions)} electrodes",
+                comments=f"electrode positions (um): {json.dumps(positions)}",
+                **get_sampling(response["time"]),
+            )
+        )
+
+
+def write_nwb(
+    output_path,
+    responses,
+    currents=None,
+    emodel="",
+    cell_id=0,
+    mtype="",
+    provenance=None,
+    scratch=None,
+    extracellular=None,
+):
+    """Write the recorded responses and the injected currents in a NWB 2 file.
+
+    Args:
+        output_path (str or Path): path to the NWB file
+        responses (dict): responses keyed by recording name
+        currents (dict): injected currents keyed by name, if any
+        emodel (str): name of the e-model
+        cell_id (int): id (gid) of the cell
+        mtype (str): morphological type of the cell
+        provenance (dict): provenance of the run, stored as json in the notes
+        scratch (dict): additional data keyed by name, stored as scratch data,
+            e.g. the presynaptic spike train
+        extracellular (dict): extracellular responses keyed by recording name, if any
+    """
+    # pylint: disable=import-outside-toplevel, too-many-arguments
+    from pynwb import NWBHDF5IO
+
+    nwbfile = create_nwb_file(emodel, cell_id, mtype, provenance)
+    add_responses(nwbfile, responses)
+    if currents:
+        add_currents(nwbfile, currents)
+    if extracellular:
+        add_extracellular(nwbfile, extracellular)
+    for name, data in (scratch or {}).items():
+        nwbfile.add_scratch(np.asarray(data), name=name, description=name)
+
+    with NWBHDF5IO(str(output_path), "w") as io:
+        io.write(nwbfile)
+    logger.info("Traces written in %s", output_path)
@@ -16,10 +16,122 @@
 
 import os
 import json
+from pathlib import Path
 
 import h5py
 import numpy as np
 
+from emodelrunner.json_utilities import NpEncoder
+
+
+def get_regular_time(time, dt):
+    """Return a regular time grid spanning the time of a trace.
+
+    Args:
+        time (numpy.ndarray): time of the samples (ms)
+        dt (float): step of the grid (ms)
+
+    Returns:
+        numpy.ndarray: time of the grid (ms)
+    """
+    if len(time) == 0:
+        return np.array([])
+    # tolerate the rounding errors on the last step of the solver
+    n_steps = int(np.floor((time[-1] - time[0]) / dt + 1e-6))
+    return time[0] + dt * np.arange(n_steps + 1)
+
+
+def resample_response(response, dt):
+    """Resample the traces of a response on a regular time grid.
+
+    With the variable time step, the samples are taken at the steps of the solver.
+    The traces are linearly interpolated at the time of the grid.
+    The voltage of the recordings, the injected current of the stimuli,
+    and the 2D arrays of the extracellular potential and of the synapse variables
+    with one column per sample, are resampled.
+
+    Args:
+        response: a TimeVoltageResponse, a dict of arrays with a 'time' entry,
+            a list of them, or a scalar response
+        dt (float): step of the grid (ms)
+
+    Returns:
+        the resampled response as a dict, or the scalar response unchanged
+    """
+    if response is None or isinstance(response, (float, np.floating)):
+        return response
+    if isinstance(response, list):
+        return [resample_response(resp, dt) for resp in response]
+
+    time = np.asarray(response["time"], dtype=float)
+    regular_time = get_regular_time(time, dt)
+    if isinstance(response, dict):
+        resampled = dict(response)
+        keys = [
+            key
+            for key, value in response.items()
+            if key in ["voltage", "current"]
+            or (
+                key != "positions"
+                and np.ndim(value) == 2
+                and np.shape(value)[1] == len(time)
+            )
+        ]
+    else:
+        resampled = {}
+        keys = ["voltage"]
+
+    resampled["time"] = regular_time
+    for key in keys:
+        values = np.asarray(response[key], dtype=float)
+        if values.ndim == 1:
+            resampled[key] = np.interp(regular_time, time, values)
+        else:
+            resampled[key] = np.array(
+                [np.interp(regular_time, time, trace) for trace in values]
+            ).reshape(len(values), len(regular_time))
+    return resampled
+
+
+def resample_responses(responses, dt):
+    """Resample the traces of the responses on a regular time grid.
+
+    Args:
+        responses (dict): responses keyed by recording name
+        dt (float): step of the grid (ms)
+
+    Returns:
+        dict: resampled responses keyed by recording name. See resample_response.
+    """
+    return {key: resample_response(resp, dt) for key, resp in responses.items()}
+
+
+def get_trace_data(key, response, variable=None):
+    """Return the recorded variable, the time and the values of a response.
+
+    Lists of responses, i.e. the synapse recordings of the synapse plasticity
+    protocols, are grouped in one array with one column per response,
+    and are named after the recorded variable. The soma voltage of the
+    synapse plasticity protocols is named after the protocol, without variable.
+
+    Args:
+        key (str): response key
+        response (dict or list): response with its 'time' and 'voltage',
+            or list of such responses
+        variable (str): recorded variable parsed from the response key, if any
+
+    Returns:
+        tuple containing the recorded variable, the time and the values,
+        with one column per response for the lists of responses
+    """
+    if isinstance(response, list):
+        return (
+            variable or key,
+            response[0]["time"],
+            np.transpose([np.asarray(rec["voltage"]) for rec in response]),
+        )
+    return variable or "v", response["time"], response["voltage"]
+
 
 def write_responses(responses, output_dir):
     """Write each response in a file.
@@ -62,11 +174,227 @@ def write_current(currents, output_dir):
         )
 
 
+def get_h5_path(key):
+    """Return the path of a response in the h5 output, from its response key.
+
+    The recordings 'prefix.protocol.location.variable' are stored in the
+    'protocol/location.variable' group, the injected currents 'current_prefix.protocol'
+    in the 'protocol/current' group, and the other responses,
+    e.g. the holding current 'prefix.name', in the 'scalars' group.
+
+    Args:
+        key (str): response key
+
+    Returns:
+        str: path of the group (or of the dataset for the scalars) in the h5 file
+    """
+    if key.startswith("current_"):
+        items = key[len("current_") :].split(".")
+        return f"{'.'.join(items[1:]) or items[0]}/current"
+    items = key.split(".")
+    if len(items) >= 4:
+        return f"{'.'.join(items[1:-2])}/{'.'.join(items[-2:])}"
+    return f"scalars/{key}"
+
+
+def write_h5_output(
+    responses, currents, output_path, compression_level=4, provenance=None
+):
+    """Write the traces of a run in a single h5 file.
+
+    Each trace is stored in a group with its 'time' and 'values' datasets,
+    and its response key as 'key' attribute. See get_h5_path for the layout.
+
+    Args:
+        responses (dict): time and recorded value of each recording
+            Should have structure "key": {"time": time, "voltage": response}
+        currents (dict): time and trace to each recording
+            Should have structure "key": {"time": time, "current": current}
+        output_path (str): path to the h5 file
+        compression_level (int): gzip compression level of the traces, from 1 to 9.
+            0 for no compression.
+        provenance (dict): provenance of the run, stored as a json string
+            in the 'provenance' attribute of the file
+    """
+    compression = {}
+    if compression_level:
+        compression = {
+            "chunks": True,
+            "compression": "gzip",
+            "compression_opts": compression_level,
+        }
+
+    traces = [(key, resp, "voltage") for key, resp in responses.items()]
+    traces += [(key, curr_dict, "current") for key, curr_dict in currents.items()]
+    with h5py.File(output_path, "w") as h5file:
+        if provenance is not None:
+            h5file.attrs["provenance"] = json.dumps(provenance, cls=NpEncoder)
+        for key, resp, field in traces:
+            # Some resp are None when spike is not found
+            if resp is None:
+                continue
+            path = get_h5_path(key)
+            if not isinstance(resp, dict):
+                dataset = h5file.create_dataset(path, data=float(resp))
+                dataset.attrs["key"] = key
+                continue
+            group = h5file.create_group(path)
+            group.attrs["key"] = key
+            group.create_dataset("time", data=np.array(resp["time"]), **compression)
+            group.create_dataset("values", data=np.array(resp[field]), **compression)
+
+
+def get_binary_index_path(output_path):
+    """Return the path of the index of a binary output file.
+
+    Args:
+        output_path (str or Path): path to the binary output file, e.g. 'L5TPC.npy'
+
+    Returns:
+        Path: path to the json index, e.g. 'L5TPC.index.json'
+    """
+    return Path(output_path).with_suffix(".index.json")
+
+
+def write_binary_output(responses, currents, output_path, provenance=None):
+    """Write the traces of a run in a single binary file, with a json index.
+
+    The time and the values of all the traces are concatenated in a 1D float64
+    array, saved as a .npy file that can be memory-mapped.
+    The index gives, for each response key, the offset and the length of its
+    time in the array, its values following right after, and the field of its
+    values ('voltage' or 'current'). It also contains the scalars,
+    e.g. the holding current, and the provenance of the run.
+
+    Args:
+        responses (dict): time and recorded value of each recording
+            Should have structure "key": {"time": time, "voltage": response}
+        currents (dict): time and trace to each recording
+            Should have structure "key": {"time": time, "current": current}
+        output_path (str or Path): path to the .npy file
+        provenance (dict): provenance of the run
+    """
+    traces = [(key, resp, "voltage") for key, resp in responses.items()]
+    traces += [(key, curr_dict, "current") for key, curr_dict in currents.items()]
+
+    index = {"version": 1, "dtype": "float64", "traces": {}, "scalars": {}}
+    offset = 0
+    for key, resp, field in traces:
+        # Some resp are None when spike is not found
+        if resp is None:
+            continue
+        if not isinstance(resp, dict):
+            index["scalars"][key] = float(resp)
+            continue
+        length = len(resp["time"])
+        index["traces"][key] = {"field": field, "offset": offset, "length": length}
+        offset += 2 * length
+    if provenance is not None:
+        index["provenance"] = provenance
+
+    if offset == 0:
+        # an empty array cannot be memory-mapped
+        np.save(output_path, np.zeros(0, dtype=np.float64))
+    else:
+        # filled in place, without building the whole array in memory
+        data = np.lib.format.open_memmap(
+            output_path, mode="w+", dtype=np.float64, shape=(offset,)
+        )
+        for key, resp, field in traces:
+            if key not in index["traces"]:
+                continue
+            trace_offset = index["traces"][key]["offset"]
+            length = index["traces"][key]["length"]
+            data[trace_offset : trace_offset + length] = resp["time"]
+            data[trace_offset + length : trace_offset + 2 * length] = resp[field]
+        data.flush()
+        del data
+
+    with open(get_binary_index_path(output_path), "w", encoding="utf-8") as index_file:
+        json.dump(index, index_file, indent=4, cls=NpEncoder)
+
+
+def write_extracellular(responses, output_dir, filename="extracellular.h5"):
+    """Write the extracellular potentials as h5, with one group per recording.
+
+    Args:
+        responses (dict): extracellular responses keyed by recording name
+            Should have structure "key": {"time": time, "positions": positions,
+            "potential": potential}
+        output_dir (str): path to the output repository
+        filename (str): name of the h5 file
+    """
+    output_path = os.path.join(output_dir, filename)
+    with h5py.File(output_path, "w") as h5file:
+        for key, resp in responses.items():
+            # Some resp are None when the protocol did not run
+            if resp is None:
+                continue
+            group = h5file.create_group(key)
+            group.create_dataset("time", data=np.array(resp["time"]))
+            group.create_dataset("positions", data=np.array(resp["positions"]))
+            group.create_dataset(
+                "potential",
+                data=np.array(resp["potential"]),
+                chunks=True,
+                compression="gzip",
+                compression_opts=9,
+            )
+            group.attrs["units"] = json.dumps(
+                {"time": "ms", "positions": "um", "potential": "mV"}
+            )
+
+
+def write_synapse_recordings(responses, output_dir, filename="synapses.h5"):
+    """Write the variables of the synapses as h5, with one group per recording.
+
+    Args:
+        responses (dict): synapse responses keyed by recording name
+            Should have structure "key": {"time": time, "synapse_ids": synapse_ids,
+            "pre_mtypes": pre_mtypes, variable: values, ...}
+        output_dir (str): path to the output repository
+        filename (str): name of the h5 file
+    """
+    output_path = os.path.join(output_dir, filename)
+    with h5py.File(output_path, "w") as h5file:
+        for key, resp in responses.items():
+            # Some resp are None when the protocol did not run
+            if resp is None:
+                continue
+            group = h5file.create_group(key)
+            for name in ["time", "synapse_ids", "pre_mtypes"]:
+                group.create_dataset(name, data=np.array(resp[name]))
+            variables = [name for name in resp if name not in group]
+            for variable in variables:
+                group.create_dataset(
+                    variable,
+                    data=np.array(resp[variable]),
+                    chunks=True,
+                    compression="gzip",
+                    compression_opts=9,
+                )
+            group.attrs["variables"] = json.dumps(variables)
+
+
+def write_efeatures(efeatures, output_dir, filename="efeatures.json"):
+    """Write the efeatures extracted for each protocol as json.
+
+    Args:
+        efeatures (dict): values of each feature for each protocol
+        output_dir (str): path to the output repository
+        filename (str): name of the efeatures file
+    """
+    output_path = os.path.join(output_dir, filename)
+    with open(output_path, "w", encoding="utf-8") as out_file:
+        json.dump(efeatures, out_file, indent=4, cls=NpEncoder)
+
+
 def write_synplas_output(
     responses,
     pre_spike_train,
     output_path="./output.h5",
     syn_prop_path="synapses/synapse_properties.json",
+    provenance=None,
 ):
     """Write output as h5.
 
@@ -75,6 +403,8 @@ def write_synplas_output(
         pre_spike_train (list): times at which the synapses fire (ms)
         output_path (str): path to the (postsynaptic data) output file
         syn_prop_path (str): path to the synapse properties file
+        provenance (dict): provenance of the run, stored as json in the
+            'provenance' attribute of the file. Optional.
     """
     results = {"prespikes": pre_spike_train}
     # add synprop
@@ -104,6 +434,8 @@ def write_synplas_output(
                 compression="gzip",
                 compression_opts=9,
             )
+    if provenance is not None:
+        h5file.attrs["provenance"] = json.dumps(provenance, cls=NpEncoder)
     h5file.close()
 
 
@@ -111,6 +443,7 @@ def write_synplas_precell_output(
     responses,
     protocol_name,
     precell_output_path="./output_precell.h5",
+    provenance=None,
 ):
     """Write precell output as h5.
 
@@ -118,6 +451,8 @@ def write_synplas_precell_output(
         responses (dict): responses of the presynaptic cell
         protocol_name (str): name of the presynaptic protocol
         precell_output_path (str): path to the presynaptic data output file
+        provenance (dict): provenance of the run, stored as json in the
+            'provenance' attribute of the file. Optional.
     """
     results = {}
 
@@ -135,4 +470,6 @@ def write_synplas_precell_output(
             compression="gzip",
             compression_opts=9,
         )
+    if provenance is not None:
+        h5file.attrs["provenance"] = json.dumps(provenance, cls=NpEncoder)
     h5file.close()
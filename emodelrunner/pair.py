@@ -0,0 +1,301 @@
+"""Simulation of a pair of connected cells, with the EPSPs of the postsynaptic cell."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import json
+import logging
+import time
+from pathlib import Path
+
+import numpy as np
+from bluepyopt import ephys
+
+from emodelrunner.create_cells import create_cell
+from emodelrunner.json_utilities import NpEncoder
+from emodelrunner.load import (
+    get_morph_args,
+    get_morph_path,
+    get_pair_args,
+    get_release_params,
+    get_stochastic_args,
+    get_syn_mech_args,
+    load_config,
+)
+from emodelrunner.morphology import create_morphology
+from emodelrunner.output import write_responses
+from emodelrunner.protocols.synplas_protocols import SweepProtocolPairSim
+from emodelrunner.progress import finish_progress_report
+from emodelrunner.provenance import get_provenance, write_provenance
+from emodelrunner.results import detect_spikes
+from emodelrunner.simulators import create_simulator
+from emodelrunner.stimuli import MultipleSteps
+from emodelrunner.synapses.create_locations import get_syn_locs
+from emodelrunner.synapses.stimuli import NetConSpikeDetector
+
+logger = logging.getLogger(__name__)
+
+# threshold of the NetCons connecting the presynaptic soma to the synapses (mV)
+PRECELL_SPIKE_THRESHOLD = -30.0
+
+
+def create_pair_cells(config, pair_args):
+    """Create the presynaptic cell and the postsynaptic cell of a pair simulation.
+
+    The postsynaptic cell is the cell of the config, with the synapses
+    from precell_gid only, or with all its synapses if precell_gid is None.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+        pair_args (dict): pair simulation configuration data.
+            See load.get_pair_args for details
+
+    Returns:
+        a tuple containing
+
+        - CellModelCustom: presynaptic cell model
+        - CellModelCustom: postsynaptic cell model
+    """
+    gid = config.getint("Cell", "gid")
+    v_init = config.getfloat("Cell", "v_init")
+    celsius = config.getfloat("Cell", "celsius")
+    instantiation = config.get("Cell", "instantiation")
+    stochastic_args = get_stochastic_args(config)
+
+    syn_mech_args = get_syn_mech_args(config)
+    if pair_args["precell_gid"] is not None:
+        syn_mech_args["selection"]["pre_cell_ids"] = [pair_args["precell_gid"]]
+
+    postcell = create_cell(
+        config.get("Paths", "unoptimized_params_path"),
+        config.get("Cell", "emodel"),
+        True,
+        create_morphology(get_morph_args(config), config.package_type),
+        gid,
+        syn_mech_args,
+        v_init=v_init,
+        celsius=celsius,
+        instantiation=instantiation,
+        stochastic_args=stochastic_args,
+    )
+
+    precell_morph_args = get_morph_args(config)
+    precell_morph_args["morph_path"] = get_morph_path(config, precell=True)
+    precell_gid = pair_args["precell_gid"]
+    precell = create_cell(
+        pair_args["precell_unoptimized_params_path"],
+        pair_args["precell_emodel"],
+        False,
+        create_morphology(precell_morph_args, config.package_type),
+        gid if precell_gid is None else precell_gid,
+        v_init=v_init,
+        celsius=celsius,
+        instantiation=instantiation,
+        stochastic_args=stochastic_args,
+    )
+
+    return precell, postcell
+
+
+def define_pair_protocol(postcell, pair_args, cvode_active, prefix):
+    """Create the protocol making the presynaptic cell spike.
+
+    Each presynaptic spike activates the synapses of the postsynaptic cell.
+
+    Args:
+        postcell (CellModelCustom): postsynaptic cell model
+        pair_args (dict): pair simulation configuration data.
+            See load.get_pair_args for details
+        cvode_active (bool): whether to use variable time step
+        prefix (str): prefix of the recording names
+
+    Raises:
+        ValueError: if there is no presynaptic spike
+            or if the postsynaptic cell has no synapses
+
+    Returns:
+        synplas_protocols.SweepProtocolPairSim: the pair simulation protocol
+    """
+    if not pair_args["spike_times"]:
+        raise ValueError("The pair simulation needs at least one presynaptic spike.")
+
+    soma_loc = ephys.locations.NrnSeclistCompLocation(
+        name="soma", seclist_name="somatic", sec_index=0, comp_x=0.5
+    )
+    syn_locs = get_syn_locs(postcell)
+    if syn_locs is None:
+        raise ValueError("The pair simulation needs the synapses of the cell.")
+
+    presyn_stims = [
+        MultipleSteps(
+            soma_loc,
+            pair_args["spike_times"],
+            pair_args["spike_amplitude"],
+            pair_args["spike_width"],
+        ),
+        NetConSpikeDetector(total_duration=pair_args["tstop"], locations=syn_locs),
+    ]
+
+    pre_rec = ephys.recordings.CompRecording(
+        name=f"{prefix}.Pair_pre.soma.v", location=soma_loc, variable="v"
+    )
+    post_rec = ephys.recordings.CompRecording(
+        name=f"{prefix}.Pair_post.soma.v", location=soma_loc, variable="v"
+    )
+
+    return SweepProtocolPairSim(
+        "Pair", (presyn_stims, []), ([pre_rec], [post_rec]), cvode_active
+    )
+
+
+def compute_epsps(time, voltage, pre_spike_times, window):
+    """Measure the EPSP following each presynaptic spike.
+
+    The baseline is the postsynaptic voltage at the presynaptic spike,
+    before the synaptic delay, and the peak is the maximum voltage
+    in the window following the spike.
+
+    Args:
+        time (numpy.ndarray): time of the postsynaptic trace (ms)
+        voltage (numpy.ndarray): postsynaptic somatic voltage (mV)
+        pre_spike_times (list of floats): presynaptic spike times (ms)
+        window (float): duration after each spike where the peak is searched (ms)
+
+    Returns:
+        dict: presynaptic spike times, and baseline, amplitude (mV) and latency
+            of the peak (ms) of each EPSP, with their paired-pulse ratio,
+            i.e. their amplitude divided by the amplitude of the first one.
+            The values are None when the window is outside of the trace.
+    """
+    time = np.asarray(time)
+    voltage = np.asarray(voltage)
+
+    epsps = {
+        "pre_spike_times": list(pre_spike_times),
+        "baselines": [],
+        "amplitudes": [],
+        "latencies": [],
+    }
+    for spike_time in pre_spike_times:
+        in_window = (time > spike_time) & (time <= spike_time + window)
+        if not np.any(in_window):
+            epsps["baselines"].append(None)
+            epsps["amplitudes"].append(None)
+            epsps["latencies"].append(None)
+            continue
+        baseline = np.interp(spike_time, time, voltage)
+        peak_idx = np.argmax(voltage[in_window])
+        epsps["baselines"].append(baseline)
+        epsps["amplitudes"].append(voltage[in_window][peak_idx] - baseline)
+        epsps["latencies"].append(time[in_window][peak_idx] - spike_time)
+
+    first = epsps["amplitudes"][0] if epsps["amplitudes"] else None
+    epsps["paired_pulse_ratios"] = [
+        None if amplitude is None or not first else amplitude / first
+        for amplitude in epsps["amplitudes"]
+    ]
+    return epsps
+
+
+def write_epsps(epsps, output_dir, provenance=None, filename="pair.json"):
+    """Write the EPSPs of a pair simulation as json.
+
+    Args:
+        epsps (dict): the EPSPs, as returned by compute_epsps
+        output_dir (str): path to the output directory
+        provenance (dict): provenance of the run, written with the EPSPs if given
+        filename (str): name of the json file
+    """
+    output = dict(epsps)
+    if provenance is not None:
+        output["provenance"] = provenance
+    output_path = Path(output_dir) / filename
+    with open(output_path, "w", encoding="utf-8") as out_file:
+        json.dump(output, out_file, indent=4, cls=NpEncoder)
+
+
+def run(config_path, write_output=True, config_overrides=None):
+    """Simulate a presynaptic cell connected to the cell of a sscx package.
+
+    Args:
+        config_path (str): path to config file
+        write_output (bool): whether to write the traces and the EPSPs
+            in the output directory
+        config_overrides (list of str): values overriding the ones of the config file,
+            given as 'section.key=value', e.g. 'Cell.celsius=36'
+
+    Returns:
+        a tuple containing
+
+        - dict: responses of the presynaptic and postsynaptic cells,
+          keyed by recording name
+        - dict: the EPSPs, as returned by compute_epsps
+    """
+    # pylint: disable=too-many-locals
+    start_time = time.perf_counter()
+    config = load_config(config_path=config_path, config_overrides=config_overrides)
+    pair_args = get_pair_args(config)
+
+    precell, postcell = create_pair_cells(config, pair_args)
+    cvode_active = config.getboolean("Sim", "cvode_active")
+    sim = create_simulator(config, cvode_active, config.getfloat("Sim", "dt"))
+
+    prefix = config.get("Morphology", "mtype")
+    protocol = define_pair_protocol(postcell, pair_args, cvode_active, prefix)
+
+    logger.info("Pair Simulation Running...")
+    pre_responses, post_responses = protocol.run(
+        precell_model=precell,
+        postcell_model=postcell,
+        pre_param_values=get_release_params(config, precell=True),
+        post_param_values=get_release_params(config),
+        sim=sim,
+        isolate=False,
+    )
+
+    pre_response = pre_responses[f"{prefix}.Pair_pre.soma.v"]
+    post_response = post_responses[f"{prefix}.Pair_post.soma.v"]
+    pre_spike_times = detect_spikes(
+        pre_response["time"], pre_response["voltage"], PRECELL_SPIKE_THRESHOLD
+    )
+    if len(pre_spike_times) != len(pair_args["spike_times"]):
+        logger.warning(
+            "The presynaptic cell spiked %d times for %d pulses.",
+            len(pre_spike_times),
+            len(pair_args["spike_times"]),
+        )
+    epsps = compute_epsps(
+        post_response["time"],
+        post_response["voltage"],
+        pre_spike_times,
+        pair_args["epsp_window"],
+    )
+
+    responses = {**pre_responses, **post_responses}
+    if write_output:
+        output_dir = config.get("Paths", "output_dir")
+        write_responses(responses, output_dir)
+        provenance = get_provenance(config)
+        write_epsps(epsps, output_dir, provenance=provenance)
+        write_provenance(
+            provenance,
+            Path(output_dir) / "provenance.json",
+            wall_time=time.perf_counter() - start_time,
+        )
+
+    finish_progress_report(sim)
+    logger.info("Pair Simulation Done.")
+
+    return responses, epsps
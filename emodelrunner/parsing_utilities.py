@@ -17,12 +17,75 @@
 import argparse
 import logging
 
+LOG_LEVELS = ["DEBUG", "INFO", "WARNING", "ERROR"]
+LOG_FORMAT = "%(asctime)s %(levelname)s %(name)s: %(message)s"
 
-def get_parser_args():
-    """Get config_path and verbosity from argparse.
+
+def positive_float(value):
+    """Argparse type of the strictly positive numbers.
+
+    Args:
+        value (str): value given on the command line
+
+    Raises:
+        argparse.ArgumentTypeError: if the value is not a strictly positive number
 
     Returns:
-        argparse.Namespace: object containing the parsed arguments
+        float: the value
+    """
+    try:
+        number = float(value)
+    except ValueError as exc:
+        raise argparse.ArgumentTypeError(f"{value} is not a number.") from exc
+    if number <= 0:
+        raise argparse.ArgumentTypeError(f"{value} is not strictly positive.")
+    return number
+
+
+def add_logging_arguments(parser):
+    """Add the verbosity and log destination arguments to a parser.
+
+    Args:
+        parser (argparse.ArgumentParser): the argument parser
+    """
+    parser.add_argument("-v", "--verbose", action="count", dest="verbosity", default=0)
+    parser.add_argument(
+        "--log-level",
+        dest="log_level",
+        choices=LOG_LEVELS,
+        default=None,
+        help="the logging level. Overrides --verbose.",
+    )
+    parser.add_argument(
+        "--log-file",
+        dest="log_file",
+        default=None,
+        help="the path to the file in which to write the logs, instead of stderr.",
+    )
+
+
+def add_config_override_argument(parser):
+    """Add the argument overriding values of the config file to a parser.
+
+    Args:
+        parser (argparse.ArgumentParser): the argument parser
+    """
+    parser.add_argument(
+        "--set",
+        action="append",
+        dest="config_overrides",
+        default=None,
+        metavar="SECTION.KEY=VALUE",
+        help="override a value of the config file, e.g. --set Cell.celsius=36. "
+        "Can be given multiple times.",
+    )
+
+
+def get_parser():
+    """Get the argument parser with the config_path and verbosity arguments.
+
+    Returns:
+        argparse.ArgumentParser: the argument parser
     """
     parser = argparse.ArgumentParser()
     parser.add_argument(
@@ -30,22 +93,742 @@ def get_parser_args():
         default=None,
         help="the path to the config file.",
     )
-    parser.add_argument("-v", "--verbose", action="count", dest="verbosity", default=0)
+    add_logging_arguments(parser)
+    return parser
+
+
+def get_parser_args():
+    """Get config_path, the config overrides and verbosity from argparse.
+
+    Returns:
+        argparse.Namespace: object containing the parsed arguments
+    """
+    parser = get_parser()
+    add_config_override_argument(parser)
+    return parser.parse_args()
+
+
+def add_gui_arguments(parser):
+    """Add the GUI display settings to a parser.
+
+    Args:
+        parser (argparse.ArgumentParser): the argument parser
+    """
+    parser.add_argument(
+        "--fps",
+        type=positive_float,
+        default=15,
+        help="number of times per second the figures are updated while the simulation runs.",
+    )
+    parser.add_argument(
+        "--theme",
+        choices=["light", "dark"],
+        default="light",
+        help="colors theme of the GUI.",
+    )
+    parser.add_argument(
+        "--scaling",
+        type=float,
+        default=None,
+        help="factor by which the widgets and figures are scaled. "
+        "Computed from the screen resolution if not given.",
+    )
+    parser.add_argument(
+        "--session",
+        default=None,
+        help="the path to a session file saved from the GUI, to restore its state. "
+        "The config of the session is used if config_path is not given.",
+    )
+    parser.add_argument(
+        "--screenshot",
+        default=None,
+        help="the path to a directory. If given, no window is opened: "
+        "the simulation is run and the figure panels are saved in this directory.",
+    )
+    parser.add_argument(
+        "--image_format",
+        default="png",
+        help="format of the images saved with --screenshot, e.g. png, svg or pdf.",
+    )
+
+
+def get_gui_parser_args():
+    """Get config_path, verbosity and the GUI display settings from argparse.
+
+    Returns:
+        argparse.Namespace: object containing the parsed arguments
+    """
+    parser = get_parser()
+    add_gui_arguments(parser)
     return parser.parse_args()
 
 
-def set_verbosity(verbosity):
-    """Set verbosity level.
+def get_netpyne_parser_args():
+    """Get config_path, verbosity and the path of the NetPyNE cell rule from argparse.
+
+    Returns:
+        argparse.Namespace: object containing the parsed arguments
+    """
+    parser = get_parser()
+    parser.add_argument(
+        "--output_path",
+        default="netpyne_cell_rule.json",
+        help="the path to the json file in which to write the NetPyNE cell rule.",
+    )
+    return parser.parse_args()
+
+
+def get_neuroml_parser_args():
+    """Get config_path, verbosity and the NeuroML export arguments from argparse.
+
+    Returns:
+        argparse.Namespace: object containing the parsed arguments
+    """
+    parser = get_parser()
+    parser.add_argument(
+        "--output_dir",
+        default="neuroml",
+        help="the directory in which to write the NeuroML files.",
+    )
+    parser.add_argument(
+        "--channels_dir",
+        default=None,
+        help="the directory of the NeuroML ion channel files, "
+        "named {mechanism}.channel.nml.",
+    )
+    parser.add_argument(
+        "--step_amplitude",
+        type=float,
+        default=None,
+        help="the amplitude of the step of the LEMS simulation (nA). "
+        "No LEMS simulation is written if not given.",
+    )
+    parser.add_argument(
+        "--step_delay",
+        type=float,
+        default=700.0,
+        help="the delay of the step of the LEMS simulation (ms).",
+    )
+    parser.add_argument(
+        "--step_duration",
+        type=float,
+        default=2000.0,
+        help="the duration of the step of the LEMS simulation (ms).",
+    )
+    parser.add_argument(
+        "--total_duration",
+        type=float,
+        default=3000.0,
+        help="the duration of the LEMS simulation (ms).",
+    )
+    return parser.parse_args()
+
+
+def get_sonata_parser_args():
+    """Get the SONATA circuit node and the cell packages paths from argparse.
+
+    Returns:
+        argparse.Namespace: object containing the parsed arguments
+    """
+    parser = argparse.ArgumentParser()
+    parser.add_argument(
+        "--circuit_config", required=True, help="the path to the SONATA circuit config."
+    )
+    parser.add_argument(
+        "--node_population", required=True, help="the name of the node population."
+    )
+    parser.add_argument(
+        "--node_id",
+        type=int,
+        required=True,
+        help="the id of the node in the population.",
+    )
+    parser.add_argument(
+        "--template_dir",
+        required=True,
+        help="the path to a sscx cell package used as template "
+        "for the protocols, features, parameters and mechanisms.",
+    )
+    parser.add_argument(
+        "--template_config",
+        default="config/config_synapses.ini",
+        help="the path to the config of the template package, relative to template_dir.",
+    )
+    parser.add_argument(
+        "--output_dir", required=True, help="the path to the cell package to create."
+    )
+    add_logging_arguments(parser)
+    return parser.parse_args()
+
+
+def get_cli_parser():
+    """Get the parser of the emodelrunner command, with one subparser per subcommand.
+
+    Returns:
+        argparse.ArgumentParser: the argument parser
+    """
+    verbosity_parser = argparse.ArgumentParser(add_help=False)
+    add_logging_arguments(verbosity_parser)
+    config_parser = argparse.ArgumentParser(add_help=False)
+    config_parser.add_argument(
+        "--config_path", required=True, help="the path to the config file."
+    )
+    add_config_override_argument(config_parser)
+
+    parser = argparse.ArgumentParser(
+        prog="emodelrunner", description="Run cells from cell packages."
+    )
+    subparsers = parser.add_subparsers(dest="command", required=True)
+
+    subparsers.add_parser(
+        "run",
+        parents=[config_parser, verbosity_parser],
+        help="run the protocols of a sscx, thalamus or synplas package.",
+    )
+    subparsers.add_parser(
+        "run-pairsim",
+        parents=[config_parser, verbosity_parser],
+        help="run the pair simulation of a synplas or sscx package.",
+    )
+    run_arbor_parser = subparsers.add_parser(
+        "run-arbor",
+        parents=[config_parser, verbosity_parser],
+        help="run the step protocols of a sscx, thalamus or hippocampus package "
+        "with the experimental Arbor backend.",
+    )
+    run_arbor_parser.add_argument(
+        "--output_dir",
+        default="arbor_recordings",
+        help="the directory in which to write the traces and the report.",
+    )
+    run_arbor_parser.add_argument(
+        "--max_extent",
+        type=float,
+        default=10.0,
+        help="the maximal length of the Arbor compartments (um).",
+    )
+    run_arbor_parser.add_argument(
+        "--reference_dir",
+        default=None,
+        help="a directory of traces to compare with, e.g. python_recordings.",
+    )
+    subparsers.add_parser(
+        "validate-config",
+        parents=[config_parser, verbosity_parser],
+        help="check that a config file is valid.",
+    )
+    validate_parser = subparsers.add_parser(
+        "validate",
+        parents=[config_parser, verbosity_parser],
+        help="check a config file and the consistency of the morphology, mechanisms, "
+        "synapse and protocols files it refers to.",
+    )
+    validate_parser.add_argument(
+        "--instantiate",
+        action="store_true",
+        help="also instantiate the cell in NEURON, without running any simulation. "
+        "The mechanisms have to be compiled.",
+    )
+    validate_parser.add_argument(
+        "--mechanisms_dir",
+        default="mechanisms",
+        help="the directory containing the mod files.",
+    )
+    subparsers.add_parser(
+        "list-protocols",
+        parents=[config_parser, verbosity_parser],
+        help="list the protocols of the protocols file of a config.",
+    )
+
+    factsheet_parser = subparsers.add_parser(
+        "factsheet",
+        parents=[config_parser, verbosity_parser],
+        help="write the me-type and e-model factsheets of a sscx, thalamus "
+        "or hippocampus package "
+        "from the output of its run.",
+    )
+    factsheet_parser.add_argument(
+        "--protocol_key",
+        default="RmpRiTau",
+        help="the name of the protocol used for the physiology features.",
+    )
+    factsheet_parser.add_argument(
+        "--output_dir",
+        default="factsheets",
+        help="the directory in which to write the factsheets.",
+    )
+
+    export_hoc_parser = subparsers.add_parser(
+        "export-hoc",
+        parents=[config_parser, verbosity_parser],
+        help="write a self-contained hoc package of the cell of a sscx config, "
+        "with its morphology and mod files, that runs with NEURON only.",
+    )
+    export_hoc_parser.add_argument(
+        "--output_dir", required=True, help="the directory of the hoc package."
+    )
+
+    setup_parser = subparsers.add_parser(
+        "setup",
+        parents=[verbosity_parser],
+        help="check NEURON and compile the mechanisms of a cell package.",
+    )
+    setup_parser.add_argument(
+        "--package_dir", default=".", help="the path to the cell package."
+    )
+    setup_parser.add_argument(
+        "--mechanisms_dir",
+        default="mechanisms",
+        help="the directory containing the mod files, relative to package_dir.",
+    )
+    setup_parser.add_argument(
+        "--cache_dir",
+        default=None,
+        help="the directory in which the compiled mechanisms are cached. "
+        "Defaults to $EMODELRUNNER_CACHE or ~/.cache/emodelrunner.",
+    )
+    setup_parser.add_argument(
+        "--coreneuron",
+        action="store_true",
+        help="also compile the mechanisms for CoreNEURON.",
+    )
+
+    fetch_parser = subparsers.add_parser(
+        "fetch",
+        parents=[verbosity_parser],
+        help="download a cell package from a registry. The token of the registry "
+        "is read from $EMODELRUNNER_REGISTRY_TOKEN.",
+    )
+    fetch_parser.add_argument(
+        "--model_id", required=True, help="the identifier of the model."
+    )
+    fetch_parser.add_argument(
+        "--registry_url", required=True, help="the url of the registry."
+    )
+    fetch_parser.add_argument(
+        "--registry_type",
+        choices=["https", "nexus"],
+        default="https",
+        help="https for a registry serving {model_id}.zip, "
+        "nexus for the files endpoint of a Nexus project.",
+    )
+    fetch_parser.add_argument(
+        "--output_dir",
+        default=".",
+        help="the directory in which to create the cell package.",
+    )
+    fetch_parser.add_argument(
+        "--template_dir",
+        default=None,
+        help="the path to a cell package used as template for the config, "
+        "protocols, features and templates, if the downloaded package has no config.",
+    )
+    fetch_parser.add_argument(
+        "--template_config",
+        default="config/config_allsteps.ini",
+        help="the path to the config of the template package, relative to template_dir.",
+    )
+
+    recipe_parser = subparsers.add_parser(
+        "convert-recipe",
+        parents=[verbosity_parser],
+        help="write the protocols and features files of a BluePyEModel e-model.",
+    )
+    recipe_parser.add_argument(
+        "--recipes_path", required=True, help="the path to the BluePyEModel recipes."
+    )
+    recipe_parser.add_argument(
+        "--emodel", required=True, help="the name of the e-model in the recipes."
+    )
+    recipe_parser.add_argument(
+        "--emodel_dir",
+        default=".",
+        help="the directory the paths of the recipes are relative to.",
+    )
+    recipe_parser.add_argument(
+        "--output_dir",
+        default="config",
+        help="the directory in which to write the protocols and features directories.",
+    )
+    recipe_parser.add_argument(
+        "--skip_unsupported",
+        action="store_true",
+        help="skip the protocols that cannot be converted instead of failing.",
+    )
+
+    package_recipe_parser = subparsers.add_parser(
+        "package-recipe",
+        parents=[verbosity_parser],
+        help="write a cell package, with its config, running a BluePyEModel e-model "
+        "from its recipe.",
+    )
+    package_recipe_parser.add_argument(
+        "--recipes_path", required=True, help="the path to the BluePyEModel recipes."
+    )
+    package_recipe_parser.add_argument(
+        "--emodel", required=True, help="the name of the e-model in the recipes."
+    )
+    package_recipe_parser.add_argument(
+        "--emodel_dir",
+        default=".",
+        help="the directory the paths of the recipes are relative to.",
+    )
+    package_recipe_parser.add_argument(
+        "--output_dir", required=True, help="the directory of the cell package."
+    )
+    package_recipe_parser.add_argument(
+        "--package_type",
+        default="sscx",
+        choices=["sscx", "thalamus", "hippocampus"],
+        help="the package type of the config.",
+    )
+    package_recipe_parser.add_argument(
+        "--template_dir",
+        default=None,
+        help="a cell package whose files missing in the new package are copied, "
+        "e.g. the hoc templates of the sscx packages.",
+    )
+    package_recipe_parser.add_argument(
+        "--skip_unsupported",
+        action="store_true",
+        help="skip the protocols that cannot be converted instead of failing.",
+    )
+
+    package_access_point_parser = subparsers.add_parser(
+        "package-access-point",
+        parents=[verbosity_parser],
+        help="write a cell package, with its config, running the e-model "
+        "of a BluePyEModel local or Nexus access point, and optionally run it.",
+    )
+    package_access_point_parser.add_argument(
+        "--access_point",
+        default="local",
+        choices=["local", "nexus"],
+        help="the type of the BluePyEModel access point.",
+    )
+    package_access_point_parser.add_argument(
+        "--emodel", required=True, help="the name of the e-model."
+    )
+    package_access_point_parser.add_argument(
+        "--etype", default=None, help="the e-type of the e-model."
+    )
+    package_access_point_parser.add_argument(
+        "--ttype", default=None, help="the t-type of the e-model."
+    )
+    package_access_point_parser.add_argument(
+        "--iteration_tag", default=None, help="the iteration tag of the e-model."
+    )
+    package_access_point_parser.add_argument(
+        "--emodel_dir",
+        default=None,
+        help="the directory of the e-model, for a local access point.",
+    )
+    package_access_point_parser.add_argument(
+        "--recipes_path",
+        default=None,
+        help="the path to the recipes, for a local access point.",
+    )
+    package_access_point_parser.add_argument(
+        "--project", default=None, help="the Nexus project, for a Nexus access point."
+    )
+    package_access_point_parser.add_argument(
+        "--organisation",
+        default=None,
+        help="the Nexus organisation, for a Nexus access point.",
+    )
+    package_access_point_parser.add_argument(
+        "--endpoint", default=None, help="the Nexus endpoint, for a Nexus access point."
+    )
+    package_access_point_parser.add_argument(
+        "--output_dir", required=True, help="the directory of the cell package."
+    )
+    package_access_point_parser.add_argument(
+        "--package_type",
+        default="sscx",
+        choices=["sscx", "thalamus", "hippocampus"],
+        help="the package type of the config.",
+    )
+    package_access_point_parser.add_argument(
+        "--template_dir",
+        default=None,
+        help="a cell package whose files missing in the new package are copied, "
+        "e.g. the hoc templates of the sscx packages.",
+    )
+    package_access_point_parser.add_argument(
+        "--skip_unsupported",
+        action="store_true",
+        help="skip the protocols that cannot be converted instead of failing.",
+    )
+    package_access_point_parser.add_argument(
+        "--run",
+        action="store_true",
+        help="compile the mechanisms and run the cell package once written.",
+    )
+    package_access_point_parser.add_argument(
+        "--cache_dir",
+        default=None,
+        help="the cache directory of the compiled mechanisms, used with --run.",
+    )
+
+    regression_parser = subparsers.add_parser(
+        "regression",
+        parents=[config_parser, verbosity_parser],
+        help="run a sscx or thalamus config and compare its responses "
+        "with reference outputs.",
+    )
+    regression_parser.add_argument(
+        "--reference_dir",
+        required=True,
+        help="the directory containing the reference outputs of a previous run.",
+    )
+    regression_parser.add_argument(
+        "--voltage_rms",
+        type=float,
+        default=0.1,
+        help="the maximum root mean square difference between the traces (mV).",
+    )
+    regression_parser.add_argument(
+        "--spike_time_shift",
+        type=float,
+        default=0.1,
+        help="the maximum shift of the spike times (ms).",
+    )
+    regression_parser.add_argument(
+        "--scalar_rtol",
+        type=float,
+        default=1e-3,
+        help="the relative tolerance of the scalar outputs, e.g. the holding current.",
+    )
+    regression_parser.add_argument(
+        "--report_path",
+        default=None,
+        help="the path to the json report to write.",
+    )
+
+    sweep_parser = subparsers.add_parser(
+        "sweep",
+        parents=[config_parser, verbosity_parser],
+        help="run a sscx or thalamus config for each combination "
+        "of the swept parameter values, in parallel.",
+    )
+    sweep_parser.add_argument(
+        "--sweep_path",
+        required=True,
+        help="the path to the json file defining the mode and the swept values.",
+    )
+    sweep_parser.add_argument(
+        "--output_dir",
+        default="sweep",
+        help="the directory in which to write the outputs of the sweep.",
+    )
+    sweep_parser.add_argument(
+        "--n_processes",
+        type=int,
+        default=None,
+        help="the number of runs in parallel. Defaults to the number of CPUs.",
+    )
+
+    trials_parser = subparsers.add_parser(
+        "trials",
+        parents=[config_parser, verbosity_parser],
+        help="run trials of a stochastic sscx, thalamus or hippocampus config "
+        "in parallel, and average their traces.",
+    )
+    trials_parser.add_argument(
+        "--n_trials", type=int, required=True, help="the number of trials."
+    )
+    trials_parser.add_argument(
+        "--output_dir",
+        default="trials",
+        help="the directory in which to write the outputs of the trials.",
+    )
+    trials_parser.add_argument(
+        "--seed",
+        type=int,
+        default=None,
+        help="the seed from which the seeds of the trials are derived. "
+        "Defaults to the global seed of the config, or 0.",
+    )
+    trials_parser.add_argument(
+        "--n_processes",
+        type=int,
+        default=None,
+        help="the number of trials in parallel. Defaults to the number of CPUs.",
+    )
+    trials_parser.add_argument(
+        "--dt",
+        type=float,
+        default=0.1,
+        help="the time step of the averaged traces (ms).",
+    )
+    trials_parser.add_argument(
+        "--no_plot",
+        action="store_true",
+        help="do not plot the averaged traces.",
+    )
+
+    sensitivity_parser = subparsers.add_parser(
+        "sensitivity",
+        parents=[config_parser, verbosity_parser],
+        help="rank the parameters of a sscx or thalamus config by the change "
+        "of the e-features when they are perturbed.",
+    )
+    sensitivity_parser.add_argument(
+        "--sensitivity_path",
+        required=True,
+        help="the path to the json file defining the perturbed parameters "
+        "and the perturbation method.",
+    )
+    sensitivity_parser.add_argument(
+        "--output_dir",
+        default="sensitivity",
+        help="the directory in which to write the outputs of the analysis.",
+    )
+    sensitivity_parser.add_argument(
+        "--n_processes",
+        type=int,
+        default=None,
+        help="the number of runs in parallel. Defaults to the number of CPUs.",
+    )
+
+    network_parser = subparsers.add_parser(
+        "network",
+        parents=[config_parser, verbosity_parser],
+        help="simulate a few connected cells of a SONATA circuit, with the settings "
+        "of a sscx template package. Needs libsonata.",
+    )
+    network_parser.add_argument(
+        "--circuit_config", required=True, help="the path to the SONATA circuit config."
+    )
+    network_parser.add_argument(
+        "--node_population", required=True, help="the name of the node population."
+    )
+    network_parser.add_argument(
+        "--node_set",
+        default="",
+        help="the name of the node set of the cells, in the node sets file "
+        "of the circuit.",
+    )
+    network_parser.add_argument(
+        "--node_ids",
+        type=int,
+        nargs="+",
+        default=None,
+        help="the ids of the cells in the population, if no node set is given.",
+    )
+    network_parser.add_argument(
+        "--spikes_path",
+        default="",
+        help="the path to the spikes of the cells outside of the network, "
+        "replayed on their synapses.",
+    )
+    network_parser.add_argument(
+        "--spike_population",
+        default="",
+        help="the node population of the spikes. "
+        "The synapses of the other populations are left out.",
+    )
+    network_parser.add_argument(
+        "--tstop",
+        type=float,
+        default=1000.0,
+        help="the duration of the simulation (ms).",
+    )
+    network_parser.add_argument(
+        "--output_dir",
+        default="network",
+        help="the directory in which to write the traces, spikes and summary.",
+    )
+
+    batch_parser = subparsers.add_parser(
+        "batch",
+        parents=[verbosity_parser],
+        help="run each protocols file on each cell package of a batch, "
+        "distributed over the ranks of an MPI job.",
+    )
+    batch_parser.add_argument(
+        "--batch_path",
+        required=True,
+        help="the path to the json file listing the cell packages and protocols files.",
+    )
+    batch_parser.add_argument(
+        "--output_dir",
+        default="batch",
+        help="the directory in which to write the outputs of the batch.",
+    )
+    batch_parser.add_argument(
+        "--timeout",
+        type=float,
+        default=None,
+        help="the maximum duration of each job (s).",
+    )
+    batch_parser.add_argument(
+        "--mpi",
+        action="store_true",
+        help="distribute the jobs over the MPI ranks. Needs mpi4py.",
+    )
+
+    plot_parser = subparsers.add_parser(
+        "plot",
+        parents=[verbosity_parser],
+        help="plot the standard figures of the outputs of a finished run "
+        "in the plots directory of the output directory.",
+    )
+    plot_parser.add_argument(
+        "output_dir", help="the directory containing the outputs of the run."
+    )
+    plot_parser.add_argument(
+        "--file_format",
+        default="png",
+        choices=["png", "svg"],
+        help="the format of the plots.",
+    )
+    plot_parser.add_argument(
+        "--step_prefix",
+        default="Step",
+        help="the beginning of the names of the step protocols to overlay.",
+    )
+
+    subparsers.add_parser(
+        "capabilities",
+        parents=[verbosity_parser],
+        help="print the supported package, protocol, stimulus and recording types "
+        "as json.",
+    )
+
+    gui_parser = subparsers.add_parser(
+        "gui", parents=[verbosity_parser], help="open the GUI."
+    )
+    gui_parser.add_argument(
+        "--config_path", default=None, help="the path to the config file."
+    )
+    add_gui_arguments(gui_parser)
+
+    return parser
+
+
+def set_verbosity(verbosity, log_level=None, log_file=None):
+    """Set verbosity level and log destination.
 
     Args:
         verbosity (int): verbosity level. 0 for warning, 1 for info and 2 or more for debug
+        log_level (str): logging level, e.g. "INFO". Overrides verbosity if given.
+        log_file (str): path to the file in which to write the logs.
+            The logs are written to stderr if None.
     """
     if verbosity > 2:
         verbosity = 2
     elif verbosity < 0:
         verbosity = 0
 
-    logging.basicConfig(
-        level=(logging.WARNING, logging.INFO, logging.DEBUG)[verbosity],
-        handlers=[logging.StreamHandler()],
-    )
+    if log_level is not None:
+        level = getattr(logging, log_level)
+    else:
+        level = (logging.WARNING, logging.INFO, logging.DEBUG)[verbosity]
+
+    if log_file is not None:
+        handler = logging.FileHandler(log_file)
+    else:
+        handler = logging.StreamHandler()
+
+    logging.basicConfig(level=level, format=LOG_FORMAT, handlers=[handler])
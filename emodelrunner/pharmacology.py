@@ -0,0 +1,152 @@
+"""Channel blocks, setting or scaling conductances to simulate pharmacology."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import fnmatch
+import logging
+import re
+
+logger = logging.getLogger(__name__)
+
+# 'name=value' sets the parameter to value, 'name*factor' scales it by factor
+BLOCK_PATTERN = re.compile(
+    r"^\s*(?P<name>[^=*\s]+)\s*(?P<operation>[=*])\s*"
+    r"(?P<value>[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?)\s*$"
+)
+
+
+def parse_channel_blocks(blocks):
+    """Parse the channel blocks of the config.
+
+    Args:
+        blocks (str): comma-separated blocks, e.g. 'gSKv3_1bar=0, gNaTgbar*0.5'.
+            A name can be the full name of a parameter, e.g. 'gNaTgbar_NaTg.axonal',
+            the name of its range variable, e.g. 'gNaTgbar_NaTg',
+            or the range variable without the mechanism suffix, e.g. 'gNaTgbar',
+            and can contain wildcards, e.g. 'gCa*bar'
+
+    Raises:
+        ValueError: if a block does not have the 'name=value' or 'name*factor' format
+
+    Returns:
+        list of dict: name, operation ('set' or 'scale') and value of each block
+    """
+    channel_blocks = []
+    for block in blocks.split(","):
+        if not block.strip():
+            continue
+        match = BLOCK_PATTERN.match(block)
+        if match is None:
+            raise ValueError(
+                f"Could not parse the channel block {block.strip()!r}. "
+                "Expected 'name=value' or 'name*factor', e.g. 'gNaTgbar*0.5'."
+            )
+        channel_blocks.append(
+            {
+                "name": match.group("name"),
+                "operation": "set" if match.group("operation") == "=" else "scale",
+                "value": float(match.group("value")),
+            }
+        )
+
+    return channel_blocks
+
+
+def get_parameter_names(parameter):
+    """Return the names a channel block can use for a parameter.
+
+    Args:
+        parameter (bluepyopt.ephys.parameters.NrnParameter): parameter of the cell
+
+    Returns:
+        list of str: full name, range variable name and, for the conductances,
+            range variable name without the mechanism suffix, e.g. 'gNaTgbar'
+    """
+    param_name = getattr(
+        parameter, "param_name", getattr(parameter, "attr_name", parameter.name)
+    )
+    names = [parameter.name, param_name]
+    # e.g. gNaTgbar_NaTg -> gNaTgbar
+    if "bar_" in param_name:
+        names.append(param_name.partition("bar_")[0] + "bar")
+
+    return names
+
+
+def apply_channel_blocks(cell, param_values, channel_blocks):
+    """Set or scale the parameters of the cell matching the channel blocks.
+
+    The blocked parameters are frozen to their new value, without bounds,
+    and removed from the parameter values to give to the protocols.
+
+    Args:
+        cell (CellModelCustom): cell model
+        param_values (dict): values of the parameters that are not frozen
+        channel_blocks (list of dict): blocks, as returned by parse_channel_blocks
+
+    Raises:
+        ValueError: if a block does not match any parameter of the cell
+
+    Returns:
+        tuple: the parameter values of the parameters that are not blocked,
+            and the original and new value of each blocked parameter
+    """
+    param_values = dict(param_values)
+    perturbations = {}
+    for channel_block in channel_blocks:
+        parameters = [
+            parameter
+            for parameter in cell.params.values()
+            if any(
+                fnmatch.fnmatchcase(name, channel_block["name"])
+                for name in get_parameter_names(parameter)
+            )
+        ]
+        if not parameters:
+            raise ValueError(
+                f"The channel block {channel_block['name']} "
+                "does not match any parameter of the cell."
+            )
+
+        for parameter in parameters:
+            if parameter.frozen:
+                value = parameter.value
+                parameter.unfreeze()
+            else:
+                value = param_values.pop(parameter.name)
+            # keep the original value of the parameters blocked several times
+            original_value = perturbations.get(parameter.name, {}).get(
+                "original_value", value
+            )
+            if channel_block["operation"] == "set":
+                value = channel_block["value"]
+            else:
+                value = value * channel_block["value"]
+
+            parameter.bounds = None
+            parameter.freeze(value)
+            perturbations[parameter.name] = {
+                "original_value": original_value,
+                "value": value,
+            }
+            logger.debug(
+                "Channel block: %s set from %s to %s",
+                parameter.name,
+                original_value,
+                value,
+            )
+
+    return param_values, perturbations
@@ -0,0 +1,438 @@
+"""Plots of the responses, written at the end of a run."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import logging
+from pathlib import Path
+
+import h5py
+import numpy as np
+from matplotlib import cm
+from matplotlib.figure import Figure
+
+from emodelrunner.hooks import get_protocol_responses
+from emodelrunner.results import load_traces
+
+logger = logging.getLogger(__name__)
+
+# datasets of the synplas outputs that are not synapse variables
+SYNPLAS_OUTPUT_DATASETS = ["t", "v", "prespikes"]
+SYNAPSE_RECORDINGS_FILENAME = "synapses.h5"
+
+# y-axis labels of the recorded variables
+VARIABLE_LABELS = {"v": "Voltage (mV)", "current": "Current (nA)"}
+
+
+def get_protocol_current(currents, protocol_name):
+    """Return the current injected by a protocol.
+
+    Args:
+        currents (dict): currents injected by the protocols,
+            with keys such as 'current_prefix.protocol_name'
+        protocol_name (str): name of the protocol
+
+    Returns:
+        dict containing the time and the current, or None if not found
+    """
+    for key, current in currents.items():
+        if key.endswith(f".{protocol_name}"):
+            return current
+    return None
+
+
+def plot_protocol(protocol_name, responses, current=None):
+    """Plot the responses of a protocol, with the injected current overlaid.
+
+    Args:
+        protocol_name (str): name of the protocol
+        responses (dict): responses of the protocol
+        current (dict): time and injected current of the protocol
+
+    Returns:
+        matplotlib.figure.Figure: the figure
+    """
+    fig = Figure(figsize=(10, 5))
+    ax = fig.add_subplot(1, 1, 1)
+    for key, resp in sorted(responses.items()):
+        if not isinstance(resp, dict) or "voltage" not in resp:
+            continue
+        ax.plot(resp["time"], resp["voltage"], label=key)
+    ax.set_xlabel("Time (ms)")
+    ax.set_ylabel("Voltage (mV)")
+    ax.set_title(protocol_name)
+    ax.legend(loc="upper left", fontsize="small")
+
+    if current is not None:
+        ax_current = ax.twinx()
+        ax_current.plot(current["time"], current["current"], color="grey", alpha=0.5)
+        ax_current.set_ylabel("Current (nA)", color="grey")
+
+    fig.tight_layout()
+    return fig
+
+
+def plot_responses(responses, currents, output_dir, file_format="png"):
+    """Plot the responses of each protocol in a separate file.
+
+    Args:
+        responses (dict): responses of the protocols
+        currents (dict): currents injected by the protocols
+        output_dir (str or Path): directory in which the plots directory is created
+        file_format (str): format of the plots ('png' or 'svg')
+
+    Returns:
+        list: paths to the plot files
+    """
+    plot_dir = Path(output_dir) / "plots"
+    plot_dir.mkdir(parents=True, exist_ok=True)
+
+    paths = []
+    for protocol_name, protocol_responses in get_protocol_responses(responses).items():
+        fig = plot_protocol(
+            protocol_name,
+            protocol_responses,
+            get_protocol_current(currents, protocol_name),
+        )
+        path = plot_dir / f"{protocol_name}.{file_format}"
+        fig.savefig(path, format=file_format)
+        paths.append(path)
+        logger.debug("Plot of %s written to %s", protocol_name, path)
+
+    return paths
+
+
+def plot_fi_curve(protocol_name, fi_curve):
+    """Plot the firing rate versus the step amplitude, with the fitted line.
+
+    Args:
+        protocol_name (str): name of the f-I curve protocol
+        fi_curve (dict): the f-I curve, as returned by fi_curve.get_fi_curve
+
+    Returns:
+        matplotlib.figure.Figure: the figure
+    """
+    fig = Figure(figsize=(4, 3.5))
+    ax = fig.add_subplot(1, 1, 1)
+    ax.plot(
+        fi_curve["step_amplitude"],
+        fi_curve["firing_rate"],
+        "o",
+        color="black",
+        label="model",
+    )
+
+    fit = fi_curve["fit"]
+    if fit["rheobase"] is not None:
+        x_fit = [fit["rheobase"], max(fi_curve["step_amplitude"])]
+        ax.plot(
+            x_fit,
+            [fit["gain"] * x + fit["intercept"] for x in x_fit],
+            "--",
+            color="tab:red",
+            label=f"fit: {fit['gain']:.0f} Hz/nA, $R^2$ = {fit['r_squared']:.2f}",
+        )
+        ax.legend(loc="upper left", fontsize="small", frameon=False)
+
+    ax.set_xlabel("Step amplitude (nA)")
+    ax.set_ylabel("Firing rate (Hz)")
+    ax.set_title(protocol_name)
+    ax.set_ylim(bottom=0)
+    ax.spines["top"].set_visible(False)
+    ax.spines["right"].set_visible(False)
+
+    fig.tight_layout()
+    return fig
+
+
+def plot_fi_curves(fi_curves, output_dir, file_format="png", dpi=300):
+    """Plot the f-I curve of each f-I curve protocol in a separate file.
+
+    Args:
+        fi_curves (dict): the f-I curve of each protocol name
+        output_dir (str or Path): directory in which the plots directory is created
+        file_format (str): format of the plots ('png' or 'svg')
+        dpi (int): resolution of the raster plots
+
+    Returns:
+        list: paths to the plot files
+    """
+    plot_dir = Path(output_dir) / "plots"
+    plot_dir.mkdir(parents=True, exist_ok=True)
+
+    paths = []
+    for protocol_name, fi_curve in fi_curves.items():
+        fig = plot_fi_curve(protocol_name, fi_curve)
+        path = plot_dir / f"{protocol_name}_fi_curve.{file_format}"
+        fig.savefig(path, format=file_format, dpi=dpi)
+        paths.append(path)
+        logger.debug("f-I curve of %s written to %s", protocol_name, path)
+
+    return paths
+
+
+def plot_protocol_traces(protocol_name, traces):
+    """Plot the traces of a protocol loaded from an output directory.
+
+    Each variable, e.g. the voltage or the injected current, is plotted on its own axis,
+    with one line per location.
+
+    Args:
+        protocol_name (str): name of the protocol
+        traces (pandas.DataFrame): traces of the protocol, as loaded by
+            results.load_traces
+
+    Returns:
+        matplotlib.figure.Figure: the figure
+    """
+    variables = list(dict.fromkeys(traces["variable"]))
+    fig = Figure(figsize=(10, 1 + 2.5 * len(variables)))
+    for i, variable in enumerate(variables):
+        ax = fig.add_subplot(len(variables), 1, i + 1)
+        variable_traces = traces[traces["variable"] == variable]
+        labels = (
+            variable_traces["prefix"].astype(str)
+            + "."
+            + variable_traces["location"].astype(str)
+        )
+        for label, trace in variable_traces.groupby(labels, sort=True):
+            ax.plot(trace["time"], trace["value"], label=label)
+        ax.set_ylabel(VARIABLE_LABELS.get(variable, variable))
+        if variable != "current":
+            ax.legend(loc="upper left", fontsize="small")
+    fig.axes[-1].set_xlabel("Time (ms)")
+    fig.axes[0].set_title(protocol_name)
+
+    fig.tight_layout()
+    return fig
+
+
+def plot_step_overlay(traces, step_prefix="Step"):
+    """Overlay the somatic voltage of the step protocols.
+
+    Args:
+        traces (pandas.DataFrame): traces loaded by results.load_traces
+        step_prefix (str): beginning of the names of the step protocols
+
+    Returns:
+        matplotlib.figure.Figure: the figure, or None if there is no step protocol
+    """
+    is_step = traces["protocol"].fillna("").str.startswith(step_prefix)
+    is_soma_voltage = (traces["variable"] == "v") & (traces["location"] == "soma")
+    step_traces = traces[is_step & is_soma_voltage]
+    if step_traces.empty:
+        return None
+
+    protocol_names = sorted(set(step_traces["protocol"]))
+    fig = Figure(figsize=(10, 5))
+    ax = fig.add_subplot(1, 1, 1)
+    for i, protocol_name in enumerate(protocol_names):
+        trace = step_traces[step_traces["protocol"] == protocol_name]
+        color = cm.viridis(i / max(len(protocol_names) - 1, 1))
+        ax.plot(trace["time"], trace["value"], color=color, label=protocol_name)
+    ax.set_xlabel("Time (ms)")
+    ax.set_ylabel(VARIABLE_LABELS["v"])
+    ax.legend(loc="upper left", fontsize="small")
+
+    fig.tight_layout()
+    return fig
+
+
+def plot_trial_average(name, average):
+    """Plot the mean of a trace over trials, with its standard deviation band.
+
+    Args:
+        name (str): name of the trace
+        average (dict): time, mean and std of the trace over the trials
+
+    Returns:
+        matplotlib.figure.Figure: the figure
+    """
+    fig = Figure(figsize=(10, 5))
+    ax = fig.add_subplot(1, 1, 1)
+    mean = np.asarray(average["mean"])
+    std = np.asarray(average["std"])
+    ax.fill_between(average["time"], mean - std, mean + std, alpha=0.3, label="std")
+    ax.plot(average["time"], mean, label="mean")
+    ax.set_xlabel("Time (ms)")
+    ax.set_ylabel(VARIABLE_LABELS["v"])
+    ax.set_title(name)
+    ax.legend(loc="upper left", fontsize="small")
+
+    fig.tight_layout()
+    return fig
+
+
+def plot_synapse_variable(name, time, values, variable="i"):
+    """Plot a variable of each recorded synapse, with their sum.
+
+    Args:
+        name (str): name of the synapse recording
+        time (numpy.ndarray): time (ms)
+        values (numpy.ndarray): values of the variable, with shape (n_synapses, n_times)
+        variable (str): name of the variable, e.g. 'i' for the synaptic current (nA)
+
+    Returns:
+        matplotlib.figure.Figure: the figure
+    """
+    fig = Figure(figsize=(10, 5))
+    ax = fig.add_subplot(1, 1, 1)
+    for synapse_values in values:
+        ax.plot(time, synapse_values, color="grey", alpha=0.3, linewidth=0.5)
+    ax.plot(time, np.nansum(values, axis=0), color="black", label="sum")
+    ax.set_xlabel("Time (ms)")
+    ax.set_ylabel(variable)
+    ax.set_title(f"{name} ({len(values)} synapses)")
+    ax.legend(loc="upper left", fontsize="small")
+
+    fig.tight_layout()
+    return fig
+
+
+def plot_plasticity_variables(name, time, variables, pre_spikes=None):
+    """Plot the time courses of the plasticity variables of the synapses.
+
+    Args:
+        name (str): name of the synplas output
+        time (numpy.ndarray): time (ms)
+        variables (dict): values of each variable, e.g. 'rho_GB' or 'Use_TM',
+            with shape (n_times, n_synapses)
+        pre_spikes (list): times at which the synapses fire (ms). Optional.
+
+    Returns:
+        matplotlib.figure.Figure: the figure
+    """
+    fig = Figure(figsize=(10, 1 + 2 * len(variables)))
+    for i, (variable, values) in enumerate(sorted(variables.items())):
+        # one column per synapse
+        values = np.reshape(values, (len(time), -1))
+        ax = fig.add_subplot(len(variables), 1, i + 1)
+        ax.plot(time, values, color="grey", alpha=0.3, linewidth=0.5)
+        ax.plot(time, np.nanmean(values, axis=1), color="black", label="mean")
+        if pre_spikes is not None and len(pre_spikes):
+            # pre-synaptic spikes as ticks at the top of the axis
+            ax.plot(
+                pre_spikes,
+                np.full(len(pre_spikes), np.nanmax(values)),
+                "|",
+                color="tab:red",
+            )
+        ax.set_ylabel(variable)
+    fig.axes[-1].set_xlabel("Time (ms)")
+    fig.axes[0].set_title(name)
+    fig.axes[0].legend(loc="upper left", fontsize="small")
+
+    fig.tight_layout()
+    return fig
+
+
+def _synapse_recordings_figures(synapses_path, variable="i"):
+    """Yield the name and the figure of each synapse recording of a synapses file.
+
+    Args:
+        synapses_path (Path): path to the file written by output.write_synapse_recordings
+        variable (str): plotted variable of the synapses
+
+    Yields:
+        tuple containing the name of the figure and the figure
+    """
+    with h5py.File(synapses_path, "r") as h5file:
+        for key, group in h5file.items():
+            if variable in group:
+                fig = plot_synapse_variable(
+                    key, group["time"][()], group[variable][()], variable
+                )
+                yield f"{key}_{variable}", fig
+
+
+def _plasticity_figures(output_dir):
+    """Yield the name and the figure of each synplas output of a directory.
+
+    Args:
+        output_dir (Path): directory containing the outputs
+
+    Yields:
+        tuple containing the name of the figure and the figure
+    """
+    for path in sorted(output_dir.glob("*.h5")):
+        with h5py.File(path, "r") as h5file:
+            # synplas outputs are the h5 files with the time and the pre-synaptic spikes
+            if not all(name in h5file for name in SYNPLAS_OUTPUT_DATASETS):
+                continue
+            variables = {
+                name: dataset[()]
+                for name, dataset in h5file.items()
+                if name not in SYNPLAS_OUTPUT_DATASETS
+            }
+            if variables:
+                fig = plot_plasticity_variables(
+                    path.stem, h5file["t"][()], variables, h5file["prespikes"][()]
+                )
+                yield f"{path.stem}_plasticity", fig
+
+
+def plot_output_dir(output_dir, file_format="png", dpi=300, step_prefix="Step"):
+    """Plot the standard figures of the outputs of a finished run, without GUI.
+
+    The figures are written in the plots directory of output_dir:
+
+    - the traces of each protocol, with the injected current, from the .dat outputs
+    - the overlay of the somatic voltage of the step protocols
+    - the currents of the recorded synapses, from the synapses.h5 output
+    - the time courses of the plasticity variables of the synplas h5 outputs
+
+    Args:
+        output_dir (str or Path): directory containing the outputs of the run
+        file_format (str): format of the plots ('png' or 'svg')
+        dpi (int): resolution of the raster plots
+        step_prefix (str): beginning of the names of the step protocols
+
+    Raises:
+        FileNotFoundError: if the output directory does not exist
+        ValueError: if there is nothing to plot in the output directory
+
+    Returns:
+        list: paths to the plot files
+    """
+    output_dir = Path(output_dir)
+    if not output_dir.is_dir():
+        raise FileNotFoundError(f"The output directory {output_dir} does not exist.")
+
+    figures = []
+    traces = load_traces(output_dir)
+    for protocol_name, protocol_traces in traces.groupby("protocol", sort=True):
+        fig = plot_protocol_traces(protocol_name, protocol_traces)
+        figures.append((protocol_name, fig))
+    step_overlay = plot_step_overlay(traces, step_prefix)
+    if step_overlay is not None:
+        figures.append(("step_overlay", step_overlay))
+
+    synapses_path = output_dir / SYNAPSE_RECORDINGS_FILENAME
+    if synapses_path.is_file():
+        figures.extend(_synapse_recordings_figures(synapses_path))
+    figures.extend(_plasticity_figures(output_dir))
+
+    if not figures:
+        raise ValueError(f"No output to plot in {output_dir}.")
+
+    plot_dir = output_dir / "plots"
+    plot_dir.mkdir(parents=True, exist_ok=True)
+    paths = []
+    for name, fig in figures:
+        path = plot_dir / f"{name}.{file_format}"
+        fig.savefig(path, format=file_format, dpi=dpi)
+        paths.append(path)
+        logger.debug("Plot %s written to %s", name, path)
+
+    return paths
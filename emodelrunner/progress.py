@@ -0,0 +1,205 @@
+"""Progress reports of the simulations, to monitor long runs."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import datetime
+import json
+import logging
+import os
+import time
+from pathlib import Path
+
+logger = logging.getLogger(__name__)
+
+
+class ProgressReporter:
+    """Reports the progress of the simulations at regular simulated times.
+
+    Each simulation, i.e. each initialization of NEURON, is a run. The progress
+    of the current run is logged, and written in a json status file if any,
+    every interval of simulated time.
+
+    Attributes:
+        interval (float): simulated time between the reports (ms)
+        status_path (str): path to the json status file. Not written if None.
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        n_runs (int): number of runs started
+        simulated_time (float): simulated time of the previous runs,
+            up to their last report (ms)
+        start_time (float): wall time at which the reporter was created (s)
+        run_start_time (float): wall time at which the current run started (s)
+        last_t (float): simulated time of the last report of the current run (ms)
+        init_handler (neuron FInitializeHandler): schedules the first report of
+            each run
+    """
+
+    def __init__(self, sim, interval, status_path=None):
+        """Constructor.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            interval (float): simulated time between the reports (ms)
+            status_path (str): path to the json status file. Not written if None.
+
+        Raises:
+            ValueError: if the interval is not positive
+        """
+        if interval <= 0:
+            raise ValueError("The interval of the progress reports should be positive.")
+        self.sim = sim
+        self.interval = interval
+        self.status_path = status_path
+        self.n_runs = 0
+        self.simulated_time = 0.0
+        self.start_time = time.perf_counter()
+        self.run_start_time = self.start_time
+        self.last_t = 0.0
+        self.init_handler = sim.neuron.h.FInitializeHandler(self.start_run)
+
+    def start_run(self):
+        """Start a run at the initialization of NEURON and schedule its first report."""
+        self.simulated_time += self.last_t
+        self.last_t = 0.0
+        self.n_runs += 1
+        self.run_start_time = time.perf_counter()
+        h = self.sim.neuron.h
+        h.cvode.event(h.t + self.interval, self.report)
+
+    def get_progress(self, t, tstop, now=None):
+        """Return the progress of the current run.
+
+        Args:
+            t (float): current simulated time (ms)
+            tstop (float): end of the current run (ms)
+            now (float): current wall time (s). The current time is used if None.
+
+        Returns:
+            dict: run number, simulated time and end of the run (ms), percent
+                of the run completed, simulated ms per wall second, estimated
+                remaining wall time of the run (s), wall time since the start (s)
+                and total simulated time up to this report (ms)
+        """
+        if now is None:
+            now = time.perf_counter()
+        run_wall_time = now - self.run_start_time
+        speed = t / run_wall_time if run_wall_time > 0 else None
+        eta = max(tstop - t, 0.0) / speed if speed else None
+        return {
+            "run": self.n_runs,
+            "t": t,
+            "tstop": tstop,
+            "percent": min(100.0 * t / tstop, 100.0) if tstop > 0 else 100.0,
+            "speed": speed,
+            "eta": eta,
+            "elapsed": now - self.start_time,
+            "simulated_time": self.simulated_time + t,
+        }
+
+    def report(self):
+        """Log the progress of the current run and schedule the next report."""
+        h = self.sim.neuron.h
+        self.last_t = h.t
+        progress = self.get_progress(h.t, h.tstop)
+        logger.info(
+            "Run %d: %.1f / %.1f ms (%.1f%%), %s simulated ms per s, ETA %s",
+            progress["run"],
+            progress["t"],
+            progress["tstop"],
+            progress["percent"],
+            "?" if progress["speed"] is None else f"{progress['speed']:.1f}",
+            "?" if progress["eta"] is None else f"{progress['eta']:.0f} s",
+        )
+        self.write_status("running", progress)
+        # a run can be continued after tstop, and the events left
+        # at the end of a run are cleared at the next initialization
+        h.cvode.event(h.t + self.interval, self.report)
+
+    def write_status(self, status, progress=None):
+        """Write the status of the run in the json status file, if any.
+
+        The file is replaced at once, so that it can be read at any time.
+
+        Args:
+            status (str): status of the run, e.g. 'running' or 'done'
+            progress (dict): progress of the current run, see get_progress
+        """
+        if self.status_path is None:
+            return
+        status_data = {
+            "status": status,
+            "updated": datetime.datetime.now().isoformat(timespec="seconds"),
+            "n_runs": self.n_runs,
+            "elapsed": time.perf_counter() - self.start_time,
+        }
+        if progress is not None:
+            status_data.update(progress)
+        status_path = Path(self.status_path)
+        status_path.parent.mkdir(parents=True, exist_ok=True)
+        tmp_path = status_path.with_name(f".{status_path.name}.tmp")
+        with open(tmp_path, "w", encoding="utf-8") as status_file:
+            json.dump(status_data, status_file, indent=4)
+        os.replace(tmp_path, status_path)
+
+    def finish(self, status="done"):
+        """Write the final status of the run, and log its total simulated time.
+
+        Args:
+            status (str): final status of the run, e.g. 'done' or 'failed'
+        """
+        self.simulated_time += self.last_t
+        self.last_t = 0.0
+        logger.info(
+            "%d runs, %.1f ms simulated in %.1f s",
+            self.n_runs,
+            self.simulated_time,
+            time.perf_counter() - self.start_time,
+        )
+        self.write_status(status, {"simulated_time": self.simulated_time})
+
+
+def set_progress_reporter(sim, config):
+    """Report the progress of the simulations of the simulator, if set in the config.
+
+    The reporter is kept in the progress_reporter attribute of the simulator.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        ProgressReporter: the progress reporter, or None if there is no report
+    """
+    interval = config.getfloat("Sim", "progress_interval", fallback=0.0)
+    if interval <= 0:
+        return None
+    if config.get("Sim", "simulator", fallback="neuron") == "coreneuron":
+        logger.warning("The progress cannot be reported during CoreNEURON runs.")
+        return None
+    status_path = config.get("Paths", "status_path", fallback="") or None
+    sim.progress_reporter = ProgressReporter(sim, interval, status_path)
+    return sim.progress_reporter
+
+
+def finish_progress_report(sim, status="done"):
+    """Write the final status of the runs of the simulator, if it has a reporter.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        status (str): final status of the run, e.g. 'done' or 'failed'
+    """
+    reporter = getattr(sim, "progress_reporter", None)
+    if reporter is not None:
+        reporter.finish(status)
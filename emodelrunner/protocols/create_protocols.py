@@ -20,23 +20,40 @@ import logging
 
 from bluepyopt import ephys
 
-from emodelrunner.create_recordings import get_pairsim_recordings
+from emodelrunner.create_recordings import (
+    get_pairsim_recordings,
+    get_soma_recording,
+    get_synapse_recordings,
+)
 from emodelrunner.create_stimuli import load_pulses
 from emodelrunner.configuration import PackageType
-from emodelrunner.protocols import synplas_protocols
+from emodelrunner.protocols import (
+    sscx_protocols,
+    synplas_protocols,
+    thalamus_protocols,
+)
 
-from emodelrunner.synapses.recordings import SynapseRecordingCustom
-from emodelrunner.stimuli import MultipleSteps
-from emodelrunner.features import define_efeatures
+from emodelrunner.stimuli import Chirp, MultipleSteps
+from emodelrunner.features import define_efeatures, load_stored_current
 from emodelrunner.protocols.reader import ProtocolParser
+from emodelrunner.protocols.protocols_func import NeuronGlobalsMixin
 from emodelrunner.synapses.create_locations import get_syn_locs
 from emodelrunner.synapses.stimuli import (
+    NrnNetStimStimulusCustom,
+    NrnSpikeGeneratorStimulus,
     NrnVecStimStimulusCustom,
     NetConSpikeDetector,
 )
 
 logger = logging.getLogger(__name__)
 
+# stimuli activating the synapses with seeded spike trains
+SEEDED_STIMULUS_TYPES = (
+    NrnNetStimStimulusCustom,
+    NrnSpikeGeneratorStimulus,
+    NrnVecStimStimulusCustom,
+)
+
 
 class ProtocolBuilder:
     """Class representing the protocols applied in SSCX.
@@ -85,6 +102,42 @@ class ProtocolBuilder:
             features_path=prot_args["features_path"],
             mtype=prot_args["mtype"],
             syn_locs=syn_locs,
+            extra_recordings=prot_args.get("extra_recordings"),
+            threshold_current_source=prot_args.get(
+                "threshold_current_source", "features"
+            ),
+            seed=prot_args.get("seed"),
+        )
+        return cls(protocols)
+
+    @classmethod
+    def using_hippocampus_protocols(cls, add_synapses, prot_args, cell=None):
+        """Creates the object with the hippocampus protocols.
+
+        The hippocampus protocols are defined like the sscx protocols.
+
+        Args:
+            add_synapses (bool): whether to add synapses to the cell
+            prot_args (dict): config data relative to protocols
+                See load.get_prot_args for details
+            cell (CellModelCustom): cell model
+        Returns:
+            ProtocolBuilder: the object with the hippocampus protocols
+        """
+        syn_locs = cls._get_syn_locs(add_synapses, cell)
+
+        protocols = create_protocols_object(
+            apical_point_isec=prot_args["apical_point_isec"],
+            prot_path=prot_args["prot_path"],
+            package_type=PackageType.hippocampus,
+            features_path=prot_args["features_path"],
+            mtype=prot_args["mtype"],
+            syn_locs=syn_locs,
+            extra_recordings=prot_args.get("extra_recordings"),
+            threshold_current_source=prot_args.get(
+                "threshold_current_source", "features"
+            ),
+            seed=prot_args.get("seed"),
         )
         return cls(protocols)
 
@@ -109,6 +162,8 @@ class ProtocolBuilder:
             features_path=prot_args["features_path"],
             mtype=prot_args["mtype"],
             syn_locs=syn_locs,
+            extra_recordings=prot_args.get("extra_recordings"),
+            seed=prot_args.get("seed"),
         )
         return cls(protocols)
 
@@ -120,6 +175,275 @@ class ProtocolBuilder:
         """
         return self.protocols
 
+    def get_stim_windows(self):
+        """Returns the stimulus window of each protocol having one.
+
+        Returns:
+            dict: (stim_start, stim_end) tuple (ms) for each protocol name
+        """
+        stim_windows = {}
+        for protocol in self.protocols.protocols:
+            for name, subprotocol in protocol.subprotocols().items():
+                if hasattr(subprotocol, "stim_start") and hasattr(
+                    subprotocol, "stim_end"
+                ):
+                    stim_windows[name] = (subprotocol.stim_start, subprotocol.stim_end)
+
+        return stim_windows
+
+    def get_step_amplitudes(self):
+        """Returns the step amplitude of each step protocol.
+
+        Should be called after the run, so that the amplitudes
+        of the threshold-based protocols are set.
+
+        Returns:
+            dict: step amplitude (nA) for each protocol name
+        """
+        step_amplitudes = {}
+        for protocol in self.protocols.protocols:
+            for name, subprotocol in protocol.subprotocols().items():
+                amplitude = getattr(subprotocol, "step_amplitude", None)
+                if amplitude is not None:
+                    step_amplitudes[name] = amplitude
+
+        return step_amplitudes
+
+    def get_noise_seeds(self):
+        """Returns the seed of each noise and spike generators protocol.
+
+        Returns:
+            dict: seed of the noise or of the spike trains for each protocol name,
+                and seed of each noisy conductance of the conductance clamp protocols.
+                The Netstim protocols without seed use the generator of NEURON.
+        """
+        noise_seeds = {}
+        for protocol in self.protocols.protocols:
+            for name, subprotocol in protocol.subprotocols().items():
+                if isinstance(subprotocol, sscx_protocols.NoiseProtocol):
+                    noise_seeds[name] = subprotocol.seed
+                elif isinstance(subprotocol, sscx_protocols.ConductanceClampProtocol):
+                    noise_seeds[name] = subprotocol.seeds
+                for stimulus in getattr(subprotocol, "stimuli", []):
+                    if isinstance(stimulus, SEEDED_STIMULUS_TYPES) and (
+                        stimulus.seed is not None
+                    ):
+                        noise_seeds[name] = stimulus.seed
+
+        return noise_seeds
+
+    def get_searched_currents(self):
+        """Returns the currents found by each current search protocol.
+
+        Should be called after the run.
+
+        Returns:
+            dict: holding voltage (mV), holding current (nA) and rheobase (nA)
+                for each current search protocol name
+        """
+        searched_currents = {}
+        for protocol in self.protocols.protocols:
+            for name, subprotocol in protocol.subprotocols().items():
+                if isinstance(subprotocol, sscx_protocols.CurrentSearchProtocol):
+                    searched_currents[name] = {
+                        "holding_voltage": subprotocol.holding_voltage,
+                        "holding_current": subprotocol.holding_current,
+                        "threshold_current": subprotocol.threshold_current,
+                    }
+
+        return searched_currents
+
+    def _get_step_series(self, protocol_cls):
+        """Returns the steps of each step series protocol of the given class.
+
+        Args:
+            protocol_cls (class): class of the step series protocols
+
+        Returns:
+            dict: step amplitude (nA) of each step protocol name
+                and spike threshold (mV) for each step series protocol name
+        """
+        step_series = {}
+        for protocol in self.protocols.protocols:
+            for name, subprotocol in protocol.subprotocols().items():
+                if isinstance(subprotocol, protocol_cls):
+                    step_series[name] = {
+                        "step_amplitudes": {
+                            step_protocol.name: step_protocol.step_amplitude
+                            for step_protocol in subprotocol.step_protocols
+                        },
+                        "spike_threshold": subprotocol.spike_threshold,
+                    }
+
+        return step_series
+
+    def get_fi_curve_protocols(self):
+        """Returns the steps of each f-I curve protocol.
+
+        Should be called after the run, so that the amplitudes
+        of the steps relative to the threshold current are set.
+
+        Returns:
+            dict: step amplitude (nA) of each step protocol name
+                and spike threshold (mV) for each f-I curve protocol name
+        """
+        return self._get_step_series(sscx_protocols.FICurveProtocol)
+
+    def get_subthreshold_protocols(self):
+        """Returns the steps of each subthreshold protocol.
+
+        Should be called after the run, so that the amplitudes
+        of the steps relative to the threshold current are set.
+
+        Returns:
+            dict: step amplitude (nA) of each step protocol name
+                and spike threshold (mV) for each subthreshold protocol name
+        """
+        return self._get_step_series(sscx_protocols.SubthresholdProtocol)
+
+    def get_voltage_clamp_protocols(self):
+        """Returns what the voltage clamp analysis needs to know of each protocol.
+
+        Returns:
+            dict: type of curve ('activation' or 'inactivation'), reversal
+                potential (mV), (start, end) of the window in which the peak current
+                is measured (ms) and, for each sweep, key of the clamp current,
+                command voltage of the swept step and voltage of the measured step
+                (mV), for each analysed voltage clamp protocol name
+        """
+        vclamp_protocols = {}
+        for protocol in self.protocols.protocols:
+            for name, subprotocol in protocol.subprotocols().items():
+                if (
+                    isinstance(subprotocol, sscx_protocols.VoltageClampProtocol)
+                    and subprotocol.analysis is not None
+                ):
+                    analysis = subprotocol.analysis
+                    step = analysis["step"]
+                    stimulus = subprotocol.sweep_protocols[0].vclamp_stimulus
+                    step_start = stimulus.step_starts[step]
+                    vclamp_protocols[name] = {
+                        "type": analysis["type"],
+                        "reversal": analysis["reversal"],
+                        "window": (
+                            step_start + analysis["transient"],
+                            step_start + stimulus.durations[step],
+                        ),
+                        "sweeps": [
+                            {
+                                "current_key": sweep.recordings[-1].name,
+                                "command_voltage": voltage,
+                                "step_voltage": sweep.vclamp_stimulus.voltages[step],
+                            }
+                            for sweep, voltage in zip(
+                                subprotocol.sweep_protocols,
+                                subprotocol.command_voltages,
+                            )
+                        ],
+                    }
+
+        return vclamp_protocols
+
+    def get_burst_protocols(self):
+        """Returns what the burst analysis needs to know of each burst protocol.
+
+        Returns:
+            dict: key of the soma voltage, type of burst ('low_threshold'
+                or 'rebound'), start and end (release) of the hyperpolarizing
+                pre-step and end of the burst window (ms) for each burst protocol name
+        """
+        burst_protocols = {}
+        for protocol in self.protocols.protocols:
+            for name, subprotocol in protocol.subprotocols().items():
+                if isinstance(subprotocol, thalamus_protocols.BurstProtocol):
+                    burst_protocols[name] = {
+                        "voltage_key": subprotocol.recordings[0].name,
+                        "type": subprotocol.burst_type,
+                        "hyperpolarization_start": subprotocol.stim_start,
+                        "release_time": subprotocol.release_time,
+                        "window_end": subprotocol.stim_end,
+                    }
+
+        return burst_protocols
+
+    def get_chirp_protocols(self):
+        """Returns what the ZAP analysis needs to know of each chirp protocol.
+
+        Returns:
+            dict: keys of the soma voltage and of the injected current,
+                (start, end) of the chirp (ms) and (start, end) of its frequency
+                sweep (Hz) for each chirp protocol name
+        """
+        chirp_protocols = {}
+        for protocol in self.protocols.protocols:
+            for name, subprotocol in protocol.subprotocols().items():
+                stimulus = getattr(subprotocol, "sampled_stimulus", None)
+                if isinstance(stimulus, Chirp):
+                    chirp_protocols[name] = {
+                        "voltage_key": subprotocol.recordings[0].name,
+                        "current_key": subprotocol.curr_output_key(),
+                        "stim_window": (subprotocol.stim_start, subprotocol.stim_end),
+                        "freq_band": (stimulus.freq_start, stimulus.freq_end),
+                    }
+
+        return chirp_protocols
+
+    def get_attenuation_protocols(self):
+        """Returns what the attenuation analysis needs to know of each protocol.
+
+        Returns:
+            dict: type ('bAP' or 'EPSP'), stimulus start (ms) and keys
+                of the voltage recordings for each bAP and EPSP attenuation
+                protocol name
+        """
+        attenuation_protocols = {}
+        for protocol in self.protocols.protocols:
+            for name, subprotocol in protocol.subprotocols().items():
+                if isinstance(subprotocol, sscx_protocols.BAPProtocol):
+                    attenuation_protocols[name] = {
+                        "type": "bAP",
+                        "stim_start": subprotocol.stim_start,
+                        "soma_key": subprotocol.recordings[0].name,
+                        "dendrite_keys": subprotocol.recording_distances,
+                    }
+                elif isinstance(subprotocol, sscx_protocols.EPSPAttenuationProtocol):
+                    attenuation_protocols[name] = {
+                        "type": "EPSP",
+                        "stim_start": subprotocol.site_protocols[0].stim_start,
+                        "sites": [
+                            {
+                                "distance": distance,
+                                "soma_key": site_protocol.recordings[0].name,
+                                "local_key": site_protocol.recordings[1].name,
+                            }
+                            for distance, site_protocol in zip(
+                                subprotocol.distances, subprotocol.site_protocols
+                            )
+                        ],
+                    }
+
+        return attenuation_protocols
+
+    def get_neuron_globals(self, celsius, v_init):
+        """Returns the temperature and the initial voltage used by each protocol.
+
+        Args:
+            celsius (float): temperature of the cell (celsius)
+            v_init (float): initial voltage of the cell (mV)
+
+        Returns:
+            dict: celsius and v_init for each protocol name,
+                the ones of the cell if the protocol has not its own
+        """
+        neuron_globals = {}
+        for protocol in self.protocols.protocols:
+            for name, subprotocol in protocol.subprotocols().items():
+                neuron_globals[name] = {"celsius": celsius, "v_init": v_init}
+                if isinstance(subprotocol, NeuronGlobalsMixin):
+                    neuron_globals[name].update(subprotocol.get_neuron_globals())
+
+        return neuron_globals
+
     def get_stim_currents(self, responses, dt):
         """Generates the currents injected by protocols.
 
@@ -191,6 +515,9 @@ def create_protocols_object(
     mtype="",
     syn_locs=None,
     stochkv_det=None,
+    extra_recordings=None,
+    threshold_current_source="features",
+    seed=None,
 ):
     """Return a dict containing protocols.
 
@@ -203,6 +530,12 @@ def create_protocols_object(
         mtype (str): morphology name to be used as prefix in output filenames
         syn_locs (list): list of synapse locations
         stochkv_det (bool): set if stochastic or deterministic
+        extra_recordings (list): extra recording definitions added to every protocol
+        threshold_current_source (str): source of the threshold current of the steps
+            relative to the threshold. Can be "features", to use the one stored
+            in the features file, or "search", to use the one of a current search
+        seed (int): global seed from which the seeds of the random stimuli are derived.
+            The seeds of the protocols file are used if None.
 
     Raises:
         ValueError: if the package type is not supported
@@ -211,19 +544,28 @@ def create_protocols_object(
         ephys.protocols.SequenceProtocol: sequence protocol containing all the protocols
     """
     # pylint: disable=unbalanced-tuple-unpacking, too-many-locals
-    if package_type == PackageType.sscx:
+    if package_type in [PackageType.sscx, PackageType.hippocampus]:
+        threshold_current = None
+        if threshold_current_source == "features" and features_path:
+            threshold_current = load_stored_current(features_path)
+
         protocols_dict = ProtocolParser().parse_sscx_protocols(
             prot_path,
             stochkv_det,
             mtype,
             apical_point_isec,
             syn_locs,
+            extra_recordings,
+            threshold_current,
+            seed=seed,
         )
     elif package_type == PackageType.thalamus:
         protocols_dict = ProtocolParser().parse_thalamus_protocols(
             prot_path,
             stochkv_det,
             mtype,
+            extra_recordings,
+            seed=seed,
         )
     else:
         raise ValueError(f"unsupported package type: {package_type}")
@@ -235,7 +577,7 @@ def create_protocols_object(
             mtype,
         )
 
-        if package_type == PackageType.sscx:
+        if package_type in [PackageType.sscx, PackageType.hippocampus]:
             set_sscx_main_protocol_efeatures(protocols_dict, efeatures, prefix=mtype)
         elif package_type == PackageType.thalamus:
             set_thalamus_main_protocol_efeatures(
@@ -244,7 +586,13 @@ def create_protocols_object(
 
         protocols = [protocols_dict["Main"]]
     else:
-        protocols = list(protocols_dict.values())
+        # the current searches are run first, for the threshold-based protocols
+        protocols = sorted(
+            protocols_dict.values(),
+            key=lambda protocol: not isinstance(
+                protocol, sscx_protocols.CurrentSearchProtocol
+            ),
+        )
 
     return ephys.protocols.SequenceProtocol(
         "all protocols",
@@ -335,6 +683,8 @@ def define_synapse_plasticity_protocols(
     tstop,
     fastforward,
     stim_path="protocols/stimuli.json",
+    checkpoint_args=None,
+    sampling=None,
 ):
     """Create stimuli and protocols to run glusynapse cell.
 
@@ -347,11 +697,17 @@ def define_synapse_plasticity_protocols(
         tstop (float): total duration of the simulation (ms)
         fastforward (float): time at which to enable synapse fast-forwarding (ms)
         stim_path (str): path to the pulse stimuli file
+        checkpoint_args (dict): checkpoint configuration data.
+            See load.get_checkpoint_args for details.
+            Leave None for no checkpoint.
+        sampling (dict): sampling of the recordings.
+            See load.get_recording_sampling_args for details.
+            The variables are recorded at every step if None.
 
     Returns:
         synplas_protocols.SweepProtocolCustom: synapse plasticity protocols
     """
-    # pylint: disable=too-many-locals
+    # pylint: disable=too-many-arguments, too-many-locals
     syn_locs = get_syn_locs(cell)
     syn_stim = NrnVecStimStimulusCustom(
         syn_locs,
@@ -364,16 +720,8 @@ def define_synapse_plasticity_protocols(
         name="soma", seclist_name="somatic", sec_index=0, comp_x=0.5
     )
     # recording
-    rec = ephys.recordings.CompRecording(
-        name=protocol_name, location=soma_loc, variable="v"
-    )
-    recs = [rec]
-
-    for syn_loc in syn_locs:
-        for synrec in synrecs:
-            recs.append(
-                SynapseRecordingCustom(name=synrec, location=syn_loc, variable=synrec)
-            )
+    recs = [get_soma_recording(protocol_name, soma_loc, sampling)]
+    recs += get_synapse_recordings(syn_locs, synrecs, sampling)
 
     # pulses
     stims = load_pulses(soma_loc, stim_path)
@@ -382,7 +730,7 @@ def define_synapse_plasticity_protocols(
 
     # create protocol
     return synplas_protocols.SweepProtocolCustom(
-        protocol_name, stims, recs, cvode_active, fastforward
+        protocol_name, stims, recs, cvode_active, fastforward, checkpoint_args
     )
 
 
@@ -396,6 +744,7 @@ def define_pairsim_protocols(
     fastforward,
     presyn_stim_args,
     stim_path="protocols/stimuli.json",
+    sampling=None,
 ):
     """Create stimuli and protocols to run glusynapse cell.
 
@@ -412,6 +761,9 @@ def define_pairsim_protocols(
         presyn_stim_args (dict): presynaptic stimulus configuration data
             See load.get_presyn_stim_args for details
         stim_path (str): path to the pulse stimuli file
+        sampling (dict): sampling of the recordings.
+            See load.get_recording_sampling_args for details.
+            The variables are recorded at every step if None.
 
     Returns:
         synplas_protocols.SweepProtocolPairSim: pair simulation synapse plasticity protocols
@@ -426,7 +778,7 @@ def define_pairsim_protocols(
     # recordings
     # has the structure (precell_recs, postcell_recs)
     recs = get_pairsim_recordings(
-        soma_loc, syn_locs, synrecs, presyn_prot_name, postsyn_prot_name
+        soma_loc, syn_locs, synrecs, presyn_prot_name, postsyn_prot_name, sampling
     )
 
     # stimuli
@@ -15,11 +15,19 @@
 # limitations under the License.
 
 import logging
+import re
 
 from bluepyopt import ephys
 
-from emodelrunner.recordings import RecordingCustom
+from emodelrunner.recordings import (
+    CURRENTS_VARIABLE,
+    CurrentsRecording,
+    RecordingCustom,
+    get_variable_name,
+)
 from emodelrunner.locations import SOMA_LOC
+from emodelrunner.locations import NrnSectionCompLocation
+from emodelrunner.locations import get_section_location_name
 
 
 logger = logging.getLogger(__name__)
@@ -31,6 +39,11 @@ seclist_to_sec = {
     "myelinated": "myelin",
 }
 
+# e.g. "150%thresh" or "-20 % threshold"
+RELATIVE_AMPLITUDE_PATTERN = re.compile(
+    r"^\s*([-+]?(?:\d+\.?\d*|\.\d+))\s*%\s*thresh(?:old)?\s*$"
+)
+
 
 class CurrentOutputKeyMixin:
     """Contains methods useful for multiple Protocol classes."""
@@ -49,6 +62,118 @@ class CurrentOutputKeyMixin:
         return "current_" + name
 
 
+class NeuronGlobalsMixin:
+    """Sets the temperature and the initial voltage of a protocol, if it has its own.
+
+    The values of the cell are set back when the protocol is destroyed.
+    Should come before the bluepyopt protocol in the base classes.
+
+    Attributes:
+        celsius (float): temperature of the protocol (celsius),
+            the one of the cell if None
+        v_init (float): initial voltage of the protocol (mV),
+            the one of the cell if None
+    """
+
+    celsius = None
+    v_init = None
+    # values of the cell, set back when the protocol is destroyed
+    _cell_globals = {}
+
+    def get_neuron_globals(self):
+        """Return the NEURON globals set by the protocol.
+
+        Returns:
+            dict: value of 'celsius' and 'v_init' if set by the protocol
+        """
+        return {
+            name: getattr(self, name)
+            for name in ["celsius", "v_init"]
+            if getattr(self, name) is not None
+        }
+
+    def set_neuron_globals(self, sim):
+        """Set the NEURON globals of the protocol, keeping the ones of the cell.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        """
+        neuron_globals = self.get_neuron_globals()
+        self._cell_globals = {
+            name: getattr(sim.neuron.h, name) for name in neuron_globals
+        }
+        for name, value in neuron_globals.items():
+            setattr(sim.neuron.h, name, value)
+
+    def reset_neuron_globals(self, sim):
+        """Set back the NEURON globals of the cell.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        """
+        for name, value in self._cell_globals.items():
+            setattr(sim.neuron.h, name, value)
+        self._cell_globals = {}
+
+    def instantiate(self, sim=None, icell=None):
+        """Instantiate the protocol, then set its NEURON globals.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            icell (neuron cell): cell instantiation in simulator
+        """
+        super().instantiate(sim=sim, icell=icell)
+        self.set_neuron_globals(sim)
+
+    def destroy(self, sim=None):
+        """Destroy the protocol, and set back the NEURON globals of the cell.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        """
+        super().destroy(sim=sim)
+        self.reset_neuron_globals(sim)
+
+
+def set_protocol_globals(protocol, protocol_definition):
+    """Set the temperature and the initial voltage of a protocol and its subprotocols.
+
+    Args:
+        protocol (bluepyopt.ephys.protocols.Protocol): the protocol
+        protocol_definition (dict): contains the protocol configuration data,
+            with the optional 'celsius' and 'v_init' of the protocol
+
+    Raises:
+        ValueError: if the protocol type cannot have its own temperature
+            or initial voltage
+    """
+    neuron_globals = {
+        name: float(protocol_definition[name])
+        for name in ["celsius", "v_init"]
+        if name in protocol_definition
+    }
+    if not neuron_globals:
+        return
+
+    subprotocols = list(protocol.subprotocols().values())
+    # the steps of e.g. the current search are copied from their template
+    if getattr(protocol, "step_protocol_template", None) is not None:
+        subprotocols.append(protocol.step_protocol_template)
+    subprotocols = [
+        subprotocol
+        for subprotocol in subprotocols
+        if isinstance(subprotocol, NeuronGlobalsMixin)
+    ]
+    if not subprotocols:
+        raise ValueError(
+            f"{protocol.name}: celsius and v_init cannot be set "
+            f"for the protocols of type {type(protocol).__name__}"
+        )
+    for subprotocol in subprotocols:
+        for name, value in neuron_globals.items():
+            setattr(subprotocol, name, value)
+
+
 def get_extra_recording_location(recording_definition, apical_point_isec=-1):
     """Get the location for the extra recording.
 
@@ -61,7 +186,8 @@ def get_extra_recording_location(recording_definition, apical_point_isec=-1):
         Exception: if the recording definition "type" is "somadistanceapic" and
             apical_point_isec is -1.
         Exception: if the 'type' in the recording definition is neither
-            "somadistance", nor "somadistanceapic", nor "nrnseclistcomp"
+            "somadistance", nor "somadistanceapic", nor "nrnseclistcomp",
+            nor "section"
 
     Returns:
         location of the extra recording
@@ -94,13 +220,129 @@ def get_extra_recording_location(recording_definition, apical_point_isec=-1):
             seclist_name=recording_definition["seclist_name"],
         )
 
+    elif recording_definition["type"] == "section":
+        sec_name = recording_definition["sec_name"]
+        sec_index = recording_definition["sec_index"]
+        comp_x = recording_definition["comp_x"]
+        location = NrnSectionCompLocation(
+            name=recording_definition.get(
+                "name", get_section_location_name(sec_name, sec_index, comp_x)
+            ),
+            sec_name=sec_name,
+            sec_index=sec_index,
+            comp_x=comp_x,
+        )
+
     else:
         raise Exception(f"Recording type {recording_definition['type']} not supported")
 
     return location
 
 
-def get_recordings(protocol_name, protocol_definition, prefix, apical_point_isec=-1):
+def get_recording_sampling(definition, default_sampling=None):
+    """Get the sampling of a recording from its definition.
+
+    Args:
+        definition (dict): recording or protocol definition, with optionally
+            the interval between the recorded samples ("record_dt", in ms)
+            or the number of integration steps between them ("record_every")
+        default_sampling (dict): sampling used if the definition has none
+
+    Returns:
+        dict: record_dt and record_every keyword arguments of the recording
+    """
+    sampling = {
+        key: definition[key]
+        for key in ["record_dt", "record_every"]
+        if key in definition
+    }
+    return sampling or dict(default_sampling or {})
+
+
+def get_extra_recordings(
+    protocol_name,
+    recording_definitions,
+    prefix,
+    apical_point_isec=-1,
+    default_sampling=None,
+):
+    """Get the extra recordings from their definitions.
+
+    Args:
+        protocol_name (str): name of the protocol
+        recording_definitions (list): extra recording definitions
+        prefix (str): prefix used in naming responses, features, recordings, etc.
+        apical_point_isec (int): apical point section index
+            Should be given if there is "somadistanceapic" in "type"
+            of at least one of the extra recording definition
+        default_sampling (dict): sampling of the recordings whose definition
+            has neither "record_dt" nor "record_every"
+
+    Returns:
+        list of RecordingCustom, with a CurrentsRecording for the "currents" variable
+    """
+    recordings = []
+    for recording_definition in recording_definitions:
+
+        location = get_extra_recording_location(recording_definition, apical_point_isec)
+
+        # mechanism variables, e.g. NaTg.m, are named as in NEURON, e.g. m_NaTg
+        var = get_variable_name(recording_definition["var"])
+        sampling = get_recording_sampling(recording_definition, default_sampling)
+        if var == CURRENTS_VARIABLE:
+            recording = CurrentsRecording(
+                name=f"{prefix}.{protocol_name}.{location.name}.{var}",
+                location=location,
+                **sampling,
+            )
+        else:
+            recording = RecordingCustom(
+                name=f"{prefix}.{protocol_name}.{location.name}.{var}",
+                location=location,
+                variable=var,
+                **sampling,
+            )
+        recordings.append(recording)
+
+    return recordings
+
+
+def get_distance_location(distance, seclist_name="apical", apical_point_isec=-1):
+    """Get the location at a distance from the soma in a section list.
+
+    On the apical dendrite, the location is taken on the path from the soma
+    to the apical point if its section index is known, i.e. on the apical trunk.
+
+    Args:
+        distance (float): distance from the soma (um)
+        seclist_name (str): name of the section list, e.g. "apical" or "basal"
+        apical_point_isec (int): apical point section index, -1 if unknown
+
+    Returns:
+        location at the distance, named e.g. "apical100um"
+    """
+    name = f"{seclist_name}{distance:g}um".replace(".", "p")
+    if seclist_name == "apical" and apical_point_isec != -1:
+        return ephys.locations.NrnSecSomaDistanceCompLocation(
+            name=name,
+            soma_distance=distance,
+            sec_name=seclist_to_sec[seclist_name],
+            sec_index=apical_point_isec,
+        )
+    return ephys.locations.NrnSomaDistanceCompLocation(
+        name=name,
+        soma_distance=distance,
+        seclist_name=seclist_name,
+    )
+
+
+def get_recordings(
+    protocol_name,
+    protocol_definition,
+    prefix,
+    apical_point_isec=-1,
+    extra_recordings=None,
+):
     """Get recordings from protocol definition.
 
     Args:
@@ -110,35 +352,60 @@ def get_recordings(protocol_name, protocol_definition, prefix, apical_point_isec
         apical_point_isec (int): apical point section index
             Should be given if there is "somadistanceapic" in "type"
             of at least one of the extra recording definition
+        extra_recordings (list): extra recording definitions added to the ones
+            of the protocol definition, e.g. the ones of the configuration file
 
     Returns:
         list of RecordingCustom
     """
+    # the sampling of the protocol is the one of its soma recording,
+    # and the default one of its extra recordings
+    sampling = get_recording_sampling(protocol_definition)
     recordings = []
     recordings.append(
         RecordingCustom(
             name=f"{prefix}.{protocol_name}.soma.v",
             location=SOMA_LOC,
             variable="v",
+            **sampling,
         )
     )
 
-    if "extra_recordings" in protocol_definition:
-        for recording_definition in protocol_definition["extra_recordings"]:
+    recording_definitions = protocol_definition.get("extra_recordings", []) + (
+        extra_recordings or []
+    )
+    recordings += get_extra_recordings(
+        protocol_name, recording_definitions, prefix, apical_point_isec, sampling
+    )
 
-            location = get_extra_recording_location(
-                recording_definition, apical_point_isec
-            )
+    return recordings
 
-            var = recording_definition["var"]
-            recording = RecordingCustom(
-                name=f"{prefix}.{protocol_name}.{location.name}.{var}",
-                location=location,
-                variable=var,
-            )
-            recordings.append(recording)
 
-    return recordings
+def parse_relative_amplitude(amp):
+    """Return the percentage of the threshold current of a relative amplitude.
+
+    Args:
+        amp (float or str): amplitude, either absolute (nA)
+            or relative to the threshold current, e.g. "150%thresh"
+
+    Raises:
+        ValueError: if amp is a string not matching a relative amplitude
+
+    Returns:
+        float: the percentage of the threshold current,
+            or None if the amplitude is absolute
+    """
+    if not isinstance(amp, str):
+        return None
+
+    match = RELATIVE_AMPLITUDE_PATTERN.match(amp)
+    if match is None:
+        raise ValueError(
+            f"Could not parse amplitude {amp}. "
+            "Expected a number or a percentage of threshold, e.g. '150%thresh'."
+        )
+
+    return float(match.group(1))
 
 
 def check_for_forbidden_protocol(protocols_dict):
@@ -158,6 +425,14 @@ def check_for_forbidden_protocol(protocols_dict):
         "StepThresholdProtocol",
         "RampThresholdProtocol",
     ]
+    # the threshold-based protocols can also use the currents of a search protocol
+    has_search_protocol = any(
+        type(prot).__name__ == "CurrentSearchProtocol"
+        for prot in protocols_dict.values()
+    )
+    if has_search_protocol:
+        forbidden_prots = forbidden_prots[:2]
+
     # check the class name of each protocol
     for prot in protocols_dict.values():
         if type(prot).__name__ in forbidden_prots:
@@ -166,3 +441,101 @@ def check_for_forbidden_protocol(protocols_dict):
                 "No MainProtocol found, but {prot} was found."
                 f"To use {prot_name}, please set MainProtocol."
             )
+        # relative steps without stored threshold current need the searched one,
+        # including the steps of the f-I curve protocols
+        for step_prot in getattr(prot, "step_protocols", [prot]):
+            if (
+                type(step_prot).__name__ == "RelativeStepProtocol"
+                and step_prot.threshold_current is None
+                and not has_search_protocol
+            ):
+                raise Exception(
+                    f"No threshold current is stored for {step_prot.name}. "
+                    "Please add a CurrentSearchProtocol or set MainProtocol."
+                )
+
+
+def get_state_names(sim, mechanism_name):
+    """Return the names of the STATE variables of a mechanism.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        mechanism_name (str): name of the mechanism, e.g. 'NaTg' or 'ProbAMPANMDA_EMS'
+
+    Returns:
+        list of str: names of the STATE variables, suffixed with the mechanism name
+            for the density mechanisms, e.g. 'm_NaTg'
+    """
+    standard = sim.neuron.h.MechanismStandard(mechanism_name, 3)
+    name = sim.neuron.h.ref("")
+    names = []
+    for i in range(int(standard.count())):
+        standard.name(name, i)
+        names.append(name[0])
+    return names
+
+
+def get_cell_state(sim, cell_model):
+    """Return the state of an instantiated cell, to carry it over to another run.
+
+    The state is made of the voltage and the STATE variables of the mechanisms
+    of each segment, and of the STATE variables of the synapses,
+    e.g. the plasticity variables of GluSynapse.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        cell_model (bluepyopt.ephys.models.CellModel): the instantiated cell model
+
+    Returns:
+        list of tuples: each segment and synapse with its values, keyed by variable
+    """
+    # the names of the STATE variables of each mechanism
+    state_names = {}
+    state = []
+    for section in cell_model.icell.all:
+        for segment in section:
+            values = {"v": segment.v}
+            for mechanism in segment:
+                if mechanism.name() not in state_names:
+                    state_names[mechanism.name()] = get_state_names(
+                        sim, mechanism.name()
+                    )
+                for name in state_names[mechanism.name()]:
+                    values[name] = getattr(segment, name)
+            state.append((segment, values))
+
+    for mechanism in cell_model.mechanisms:
+        for synapse in getattr(mechanism, "pprocesses", None) or []:
+            hsynapse = synapse.hsynapse
+            mechanism_name = hsynapse.hname().split("[", maxsplit=1)[0]
+            if mechanism_name not in state_names:
+                state_names[mechanism_name] = get_state_names(sim, mechanism_name)
+            state.append(
+                (
+                    hsynapse,
+                    {
+                        name: getattr(hsynapse, name)
+                        for name in state_names[mechanism_name]
+                    },
+                )
+            )
+
+    return state
+
+
+def set_cell_state(sim, state):
+    """Set back the state of a cell, once the simulation is initialized.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        state (list of tuples): state of the cell, as returned by get_cell_state
+    """
+    for obj, values in state:
+        for name, value in values.items():
+            setattr(obj, name, value)
+
+    h = sim.neuron.h
+    if h.cvode.active():
+        h.cvode.re_init()
+    else:
+        h.fcurrent()
@@ -16,22 +16,46 @@
 
 import logging
 import json
+from pathlib import Path
+import numpy as np
 from bluepyopt import ephys
 
 from emodelrunner.protocols import sscx_protocols, thalamus_protocols
 from emodelrunner.locations import SOMA_LOC
+from emodelrunner.recordings import ClampCurrentRecording, RecordingCustom
+from emodelrunner.seeds import seed_protocol_definitions
+from emodelrunner.synapses.spike_trains import load_spike_trains
+from emodelrunner.stimuli import (
+    Chirp,
+    ConductanceClamp,
+    DoubleExponential,
+    OrnsteinUhlenbeck,
+    SampledTrace,
+    Sinusoid,
+    VoltageClamp,
+    Waveform,
+)
+from emodelrunner.synapses.spike_generators import check_generator_definition
 from emodelrunner.synapses.stimuli import (
     NrnNetStimStimulusCustom,
+    NrnSpikeGeneratorStimulus,
     NrnVecStimStimulusCustom,
 )
 from emodelrunner.protocols.protocols_func import (
     check_for_forbidden_protocol,
+    get_distance_location,
+    get_extra_recordings,
+    get_recording_sampling,
     get_recordings,
+    parse_relative_amplitude,
+    set_protocol_globals,
 )
 
 
 logger = logging.getLogger(__name__)
 
+VOLTAGE_CLAMP_ANALYSES = ["activation", "inactivation"]
+
 
 class ProtocolParser:
     """Parses the protocol json file."""
@@ -69,6 +93,7 @@ class ProtocolParser:
         protocol_module,
         recordings,
         stochkv_det,
+        threshold_current=None,
     ):
         """Parses the step and ramp protocols into self.protocols_dict."""
         if protocol_definition["type"] == "StepProtocol":
@@ -78,6 +103,7 @@ class ProtocolParser:
                 protocol_definition,
                 recordings,
                 stochkv_det,
+                threshold_current,
             )
         elif protocol_definition["type"] == "StepThresholdProtocol":
             self.protocols_dict[protocol_name] = read_step_threshold_protocol(
@@ -98,6 +124,22 @@ class ProtocolParser:
                 self.protocols_dict[protocol_name] = read_ramp_protocol(
                     protocol_name, protocol_definition, recordings
                 )
+            elif protocol_definition["type"] in ["SinusoidProtocol", "ChirpProtocol"]:
+                self.protocols_dict[protocol_name] = read_sinusoid_protocol(
+                    protocol_name, protocol_definition, recordings
+                )
+            elif protocol_definition["type"] == "NoiseProtocol":
+                self.protocols_dict[protocol_name] = read_noise_protocol(
+                    protocol_name, protocol_definition, recordings
+                )
+            elif protocol_definition["type"] == "WaveformProtocol":
+                self.protocols_dict[protocol_name] = read_waveform_protocol(
+                    protocol_name, protocol_definition, recordings
+                )
+            elif protocol_definition["type"] == "ConductanceClampProtocol":
+                self.protocols_dict[protocol_name] = read_conductance_clamp_protocol(
+                    protocol_name, protocol_definition, recordings
+                )
 
     def _parse_sscx_threshold_detection(self, protocol_definition, recordings, prefix):
         """Parses the sscx threshold detection protocol into self.protocols_dict."""
@@ -115,6 +157,75 @@ class ProtocolParser:
                 prefix=prefix,
             )
 
+    def _parse_current_search(
+        self, protocol_definition, protocol_name, recordings, stochkv_det, prefix
+    ):
+        """Parses the current search protocol into self.protocols_dict."""
+        # pylint: disable=too-many-arguments
+        if protocol_definition["type"] == "CurrentSearchProtocol":
+            self.protocols_dict[protocol_name] = read_current_search_protocol(
+                protocol_name, protocol_definition, recordings, stochkv_det, prefix
+            )
+
+    def _parse_step_series(
+        self,
+        protocol_definition,
+        protocol_name,
+        prefix,
+        apical_point_isec,
+        extra_recordings,
+        stochkv_det,
+        threshold_current,
+    ):
+        """Parses the f-I curve, subthreshold and voltage clamp protocols."""
+        if protocol_definition["type"] in ["FICurveProtocol", "SubthresholdProtocol"]:
+            self.protocols_dict[protocol_name] = read_step_series_protocol(
+                protocol_name,
+                protocol_definition,
+                prefix,
+                apical_point_isec,
+                extra_recordings,
+                stochkv_det,
+                threshold_current,
+            )
+        elif protocol_definition["type"] == "VoltageClampProtocol":
+            self.protocols_dict[protocol_name] = read_voltage_clamp_protocol(
+                protocol_name,
+                protocol_definition,
+                prefix,
+                apical_point_isec,
+                extra_recordings,
+            )
+
+    def _parse_attenuation(
+        self,
+        protocol_definition,
+        protocol_name,
+        prefix,
+        apical_point_isec,
+        extra_recordings,
+        stochkv_det,
+    ):
+        """Parses the bAP and EPSP attenuation protocols into self.protocols_dict."""
+        # pylint: disable=too-many-arguments
+        if protocol_definition["type"] == "BAPProtocol":
+            self.protocols_dict[protocol_name] = read_bap_protocol(
+                protocol_name,
+                protocol_definition,
+                prefix,
+                apical_point_isec,
+                extra_recordings,
+                stochkv_det,
+            )
+        elif protocol_definition["type"] == "EPSPAttenuationProtocol":
+            self.protocols_dict[protocol_name] = read_epsp_attenuation_protocol(
+                protocol_name,
+                protocol_definition,
+                prefix,
+                apical_point_isec,
+                extra_recordings,
+            )
+
     def _parse_thalamus_threshold_detection(
         self, protocol_definition, protocol_name, recordings, prefix
     ):
@@ -159,6 +270,35 @@ class ProtocolParser:
             self.protocols_dict[protocol_name] = read_netstim_protocol(
                 protocol_name, protocol_definition, recordings, syn_locs
             )
+        elif protocol_definition["type"] == "SpikeGenerators":
+            self.protocols_dict[protocol_name] = read_spike_generators_protocol(
+                protocol_name, protocol_definition, recordings, syn_locs
+            )
+
+    def _parse_chain(
+        self,
+        protocol_definition,
+        protocol_name,
+        stochkv_det,
+        prefix,
+        apical_point_isec,
+        syn_locs,
+        extra_recordings,
+        threshold_current,
+    ):
+        """Parses the protocols chained on the same cell into self.protocols_dict."""
+        # pylint: disable=too-many-arguments
+        if protocol_definition["type"] == "ChainProtocol":
+            self.protocols_dict[protocol_name] = read_chain_protocol(
+                protocol_name,
+                protocol_definition,
+                stochkv_det,
+                prefix,
+                apical_point_isec,
+                syn_locs,
+                extra_recordings,
+                threshold_current,
+            )
 
     def _parse_sscx_main(self, protocol_definitions, prefix):
         """Parses the main sscx protocol into self.protocols_dict."""
@@ -263,6 +403,9 @@ class ProtocolParser:
         prefix="",
         apical_point_isec=-1,
         syn_locs=None,
+        extra_recordings=None,
+        threshold_current=None,
+        seed=None,
     ):
         """Parses the SSCX protocols from the json file input.
 
@@ -275,16 +418,66 @@ class ProtocolParser:
                 of at least one of the extra recordings
             syn_locs (list of ephys.locations.NrnPointProcessLocation):
                 locations of the synapses (if any, else None)
+            extra_recordings (list): extra recording definitions added to
+                every protocol, e.g. the ones of the configuration file
+            threshold_current (float): stored threshold current (nA) used by the
+                steps relative to the threshold, e.g. the one of the features file
+            seed (int): global seed from which the seeds of the random stimuli
+                are derived. The seeds of the protocols file are used if None.
 
         Returns:
             dict containing the protocols
         """
-        protocol_definitions = self.load_protocol_json(protocols_filepath)
+        protocol_definitions = seed_protocol_definitions(
+            self.load_protocol_json(protocols_filepath), seed
+        )
+        return self.parse_sscx_protocol_definitions(
+            protocol_definitions,
+            stochkv_det=stochkv_det,
+            prefix=prefix,
+            apical_point_isec=apical_point_isec,
+            syn_locs=syn_locs,
+            extra_recordings=extra_recordings,
+            threshold_current=threshold_current,
+        )
+
+    def parse_sscx_protocol_definitions(
+        self,
+        protocol_definitions,
+        stochkv_det=None,
+        prefix="",
+        apical_point_isec=-1,
+        syn_locs=None,
+        extra_recordings=None,
+        threshold_current=None,
+    ):
+        """Parses SSCX protocol definitions, with the structure of the protocols file.
+
+        Args:
+            protocol_definitions (dict): protocol definitions keyed by protocol name
+            stochkv_det (bool): set if stochastic or deterministic
+            prefix (str): prefix used in naming responses, features, recordings, etc.
+            apical_point_isec (int): apical point section index
+                Should be given if there is "somadistanceapic" in "type"
+                of at least one of the extra recordings
+            syn_locs (list of ephys.locations.NrnPointProcessLocation):
+                locations of the synapses (if any, else None)
+            extra_recordings (list): extra recording definitions added to
+                every protocol, e.g. the ones of the configuration file
+            threshold_current (float): stored threshold current (nA) used by the
+                steps relative to the threshold, e.g. the one of the features file
 
+        Returns:
+            dict containing the protocols
+        """
         for protocol_name, protocol_definition in protocol_definitions.items():
             if protocol_name not in ["Main", "RinHoldcurrent"]:
                 recordings = get_recordings(
-                    protocol_name, protocol_definition, prefix, apical_point_isec
+                    protocol_name,
+                    protocol_definition,
+                    prefix,
+                    apical_point_isec,
+                    extra_recordings,
                 )
 
                 if "type" in protocol_definition:
@@ -295,13 +488,48 @@ class ProtocolParser:
                         sscx_protocols,
                         recordings,
                         stochkv_det,
+                        threshold_current,
                     )
                     self._parse_sscx_threshold_detection(
                         protocol_definition, recordings, prefix
                     )
+                    self._parse_current_search(
+                        protocol_definition,
+                        protocol_name,
+                        recordings,
+                        stochkv_det,
+                        prefix,
+                    )
+                    self._parse_step_series(
+                        protocol_definition,
+                        protocol_name,
+                        prefix,
+                        apical_point_isec,
+                        extra_recordings,
+                        stochkv_det,
+                        threshold_current,
+                    )
+                    self._parse_attenuation(
+                        protocol_definition,
+                        protocol_name,
+                        prefix,
+                        apical_point_isec,
+                        extra_recordings,
+                        stochkv_det,
+                    )
                     self._parse_vecstim_netstim(
                         protocol_definition, protocol_name, recordings, syn_locs
                     )
+                    self._parse_chain(
+                        protocol_definition,
+                        protocol_name,
+                        stochkv_det,
+                        prefix,
+                        apical_point_isec,
+                        syn_locs,
+                        extra_recordings,
+                        threshold_current,
+                    )
 
                 else:
                     stimuli = [
@@ -318,6 +546,11 @@ class ProtocolParser:
                         name=protocol_name, stimuli=stimuli, recordings=recordings
                     )
 
+                if protocol_name in self.protocols_dict:
+                    set_protocol_globals(
+                        self.protocols_dict[protocol_name], protocol_definition
+                    )
+
         if "Main" in protocol_definitions.keys():
             self._parse_sscx_main(protocol_definitions, prefix)
         else:
@@ -330,6 +563,8 @@ class ProtocolParser:
         protocols_filepath,
         stochkv_det=None,
         prefix="",
+        extra_recordings=None,
+        seed=None,
     ):
         """Parses the Thalamus protocols from the json file input.
 
@@ -337,11 +572,17 @@ class ProtocolParser:
             protocols_filename (str): path to the protocols file
             stochkv_det (bool): set if stochastic or deterministic
             prefix (str): prefix used in naming responses, features, recordings, etc.
+            extra_recordings (list): extra recording definitions added to
+                every protocol, e.g. the ones of the configuration file
+            seed (int): global seed from which the seeds of the random stimuli
+                are derived. The seeds of the protocols file are used if None.
 
         Returns:
             dict containing the protocols
         """
-        protocol_definitions = self.load_protocol_json(protocols_filepath)
+        protocol_definitions = seed_protocol_definitions(
+            self.load_protocol_json(protocols_filepath), seed
+        )
 
         for protocol_name, protocol_definition in protocol_definitions.items():
             if protocol_name not in [
@@ -349,14 +590,30 @@ class ProtocolParser:
                 "RinHoldcurrent_dep",
                 "RinHoldcurrent_hyp",
             ]:
-                # By default include somatic recording
-                somav_recording = ephys.recordings.CompRecording(
-                    name=f"{prefix}.{protocol_name}.soma.v",
-                    location=SOMA_LOC,
-                    variable="v",
-                )
+                # By default include somatic recording,
+                # at every step if the protocol has no sampling
+                sampling = get_recording_sampling(protocol_definition)
+                if sampling:
+                    somav_recording = RecordingCustom(
+                        name=f"{prefix}.{protocol_name}.soma.v",
+                        location=SOMA_LOC,
+                        variable="v",
+                        **sampling,
+                    )
+                else:
+                    somav_recording = ephys.recordings.CompRecording(
+                        name=f"{prefix}.{protocol_name}.soma.v",
+                        location=SOMA_LOC,
+                        variable="v",
+                    )
 
                 recordings = [somav_recording]
+                recordings += get_extra_recordings(
+                    protocol_name,
+                    extra_recordings or [],
+                    prefix,
+                    default_sampling=sampling,
+                )
 
                 if "type" in protocol_definition:
                     # add protocol to protocol dict
@@ -370,6 +627,10 @@ class ProtocolParser:
                     self._parse_thalamus_threshold_detection(
                         protocol_definition, protocol_name, recordings, prefix
                     )
+                    if protocol_definition["type"] == "BurstProtocol":
+                        self.protocols_dict[protocol_name] = read_burst_protocol(
+                            protocol_name, protocol_definition, recordings
+                        )
 
                 else:
                     stimuli = [
@@ -386,6 +647,11 @@ class ProtocolParser:
                         name=protocol_name, stimuli=stimuli, recordings=recordings
                     )
 
+                if protocol_name in self.protocols_dict:
+                    set_protocol_globals(
+                        self.protocols_dict[protocol_name], protocol_definition
+                    )
+
         if "Main" in protocol_definitions.keys():
             self._parse_thalamus_main(protocol_definitions, prefix)
         else:
@@ -394,6 +660,65 @@ class ProtocolParser:
         return self.protocols_dict
 
 
+def read_burst_protocol(protocol_name, protocol_definition, recordings):
+    """Read the low-threshold burst protocol of the thalamus packages from definition.
+
+    The depolarizing step starts at the end of the hyperpolarizing pre-step.
+    Without step, the rebound burst after the release of the pre-step is analysed.
+
+    Args:
+        protocol_name (str): name of the protocol
+        protocol_definition (dict): contains the protocol configuration data
+        recordings (bluepyopt.ephys.recordings.CompRecording):
+            recordings to use with this protocol
+
+    Returns:
+        thalamus_protocols.BurstProtocol: burst protocol
+    """
+    stimuli_definition = protocol_definition["stimuli"]
+    hyperpolarization_definition = stimuli_definition["hyperpolarization"]
+    total_duration = hyperpolarization_definition["totduration"]
+    hyperpolarization_stimulus = ephys.stimuli.NrnSquarePulse(
+        step_amplitude=hyperpolarization_definition["amp"],
+        step_delay=hyperpolarization_definition["delay"],
+        step_duration=hyperpolarization_definition["duration"],
+        location=SOMA_LOC,
+        total_duration=total_duration,
+    )
+
+    step_stimulus = None
+    if "step" in stimuli_definition:
+        step_definition = stimuli_definition["step"]
+        step_stimulus = ephys.stimuli.NrnSquarePulse(
+            step_amplitude=step_definition["amp"],
+            step_delay=hyperpolarization_definition["delay"]
+            + hyperpolarization_definition["duration"],
+            step_duration=step_definition["duration"],
+            location=SOMA_LOC,
+            total_duration=total_duration,
+        )
+
+    holding_stimulus = None
+    if "holding" in stimuli_definition:
+        holding_definition = stimuli_definition["holding"]
+        holding_stimulus = ephys.stimuli.NrnSquarePulse(
+            step_amplitude=holding_definition.get("amp"),
+            step_delay=holding_definition.get("delay", 0.0),
+            step_duration=holding_definition.get("duration", total_duration),
+            location=SOMA_LOC,
+            total_duration=total_duration,
+        )
+
+    return thalamus_protocols.BurstProtocol(
+        name=protocol_name,
+        hyperpolarization_stimulus=hyperpolarization_stimulus,
+        step_stimulus=step_stimulus,
+        holding_stimulus=holding_stimulus,
+        recordings=recordings,
+        burst_window=protocol_definition.get("burst_window", 100.0),
+    )
+
+
 def read_ramp_threshold_protocol(protocol_name, protocol_definition, recordings):
     """Read ramp threshold protocol from definition.
 
@@ -473,11 +798,358 @@ def read_ramp_protocol(protocol_name, protocol_definition, recordings):
     )
 
 
+def read_sinusoid_protocol(protocol_name, protocol_definition, recordings):
+    """Read sinusoid or chirp protocol from definition.
+
+    Args:
+        protocol_name (str): name of the protocol
+        protocol_definition (dict): contains the protocol configuration data
+        recordings (bluepyopt.ephys.recordings.CompRecording):
+            recordings to use with this protocol
+
+    Returns:
+        SinusoidProtocol: Protocol injecting a sinusoidal or chirp current
+    """
+    if protocol_definition["type"] == "ChirpProtocol":
+        chirp_definition = protocol_definition["stimuli"]["chirp"]
+        sinusoid_stimulus = Chirp(
+            location=SOMA_LOC,
+            delay=chirp_definition["delay"],
+            duration=chirp_definition["duration"],
+            amp=chirp_definition["amp"],
+            freq_start=chirp_definition["freq_start"],
+            freq_end=chirp_definition["freq_end"],
+            total_duration=chirp_definition["totduration"],
+            offset=chirp_definition.get("offset", 0.0),
+        )
+    else:
+        sinusoid_definition = protocol_definition["stimuli"]["sinusoid"]
+        sinusoid_stimulus = Sinusoid(
+            location=SOMA_LOC,
+            delay=sinusoid_definition["delay"],
+            duration=sinusoid_definition["duration"],
+            amp=sinusoid_definition["amp"],
+            frequency=sinusoid_definition["frequency"],
+            total_duration=sinusoid_definition["totduration"],
+            offset=sinusoid_definition.get("offset", 0.0),
+        )
+
+    if "holding" in protocol_definition["stimuli"]:
+        holding_definition = protocol_definition["stimuli"]["holding"]
+        holding_stimulus = ephys.stimuli.NrnSquarePulse(
+            step_amplitude=holding_definition["amp"],
+            step_delay=holding_definition["delay"],
+            step_duration=holding_definition["duration"],
+            location=SOMA_LOC,
+            total_duration=holding_definition["totduration"],
+        )
+    else:
+        holding_stimulus = None
+
+    return sscx_protocols.SinusoidProtocol(
+        name=protocol_name,
+        sampled_stimulus=sinusoid_stimulus,
+        holding_stimulus=holding_stimulus,
+        recordings=recordings,
+    )
+
+
+def read_noise_protocol(protocol_name, protocol_definition, recordings):
+    """Read Ornstein-Uhlenbeck noise protocol from definition.
+
+    Args:
+        protocol_name (str): name of the protocol
+        protocol_definition (dict): contains the protocol configuration data
+        recordings (bluepyopt.ephys.recordings.CompRecording):
+            recordings to use with this protocol
+
+    Returns:
+        NoiseProtocol: Protocol injecting a noise current
+    """
+    noise_definition = protocol_definition["stimuli"]["noise"]
+    noise_stimulus = OrnsteinUhlenbeck(
+        location=SOMA_LOC,
+        delay=noise_definition["delay"],
+        duration=noise_definition["duration"],
+        mean=noise_definition["mean"],
+        sigma=noise_definition["sigma"],
+        tau=noise_definition["tau"],
+        total_duration=noise_definition["totduration"],
+        seed=noise_definition.get("seed", None),
+    )
+
+    if "holding" in protocol_definition["stimuli"]:
+        holding_definition = protocol_definition["stimuli"]["holding"]
+        holding_stimulus = ephys.stimuli.NrnSquarePulse(
+            step_amplitude=holding_definition["amp"],
+            step_delay=holding_definition["delay"],
+            step_duration=holding_definition["duration"],
+            location=SOMA_LOC,
+            total_duration=holding_definition["totduration"],
+        )
+    else:
+        holding_stimulus = None
+
+    return sscx_protocols.NoiseProtocol(
+        name=protocol_name,
+        sampled_stimulus=noise_stimulus,
+        holding_stimulus=holding_stimulus,
+        recordings=recordings,
+    )
+
+
+def read_waveform_file(path):
+    """Read the samples of a waveform from a npy, csv or text file.
+
+    Args:
+        path (str or Path): path to the file. The csv files are comma separated
+            and the other text files are whitespace separated.
+
+    Returns:
+        numpy.ndarray: the samples, with one sample per row
+    """
+    path = Path(path)
+    if path.suffix == ".npy":
+        return np.load(path)
+    delimiter = "," if path.suffix == ".csv" else None
+    return np.loadtxt(path, delimiter=delimiter)
+
+
+def read_waveform_protocol(protocol_name, protocol_definition, recordings):
+    """Read protocol playing a current waveform from a file.
+
+    The file contains the time (ms) and the current (nA) of each sample,
+    or only the current if the sampling time step 'dt' (ms) is given.
+
+    Args:
+        protocol_name (str): name of the protocol
+        protocol_definition (dict): contains the protocol configuration data
+        recordings (bluepyopt.ephys.recordings.CompRecording):
+            recordings to use with this protocol
+
+    Raises:
+        ValueError: if the file does not contain the times and currents
+            and no 'dt' is given
+
+    Returns:
+        WaveformProtocol: Protocol injecting the current waveform
+    """
+    waveform_definition = protocol_definition["stimuli"]["waveform"]
+    samples = read_waveform_file(waveform_definition["path"])
+    if samples.ndim == 2 and samples.shape[1] == 2:
+        time, current = samples[:, 0], samples[:, 1]
+    elif samples.ndim == 1 and "dt" in waveform_definition:
+        current = samples
+        time = np.arange(len(current)) * waveform_definition["dt"]
+    else:
+        raise ValueError(
+            f"The waveform of protocol {protocol_name} should have a time and "
+            "a current column, or only a current column and a 'dt'."
+        )
+    waveform_stimulus = Waveform(
+        location=SOMA_LOC,
+        delay=waveform_definition["delay"],
+        time=time,
+        current=np.asarray(current) * waveform_definition.get("scale", 1.0),
+        total_duration=waveform_definition["totduration"],
+    )
+
+    if "holding" in protocol_definition["stimuli"]:
+        holding_definition = protocol_definition["stimuli"]["holding"]
+        holding_stimulus = ephys.stimuli.NrnSquarePulse(
+            step_amplitude=holding_definition["amp"],
+            step_delay=holding_definition["delay"],
+            step_duration=holding_definition["duration"],
+            location=SOMA_LOC,
+            total_duration=holding_definition["totduration"],
+        )
+    else:
+        holding_stimulus = None
+
+    return sscx_protocols.WaveformProtocol(
+        name=protocol_name,
+        sampled_stimulus=waveform_stimulus,
+        holding_stimulus=holding_stimulus,
+        recordings=recordings,
+    )
+
+
+def read_conductance(conductance_definition, delay, duration, total_duration):
+    """Read the waveform of a conductance of the conductance clamp protocol.
+
+    Args:
+        conductance_definition (dict): the values (uS) of the conductance,
+            given as a 'values' list sampled at 'dt' (ms), or in a file at 'path',
+            or the 'mean', 'sigma' (uS), 'tau' (ms) and optional 'seed'
+            of an Ornstein-Uhlenbeck conductance
+        delay (float): delay after which the conductance begins (ms)
+        duration (float): duration of the conductance (ms)
+        total_duration (float): total duration of the protocol (ms)
+
+    Raises:
+        ValueError: if neither values nor Ornstein-Uhlenbeck parameters are given
+
+    Returns:
+        stimuli.SampledTrace or stimuli.OrnsteinUhlenbeck: waveform of the conductance
+    """
+    if "values" in conductance_definition or "path" in conductance_definition:
+        if "values" in conductance_definition:
+            values = conductance_definition["values"]
+        else:
+            values = read_waveform_file(conductance_definition["path"])
+        return SampledTrace(
+            location=SOMA_LOC,
+            delay=delay,
+            duration=duration,
+            values=values,
+            total_duration=total_duration,
+            dt=conductance_definition.get("dt", 0.025),
+        )
+    if all(key in conductance_definition for key in ["mean", "sigma", "tau"]):
+        return OrnsteinUhlenbeck(
+            location=SOMA_LOC,
+            delay=delay,
+            duration=duration,
+            mean=conductance_definition["mean"],
+            sigma=conductance_definition["sigma"],
+            tau=conductance_definition["tau"],
+            total_duration=total_duration,
+            seed=conductance_definition.get("seed", None),
+        )
+    raise ValueError(
+        "A conductance should be given by its 'values', a 'path' to its values, "
+        "or the 'mean', 'sigma' and 'tau' of an Ornstein-Uhlenbeck process."
+    )
+
+
+def read_conductance_clamp_protocol(protocol_name, protocol_definition, recordings):
+    """Read conductance clamp protocol from definition.
+
+    Args:
+        protocol_name (str): name of the protocol
+        protocol_definition (dict): contains the protocol configuration data
+        recordings (bluepyopt.ephys.recordings.CompRecording):
+            recordings to use with this protocol
+
+    Raises:
+        ValueError: if no conductance is given
+
+    Returns:
+        ConductanceClampProtocol: Protocol injecting conductances at the soma
+    """
+    conductance_definition = protocol_definition["stimuli"]["conductance"]
+    timing_keys = ["delay", "duration", "totduration"]
+    conductance_stimuli = {
+        name: ConductanceClamp(
+            location=SOMA_LOC,
+            conductance=read_conductance(
+                definition,
+                conductance_definition["delay"],
+                conductance_definition["duration"],
+                conductance_definition["totduration"],
+            ),
+            reversal_potential=definition["reversal"],
+        )
+        for name, definition in conductance_definition.items()
+        if name not in timing_keys
+    }
+    if not conductance_stimuli:
+        raise ValueError(f"No conductance is given for protocol {protocol_name}.")
+
+    if "holding" in protocol_definition["stimuli"]:
+        holding_definition = protocol_definition["stimuli"]["holding"]
+        holding_stimulus = ephys.stimuli.NrnSquarePulse(
+            step_amplitude=holding_definition["amp"],
+            step_delay=holding_definition["delay"],
+            step_duration=holding_definition["duration"],
+            location=SOMA_LOC,
+            total_duration=holding_definition["totduration"],
+        )
+    else:
+        holding_stimulus = None
+
+    return sscx_protocols.ConductanceClampProtocol(
+        name=protocol_name,
+        conductance_stimuli=conductance_stimuli,
+        holding_stimulus=holding_stimulus,
+        recordings=recordings,
+    )
+
+
+def read_current_search_protocol(
+    protocol_name, protocol_definition, recordings, stochkv_det=None, prefix=""
+):
+    """Read the holding current and rheobase search protocol from definition.
+
+    Args:
+        protocol_name (str): name of the protocol
+        protocol_definition (dict): contains the protocol configuration data
+        recordings (bluepyopt.ephys.recordings.CompRecording):
+            recordings to use with this protocol
+        stochkv_det (bool): set if stochastic or deterministic
+        prefix (str): prefix used in naming responses, features, recordings, etc.
+
+    Returns:
+        sscx_protocols.CurrentSearchProtocol: the current search protocol
+    """
+    step_definition = protocol_definition["stimuli"]["step"]
+    step_stimulus = ephys.stimuli.NrnSquarePulse(
+        step_amplitude=0.0,
+        step_delay=step_definition["delay"],
+        step_duration=step_definition["duration"],
+        location=SOMA_LOC,
+        total_duration=step_definition["totduration"],
+    )
+    holding_stimulus = ephys.stimuli.NrnSquarePulse(
+        step_amplitude=0.0,
+        step_delay=0.0,
+        step_duration=step_definition["totduration"],
+        location=SOMA_LOC,
+        total_duration=step_definition["totduration"],
+    )
+    step_protocol_template = sscx_protocols.StepProtocol(
+        name=protocol_name,
+        step_stimuli=[step_stimulus],
+        holding_stimulus=holding_stimulus,
+        recordings=recordings,
+        stochkv_det=stochkv_det,
+    )
+
+    search_parameters = {
+        key: protocol_definition[key]
+        for key in [
+            "holding_voltage",
+            "holding_precision",
+            "threshold_precision",
+            "max_depth",
+            "max_holding_current",
+            "max_threshold_current",
+            "spike_threshold",
+        ]
+        if key in protocol_definition
+    }
+
+    return sscx_protocols.CurrentSearchProtocol(
+        name=protocol_name,
+        step_protocol_template=step_protocol_template,
+        prefix=prefix,
+        **search_parameters,
+    )
+
+
 def read_step_protocol(
-    protocol_name, protocol_module, protocol_definition, recordings, stochkv_det=None
+    protocol_name,
+    protocol_module,
+    protocol_definition,
+    recordings,
+    stochkv_det=None,
+    threshold_current=None,
 ):
     """Read step protocol from definition.
 
+    The step amplitudes can be given relative to the threshold current,
+    e.g. "150%thresh", for the sscx protocols.
+
     Args:
         protocol_name (str): name of the protocol
         protocol_module (module): module that contains the protocol
@@ -486,9 +1158,15 @@ def read_step_protocol(
             recordings to use with this protocol
         protocol_cls (module): module that contains the protocol
         stochkv_det (bool): set if stochastic or deterministic
+        threshold_current (float): stored threshold current (nA)
+            used by the relative amplitudes. If None, the one of the cell model is used
+
+    Raises:
+        ValueError: if relative amplitudes are used with thalamus protocols
 
     Returns:
-        sscx_protocols.StepProtocol or thalamus_protocols.StepProtocolCustom: Step Protocol
+        sscx_protocols.StepProtocol or thalamus_protocols.StepProtocolCustom
+            or sscx_protocols.RelativeStepProtocol: Step Protocol
     """
     # pylint: disable=undefined-loop-variable
     step_definitions = protocol_definition["stimuli"]["step"]
@@ -496,9 +1174,12 @@ def read_step_protocol(
         step_definitions = [step_definitions]
 
     step_stimuli = []
+    thresh_percs = []
     for step_definition in step_definitions:
+        thresh_perc = parse_relative_amplitude(step_definition["amp"])
+        thresh_percs.append(thresh_perc)
         step_stim = ephys.stimuli.NrnSquarePulse(
-            step_amplitude=step_definition["amp"],
+            step_amplitude=step_definition["amp"] if thresh_perc is None else None,
             step_delay=step_definition["delay"],
             step_duration=step_definition["duration"],
             location=SOMA_LOC,
@@ -523,7 +1204,24 @@ def read_step_protocol(
             step_definition["stochkv_det"] if "stochkv_det" in step_definition else None
         )
 
-    if protocol_module is thalamus_protocols:
+    is_relative = any(thresh_perc is not None for thresh_perc in thresh_percs)
+    if is_relative and protocol_module is not sscx_protocols:
+        raise ValueError(
+            f"{protocol_name}: amplitudes relative to the threshold current "
+            "are only supported by the sscx protocols"
+        )
+
+    if is_relative:
+        return sscx_protocols.RelativeStepProtocol(
+            name=protocol_name,
+            thresh_percs=thresh_percs,
+            threshold_current=threshold_current,
+            step_stimuli=step_stimuli,
+            holding_stimulus=holding_stimulus,
+            recordings=recordings,
+            stochkv_det=stochkv_det,
+        )
+    elif protocol_module is thalamus_protocols:
         return protocol_module.StepProtocolCustom(
             name=protocol_name,
             step_stimulus=step_stimuli[0],
@@ -543,6 +1241,380 @@ def read_step_protocol(
         raise ValueError(f"unsupported protocol module: {protocol_module}")
 
 
+def get_step_series_amplitudes(amplitude_definition):
+    """Return the step amplitudes of a f-I curve or subthreshold protocol.
+
+    Args:
+        amplitude_definition (list or dict): list of amplitudes, or dict with the
+            'start', 'stop' and 'step' of evenly spaced amplitudes (nA),
+            'stop' included
+
+    Raises:
+        ValueError: if the step of the amplitude range is not positive
+
+    Returns:
+        list: the step amplitudes, in nA or relative to the threshold current
+    """
+    if isinstance(amplitude_definition, list):
+        return amplitude_definition
+
+    step = amplitude_definition["step"]
+    if step <= 0:
+        raise ValueError(f"the step of the amplitude range must be > 0: {step}")
+    # half a step more, to include the stop amplitude despite rounding errors
+    amplitudes = np.arange(
+        amplitude_definition["start"], amplitude_definition["stop"] + step / 2, step
+    )
+    return [round(float(amplitude), 10) for amplitude in amplitudes]
+
+
+def read_step_series_protocol(
+    protocol_name,
+    protocol_definition,
+    prefix="",
+    apical_point_isec=-1,
+    extra_recordings=None,
+    stochkv_det=None,
+    threshold_current=None,
+):
+    """Read the f-I curve or subthreshold protocol from definition.
+
+    One step protocol, named '<protocol_name>_<index>', is created per amplitude,
+    with the timing of the step of the definition and its own recordings.
+
+    Args:
+        protocol_name (str): name of the protocol
+        protocol_definition (dict): contains the protocol configuration data
+        prefix (str): prefix used in naming responses, features, recordings, etc.
+        apical_point_isec (int): apical point section index
+        extra_recordings (list): extra recording definitions added to every step
+        stochkv_det (bool): set if stochastic or deterministic
+        threshold_current (float): stored threshold current (nA)
+            used by the relative amplitudes
+
+    Returns:
+        sscx_protocols.FICurveProtocol or sscx_protocols.SubthresholdProtocol:
+            the series of steps, with the class of the protocol type
+    """
+    step_protocols = []
+    amplitudes = get_step_series_amplitudes(protocol_definition["amplitudes"])
+    for i, amplitude in enumerate(amplitudes):
+        step_name = f"{protocol_name}_{i}"
+        step_definition = {
+            "stimuli": {
+                **protocol_definition["stimuli"],
+                "step": {**protocol_definition["stimuli"]["step"], "amp": amplitude},
+            }
+        }
+        step_protocols.append(
+            read_step_protocol(
+                step_name,
+                sscx_protocols,
+                step_definition,
+                get_recordings(
+                    step_name,
+                    protocol_definition,
+                    prefix,
+                    apical_point_isec,
+                    extra_recordings,
+                ),
+                stochkv_det,
+                threshold_current,
+            )
+        )
+
+    protocol_cls = getattr(sscx_protocols, protocol_definition["type"])
+    return protocol_cls(
+        name=protocol_name,
+        step_protocols=step_protocols,
+        spike_threshold=protocol_definition.get("spike_threshold", -20.0),
+    )
+
+
+def get_voltage_clamp_analysis(analysis_definition, swept_step, n_steps):
+    """Return the analysis of the peak currents of a voltage clamp protocol.
+
+    Args:
+        analysis_definition (dict): the 'type' of curve, 'activation' or
+            'inactivation', the optional index of the 'step' in which the peak
+            current is measured, the 'reversal' potential (mV) of the current,
+            needed by the activation curves, and the 'transient' (ms, 0.5 by default)
+            skipped at the start of the step to ignore the capacitive current
+        swept_step (int): index of the step whose voltage changes between sweeps
+        n_steps (int): number of steps of the command voltage
+
+    Raises:
+        ValueError: if the type is unknown, if the step does not exist,
+            or if the reversal potential of an activation curve is missing
+
+    Returns:
+        dict: the analysis, with the measured step set by default to the swept step
+            for the activation curves, and to the next one for the inactivation curves
+    """
+    analysis_type = analysis_definition.get("type")
+    if analysis_type not in VOLTAGE_CLAMP_ANALYSES:
+        raise ValueError(
+            f"Unknown voltage clamp analysis {analysis_type}. "
+            f"Expected one of {VOLTAGE_CLAMP_ANALYSES}."
+        )
+    default_step = swept_step if analysis_type == "activation" else swept_step + 1
+    analysis = {
+        "type": analysis_type,
+        "step": analysis_definition.get("step", default_step),
+        "reversal": analysis_definition.get("reversal", None),
+        "transient": analysis_definition.get("transient", 0.5),
+    }
+    if not 0 <= analysis["step"] < n_steps:
+        raise ValueError(
+            f"The step {analysis['step']} of the voltage clamp analysis does not exist."
+        )
+    if analysis_type == "activation" and analysis["reversal"] is None:
+        raise ValueError("The activation curve needs the reversal potential.")
+    return analysis
+
+
+def read_voltage_clamp_protocol(
+    protocol_name,
+    protocol_definition,
+    prefix="",
+    apical_point_isec=-1,
+    extra_recordings=None,
+):
+    """Read the voltage clamp protocol from definition.
+
+    The voltage of at most one step can be a list, or a dict with the 'start',
+    'stop' and 'step' of evenly spaced voltages (mV). One sweep protocol,
+    named '<protocol_name>_<index>', is created per voltage of this step,
+    recording the clamp current as '<prefix>.<sweep name>.soma.i'.
+
+    Args:
+        protocol_name (str): name of the protocol
+        protocol_definition (dict): contains the protocol configuration data
+        prefix (str): prefix used in naming responses, features, recordings, etc.
+        apical_point_isec (int): apical point section index
+        extra_recordings (list): extra recording definitions added to every sweep
+
+    Raises:
+        ValueError: if the voltage of more than one step changes between sweeps
+
+    Returns:
+        sscx_protocols.VoltageClampProtocol: the series of voltage clamp sweeps
+    """
+    vclamp_definition = protocol_definition["stimuli"]["vclamp"]
+    steps = vclamp_definition["steps"]
+    swept_steps = [
+        i for i, step in enumerate(steps) if isinstance(step["voltage"], (list, dict))
+    ]
+    if len(swept_steps) > 1:
+        raise ValueError(
+            f"{protocol_name}: only the voltage of one step can change between sweeps."
+        )
+    swept_step = swept_steps[0] if swept_steps else 0
+    if swept_steps:
+        swept_voltages = get_step_series_amplitudes(steps[swept_step]["voltage"])
+    else:
+        swept_voltages = [steps[swept_step]["voltage"]]
+
+    sweep_protocols = []
+    for i, swept_voltage in enumerate(swept_voltages):
+        sweep_name = f"{protocol_name}_{i}"
+        voltages = [step["voltage"] for step in steps]
+        voltages[swept_step] = swept_voltage
+        vclamp_stimulus = VoltageClamp(
+            location=SOMA_LOC,
+            voltages=voltages,
+            durations=[step["duration"] for step in steps],
+            series_resistance=vclamp_definition.get("series_resistance", 0.01),
+        )
+        recordings = get_recordings(
+            sweep_name, protocol_definition, prefix, apical_point_isec, extra_recordings
+        )
+        recordings.append(
+            ClampCurrentRecording(
+                name=f"{prefix}.{sweep_name}.soma.i",
+                stimulus=vclamp_stimulus,
+                **get_recording_sampling(protocol_definition),
+            )
+        )
+        sweep_protocols.append(
+            sscx_protocols.VoltageClampSweepProtocol(
+                name=sweep_name, vclamp_stimulus=vclamp_stimulus, recordings=recordings
+            )
+        )
+
+    if "analysis" in protocol_definition:
+        analysis = get_voltage_clamp_analysis(
+            protocol_definition["analysis"], swept_step, len(steps)
+        )
+    else:
+        analysis = None
+
+    return sscx_protocols.VoltageClampProtocol(
+        name=protocol_name,
+        sweep_protocols=sweep_protocols,
+        swept_step=swept_step,
+        analysis=analysis,
+    )
+
+
+def get_distance_recording(
+    protocol_name, distance, seclist_name, prefix, apical_point_isec=-1, sampling=None
+):
+    """Return the voltage recording at a distance from the soma.
+
+    Args:
+        protocol_name (str): name of the protocol
+        distance (float): distance from the soma (um)
+        seclist_name (str): name of the section list, e.g. "apical" or "basal"
+        prefix (str): prefix used in naming responses, features, recordings, etc.
+        apical_point_isec (int): apical point section index
+        sampling (dict): record_dt and record_every keyword arguments
+            of the recording, see protocols_func.get_recording_sampling
+
+    Returns:
+        RecordingCustom: the recording, named after the distance
+    """
+    # pylint: disable=too-many-arguments
+    location = get_distance_location(distance, seclist_name, apical_point_isec)
+    return RecordingCustom(
+        name=f"{prefix}.{protocol_name}.{location.name}.v",
+        location=location,
+        variable="v",
+        **(sampling or {}),
+    )
+
+
+def read_bap_protocol(
+    protocol_name,
+    protocol_definition,
+    prefix="",
+    apical_point_isec=-1,
+    extra_recordings=None,
+    stochkv_det=None,
+):
+    """Read the backpropagating action potential protocol from definition.
+
+    The voltage is recorded at each of the 'distances' (um) from the soma
+    in the section list 'seclist_name', 'apical' by default.
+
+    Args:
+        protocol_name (str): name of the protocol
+        protocol_definition (dict): contains the protocol configuration data
+        prefix (str): prefix used in naming responses, features, recordings, etc.
+        apical_point_isec (int): apical point section index
+        extra_recordings (list): extra recording definitions
+        stochkv_det (bool): set if stochastic or deterministic
+
+    Raises:
+        ValueError: if the step amplitude is relative to the threshold current
+
+    Returns:
+        sscx_protocols.BAPProtocol: the bAP protocol
+    """
+    seclist_name = protocol_definition.get("seclist_name", "apical")
+    recordings = get_recordings(
+        protocol_name,
+        protocol_definition,
+        prefix,
+        apical_point_isec,
+        extra_recordings,
+    )
+    recording_distances = {}
+    for distance in protocol_definition["distances"]:
+        recording = get_distance_recording(
+            protocol_name,
+            distance,
+            seclist_name,
+            prefix,
+            apical_point_isec,
+            get_recording_sampling(protocol_definition),
+        )
+        recordings.append(recording)
+        recording_distances[recording.name] = distance
+
+    step_protocol = read_step_protocol(
+        protocol_name, sscx_protocols, protocol_definition, recordings, stochkv_det
+    )
+    if isinstance(step_protocol, sscx_protocols.RelativeStepProtocol):
+        raise ValueError(
+            f"{protocol_name}: the step amplitude of the bAP protocol must be in nA"
+        )
+    return sscx_protocols.BAPProtocol(
+        name=protocol_name,
+        step_stimuli=step_protocol.step_stimuli,
+        holding_stimulus=step_protocol.holding_stimulus,
+        recordings=recordings,
+        recording_distances=recording_distances,
+        stochkv_det=step_protocol.stochkv_det,
+    )
+
+
+def read_epsp_attenuation_protocol(
+    protocol_name,
+    protocol_definition,
+    prefix="",
+    apical_point_isec=-1,
+    extra_recordings=None,
+):
+    """Read the EPSP attenuation protocol from definition.
+
+    One protocol, named '<protocol_name>_<index>', is created per distance,
+    injecting the EPSP-like current at the distance from the soma
+    in the section list 'seclist_name', 'apical' by default,
+    and recording at the soma and at the injection site.
+
+    Args:
+        protocol_name (str): name of the protocol
+        protocol_definition (dict): contains the protocol configuration data
+        prefix (str): prefix used in naming responses, features, recordings, etc.
+        apical_point_isec (int): apical point section index
+        extra_recordings (list): extra recording definitions added to every site
+
+    Returns:
+        sscx_protocols.EPSPAttenuationProtocol: the EPSP attenuation protocol
+    """
+    seclist_name = protocol_definition.get("seclist_name", "apical")
+    epsp_definition = protocol_definition["stimuli"]["epsp"]
+    distances = protocol_definition["distances"]
+
+    site_protocols = []
+    for i, distance in enumerate(distances):
+        site_name = f"{protocol_name}_{i}"
+        site_recording = get_distance_recording(
+            site_name,
+            distance,
+            seclist_name,
+            prefix,
+            apical_point_isec,
+            get_recording_sampling(protocol_definition),
+        )
+        recordings = get_recordings(
+            site_name, protocol_definition, prefix, apical_point_isec, extra_recordings
+        )
+        # the injection site comes right after the soma
+        recordings.insert(1, site_recording)
+        epsp_stimulus = DoubleExponential(
+            location=site_recording.location,
+            delay=epsp_definition["delay"],
+            duration=epsp_definition["duration"],
+            amp=epsp_definition["amp"],
+            tau_rise=epsp_definition["tau_rise"],
+            tau_decay=epsp_definition["tau_decay"],
+            total_duration=epsp_definition["totduration"],
+        )
+        site_protocols.append(
+            sscx_protocols.SampledCurrentProtocol(
+                name=site_name,
+                sampled_stimulus=epsp_stimulus,
+                recordings=recordings,
+            )
+        )
+
+    return sscx_protocols.EPSPAttenuationProtocol(
+        name=protocol_name, site_protocols=site_protocols, distances=distances
+    )
+
+
 def read_step_threshold_protocol(
     protocol_name, protocol_module, protocol_definition, recordings, stochkv_det=None
 ):
@@ -608,6 +1680,149 @@ def read_step_threshold_protocol(
         raise ValueError(f"unsupported protocol module: {protocol_module}")
 
 
+def read_chain_protocol(
+    protocol_name,
+    protocol_definition,
+    stochkv_det=None,
+    prefix="",
+    apical_point_isec=-1,
+    syn_locs=None,
+    extra_recordings=None,
+    threshold_current=None,
+):
+    """Read the protocols run back-to-back on the same cell from definitions.
+
+    Each phase is defined like a protocol of the protocols file,
+    and is named after the chain and the phase, e.g. 'Plasticity_Induction'.
+
+    Args:
+        protocol_name (str): name of the protocol
+        protocol_definition (dict): dict containing the protocol data,
+            with the definition of each phase under 'phases', in the order of the run
+        stochkv_det (bool): set if stochastic or deterministic
+        prefix (str): prefix used in naming responses, features, recordings, etc.
+        apical_point_isec (int): apical point section index
+        syn_locs (list of ephys.locations.NrnPointProcessLocation):
+            locations of the synapses (if any, else None)
+        extra_recordings (list): extra recording definitions added to every phase
+        threshold_current (float): stored threshold current (nA) used by the
+            steps relative to the threshold
+
+    Raises:
+        ValueError: if there is no phase, or if a phase is not a single sweep
+
+    Returns:
+        sscx_protocols.ChainProtocol: the chained protocols
+    """
+    # pylint: disable=too-many-arguments
+    phases = protocol_definition.get("phases", {})
+    phase_definitions = {
+        f"{protocol_name}_{phase_name}": phase_definition
+        for phase_name, phase_definition in phases.items()
+    }
+    if not phase_definitions:
+        raise ValueError(f"The chain protocol {protocol_name} has no phases.")
+
+    phases_dict = ProtocolParser().parse_sscx_protocol_definitions(
+        phase_definitions,
+        stochkv_det=stochkv_det,
+        prefix=prefix,
+        apical_point_isec=apical_point_isec,
+        syn_locs=syn_locs,
+        extra_recordings=extra_recordings,
+        threshold_current=threshold_current,
+    )
+    phase_protocols = []
+    for phase_name in phase_definitions:
+        phase_protocol = phases_dict.get(phase_name)
+        if not isinstance(phase_protocol, ephys.protocols.SweepProtocol):
+            raise ValueError(
+                f"The phase {phase_name} of {protocol_name} should be a single sweep, "
+                "e.g. a step or a synapse protocol."
+            )
+        phase_protocols.append(phase_protocol)
+
+    return sscx_protocols.ChainProtocol(protocol_name, phase_protocols)
+
+
+def read_synapse_protocol(protocol_name, stim_definition, synapse_stimulus, recordings):
+    """Return a synapse protocol, with its holding current and conditioning if any.
+
+    Args:
+        protocol_name (str): name of the protocol
+        stim_definition (dict): stimuli of the protocol definition
+        synapse_stimulus (Stimulus): stimulus activating the synapses
+        recordings (bluepyopt.ephys.recordings.CompRecording):
+            recordings to use with this protocol
+
+    Raises:
+        ValueError: if the conditioning is not a current or voltage step
+            of positive duration ending before the synapses are activated
+
+    Returns:
+        emodelrunner.protocols.SweepProtocolCustom: the synapse protocol,
+            a ConditionedSynapseProtocol if it has a holding current or a conditioning
+    """
+    holding_current = stim_definition.get("holding_current")
+    conditioning = stim_definition.get("conditioning")
+    if holding_current is None and conditioning is None:
+        return sscx_protocols.SweepProtocolCustom(
+            protocol_name, [synapse_stimulus], recordings
+        )
+
+    total_duration = synapse_stimulus.total_duration
+    holding_stimulus = None
+    if holding_current is not None:
+        holding_stimulus = ephys.stimuli.NrnSquarePulse(
+            step_amplitude=holding_current,
+            step_delay=0.0,
+            step_duration=total_duration,
+            location=SOMA_LOC,
+            total_duration=total_duration,
+        )
+
+    conditioning_stimulus = None
+    conditioning_duration = 0.0
+    exclude_conditioning = False
+    if conditioning is not None:
+        conditioning_duration = conditioning.get("duration", 0.0)
+        syn_start = stim_definition.get("syn_start", 0.0)
+        if not 0 < conditioning_duration <= syn_start:
+            raise ValueError(
+                f"The conditioning of {protocol_name} should have a positive duration "
+                f"ending before syn_start ({syn_start} ms)."
+            )
+        conditioning_type = conditioning.get("type", "current")
+        if conditioning_type == "current":
+            conditioning_stimulus = ephys.stimuli.NrnSquarePulse(
+                step_amplitude=conditioning["amp"],
+                step_delay=0.0,
+                step_duration=conditioning_duration,
+                location=SOMA_LOC,
+                total_duration=total_duration,
+            )
+        elif conditioning_type == "voltage":
+            conditioning_stimulus = VoltageClamp(
+                SOMA_LOC, [conditioning["voltage"]], [conditioning_duration]
+            )
+        else:
+            raise ValueError(
+                f"Unknown conditioning type of {protocol_name}: {conditioning_type}. "
+                "Choose 'current' or 'voltage'."
+            )
+        exclude_conditioning = conditioning.get("exclude", False)
+
+    return sscx_protocols.ConditionedSynapseProtocol(
+        name=protocol_name,
+        synapse_stimuli=[synapse_stimulus],
+        holding_stimulus=holding_stimulus,
+        conditioning_stimulus=conditioning_stimulus,
+        conditioning_duration=conditioning_duration,
+        exclude_conditioning=exclude_conditioning,
+        recordings=recordings,
+    )
+
+
 def read_vecstim_protocol(protocol_name, protocol_definition, recordings, syn_locs):
     """Read Vecstim protocol from definitions.
 
@@ -624,6 +1839,19 @@ def read_vecstim_protocol(protocol_name, protocol_definition, recordings, syn_lo
             a protocol containing Vecstim stimulus activating synapses
     """
     stim_definition = protocol_definition["stimuli"]
+    if "spike_file" in stim_definition:
+        # each synapse receives the spikes of its presynaptic cell
+        stim = NrnVecStimStimulusCustom(
+            syn_locs,
+            stim_definition.get("syn_start", 0.0),
+            stim_definition["syn_stop"],
+            pre_spike_trains=load_spike_trains(
+                stim_definition["spike_file"],
+                stim_definition.get("spike_population", ""),
+            ),
+        )
+        return read_synapse_protocol(protocol_name, stim_definition, stim, recordings)
+
     if stim_definition["vecstim_random"] not in [
         "python",
         "neuron",
@@ -642,7 +1870,7 @@ def read_vecstim_protocol(protocol_name, protocol_definition, recordings, syn_lo
         stim_definition["vecstim_random"],
     )
 
-    return sscx_protocols.SweepProtocolCustom(protocol_name, [stim], recordings)
+    return read_synapse_protocol(protocol_name, stim_definition, stim, recordings)
 
 
 def read_netstim_protocol(protocol_name, protocol_definition, recordings, syn_locs):
@@ -669,6 +1897,39 @@ def read_netstim_protocol(protocol_name, protocol_definition, recordings, syn_lo
         stim_definition["syn_interval"],
         stim_definition["syn_start"],
         stim_definition["syn_noise"],
+        seed=stim_definition.get("syn_stim_seed"),
+    )
+
+    return read_synapse_protocol(protocol_name, stim_definition, stim, recordings)
+
+
+def read_spike_generators_protocol(
+    protocol_name, protocol_definition, recordings, syn_locs
+):
+    """Read SpikeGenerators protocol from definitions.
+
+    Args:
+        protocol_name (str): name of the protocol
+        protocol_definition (dict): dict containing the protocol data
+        recordings (bluepyopt.ephys.recordings.CompRecording):
+            recordings to use with this protocol
+        syn_locs (list of ephys.locations.NrnPointProcessLocation):
+            locations of the synapses
+
+    Returns:
+        emodelrunner.protocols.SweepProtocolCustom:
+            a protocol containing Poisson or burst spike trains activating synapses
+    """
+    stim_definition = protocol_definition["stimuli"]
+    for group, generator in stim_definition["generators"].items():
+        check_generator_definition(group, generator)
+
+    stim = NrnSpikeGeneratorStimulus(
+        syn_locs,
+        stim_definition.get("syn_start", 0.0),
+        stim_definition["syn_stop"],
+        stim_definition["generators"],
+        stim_definition.get("syn_stim_seed"),
     )
 
-    return sscx_protocols.SweepProtocolCustom(protocol_name, [stim], recordings)
+    return read_synapse_protocol(protocol_name, stim_definition, stim, recordings)
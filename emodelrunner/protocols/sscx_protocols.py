@@ -22,7 +22,14 @@ import logging
 import numpy as np
 from bluepyopt import ephys
 
-from emodelrunner.protocols.protocols_func import CurrentOutputKeyMixin
+from emodelrunner.protocols.protocols_func import (
+    CurrentOutputKeyMixin,
+    NeuronGlobalsMixin,
+    get_cell_state,
+    set_cell_state,
+)
+from emodelrunner.results import detect_spikes
+from emodelrunner.simulators import continue_run
 
 logger = logging.getLogger(__name__)
 
@@ -150,7 +157,7 @@ class RatSSCxMainProtocol(ephys.protocols.Protocol):
             response = other_protocol.run(cell_model, {}, sim=sim)
             responses.update(response)
 
-    def run(self, cell_model, param_values, sim=None, isolate=None):
+    def run(self, cell_model, param_values, sim=None, isolate=None, timeout=None):
         """Run protocol.
 
         Args:
@@ -159,6 +166,8 @@ class RatSSCxMainProtocol(ephys.protocols.Protocol):
             sim (bluepyopt.ephys.NrnSimulator): neuron simulator
             isolate (bool): whether to isolate the run in a process with a timeout
                 to avoid bad cells running for too long
+            timeout (float): duration in seconds after which the isolated run is stopped.
+                Unused, for compatibility with bluepyopt's CellEvaluator
 
         Returns:
             dict containing the responses for all the protocols
@@ -757,7 +766,9 @@ class RatSSCxThresholdDetectionProtocol(ephys.protocols.Protocol):
         return threshold_current
 
 
-class StepProtocol(ephys.protocols.SweepProtocol, CurrentOutputKeyMixin):
+class StepProtocol(
+    NeuronGlobalsMixin, ephys.protocols.SweepProtocol, CurrentOutputKeyMixin
+):
     """Protocol consisting of step and holding current.
 
     Attributes:
@@ -824,6 +835,8 @@ class StepProtocol(ephys.protocols.SweepProtocol, CurrentOutputKeyMixin):
                     e,
                 )
 
+        self.set_neuron_globals(sim)
+
     def run(self, cell_model, param_values, sim=None, isolate=None, timeout=None):
         """Run protocol.
 
@@ -841,9 +854,13 @@ class StepProtocol(ephys.protocols.SweepProtocol, CurrentOutputKeyMixin):
         responses = {}
 
         cvode_active_copy = self.cvode_active
+        # the stochastic channels are set back as they were after the run,
+        # e.g. stochastic if they are stochastic in every protocol
+        deterministic_copy = {}
         if self.stochkv_det is not None and not self.stochkv_det:
             for mechanism in cell_model.mechanisms:
                 if "Stoch" in mechanism.prefix:
+                    deterministic_copy[mechanism.name] = mechanism.deterministic
                     mechanism.deterministic = False
             self.cvode_active = False
 
@@ -856,7 +873,7 @@ class StepProtocol(ephys.protocols.SweepProtocol, CurrentOutputKeyMixin):
         if self.stochkv_det is not None and not self.stochkv_det:
             for mechanism in cell_model.mechanisms:
                 if "Stoch" in mechanism.prefix:
-                    mechanism.deterministic = True
+                    mechanism.deterministic = deterministic_copy[mechanism.name]
             self.cvode_active = cvode_active_copy
 
         return responses
@@ -1067,149 +1084,328 @@ class StepThresholdProtocol(StepProtocol):
         return {self.curr_output_key(): {"time": t, "current": current}}
 
 
-class RampProtocol(ephys.protocols.SweepProtocol, CurrentOutputKeyMixin):
-    """Protocol consisting of ramp and holding current.
+class RelativeStepProtocol(StepProtocol):
+    """Step protocol with amplitudes relative to the threshold current.
+
+    The threshold current is the stored one if given,
+    else the one set to the cell model, e.g. by a CurrentSearchProtocol.
 
     Attributes:
         name (str): name of this object
         stimuli (list of Stimuli): List of all Stimulus objects used in protocol
         recordings (list of Recordings): Recording objects used in the protocol
         cvode_active (bool): whether to use variable time step
-        ramp_stimulus (Stimulus): ramp Stimulus
+        step_stimuli (list of Stimuli): List of step Stimulus objects used in protocol
         holding_stimulus (Stimulus): Holding Stimulus
+        stochkv_det (bool): set if stochastic or deterministic
+        thresh_percs (list): percentage of the threshold current of each step,
+            None for the steps with an absolute amplitude
+        threshold_current (float): stored threshold current (nA)
     """
 
     def __init__(
         self,
-        name=None,
-        ramp_stimulus=None,
+        name,
+        thresh_percs=None,
+        threshold_current=None,
+        step_stimuli=None,
         holding_stimulus=None,
         recordings=None,
         cvode_active=None,
+        stochkv_det=None,
     ):
         """Constructor.
 
         Args:
             name (str): name of this object
-            ramp_stimulus (Stimulus): Stimulus objects used in protocol
+            thresh_percs (list): percentage of the threshold current of each step,
+                None for the steps with an absolute amplitude
+            threshold_current (float): stored threshold current (nA).
+                If None, the one of the cell model is used
+            step_stimuli (list of Stimuli): List of Stimulus objects used in protocol
             holding_stimulus (Stimulus): Holding Stimulus
             recordings (list of Recordings): Recording objects used in the
                 protocol
             cvode_active (bool): whether to use variable time step
+            stochkv_det (bool): set if stochastic or deterministic
         """
         super().__init__(
             name,
-            stimuli=[ramp_stimulus, holding_stimulus]
-            if holding_stimulus is not None
-            else [ramp_stimulus],
+            step_stimuli=step_stimuli,
+            holding_stimulus=holding_stimulus,
             recordings=recordings,
             cvode_active=cvode_active,
+            stochkv_det=stochkv_det,
         )
 
-        self.ramp_stimulus = ramp_stimulus
-        self.holding_stimulus = holding_stimulus
+        self.thresh_percs = thresh_percs
+        self.threshold_current = threshold_current
+        if threshold_current is not None:
+            self.set_step_amplitudes(threshold_current)
+
+    def set_step_amplitudes(self, threshold_current):
+        """Set the amplitudes of the relative steps.
+
+        Args:
+            threshold_current (float): the threshold current (nA)
+        """
+        for step_stim, thresh_perc in zip(self.step_stimuli, self.thresh_percs):
+            if thresh_perc is not None:
+                step_stim.step_amplitude = threshold_current * (
+                    float(thresh_perc) / 100.0
+                )
+
+    def run(self, cell_model, param_values, sim=None, isolate=None, timeout=None):
+        """Run protocol.
+
+        Args:
+            cell_model (bluepyopt.ephys.models.CellModel): the cell model
+            param_values (dict): optimized parameters
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            isolate (bool): whether to isolate the run in a process with a timeout
+                to avoid bad cells running for too long
+            timeout (float): maximum real time (s) the cell is allowed to run when isolated
+
+        Raises:
+            Exception: if no threshold current is stored nor set to the cell model
+
+        Returns:
+            dict containing the responses for the step protocol
+        """
+        if self.threshold_current is None:
+            threshold_current = getattr(cell_model, "threshold_current", None)
+            if threshold_current is None:
+                raise Exception(
+                    "RelativeStepProtocol: running on cell_model "
+                    f"that doesnt have threshold current value set: {cell_model}"
+                )
+            self.set_step_amplitudes(threshold_current)
+
+        return super().run(
+            cell_model, param_values, sim=sim, isolate=isolate, timeout=timeout
+        )
 
     def generate_current(self, threshold_current=None, holding_current=None, dt=0.1):
         """Return current time series.
 
         Args:
-            threshold_current (float): the threshold current (nA)
+            threshold_current (float): the threshold current (nA),
+                used if no threshold current is stored
             holding_current (float): the holding current (nA)
             dt (float): timestep of the generated currents (ms)
 
         Returns:
             dict containing the generated current
         """
-        # pylint: disable=unused-argument
-        holding_current = 0.0
-        if self.holding_stimulus is not None:
-            holding_current = self.holding_stimulus.step_amplitude
-        t = np.arange(0.0, self.ramp_stimulus.total_duration, dt)
-        current = np.full(t.shape, holding_current, dtype="float64")
+        if self.threshold_current is None and threshold_current is not None:
+            self.set_step_amplitudes(threshold_current)
 
-        ton_idx = int(self.step_delay / dt)
-        toff_idx = int((self.step_delay + self.step_duration) / dt)
+        return super().generate_current(
+            threshold_current=threshold_current,
+            holding_current=holding_current,
+            dt=dt,
+        )
 
-        current[ton_idx:toff_idx] += np.linspace(
-            self.ramp_stimulus.ramp_amplitude_start,
-            self.ramp_stimulus.ramp_amplitude_end,
-            toff_idx - ton_idx + 1,
-        )[:-1]
 
-        return {self.curr_output_key(): {"time": t, "current": current}}
+class StepSeriesProtocol(ephys.protocols.Protocol):
+    """Series of step protocols of different amplitudes, run one after the other.
 
-    @property
-    def step_delay(self):
-        """Time stimulus delay.
+    Attributes:
+        name (str): name of the protocol
+        step_protocols (list of StepProtocol): one step protocol per amplitude
+        spike_threshold (float): voltage threshold for spike detection (mV)
+    """
+
+    def __init__(self, name, step_protocols=None, spike_threshold=-20.0):
+        """Constructor.
+
+        Args:
+            name (str): name of the protocol
+            step_protocols (list of StepProtocol): one step protocol per amplitude
+            spike_threshold (float): voltage threshold for spike detection (mV)
+        """
+        super().__init__(name=name)
+        self.step_protocols = step_protocols or []
+        self.spike_threshold = spike_threshold
+
+    def subprotocols(self):
+        """Return subprotocols.
 
         Returns:
-            time at which the ramp starts (ms)
+            dict containing the protocol and its step protocols
         """
-        return self.ramp_stimulus.ramp_delay
+        subprotocols = collections.OrderedDict({self.name: self})
+        for step_protocol in self.step_protocols:
+            subprotocols.update(step_protocol.subprotocols())
+
+        return subprotocols
+
+    def run(self, cell_model, param_values, sim=None, isolate=None, timeout=None):
+        """Run the step protocols.
+
+        Args:
+            cell_model (bluepyopt.ephys.models.CellModel): the cell model
+            param_values (dict): optimized parameters
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            isolate (bool): whether to isolate the run in a process with a timeout
+                to avoid bad cells running for too long
+            timeout (float): maximum real time (s) the cell is allowed to run when isolated
+
+        Returns:
+            dict containing the responses of all the steps
+        """
+        responses = collections.OrderedDict()
+        for step_protocol in self.step_protocols:
+            responses.update(
+                step_protocol.run(
+                    cell_model, param_values, sim=sim, isolate=isolate, timeout=timeout
+                )
+            )
+
+        return responses
+
+    def generate_current(self, threshold_current=None, holding_current=None, dt=0.1):
+        """Return the current time series of each step.
+
+        Args:
+            threshold_current (float): the threshold current (nA)
+            holding_current (float): the holding current (nA)
+            dt (float): timestep of the generated currents (ms)
+
+        Returns:
+            dict containing the generated currents
+        """
+        currents = {}
+        for step_protocol in self.step_protocols:
+            currents.update(
+                step_protocol.generate_current(
+                    threshold_current=threshold_current,
+                    holding_current=holding_current,
+                    dt=dt,
+                )
+            )
+
+        return currents
 
     @property
-    def step_duration(self):
-        """Time stimulus duration.
+    def step_amplitudes(self):
+        """Amplitude of each step.
 
         Returns:
-            duration of the ramp stimulus (ms)
+            list: the step amplitudes (nA), None for the relative steps not run yet
         """
-        return self.ramp_stimulus.ramp_duration
+        return [step_protocol.step_amplitude for step_protocol in self.step_protocols]
 
 
-class RampThresholdProtocol(RampProtocol):
-    """Step protocol based on threshold.
+class FICurveProtocol(StepSeriesProtocol):
+    """Series of step protocols whose firing rates give the f-I curve of the cell.
+
+    See emodelrunner.fi_curve for the computation of the curve and its fit.
+    """
+
+
+class SubthresholdProtocol(StepSeriesProtocol):
+    """Series of small steps, usually hyperpolarizing, not eliciting spikes.
+
+    The responses give the input resistance, the membrane time constant
+    and the sag ratio of the cell, see emodelrunner.subthreshold.
+    """
+
+
+class BAPProtocol(StepProtocol):
+    """Short somatic step eliciting an action potential, recorded in the dendrites.
+
+    The amplitude of the backpropagating action potential at each distance
+    gives its attenuation, see emodelrunner.attenuation.
 
     Attributes:
         name (str): name of this object
         stimuli (list of Stimuli): List of all Stimulus objects used in protocol
-        recordings (list of Recordings): Recording objects used in the protocol
+        recordings (list of Recordings): Recording objects used in the protocol,
+            the somatic one first
         cvode_active (bool): whether to use variable time step
-        ramp_stimulus (Stimulus): ramp Stimulus
+        step_stimuli (list of Stimuli): List of step Stimulus objects used in protocol
         holding_stimulus (Stimulus): Holding Stimulus
-        thresh_perc_start (float): percentage of the threshold current
-            at which to set the start of the ramp amplitude
-        thresh_perc_end (float): percentage of the threshold current
-            at which to set the end of the ramp amplitude
+        stochkv_det (bool): set if stochastic or deterministic
+        recording_distances (dict): distance from the soma (um)
+            of each dendritic recording name
     """
 
     def __init__(
         self,
-        name,
-        thresh_perc_start=None,
-        thresh_perc_end=None,
-        ramp_stimulus=None,
+        name=None,
+        step_stimuli=None,
         holding_stimulus=None,
         recordings=None,
+        recording_distances=None,
         cvode_active=None,
+        stochkv_det=None,
     ):
         """Constructor.
 
         Args:
-           name (str): name of this object
-           thresh_perc_start (float): percentage of the threshold current
-               at which to set the start of the ramp amplitude
-           thresh_perc_end (float): percentage of the threshold current
-               at which to set the end of the ramp amplitude
-           ramp_stimulus (Stimulus): Stimulus objects used in protocol
-           holding_stimulus (Stimulus): Holding Stimulus
-           recordings (list of Recordings): Recording objects used in the
-               protocol
-           cvode_active (bool): whether to use variable time step
+            name (str): name of this object
+            step_stimuli (list of Stimuli): List of Stimulus objects used in protocol
+            holding_stimulus (Stimulus): Holding Stimulus
+            recordings (list of Recordings): Recording objects used in the
+                protocol, the somatic one first
+            recording_distances (dict): distance from the soma (um)
+                of each dendritic recording name
+            cvode_active (bool): whether to use variable time step
+            stochkv_det (bool): set if stochastic or deterministic
         """
         super().__init__(
             name,
-            ramp_stimulus=ramp_stimulus,
+            step_stimuli=step_stimuli,
             holding_stimulus=holding_stimulus,
             recordings=recordings,
             cvode_active=cvode_active,
+            stochkv_det=stochkv_det,
         )
+        self.recording_distances = recording_distances or {}
 
-        self.thresh_perc_start = thresh_perc_start
-        self.thresh_perc_end = thresh_perc_end
+
+class EPSPAttenuationProtocol(ephys.protocols.Protocol):
+    """EPSP-like current injected at several distances in the dendrites, one by one.
+
+    Each injection site has its own protocol, recording at the soma
+    and at the injection site, so that the forward attenuation of the EPSP
+    can be computed, see emodelrunner.attenuation.
+
+    Attributes:
+        name (str): name of the protocol
+        site_protocols (list of SampledCurrentProtocol): one protocol per site,
+            recording at the soma first and at the injection site second
+        distances (list): distance from the soma (um) of each injection site
+    """
+
+    def __init__(self, name, site_protocols=None, distances=None):
+        """Constructor.
+
+        Args:
+            name (str): name of the protocol
+            site_protocols (list of SampledCurrentProtocol): one protocol per site,
+                recording at the soma first and at the injection site second
+            distances (list): distance from the soma (um) of each injection site
+        """
+        super().__init__(name=name)
+        self.site_protocols = site_protocols or []
+        self.distances = distances or []
+
+    def subprotocols(self):
+        """Return subprotocols.
+
+        Returns:
+            dict containing the protocol and the protocols of its sites
+        """
+        subprotocols = collections.OrderedDict({self.name: self})
+        for site_protocol in self.site_protocols:
+            subprotocols.update(site_protocol.subprotocols())
+
+        return subprotocols
 
     def run(self, cell_model, param_values, sim=None, isolate=None, timeout=None):
-        """Run protocol.
+        """Run the protocols of the injection sites.
 
         Args:
             cell_model (bluepyopt.ephys.models.CellModel): the cell model
@@ -1219,39 +1415,21 @@ class RampThresholdProtocol(RampProtocol):
                 to avoid bad cells running for too long
             timeout (float): maximum real time (s) the cell is allowed to run when isolated
 
-        Raises:
-            Exception: if the threshold_current is not set to the cell model
-
         Returns:
-            dict containing the responses for the ramp protocol
+            dict containing the responses of all the sites
         """
-        # pylint: disable=unused-argument
-        responses = {}
-        if not hasattr(cell_model, "threshold_current"):
-            raise Exception(
-                "RampThresholdProtocol: running on cell_model "
-                f"that doesnt have threshold current value set: {cell_model}"
-            )
-
-        self.ramp_stimulus.ramp_amplitude_start = cell_model.threshold_current * (
-            float(self.thresh_perc_start) / 100
-        )
-        self.ramp_stimulus.ramp_amplitude_end = cell_model.threshold_current * (
-            float(self.thresh_perc_end) / 100
-        )
-
-        self.holding_stimulus.step_amplitude = cell_model.holding_current
-
-        responses.update(
-            super().run(
-                cell_model, param_values, sim=sim, isolate=isolate, timeout=timeout
+        responses = collections.OrderedDict()
+        for site_protocol in self.site_protocols:
+            responses.update(
+                site_protocol.run(
+                    cell_model, param_values, sim=sim, isolate=isolate, timeout=timeout
+                )
             )
-        )
 
         return responses
 
-    def generate_current(self, threshold_current, holding_current, dt=0.1):
-        """Return current time series.
+    def generate_current(self, threshold_current=None, holding_current=None, dt=0.1):
+        """Return the current time series injected at each site.
 
         Args:
             threshold_current (float): the threshold current (nA)
@@ -1259,28 +1437,934 @@ class RampThresholdProtocol(RampProtocol):
             dt (float): timestep of the generated currents (ms)
 
         Returns:
-            dict containing the generated current
+            dict containing the generated currents
         """
-        # pylint: disable=signature-differs
-        t = np.arange(0.0, self.ramp_stimulus.total_duration, dt)
-        current = np.full(t.shape, holding_current, dtype="float64")
-
-        ton_idx = int(self.step_delay / dt)
-        toff_idx = int((self.step_delay + self.step_duration) / dt)
+        currents = {}
+        for site_protocol in self.site_protocols:
+            currents.update(
+                site_protocol.generate_current(
+                    threshold_current=threshold_current,
+                    holding_current=holding_current,
+                    dt=dt,
+                )
+            )
 
-        amp_start = threshold_current * (float(self.thresh_perc_start) / 100)
-        amp_end = threshold_current * (float(self.thresh_perc_end) / 100)
-        current[ton_idx:toff_idx] += np.linspace(
-            amp_start,
-            amp_end,
-            toff_idx - ton_idx + 1,
-        )[:-1]
+        return currents
 
-        return {self.curr_output_key(): {"time": t, "current": current}}
 
+class RampProtocol(
+    NeuronGlobalsMixin, ephys.protocols.SweepProtocol, CurrentOutputKeyMixin
+):
+    """Protocol consisting of ramp and holding current.
 
-class SweepProtocolCustom(ephys.protocols.SweepProtocol):
-    """SweepProtocol with generate_current method.
+    Attributes:
+        name (str): name of this object
+        stimuli (list of Stimuli): List of all Stimulus objects used in protocol
+        recordings (list of Recordings): Recording objects used in the protocol
+        cvode_active (bool): whether to use variable time step
+        ramp_stimulus (Stimulus): ramp Stimulus
+        holding_stimulus (Stimulus): Holding Stimulus
+    """
+
+    def __init__(
+        self,
+        name=None,
+        ramp_stimulus=None,
+        holding_stimulus=None,
+        recordings=None,
+        cvode_active=None,
+    ):
+        """Constructor.
+
+        Args:
+            name (str): name of this object
+            ramp_stimulus (Stimulus): Stimulus objects used in protocol
+            holding_stimulus (Stimulus): Holding Stimulus
+            recordings (list of Recordings): Recording objects used in the
+                protocol
+            cvode_active (bool): whether to use variable time step
+        """
+        super().__init__(
+            name,
+            stimuli=[ramp_stimulus, holding_stimulus]
+            if holding_stimulus is not None
+            else [ramp_stimulus],
+            recordings=recordings,
+            cvode_active=cvode_active,
+        )
+
+        self.ramp_stimulus = ramp_stimulus
+        self.holding_stimulus = holding_stimulus
+
+    def generate_current(self, threshold_current=None, holding_current=None, dt=0.1):
+        """Return current time series.
+
+        Args:
+            threshold_current (float): the threshold current (nA)
+            holding_current (float): the holding current (nA)
+            dt (float): timestep of the generated currents (ms)
+
+        Returns:
+            dict containing the generated current
+        """
+        # pylint: disable=unused-argument
+        holding_current = 0.0
+        if self.holding_stimulus is not None:
+            holding_current = self.holding_stimulus.step_amplitude
+        t = np.arange(0.0, self.ramp_stimulus.total_duration, dt)
+        current = np.full(t.shape, holding_current, dtype="float64")
+
+        ton_idx = int(self.step_delay / dt)
+        toff_idx = int((self.step_delay + self.step_duration) / dt)
+
+        current[ton_idx:toff_idx] += np.linspace(
+            self.ramp_stimulus.ramp_amplitude_start,
+            self.ramp_stimulus.ramp_amplitude_end,
+            toff_idx - ton_idx + 1,
+        )[:-1]
+
+        return {self.curr_output_key(): {"time": t, "current": current}}
+
+    @property
+    def step_delay(self):
+        """Time stimulus delay.
+
+        Returns:
+            time at which the ramp starts (ms)
+        """
+        return self.ramp_stimulus.ramp_delay
+
+    @property
+    def step_duration(self):
+        """Time stimulus duration.
+
+        Returns:
+            duration of the ramp stimulus (ms)
+        """
+        return self.ramp_stimulus.ramp_duration
+
+
+class RampThresholdProtocol(RampProtocol):
+    """Step protocol based on threshold.
+
+    Attributes:
+        name (str): name of this object
+        stimuli (list of Stimuli): List of all Stimulus objects used in protocol
+        recordings (list of Recordings): Recording objects used in the protocol
+        cvode_active (bool): whether to use variable time step
+        ramp_stimulus (Stimulus): ramp Stimulus
+        holding_stimulus (Stimulus): Holding Stimulus
+        thresh_perc_start (float): percentage of the threshold current
+            at which to set the start of the ramp amplitude
+        thresh_perc_end (float): percentage of the threshold current
+            at which to set the end of the ramp amplitude
+    """
+
+    def __init__(
+        self,
+        name,
+        thresh_perc_start=None,
+        thresh_perc_end=None,
+        ramp_stimulus=None,
+        holding_stimulus=None,
+        recordings=None,
+        cvode_active=None,
+    ):
+        """Constructor.
+
+        Args:
+           name (str): name of this object
+           thresh_perc_start (float): percentage of the threshold current
+               at which to set the start of the ramp amplitude
+           thresh_perc_end (float): percentage of the threshold current
+               at which to set the end of the ramp amplitude
+           ramp_stimulus (Stimulus): Stimulus objects used in protocol
+           holding_stimulus (Stimulus): Holding Stimulus
+           recordings (list of Recordings): Recording objects used in the
+               protocol
+           cvode_active (bool): whether to use variable time step
+        """
+        super().__init__(
+            name,
+            ramp_stimulus=ramp_stimulus,
+            holding_stimulus=holding_stimulus,
+            recordings=recordings,
+            cvode_active=cvode_active,
+        )
+
+        self.thresh_perc_start = thresh_perc_start
+        self.thresh_perc_end = thresh_perc_end
+
+    def run(self, cell_model, param_values, sim=None, isolate=None, timeout=None):
+        """Run protocol.
+
+        Args:
+            cell_model (bluepyopt.ephys.models.CellModel): the cell model
+            param_values (dict): optimized parameters
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            isolate (bool): whether to isolate the run in a process with a timeout
+                to avoid bad cells running for too long
+            timeout (float): maximum real time (s) the cell is allowed to run when isolated
+
+        Raises:
+            Exception: if the threshold_current is not set to the cell model
+
+        Returns:
+            dict containing the responses for the ramp protocol
+        """
+        # pylint: disable=unused-argument
+        responses = {}
+        if not hasattr(cell_model, "threshold_current"):
+            raise Exception(
+                "RampThresholdProtocol: running on cell_model "
+                f"that doesnt have threshold current value set: {cell_model}"
+            )
+
+        self.ramp_stimulus.ramp_amplitude_start = cell_model.threshold_current * (
+            float(self.thresh_perc_start) / 100
+        )
+        self.ramp_stimulus.ramp_amplitude_end = cell_model.threshold_current * (
+            float(self.thresh_perc_end) / 100
+        )
+
+        self.holding_stimulus.step_amplitude = cell_model.holding_current
+
+        responses.update(
+            super().run(
+                cell_model, param_values, sim=sim, isolate=isolate, timeout=timeout
+            )
+        )
+
+        return responses
+
+    def generate_current(self, threshold_current, holding_current, dt=0.1):
+        """Return current time series.
+
+        Args:
+            threshold_current (float): the threshold current (nA)
+            holding_current (float): the holding current (nA)
+            dt (float): timestep of the generated currents (ms)
+
+        Returns:
+            dict containing the generated current
+        """
+        # pylint: disable=signature-differs
+        t = np.arange(0.0, self.ramp_stimulus.total_duration, dt)
+        current = np.full(t.shape, holding_current, dtype="float64")
+
+        ton_idx = int(self.step_delay / dt)
+        toff_idx = int((self.step_delay + self.step_duration) / dt)
+
+        amp_start = threshold_current * (float(self.thresh_perc_start) / 100)
+        amp_end = threshold_current * (float(self.thresh_perc_end) / 100)
+        current[ton_idx:toff_idx] += np.linspace(
+            amp_start,
+            amp_end,
+            toff_idx - ton_idx + 1,
+        )[:-1]
+
+        return {self.curr_output_key(): {"time": t, "current": current}}
+
+
+class SampledCurrentProtocol(
+    NeuronGlobalsMixin, ephys.protocols.SweepProtocol, CurrentOutputKeyMixin
+):
+    """Protocol consisting of a sampled current, e.g. a sinusoid, and a holding current.
+
+    Attributes:
+        name (str): name of this object
+        stimuli (list of Stimuli): List of all Stimulus objects used in protocol
+        recordings (list of Recordings): Recording objects used in the protocol
+        cvode_active (bool): whether to use variable time step
+        sampled_stimulus (stimuli.SampledCurrent): sampled current Stimulus
+        holding_stimulus (Stimulus): Holding Stimulus
+    """
+
+    def __init__(
+        self,
+        name=None,
+        sampled_stimulus=None,
+        holding_stimulus=None,
+        recordings=None,
+        cvode_active=None,
+    ):
+        """Constructor.
+
+        Args:
+            name (str): name of this object
+            sampled_stimulus (stimuli.SampledCurrent): sampled current Stimulus
+            holding_stimulus (Stimulus): Holding Stimulus
+            recordings (list of Recordings): Recording objects used in the
+                protocol
+            cvode_active (bool): whether to use variable time step
+        """
+        super().__init__(
+            name,
+            stimuli=[sampled_stimulus, holding_stimulus]
+            if holding_stimulus is not None
+            else [sampled_stimulus],
+            recordings=recordings,
+            cvode_active=cvode_active,
+        )
+
+        self.sampled_stimulus = sampled_stimulus
+        self.holding_stimulus = holding_stimulus
+
+    def generate_current(self, threshold_current=None, holding_current=None, dt=0.1):
+        """Return current time series.
+
+        Args:
+            threshold_current (float): the threshold current (nA)
+            holding_current (float): the holding current (nA)
+            dt (float): timestep of the generated currents (ms)
+
+        Returns:
+            dict containing the generated current
+        """
+        # pylint: disable=unused-argument
+        t, current = self.sampled_stimulus.generate(dt)
+
+        if self.holding_stimulus is not None:
+            ton_idx = int(self.holding_stimulus.step_delay / dt)
+            toff_idx = int(
+                (self.holding_stimulus.step_delay + self.holding_stimulus.step_duration)
+                / dt
+            )
+            current[ton_idx:toff_idx] += self.holding_stimulus.step_amplitude
+
+        return {self.curr_output_key(): {"time": t, "current": current}}
+
+    @property
+    def stim_start(self):
+        """Time stimulus starts.
+
+        Returns:
+            time at which the sampled current starts (ms)
+        """
+        return self.sampled_stimulus.delay
+
+    @property
+    def stim_end(self):
+        """Time stimulus ends.
+
+        Returns:
+            time at which the sampled current ends (ms)
+        """
+        return self.sampled_stimulus.delay + self.sampled_stimulus.duration
+
+
+class SinusoidProtocol(SampledCurrentProtocol):
+    """Protocol consisting of a sinusoidal or chirp current and a holding current.
+
+    Attributes:
+        name (str): name of this object
+        stimuli (list of Stimuli): List of all Stimulus objects used in protocol
+        recordings (list of Recordings): Recording objects used in the protocol
+        cvode_active (bool): whether to use variable time step
+        sampled_stimulus (stimuli.Sinusoid): sinusoid or chirp Stimulus
+        holding_stimulus (Stimulus): Holding Stimulus
+    """
+
+
+class NoiseProtocol(SampledCurrentProtocol):
+    """Protocol consisting of an Ornstein-Uhlenbeck noise current and a holding current.
+
+    Attributes:
+        name (str): name of this object
+        stimuli (list of Stimuli): List of all Stimulus objects used in protocol
+        recordings (list of Recordings): Recording objects used in the protocol
+        cvode_active (bool): whether to use variable time step
+        sampled_stimulus (stimuli.OrnsteinUhlenbeck): noise Stimulus
+        holding_stimulus (Stimulus): Holding Stimulus
+    """
+
+    @property
+    def seed(self):
+        """Seed of the noise.
+
+        Returns:
+            int: seed of the random number generator of the noise
+        """
+        return self.sampled_stimulus.seed
+
+
+class WaveformProtocol(SampledCurrentProtocol):
+    """Protocol consisting of a current waveform read from a file and a holding current.
+
+    Attributes:
+        name (str): name of this object
+        stimuli (list of Stimuli): List of all Stimulus objects used in protocol
+        recordings (list of Recordings): Recording objects used in the protocol
+        cvode_active (bool): whether to use variable time step
+        sampled_stimulus (stimuli.Waveform): waveform Stimulus
+        holding_stimulus (Stimulus): Holding Stimulus
+    """
+
+
+class ConductanceClampProtocol(NeuronGlobalsMixin, ephys.protocols.SweepProtocol):
+    """Protocol injecting conductances, e.g. excitatory and inhibitory, at the soma.
+
+    The injected current depends on the voltage, and is not written with the currents
+    of the other protocols.
+
+    Attributes:
+        name (str): name of this object
+        stimuli (list of Stimuli): List of all Stimulus objects used in protocol
+        recordings (list of Recordings): Recording objects used in the protocol
+        cvode_active (bool): whether to use variable time step
+        conductance_stimuli (dict): stimuli.ConductanceClamp of each conductance name
+        holding_stimulus (Stimulus): Holding Stimulus
+    """
+
+    def __init__(
+        self,
+        name=None,
+        conductance_stimuli=None,
+        holding_stimulus=None,
+        recordings=None,
+        cvode_active=None,
+    ):
+        """Constructor.
+
+        Args:
+            name (str): name of this object
+            conductance_stimuli (dict): stimuli.ConductanceClamp
+                of each conductance name, e.g. 'exc' and 'inh'
+            holding_stimulus (Stimulus): Holding Stimulus
+            recordings (list of Recordings): Recording objects used in the
+                protocol
+            cvode_active (bool): whether to use variable time step
+        """
+        stimuli = list(conductance_stimuli.values())
+        if holding_stimulus is not None:
+            stimuli.append(holding_stimulus)
+        super().__init__(
+            name, stimuli=stimuli, recordings=recordings, cvode_active=cvode_active
+        )
+
+        self.conductance_stimuli = conductance_stimuli
+        self.holding_stimulus = holding_stimulus
+
+    def generate_current(self, threshold_current=None, holding_current=None, dt=0.1):
+        """Return an empty dictionary, the injected current depending on the voltage.
+
+        Args:
+            threshold_current (float): the threshold current (nA)
+            holding_current (float): the holding current (nA)
+            dt (float): timestep of the generated currents (ms)
+
+        Returns:
+            empty dict
+        """
+        # pylint: disable=unused-argument
+        return {}
+
+    @property
+    def stim_start(self):
+        """Time stimulus starts.
+
+        Returns:
+            time at which the first conductance starts (ms)
+        """
+        return min(stimulus.delay for stimulus in self.conductance_stimuli.values())
+
+    @property
+    def stim_end(self):
+        """Time stimulus ends.
+
+        Returns:
+            time at which the last conductance ends (ms)
+        """
+        return max(
+            stimulus.delay + stimulus.duration
+            for stimulus in self.conductance_stimuli.values()
+        )
+
+    @property
+    def seeds(self):
+        """Seeds of the noisy conductances.
+
+        Returns:
+            dict: seed of the random number generator of each
+                Ornstein-Uhlenbeck conductance name
+        """
+        return {
+            name: stimulus.conductance.seed
+            for name, stimulus in self.conductance_stimuli.items()
+            if hasattr(stimulus.conductance, "seed")
+        }
+
+
+class VoltageClampSweepProtocol(NeuronGlobalsMixin, ephys.protocols.SweepProtocol):
+    """Protocol clamping the soma at successive command voltages.
+
+    Attributes:
+        name (str): name of this object
+        stimuli (list of Stimuli): List of all Stimulus objects used in protocol
+        recordings (list of Recordings): Recording objects used in the protocol,
+            including the one of the clamp current
+        cvode_active (bool): whether to use variable time step
+        vclamp_stimulus (stimuli.VoltageClamp): voltage clamp Stimulus
+    """
+
+    def __init__(
+        self, name=None, vclamp_stimulus=None, recordings=None, cvode_active=None
+    ):
+        """Constructor.
+
+        Args:
+            name (str): name of this object
+            vclamp_stimulus (stimuli.VoltageClamp): voltage clamp Stimulus
+            recordings (list of Recordings): Recording objects used in the
+                protocol, including the one of the clamp current
+            cvode_active (bool): whether to use variable time step
+        """
+        super().__init__(
+            name,
+            stimuli=[vclamp_stimulus],
+            recordings=recordings,
+            cvode_active=cvode_active,
+        )
+
+        self.vclamp_stimulus = vclamp_stimulus
+
+
+class VoltageClampProtocol(ephys.protocols.Protocol):
+    """Series of voltage clamp sweeps, one per voltage of the swept step.
+
+    The peak clamp currents of the sweeps give the activation or inactivation
+    curve of the channels, see emodelrunner.voltage_clamp.
+
+    Attributes:
+        name (str): name of the protocol
+        sweep_protocols (list of VoltageClampSweepProtocol): one protocol per voltage
+        swept_step (int): index of the step whose voltage changes between sweeps
+        analysis (dict): type of curve ('activation' or 'inactivation'),
+            index of the 'step' in which the peak current is measured,
+            'reversal' potential (mV) and 'transient' (ms) skipped at the start
+            of the step. None if the curve is not analysed.
+    """
+
+    def __init__(self, name, sweep_protocols=None, swept_step=0, analysis=None):
+        """Constructor.
+
+        Args:
+            name (str): name of the protocol
+            sweep_protocols (list of VoltageClampSweepProtocol): one protocol
+                per voltage
+            swept_step (int): index of the step whose voltage changes between sweeps
+            analysis (dict): analysis of the peak currents, None if not analysed
+        """
+        super().__init__(name=name)
+        self.sweep_protocols = sweep_protocols or []
+        self.swept_step = swept_step
+        self.analysis = analysis
+
+    def subprotocols(self):
+        """Return subprotocols.
+
+        Returns:
+            dict containing the protocol and its sweep protocols
+        """
+        subprotocols = collections.OrderedDict({self.name: self})
+        for sweep_protocol in self.sweep_protocols:
+            subprotocols.update(sweep_protocol.subprotocols())
+
+        return subprotocols
+
+    def run(self, cell_model, param_values, sim=None, isolate=None, timeout=None):
+        """Run the sweep protocols.
+
+        Args:
+            cell_model (bluepyopt.ephys.models.CellModel): the cell model
+            param_values (dict): optimized parameters
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            isolate (bool): whether to isolate the run in a process with a timeout
+                to avoid bad cells running for too long
+            timeout (float): maximum real time (s) the cell is allowed to run when isolated
+
+        Returns:
+            dict containing the responses of all the sweeps
+        """
+        responses = collections.OrderedDict()
+        for sweep_protocol in self.sweep_protocols:
+            responses.update(
+                sweep_protocol.run(
+                    cell_model, param_values, sim=sim, isolate=isolate, timeout=timeout
+                )
+            )
+
+        return responses
+
+    def generate_current(self, threshold_current=None, holding_current=None, dt=0.1):
+        """Return no current, since the clamp current is recorded.
+
+        Args:
+            threshold_current (float): the threshold current (nA)
+            holding_current (float): the holding current (nA)
+            dt (float): timestep of the generated currents (ms)
+
+        Returns:
+            dict: empty
+        """
+        # pylint: disable=unused-argument
+        return {}
+
+    @property
+    def command_voltages(self):
+        """Voltage of the swept step of each sweep.
+
+        Returns:
+            list: the command voltages (mV)
+        """
+        return [
+            sweep_protocol.vclamp_stimulus.voltages[self.swept_step]
+            for sweep_protocol in self.sweep_protocols
+        ]
+
+
+class CurrentSearchProtocol(ephys.protocols.Protocol):
+    """Bisection search of the holding current and of the rheobase.
+
+    Pseudo code:
+
+    - Find the holding current bringing the cell to the holding voltage, if any
+    - Find the rheobase, i.e. the smallest step current eliciting a spike
+      on top of the holding current
+    - Set both currents to the cell model, so that the threshold-based protocols
+      run after this one use them
+
+    Attributes:
+        name (str): name of the protocol
+        step_protocol_template (StepProtocol): template for the search protocols
+            with amplitude for the holding and step stimuli to be filled
+        holding_voltage (float): voltage (mV) at which to hold the cell.
+            No holding current is searched if None.
+        holding_precision (float): precision (mV) with which to reach
+            the holding voltage
+        threshold_precision (float): precision (nA) of the rheobase
+        max_depth (int): maximum number of bisection steps of each search
+        max_holding_current (float): maximum absolute holding current (nA)
+        max_threshold_current (float): maximum rheobase (nA)
+        spike_threshold (float): voltage threshold for spike detection (mV)
+        prefix (str): prefix used in naming responses, features, recordings, etc.
+        holding_current (float): holding current (nA), set after the run
+        threshold_current (float): rheobase (nA), set after the run
+    """
+
+    initial_current = 0.1  # first bound (nA) of the searches, doubled if needed
+
+    def __init__(
+        self,
+        name,
+        step_protocol_template=None,
+        holding_voltage=None,
+        holding_precision=0.1,
+        threshold_precision=0.01,
+        max_depth=10,
+        max_holding_current=2.0,
+        max_threshold_current=2.0,
+        spike_threshold=-20.0,
+        prefix=None,
+    ):
+        """Constructor.
+
+        Args:
+            name (str): name of the protocol
+            step_protocol_template (StepProtocol): template for the search protocols
+                with amplitude for the holding and step stimuli to be filled
+            holding_voltage (float): voltage (mV) at which to hold the cell.
+                No holding current is searched if None.
+            holding_precision (float): precision (mV) with which to reach
+                the holding voltage
+            threshold_precision (float): precision (nA) of the rheobase
+            max_depth (int): maximum number of bisection steps of each search
+            max_holding_current (float): maximum absolute holding current (nA)
+            max_threshold_current (float): maximum rheobase (nA)
+            spike_threshold (float): voltage threshold for spike detection (mV)
+            prefix (str): prefix used in naming responses, features, recordings, etc.
+        """
+        # pylint: disable=too-many-arguments
+        super().__init__(name=name)
+        self.step_protocol_template = step_protocol_template
+        self.holding_voltage = holding_voltage
+        self.holding_precision = holding_precision
+        self.threshold_precision = threshold_precision
+        self.max_depth = max_depth
+        self.max_holding_current = max_holding_current
+        self.max_threshold_current = max_threshold_current
+        self.spike_threshold = spike_threshold
+
+        if prefix is None:
+            self.prefix = ""
+        else:
+            self.prefix = prefix + "."
+
+        # This will be set after the run()
+        self.holding_current = None
+        self.threshold_current = None
+
+    def subprotocols(self):
+        """Return subprotocols.
+
+        The step protocol template is not returned,
+        since its amplitudes only make sense during the search.
+
+        Returns:
+            dict containing the current search protocol
+        """
+        return collections.OrderedDict({self.name: self})
+
+    def run(self, cell_model, param_values, sim=None, isolate=None, timeout=None):
+        """Run protocol.
+
+        Args:
+            cell_model (bluepyopt.ephys.models.CellModel): the cell model
+            param_values (dict): optimized parameters
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            isolate (bool): whether to isolate the run in a process with a timeout
+                to avoid bad cells running for too long. Unused
+            timeout (float): duration in seconds after which the isolated run
+                is stopped. Unused
+
+        Raises:
+            RuntimeError: if the holding current or the rheobase is not found
+                below their maximum value
+
+        Returns:
+            dict containing the holding current and the rheobase
+        """
+        # pylint: disable=unused-argument
+        if self.holding_voltage is None:
+            holding_current = 0.0
+        else:
+            holding_current = self.search_holding_current(
+                cell_model, param_values, sim
+            )
+            if holding_current is None:
+                raise RuntimeError(
+                    f"{self.name}: no holding current below {self.max_holding_current}"
+                    f" nA holds the cell at {self.holding_voltage} mV."
+                )
+
+        threshold_current = self.search_threshold_current(
+            cell_model, param_values, sim, holding_current
+        )
+        if threshold_current is None:
+            raise RuntimeError(
+                f"{self.name}: the cell does not spike "
+                f"below {self.max_threshold_current} nA."
+            )
+
+        logger.info(
+            "%s: holding current %.6g nA, rheobase %.6g nA",
+            self.name,
+            holding_current,
+            threshold_current,
+        )
+
+        self.holding_current = holding_current
+        self.threshold_current = threshold_current
+        cell_model.holding_current = holding_current
+        cell_model.threshold_current = threshold_current
+
+        responses = collections.OrderedDict()
+        responses[self.prefix + "bpo_holding_current"] = holding_current
+        responses[self.prefix + "bpo_threshold_current"] = threshold_current
+
+        return responses
+
+    def create_step_protocol(self, holding_current=0.0, step_current=0.0):
+        """Create a search protocol.
+
+        Args:
+            holding_current (float): holding current amplitude (nA)
+            step_current (float): step current amplitude (nA)
+
+        Returns:
+            StepProtocol: the search protocol
+        """
+        step_protocol = copy.deepcopy(self.step_protocol_template)
+        step_protocol.holding_stimulus.step_amplitude = holding_current
+        for step_stim in step_protocol.step_stimuli:
+            step_stim.step_amplitude = step_current
+
+        return step_protocol
+
+    def run_soma_voltage(self, protocol, cell_model, param_values, sim):
+        """Run a search protocol and return its somatic voltage.
+
+        Args:
+            protocol (StepProtocol): the search protocol
+            cell_model (bluepyopt.ephys.models.CellModel): the cell model
+            param_values (dict): optimized parameters
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+
+        Returns:
+            tuple containing the time (ms) and the somatic voltage (mV)
+        """
+        responses = protocol.run(cell_model, param_values, sim=sim)
+        response = responses[protocol.recordings[0].name]
+        return np.asarray(response["time"]), np.asarray(response["voltage"])
+
+    def voltage_base(self, current, cell_model, param_values, sim):
+        """Compute the voltage reached with a holding current.
+
+        Only the part of the protocol before the step is run, and the voltage
+        is averaged over its last 10%, as the voltage_base efeature.
+
+        Args:
+            current (float): holding current amplitude (nA)
+            cell_model (bluepyopt.ephys.models.CellModel): the cell model
+            param_values (dict): optimized parameters
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+
+        Returns:
+            float: voltage base (mV)
+        """
+        protocol = self.create_step_protocol(holding_current=current)
+        stim_start = protocol.stim_start
+        for stimulus in protocol.stimuli:
+            stimulus.total_duration = stim_start
+        protocol.holding_stimulus.step_duration = stim_start
+
+        time, voltage = self.run_soma_voltage(protocol, cell_model, param_values, sim)
+        return float(np.mean(voltage[time >= 0.9 * stim_start]))
+
+    def detect_spike(
+        self, step_current, holding_current, cell_model, param_values, sim
+    ):
+        """Detect if a step current elicits a spike.
+
+        Args:
+            step_current (float): step current amplitude (nA)
+            holding_current (float): holding current amplitude (nA)
+            cell_model (bluepyopt.ephys.models.CellModel): the cell model
+            param_values (dict): optimized parameters
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+
+        Returns:
+            bool: True if at least one spike was detected during the step
+        """
+        # pylint: disable=too-many-arguments
+        protocol = self.create_step_protocol(holding_current, step_current)
+        time, voltage = self.run_soma_voltage(protocol, cell_model, param_values, sim)
+        spike_times = detect_spikes(time, voltage, self.spike_threshold)
+        return bool(
+            np.any(
+                (spike_times >= protocol.stim_start)
+                & (spike_times <= protocol.stim_end)
+            )
+        )
+
+    def search_holding_current(self, cell_model, param_values, sim):
+        """Find the holding current bringing the cell to the holding voltage.
+
+        The voltage increases with the holding current, so that the current is
+        bracketed by doubling the first bound, and then found by bisection.
+
+        Args:
+            cell_model (bluepyopt.ephys.models.CellModel): the cell model
+            param_values (dict): optimized parameters
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+
+        Returns:
+            float: the holding current (nA), or None if it is above max_holding_current
+        """
+        voltage = self.voltage_base(0.0, cell_model, param_values, sim)
+        if abs(voltage - self.holding_voltage) <= self.holding_precision:
+            return 0.0
+        direction = 1.0 if voltage < self.holding_voltage else -1.0
+
+        def distance(current):
+            """Signed distance to the holding voltage, positive beyond it."""
+            voltage = self.voltage_base(current, cell_model, param_values, sim)
+            return (voltage - self.holding_voltage) * direction
+
+        # bracket the holding current between inner and outer
+        inner = 0.0
+        outer = direction * min(self.initial_current, self.max_holding_current)
+        while distance(outer) < 0:
+            if abs(outer) >= self.max_holding_current:
+                return None
+            inner = outer
+            outer = direction * min(2 * abs(outer), self.max_holding_current)
+
+        for _ in range(self.max_depth):
+            middle = (inner + outer) / 2
+            middle_distance = distance(middle)
+            if abs(middle_distance) <= self.holding_precision:
+                return middle
+            if middle_distance < 0:
+                inner = middle
+            else:
+                outer = middle
+
+        return (inner + outer) / 2
+
+    def search_threshold_current(self, cell_model, param_values, sim, holding_current):
+        """Find the rheobase on top of the holding current.
+
+        The rheobase is bracketed by doubling the first bound,
+        and then found by bisection.
+
+        Args:
+            cell_model (bluepyopt.ephys.models.CellModel): the cell model
+            param_values (dict): optimized parameters
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            holding_current (float): holding current amplitude (nA)
+
+        Returns:
+            float: the rheobase (nA), or None if it is above max_threshold_current
+        """
+
+        def spikes(current):
+            """Whether the step current elicits a spike."""
+            return self.detect_spike(
+                current, holding_current, cell_model, param_values, sim
+            )
+
+        if spikes(0.0):
+            logger.warning("%s: the cell spikes without step current.", self.name)
+            return 0.0
+
+        lower = 0.0
+        upper = min(self.initial_current, self.max_threshold_current)
+        while not spikes(upper):
+            if upper >= self.max_threshold_current:
+                return None
+            lower = upper
+            upper = min(2 * upper, self.max_threshold_current)
+
+        depth = 0
+        while depth < self.max_depth and upper - lower > self.threshold_precision:
+            middle = (lower + upper) / 2
+            if spikes(middle):
+                upper = middle
+            else:
+                lower = middle
+            depth += 1
+
+        return upper
+
+    @staticmethod
+    def generate_current(threshold_current=None, holding_current=None, dt=0.1):
+        """Return an empty dictionary, the search currents are not written.
+
+        Args:
+            threshold_current (float): the threshold current (nA)
+            holding_current (float): the holding current (nA)
+            dt (float): timestep of the generated currents (ms)
+
+        Returns:
+            empty dict
+        """
+        # pylint: disable=unused-argument
+        return {}
+
+
+class SweepProtocolCustom(NeuronGlobalsMixin, ephys.protocols.SweepProtocol):
+    """SweepProtocol with generate_current method.
 
     Attributes:
         name (str): name of this object
@@ -1310,3 +2394,268 @@ class SweepProtocolCustom(ephys.protocols.SweepProtocol):
         """
         # pylint: disable=unused-argument
         return {}
+
+
+class ConditionedSynapseProtocol(SweepProtocolCustom, CurrentOutputKeyMixin):
+    """Synapse protocol with a holding current and a conditioning pre-step at the soma.
+
+    The conditioning step, of current or of voltage, starts at 0 ms and ends
+    before the synapses are activated. It can be removed from the voltage traces.
+
+    Attributes:
+        name (str): name of this object
+        stimuli (list of Stimuli): List of all Stimulus objects used in protocol
+        recordings (list of Recordings): Recording objects used in the protocol
+        cvode_active (bool): whether to use variable time step
+        synapse_stimuli (list of Stimuli): Stimulus objects activating the synapses
+        holding_stimulus (Stimulus): Holding Stimulus, lasting the whole protocol
+        conditioning_stimulus (Stimulus): current step or voltage clamp
+            of the conditioning
+        conditioning_duration (float): duration of the conditioning (ms)
+        exclude_conditioning (bool): whether to remove the conditioning
+            from the traces
+    """
+
+    def __init__(
+        self,
+        name=None,
+        synapse_stimuli=None,
+        holding_stimulus=None,
+        conditioning_stimulus=None,
+        conditioning_duration=0.0,
+        exclude_conditioning=False,
+        recordings=None,
+        cvode_active=None,
+    ):
+        """Constructor.
+
+        Args:
+            name (str): name of this object
+            synapse_stimuli (list of Stimuli): Stimulus objects activating
+                the synapses
+            holding_stimulus (Stimulus): Holding Stimulus, lasting the whole protocol
+            conditioning_stimulus (Stimulus): current step or voltage clamp
+                of the conditioning
+            conditioning_duration (float): duration of the conditioning (ms)
+            exclude_conditioning (bool): whether to remove the conditioning
+                from the traces
+            recordings (list of Recordings): Recording objects used in the
+                protocol
+            cvode_active (bool): whether to use variable time step
+        """
+        # pylint: disable=too-many-arguments
+        stimuli = list(synapse_stimuli)
+        for stimulus in [holding_stimulus, conditioning_stimulus]:
+            if stimulus is not None:
+                stimuli.append(stimulus)
+        super().__init__(
+            name, stimuli=stimuli, recordings=recordings, cvode_active=cvode_active
+        )
+
+        self.synapse_stimuli = synapse_stimuli
+        self.holding_stimulus = holding_stimulus
+        self.conditioning_stimulus = conditioning_stimulus
+        self.conditioning_duration = conditioning_duration
+        self.exclude_conditioning = exclude_conditioning
+
+    def run(self, cell_model, param_values, sim=None, isolate=None, timeout=None):
+        """Run protocol, removing the conditioning from the traces if excluded.
+
+        Args:
+            cell_model (bluepyopt.ephys.models.CellModel): the cell model
+            param_values (dict): optimized parameters
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            isolate (bool): whether to isolate the run in a process with a timeout
+                to avoid bad cells running for too long
+            timeout (float): maximum real time (s) the cell is allowed to run when isolated
+
+        Returns:
+            dict containing the responses of the protocol
+        """
+        responses = super().run(
+            cell_model, param_values, sim=sim, isolate=isolate, timeout=timeout
+        )
+        if self.exclude_conditioning:
+            for response in responses.values():
+                if isinstance(response, ephys.responses.TimeVoltageResponse):
+                    trace = response.response
+                    response.response = trace[
+                        trace["time"] >= self.conditioning_duration
+                    ].reset_index(drop=True)
+        return responses
+
+    def generate_current(self, threshold_current=None, holding_current=None, dt=0.1):
+        """Return the current injected by the holding and conditioning steps.
+
+        Args:
+            threshold_current (float): the threshold current (nA)
+            holding_current (float): the holding current (nA)
+            dt (float): timestep of the generated currents (ms)
+
+        Returns:
+            dict containing the generated current, empty if no current is injected
+        """
+        # pylint: disable=unused-argument
+        current_stimuli = [
+            stimulus
+            for stimulus in [self.holding_stimulus, self.conditioning_stimulus]
+            if isinstance(stimulus, ephys.stimuli.NrnSquarePulse)
+        ]
+        if not current_stimuli:
+            return {}
+
+        t = np.arange(0.0, self.total_duration, dt)
+        current = np.zeros(t.shape, dtype="float64")
+        for stimulus in current_stimuli:
+            ton_idx = int(stimulus.step_delay / dt)
+            toff_idx = int((stimulus.step_delay + stimulus.step_duration) / dt)
+            current[ton_idx:toff_idx] += stimulus.step_amplitude
+
+        if self.exclude_conditioning:
+            current = current[t >= self.conditioning_duration]
+            t = t[t >= self.conditioning_duration]
+        return {self.curr_output_key(): {"time": t, "current": current}}
+
+
+class ChainProtocol(ephys.protocols.Protocol):
+    """Sequence of protocols run back-to-back on the same instantiated cell.
+
+    The cell is instantiated once, and each phase starts at 0 ms from the state
+    of the cell at the end of the previous phase, i.e. its voltage and the STATE
+    variables of its mechanisms and synapses, e.g. the plasticity of GluSynapse.
+    Only the stimuli and recordings of the phases are used, not their own run.
+
+    Attributes:
+        name (str): name of the protocol
+        phase_protocols (list of bluepyopt.ephys.protocols.SweepProtocol):
+            protocol of each phase, in the order they are run
+        cvode_active (bool): whether to use variable time step
+    """
+
+    def __init__(self, name, phase_protocols=None, cvode_active=None):
+        """Constructor.
+
+        Args:
+            name (str): name of the protocol
+            phase_protocols (list of bluepyopt.ephys.protocols.SweepProtocol):
+                protocol of each phase, in the order they are run
+            cvode_active (bool): whether to use variable time step
+        """
+        super().__init__(name=name)
+        self.phase_protocols = phase_protocols or []
+        self.cvode_active = cvode_active
+
+    def subprotocols(self):
+        """Return subprotocols.
+
+        Returns:
+            dict containing the protocol and its phase protocols
+        """
+        subprotocols = collections.OrderedDict({self.name: self})
+        for phase_protocol in self.phase_protocols:
+            subprotocols.update(phase_protocol.subprotocols())
+
+        return subprotocols
+
+    @property
+    def total_duration(self):
+        """Total duration of the phases (ms)."""
+        return sum(phase.total_duration for phase in self.phase_protocols)
+
+    def run_phase(self, phase_protocol, sim, state=None):
+        """Initialize the simulation, set back the state of the cell and run a phase.
+
+        Args:
+            phase_protocol (bluepyopt.ephys.protocols.SweepProtocol): instantiated
+                protocol of the phase
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            state (list of tuples): state of the cell at the end of the previous
+                phase, as returned by get_cell_state. None for the first phase.
+        """
+        h = sim.neuron.h
+        cvode_active = bool(self.cvode_active)
+        h.tstop = phase_protocol.total_duration
+        h.cvode_active(int(cvode_active))
+        if not cvode_active:
+            h.dt = sim.dt
+            h.steps_per_ms = 1.0 / sim.dt
+        h.stdinit()
+        if state is not None:
+            set_cell_state(sim, state)
+        continue_run(sim, phase_protocol.total_duration, cvode_active=cvode_active)
+
+    def run(self, cell_model, param_values, sim=None, isolate=None, timeout=None):
+        """Run the phases one after the other, carrying over the state of the cell.
+
+        The phases are run in this process, whatever isolate.
+
+        Args:
+            cell_model (bluepyopt.ephys.models.CellModel): the cell model
+            param_values (dict): optimized parameters
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            isolate (bool): not used
+            timeout (float): not used
+
+        Returns:
+            dict containing the responses of all the phases,
+                None for the phases that were not run because of a simulation error
+        """
+        # pylint: disable=unused-argument
+        responses = collections.OrderedDict()
+        cell_model.freeze(param_values)
+        cell_model.instantiate(sim=sim)
+        try:
+            state = None
+            failed = False
+            for phase_protocol in self.phase_protocols:
+                phase_protocol.instantiate(sim=sim, icell=cell_model.icell)
+                try:
+                    if not failed:
+                        try:
+                            self.run_phase(phase_protocol, sim, state)
+                        except (RuntimeError, ephys.simulators.NrnSimulatorException):
+                            logger.debug(
+                                "ChainProtocol: Running phase %s generated an "
+                                "exception, returning None in the next responses",
+                                phase_protocol.name,
+                            )
+                            failed = True
+                        else:
+                            state = get_cell_state(sim, cell_model)
+                    responses.update(
+                        {
+                            recording.name: None if failed else recording.response
+                            for recording in phase_protocol.recordings
+                        }
+                    )
+                finally:
+                    phase_protocol.destroy(sim=sim)
+        finally:
+            cell_model.destroy(sim=sim)
+            cell_model.unfreeze(param_values.keys())
+
+        return responses
+
+    def generate_current(self, threshold_current=None, holding_current=None, dt=0.1):
+        """Return the current time series of each phase.
+
+        Args:
+            threshold_current (float): the threshold current (nA)
+            holding_current (float): the holding current (nA)
+            dt (float): timestep of the generated currents (ms)
+
+        Returns:
+            dict containing the generated currents
+        """
+        currents = {}
+        for phase_protocol in self.phase_protocols:
+            if hasattr(phase_protocol, "generate_current"):
+                currents.update(
+                    phase_protocol.generate_current(
+                        threshold_current=threshold_current,
+                        holding_current=holding_current,
+                        dt=dt,
+                    )
+                )
+
+        return currents
@@ -21,6 +21,15 @@ import traceback
 
 from bluepyopt import ephys
 
+from emodelrunner.checkpoint import (
+    get_checkpoint_times,
+    load_checkpoint_info,
+    remove_checkpoint,
+    restore_checkpoint,
+    save_checkpoint,
+)
+from emodelrunner.simulators import continue_run
+
 logger = logging.getLogger(__name__)
 
 
@@ -60,6 +69,9 @@ class SweepProtocolCustom(ephys.protocols.SweepProtocol):
         cvode_active (bool): whether to use variable time step
         fastforward (float): Time after which the synapses are fasforwarded.
             Leave None for no fastforward.
+        checkpoint_args (dict): 'interval' between the checkpoints (ms),
+            checkpoint 'dir' and whether to 'resume' from its checkpoint.
+            Leave None for no checkpoint.
     """
 
     def __init__(
@@ -69,6 +81,7 @@ class SweepProtocolCustom(ephys.protocols.SweepProtocol):
         recordings=None,
         cvode_active=None,
         fastforward=None,
+        checkpoint_args=None,
     ):
         """Constructor.
 
@@ -85,10 +98,100 @@ class SweepProtocolCustom(ephys.protocols.SweepProtocol):
             cvode_active (bool): whether to use variable time step
             fastforward (float): Time after which the synapses are fasforwarded.
                 Leave None for no fastforward.
+            checkpoint_args (dict): 'interval' between the checkpoints (ms),
+                checkpoint 'dir' and whether to 'resume' from its checkpoint.
+                Leave None for no checkpoint.
         """
         super().__init__(name, stimuli, recordings, cvode_active)
 
         self.fastforward = fastforward
+        self.checkpoint_args = checkpoint_args
+
+    def restore(self, sim):
+        """Initialize the simulation and restore the checkpoint, if any.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+
+        Raises:
+            ValueError: if the checkpoint was saved by a different protocol
+
+        Returns:
+            dict: information of the restored checkpoint, or None if there is none
+        """
+        checkpoint_dir = self.checkpoint_args["dir"]
+        info = load_checkpoint_info(checkpoint_dir)
+        if info is None:
+            logger.info("No checkpoint in %s, starting from 0 ms", checkpoint_dir)
+            return None
+        for key in ["total_duration", "fastforward"]:
+            if info[key] != getattr(self, key):
+                raise ValueError(
+                    f"The checkpoint in {checkpoint_dir} has a {key} of {info[key]} "
+                    f"instead of {getattr(self, key)}."
+                )
+
+        h = sim.neuron.h
+        h.tstop = self.total_duration
+        h.cvode_active(int(bool(self.cvode_active or info["fastforwarded"])))
+        if not h.cvode_active():
+            h.dt = sim.dt
+            h.steps_per_ms = 1.0 / sim.dt
+        h.stdinit()
+        return restore_checkpoint(sim, self.recordings, checkpoint_dir)
+
+    def run_with_checkpoints(self, cell_model, sim):
+        """Run the simulation, saving its state at regular intervals.
+
+        When resuming, the run starts from the last checkpoint.
+        The checkpoint is removed once the run is complete.
+
+        Args:
+            cell_model (bluepyopt.ephys.models.CellModel): the cell model
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        """
+        checkpoint_dir = self.checkpoint_args["dir"]
+        checkpoint_times = get_checkpoint_times(
+            self.total_duration, self.checkpoint_args["interval"]
+        )
+        stops = set(checkpoint_times + [self.total_duration])
+        if self.fastforward is not None:
+            stops.add(self.fastforward)
+        stops = sorted(stops)
+
+        info = self.restore(sim) if self.checkpoint_args["resume"] else None
+        if info is None:
+            time, last_saved, fastforwarded = stops[0], 0.0, False
+            sim.run(time, cvode_active=self.cvode_active)
+        else:
+            time = last_saved = info["time"]
+            fastforwarded = info["fastforwarded"]
+
+        while True:
+            if self.fastforward is not None and not fastforwarded:
+                if time >= self.fastforward:
+                    fastforward_synapses(cell_model)
+                    fastforwarded = True
+            if time >= self.total_duration:
+                break
+            if time in checkpoint_times and time > last_saved:
+                save_checkpoint(
+                    sim,
+                    self.recordings,
+                    checkpoint_dir,
+                    {
+                        "total_duration": self.total_duration,
+                        "fastforward": self.fastforward,
+                        "fastforwarded": fastforwarded,
+                    },
+                )
+                last_saved = time
+            time = min(stop for stop in stops if stop > time)
+            # as without checkpoints, the variable time step is used
+            # once the synapses are fast-forwarded
+            continue_run(sim, time, cvode_active=self.cvode_active or fastforwarded)
+
+        remove_checkpoint(checkpoint_dir)
 
     def _run_func(self, cell_model, param_values, sim=None):
         """Run protocols.
@@ -112,11 +215,12 @@ class SweepProtocolCustom(ephys.protocols.SweepProtocol):
             self.instantiate(sim=sim, icell=cell_model.icell)
 
             try:
-                if self.fastforward is not None:
+                if self.checkpoint_args is not None:
+                    self.run_with_checkpoints(cell_model, sim)
+                elif self.fastforward is not None:
                     sim.run(self.fastforward, cvode_active=self.cvode_active)
                     fastforward_synapses(cell_model)
-                    sim.neuron.h.cvode_active(1)
-                    sim.neuron.h.continuerun(self.total_duration)
+                    continue_run(sim, self.total_duration)
                 else:
                     sim.run(self.total_duration, cvode_active=self.cvode_active)
             except (RuntimeError, ephys.simulators.NrnSimulatorException):
@@ -267,8 +371,7 @@ class SweepProtocolPairSim(ephys.protocols.Protocol):
                     sim.run(self.fastforward, cvode_active=self.cvode_active)
                     fastforward_synapses(precell_model)
                     fastforward_synapses(postcell_model)
-                    sim.neuron.h.cvode_active(1)
-                    sim.neuron.h.continuerun(self.total_duration)
+                    continue_run(sim, self.total_duration)
                 else:
                     sim.run(self.total_duration, cvode_active=self.cvode_active)
             except (RuntimeError, ephys.simulators.NrnSimulatorException):
@@ -22,7 +22,10 @@ import logging
 import numpy as np
 from bluepyopt import ephys
 
-from emodelrunner.protocols.protocols_func import CurrentOutputKeyMixin
+from emodelrunner.protocols.protocols_func import (
+    CurrentOutputKeyMixin,
+    NeuronGlobalsMixin,
+)
 
 logger = logging.getLogger(__name__)
 
@@ -116,7 +119,7 @@ class RatSSCxMainProtocol(ephys.protocols.Protocol):
         """Set rin_efeature."""
         self.rinhold_protocol_hyp.rin_efeature_hyp = value
 
-    def run(self, cell_model, param_values, sim=None, isolate=None):
+    def run(self, cell_model, param_values, sim=None, isolate=None, timeout=None):
         """Run protocol."""
         # pylint: disable=unused-argument
         responses = collections.OrderedDict()
@@ -813,7 +816,9 @@ class RatSSCxThresholdDetectionProtocol(ephys.protocols.Protocol):
         return threshold_current
 
 
-class StepProtocolCustom(ephys.protocols.StepProtocol, CurrentOutputKeyMixin):
+class StepProtocolCustom(
+    NeuronGlobalsMixin, ephys.protocols.StepProtocol, CurrentOutputKeyMixin
+):
     """Step protocol with custom options to turn stochkv_det on or off."""
 
     def __init__(
@@ -840,9 +845,11 @@ class StepProtocolCustom(ephys.protocols.StepProtocol, CurrentOutputKeyMixin):
         """Run protocol."""
         responses = {}
 
+        deterministic_copy = {}
         if self.stochkv_det is not None and not self.stochkv_det:
             for mechanism in cell_model.mechanisms:
                 if "Stoch" in mechanism.prefix:
+                    deterministic_copy[mechanism.name] = mechanism.deterministic
                     mechanism.deterministic = False
             self.cvode_active = False
 
@@ -855,7 +862,7 @@ class StepProtocolCustom(ephys.protocols.StepProtocol, CurrentOutputKeyMixin):
         if self.stochkv_det is not None and not self.stochkv_det:
             for mechanism in cell_model.mechanisms:
                 if "Stoch" in mechanism.prefix:
-                    mechanism.deterministic = True
+                    mechanism.deterministic = deterministic_copy[mechanism.name]
             self.cvode_active = True
 
         return responses
@@ -967,9 +974,11 @@ class StepThresholdProtocol(StepProtocolCustom):
                 float(self.thresh_perc) / 100
             )
 
+        deterministic_copy = {}
         if self.stochkv_det is not None and not self.stochkv_det:
             for mechanism in cell_model.mechanisms:
                 if "Stoch" in mechanism.prefix:
+                    deterministic_copy[mechanism.name] = mechanism.deterministic
                     mechanism.deterministic = False
             self.cvode_active = False
 
@@ -982,7 +991,7 @@ class StepThresholdProtocol(StepProtocolCustom):
         if self.stochkv_det is not None and not self.stochkv_det:
             for mechanism in cell_model.mechanisms:
                 if "Stoch" in mechanism.prefix:
-                    mechanism.deterministic = True
+                    mechanism.deterministic = deterministic_copy[mechanism.name]
             self.cvode_active = True
 
         return responses
@@ -1016,3 +1025,153 @@ class StepThresholdProtocol(StepProtocolCustom):
             )
 
         return {self.curr_output_key(): {"time": t, "current": current}}
+
+
+class BurstProtocol(
+    NeuronGlobalsMixin, ephys.protocols.SweepProtocol, CurrentOutputKeyMixin
+):
+    """Low-threshold burst protocol of the thalamic cells.
+
+    A hyperpolarizing pre-step de-inactivates the T-type calcium channels.
+    It is followed by a depolarizing step evoking a low-threshold burst,
+    or by the release of the current, evoking a rebound burst if there is no step.
+
+    Attributes:
+        hyperpolarization_stimulus (ephys.stimuli.NrnSquarePulse): pre-step
+        step_stimulus (ephys.stimuli.NrnSquarePulse): depolarizing step starting at
+            the end of the pre-step. None for a rebound burst.
+        holding_stimulus (ephys.stimuli.NrnSquarePulse): holding current.
+            Its amplitude is the holding current of the cell if it is None.
+        burst_window (float): duration after the release in which the burst
+            is analysed (ms)
+    """
+
+    def __init__(
+        self,
+        name,
+        hyperpolarization_stimulus,
+        step_stimulus=None,
+        holding_stimulus=None,
+        recordings=None,
+        burst_window=100.0,
+        cvode_active=None,
+    ):
+        """Constructor.
+
+        Args:
+            name (str): name of the protocol
+            hyperpolarization_stimulus (ephys.stimuli.NrnSquarePulse): pre-step
+            step_stimulus (ephys.stimuli.NrnSquarePulse): depolarizing step,
+                None for a rebound burst
+            holding_stimulus (ephys.stimuli.NrnSquarePulse): holding current
+            recordings (list of ephys.recordings.CompRecording): recordings
+            burst_window (float): duration after the release in which
+                the burst is analysed (ms)
+            cvode_active (bool): whether to use the variable time step
+        """
+        # pylint: disable=too-many-arguments
+        stimuli = [hyperpolarization_stimulus]
+        for stimulus in [step_stimulus, holding_stimulus]:
+            if stimulus is not None:
+                stimuli.append(stimulus)
+        super().__init__(
+            name, stimuli=stimuli, recordings=recordings, cvode_active=cvode_active
+        )
+        self.hyperpolarization_stimulus = hyperpolarization_stimulus
+        self.step_stimulus = step_stimulus
+        self.holding_stimulus = holding_stimulus
+        self.burst_window = burst_window
+        self.use_cell_holding_current = (
+            holding_stimulus is not None and holding_stimulus.step_amplitude is None
+        )
+
+    def run(self, cell_model, param_values, sim=None, isolate=None, timeout=None):
+        """Run protocol, with the holding current of the cell if none is given."""
+        logger.info("Running protocol %s", self.name)
+        if self.use_cell_holding_current:
+            if self.name.endswith("_hyp"):
+                self.holding_stimulus.step_amplitude = cell_model.holding_current_hyp
+            else:
+                self.holding_stimulus.step_amplitude = cell_model.holding_current_dep
+
+        return super().run(
+            cell_model, param_values, sim=sim, isolate=isolate, timeout=timeout
+        )
+
+    @property
+    def burst_type(self):
+        """Type of burst.
+
+        Returns:
+            str: 'low_threshold' with a depolarizing step, 'rebound' otherwise
+        """
+        return "rebound" if self.step_stimulus is None else "low_threshold"
+
+    @property
+    def release_time(self):
+        """Time at which the hyperpolarizing pre-step ends.
+
+        Returns:
+            float: the end of the pre-step (ms)
+        """
+        return (
+            self.hyperpolarization_stimulus.step_delay
+            + self.hyperpolarization_stimulus.step_duration
+        )
+
+    @property
+    def stim_start(self):
+        """Time stimulus starts.
+
+        Returns:
+            the time at which the hyperpolarizing pre-step starts (ms)
+        """
+        return self.hyperpolarization_stimulus.step_delay
+
+    @property
+    def stim_end(self):
+        """Time stimulus ends.
+
+        Returns:
+            the end of the burst window (ms), within the protocol
+        """
+        return min(
+            self.release_time + self.burst_window,
+            self.hyperpolarization_stimulus.total_duration,
+        )
+
+    def generate_current(self, threshold_current=None, holding_current=None, dt=0.1):
+        """Return current time series.
+
+        Args:
+            threshold_current (float): the threshold current (nA). Not used.
+            holding_current (float): the holding current of the cell (nA),
+                used if the holding amplitude is not given
+            dt (float): timestep of the generated currents (ms)
+
+        Returns:
+            dict containing the generated current
+        """
+        # pylint: disable=unused-argument
+        total_duration = self.hyperpolarization_stimulus.total_duration
+
+        t = np.arange(0.0, total_duration + dt, dt)
+        current = np.zeros(t.shape, dtype="float64")
+        if self.holding_stimulus is not None:
+            holding_amplitude = self.holding_stimulus.step_amplitude
+            if self.use_cell_holding_current and holding_current is not None:
+                holding_amplitude = holding_current
+            ton_idx = int(self.holding_stimulus.step_delay / dt)
+            toff_idx = int(
+                (self.holding_stimulus.step_delay + self.holding_stimulus.step_duration)
+                / dt
+            )
+            current[ton_idx:toff_idx] += holding_amplitude or 0.0
+
+        for stimulus in [self.hyperpolarization_stimulus, self.step_stimulus]:
+            if stimulus is not None:
+                ton_idx = int(stimulus.step_delay / dt)
+                toff_idx = int((stimulus.step_delay + stimulus.step_duration) / dt)
+                current[ton_idx:toff_idx] += stimulus.step_amplitude
+
+        return {self.curr_output_key(): {"time": t, "current": current}}
@@ -0,0 +1,215 @@
+"""Provenance of the runs, for their reproducibility."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import hashlib
+import json
+import logging
+import platform
+import subprocess
+from datetime import datetime, timezone
+from pathlib import Path
+
+import emodelrunner
+from emodelrunner.json_utilities import NpEncoder
+from emodelrunner.seeds import CONFIG_SEED_KEYS
+
+logger = logging.getLogger(__name__)
+
+
+def get_neuron_version():
+    """Return the NEURON version.
+
+    Returns:
+        str: NEURON version, or None if NEURON cannot be imported
+    """
+    try:
+        # pylint: disable=import-outside-toplevel
+        import neuron
+    except ImportError:
+        return None
+    return neuron.__version__
+
+
+def get_git_sha(repo_dir):
+    """Return the checked out commit of a git repository.
+
+    Args:
+        repo_dir (str or Path): root directory of the repository
+
+    Returns:
+        str: SHA of the checked out commit, or None if it cannot be found
+    """
+    try:
+        result = subprocess.run(
+            ["git", "rev-parse", "HEAD"],
+            cwd=repo_dir,
+            capture_output=True,
+            text=True,
+            check=True,
+        )
+    except (OSError, subprocess.CalledProcessError):
+        return None
+    return result.stdout.strip()
+
+
+def get_emodelrunner_git_sha():
+    """Return the commit of EModelRunner when it is installed from a git repository.
+
+    Returns:
+        str: SHA of the checked out commit, or None if not installed from a git repository
+    """
+    source_dir = Path(emodelrunner.__file__).resolve().parent.parent
+    if not (source_dir / ".git").exists():
+        return None
+    return get_git_sha(source_dir)
+
+
+def get_mod_file_hashes(mechanisms_dir):
+    """Return the sha256 of each mod file of a directory.
+
+    Args:
+        mechanisms_dir (str or Path): directory containing the mod files
+
+    Returns:
+        dict: sha256 of each mod file, keyed by file name. Empty if there is no mod file.
+    """
+    return {
+        mod_file.name: hashlib.sha256(mod_file.read_bytes()).hexdigest()
+        for mod_file in sorted(Path(mechanisms_dir).glob("*.mod"))
+    }
+
+
+def get_file_hash(path):
+    """Return the sha256 of a file.
+
+    Args:
+        path (str or Path): path to the file
+
+    Returns:
+        str: sha256 of the file, or None if it is not a file, e.g. a morphology directory
+    """
+    path = Path(path)
+    if not path.is_file():
+        return None
+    return hashlib.sha256(path.read_bytes()).hexdigest()
+
+
+def get_morphology_hashes(config):
+    """Return the sha256 of the morphology files of the config.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        dict: sha256 of each morphology file, keyed by its path in the config
+    """
+    morph_paths = [
+        config.get("Paths", key, fallback="")
+        for key in ["morph_path", "precell_morph_path"]
+    ]
+    return {path: get_file_hash(path) for path in morph_paths if path}
+
+
+def get_seeds(config):
+    """Return the seeds of the random number generators set in the config.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        dict: seeds keyed by 'section.key', derived from 'Sim.seed' if it is set.
+            The noise stimuli seeds are set by the protocols.
+    """
+    return {
+        f"{section}.{key}": config.getint(section, key)
+        for section, key in [("Sim", "seed")] + CONFIG_SEED_KEYS
+        if config.get(section, key, fallback="")
+    }
+
+
+def get_normalized_config(config):
+    """Return the config as a dict, with the default values and the interpolations resolved.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        dict: values of each section
+    """
+    return {section: dict(config.items(section)) for section in config.sections()}
+
+
+def get_config_hash(config):
+    """Return the sha256 of the normalized config.
+
+    The hash does not depend on the order of the sections and keys in the config file.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        str: sha256 of the normalized config
+    """
+    normalized_config = json.dumps(get_normalized_config(config), sort_keys=True)
+    return hashlib.sha256(normalized_config.encode("utf-8")).hexdigest()
+
+
+def get_provenance(config):
+    """Return the provenance of a run.
+
+    The mod files are read from the mechanisms directory of the cell package.
+
+    Args:
+        config (configparser.ConfigParser): configuration of the run
+
+    Returns:
+        dict: versions of NEURON and EModelRunner, git SHA of EModelRunner
+            if it is installed from a git repository, platform, hostname,
+            mod file and morphology hashes, seeds, and normalized config with its hash
+    """
+    package_dir = Path(config.get("Paths", "memodel_dir", fallback="."))
+    return {
+        "date": datetime.now(timezone.utc).isoformat(),
+        "emodelrunner_version": getattr(emodelrunner, "__version__", None),
+        "emodelrunner_git_sha": get_emodelrunner_git_sha(),
+        "neuron_version": get_neuron_version(),
+        "python_version": platform.python_version(),
+        "platform": platform.platform(),
+        "hostname": platform.node(),
+        "mod_files": get_mod_file_hashes(package_dir / "mechanisms"),
+        "morphology_files": get_morphology_hashes(config),
+        "seeds": get_seeds(config),
+        "config_hash": get_config_hash(config),
+        "config": get_normalized_config(config),
+    }
+
+
+def write_provenance(provenance, output_path, wall_time=None):
+    """Write the provenance of a run in a json file.
+
+    Args:
+        provenance (dict): provenance of the run, as returned by get_provenance
+        output_path (str or Path): path to the json file to write
+        wall_time (float): duration of the run (s). Not written if None.
+    """
+    if wall_time is not None:
+        provenance = {**provenance, "wall_time": wall_time}
+
+    output_path = Path(output_path)
+    output_path.parent.mkdir(parents=True, exist_ok=True)
+    with open(output_path, "w", encoding="utf-8") as output_file:
+        json.dump(provenance, output_file, indent=4, cls=NpEncoder)
+    logger.info("Provenance written in %s", output_path)
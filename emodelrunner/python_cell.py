@@ -0,0 +1,238 @@
+"""Cell instantiation through the NEURON python API, without hoc template."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import logging
+
+from emodelrunner.cell import CellModelCustom
+
+logger = logging.getLogger(__name__)
+
+SECLIST_NAMES = ["all", "somatic", "basal", "apical", "axonal", "myelinated"]
+SECARRAY_NAMES = ["soma", "dend", "apic", "axon", "myelin"]
+
+# section array and section list of each neurite type
+NEURITE_SECTIONS = {
+    "axon": ("axon", "axonal"),
+    "basal_dendrite": ("dend", "basal"),
+    "apical_dendrite": ("apic", "apical"),
+}
+
+
+class PythonCell:
+    """Cell built with python sections, with the interface of the hoc cell template.
+
+    Attributes:
+        name (str): name of the cell
+        gid (int): cell's ID
+        h (neuron.hoc.HocObject): neuron hoc interpreter, used to create the sections
+        soma, dend, apic, axon, myelin (list of neuron Section): section arrays
+        all, somatic, basal, apical, axonal, myelinated (neuron SectionList):
+            section lists
+    """
+
+    def __init__(self, name, sim, gid=0):
+        """Constructor.
+
+        Args:
+            name (str): name of the cell
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            gid (int): cell's ID
+        """
+        self.name = name
+        self.gid = gid
+        self.h = sim.neuron.h
+
+        for secarray_name in SECARRAY_NAMES:
+            setattr(self, secarray_name, [])
+        for seclist_name in SECLIST_NAMES:
+            setattr(self, seclist_name, self.h.SectionList())
+
+    def __str__(self):
+        """String representation, used as prefix of the section names."""
+        return self.name
+
+    def create_section(self, secarray_name):
+        """Create a section and append it to a section array.
+
+        Args:
+            secarray_name (str): name of the section array, e.g. 'dend'
+
+        Returns:
+            neuron Section: the new section, named e.g. 'dend[3]'
+        """
+        secarray = getattr(self, secarray_name)
+        section = self.h.Section(name=f"{secarray_name}[{len(secarray)}]", cell=self)
+        secarray.append(section)
+        return section
+
+    def create_section_array(self, secarray_name, size):
+        """Replace a section array by new sections, as 'create axon[2]' does in hoc.
+
+        Args:
+            secarray_name (str): name of the section array, e.g. 'axon'
+            size (int): number of sections
+        """
+        setattr(self, secarray_name, [])
+        for _ in range(size):
+            self.create_section(secarray_name)
+
+    def getCell(self):
+        """Return the cell, as the hoc template does.
+
+        Returns:
+            PythonCell: this cell
+        """
+        # pylint: disable=invalid-name
+        return self
+
+    def connect2target(self, target, netcon):
+        """Connect the soma voltage to a target, as the hoc template does.
+
+        Args:
+            target (neuron point process): NetCon target (can be None)
+            netcon (neuron ref): reference in which the NetCon is put
+        """
+        soma = self.soma[0]
+        netcon[0] = self.h.NetCon(soma(1)._ref_v, target, sec=soma)
+        netcon[0].threshold = -30
+
+    def delete_sections(self):
+        """Delete the sections of the cell.
+
+        The python sections are deleted when they are not referenced anymore.
+        """
+        for secarray_name in SECARRAY_NAMES:
+            setattr(self, secarray_name, [])
+        for seclist_name in SECLIST_NAMES:
+            setattr(self, seclist_name, self.h.SectionList())
+
+
+def load_morphology(sim, icell, morphology_path):
+    """Create the sections of a morphology file in a python cell.
+
+    The soma is a cylinder along y, of length and diameter twice the soma radius.
+    The neurites are connected at the middle of the soma, and their sections are
+    numbered in depth-first order, neurite by neurite.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        icell (PythonCell): cell in which to create the sections
+        morphology_path (str): path to the morphology file
+
+    Raises:
+        ValueError: if a neurite is neither an axon, a basal nor an apical dendrite
+    """
+    # pylint: disable=import-outside-toplevel
+    import neurom
+
+    morph = neurom.load_morphology(morphology_path)
+
+    soma = icell.create_section("soma")
+    x, y, z = morph.soma.center
+    diameter = 2 * morph.soma.radius
+    for dy in [-morph.soma.radius, 0, morph.soma.radius]:
+        sim.neuron.h.pt3dadd(x, y + dy, z, diameter, sec=soma)
+    icell.somatic.append(sec=soma)
+    icell.all.append(sec=soma)
+
+    for neurite in morph.neurites:
+        if neurite.type.name not in NEURITE_SECTIONS:
+            raise ValueError(
+                f"Unsupported neurite type in {morphology_path}: {neurite.type.name}"
+            )
+        secarray_name, seclist_name = NEURITE_SECTIONS[neurite.type.name]
+        seclist = getattr(icell, seclist_name)
+        sections = {}
+        for morph_section in neurite.root_node.ipreorder():
+            section = icell.create_section(secarray_name)
+            for point in morph_section.points:
+                sim.neuron.h.pt3dadd(
+                    point[0], point[1], point[2], 2 * point[3], sec=section
+                )
+
+            if morph_section.parent is None:
+                section.connect(soma(0.5), 0.0)
+            else:
+                section.connect(sections[morph_section.parent.id](1.0), 0.0)
+            sections[morph_section.id] = section
+
+            seclist.append(sec=section)
+            icell.all.append(sec=section)
+
+    logger.debug("Loaded %s with %d sections", morphology_path, len(list(icell.all)))
+
+
+def instantiate_morphology(sim, icell, morphology):
+    """Instantiate a morphology in a python cell, as NrnFileMorphology does with hoc.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        icell (PythonCell): cell in which to create the sections
+        morphology (bluepyopt.ephys.morphologies.NrnFileMorphology): the morphology,
+            with its axon replacement and morphology modifiers
+    """
+    load_morphology(sim, icell, morphology.morphology_path)
+
+    if morphology.do_set_nseg:
+        morphology.set_nseg(icell)
+
+    if morphology.do_replace_axon:
+        morphology.replace_axon(sim=sim, icell=icell)
+
+    if morphology.morph_modifiers is not None:
+        for morph_modifier in morphology.morph_modifiers:
+            morph_modifier(sim=sim, icell=icell)
+
+
+class PythonCellModel(CellModelCustom):
+    """Cell model instantiated through the NEURON python API.
+
+    No hoc template is generated nor loaded: the sections are python sections
+    created from the morphology file, and the mechanisms and parameters
+    are instantiated on them as with the hoc template.
+    The hoc files of the cell can still be created with create_custom_hoc.
+    """
+
+    def instantiate(self, sim=None):
+        """Instantiate model in simulator.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        """
+        sim.neuron.h.load_file("stdrun.hoc")
+
+        self.icell = PythonCell(self.name, sim, gid=self.gid)
+
+        instantiate_morphology(sim, self.icell, self.morphology)
+
+        if self.mechanisms is not None:
+            for mechanism in self.mechanisms:
+                mechanism.instantiate(sim=sim, icell=self.icell)
+        if self.params is not None:
+            for param in self.params.values():
+                param.instantiate(sim=sim, icell=self.icell)
+
+        self.fix_hyperpolarization()
+
+    def destroy(self, sim=None):
+        """Destroy instantiated model in simulator.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        """
+        if self.icell is not None:
+            self.icell.delete_sections()
+        super().destroy(sim=sim)
@@ -15,29 +15,247 @@
 # limitations under the License.
 
 import logging
+import re
 
+import numpy as np
 from bluepyopt import ephys
 
+from emodelrunner.units import NONSPECIFIC_CURRENTS
+
 logger = logging.getLogger(__name__)
 
+DEFAULT_RECORD_DT = 0.1
+
+# e.g. 'v', 'cai', 'm_NaTg' or 'NaTg.m' for the state m of the NaTg mechanism
+RECORDING_VARIABLE_PATTERN = re.compile(
+    r"^((?P<mechanism>[A-Za-z_]\w*)\.)?(?P<variable>[A-Za-z_]\w*)$"
+)
+
+# recorded variable standing for all the ionic currents of a location
+CURRENTS_VARIABLE = "currents"
+
+
+def get_variable_name(variable):
+    """Return the NEURON name of a recorded range variable.
+
+    The variables of a mechanism can be given as 'mechanism.variable',
+    e.g. 'NaTg.m', and are named 'variable_mechanism', e.g. 'm_NaTg', as in NEURON,
+    so that the names of the outputs do not contain dots.
+
+    Args:
+        variable (str): recorded variable, e.g. 'v', 'cai', 'm_NaTg' or 'NaTg.m'
+
+    Raises:
+        ValueError: if the variable is not a valid name
+
+    Returns:
+        str: NEURON name of the range variable, e.g. 'm_NaTg'
+    """
+    match = RECORDING_VARIABLE_PATTERN.match(variable)
+    if match is None:
+        raise ValueError(
+            f"Invalid recorded variable: {variable}. Expected e.g. 'cai' or 'NaTg.m'."
+        )
+    if match["mechanism"] is None:
+        return variable
+    return f"{match['variable']}_{match['mechanism']}"
+
+
+def get_variable_ref(seg, variable):
+    """Return the reference to a range variable of a segment.
+
+    Args:
+        seg (neuron Segment): the recorded segment
+        variable (str): recorded variable, e.g. 'v', 'cai', 'm_NaTg' or 'NaTg.m'
+
+    Raises:
+        ValueError: if the segment does not have the variable, e.g. the concentration
+            of an ion that no mechanism of the section uses
+
+    Returns:
+        neuron reference: reference to the variable, to be recorded
+    """
+    name = get_variable_name(variable)
+    try:
+        return getattr(seg, f"_ref_{name}")
+    except (AttributeError, NameError) as exc:
+        raise ValueError(
+            f"Cannot record {variable} at {seg}: there is no {name} at this location. "
+            "The ion concentrations exist only where a mechanism uses the ion, "
+            "and the mechanism variables only where the mechanism is inserted."
+        ) from exc
+
+
+def get_assigned_names(sim, mechanism_name):
+    """Return the names of the ASSIGNED variables of a mechanism.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        mechanism_name (str): name of the density mechanism, e.g. 'NaTg'
+
+    Returns:
+        list of str: names of the ASSIGNED variables, suffixed with the mechanism
+            name, e.g. 'ina_NaTg'
+    """
+    standard = sim.neuron.h.MechanismStandard(mechanism_name, 2)
+    name = sim.neuron.h.ref("")
+    names = []
+    for i in range(int(standard.count())):
+        standard.name(name, i)
+        names.append(name[0])
+    return names
+
+
+def get_segment_currents(sim, seg):
+    """Return the names of the ionic currents of a segment.
+
+    The currents are the total current of each ion written by a mechanism,
+    e.g. 'ina', the current of each mechanism, i.e. its ion currents, e.g. 'ina_NaTg',
+    and its nonspecific current, e.g. 'ihcn_Ih' or 'i_pas', and the capacitive
+    current 'i_cap'.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        seg (neuron Segment): the recorded segment
+
+    Returns:
+        list of str: NEURON names of the currents
+    """
+    ions = []
+    mechanism_currents = []
+    for mechanism in seg:
+        mechanism_name = mechanism.name()
+        suffix = f"_{mechanism_name}"
+        for name in get_assigned_names(sim, mechanism_name):
+            # e.g. 'ik' for 'ik_SKv3_1'
+            current = name[: -len(suffix)]
+            if not name.endswith(suffix) or not current.startswith("i"):
+                continue
+            ion = current[1:]
+            if sim.neuron.h.ismembrane(f"{ion}_ion", sec=seg.sec):
+                ions.append(ion)
+                mechanism_currents.append(name)
+            elif current in NONSPECIFIC_CURRENTS:
+                mechanism_currents.append(name)
+    # dict keys, to keep each ion once, in order
+    return [f"i{ion}" for ion in dict.fromkeys(ions)] + mechanism_currents + ["i_cap"]
+
+
+def check_sampling(record_dt=None, record_every=None):
+    """Check the sampling of a recording.
+
+    Args:
+        record_dt (float): interval between the recorded samples (ms)
+        record_every (int): number of integration steps between the recorded samples
+
+    Raises:
+        ValueError: if both record_dt and record_every are given, or if one is
+            not positive
+    """
+    if record_dt is not None and record_every is not None:
+        raise ValueError("Give either record_dt or record_every, not both.")
+    if record_dt is not None and record_dt <= 0:
+        raise ValueError(f"record_dt should be positive, got {record_dt}.")
+    if record_every is not None and (
+        int(record_every) != record_every or record_every < 1
+    ):
+        raise ValueError(
+            f"record_every should be a positive integer, got {record_every}."
+        )
+
+
+def get_sampling_interval(sim, record_dt=None, record_every=None):
+    """Return the interval between the recorded samples.
+
+    With the variable time step, record_every is a number of steps of sim.dt.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        record_dt (float): interval between the recorded samples (ms)
+        record_every (int): number of integration steps between the recorded samples
+
+    Returns:
+        float: interval between the recorded samples (ms),
+            or None if the variable is recorded at every step
+    """
+    if record_every is not None:
+        return record_every * sim.dt
+    return record_dt
+
+
+def record_variable(sim, ref, interval=None):
+    """Return a neuron Vector recording a variable.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        ref (neuron reference): reference to the recorded variable, e.g. seg._ref_v
+        interval (float): interval between the recorded samples (ms).
+            If None, the variable is recorded at every step.
+
+    Returns:
+        neuron Vector: the vector recording the variable
+    """
+    vector = sim.neuron.h.Vector()
+    if interval is None:
+        vector.record(ref)
+    else:
+        vector.record(ref, interval)
+    return vector
+
 
 class RecordingCustom(ephys.recordings.CompRecording):
-    """Response to stimulus with recording every 0.1 ms.
+    """Response to stimulus with recording every 0.1 ms by default.
 
     Attributes:
         name (str): name of this object
         location (Location): location in the model of the recording
         variable (str): which variable to record from (e.g. 'v')
+        record_dt (float): interval between the recorded samples (ms)
+        record_every (int): number of integration steps between the recorded
+            samples, None if the samples are taken every record_dt
         varvector (neuron Vector): vector recording the variable
         tvector (neuron Vector): vector recording the time (ms)
         instantiated (bool): whether the object has been instantiated or not
+    """
 
-    Args of the parent constructor:
+    def __init__(
+        self,
+        name=None,
+        location=None,
+        variable="v",
+        record_dt=None,
+        record_every=None,
+    ):
+        """Constructor.
 
-    - name (str): name of this object
-    - location (Location): location in the model of the recording
-    - variable (str): which variable to record from (e.g. 'v')
-    """
+        Args:
+            name (str): name of this object
+            location (Location): location in the model of the recording
+            variable (str): which variable to record from (e.g. 'v')
+            record_dt (float): interval between the recorded samples (ms)
+            record_every (int): number of integration steps between the
+                recorded samples. The variable is recorded every 0.1 ms
+                if both are None.
+        """
+        # pylint: disable=too-many-arguments
+        super().__init__(name=name, location=location, variable=variable)
+        check_sampling(record_dt, record_every)
+        if record_dt is None and record_every is None:
+            record_dt = DEFAULT_RECORD_DT
+        self.record_dt = record_dt
+        self.record_every = record_every
+
+    def record_time(self, sim):
+        """Record the time at the samples of the recording.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        """
+        self.tvector = record_variable(
+            sim,
+            sim.neuron.h._ref_t,  # pylint: disable=protected-access
+            get_sampling_interval(sim, self.record_dt, self.record_every),
+        )
 
     def instantiate(self, sim=None, icell=None):
         """Instantiate recording.
@@ -50,11 +268,184 @@ class RecordingCustom(ephys.recordings.CompRecording):
             "Adding compartment recording of %s at %s", self.variable, self.location
         )
 
-        self.varvector = sim.neuron.h.Vector()
         seg = self.location.instantiate(sim=sim, icell=icell)
-        self.varvector.record(getattr(seg, f"_ref_{self.variable}"), 0.1)
+        self.varvector = record_variable(
+            sim,
+            get_variable_ref(seg, self.variable),
+            get_sampling_interval(sim, self.record_dt, self.record_every),
+        )
+        self.record_time(sim)
+
+        self.instantiated = True
 
-        self.tvector = sim.neuron.h.Vector()
-        self.tvector.record(sim.neuron.h._ref_t, 0.1)  # pylint: disable=W0212
+
+class ClampCurrentRecording(RecordingCustom):
+    """Current of a voltage clamp stimulus, recorded every 0.1 ms by default.
+
+    Attributes:
+        name (str): name of this object
+        location (Location): location in the model of the clamp
+        variable (str): the current of the clamp ('i')
+        stimulus (stimuli.VoltageClamp): the voltage clamp, instantiated
+            before the recording
+        record_dt (float): interval between the recorded samples (ms)
+        record_every (int): number of integration steps between the recorded
+            samples, None if the samples are taken every record_dt
+        varvector (neuron Vector): vector recording the current (nA)
+        tvector (neuron Vector): vector recording the time (ms)
+        instantiated (bool): whether the object has been instantiated or not
+    """
+
+    def __init__(self, name=None, stimulus=None, record_dt=None, record_every=None):
+        """Constructor.
+
+        Args:
+            name (str): name of this object
+            stimulus (stimuli.VoltageClamp): the voltage clamp
+            record_dt (float): interval between the recorded samples (ms)
+            record_every (int): number of integration steps between the
+                recorded samples. The current is recorded every 0.1 ms
+                if both are None.
+        """
+        super().__init__(
+            name=name,
+            location=stimulus.location,
+            variable="i",
+            record_dt=record_dt,
+            record_every=record_every,
+        )
+        self.stimulus = stimulus
+
+    def instantiate(self, sim=None, icell=None):
+        """Instantiate recording.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            icell (neuron cell): cell instantiation in simulator
+        """
+        # pylint: disable=unused-argument
+        logger.debug("Adding clamp current recording at %s", self.location)
+
+        self.varvector = record_variable(
+            sim,
+            self.stimulus.seclamp._ref_i,  # pylint: disable=protected-access
+            get_sampling_interval(sim, self.record_dt, self.record_every),
+        )
+        self.record_time(sim)
 
         self.instantiated = True
+
+
+class CurrentsRecording(RecordingCustom):
+    """Ionic currents of a location, recorded every 0.1 ms by default.
+
+    The currents are found at instantiation, see get_segment_currents,
+    and can be used to decompose the membrane current, e.g. during an action potential.
+
+    Attributes:
+        name (str): name of this object
+        location (Location): location in the model of the recording
+        variable (str): the recorded variable ('currents')
+        record_dt (float): interval between the recorded samples (ms)
+        record_every (int): number of integration steps between the recorded
+            samples, None if the samples are taken every record_dt
+        varvectors (dict): vector recording each current (mA/cm2), keyed by its name
+        tvector (neuron Vector): vector recording the time (ms)
+        instantiated (bool): whether the object has been instantiated or not
+    """
+
+    def __init__(self, name=None, location=None, record_dt=None, record_every=None):
+        """Constructor.
+
+        Args:
+            name (str): name of this object
+            location (Location): location in the model of the recording
+            record_dt (float): interval between the recorded samples (ms)
+            record_every (int): number of integration steps between the
+                recorded samples. The currents are recorded every 0.1 ms
+                if both are None.
+        """
+        super().__init__(
+            name=name,
+            location=location,
+            variable=CURRENTS_VARIABLE,
+            record_dt=record_dt,
+            record_every=record_every,
+        )
+        self.varvectors = None
+
+    def instantiate(self, sim=None, icell=None):
+        """Instantiate recording.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            icell (neuron cell): cell instantiation in simulator
+        """
+        seg = self.location.instantiate(sim=sim, icell=icell)
+        currents = get_segment_currents(sim, seg)
+        logger.debug("Adding recording of %s at %s", currents, self.location)
+
+        interval = get_sampling_interval(sim, self.record_dt, self.record_every)
+        self.varvectors = {
+            current: record_variable(sim, get_variable_ref(seg, current), interval)
+            for current in currents
+        }
+        self.record_time(sim)
+
+        self.instantiated = True
+
+    @property
+    def response(self):
+        """Return the recorded currents.
+
+        Returns:
+            dict containing the time (ms) and the values of each current (mA/cm2)
+        """
+        if not self.instantiated:
+            return None
+
+        return {
+            "time": np.array(self.tvector),
+            "currents": {
+                current: np.array(varvector)
+                for current, varvector in self.varvectors.items()
+            },
+        }
+
+    def destroy(self, sim=None):
+        """Destroy recording.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        """
+        # pylint: disable=unused-argument
+        self.varvectors = None
+        self.tvector = None
+        self.instantiated = False
+
+
+def expand_current_responses(responses):
+    """Return the responses with a trace for each current of the current recordings.
+
+    The currents recorded as 'prefix.protocol.location.currents' become the traces
+    'prefix.protocol.location.current', e.g. 'L5TPC.Step_150.soma.ina_NaTg',
+    written and analysed as the other recordings.
+
+    Args:
+        responses (dict): responses of the protocols, keyed by recording name
+
+    Returns:
+        dict: the responses, with the current recordings replaced by their currents
+    """
+    suffix = f".{CURRENTS_VARIABLE}"
+    expanded = {}
+    for name, response in responses.items():
+        if not (name.endswith(suffix) and isinstance(response, dict)):
+            expanded[name] = response
+            continue
+        for current, values in response["currents"].items():
+            current_name = f"{name[: -len(suffix)]}.{current}"
+            expanded[current_name] = ephys.recordings.responses.TimeVoltageResponse(
+                current_name, response["time"], values
+            )
+    return expanded
@@ -0,0 +1,337 @@
+"""Download of cell packages from a remote registry."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import configparser
+import json
+import logging
+import os
+import shutil
+import tempfile
+import urllib.error
+import urllib.parse
+import urllib.request
+import zipfile
+from pathlib import Path
+
+logger = logging.getLogger(__name__)
+
+REGISTRY_TYPES = ["https", "nexus"]
+TOKEN_ENV_VARIABLE = "EMODELRUNNER_REGISTRY_TOKEN"
+MORPHOLOGY_EXTENSIONS = [".asc", ".swc", ".h5"]
+PARAMS_PATH = Path("config") / "params" / "final.json"
+
+# not copied from the template package
+TEMPLATE_IGNORE_PATTERNS = [
+    "*.ini",
+    "final.json",
+    "LICENSE*",
+    "morphology",
+    "mechanisms",
+    "synapses",
+    "python_recordings",
+    "hoc_recordings",
+    "x86_64",
+]
+
+
+def get_archive_url(registry_url, model_id, registry_type="https"):
+    """Return the url of the archive of a model.
+
+    Args:
+        registry_url (str): url of the registry. For Nexus, the url of the files
+            of a project, e.g. https://host/nexus/v1/files/org/project
+        model_id (str): identifier of the model
+        registry_type (str): "https" for a plain registry serving {model_id}.zip,
+            or "nexus" for a Nexus project storing the archives as files
+
+    Raises:
+        ValueError: if the registry type is not supported
+
+    Returns:
+        str: url of the archive
+    """
+    registry_url = registry_url.rstrip("/")
+    if registry_type == "https":
+        return f"{registry_url}/{urllib.parse.quote(model_id)}.zip"
+    if registry_type == "nexus":
+        # Nexus ids are usually urls, they have to be fully encoded
+        return f"{registry_url}/{urllib.parse.quote(model_id, safe='')}"
+    raise ValueError(
+        f"Unsupported registry type: {registry_type}. Choose from {REGISTRY_TYPES}."
+    )
+
+
+def download_archive(url, archive_path, token=None):
+    """Download an archive.
+
+    Args:
+        url (str): url of the archive
+        archive_path (str or Path): path to the downloaded archive
+        token (str): bearer token sent to the registry, if any
+
+    Raises:
+        RuntimeError: if the download fails
+    """
+    headers = {"Accept": "*/*"}
+    if token is not None:
+        headers["Authorization"] = f"Bearer {token}"
+    request = urllib.request.Request(url, headers=headers)
+
+    logger.info("Downloading %s", url)
+    try:
+        with urllib.request.urlopen(request, timeout=60) as response, open(
+            archive_path, "wb"
+        ) as archive_file:
+            shutil.copyfileobj(response, archive_file)
+    except (urllib.error.URLError, OSError) as exc:
+        raise RuntimeError(f"The download of {url} failed: {exc}") from exc
+
+
+def extract_archive(archive_path, package_dir):
+    """Extract a zip archive in a new directory.
+
+    If all the files of the archive are in one directory,
+    the content of this directory is extracted.
+
+    Args:
+        archive_path (str or Path): path to the zip archive
+        package_dir (Path): directory to create
+
+    Raises:
+        ValueError: if the archive is not a zip archive
+            or if one of its files would be extracted out of package_dir
+    """
+    if not zipfile.is_zipfile(archive_path):
+        raise ValueError(f"{archive_path} is not a zip archive.")
+
+    with zipfile.ZipFile(archive_path) as archive:
+        for name in archive.namelist():
+            if Path(name).is_absolute() or ".." in Path(name).parts:
+                raise ValueError(f"Unsafe path in the archive: {name}")
+
+        with tempfile.TemporaryDirectory(dir=package_dir.parent) as extract_dir:
+            archive.extractall(extract_dir)
+            content = list(Path(extract_dir).iterdir())
+            if len(content) == 1 and content[0].is_dir():
+                content = list(content[0].iterdir())
+            package_dir.mkdir()
+            for path in content:
+                shutil.move(str(path), str(package_dir / path.name))
+
+
+def find_morphology(package_dir):
+    """Return the path to the morphology of a cell package.
+
+    Args:
+        package_dir (Path): cell package directory
+
+    Raises:
+        FileNotFoundError: if there is not exactly one morphology in the package
+
+    Returns:
+        Path: path to the morphology, relative to the package directory
+    """
+    morphologies = [
+        path
+        for path in sorted((package_dir / "morphology").glob("*"))
+        if path.suffix.lower() in MORPHOLOGY_EXTENSIONS
+    ]
+    if len(morphologies) != 1:
+        raise FileNotFoundError(
+            f"Expected one morphology in {package_dir / 'morphology'}, "
+            f"found {len(morphologies)}."
+        )
+    return morphologies[0].relative_to(package_dir)
+
+
+def get_emodel_name(package_dir, model_id):
+    """Return the name of the e-model in the parameters file of a cell package.
+
+    Args:
+        package_dir (Path): cell package directory
+        model_id (str): identifier of the model, used if the parameters file
+            contains several e-models
+
+    Raises:
+        ValueError: if the e-model cannot be chosen
+
+    Returns:
+        str: e-model name
+    """
+    with open(package_dir / PARAMS_PATH, "r", encoding="utf-8") as params_file:
+        emodels = list(json.load(params_file))
+    if len(emodels) == 1:
+        return emodels[0]
+    if model_id in emodels:
+        return model_id
+    raise ValueError(
+        f"{PARAMS_PATH} contains several e-models, none of them named {model_id}."
+    )
+
+
+def check_package(package_dir):
+    """Check that a cell package contains the parameters, morphology and mod files.
+
+    Args:
+        package_dir (Path): cell package directory
+
+    Raises:
+        FileNotFoundError: if a file is missing
+    """
+    if not (package_dir / PARAMS_PATH).is_file():
+        raise FileNotFoundError(f"{PARAMS_PATH} not found in {package_dir}.")
+    if not list((package_dir / "mechanisms").glob("*.mod")):
+        raise FileNotFoundError(f"No mod file found in {package_dir / 'mechanisms'}.")
+    find_morphology(package_dir)
+
+
+def copy_missing_files(source_dir, target_dir, ignore):
+    """Recursively copy the files of a directory that are missing in another one.
+
+    Args:
+        source_dir (Path): directory to copy
+        target_dir (Path): directory in which to copy the missing files
+        ignore (callable): ignore function, as in shutil.copytree
+    """
+    ignored = ignore(str(source_dir), os.listdir(source_dir))
+    for source_path in sorted(source_dir.iterdir()):
+        if source_path.name in ignored:
+            continue
+        target_path = target_dir / source_path.name
+        if source_path.is_dir():
+            if target_path.exists():
+                copy_missing_files(source_path, target_path, ignore)
+            else:
+                shutil.copytree(source_path, target_path, ignore=ignore)
+        elif not target_path.exists():
+            shutil.copy(source_path, target_path)
+
+
+def create_local_config(package_dir, model_id, template_dir, template_config):
+    """Complete a cell package with the files of a template package, and write its config.
+
+    The protocols, features and templates of the template package are copied
+    if the package does not have them.
+
+    Args:
+        package_dir (Path): cell package directory
+        model_id (str): identifier of the model
+        template_dir (str or Path): path to the template cell package
+        template_config (str): path to the config of the template package,
+            relative to template_dir
+
+    Returns:
+        Path: path to the config
+    """
+    template_dir = Path(template_dir)
+    copy_missing_files(
+        template_dir, package_dir, shutil.ignore_patterns(*TEMPLATE_IGNORE_PATTERNS)
+    )
+
+    config = configparser.ConfigParser(interpolation=None)
+    config.optionxform = str
+    config.read(template_dir / template_config)
+    for section in ["Cell", "Paths"]:
+        if not config.has_section(section):
+            config.add_section(section)
+    config.set("Cell", "emodel", get_emodel_name(package_dir, model_id))
+    config.set("Paths", "memodel_dir", ".")
+    config.set("Paths", "morph_path", str(find_morphology(package_dir)))
+    config.set("Paths", "params_path", f"%(memodel_dir)s/{PARAMS_PATH.as_posix()}")
+
+    config_path = package_dir / template_config
+    config_path.parent.mkdir(parents=True, exist_ok=True)
+    with open(config_path, "w", encoding="utf-8") as config_file:
+        config.write(config_file)
+    return config_path
+
+
+def fetch_emodel(
+    model_id,
+    registry_url,
+    output_dir,
+    registry_type="https",
+    token=None,
+    template_dir=None,
+    template_config="config/config_allsteps.ini",
+):
+    """Download a cell package from a registry, and write its local config if it has none.
+
+    The archive of the model is a zip of a cell package, containing at least
+    the parameters (config/params/final.json), the morphology (in morphology)
+    and the mod files (in mechanisms). If it has no config, the config and
+    the missing protocols, features and templates are taken from a template package.
+
+    Args:
+        model_id (str): identifier of the model
+        registry_url (str): url of the registry. See get_archive_url.
+        output_dir (str or Path): directory in which to create the cell package,
+            named after the model
+        registry_type (str): "https" or "nexus". See get_archive_url.
+        token (str): bearer token sent to the registry. If None,
+            the EMODELRUNNER_REGISTRY_TOKEN environment variable is used, if set.
+        template_dir (str or Path): path to a template cell package.
+            Needed if the archive has no config.
+        template_config (str): path to the config of the template package,
+            relative to template_dir
+
+    Raises:
+        ValueError: if the package already exists, or if it has no config
+            and no template package is given
+
+    Returns:
+        list of Path: paths to the configs of the cell package
+    """
+    if token is None:
+        token = os.environ.get(TOKEN_ENV_VARIABLE)
+
+    output_dir = Path(output_dir)
+    package_dir = output_dir / Path(urllib.parse.unquote(model_id)).name
+    if package_dir.exists():
+        raise ValueError(f"{package_dir} already exists.")
+    output_dir.mkdir(parents=True, exist_ok=True)
+
+    url = get_archive_url(registry_url, model_id, registry_type)
+    with tempfile.TemporaryDirectory() as download_dir:
+        archive_path = Path(download_dir) / "emodel.zip"
+        download_archive(url, archive_path, token)
+        extract_archive(archive_path, package_dir)
+
+    try:
+        check_package(package_dir)
+        config_paths = sorted((package_dir / "config").glob("*.ini"))
+        if not config_paths:
+            if template_dir is None:
+                raise ValueError(
+                    f"The package of {model_id} has no config. "
+                    "Give a template package to create it."
+                )
+            config_paths = [
+                create_local_config(
+                    package_dir, model_id, template_dir, template_config
+                )
+            ]
+    except (FileNotFoundError, ValueError):
+        # do not leave an unusable package, that would prevent fetching it again
+        shutil.rmtree(package_dir)
+        raise
+
+    logger.info(
+        "%s downloaded in %s. Compile its mechanisms before running it.",
+        model_id,
+        package_dir,
+    )
+    return config_paths
@@ -0,0 +1,238 @@
+"""Comparison of the responses of a run with reference outputs."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import logging
+from pathlib import Path
+
+import numpy as np
+
+from emodelrunner.json_utilities import NpEncoder
+from emodelrunner.load import load_config
+from emodelrunner.provenance import get_provenance
+from emodelrunner.results import detect_spikes, parse_output_filename
+from emodelrunner.run import run
+
+logger = logging.getLogger(__name__)
+
+DEFAULT_TOLERANCES = {
+    # root mean square of the difference between the traces (mV)
+    "voltage_rms": 0.1,
+    # maximum shift of the spike times (ms)
+    "spike_time_shift": 0.1,
+    # relative tolerance of the scalar outputs, e.g. the holding current
+    "scalar_rtol": 1e-3,
+    # voltage threshold for spike detection (mV)
+    "spike_threshold": -20.0,
+}
+
+
+def load_reference(reference_dir):
+    """Load the reference traces and scalar outputs written by a run.
+
+    Args:
+        reference_dir (str or Path): directory containing the .dat output files
+            of a run, e.g. python_recordings
+
+    Raises:
+        FileNotFoundError: if the directory contains no trace nor scalar output
+
+    Returns:
+        dict: reference responses keyed by response name, as the responses of a run.
+            Traces are dicts with time and voltage, scalar outputs are floats.
+    """
+    reference = {}
+    for path in sorted(Path(reference_dir).glob("*.dat")):
+        kind = parse_output_filename(path)["kind"]
+        if kind == "trace":
+            data = np.loadtxt(path, ndmin=2)
+            reference[path.stem] = {"time": data[:, 0], "voltage": data[:, 1]}
+        elif kind == "scalar":
+            reference[path.stem] = float(np.loadtxt(path, ndmin=1)[0])
+
+    if not reference:
+        raise FileNotFoundError(f"No reference output found in {reference_dir}.")
+    return reference
+
+
+def get_voltage_rms(time, voltage, ref_time, ref_voltage):
+    """Return the root mean square of the difference between a trace and its reference.
+
+    The trace is interpolated at the reference times, so that traces recorded
+    with different time steps can be compared.
+
+    Args:
+        time (numpy.ndarray): time of the trace (ms)
+        voltage (numpy.ndarray): recorded values of the trace, e.g. voltage (mV)
+        ref_time (numpy.ndarray): time of the reference trace (ms)
+        ref_voltage (numpy.ndarray): recorded values of the reference trace
+
+    Returns:
+        float: root mean square of the difference
+    """
+    interpolated = np.interp(ref_time, time, voltage)
+    return float(np.sqrt(np.mean((interpolated - ref_voltage) ** 2)))
+
+
+def compare_trace(name, response, ref_response, tolerances):
+    """Compare a trace with its reference.
+
+    Args:
+        name (str): name of the response
+        response (dict): response with time and voltage
+        ref_response (dict): reference response with time and voltage
+        tolerances (dict): tolerances, see DEFAULT_TOLERANCES
+
+    Returns:
+        dict: comparison with the voltage RMS and, for voltage traces,
+            the spike counts and the maximum spike time shift
+    """
+    time = np.asarray(response["time"])
+    voltage = np.asarray(response["voltage"])
+    ref_time = np.asarray(ref_response["time"])
+    ref_voltage = np.asarray(ref_response["voltage"])
+
+    result = {"voltage_rms": get_voltage_rms(time, voltage, ref_time, ref_voltage)}
+    failures = []
+    if result["voltage_rms"] > tolerances["voltage_rms"]:
+        failures.append("voltage_rms")
+
+    if parse_output_filename(name)["variable"] == "v":
+        spikes = detect_spikes(time, voltage, tolerances["spike_threshold"])
+        ref_spikes = detect_spikes(ref_time, ref_voltage, tolerances["spike_threshold"])
+        result["spike_count"] = len(spikes)
+        result["reference_spike_count"] = len(ref_spikes)
+        if len(spikes) != len(ref_spikes):
+            result["spike_time_shift"] = None
+            failures.append("spike_count")
+        else:
+            result["spike_time_shift"] = (
+                float(np.max(np.abs(spikes - ref_spikes))) if len(spikes) else 0.0
+            )
+            if result["spike_time_shift"] > tolerances["spike_time_shift"]:
+                failures.append("spike_time_shift")
+
+    result["passed"] = not failures
+    result["failures"] = failures
+    return result
+
+
+def compare_scalar(value, ref_value, tolerances):
+    """Compare a scalar output, e.g. the holding current, with its reference.
+
+    Args:
+        value (float): scalar output
+        ref_value (float): reference scalar output
+        tolerances (dict): tolerances, see DEFAULT_TOLERANCES
+
+    Returns:
+        dict: comparison with the value and the reference value
+    """
+    passed = bool(np.isclose(value, ref_value, rtol=tolerances["scalar_rtol"], atol=0))
+    return {
+        "value": float(value),
+        "reference_value": ref_value,
+        "passed": passed,
+        "failures": [] if passed else ["scalar_rtol"],
+    }
+
+
+def compare_responses(responses, reference, tolerances=None):
+    """Compare the responses of a run with reference responses.
+
+    Each reference response has to be in the responses. The responses
+    without reference are ignored.
+
+    Args:
+        responses (dict): responses of a run, as returned by run.run
+        reference (dict): reference responses, as returned by load_reference
+        tolerances (dict): tolerances overriding DEFAULT_TOLERANCES
+
+    Returns:
+        dict: report with the tolerances, the comparison of each response
+            and whether all the comparisons passed
+    """
+    tolerances = {**DEFAULT_TOLERANCES, **(tolerances or {})}
+
+    comparisons = {}
+    for name, ref_response in reference.items():
+        response = responses.get(name)
+        if response is None:
+            comparisons[name] = {"passed": False, "failures": ["missing"]}
+        elif isinstance(ref_response, dict):
+            comparisons[name] = compare_trace(name, response, ref_response, tolerances)
+        else:
+            comparisons[name] = compare_scalar(response, ref_response, tolerances)
+
+        if not comparisons[name]["passed"]:
+            logger.warning(
+                "%s differs from the reference: %s",
+                name,
+                ", ".join(comparisons[name]["failures"]),
+            )
+
+    return {
+        "passed": all(comparison["passed"] for comparison in comparisons.values()),
+        "tolerances": tolerances,
+        "responses": comparisons,
+    }
+
+
+def write_report(report, report_path):
+    """Write a regression report as json.
+
+    Args:
+        report (dict): report, as returned by compare_responses
+        report_path (str or Path): path to the report
+    """
+    with open(report_path, "w", encoding="utf-8") as report_file:
+        json.dump(report, report_file, indent=4, cls=NpEncoder)
+
+
+def run_regression(
+    config_path, reference_dir, tolerances=None, report_path=None, config_overrides=None
+):
+    """Run the protocols of a config and compare the responses with reference outputs.
+
+    The reference outputs are the .dat files written by a previous run,
+    e.g. the python_recordings directory of a published cell package.
+
+    Args:
+        config_path (str): path to the config of a sscx or thalamus package
+        reference_dir (str or Path): directory containing the reference outputs
+        tolerances (dict): tolerances overriding DEFAULT_TOLERANCES
+        report_path (str or Path): path to the json report to write, if any
+        config_overrides (list of str): values overriding the ones of the config file,
+            given as 'section.key=value', e.g. 'Cell.celsius=36'
+
+    Returns:
+        dict: report with the tolerances, the comparison of each response,
+            whether all the comparisons passed and the provenance of the run
+    """
+    reference = load_reference(reference_dir)
+    config = load_config(config_path=config_path, config_overrides=config_overrides)
+    responses = run(config, write_output=False)
+
+    report = compare_responses(responses, reference, tolerances)
+    report["reference_dir"] = str(reference_dir)
+    report["provenance"] = get_provenance(config)
+
+    if report_path is not None:
+        write_report(report, report_path)
+
+    if report["passed"]:
+        logger.info("All the responses match the reference outputs.")
+    return report
@@ -0,0 +1,468 @@
+"""Load the outputs of a run into pandas DataFrames."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import logging
+from collections.abc import Mapping
+from pathlib import Path
+
+import h5py
+import numpy as np
+import pandas as pd
+
+from emodelrunner.locations import parse_section_location_name
+from emodelrunner.output import get_binary_index_path
+
+logger = logging.getLogger(__name__)
+
+TRACE_COLUMNS = [
+    "prefix",
+    "protocol",
+    "location",
+    "section",
+    "position",
+    "variable",
+    "time",
+    "value",
+]
+FEATURE_COLUMNS = ["prefix", "protocol", "location", "feature", "value"]
+SPIKE_COLUMNS = ["prefix", "protocol", "location", "spike_index", "time"]
+# suffixes of the output files written by the run that cannot be loaded back
+UNREADABLE_OUTPUT_SUFFIXES = [".nwb"]
+
+
+def parse_output_filename(filename):
+    """Get the metadata encoded in the name of an output file.
+
+    Output files are named after the response keys, e.g.
+    'prefix.protocol.location.variable.dat' for recordings,
+    'current_prefix.protocol.dat' for injected currents
+    and 'prefix.name.dat' for scalar outputs such as the holding current.
+    The recordings at a section location have the section and the position
+    encoded in the location, e.g. 'dend3_x0p5' for 'dend[3](0.5)'.
+
+    Args:
+        filename (str or Path): name of (or path to) the output file,
+            or response key
+
+    Returns:
+        dict containing the kind of output ('trace', 'current' or 'scalar'),
+        the prefix, the protocol, the location, the section and the position
+        (None if the location is not a section location) and the variable
+    """
+    key = Path(filename).name
+    if key.endswith(".dat"):
+        key = key[: -len(".dat")]
+    metadata = {
+        "kind": None,
+        "prefix": None,
+        "protocol": None,
+        "location": None,
+        "section": None,
+        "position": None,
+        "variable": None,
+    }
+
+    if key.startswith("current_"):
+        items = key[len("current_") :].split(".")
+        metadata["kind"] = "current"
+        metadata["prefix"] = items[0]
+        metadata["protocol"] = ".".join(items[1:]) if len(items) > 1 else None
+        metadata["variable"] = "current"
+        return metadata
+
+    items = key.split(".")
+    if len(items) >= 4:
+        metadata["kind"] = "trace"
+        metadata["prefix"] = items[0]
+        metadata["protocol"] = ".".join(items[1:-2])
+        metadata["location"] = items[-2]
+        metadata["variable"] = items[-1]
+        section_location = parse_section_location_name(items[-2])
+        if section_location is not None:
+            metadata.update(section_location)
+    elif len(items) == 2:
+        metadata["kind"] = "scalar"
+        metadata["prefix"] = items[0]
+        metadata["variable"] = items[1]
+    else:
+        metadata["kind"] = "trace"
+        metadata["protocol"] = key
+        metadata["variable"] = "v"
+
+    return metadata
+
+
+def detect_spikes(time, voltage, threshold=-20.0):
+    """Return the times at which the voltage crosses the threshold upwards.
+
+    Args:
+        time (numpy.ndarray): time of the trace (ms)
+        voltage (numpy.ndarray): voltage of the trace (mV)
+        threshold (float): voltage threshold for spike detection (mV)
+
+    Returns:
+        numpy.ndarray: spike times (ms)
+    """
+    time = np.asarray(time)
+    voltage = np.asarray(voltage)
+    above = voltage >= threshold
+    crossings = np.where(~above[:-1] & above[1:])[0] + 1
+    return time[crossings]
+
+
+def load_h5_output(output_path):
+    """Load the traces of a h5 output file written by output.write_h5_output.
+
+    Args:
+        output_path (str or Path): path to the h5 output file
+
+    Returns:
+        dict: responses keyed by response key, with the same structure as
+        the ones of the run: {"time": time, "voltage": values} for the recordings,
+        {"time": time, "current": values} for the injected currents
+        and a float for the scalars
+    """
+    responses = {}
+
+    def _load_item(_, item):
+        """Load a trace group or a scalar dataset."""
+        key = item.attrs.get("key")
+        if key is None:
+            return
+        if isinstance(item, h5py.Dataset):
+            responses[key] = float(item[()])
+        else:
+            field = "current" if key.startswith("current_") else "voltage"
+            responses[key] = {"time": item["time"][()], field: item["values"][()]}
+
+    with h5py.File(output_path, "r") as h5file:
+        h5file.visititems(_load_item)
+    return responses
+
+
+class BinaryOutput(Mapping):
+    """Lazy reader of a binary output file written by output.write_binary_output.
+
+    The responses are read from the memory-mapped file only when accessed,
+    so that a few traces can be taken from a large output without loading it.
+    The traces are read-only views on the file.
+
+    Attributes:
+        output_path (Path): path to the .npy output file
+        index (dict): index of the file, with the offset, length and field
+            of each trace, the scalars and the provenance of the run
+    """
+
+    def __init__(self, output_path):
+        """Constructor.
+
+        Args:
+            output_path (str or Path): path to the .npy output file
+        """
+        self.output_path = Path(output_path)
+        index_path = get_binary_index_path(output_path)
+        with open(index_path, "r", encoding="utf-8") as index_file:
+            self.index = json.load(index_file)
+        self._data = None
+
+    @property
+    def data(self):
+        """Return the memory-mapped array of the file, mapped at first access.
+
+        Returns:
+            numpy.memmap: the time and the values of all the traces
+        """
+        if self._data is None:
+            if self.index["traces"]:
+                self._data = np.load(self.output_path, mmap_mode="r")
+            else:
+                self._data = np.zeros(0)
+        return self._data
+
+    @property
+    def provenance(self):
+        """Return the provenance of the run.
+
+        Returns:
+            dict: provenance of the run, or None if not written
+        """
+        return self.index.get("provenance")
+
+    def __getitem__(self, key):
+        """Return the response of a response key.
+
+        Args:
+            key (str): response key
+
+        Raises:
+            KeyError: if the key is not in the output
+
+        Returns:
+            {"time": time, "voltage": values} for the recordings,
+            {"time": time, "current": values} for the injected currents
+            and a float for the scalars
+        """
+        if key in self.index["scalars"]:
+            return self.index["scalars"][key]
+        trace = self.index["traces"][key]
+        offset = trace["offset"]
+        length = trace["length"]
+        return {
+            "time": self.data[offset : offset + length],
+            trace["field"]: self.data[offset + length : offset + 2 * length],
+        }
+
+    def __iter__(self):
+        """Iterate over the response keys."""
+        yield from self.index["traces"]
+        yield from self.index["scalars"]
+
+    def __len__(self):
+        """Return the number of responses."""
+        return len(self.index["traces"]) + len(self.index["scalars"])
+
+    def trace_metadata(self):
+        """Return the metadata encoded in the response key of each trace.
+
+        Returns:
+            dict: metadata of each response key, see parse_output_filename
+        """
+        return {key: parse_output_filename(key) for key in self.index["traces"]}
+
+
+def load_binary_output(output_path):
+    """Load all the responses of a binary output file in memory.
+
+    Args:
+        output_path (str or Path): path to the .npy output file
+
+    Returns:
+        dict: responses keyed by response key, with the same structure as
+        the ones of the run, see BinaryOutput
+    """
+    output = BinaryOutput(output_path)
+    return {
+        key: (
+            {field: np.array(values) for field, values in response.items()}
+            if isinstance(response, dict)
+            else response
+        )
+        for key, response in output.items()
+    }
+
+
+def _get_response_content(response):
+    """Return the time and the values of a trace response, or the value of a scalar.
+
+    Args:
+        response (dict or float): response, structured like the responses of the run
+
+    Returns:
+        tuple containing the time and the values of a trace, or float for a scalar
+    """
+    if isinstance(response, dict):
+        field = "current" if "current" in response else "voltage"
+        return np.asarray(response["time"]), np.asarray(response[field])
+    return float(response)
+
+
+def _iter_output_files(output_dir, kinds):
+    """Yield the metadata and the content of each output of the given kinds.
+
+    The outputs are read from the .dat files, from the h5 output files
+    written by output.write_h5_output and from the binary output files
+    written by output.write_binary_output, with their json index.
+    The other h5 files, e.g. the synapse recordings, have no response key
+    and are skipped.
+
+    Args:
+        output_dir (str or Path): directory containing the output files of a run
+        kinds (list of str): kinds of output to keep
+
+    Raises:
+        ValueError: if the directory contains outputs in a format that cannot be loaded
+
+    Yields:
+        tuple containing the metadata of the output and its content:
+        a tuple with the time and the values for the traces, a float for the scalars
+    """
+    output_dir = Path(output_dir)
+    unreadable_paths = sorted(
+        path
+        for suffix in UNREADABLE_OUTPUT_SUFFIXES
+        for path in output_dir.glob(f"*{suffix}")
+    )
+    # a binary output cannot be read without its index
+    unreadable_paths += [
+        path
+        for path in sorted(output_dir.glob("*.npy"))
+        if not get_binary_index_path(path).is_file()
+    ]
+    if unreadable_paths:
+        raise ValueError(
+            f"The outputs of {', '.join(str(path) for path in unreadable_paths)} "
+            "cannot be loaded. Run the config with the dat, h5 or npy output format."
+        )
+
+    for path in sorted(output_dir.glob("*.dat")):
+        metadata = parse_output_filename(path)
+        if metadata["kind"] not in kinds:
+            continue
+        if metadata["kind"] == "scalar":
+            yield metadata, float(np.loadtxt(path, ndmin=1)[0])
+        else:
+            data = np.loadtxt(path, ndmin=2)
+            yield metadata, (data[:, 0], data[:, 1])
+
+    for path in sorted(output_dir.glob("*.h5")):
+        for key, response in load_h5_output(path).items():
+            metadata = parse_output_filename(key)
+            if metadata["kind"] in kinds:
+                yield metadata, _get_response_content(response)
+
+    for path in sorted(output_dir.glob("*.npy")):
+        for key, response in BinaryOutput(path).items():
+            metadata = parse_output_filename(key)
+            if metadata["kind"] in kinds:
+                yield metadata, _get_response_content(response)
+
+
+def load_traces(output_dir, include_currents=True):
+    """Load the recorded traces into a tidy DataFrame (one row per sample).
+
+    Args:
+        output_dir (str or Path): directory containing the output files of a run,
+            in the dat, h5 or npy format
+        include_currents (bool): whether to also load the injected currents
+
+    Raises:
+        ValueError: if the outputs are in a format that cannot be loaded, e.g. nwb
+
+    Returns:
+        pandas.DataFrame: traces with columns
+        prefix, protocol, location, section, position, variable, time and value
+    """
+    kinds = ["trace", "current"] if include_currents else ["trace"]
+    frames = []
+    for metadata, (time, values) in _iter_output_files(output_dir, kinds):
+        frame = pd.DataFrame({"time": time, "value": values})
+        for column in TRACE_COLUMNS[:-2]:
+            frame[column] = metadata[column]
+        frames.append(frame[TRACE_COLUMNS])
+
+    if not frames:
+        return pd.DataFrame(columns=TRACE_COLUMNS)
+    return pd.concat(frames, ignore_index=True)
+
+
+def load_features(output_dir):
+    """Load the scalar outputs (e.g. holding and threshold currents) into a DataFrame.
+
+    The efeatures extracted for each protocol are also loaded if present,
+    with one row per feature value.
+
+    Args:
+        output_dir (str or Path): directory containing the output files of a run
+
+    Raises:
+        ValueError: if the outputs are in a format that cannot be loaded, e.g. nwb
+
+    Returns:
+        pandas.DataFrame: features with columns prefix, protocol, location, feature and value
+    """
+    rows = []
+    for metadata, value in _iter_output_files(output_dir, ["scalar"]):
+        rows.append(
+            {
+                "prefix": metadata["prefix"],
+                "protocol": metadata["protocol"],
+                "location": metadata["location"],
+                "feature": metadata["variable"],
+                "value": float(value),
+            }
+        )
+
+    efeatures_path = Path(output_dir) / "efeatures.json"
+    if efeatures_path.is_file():
+        with open(efeatures_path, "r", encoding="utf-8") as efeatures_file:
+            efeatures = json.load(efeatures_file)
+        for protocol_name, features in efeatures.items():
+            for feature_name, values in features.items():
+                for value in values or []:
+                    rows.append(
+                        {
+                            "prefix": None,
+                            "protocol": protocol_name,
+                            "location": "soma",
+                            "feature": feature_name,
+                            "value": float(value),
+                        }
+                    )
+
+    return pd.DataFrame(rows, columns=FEATURE_COLUMNS)
+
+
+def load_spikes(output_dir, threshold=-20.0):
+    """Detect the spikes in the voltage traces and return them as a DataFrame.
+
+    Args:
+        output_dir (str or Path): directory containing the output files of a run
+        threshold (float): voltage threshold for spike detection (mV)
+
+    Raises:
+        ValueError: if the outputs are in a format that cannot be loaded, e.g. nwb
+
+    Returns:
+        pandas.DataFrame: spikes with columns prefix, protocol, location, spike_index and time
+    """
+    rows = []
+    for metadata, (time, voltage) in _iter_output_files(output_dir, ["trace"]):
+        if metadata["variable"] != "v":
+            continue
+        for idx, spike_time in enumerate(detect_spikes(time, voltage, threshold)):
+            rows.append(
+                {
+                    "prefix": metadata["prefix"],
+                    "protocol": metadata["protocol"],
+                    "location": metadata["location"],
+                    "spike_index": idx,
+                    "time": float(spike_time),
+                }
+            )
+
+    return pd.DataFrame(rows, columns=SPIKE_COLUMNS)
+
+
+def load_results(output_dir, spike_threshold=-20.0):
+    """Load all the outputs of a run.
+
+    Args:
+        output_dir (str or Path): directory containing the output files of a run
+        spike_threshold (float): voltage threshold for spike detection (mV)
+
+    Raises:
+        ValueError: if the outputs are in a format that cannot be loaded, e.g. nwb
+
+    Returns:
+        dict containing the 'traces', 'features' and 'spikes' DataFrames
+    """
+    logger.debug("Loading results from %s", output_dir)
+    return {
+        "traces": load_traces(output_dir),
+        "features": load_features(output_dir),
+        "spikes": load_spikes(output_dir, spike_threshold),
+    }
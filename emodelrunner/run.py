@@ -14,43 +14,110 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
+import json
 import logging
-
-from bluepyopt import ephys
+import os
+import time
 
 from emodelrunner.configuration.configparser import PackageType
 from emodelrunner.create_cells import create_cell_using_config
+from emodelrunner.evaluator import get_all_subprotocols, get_fitness_protocols
+from emodelrunner.extracellular import (
+    add_extracellular_recordings,
+    pop_extracellular_responses,
+)
+from emodelrunner.factsheets.target_comparison import (
+    compare_to_targets,
+    write_target_comparison,
+)
+from emodelrunner.attenuation import compute_attenuations
+from emodelrunner.bursts import compute_burst_features
+from emodelrunner.features import (
+    add_config_efeatures,
+    define_efeatures,
+    extract_protocols_efeatures,
+    get_protocols_efeatures,
+)
+from emodelrunner.fi_curve import compute_fi_curves, write_fi_curves
+from emodelrunner.hooks import load_hooks, run_hooks
+from emodelrunner.impedance import compute_zap_analyses
+from emodelrunner.logging_utilities import neuron_output_to_logger
 from emodelrunner.parsing_utilities import get_parser_args, set_verbosity
 from emodelrunner.protocols.create_protocols import ProtocolBuilder
+from emodelrunner.protocols.reader import ProtocolParser
 from emodelrunner.load import (
     load_config,
+    get_channel_blocks,
+    get_extracellular_args,
+    get_minis_args,
     get_prot_args,
     get_release_params,
+    get_stochastic_args,
+    get_synapse_recording_args,
 )
-from emodelrunner.output import write_current
-from emodelrunner.output import write_responses
+from emodelrunner.nwb_output import OUTPUT_FORMATS, write_nwb
+from emodelrunner.pharmacology import apply_channel_blocks
+from emodelrunner.output import (
+    resample_responses,
+    write_binary_output,
+    write_current,
+    write_efeatures,
+    write_extracellular,
+    write_h5_output,
+    write_responses,
+    write_synapse_recordings,
+)
+from emodelrunner.plotting import plot_fi_curves, plot_responses
+from emodelrunner.progress import finish_progress_report
+from emodelrunner.provenance import get_provenance, write_provenance
+from emodelrunner.recordings import expand_current_responses
+from emodelrunner.simulators import create_simulator
+from emodelrunner.stochastic import get_trial_responses, set_channel_seed
+from emodelrunner.subthreshold import compute_subthreshold_properties
+from emodelrunner.summary import get_run_summary, write_run_summary
+from emodelrunner.synapses.minis import add_minis
+from emodelrunner.synapses.recordings import (
+    add_synapse_recordings,
+    pop_synapse_responses,
+)
+from emodelrunner.units import responses_with_units
+from emodelrunner.voltage_clamp import compute_voltage_clamp_curves
 
 logger = logging.getLogger(__name__)
 
 
-def main(config_path):
-    """Main.
+def run(config, write_output=True, units=False):
+    """Run the protocols of a sscx, thalamus or hippocampus config.
 
     Args:
-        config_path (str): path to config file
-            The config file should have '.ini' suffix
+        config (configparser.ConfigParser): configuration, as returned by load_config
+        write_output (bool): whether to write the responses, currents, summary,
+            efeatures and plots in the output directory and to run the hooks.
+            Set to False to only get the responses, e.g. in analysis loops.
+        units (bool): whether to return the responses with units, as pint quantities.
+            Needs pint to be installed. The output files are not affected.
+
+    Raises:
+        ValueError: if the package type or the output format is not supported
+
+    Returns:
+        dict: responses of the protocols, keyed by recording name.
+            See units.responses_with_units for the responses with units.
     """
     # pylint: disable=too-many-locals
-    config = load_config(config_path=config_path)
-
+    start_time = time.perf_counter()
     cell = create_cell_using_config(config)
     release_params = get_release_params(config)
+    # scale or zero the conductances of the blocked channels, if any
+    release_params, channel_blocks = apply_channel_blocks(
+        cell, release_params, get_channel_blocks(config)
+    )
 
     cvode_active = config.getboolean("Sim", "cvode_active")
 
     # simulator
     dt = config.getfloat("Sim", "dt")
-    sim = ephys.simulators.NrnSimulator(dt=dt, cvode_active=cvode_active)
+    sim = create_simulator(config, cvode_active, dt)
 
     # create protocols
     add_synapses = config.getboolean("Synapses", "add_synapses")
@@ -62,32 +129,271 @@ def main(config_path):
         protocols = ProtocolBuilder.using_thalamus_protocols(
             add_synapses, prot_args, cell
         )
+    elif config.package_type == PackageType.hippocampus:
+        protocols = ProtocolBuilder.using_hippocampus_protocols(
+            add_synapses, prot_args, cell
+        )
     else:
         raise ValueError(f"unsupported package type: {config.package_type}")
     ephys_protocols = protocols.get_ephys_protocols()
 
+    # record the extracellular potential at the electrodes, if any
+    mtype = config.get("Morphology", "mtype")
+    add_extracellular_recordings(
+        ephys_protocols, get_extracellular_args(config), prefix=mtype
+    )
+    # record the variables of the individual synapses, if any
+    add_synapse_recordings(
+        ephys_protocols, cell, get_synapse_recording_args(config), prefix=mtype
+    )
+    # spontaneous release of the synapses, if any
+    add_minis(ephys_protocols, cell, get_minis_args(config))
+
     # run
     logger.info("Python Recordings Running...")
-    responses = ephys_protocols.run(
-        cell_model=cell, param_values=release_params, sim=sim, isolate=False
+    # with a trace for each recorded current, e.g. 'L5TPC.Step_150.soma.ina_NaTg'
+    responses = expand_current_responses(
+        ephys_protocols.run(
+            cell_model=cell, param_values=release_params, sim=sim, isolate=False
+        )
     )
+    # the other trials of the stochastic channels, each with its own channel seed
+    stochastic_args = get_stochastic_args(config)
+    for trial in range(1, stochastic_args["n_trials"]):
+        logger.info("Running trial %d", trial)
+        set_channel_seed(cell, stochastic_args["channel_seed"] + trial)
+        responses.update(
+            get_trial_responses(
+                expand_current_responses(
+                    ephys_protocols.run(
+                        cell_model=cell,
+                        param_values=release_params,
+                        sim=sim,
+                        isolate=False,
+                    )
+                ),
+                trial,
+            )
+        )
+    set_channel_seed(cell, stochastic_args["channel_seed"])
 
-    mtype = config.get("Morphology", "mtype")
-    if config.package_type == PackageType.sscx:
+    if not write_output:
+        finish_progress_report(sim)
+        logger.info("Python Recordings Done")
+        return responses_with_units(responses) if units else responses
+
+    # kept apart from the intracellular responses, and written alongside them
+    extracellular = pop_extracellular_responses(responses)
+    synapse_responses = pop_synapse_responses(responses)
+
+    if config.package_type in [PackageType.sscx, PackageType.hippocampus]:
         currents = protocols.get_stim_currents(responses, dt)
     elif config.package_type == PackageType.thalamus:
         currents = protocols.get_thalamus_stim_currents(responses, mtype, dt)
 
+    # provenance of the run, with the seeds needed to reproduce the noise stimuli
+    provenance = get_provenance(config)
+    provenance["noise_seeds"] = protocols.get_noise_seeds()
+    provenance["channel_blocks"] = channel_blocks
+
+    # with the variable time step, write the traces on a regular grid
+    output_responses = responses
+    output_currents = currents
+    output_extracellular = extracellular
+    output_synapse_responses = synapse_responses
+    if cvode_active and config.getboolean("Sim", "resample_output"):
+        output_responses = resample_responses(responses, dt)
+        # on the same time grid as the voltage
+        output_currents = resample_responses(currents, dt)
+        output_extracellular = resample_responses(extracellular, dt)
+        output_synapse_responses = resample_responses(synapse_responses, dt)
+
     # write responses
     output_dir = config.get("Paths", "output_dir")
-    write_responses(responses, output_dir)
-    write_current(currents, output_dir)
+    if config.get("Analysis", "output_format") == "nwb":
+        emodel = config.get("Cell", "emodel")
+        write_nwb(
+            os.path.join(output_dir, f"{emodel}.nwb"),
+            output_responses,
+            output_currents,
+            emodel=emodel,
+            cell_id=config.getint("Cell", "gid"),
+            mtype=mtype,
+            provenance=provenance,
+            extracellular=output_extracellular,
+        )
+    elif config.get("Analysis", "output_format") == "h5":
+        write_h5_output(
+            output_responses,
+            output_currents,
+            os.path.join(output_dir, f"{config.get('Cell', 'emodel')}.h5"),
+            compression_level=config.getint("Analysis", "h5_compression"),
+            provenance=provenance,
+        )
+        if output_extracellular:
+            write_extracellular(output_extracellular, output_dir)
+    elif config.get("Analysis", "output_format") == "npy":
+        write_binary_output(
+            output_responses,
+            output_currents,
+            os.path.join(output_dir, f"{config.get('Cell', 'emodel')}.npy"),
+            provenance=provenance,
+        )
+        if output_extracellular:
+            write_extracellular(output_extracellular, output_dir)
+    elif config.get("Analysis", "output_format") == "dat":
+        write_responses(output_responses, output_dir)
+        write_current(output_currents, output_dir)
+        if output_extracellular:
+            write_extracellular(output_extracellular, output_dir)
+    else:
+        raise ValueError(
+            f"Unsupported output format {config.get('Analysis', 'output_format')}. "
+            f"It should be one of {OUTPUT_FORMATS}."
+        )
+    # written as h5 whatever the output format
+    if output_synapse_responses:
+        write_synapse_recordings(output_synapse_responses, output_dir)
+
+    # write summary, flagging e.g. traces in depolarization block,
+    # with the first spike latency curve of the step protocols
+    # and the provenance of the run
+    stim_windows = protocols.get_stim_windows()
+    summary = get_run_summary(
+        responses, stim_windows, step_amplitudes=protocols.get_step_amplitudes()
+    )
+    summary["provenance"] = provenance
+    # temperature and initial voltage actually used by each protocol
+    summary["neuron_globals"] = protocols.get_neuron_globals(
+        config.getfloat("Cell", "celsius"), config.getfloat("Cell", "v_init")
+    )
+    # holding current and rheobase, to set relative amplitudes in later runs
+    searched_currents = protocols.get_searched_currents()
+    if searched_currents:
+        summary["searched_currents"] = searched_currents
+    # input resistance, membrane time constant and sag of the subthreshold protocols
+    subthreshold = compute_subthreshold_properties(
+        responses, protocols.get_subthreshold_protocols(), stim_windows
+    )
+    if subthreshold:
+        summary["subthreshold"] = subthreshold
+    # low-threshold and rebound bursts of the thalamus burst protocols
+    bursts = compute_burst_features(responses, protocols.get_burst_protocols())
+    if bursts:
+        summary["bursts"] = bursts
+    # impedance profile, resonance frequency and Q-factor of the chirp protocols
+    impedance = compute_zap_analyses(
+        responses, currents, protocols.get_chirp_protocols()
+    )
+    if impedance:
+        summary["impedance"] = impedance
+    # bAP and EPSP attenuation with the distance from the soma
+    attenuation = compute_attenuations(responses, protocols.get_attenuation_protocols())
+    if attenuation:
+        summary["attenuation"] = attenuation
+    # activation and inactivation curves of the voltage clamp protocols
+    voltage_clamp = compute_voltage_clamp_curves(
+        responses, protocols.get_voltage_clamp_protocols()
+    )
+    if voltage_clamp:
+        summary["voltage_clamp"] = voltage_clamp
+    write_run_summary(summary, output_dir)
+
+    # firing rate versus step amplitude of the f-I curve protocols, if any
+    fi_curves = compute_fi_curves(
+        responses, protocols.get_fi_curve_protocols(), stim_windows
+    )
+    if fi_curves:
+        write_fi_curves(fi_curves, output_dir)
+        plot_fi_curves(fi_curves, output_dir, config.get("Analysis", "plot_format"))
+
+    # extract the efeatures attached to each protocol
+    # and the ones of the config for every protocol, if any
+    protocols_efeatures = add_config_efeatures(
+        get_protocols_efeatures(
+            ProtocolParser.load_protocol_json(prot_args["prot_path"])
+        ),
+        json.loads(config.get("Analysis", "efeatures")),
+        responses,
+        mtype,
+    )
+    if protocols_efeatures:
+        efeatures = extract_protocols_efeatures(
+            responses,
+            protocols_efeatures,
+            stim_windows,
+            mtype,
+            stim_amplitudes=protocols.get_step_amplitudes(),
+        )
+        write_efeatures(efeatures, output_dir)
 
+    # z-scores of the model features with respect to the optimization targets
+    if config.getboolean("Analysis", "compare_to_targets"):
+        target_efeatures = define_efeatures(
+            get_all_subprotocols(get_fitness_protocols(ephys_protocols)),
+            prot_args["features_path"],
+            mtype,
+            skip_missing=True,
+        )
+        write_target_comparison(
+            compare_to_targets(
+                target_efeatures,
+                responses,
+                config.getfloat("Analysis", "max_zscore"),
+            ),
+            output_dir,
+        )
+
+    if config.getboolean("Analysis", "plot_responses"):
+        plot_responses(
+            output_responses,
+            output_currents,
+            output_dir,
+            config.get("Analysis", "plot_format"),
+        )
+
+    # run the user-defined analyses, the hooks of the config only for this run
+    run_hooks(
+        responses,
+        output_dir,
+        hooks=load_hooks(json.loads(config.get("Analysis", "hooks"))),
+    )
+
+    write_provenance(
+        provenance,
+        os.path.join(output_dir, "provenance.json"),
+        wall_time=time.perf_counter() - start_time,
+    )
+
+    finish_progress_report(sim)
     logger.info("Python Recordings Done")
 
+    responses.update(extracellular)
+    responses.update(synapse_responses)
+    return responses_with_units(responses) if units else responses
+
+
+def main(config_path, write_output=True, units=False, config_overrides=None):
+    """Main.
+
+    Args:
+        config_path (str): path to config file
+            The config file should have '.ini' suffix
+        write_output (bool): whether to write the output files and to run the hooks
+        units (bool): whether to return the responses with units
+        config_overrides (list of str): values overriding the ones of the config file,
+            given as 'section.key=value', e.g. 'Cell.celsius=36'
+
+    Returns:
+        dict: responses of the protocols, keyed by recording name
+    """
+    config = load_config(config_path=config_path, config_overrides=config_overrides)
+    return run(config, write_output, units)
+
 
 if __name__ == "__main__":
     args = get_parser_args()
-    set_verbosity(args.verbosity)
+    set_verbosity(args.verbosity, args.log_level, args.log_file)
 
-    main(config_path=args.config_path)
+    with neuron_output_to_logger():
+        main(config_path=args.config_path, config_overrides=args.config_overrides)
@@ -16,18 +16,24 @@
 
 import json
 import logging
+import time
+from pathlib import Path
 
 import numpy as np
-from bluepyopt import ephys
 from emodelrunner.create_cells import get_precell, get_postcell
+from emodelrunner.logging_utilities import neuron_output_to_logger
 from emodelrunner.parsing_utilities import get_parser_args, set_verbosity
 from emodelrunner.protocols.create_protocols import define_pairsim_protocols
 from emodelrunner.load import get_presyn_stim_args
+from emodelrunner.load import get_recording_sampling_args
 from emodelrunner.load import get_release_params
 from emodelrunner.load import get_syn_setup_params
 from emodelrunner.load import load_config
 from emodelrunner.output import write_synplas_output
 from emodelrunner.output import write_synplas_precell_output
+from emodelrunner.progress import finish_progress_report
+from emodelrunner.provenance import get_provenance, write_provenance
+from emodelrunner.simulators import create_simulator
 from emodelrunner.run_synplas import _set_global_params
 
 # Configure logger
@@ -40,6 +46,8 @@ def run(
     postsyn_protocol_name="pulse",
     presyn_protocol_name="presyn_pulse",
     fixhp=True,
+    write_output=True,
+    config_overrides=None,
 ):
     """Run cell with pulse stimuli and pre-cell spike train.
 
@@ -49,9 +57,17 @@ def run(
         postsyn_protocol_name (str): name of the postsynaptic protocol
         presyn_protocol_name (str): name of the presynaptic protocol
         fixhp (bool): to uninsert SK_E2 for hyperpolarization in cell model
+        write_output (bool): whether to write the responses in the output files
+        config_overrides (list of str): values overriding the ones of the config file,
+            given as 'section.key=value', e.g. 'Cell.celsius=36'
+
+    Returns:
+        list: responses of the precell and responses of the postcell,
+            each keyed by recording name
     """
     # pylint:disable=too-many-locals
-    config = load_config(config_path=config_path)
+    start_time = time.perf_counter()
+    config = load_config(config_path=config_path, config_overrides=config_overrides)
 
     # load extra_params
     syn_setup_params = get_syn_setup_params(
@@ -75,7 +91,7 @@ def run(
         fixhp=fixhp,
     )
 
-    sim = ephys.simulators.NrnSimulator(cvode_active=cvode_active)
+    sim = create_simulator(config, cvode_active)
     pre_release_params = get_release_params(config, precell=True)
     post_release_params = get_release_params(config)
 
@@ -108,6 +124,7 @@ def run(
         config.getfloat("SynapsePlasticity", "fastforward"),
         presyn_stim_args,
         config.get("Paths", "stimuli_path"),
+        sampling=get_recording_sampling_args(config, "SynapsePlasticity"),
     )
 
     # run
@@ -123,19 +140,39 @@ def run(
     )
 
     # write responses
-    output_path = config.get("Paths", "pairsim_output_path")
-    precell_output_path = config.get("Paths", "pairsim_precell_output_path")
-    syn_prop_path = config.get("Paths", "syn_prop_path")
-    write_synplas_output(responses[1], pre_spike_train, output_path, syn_prop_path)
-    write_synplas_precell_output(
-        responses[0], presyn_protocol_name, precell_output_path
-    )
-
+    if write_output:
+        output_path = config.get("Paths", "pairsim_output_path")
+        precell_output_path = config.get("Paths", "pairsim_precell_output_path")
+        syn_prop_path = config.get("Paths", "syn_prop_path")
+        provenance = get_provenance(config)
+        write_synplas_output(
+            responses[1],
+            pre_spike_train,
+            output_path,
+            syn_prop_path,
+            provenance=provenance,
+        )
+        write_synplas_precell_output(
+            responses[0],
+            presyn_protocol_name,
+            precell_output_path,
+            provenance=provenance,
+        )
+        write_provenance(
+            provenance,
+            f"{Path(output_path).with_suffix('')}_provenance.json",
+            wall_time=time.perf_counter() - start_time,
+        )
+
+    finish_progress_report(sim)
     logger.info("Python Recordings Done.")
 
+    return responses
+
 
 if __name__ == "__main__":
     args = get_parser_args()
-    set_verbosity(args.verbosity)
+    set_verbosity(args.verbosity, args.log_level, args.log_file)
 
-    run(config_path=args.config_path)
+    with neuron_output_to_logger():
+        run(config_path=args.config_path, config_overrides=args.config_overrides)
@@ -17,17 +17,33 @@
 import json
 import logging
 import re
+import time
+from pathlib import Path
 
 import numpy as np
 
-from bluepyopt import ephys
 from emodelrunner.create_cells import get_postcell
+from emodelrunner.logging_utilities import neuron_output_to_logger
 from emodelrunner.parsing_utilities import get_parser_args, set_verbosity
 from emodelrunner.protocols.create_protocols import define_synapse_plasticity_protocols
+from emodelrunner.load import get_checkpoint_args
+from emodelrunner.load import get_pairing_args
+from emodelrunner.load import get_recording_sampling_args
 from emodelrunner.load import get_release_params
 from emodelrunner.load import get_syn_setup_params
 from emodelrunner.load import load_config
+from emodelrunner.nwb_output import write_nwb
 from emodelrunner.output import write_synplas_output
+from emodelrunner.progress import finish_progress_report
+from emodelrunner.provenance import get_provenance, write_provenance
+from emodelrunner.simulators import create_simulator
+from emodelrunner.synplas_pairing import (
+    check_pairing_args,
+    compute_pairing_epsps,
+    get_pairing_timeline,
+    write_pairing_epsps,
+    write_pairing_protocol,
+)
 
 # Configure logger
 logger = logging.getLogger(__name__)
@@ -54,6 +70,8 @@ def run(
     cvode_active=True,
     protocol_name="pulse",
     fixhp=True,
+    write_output=True,
+    config_overrides=None,
 ):
     """Run cell with pulse stimuli and pre-cell spike train.
 
@@ -62,8 +80,15 @@ def run(
         cvode_active (bool): whether to use variable time step
         protocol_name (str): name of the protocol
         fixhp (bool): to uninsert SK_E2 for hyperpolarization in cell model
+        write_output (bool): whether to write the responses in the output file
+        config_overrides (list of str): values overriding the ones of the config file,
+            given as 'section.key=value', e.g. 'Cell.celsius=36'
+
+    Returns:
+        dict: responses of the protocol, keyed by recording name
     """
-    config = load_config(config_path=config_path)
+    start_time = time.perf_counter()
+    config = load_config(config_path=config_path, config_overrides=config_overrides)
 
     # load extra_params
     syn_setup_params = get_syn_setup_params(
@@ -81,14 +106,42 @@ def run(
         syn_setup_params=syn_setup_params,
     )
 
-    sim = ephys.simulators.NrnSimulator(cvode_active=cvode_active)
+    sim = create_simulator(config, cvode_active)
     release_params = get_release_params(config)
 
     # set dynamic timestep tolerance
     sim.neuron.h.cvode.atolscale("v", 0.1)  # 0.01 for more precision
 
+    tstop = config.getfloat("Protocol", "tstop")
+    fastforward = config.getfloat("SynapsePlasticity", "fastforward")
+    stimuli_path = config.get("Paths", "stimuli_path")
+    output_path = config.get("Paths", "synplas_output_path")
+
+    pairing_args = get_pairing_args(config)
+    if pairing_args["enabled"]:
+        # build the pairing protocol files next to the output
+        check_pairing_args(pairing_args)
+        output_stem = Path(output_path).with_suffix("")
+        spike_train_path = f"{output_stem}_spiketrain.dat"
+        stimuli_path = f"{output_stem}_stimuli.json"
+        write_pairing_protocol(
+            pairing_args,
+            spike_train_path,
+            stimuli_path,
+            config.getint("Cell", "precell_gid"),
+        )
+        timeline = get_pairing_timeline(pairing_args)
+        tstop = timeline["tstop"]
+        # the synapses are fast-forwarded after the induction
+        fastforward = timeline["c02_start"]
+    else:
+        spike_train_path = config.get("Paths", "spiketrain_path")
+
+    checkpoint_args = get_checkpoint_args(config)
+    if checkpoint_args is not None and config.get("Sim", "simulator") == "coreneuron":
+        raise ValueError("The checkpoints are not supported by CoreNEURON.")
+
     # get pre_spike_train
-    spike_train_path = config.get("Paths", "spiketrain_path")
     pre_spike_train = np.unique(np.loadtxt(spike_train_path, skiprows=1)[:, 0])
 
     # Set fitted model parameters
@@ -106,9 +159,11 @@ def run(
         protocol_name,
         cvode_active,
         json.loads(config.get("SynapsePlasticity", "synrec")),
-        config.getfloat("Protocol", "tstop"),
-        config.getfloat("SynapsePlasticity", "fastforward"),
-        config.get("Paths", "stimuli_path"),
+        tstop,
+        fastforward,
+        stim_path=stimuli_path,
+        checkpoint_args=checkpoint_args,
+        sampling=get_recording_sampling_args(config, "SynapsePlasticity"),
     )
 
     # run
@@ -119,15 +174,52 @@ def run(
     )
 
     # write responses
-    output_path = config.get("Paths", "synplas_output_path")
-    syn_prop_path = config.get("Paths", "syn_prop_path")
-    write_synplas_output(responses, pre_spike_train, output_path, syn_prop_path)
-
+    if write_output:
+        syn_prop_path = config.get("Paths", "syn_prop_path")
+        provenance = get_provenance(config)
+        if output_path.endswith(".nwb"):
+            write_nwb(
+                output_path,
+                responses,
+                emodel=config.get("Cell", "emodel"),
+                cell_id=config.getint("Cell", "gid"),
+                provenance=provenance,
+                scratch={"prespikes": pre_spike_train},
+            )
+        else:
+            write_synplas_output(
+                responses,
+                pre_spike_train,
+                output_path,
+                syn_prop_path,
+                provenance=provenance,
+            )
+
+        # EPSP before and after the pairings
+        if pairing_args["enabled"]:
+            epsps = compute_pairing_epsps(
+                np.array(responses[protocol_name]["time"]),
+                np.array(responses[protocol_name]["voltage"]),
+                pre_spike_train,
+                pairing_args,
+            )
+            write_pairing_epsps(epsps, f"{output_stem}_epsp.json")
+
+        write_provenance(
+            provenance,
+            f"{Path(output_path).with_suffix('')}_provenance.json",
+            wall_time=time.perf_counter() - start_time,
+        )
+
+    finish_progress_report(sim)
     logger.info("Python Recordings Done.")
 
+    return responses
+
 
 if __name__ == "__main__":
     args = get_parser_args()
-    set_verbosity(args.verbosity)
+    set_verbosity(args.verbosity, args.log_level, args.log_file)
 
-    run(config_path=args.config_path)
+    with neuron_output_to_logger():
+        run(config_path=args.config_path, config_overrides=args.config_overrides)
@@ -0,0 +1,132 @@
+"""Seeds of all the random number streams derived from a single global seed."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import hashlib
+import logging
+
+logger = logging.getLogger(__name__)
+
+# the derived seeds fit in the signed integers of hoc and of the mod files
+MAX_SEED = 2**31 - 1
+
+# seeds of the config that are replaced by the ones derived from the global seed
+CONFIG_SEED_KEYS = [
+    ("Synapses", "seed"),
+    ("Sim", "channel_seed"),
+    ("SynapsePlasticity", "base_seed"),
+    ("Minis", "seed"),
+]
+
+# protocol types whose synapses are activated by random spike trains
+SPIKE_TRAIN_PROTOCOL_TYPES = ["Vecstim", "Netstim", "SpikeGenerators"]
+
+
+def derive_seed(seed, stream):
+    """Return the seed of a random number stream derived from the global seed.
+
+    The derived seed only depends on the global seed and on the name of the stream,
+    so that adding a stream does not change the seeds of the other ones.
+
+    Args:
+        seed (int): global seed
+        stream (str): name of the random number stream, e.g. 'Synapses.seed'
+
+    Returns:
+        int: seed of the stream, between 0 and MAX_SEED
+    """
+    digest = hashlib.sha256(f"{seed}:{stream}".encode("utf-8")).digest()
+    return int.from_bytes(digest[:8], "little") % MAX_SEED
+
+
+def get_global_seed(config):
+    """Return the global seed of the config.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+
+    Returns:
+        int: global seed, or None if it is not set
+    """
+    seed = config.get("Sim", "seed", fallback="")
+    return int(seed) if seed else None
+
+
+def apply_global_seed(config):
+    """Replace the seeds of the config by the ones derived from the global seed.
+
+    The config is not changed if the global seed is not set.
+
+    Args:
+        config (configparser.ConfigParser): configuration, modified in place
+
+    Returns:
+        dict: derived seeds keyed by 'section.key'
+    """
+    seed = get_global_seed(config)
+    if seed is None:
+        return {}
+
+    derived_seeds = {}
+    for section, key in CONFIG_SEED_KEYS:
+        if config.has_option(section, key):
+            stream = f"{section}.{key}"
+            derived_seeds[stream] = derive_seed(seed, stream)
+            config.set(section, key, str(derived_seeds[stream]))
+    logger.debug("Seeds derived from the global seed %d: %s", seed, derived_seeds)
+    return derived_seeds
+
+
+def seed_protocol_definitions(protocol_definitions, seed):
+    """Set the seeds of the random stimuli of the protocols from the global seed.
+
+    The seeds of the noise stimuli, of the noisy conductances and of the spike trains
+    activating the synapses replace the ones of the protocols file.
+
+    Args:
+        protocol_definitions (dict): protocol definitions keyed by protocol name,
+            modified in place
+        seed (int): global seed. The definitions are not changed if None.
+
+    Returns:
+        dict: the protocol definitions
+    """
+    if seed is None:
+        return protocol_definitions
+
+    for name, definition in protocol_definitions.items():
+        # the phases of the chained protocols are named after the chain
+        if isinstance(definition.get("phases"), dict):
+            seed_protocol_definitions(
+                {
+                    f"{name}_{phase_name}": phase_definition
+                    for phase_name, phase_definition in definition["phases"].items()
+                },
+                seed,
+            )
+        stimuli = definition.get("stimuli")
+        if not isinstance(stimuli, dict):
+            continue
+        if isinstance(stimuli.get("noise"), dict):
+            stimuli["noise"]["seed"] = derive_seed(seed, f"{name}.noise")
+        for conductance_name, conductance in stimuli.get("conductance", {}).items():
+            # the other entries are the timings of the conductances
+            if isinstance(conductance, dict):
+                conductance["seed"] = derive_seed(seed, f"{name}.{conductance_name}")
+        if definition.get("type") in SPIKE_TRAIN_PROTOCOL_TYPES:
+            stimuli["syn_stim_seed"] = derive_seed(seed, f"{name}.syn_stim")
+
+    return protocol_definitions
@@ -0,0 +1,354 @@
+"""Sensitivity of the e-features to perturbations of the e-model parameters."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import json
+import logging
+from pathlib import Path
+
+import numpy as np
+import pandas as pd
+
+from emodelrunner.load import load_config
+from emodelrunner.sweep import run_sweep
+
+logger = logging.getLogger(__name__)
+
+SENSITIVITY_METHODS = ["oat", "lhs"]
+
+
+def load_sensitivity_definition(sensitivity_path):
+    """Load the perturbed parameters and the perturbation method.
+
+    Args:
+        sensitivity_path (str or Path): path to the json file defining the analysis,
+            e.g. {"method": "oat", "parameters": ["gNaTgbar", "gSKv3_1bar"],
+            "perturbations": [-10, 10]}
+
+    Raises:
+        ValueError: if the method is not supported, if no parameter is given,
+            or if a perturbation is not above -100%
+
+    Returns:
+        dict: the method, the perturbed parameters, the perturbations (%)
+            of the one-at-a-time method, and the range (%), number of samples
+            and seed of the Latin hypercube method
+    """
+    with open(sensitivity_path, "r", encoding="utf-8") as sensitivity_file:
+        definition = json.load(sensitivity_file)
+
+    sensitivity_definition = {
+        "method": definition.get("method", "oat"),
+        "parameters": definition.get("parameters"),
+        "perturbations": definition.get("perturbations", [-10, 10]),
+        "range": definition.get("range", 10),
+        "n_samples": definition.get("n_samples", 20),
+        "seed": definition.get("seed", 0),
+    }
+    if sensitivity_definition["method"] not in SENSITIVITY_METHODS:
+        raise ValueError(
+            f"Unsupported sensitivity method {sensitivity_definition['method']}. "
+            f"Expected one of {SENSITIVITY_METHODS}."
+        )
+    parameters = sensitivity_definition["parameters"]
+    if not isinstance(parameters, list) or not parameters:
+        raise ValueError(f"No parameters to perturb are defined in {sensitivity_path}.")
+    perturbations = list(sensitivity_definition["perturbations"]) + [
+        sensitivity_definition["range"],
+        -sensitivity_definition["range"],
+    ]
+    if not all(
+        isinstance(perturbation, (int, float)) and perturbation != 0
+        for perturbation in perturbations
+    ) or any(perturbation <= -100 for perturbation in perturbations):
+        raise ValueError(
+            "The perturbations and the range should be non-zero percentages "
+            "above -100."
+        )
+
+    return sensitivity_definition
+
+
+def get_perturbation_points(
+    parameters,
+    method="oat",
+    perturbations=(-10, 10),
+    sample_range=10,
+    n_samples=20,
+    seed=0,
+):
+    """Return the perturbation (%) of each parameter for each run.
+
+    Args:
+        parameters (list of str): names of the perturbed parameters,
+            as in the channel blocks, e.g. 'gNaTgbar' or 'gNaTgbar_NaTg.axonal'
+        method (str): "oat" to perturb each parameter by each perturbation,
+            one at a time, "lhs" to perturb all the parameters together
+            with a Latin hypercube sampling of [-sample_range, sample_range]
+        perturbations (list of float): perturbations (%) of the "oat" method
+        sample_range (float): maximum perturbation (%) of the "lhs" method
+        n_samples (int): number of samples of the "lhs" method
+        seed (int): seed of the "lhs" sampling
+
+    Raises:
+        ValueError: if the method is not supported
+
+    Returns:
+        list of dict: the perturbation (%) of each perturbed parameter, for each run
+    """
+    # pylint: disable=too-many-arguments
+    if method == "oat":
+        return [
+            {name: perturbation}
+            for name in parameters
+            for perturbation in perturbations
+        ]
+    if method == "lhs":
+        rng = np.random.default_rng(seed)
+        # one sample in each of the n_samples strata of each parameter
+        strata = np.array([rng.permutation(n_samples) for _ in parameters]).T
+        samples = (strata + rng.random(strata.shape)) / n_samples
+        perturbations = sample_range * (2 * samples - 1)
+        return [dict(zip(parameters, sample.tolist())) for sample in perturbations]
+    raise ValueError(
+        f"Unsupported sensitivity method {method}. "
+        f"Expected one of {SENSITIVITY_METHODS}."
+    )
+
+
+def get_channel_block(point, base_block=""):
+    """Return the channel blocks scaling the parameters of a run.
+
+    Args:
+        point (dict): the perturbation (%) of each perturbed parameter
+        base_block (str): channel blocks of the base config, applied first
+
+    Returns:
+        str: the channel blocks of the run, e.g. 'gNaTgbar*1.1, gSKv3_1bar*0.9'
+    """
+    blocks = [base_block] if base_block.strip() else []
+    blocks += [
+        f"{name}*{1 + perturbation / 100}" for name, perturbation in point.items()
+    ]
+    return ", ".join(blocks)
+
+
+def read_run_efeatures(run_dir):
+    """Read the mean value of each e-feature of a run.
+
+    Args:
+        run_dir (str or Path): output directory of the run
+
+    Returns:
+        dict: mean value of each feature, keyed by 'protocol.feature',
+            NaN for the features that could not be computed.
+            Empty if the run did not write any e-feature.
+    """
+    efeatures_path = Path(run_dir) / "efeatures.json"
+    if not efeatures_path.is_file():
+        return {}
+    with open(efeatures_path, "r", encoding="utf-8") as efeatures_file:
+        efeatures = json.load(efeatures_file)
+
+    return {
+        f"{protocol_name}.{feature_name}": float(np.mean(values)) if values else np.nan
+        for protocol_name, features in efeatures.items()
+        for feature_name, values in features.items()
+    }
+
+
+def compute_sensitivities(baseline, features, points, method="oat"):
+    """Compute the sensitivity of each e-feature to each parameter.
+
+    The sensitivity is the relative change of the feature
+    divided by the relative change of the parameter, i.e. 1 if the feature
+    is proportional to the parameter. It is averaged over the perturbations
+    of the "oat" method, and is the coefficient of the linear regression
+    of the relative changes of the feature on the ones of all the parameters
+    for the "lhs" method.
+
+    Args:
+        baseline (dict): value of each feature without perturbation
+        features (list of dict): value of each feature for each perturbed run
+        points (list of dict): the perturbation (%) of each parameter for each run
+        method (str): "oat" or "lhs", the method used to get the points
+
+    Returns:
+        pandas.DataFrame: parameter, feature and sensitivity, NaN if the feature
+            is zero or could not be computed in the baseline or in the runs
+    """
+    parameters = list(dict.fromkeys(name for point in points for name in point))
+    rows = []
+    for feature_name, base_value in baseline.items():
+        changes = np.array(
+            [
+                run_features.get(feature_name, np.nan) / base_value - 1
+                if base_value
+                else np.nan
+                for run_features in features
+            ]
+        )
+        if method == "oat":
+            for name in parameters:
+                run_ids = [i for i, point in enumerate(points) if name in point]
+                ratios = [changes[i] / (points[i][name] / 100) for i in run_ids]
+                sensitivity = (
+                    np.nan if np.all(np.isnan(ratios)) else float(np.nanmean(ratios))
+                )
+                rows.append((name, feature_name, sensitivity))
+        else:
+            valid = ~np.isnan(changes)
+            perturbations = np.array(
+                [[point[name] / 100 for name in parameters] for point in points]
+            )
+            if valid.sum() > len(parameters):
+                coefficients = np.linalg.lstsq(
+                    np.column_stack([perturbations[valid], np.ones(valid.sum())]),
+                    changes[valid],
+                    rcond=None,
+                )[0][:-1]
+            else:
+                coefficients = np.full(len(parameters), np.nan)
+            rows += [
+                (name, feature_name, float(coefficient))
+                for name, coefficient in zip(parameters, coefficients)
+            ]
+
+    return pd.DataFrame(rows, columns=["parameter", "feature", "sensitivity"])
+
+
+def rank_parameters(sensitivities):
+    """Rank the parameters by the mean absolute sensitivity of the features.
+
+    Args:
+        sensitivities (pandas.DataFrame): parameter, feature and sensitivity,
+            as returned by compute_sensitivities
+
+    Returns:
+        pandas.DataFrame: rank, parameter, mean and max absolute sensitivity,
+            and the feature the most sensitive to the parameter,
+            from the most to the least influential parameter
+    """
+    rows = []
+    for name, group in sensitivities.groupby("parameter", sort=False):
+        abs_sensitivities = group["sensitivity"].abs()
+        most_sensitive_feature = ""
+        if abs_sensitivities.notna().any():
+            most_sensitive_feature = group["feature"][abs_sensitivities.idxmax()]
+        rows.append(
+            {
+                "parameter": name,
+                "mean_abs_sensitivity": abs_sensitivities.mean(),
+                "max_abs_sensitivity": abs_sensitivities.max(),
+                "most_sensitive_feature": most_sensitive_feature,
+            }
+        )
+
+    ranking = pd.DataFrame(
+        rows,
+        columns=[
+            "parameter",
+            "mean_abs_sensitivity",
+            "max_abs_sensitivity",
+            "most_sensitive_feature",
+        ],
+    )
+    ranking = ranking.sort_values(
+        "mean_abs_sensitivity", ascending=False, na_position="last"
+    ).reset_index(drop=True)
+    ranking.insert(0, "rank", range(1, len(ranking) + 1))
+    return ranking
+
+
+def run_sensitivity(
+    config_path,
+    sensitivity_definition,
+    output_dir,
+    n_processes=None,
+    config_overrides=None,
+):
+    """Run the sensitivity analysis of the e-features of a sscx or thalamus config.
+
+    The parameters are scaled with the channel blocks of the Pharmacology section.
+    The baseline and the perturbed runs are run as a sweep, in output_dir/run_0000
+    (the baseline), output_dir/run_0001, etc.
+    The e-features are the ones attached to the protocols of the protocols file
+    and the ones of the Analysis section of the config.
+    The sensitivity of each feature to each parameter is written
+    in output_dir/sensitivity.csv, and the ranking of the parameters
+    in output_dir/sensitivity_ranking.csv.
+
+    Args:
+        config_path (str): path to the base config of the analysis
+        sensitivity_definition (dict): the method and the perturbations,
+            as returned by load_sensitivity_definition
+        output_dir (str or Path): directory in which to write the outputs
+        n_processes (int): number of runs in parallel. The number of CPUs if None.
+        config_overrides (list of str): values overriding the ones of the base config,
+            given as 'section.key=value'
+
+    Raises:
+        RuntimeError: if the baseline run failed or did not extract any e-feature
+
+    Returns:
+        tuple of pandas.DataFrame: the sensitivity of each feature to each parameter,
+            and the ranking of the parameters
+    """
+    # pylint: disable=too-many-locals
+    config = load_config(config_path, config_overrides)
+    base_block = config.get("Pharmacology", "block", fallback="")
+
+    method = sensitivity_definition["method"]
+    points = get_perturbation_points(
+        sensitivity_definition["parameters"],
+        method,
+        sensitivity_definition["perturbations"],
+        sensitivity_definition["range"],
+        sensitivity_definition["n_samples"],
+        sensitivity_definition["seed"],
+    )
+    blocks = [base_block] + [get_channel_block(point, base_block) for point in points]
+
+    logger.info("Running the baseline and %d perturbed runs.", len(points))
+    table = run_sweep(
+        config_path,
+        {"Pharmacology.block": blocks},
+        output_dir,
+        mode="list",
+        n_processes=n_processes,
+        config_overrides=config_overrides,
+    )
+
+    run_features = [
+        read_run_efeatures(run_dir) if status == "done" else {}
+        for run_dir, status in zip(table["run_dir"], table["status"])
+    ]
+    if not run_features[0]:
+        raise RuntimeError(
+            "The baseline run failed or did not extract any e-feature. "
+            "Attach efeatures to the protocols or set the efeatures of the config."
+        )
+
+    sensitivities = compute_sensitivities(
+        run_features[0], run_features[1:], points, method
+    )
+    ranking = rank_parameters(sensitivities)
+
+    output_dir = Path(output_dir)
+    sensitivities.to_csv(output_dir / "sensitivity.csv", index=False)
+    ranking.to_csv(output_dir / "sensitivity_ranking.csv", index=False)
+
+    return sensitivities, ranking
@@ -0,0 +1,189 @@
+"""Simulators running the protocols with NEURON or CoreNEURON."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import logging
+
+from bluepyopt import ephys
+
+from emodelrunner.progress import set_progress_reporter
+
+logger = logging.getLogger(__name__)
+
+SIMULATORS = ["neuron", "coreneuron"]
+DEFAULT_DT = 0.025
+
+
+class CoreNeuronSimulator(ephys.simulators.NrnSimulator):
+    """Simulator running the simulations with CoreNEURON.
+
+    The model is built with NEURON, and transferred in memory to CoreNEURON
+    at each run. The state of the model and the recorded vectors are copied back
+    to NEURON at the end of the run, so that the recordings, the stimuli
+    and the analyses are the same as with NEURON. CoreNEURON only supports
+    the fixed time step, and needs the mechanisms to be compiled
+    with 'nrnivmodl -coreneuron'.
+
+    Attributes:
+        gpu (bool): whether to run the simulations on GPU
+    """
+
+    def __init__(self, dt=DEFAULT_DT, gpu=False):
+        """Constructor.
+
+        Args:
+            dt (float): time step (ms)
+            gpu (bool): whether to run the simulations on GPU
+        """
+        super().__init__(dt=dt, cvode_active=False)
+        self.gpu = gpu
+
+    def run(
+        self, tstop=None, dt=None, cvode_active=None, random123_globalindex=None
+    ):
+        """Run the simulation with CoreNEURON.
+
+        Args:
+            tstop (float): duration of the simulation (ms)
+            dt (float): time step (ms). The time step of the simulator is used if None.
+            cvode_active (bool): ignored, CoreNEURON only supports the fixed time step
+            random123_globalindex (int): global index of the Random123 generators
+
+        Raises:
+            NrnSimulatorException: if the simulation fails
+        """
+        # pylint: disable=unused-argument
+        h = self.neuron.h
+
+        h.tstop = tstop
+        h.cvode_active(0)
+        h.dt = self.dt if dt is None else dt
+        h.steps_per_ms = 1.0 / h.dt
+        if random123_globalindex is not None:
+            h.Random123_globalindex(random123_globalindex)
+
+        logger.debug("Running a simulation of %s ms with CoreNEURON", tstop)
+        try:
+            # CoreNEURON needs the data to be contiguous in memory,
+            # which has to be set before the initialisation of the model
+            h.CVode().cache_efficient(1)
+            h.ParallelContext().set_maxstep(10)
+            h.stdinit()
+            self.continue_run(tstop)
+        except Exception as exc:
+            raise ephys.simulators.NrnSimulatorException(
+                "CoreNEURON simulator error", exc
+            ) from exc
+
+    def continue_run(self, tstop):
+        """Continue the current simulation with CoreNEURON up to tstop.
+
+        The model should have been initialised by run,
+        with the data contiguous in memory.
+
+        Args:
+            tstop (float): time at which to stop the simulation (ms)
+        """
+        # pylint: disable=import-outside-toplevel
+        from neuron import coreneuron
+
+        coreneuron.enable = True
+        coreneuron.gpu = self.gpu
+        try:
+            self.neuron.h.ParallelContext().psolve(tstop)
+        finally:
+            coreneuron.enable = False
+
+
+def set_cvode_tolerances(sim, config):
+    """Set the tolerances of the variable time step given in the config.
+
+    The sscx, thalamus and hippocampus configs always have the tolerances,
+    with their defaults if not set. The tolerances of NEURON are kept
+    for the configs without them, i.e. the synapse plasticity configs.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        config (configparser.ConfigParser): configuration
+    """
+    cvode = sim.neuron.h.CVode()
+    if config.has_option("Sim", "cvode_atol"):
+        cvode.atol(config.getfloat("Sim", "cvode_atol"))
+    if config.has_option("Sim", "cvode_rtol"):
+        cvode.rtol(config.getfloat("Sim", "cvode_rtol"))
+    atolscales = json.loads(config.get("Sim", "cvode_atolscale", fallback="{}"))
+    for state, scale in atolscales.items():
+        cvode.atolscale(state, scale)
+    logger.debug(
+        "Variable time step with atol %s and rtol %s", cvode.atol(), cvode.rtol()
+    )
+
+
+def create_simulator(config, cvode_active, dt=None):
+    """Create the simulator chosen in the config.
+
+    Args:
+        config (configparser.ConfigParser): configuration
+        cvode_active (bool): whether to use the variable time step.
+            Ignored by CoreNEURON, that only supports the fixed time step.
+        dt (float): time step (ms). Defaults to 0.025 ms for CoreNEURON.
+
+    Raises:
+        ValueError: if the simulator is not supported
+
+    Returns:
+        bluepyopt.ephys.NrnSimulator: the simulator
+    """
+    simulator = config.get("Sim", "simulator")
+    if simulator == "neuron":
+        sim = ephys.simulators.NrnSimulator(dt=dt, cvode_active=cvode_active)
+        if cvode_active:
+            set_cvode_tolerances(sim, config)
+    elif simulator == "coreneuron":
+        if cvode_active:
+            logger.warning(
+                "CoreNEURON does not support the variable time step, "
+                "the fixed time step is used."
+            )
+        sim = CoreNeuronSimulator(
+            dt=DEFAULT_DT if dt is None else dt,
+            gpu=config.getboolean("Sim", "coreneuron_gpu"),
+        )
+    else:
+        raise ValueError(
+            f"Unsupported simulator: {simulator}. Choose from {SIMULATORS}."
+        )
+    # warns that the progress cannot be reported with CoreNEURON
+    set_progress_reporter(sim, config)
+    return sim
+
+
+def continue_run(sim, tstop, cvode_active=True):
+    """Continue the current simulation up to tstop.
+
+    NEURON continues with the variable time step by default,
+    CoreNEURON with the fixed time step.
+
+    Args:
+        sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        tstop (float): time at which to stop the simulation (ms)
+        cvode_active (bool): whether NEURON continues with the variable time step
+    """
+    if isinstance(sim, CoreNeuronSimulator):
+        sim.continue_run(tstop)
+    else:
+        sim.neuron.h.cvode_active(int(cvode_active))
+        sim.neuron.h.continuerun(tstop)
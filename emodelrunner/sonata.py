@@ -0,0 +1,594 @@
+"""Read the cells, synapses and spikes of SONATA circuits."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import configparser
+import json
+import logging
+import shutil
+from pathlib import Path
+
+import numpy as np
+
+from emodelrunner.locations import SECTIONLIST_IDS
+from emodelrunner.morphology.formats import (
+    convert_morphology,
+    is_collection,
+    load_morphology,
+)
+from emodelrunner.parsing_utilities import get_sonata_parser_args, set_verbosity
+
+logger = logging.getLogger(__name__)
+
+# morphology formats, by order of preference
+MORPHOLOGY_FORMATS = [("neurolucida-asc", "asc"), ("h5v1", "h5")]
+
+# SONATA edge attribute of each column of the synapses tsv file,
+# with its default value (None if the attribute is required)
+SYNAPSE_ATTRIBUTES = {
+    "synapse_type": ("syn_type_id", None),
+    "dep": ("depression_time", None),
+    "fac": ("facilitation_time", None),
+    "use": ("u_syn", None),
+    "tau_d": ("decay_time", None),
+    "delay": ("delay", None),
+    "weight": ("conductance", None),
+    # older circuits have no vesicle pool size
+    "Nrrp": ("n_rrp_vesicles", 1),
+}
+
+# columns of the synapses tsv file, see load.load_synapses_tsv_data
+SYNAPSE_COLUMNS = [
+    "sid",
+    "pre_cell_id",
+    "sectionlist_id",
+    "sectionlist_index",
+    "seg_x",
+    "synapse_type",
+    "dep",
+    "fac",
+    "use",
+    "tau_d",
+    "delay",
+    "weight",
+    "Nrrp",
+    "pre_mtype",
+]
+
+
+def get_emodel_name(model_template):
+    """Return the e-model name of a SONATA model_template.
+
+    Args:
+        model_template (str): model template, e.g. 'hoc:cADpyr_L5TPC'
+
+    Returns:
+        str: the e-model name, e.g. 'cADpyr_L5TPC'
+    """
+    return model_template.split(":")[-1]
+
+
+def get_section_locations(section_types):
+    """Return the sectionlist id and index of each section of a morphology.
+
+    Args:
+        section_types (list of str): type of each neurite section, in morphology order,
+            e.g. ['axon', 'basal_dendrite', ...]. The soma is not included.
+
+    Returns:
+        list of tuples: (sectionlist_id, sectionlist_index) of each SONATA section id.
+            The first one is the soma.
+    """
+    counters = {section_type: 0 for section_type in SECTIONLIST_IDS}
+    locations = [(SECTIONLIST_IDS["soma"], 0)]
+    for section_type in section_types:
+        locations.append((SECTIONLIST_IDS[section_type], counters[section_type]))
+        counters[section_type] += 1
+    return locations
+
+
+def get_morphology_section_locations(morph_path, morph_name=""):
+    """Return the sectionlist id and index of each section of a morphology file.
+
+    Args:
+        morph_path (str or Path): path to the morphology, or to a directory
+            or a h5 container of morphologies
+        morph_name (str): name of the morphology in the directory or the container
+
+    Returns:
+        list of tuples: (sectionlist_id, sectionlist_index) of each SONATA section id
+    """
+    morph = load_morphology(morph_path, morph_name)
+    return get_section_locations([section.type.name for section in morph.sections])
+
+
+def get_morphology_path(node_properties, morphology_name):
+    """Return the path to the morphology of a node, preferring the asc format.
+
+    Args:
+        node_properties (libsonata.NodePopulationProperties): properties of the node population
+        morphology_name (str): morphology name, without extension
+
+    Returns:
+        Path: path to the morphology file, or to the h5 container of the morphologies
+    """
+    for format_name, extension in MORPHOLOGY_FORMATS:
+        morph_dir = node_properties.alternate_morphology_formats.get(format_name)
+        if morph_dir is not None:
+            if Path(morph_dir).is_file():
+                # h5 container of all the morphologies of the circuit
+                return Path(morph_dir)
+            return Path(morph_dir) / f"{morphology_name}.{extension}"
+    return Path(node_properties.morphologies_dir) / f"{morphology_name}.swc"
+
+
+def get_node_data(circuit_config, node_population, node_id):
+    """Return the properties of a node needed to build its config.
+
+    Args:
+        circuit_config (libsonata.CircuitConfig): circuit config
+        node_population (str): name of the node population
+        node_id (int): id of the node in the population
+
+    Returns:
+        dict containing the 'emodel', 'morph_path', 'morph_name', 'mtype'
+        and 'etype' of the node
+    """
+    # pylint: disable=import-error,import-outside-toplevel
+    import libsonata
+
+    population = circuit_config.node_population(node_population)
+    selection = libsonata.Selection([node_id])
+    attributes = {
+        name: population.get_attribute(name, selection)[0]
+        for name in ["model_template", "morphology", "mtype", "etype"]
+    }
+
+    morph_path = get_morphology_path(
+        circuit_config.node_population_properties(node_population),
+        attributes["morphology"],
+    )
+    return {
+        "emodel": get_emodel_name(attributes["model_template"]),
+        "morph_path": morph_path,
+        "morph_name": attributes["morphology"],
+        "mtype": attributes["mtype"],
+        "etype": attributes["etype"],
+    }
+
+
+def append_edge_synapses(
+    synapses, mtype_ids, population, selection, pre_mtypes, section_locations
+):
+    """Append the synapses of a selection of edges to a list of synapses.
+
+    The synapses located on the axon are left out, since the axon is replaced
+    when the cell is instantiated.
+
+    Args:
+        synapses (list of dicts): data of each synapse, with the columns
+            of the synapses tsv file. The new synapses are appended to it.
+        mtype_ids (dict): id of each pre-synaptic mtype name {mtype_name: mtype_id}.
+            The new pre-synaptic mtypes are added to it.
+        population (libsonata.EdgePopulation): edge population
+        selection (libsonata.Selection): selection of the afferent edges of the cell
+        pre_mtypes (dict): pre-synaptic mtype name of each source node id
+        section_locations (list of tuples): (sectionlist_id, sectionlist_index)
+            of each SONATA section id of the morphology
+
+    Raises:
+        ValueError: if the edge population lacks a required synapse attribute
+    """
+    # pylint: disable=too-many-arguments
+    pre_ids = population.source_nodes(selection)
+    columns = {
+        "section_id": population.get_attribute("afferent_section_id", selection),
+        "seg_x": population.get_attribute("afferent_section_pos", selection),
+    }
+    for column, (attribute, default) in SYNAPSE_ATTRIBUTES.items():
+        if attribute in population.attribute_names:
+            columns[column] = population.get_attribute(attribute, selection)
+        elif default is not None:
+            columns[column] = [default] * selection.flat_size
+        else:
+            raise ValueError(
+                f"The edge population {population.name} has no {attribute}."
+            )
+
+    n_axon_synapses = 0
+    for idx, pre_id in enumerate(pre_ids):
+        pre_mtype = pre_mtypes[pre_id]
+        sectionlist_id, sectionlist_index = section_locations[
+            columns["section_id"][idx]
+        ]
+        if sectionlist_id == SECTIONLIST_IDS["axon"]:
+            n_axon_synapses += 1
+            continue
+        mtype_ids.setdefault(pre_mtype, len(mtype_ids))
+
+        synapse = {
+            "sid": len(synapses),
+            # gids are node ids + 1, as in neurodamus
+            "pre_cell_id": int(pre_id) + 1,
+            "sectionlist_id": sectionlist_id,
+            "sectionlist_index": sectionlist_index,
+            "seg_x": float(columns["seg_x"][idx]),
+            "pre_mtype": mtype_ids[pre_mtype],
+            # not written in the tsv file, to find the presynaptic cells of a network
+            "pre_population": population.source,
+        }
+        for column in SYNAPSE_ATTRIBUTES:
+            synapse[column] = columns[column][idx]
+        synapses.append(synapse)
+
+    if n_axon_synapses:
+        logger.warning(
+            "%s synapses of %s on the axon are left out.",
+            n_axon_synapses,
+            population.name,
+        )
+
+
+def get_afferent_synapses(circuit_config, node_population, node_id, section_locations):
+    """Return the afferent chemical synapses of a node.
+
+    Args:
+        circuit_config (libsonata.CircuitConfig): circuit config
+        node_population (str): name of the node population
+        node_id (int): id of the node in the population
+        section_locations (list of tuples): (sectionlist_id, sectionlist_index)
+            of each SONATA section id of the morphology
+
+    Returns:
+        a tuple containing
+
+        - list of dicts: data of each synapse, with the columns of the synapses tsv file
+        - dict: pre-synaptic mtype names {mtype_id: mtype_name}
+
+    Raises:
+        ValueError: if an edge population lacks a required synapse attribute
+    """
+    # pylint: disable=import-error,import-outside-toplevel
+    import libsonata
+
+    synapses = []
+    mtype_ids = {}
+    for edge_population in sorted(circuit_config.edge_populations):
+        properties = circuit_config.edge_population_properties(edge_population)
+        population = circuit_config.edge_population(edge_population)
+        if properties.type != "chemical" or population.target != node_population:
+            continue
+
+        selection = population.afferent_edges([node_id])
+        if selection.flat_size == 0:
+            continue
+
+        unique_pre_ids = np.unique(population.source_nodes(selection))
+        pre_mtypes = dict(
+            zip(
+                unique_pre_ids,
+                circuit_config.node_population(population.source).get_attribute(
+                    "mtype", libsonata.Selection(unique_pre_ids)
+                ),
+            )
+        )
+        append_edge_synapses(
+            synapses, mtype_ids, population, selection, pre_mtypes, section_locations
+        )
+
+    mtypes = {mtype_id: mtype_name for mtype_name, mtype_id in mtype_ids.items()}
+    return synapses, mtypes
+
+
+def load_edge_file_synapses(
+    edges_path,
+    node_id,
+    morph_path,
+    edge_population="",
+    source_nodes_path="",
+    morph_name="",
+):
+    """Load the afferent synapses of a cell from a SONATA edges file.
+
+    Args:
+        edges_path (str or Path): path to the SONATA edges file
+        node_id (int): id of the cell in the target node population
+        morph_path (str or Path): path to the morphology of the cell
+        edge_population (str): name of the edge population.
+            All the populations of the file are used if empty.
+        source_nodes_path (str or Path): path to the SONATA nodes file
+            of the pre-synaptic cells, to read their mtypes.
+            If empty, the synapses are grouped by source node population instead.
+        morph_name (str): name of the morphology if morph_path is a directory
+            or a h5 container of morphologies
+
+    Returns:
+        a tuple containing
+
+        - list of dicts: data of each synapse, with the columns of the synapses tsv file
+        - dict: pre-synaptic mtype names {mtype_id: mtype_name}
+
+    Raises:
+        ValueError: if the edge population is not in the file,
+            or if it lacks a required synapse attribute
+    """
+    # pylint: disable=import-error,import-outside-toplevel
+    import libsonata
+
+    storage = libsonata.EdgeStorage(str(edges_path))
+    if not edge_population:
+        edge_populations = sorted(storage.population_names)
+    elif edge_population in storage.population_names:
+        edge_populations = [edge_population]
+    else:
+        raise ValueError(
+            f"The edge population {edge_population} is not in {edges_path}. "
+            f"Expected one of {sorted(storage.population_names)}."
+        )
+
+    section_locations = get_morphology_section_locations(morph_path, morph_name)
+    synapses = []
+    mtype_ids = {}
+    for population_name in edge_populations:
+        population = storage.open_population(population_name)
+        selection = population.afferent_edges([node_id])
+        if selection.flat_size == 0:
+            continue
+
+        unique_pre_ids = np.unique(population.source_nodes(selection))
+        if source_nodes_path:
+            pre_mtype_names = (
+                libsonata.NodeStorage(str(source_nodes_path))
+                .open_population(population.source)
+                .get_attribute("mtype", libsonata.Selection(unique_pre_ids))
+            )
+        else:
+            pre_mtype_names = [population.source] * len(unique_pre_ids)
+        append_edge_synapses(
+            synapses,
+            mtype_ids,
+            population,
+            selection,
+            dict(zip(unique_pre_ids, pre_mtype_names)),
+            section_locations,
+        )
+
+    if not synapses:
+        logger.warning("No afferent synapses of node %s in %s.", node_id, edges_path)
+    mtypes = {mtype_id: mtype_name for mtype_name, mtype_id in mtype_ids.items()}
+    return synapses, mtypes
+
+
+def get_spike_trains(node_ids, timestamps):
+    """Return the spike train of each pre-synaptic cell.
+
+    Args:
+        node_ids (list of int): node id of each spike
+        timestamps (list of float): time of each spike (ms)
+
+    Returns:
+        dict: sorted spike times of each pre-synaptic cell {gid: spike_times}.
+            The gids are the node ids + 1, as the pre_cell_id of the synapses.
+    """
+    spike_trains = {}
+    for node_id, timestamp in zip(node_ids, timestamps):
+        spike_trains.setdefault(int(node_id) + 1, []).append(float(timestamp))
+    return {gid: sorted(spike_times) for gid, spike_times in spike_trains.items()}
+
+
+def load_spike_trains(spikes_path, spike_population=""):
+    """Load the spike trains of the pre-synaptic cells from a SONATA spikes file.
+
+    Args:
+        spikes_path (str or Path): path to the SONATA spikes file
+        spike_population (str): name of the node population of the spikes.
+            Can be empty if the file has only one population.
+
+    Returns:
+        dict: sorted spike times of each pre-synaptic cell {gid: spike_times}
+
+    Raises:
+        ValueError: if the population is not in the file,
+            or if it is not given and the file has several populations
+    """
+    # pylint: disable=import-error,import-outside-toplevel
+    import libsonata
+
+    reader = libsonata.SpikeReader(str(spikes_path))
+    population_names = sorted(reader.get_population_names())
+    if not spike_population and len(population_names) == 1:
+        spike_population = population_names[0]
+    if spike_population not in population_names:
+        raise ValueError(
+            f"Set the spike population of {spikes_path} "
+            f"to one of {population_names}."
+        )
+
+    spikes = reader[spike_population].get()
+    return get_spike_trains(
+        [node_id for node_id, _ in spikes], [timestamp for _, timestamp in spikes]
+    )
+
+
+def write_synapses_tsv(path, synapses):
+    """Write the synapses in the tsv format read by load.load_synapses_tsv_data.
+
+    Args:
+        path (str or Path): path to the tsv file
+        synapses (list of dicts): data of each synapse, with the columns of the tsv file
+    """
+    with open(path, "w", encoding="utf-8") as tsv_file:
+        # first line is dimensions
+        tsv_file.write(f"{len(synapses)} {len(SYNAPSE_COLUMNS)}\n")
+        for synapse in synapses:
+            tsv_file.write(
+                "\t".join(str(synapse[column]) for column in SYNAPSE_COLUMNS) + "\n"
+            )
+
+
+def write_mtype_map(path, mtypes):
+    """Write the map of the pre-synaptic mtypes.
+
+    Args:
+        path (str or Path): path to the mtype map file
+        mtypes (dict): pre-synaptic mtype names {mtype_id: mtype_name}
+    """
+    with open(path, "w", encoding="utf-8") as mtype_file:
+        for mtype_id, mtype_name in sorted(mtypes.items()):
+            mtype_file.write(f"{mtype_id} {mtype_name}\n")
+
+
+def update_config(config, emodel, gid, morph_path, mtype):
+    """Set the cell-specific values of a template config.
+
+    Args:
+        config (configparser.ConfigParser): config of the template cell package
+        emodel (str): e-model name
+        gid (int): gid of the cell
+        morph_path (str): path to the morphology, relative to the cell package
+        mtype (str): morphological type of the cell
+    """
+    for section in ["Cell", "Paths", "Morphology", "Synapses", "Protocol"]:
+        if not config.has_section(section):
+            config.add_section(section)
+
+    config.set("Cell", "emodel", emodel)
+    config.set("Cell", "gid", str(gid))
+    config.set("Paths", "morph_path", morph_path)
+    config.set("Morphology", "mtype", mtype)
+    config.set("Synapses", "add_synapses", "True")
+    # the apical point of the new morphology is unknown
+    config.set("Protocol", "apical_point_isec", "-1")
+
+
+def create_config_from_circuit(
+    circuit_config_path,
+    node_population,
+    node_id,
+    template_dir,
+    output_dir,
+    template_config="config/config_synapses.ini",
+):
+    """Create a ready-to-run cell package for a node of a SONATA circuit.
+
+    The cell package is a copy of a template sscx cell package (with its protocols,
+    features, parameters and mechanisms), in which the e-model, the morphology
+    and the afferent synapses are replaced by the ones of the node.
+    The parameters file of the template package must contain the e-model of the node.
+
+    Args:
+        circuit_config_path (str): path to the SONATA circuit config
+        node_population (str): name of the node population
+        node_id (int): id of the node in the population
+        template_dir (str): path to the template cell package
+        output_dir (str): path to the cell package to create
+        template_config (str): path to the config of the template package,
+            relative to template_dir
+
+    Returns:
+        Path: path to the config of the created cell package
+    """
+    # pylint: disable=import-error,import-outside-toplevel
+    import libsonata
+
+    circuit_config = libsonata.CircuitConfig.from_file(circuit_config_path)
+    node_data = get_node_data(circuit_config, node_population, node_id)
+    gid = node_id + 1
+
+    output_dir = Path(output_dir)
+    shutil.copytree(
+        template_dir,
+        output_dir,
+        ignore=shutil.ignore_patterns(
+            "morphology", "synapses", "python_recordings", "hoc_recordings", "x86_64"
+        ),
+    )
+
+    # morphology
+    morph_dir = output_dir / "morphology"
+    morph_dir.mkdir()
+    if is_collection(node_data["morph_path"]):
+        # extracted from the container, and converted to asc when the cell is run
+        morph_path = convert_morphology(
+            node_data["morph_path"],
+            morph_dir / f"{node_data['morph_name']}.h5",
+            node_data["morph_name"],
+        )
+    else:
+        shutil.copy(node_data["morph_path"], morph_dir)
+        morph_path = morph_dir / node_data["morph_path"].name
+    morph_path = morph_path.relative_to(output_dir)
+
+    # synapses
+    synapses, mtypes = get_afferent_synapses(
+        circuit_config,
+        node_population,
+        node_id,
+        get_morphology_section_locations(
+            node_data["morph_path"], node_data["morph_name"]
+        ),
+    )
+    syn_dir = output_dir / "synapses"
+    syn_dir.mkdir()
+    write_synapses_tsv(syn_dir / "synapses.tsv", synapses)
+    write_mtype_map(syn_dir / "mtype_map.tsv", mtypes)
+    # no extra synapse configuration
+    (syn_dir / "synconf.txt").touch()
+    logger.info("%s afferent synapses extracted.", len(synapses))
+
+    # keep the circuit data of the cell, e.g. to find back the cell in the circuit
+    with open(output_dir / "circuit_node.json", "w", encoding="utf-8") as node_file:
+        json.dump(
+            {
+                "circuit_config": str(Path(circuit_config_path).resolve()),
+                "node_population": node_population,
+                "node_id": node_id,
+                "emodel": node_data["emodel"],
+                "mtype": node_data["mtype"],
+                "etype": node_data["etype"],
+            },
+            node_file,
+            indent=4,
+        )
+
+    # config
+    config = configparser.ConfigParser(interpolation=None)
+    config.optionxform = str
+    config.read(Path(template_dir) / template_config)
+    update_config(config, node_data["emodel"], gid, str(morph_path), node_data["mtype"])
+    config_path = output_dir / template_config
+    with open(config_path, "w", encoding="utf-8") as config_file:
+        config.write(config_file)
+    logger.warning(
+        "The apical point of the morphology is unknown. "
+        "Set apical_point_isec in %s if the protocols record on the apical dendrite.",
+        config_path,
+    )
+
+    return config_path
+
+
+if __name__ == "__main__":
+    args = get_sonata_parser_args()
+    set_verbosity(args.verbosity, args.log_level, args.log_file)
+
+    create_config_from_circuit(
+        args.circuit_config,
+        args.node_population,
+        args.node_id,
+        args.template_dir,
+        args.output_dir,
+        args.template_config,
+    )
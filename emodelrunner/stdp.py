@@ -0,0 +1,271 @@
+"""STDP curve of the model and comparison with experimental curves."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import argparse
+import json
+import logging
+import re
+from pathlib import Path
+
+import numpy as np
+
+from emodelrunner.json_utilities import NpEncoder
+from emodelrunner.parsing_utilities import add_logging_arguments, set_verbosity
+from emodelrunner.synplas_analysis import Experiment
+
+logger = logging.getLogger(__name__)
+
+# experimental STDP curves shipped with the package, used by default
+BUNDLED_REFERENCES_DIR = Path(__file__).resolve().parent / "stdp_references"
+
+# e.g. output_10Hz_-10ms.h5
+OUTPUT_NAME_PATTERN = re.compile(
+    r"_(?P<frequency>[-+]?\d*\.?\d+)Hz_(?P<delta_t>[-+]?\d*\.?\d+)ms$"
+)
+
+
+def parse_synplas_output_name(output_path):
+    """Get the pairing frequency and delay from the name of a synplas output file.
+
+    Args:
+        output_path (str or Path): path to the output file,
+            named e.g. 'output_10Hz_-10ms.h5'
+
+    Raises:
+        ValueError: if the frequency and delay cannot be found in the file name
+
+    Returns:
+        tuple containing the pairing frequency (Hz) and the delay between
+        the pre-synaptic and the post-synaptic spikes (ms)
+    """
+    match = OUTPUT_NAME_PATTERN.search(Path(output_path).stem)
+    if match is None:
+        raise ValueError(
+            f"Could not find frequency and delay in the name of {output_path}. "
+            "Expected a name like 'output_10Hz_-10ms.h5'."
+        )
+    return float(match.group("frequency")), float(match.group("delta_t"))
+
+
+def compute_stdp_curve(output_paths, n=60, method="amplitude", **experiment_kwargs):
+    """Compute the EPSP change for each pairing delay of a synplas sweep.
+
+    Args:
+        output_paths (list): paths to the synplas outputs of the sweep,
+            named e.g. 'output_10Hz_-10ms.h5'
+        n (int): number of sweeps to be considered for mean EPSP calculation
+        method (str): method used to compute EPSP ratio (amplitude or slope)
+        experiment_kwargs: arguments passed to synplas_analysis.Experiment,
+            e.g. c01duration, c02duration and period
+
+    Returns:
+        dict: for each pairing frequency (Hz), a dict containing the sorted delays
+        'delta_t' (ms) and the corresponding 'epsp_ratio'
+    """
+    points = {}
+    for output_path in output_paths:
+        frequency, delta_t = parse_synplas_output_name(output_path)
+        exp = Experiment(data=str(output_path), **experiment_kwargs)
+        epsp_ratio = exp.compute_epsp_ratio(n=n, method=method)
+        logger.info("EPSP ratio at %s Hz, %s ms: %s", frequency, delta_t, epsp_ratio)
+        points.setdefault(frequency, []).append((delta_t, epsp_ratio))
+
+    curves = {}
+    for frequency, freq_points in points.items():
+        freq_points.sort()
+        curves[frequency] = {
+            "delta_t": np.array([point[0] for point in freq_points]),
+            "epsp_ratio": np.array([point[1] for point in freq_points]),
+        }
+    return curves
+
+
+def load_stdp_reference(reference_path):
+    """Load an experimental STDP curve.
+
+    The reference file is a json file containing the 'name' of the reference,
+    the pairing 'frequency' (Hz), the delays 'delta_t' (ms)
+    and the corresponding 'epsp_ratio'.
+
+    Args:
+        reference_path (str or Path): path to the reference json file
+
+    Raises:
+        ValueError: if delta_t and epsp_ratio do not have the same length
+
+    Returns:
+        dict: the reference curve
+    """
+    with open(reference_path, "r", encoding="utf-8") as reference_file:
+        reference = json.load(reference_file)
+
+    reference.setdefault("name", Path(reference_path).stem)
+    reference["frequency"] = float(reference["frequency"])
+    reference["delta_t"] = np.asarray(reference["delta_t"], dtype=float)
+    reference["epsp_ratio"] = np.asarray(reference["epsp_ratio"], dtype=float)
+    if len(reference["delta_t"]) != len(reference["epsp_ratio"]):
+        raise ValueError(
+            f"delta_t and epsp_ratio of reference {reference['name']} "
+            "should have the same length."
+        )
+
+    return reference
+
+
+def load_bundled_stdp_references():
+    """Load the experimental STDP curves shipped with the package.
+
+    They are the mean EPSP changes of L5 pyramidal cell pairs
+    of Markram et al. (1997) and Sjöström et al. (2001).
+
+    Returns:
+        list: the reference curves, sorted by name
+    """
+    return [
+        load_stdp_reference(reference_path)
+        for reference_path in sorted(BUNDLED_REFERENCES_DIR.glob("*.json"))
+    ]
+
+
+def stdp_rms_deviation(curve, reference):
+    """Compute the RMS deviation between the model and a reference STDP curve.
+
+    The model curve is linearly interpolated at the delays of the reference.
+    Reference delays outside of the range of the model curve are ignored.
+
+    Args:
+        curve (dict): model curve with 'delta_t' (ms) and 'epsp_ratio'
+        reference (dict): reference curve with 'delta_t' (ms) and 'epsp_ratio'
+
+    Returns:
+        float: the RMS deviation, or None if no reference delay is covered by the model curve
+    """
+    delta_t = np.asarray(curve["delta_t"], dtype=float)
+    ref_delta_t = np.asarray(reference["delta_t"], dtype=float)
+    covered = (ref_delta_t >= delta_t.min()) & (ref_delta_t <= delta_t.max())
+    if not np.any(covered):
+        return None
+
+    model_ratio = np.interp(ref_delta_t[covered], delta_t, curve["epsp_ratio"])
+    ref_ratio = np.asarray(reference["epsp_ratio"], dtype=float)[covered]
+    return float(np.sqrt(np.mean((model_ratio - ref_ratio) ** 2)))
+
+
+def compare_stdp_curves(curves, references):
+    """Compare the model STDP curves with the references at the same pairing frequency.
+
+    Args:
+        curves (dict): model curves for each pairing frequency (Hz)
+        references (list): reference curves
+
+    Returns:
+        dict: RMS deviation for each reference name
+    """
+    deviations = {}
+    for reference in references:
+        curve = curves.get(reference["frequency"])
+        if curve is None:
+            logger.warning(
+                "No simulation at %s Hz to compare with reference %s.",
+                reference["frequency"],
+                reference["name"],
+            )
+            deviations[reference["name"]] = None
+            continue
+        deviations[reference["name"]] = stdp_rms_deviation(curve, reference)
+        logger.info(
+            "RMS deviation from %s: %s",
+            reference["name"],
+            deviations[reference["name"]],
+        )
+
+    return deviations
+
+
+def write_stdp_comparison(curves, deviations, output_path):
+    """Write the model STDP curves and the deviations from the references as json.
+
+    Args:
+        curves (dict): model curves for each pairing frequency (Hz)
+        deviations (dict): RMS deviation for each reference name
+        output_path (str or Path): path to the output json file
+    """
+    with open(output_path, "w", encoding="utf-8") as out_file:
+        json.dump(
+            {
+                "curves": {str(freq): curve for freq, curve in curves.items()},
+                "rms_deviation": deviations,
+            },
+            out_file,
+            indent=4,
+            cls=NpEncoder,
+        )
+
+
+def get_parser_args():
+    """Get the arguments of the STDP comparison.
+
+    Returns:
+        argparse.Namespace: object containing the parsed arguments
+    """
+    parser = argparse.ArgumentParser(
+        description="Compare the STDP curve of a synplas sweep with experimental curves."
+    )
+    parser.add_argument(
+        "outputs", nargs="+", help="synplas outputs, named e.g. output_10Hz_-10ms.h5"
+    )
+    parser.add_argument(
+        "--references",
+        nargs="*",
+        default=None,
+        help="paths to the json files of the reference STDP curves. "
+        "The curves shipped with the package are used if not given.",
+    )
+    parser.add_argument("--c01duration", type=float, default=40.0)
+    parser.add_argument("--c02duration", type=float, default=40.0)
+    parser.add_argument("--period", type=float, default=10.0)
+    parser.add_argument(
+        "--n", type=int, default=60, help="number of sweeps used for the mean EPSP"
+    )
+    parser.add_argument("--method", choices=["amplitude", "slope"], default="amplitude")
+    parser.add_argument("--output", default="stdp_comparison.json")
+    add_logging_arguments(parser)
+    return parser.parse_args()
+
+
+def main():
+    """Compute the STDP curve and compare it with the references."""
+    args = get_parser_args()
+    set_verbosity(args.verbosity, args.log_level, args.log_file)
+
+    curves = compute_stdp_curve(
+        args.outputs,
+        n=args.n,
+        method=args.method,
+        c01duration=args.c01duration,
+        c02duration=args.c02duration,
+        period=args.period,
+    )
+    if args.references is None:
+        references = load_bundled_stdp_references()
+    else:
+        references = [load_stdp_reference(path) for path in args.references]
+    deviations = compare_stdp_curves(curves, references)
+    write_stdp_comparison(curves, deviations, args.output)
+
+
+if __name__ == "__main__":
+    main()
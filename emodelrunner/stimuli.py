@@ -14,6 +14,7 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
+import numpy as np
 from bluepyopt.ephys.stimuli import Stimulus
 
 
@@ -234,3 +235,774 @@ class MultipleSteps(Stimulus):
         self.iclamp = None
         self.time_vec = None
         self.current_vec = None
+
+
+class SampledCurrent(Stimulus):
+    """Current sampled at a fixed time step and played into an IClamp.
+
+    Subclasses define the current during the stimulus in stimulus_current.
+
+    Attributes:
+        delay (float): delay after which the stimulus begins (ms)
+        duration (float): duration of the stimulus (ms)
+        total_duration (float): total duration of the protocol (ms)
+        dt (float): time step at which the current is sampled (ms)
+        location (Location): location of stimulus
+        iclamp (neuron IClamp): clamp to inject the stimulus into the cell
+        current_vec (neuron Vector): current to inject to the cell
+        time_vec (neuron Vector): times at which to play the current
+    """
+
+    def __init__(self, location, delay, duration, total_duration, dt=0.025):
+        """Constructor.
+
+        Args:
+            location (Location): location of stimulus
+            delay (float): delay after which the stimulus begins (ms)
+            duration (float): duration of the stimulus (ms)
+            total_duration (float): total duration of the protocol (ms)
+            dt (float): time step at which the current is sampled (ms)
+        """
+        self.delay = delay
+        self.duration = duration
+        self.total_duration = total_duration
+        self.dt = dt
+
+        self.location = location
+
+        self.iclamp = None
+        self.current_vec = None
+        self.time_vec = None
+
+        super().__init__()
+
+    def stimulus_current(self, t):
+        """Return the current during the stimulus.
+
+        Args:
+            t (numpy.ndarray): time since the start of the stimulus (ms),
+                sampled at the time step of the stimulus
+
+        Returns:
+            numpy.ndarray: current (nA)
+        """
+        raise NotImplementedError
+
+    def generate(self, dt=None):
+        """Return the injected current.
+
+        The current is always computed at the time step of the stimulus,
+        and interpolated at the requested time step if it differs,
+        so that e.g. the noise realizations do not depend on dt.
+
+        Args:
+            dt (float): time step of the current (ms).
+                The time step of the stimulus is used if None.
+
+        Returns:
+            tuple of numpy.ndarray: time (ms) and current (nA)
+        """
+        t = np.arange(0.0, self.total_duration, self.dt)
+        current = np.zeros(t.shape, dtype="float64")
+
+        stim_idx = (t >= self.delay) & (t < self.delay + self.duration)
+        current[stim_idx] = self.stimulus_current(t[stim_idx] - self.delay)
+
+        if dt is None or dt == self.dt:
+            return t, current
+        new_t = np.arange(0.0, self.total_duration, dt)
+        return new_t, np.interp(new_t, t, current)
+
+    def instantiate(self, sim=None, icell=None):
+        """Instantiate stimulus.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            icell (neuron cell): cell instantiation in simulator
+        """
+        icomp = self.location.instantiate(sim=sim, icell=icell)
+
+        self.iclamp = sim.neuron.h.IClamp(icomp.x, sec=icomp.sec)
+        self.iclamp.dur = self.total_duration
+
+        t, current = self.generate()
+        self.time_vec = sim.neuron.h.Vector(t)
+        self.current_vec = sim.neuron.h.Vector(current)
+
+        self.iclamp.delay = 0
+        self.current_vec.play(
+            self.iclamp._ref_amp,  # pylint:disable=W0212
+            self.time_vec,
+            1,
+            sec=icomp.sec,
+        )
+
+    def destroy(self, sim=None):  # pylint:disable=W0613
+        """Destroy stimulus.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        """
+        self.iclamp = None
+        self.time_vec = None
+        self.current_vec = None
+
+
+class Sinusoid(SampledCurrent):
+    """Sinusoidal current injection, with an optional constant offset.
+
+    Attributes:
+        delay (float): delay after which the sinusoid begins (ms)
+        duration (float): duration of the sinusoid (ms)
+        amp (float): amplitude of the sinusoid (nA)
+        offset (float): constant current added during the sinusoid (nA)
+        frequency (float): frequency of the sinusoid (Hz)
+        total_duration (float): total duration of the protocol (ms)
+        dt (float): time step at which the current is sampled (ms)
+        location (Location): location of stimulus
+        iclamp (neuron IClamp): clamp to inject the stimulus into the cell
+        current_vec (neuron Vector): current to inject to the cell
+        time_vec (neuron Vector): times at which to play the current
+    """
+
+    def __init__(
+        self,
+        location,
+        delay,
+        duration,
+        amp,
+        frequency,
+        total_duration,
+        offset=0.0,
+        dt=0.025,
+    ):
+        """Constructor.
+
+        Args:
+            location (Location): location of stimulus
+            delay (float): delay after which the sinusoid begins (ms)
+            duration (float): duration of the sinusoid (ms)
+            amp (float): amplitude of the sinusoid (nA)
+            frequency (float): frequency of the sinusoid (Hz)
+            total_duration (float): total duration of the protocol (ms)
+            offset (float): constant current added during the sinusoid (nA)
+            dt (float): time step at which the current is sampled (ms)
+        """
+        # pylint: disable=too-many-arguments
+        self.amp = amp
+        self.offset = offset
+        self.frequency = frequency
+
+        super().__init__(location, delay, duration, total_duration, dt=dt)
+
+    def phase(self, t):
+        """Return the phase of the sinusoid.
+
+        Args:
+            t (numpy.ndarray): time since the start of the sinusoid (ms)
+
+        Returns:
+            numpy.ndarray: phase (rad)
+        """
+        return 2.0 * np.pi * self.frequency * t / 1000.0
+
+    def stimulus_current(self, t):
+        """Return the current during the sinusoid.
+
+        Args:
+            t (numpy.ndarray): time since the start of the sinusoid (ms)
+
+        Returns:
+            numpy.ndarray: current (nA)
+        """
+        return self.offset + self.amp * np.sin(self.phase(t))
+
+
+class Chirp(Sinusoid):
+    """Chirp (ZAP) current injection, a sinusoid with a frequency sweep.
+
+    The frequency increases (or decreases) linearly from freq_start
+    at the start of the stimulus to freq_end at its end.
+
+    Attributes:
+        delay (float): delay after which the chirp begins (ms)
+        duration (float): duration of the chirp (ms)
+        amp (float): amplitude of the chirp (nA)
+        offset (float): constant current added during the chirp (nA)
+        freq_start (float): frequency at the start of the chirp (Hz)
+        freq_end (float): frequency at the end of the chirp (Hz)
+        total_duration (float): total duration of the protocol (ms)
+        dt (float): time step at which the current is sampled (ms)
+        location (Location): location of stimulus
+        iclamp (neuron IClamp): clamp to inject the stimulus into the cell
+        current_vec (neuron Vector): current to inject to the cell
+        time_vec (neuron Vector): times at which to play the current
+    """
+
+    def __init__(
+        self,
+        location,
+        delay,
+        duration,
+        amp,
+        freq_start,
+        freq_end,
+        total_duration,
+        offset=0.0,
+        dt=0.025,
+    ):
+        """Constructor.
+
+        Args:
+            location (Location): location of stimulus
+            delay (float): delay after which the chirp begins (ms)
+            duration (float): duration of the chirp (ms)
+            amp (float): amplitude of the chirp (nA)
+            freq_start (float): frequency at the start of the chirp (Hz)
+            freq_end (float): frequency at the end of the chirp (Hz)
+            total_duration (float): total duration of the protocol (ms)
+            offset (float): constant current added during the chirp (nA)
+            dt (float): time step at which the current is sampled (ms)
+        """
+        # pylint: disable=too-many-arguments
+        super().__init__(
+            location,
+            delay,
+            duration,
+            amp,
+            freq_start,
+            total_duration,
+            offset=offset,
+            dt=dt,
+        )
+        self.freq_start = freq_start
+        self.freq_end = freq_end
+
+    def phase(self, t):
+        """Return the phase of the chirp.
+
+        Args:
+            t (numpy.ndarray): time since the start of the chirp (ms)
+
+        Returns:
+            numpy.ndarray: phase (rad)
+        """
+        t = t / 1000.0
+        sweep_rate = (self.freq_end - self.freq_start) / (self.duration / 1000.0)
+        return 2.0 * np.pi * (self.freq_start * t + 0.5 * sweep_rate * t**2)
+
+
+class DoubleExponential(SampledCurrent):
+    """EPSP-like current injection, rising and decaying exponentially.
+
+    Attributes:
+        delay (float): delay after which the current begins (ms)
+        duration (float): duration of the current, after which it is cut (ms)
+        amp (float): peak amplitude of the current (nA)
+        tau_rise (float): rise time constant (ms)
+        tau_decay (float): decay time constant (ms)
+        total_duration (float): total duration of the protocol (ms)
+        dt (float): time step at which the current is sampled (ms)
+        location (Location): location of stimulus
+        iclamp (neuron IClamp): clamp to inject the stimulus into the cell
+        current_vec (neuron Vector): current to inject to the cell
+        time_vec (neuron Vector): times at which to play the current
+    """
+
+    def __init__(
+        self,
+        location,
+        delay,
+        duration,
+        amp,
+        tau_rise,
+        tau_decay,
+        total_duration,
+        dt=0.025,
+    ):
+        """Constructor.
+
+        Args:
+            location (Location): location of stimulus
+            delay (float): delay after which the current begins (ms)
+            duration (float): duration of the current, after which it is cut (ms)
+            amp (float): peak amplitude of the current (nA)
+            tau_rise (float): rise time constant (ms)
+            tau_decay (float): decay time constant (ms)
+            total_duration (float): total duration of the protocol (ms)
+            dt (float): time step at which the current is sampled (ms)
+
+        Raises:
+            ValueError: if the decay is not slower than the rise
+        """
+        # pylint: disable=too-many-arguments
+        if tau_decay <= tau_rise:
+            raise ValueError(
+                f"tau_decay ({tau_decay} ms) should be larger than "
+                f"tau_rise ({tau_rise} ms)"
+            )
+        self.amp = amp
+        self.tau_rise = tau_rise
+        self.tau_decay = tau_decay
+
+        super().__init__(location, delay, duration, total_duration, dt=dt)
+
+    @property
+    def time_to_peak(self):
+        """Time from the start of the current to its peak.
+
+        Returns:
+            float: time to peak (ms)
+        """
+        return (
+            self.tau_rise
+            * self.tau_decay
+            / (self.tau_decay - self.tau_rise)
+            * np.log(self.tau_decay / self.tau_rise)
+        )
+
+    def stimulus_current(self, t):
+        """Return the current, normalized so that its peak is amp.
+
+        Args:
+            t (numpy.ndarray): time since the start of the current (ms)
+
+        Returns:
+            numpy.ndarray: current (nA)
+        """
+
+        def shape(t):
+            return np.exp(-t / self.tau_decay) - np.exp(-t / self.tau_rise)
+
+        return self.amp * shape(t) / shape(self.time_to_peak)
+
+
+class OrnsteinUhlenbeck(SampledCurrent):
+    """Noise current following an Ornstein-Uhlenbeck process.
+
+    The current starts at its mean and relaxes towards it with the time constant tau,
+    with a stationary standard deviation sigma. It is computed with the exact update
+    of the process, so that its statistics do not depend on the time step.
+
+    Attributes:
+        delay (float): delay after which the noise begins (ms)
+        duration (float): duration of the noise (ms)
+        mean (float): mean of the current (nA)
+        sigma (float): standard deviation of the current (nA)
+        tau (float): time constant of the process (ms)
+        seed (int): seed of the random number generator
+        total_duration (float): total duration of the protocol (ms)
+        dt (float): time step at which the current is sampled (ms)
+        location (Location): location of stimulus
+        iclamp (neuron IClamp): clamp to inject the stimulus into the cell
+        current_vec (neuron Vector): current to inject to the cell
+        time_vec (neuron Vector): times at which to play the current
+    """
+
+    def __init__(
+        self,
+        location,
+        delay,
+        duration,
+        mean,
+        sigma,
+        tau,
+        total_duration,
+        seed=None,
+        dt=0.025,
+    ):
+        """Constructor.
+
+        Args:
+            location (Location): location of stimulus
+            delay (float): delay after which the noise begins (ms)
+            duration (float): duration of the noise (ms)
+            mean (float): mean of the current (nA)
+            sigma (float): standard deviation of the current (nA)
+            tau (float): time constant of the process (ms)
+            total_duration (float): total duration of the protocol (ms)
+            seed (int): seed of the random number generator.
+                A random seed is drawn if None, and kept in the seed attribute
+                so that the noise realization can be reproduced.
+            dt (float): time step at which the current is sampled (ms)
+
+        Raises:
+            ValueError: if tau is not positive or sigma is negative
+        """
+        # pylint: disable=too-many-arguments
+        if tau <= 0:
+            raise ValueError(f"The time constant tau has to be positive, got {tau}")
+        if sigma < 0:
+            raise ValueError(f"The standard deviation sigma is negative: {sigma}")
+
+        self.mean = mean
+        self.sigma = sigma
+        self.tau = tau
+        if seed is None:
+            seed = int(np.random.SeedSequence().generate_state(1)[0])
+        self.seed = int(seed)
+
+        super().__init__(location, delay, duration, total_duration, dt=dt)
+
+    def stimulus_current(self, t):
+        """Return a realization of the noise current.
+
+        The same realization is returned at each call.
+
+        Args:
+            t (numpy.ndarray): time since the start of the noise (ms)
+
+        Returns:
+            numpy.ndarray: current (nA)
+        """
+        rng = np.random.default_rng(self.seed)
+        decay = np.exp(-self.dt / self.tau)
+        noise_amp = self.sigma * np.sqrt(1.0 - decay**2)
+
+        current = np.empty(len(t), dtype="float64")
+        if len(t) == 0:
+            return current
+        current[0] = self.mean
+        kicks = rng.standard_normal(len(t) - 1) * noise_amp
+        for i, kick in enumerate(kicks):
+            current[i + 1] = self.mean + (current[i] - self.mean) * decay + kick
+        return current
+
+
+class SampledTrace(SampledCurrent):
+    """Waveform given as values sampled at a fixed time step, e.g. a conductance.
+
+    Attributes:
+        delay (float): delay after which the waveform begins (ms)
+        duration (float): duration of the waveform (ms)
+        values (numpy.ndarray): values of the waveform, sampled at dt
+            from the start of the waveform. The last value is held until its end.
+        total_duration (float): total duration of the protocol (ms)
+        dt (float): time step at which the values are sampled (ms)
+        location (Location): location of stimulus
+        iclamp (neuron IClamp): clamp to inject the stimulus into the cell
+        current_vec (neuron Vector): current to inject to the cell
+        time_vec (neuron Vector): times at which to play the current
+    """
+
+    def __init__(self, location, delay, duration, values, total_duration, dt=0.025):
+        """Constructor.
+
+        Args:
+            location (Location): location of stimulus
+            delay (float): delay after which the waveform begins (ms)
+            duration (float): duration of the waveform (ms)
+            values (list or numpy.ndarray): values of the waveform,
+                sampled at dt from the start of the waveform
+            total_duration (float): total duration of the protocol (ms)
+            dt (float): time step at which the values are sampled (ms)
+
+        Raises:
+            ValueError: if no value is given
+        """
+        # pylint: disable=too-many-arguments
+        self.values = np.asarray(values, dtype="float64")
+        if self.values.ndim != 1 or len(self.values) == 0:
+            raise ValueError("The values of the waveform should be a non-empty list.")
+
+        super().__init__(location, delay, duration, total_duration, dt=dt)
+
+    def stimulus_current(self, t):
+        """Return the values of the waveform.
+
+        Args:
+            t (numpy.ndarray): time since the start of the waveform (ms)
+
+        Returns:
+            numpy.ndarray: values of the waveform
+        """
+        return np.interp(t, np.arange(len(self.values)) * self.dt, self.values)
+
+
+class ConductanceClamp(Stimulus):
+    """Conductance injected at a location, with its reversal potential (dynamic clamp).
+
+    The injected current is g(t) * (reversal_potential - v).
+    It is played with a single electrode clamp at the reversal potential,
+    whose series resistance is the inverse of the conductance.
+
+    Attributes:
+        location (Location): location of stimulus
+        conductance (SampledCurrent): waveform of the conductance (uS),
+            e.g. a SampledTrace or an OrnsteinUhlenbeck. The negative values are
+            set to 0.
+        reversal_potential (float): reversal potential of the conductance (mV)
+        seclamp (neuron SEClamp): clamp to inject the conductance into the cell
+        resistance_vec (neuron Vector): series resistance of the clamp (MOhm)
+        time_vec (neuron Vector): times at which to play the resistance
+    """
+
+    # series resistance (MOhm) when the conductance is 0
+    max_resistance = 1e9
+
+    def __init__(self, location, conductance, reversal_potential):
+        """Constructor.
+
+        Args:
+            location (Location): location of stimulus
+            conductance (SampledCurrent): waveform of the conductance (uS)
+            reversal_potential (float): reversal potential of the conductance (mV)
+        """
+        self.location = location
+        self.conductance = conductance
+        self.reversal_potential = reversal_potential
+
+        self.seclamp = None
+        self.resistance_vec = None
+        self.time_vec = None
+
+        super().__init__()
+
+    @property
+    def delay(self):
+        """Time at which the conductance begins (ms)."""
+        return self.conductance.delay
+
+    @property
+    def duration(self):
+        """Duration of the conductance (ms)."""
+        return self.conductance.duration
+
+    @property
+    def total_duration(self):
+        """Total duration of the protocol (ms)."""
+        return self.conductance.total_duration
+
+    def generate(self, dt=None):
+        """Return the conductance.
+
+        Args:
+            dt (float): time step of the conductance (ms).
+                The time step of the waveform is used if None.
+
+        Returns:
+            tuple of numpy.ndarray: time (ms) and conductance (uS)
+        """
+        t, conductance = self.conductance.generate(dt)
+        return t, np.clip(conductance, 0.0, None)
+
+    def instantiate(self, sim=None, icell=None):
+        """Instantiate stimulus.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            icell (neuron cell): cell instantiation in simulator
+        """
+        icomp = self.location.instantiate(sim=sim, icell=icell)
+
+        self.seclamp = sim.neuron.h.SEClamp(icomp.x, sec=icomp.sec)
+        self.seclamp.dur1 = self.total_duration
+        self.seclamp.amp1 = self.reversal_potential
+
+        t, conductance = self.generate()
+        resistance = np.full(conductance.shape, self.max_resistance)
+        resistance[conductance > 0] = np.minimum(
+            1.0 / conductance[conductance > 0], self.max_resistance
+        )
+        self.time_vec = sim.neuron.h.Vector(t)
+        self.resistance_vec = sim.neuron.h.Vector(resistance)
+
+        self.resistance_vec.play(
+            self.seclamp._ref_rs,  # pylint:disable=W0212
+            self.time_vec,
+            1,
+            sec=icomp.sec,
+        )
+
+    def destroy(self, sim=None):  # pylint:disable=W0613
+        """Destroy stimulus.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        """
+        self.seclamp = None
+        self.time_vec = None
+        self.resistance_vec = None
+
+
+class Waveform(SampledCurrent):
+    """Arbitrary current waveform, e.g. a recorded stimulus.
+
+    The waveform is resampled at the time step of the simulator when instantiated.
+
+    Attributes:
+        delay (float): delay after which the waveform begins (ms)
+        duration (float): duration of the waveform (ms)
+        time (numpy.ndarray): times of the samples of the waveform,
+            from the start of the waveform (ms)
+        current (numpy.ndarray): current of the samples of the waveform (nA)
+        total_duration (float): total duration of the protocol (ms)
+        dt (float): time step at which the current is played (ms)
+        location (Location): location of stimulus
+        iclamp (neuron IClamp): clamp to inject the stimulus into the cell
+        current_vec (neuron Vector): current to inject to the cell
+        time_vec (neuron Vector): times at which to play the current
+    """
+
+    def __init__(self, location, delay, time, current, total_duration, dt=0.025):
+        """Constructor.
+
+        Args:
+            location (Location): location of stimulus
+            delay (float): delay after which the waveform begins (ms)
+            time (list or numpy.ndarray): increasing times of the samples (ms).
+                They are shifted so that the first sample is at the start
+                of the waveform.
+            current (list or numpy.ndarray): current of the samples (nA)
+            total_duration (float): total duration of the protocol (ms)
+            dt (float): time step at which the current is played (ms)
+
+        Raises:
+            ValueError: if the times and currents do not match,
+                or if the times are not increasing
+        """
+        # pylint: disable=too-many-arguments
+        time = np.asarray(time, dtype="float64")
+        self.current = np.asarray(current, dtype="float64")
+        if time.ndim != 1 or time.shape != self.current.shape or len(time) < 2:
+            raise ValueError(
+                "The waveform should have the same number of times and currents, "
+                "with at least 2 samples."
+            )
+        if np.any(np.diff(time) <= 0):
+            raise ValueError("The times of the waveform should be increasing.")
+        self.time = time - time[0]
+
+        super().__init__(location, delay, self.time[-1], total_duration, dt=dt)
+
+    def stimulus_current(self, t):
+        """Return the current of the waveform, interpolated between its samples.
+
+        Args:
+            t (numpy.ndarray): time since the start of the waveform (ms)
+
+        Returns:
+            numpy.ndarray: current (nA)
+        """
+        return np.interp(t, self.time, self.current)
+
+    def instantiate(self, sim=None, icell=None):
+        """Instantiate stimulus, resampled at the time step of the simulator.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            icell (neuron cell): cell instantiation in simulator
+        """
+        if getattr(sim, "dt", None) is not None:
+            self.dt = sim.dt
+        super().instantiate(sim=sim, icell=icell)
+
+
+class VoltageClamp(Stimulus):
+    """Voltage clamp with a command voltage made of successive steps.
+
+    Attributes:
+        location (Location): location of stimulus
+        voltages (list): command voltage of each step (mV)
+        durations (list): duration of each step (ms)
+        series_resistance (float): series resistance of the clamp (MOhm)
+        total_duration (float): total duration of the clamp (ms)
+        seclamp (neuron SEClamp): clamp imposing the command voltage
+        voltage_vec (neuron Vector): command voltage of the clamp
+        time_vec (neuron Vector): times at which to play the command voltage
+    """
+
+    def __init__(self, location, voltages, durations, series_resistance=0.01):
+        """Constructor.
+
+        Args:
+            location (Location): location of stimulus
+            voltages (list): command voltage of each step (mV)
+            durations (list): duration of each step (ms)
+            series_resistance (float): series resistance of the clamp (MOhm)
+
+        Raises:
+            ValueError: if the voltages and durations do not match,
+                or if a duration or the series resistance is not positive
+        """
+        if len(voltages) == 0 or len(voltages) != len(durations):
+            raise ValueError(
+                "The voltage clamp should have the same number of voltages "
+                "and durations, with at least 1 step."
+            )
+        if min(durations) <= 0 or series_resistance <= 0:
+            raise ValueError(
+                "The durations and the series resistance of the voltage clamp "
+                "should be positive."
+            )
+        self.location = location
+        self.voltages = list(voltages)
+        self.durations = list(durations)
+        self.series_resistance = series_resistance
+        self.total_duration = float(sum(self.durations))
+
+        self.seclamp = None
+        self.voltage_vec = None
+        self.time_vec = None
+
+        super().__init__()
+
+    @property
+    def step_starts(self):
+        """Time at which each step starts (ms)."""
+        return list(np.cumsum([0.0] + self.durations[:-1]))
+
+    def generate(self, dt=0.1):
+        """Return the command voltage.
+
+        Args:
+            dt (float): time step of the command voltage (ms)
+
+        Returns:
+            tuple of numpy.ndarray: time (ms) and command voltage (mV)
+        """
+        step_ends = np.cumsum(self.durations)
+        t = np.arange(0.0, step_ends[-1], dt)
+        step_idx = np.searchsorted(step_ends, t, side="right")
+        return t, np.asarray(self.voltages, dtype="float64")[step_idx]
+
+    def instantiate(self, sim=None, icell=None):
+        """Instantiate stimulus.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            icell (neuron cell): cell instantiation in simulator
+        """
+        icomp = self.location.instantiate(sim=sim, icell=icell)
+
+        self.seclamp = sim.neuron.h.SEClamp(icomp.x, sec=icomp.sec)
+        self.seclamp.rs = self.series_resistance
+        self.seclamp.dur1 = self.total_duration
+
+        self.voltage_vec = sim.neuron.h.Vector()
+        self.time_vec = sim.neuron.h.Vector()
+        for start, duration, voltage in zip(
+            self.step_starts, self.durations, self.voltages
+        ):
+            self.time_vec.append(start)
+            self.voltage_vec.append(voltage)
+
+            self.time_vec.append(start + duration)
+            self.voltage_vec.append(voltage)
+
+        self.voltage_vec.play(
+            self.seclamp._ref_amp1,  # pylint:disable=W0212
+            self.time_vec,
+            1,
+            sec=icomp.sec,
+        )
+
+    def destroy(self, sim=None):  # pylint:disable=W0613
+        """Destroy stimulus.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        """
+        self.seclamp = None
+        self.time_vec = None
+        self.voltage_vec = None
@@ -0,0 +1,139 @@
+"""Stochastic ion channels seeded from the config, and their repeated trials."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import logging
+
+from bluepyopt import ephys
+
+from emodelrunner.results import parse_output_filename
+
+logger = logging.getLogger(__name__)
+
+
+class NrnMODMechanismCustom(ephys.mechanisms.NrnMODMechanism):
+    """Mechanism whose stochastic channels, e.g. StochKv, are seeded from the config.
+
+    The random number generator of each segment of a stochastic channel is seeded
+    with the gid of the cell plus the channel seed, and a hash of the segment name,
+    so that each segment has its own reproducible stream of random numbers.
+
+    Attributes:
+        seed (int): channel seed, added to the gid of the cell.
+            With 0, the seeds are the ones of bluepyopt.
+    """
+
+    def __init__(self, name, suffix, locations, deterministic=True, seed=0):
+        """Constructor.
+
+        Args:
+            name (str): name of this object
+            suffix (str): suffix of the mechanism, e.g. 'StochKv3'
+            locations (list of ephys.locations.Location): locations of the mechanism
+            deterministic (bool): whether the stochastic channels are deterministic
+            seed (int): channel seed, added to the gid of the cell
+        """
+        super().__init__(
+            name=name,
+            mod_path=None,
+            suffix=suffix,
+            locations=locations,
+            preloaded=True,
+            deterministic=deterministic,
+        )
+        self.seed = seed
+
+    @property
+    def is_stochastic(self):
+        """Whether the mechanism is a stochastic channel, e.g. StochKv."""
+        return "Stoch" in self.suffix
+
+    def instantiate_determinism(self, deterministic, icell, isec, sim):
+        """Make the stochastic channel of a section deterministic or seed it.
+
+        Args:
+            deterministic (bool): whether the stochastic channel is deterministic
+            icell (neuron cell): cell instantiation in simulator
+            isec (neuron section): section in which the mechanism is inserted
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+
+        Raises:
+            TypeError: if a mechanism that is not stochastic is not deterministic
+        """
+        if not self.is_stochastic:
+            if not deterministic:
+                raise TypeError(
+                    "Deterministic can only be set to False for the stochastic "
+                    f"channels, not for {self.suffix}"
+                )
+            return
+
+        setattr(isec, f"deterministic_{self.suffix}", 1 if deterministic else 0)
+        if deterministic:
+            return
+
+        short_secname = sim.neuron.h.secname(sec=isec).split(".")[-1]
+        for iseg in isec:
+            seg_name = f"{short_secname}.{iseg.x:.19g}"
+            getattr(sim.neuron.h, f"setdata_{self.suffix}")(iseg.x, sec=isec)
+            getattr(sim.neuron.h, f"setRNG_{self.suffix}")(
+                icell.gid + self.seed, self.hash_py(seg_name)
+            )
+
+
+def set_channel_seed(cell, seed):
+    """Set the channel seed of the stochastic channels of a cell.
+
+    Args:
+        cell (CellModelCustom): cell model
+        seed (int): channel seed, added to the gid of the cell
+    """
+    for mechanism in cell.mechanisms:
+        if isinstance(mechanism, NrnMODMechanismCustom):
+            mechanism.seed = seed
+
+
+def get_trial_responses(responses, trial):
+    """Return the traces of a trial, named after the trial.
+
+    The trial is appended to the protocol, e.g. 'L5TPC.Step_150.soma.v'
+    becomes 'L5TPC.Step_150.trial1.soma.v' for the trial 1.
+    The scalar responses, e.g. the holding current, are not returned.
+
+    Args:
+        responses (dict): responses of the protocols run in the trial
+        trial (int): index of the trial
+
+    Returns:
+        dict: the traces of the trial, keyed by their name with the trial
+    """
+    trial_responses = {}
+    for key, response in responses.items():
+        metadata = parse_output_filename(key)
+        if metadata["kind"] != "trace" or metadata["location"] is None:
+            continue
+        trial_key = ".".join(
+            [
+                metadata["prefix"],
+                metadata["protocol"],
+                f"trial{trial}",
+                metadata["location"],
+                metadata["variable"],
+            ]
+        )
+        trial_responses[trial_key] = response
+
+    return trial_responses
@@ -0,0 +1,240 @@
+"""Input resistance, membrane time constant and sag of the subthreshold protocols."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import logging
+
+import numpy as np
+
+from emodelrunner.results import detect_spikes, parse_output_filename
+
+logger = logging.getLogger(__name__)
+
+SUBTHRESHOLD_FEATURES = ["input_resistance", "time_constant", "sag_ratio"]
+
+
+def get_voltage_base(time, voltage, stim_start):
+    """Return the mean voltage during the last 10% of the time before the stimulus.
+
+    Args:
+        time (numpy.ndarray): time of the trace (ms)
+        voltage (numpy.ndarray): voltage of the trace (mV)
+        stim_start (float): time at which the stimulus starts (ms)
+
+    Returns:
+        float: the voltage base (mV)
+    """
+    before = (time >= 0.9 * stim_start) & (time < stim_start)
+    if not np.any(before):
+        return float(voltage[0])
+    return float(np.mean(voltage[before]))
+
+
+def get_steady_state_voltage(time, voltage, stim_start, stim_end):
+    """Return the mean voltage during the last 10% of the stimulus.
+
+    Args:
+        time (numpy.ndarray): time of the trace (ms)
+        voltage (numpy.ndarray): voltage of the trace (mV)
+        stim_start (float): time at which the stimulus starts (ms)
+        stim_end (float): time at which the stimulus ends (ms)
+
+    Returns:
+        float: the steady state voltage (mV)
+    """
+    end = (time >= stim_end - 0.1 * (stim_end - stim_start)) & (time < stim_end)
+    return float(np.mean(voltage[end]))
+
+
+def get_time_constant(time, voltage, voltage_base, peak_voltage):
+    """Fit an exponential to the charging of the membrane at the start of the step.
+
+    The log of the remaining deflection towards the peak voltage is fitted
+    with a line between 10% and 90% of the deflection.
+
+    Args:
+        time (numpy.ndarray): time of the trace from the start of the stimulus
+            to the peak voltage (ms)
+        voltage (numpy.ndarray): voltage of the trace over the same period (mV)
+        voltage_base (float): voltage before the stimulus (mV)
+        peak_voltage (float): voltage at the peak of the deflection (mV)
+
+    Returns:
+        float: the membrane time constant (ms), or None if it cannot be fitted
+    """
+    remaining = (voltage - peak_voltage) / (voltage_base - peak_voltage)
+    fitted = (remaining >= 0.1) & (remaining <= 0.9)
+    if np.sum(fitted) < 3:
+        return None
+
+    slope, _ = np.polyfit(time[fitted], np.log(remaining[fitted]), 1)
+    if slope >= 0:
+        return None
+    return float(-1.0 / slope)
+
+
+def get_subthreshold_features(
+    time, voltage, step_amplitude, stim_start, stim_end, spike_threshold=-20.0
+):
+    """Compute the input resistance, the membrane time constant and the sag ratio.
+
+    The sag ratio is (steady state voltage - peak voltage) / (voltage base
+    - peak voltage), where the peak voltage is the extremum of the deflection
+    during the step, i.e. 0 without sag and 1 if the voltage goes back to its base.
+
+    Args:
+        time (numpy.ndarray): time of the trace (ms)
+        voltage (numpy.ndarray): voltage of the trace (mV)
+        step_amplitude (float): amplitude of the step (nA)
+        stim_start (float): time at which the step starts (ms)
+        stim_end (float): time at which the step ends (ms)
+        spike_threshold (float): voltage threshold for spike detection (mV)
+
+    Returns:
+        dict containing the 'voltage_base' and 'steady_state_voltage' (mV),
+        the 'input_resistance' (MOhm), the 'time_constant' (ms) and the 'sag_ratio'.
+        The features are None if the step amplitude is 0 or if the cell spikes.
+    """
+    time = np.asarray(time, dtype=float)
+    voltage = np.asarray(voltage, dtype=float)
+    features = {
+        "voltage_base": get_voltage_base(time, voltage, stim_start),
+        "steady_state_voltage": get_steady_state_voltage(
+            time, voltage, stim_start, stim_end
+        ),
+    }
+    features.update({name: None for name in SUBTHRESHOLD_FEATURES})
+    if step_amplitude == 0 or len(detect_spikes(time, voltage, spike_threshold)) > 0:
+        return features
+
+    during = (time >= stim_start) & (time < stim_end)
+    if step_amplitude < 0:
+        peak_idx = np.argmin(voltage[during])
+    else:
+        peak_idx = np.argmax(voltage[during])
+    peak_voltage = voltage[during][peak_idx]
+    deflection = features["voltage_base"] - peak_voltage
+    if deflection == 0:
+        return features
+
+    features["input_resistance"] = (
+        features["steady_state_voltage"] - features["voltage_base"]
+    ) / step_amplitude
+    features["time_constant"] = get_time_constant(
+        time[during][: peak_idx + 1],
+        voltage[during][: peak_idx + 1],
+        features["voltage_base"],
+        peak_voltage,
+    )
+    features["sag_ratio"] = (
+        features["steady_state_voltage"] - peak_voltage
+    ) / deflection
+    return features
+
+
+def get_subthreshold_properties(
+    responses, step_amplitudes, stim_windows, spike_threshold=-20.0
+):
+    """Compute the subthreshold features of the steps of a protocol.
+
+    Args:
+        responses (dict): responses of the protocols
+        step_amplitudes (dict): step amplitude (nA) for each step protocol name
+        stim_windows (dict): (start, end) of the stimulus (ms) for each protocol name
+        spike_threshold (float): voltage threshold for spike detection (mV)
+
+    Returns:
+        dict containing the 'protocol' names, the 'step_amplitude' (nA)
+        and the features of each step, sorted by step amplitude,
+        and the 'mean' of each feature over the steps where it is computed
+    """
+    points = []
+    for key, resp in responses.items():
+        if not isinstance(resp, dict) or "voltage" not in resp:
+            continue
+        metadata = parse_output_filename(key)
+        if metadata["location"] != "soma" or metadata["variable"] != "v":
+            continue
+        protocol_name = metadata["protocol"]
+        if step_amplitudes.get(protocol_name) is None:
+            continue
+        if protocol_name not in stim_windows:
+            continue
+
+        features = get_subthreshold_features(
+            resp["time"],
+            resp["voltage"],
+            step_amplitudes[protocol_name],
+            *stim_windows[protocol_name],
+            spike_threshold=spike_threshold,
+        )
+        points.append((step_amplitudes[protocol_name], protocol_name, features))
+
+    points.sort(key=lambda point: point[0])
+    properties = {
+        "protocol": [point[1] for point in points],
+        "step_amplitude": [point[0] for point in points],
+    }
+    for name in ["voltage_base", "steady_state_voltage"] + SUBTHRESHOLD_FEATURES:
+        properties[name] = [point[2][name] for point in points]
+
+    properties["mean"] = {}
+    for name in SUBTHRESHOLD_FEATURES:
+        values = [value for value in properties[name] if value is not None]
+        properties["mean"][name] = float(np.mean(values)) if values else None
+
+    return properties
+
+
+def compute_subthreshold_properties(responses, subthreshold_protocols, stim_windows):
+    """Compute the subthreshold features of each subthreshold protocol.
+
+    Args:
+        responses (dict): responses of the protocols
+        subthreshold_protocols (dict): step amplitudes and spike threshold
+            of each subthreshold protocol,
+            see ProtocolBuilder.get_subthreshold_protocols
+        stim_windows (dict): (start, end) of the stimulus (ms) for each protocol name
+
+    Returns:
+        dict: the subthreshold features of each subthreshold protocol name,
+            see get_subthreshold_properties
+    """
+    subthreshold = {}
+    for name, subthreshold_protocol in subthreshold_protocols.items():
+        subthreshold[name] = get_subthreshold_properties(
+            responses,
+            subthreshold_protocol["step_amplitudes"],
+            stim_windows,
+            subthreshold_protocol["spike_threshold"],
+        )
+        mean = subthreshold[name]["mean"]
+        if mean["input_resistance"] is None:
+            logger.warning(
+                "%s: no step gives the subthreshold features, "
+                "e.g. because the cell spikes.",
+                name,
+            )
+        else:
+            logger.info(
+                "%s: input resistance %.4g MOhm, membrane time constant %s ms, "
+                "sag ratio %.3f",
+                name,
+                mean["input_resistance"],
+                mean["time_constant"],
+                mean["sag_ratio"],
+            )
+
+    return subthreshold
@@ -0,0 +1,264 @@
+"""Summary of a run, flagging traces with a suspicious behaviour."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import logging
+from pathlib import Path
+
+import numpy as np
+
+from emodelrunner.json_utilities import NpEncoder
+from emodelrunner.results import detect_spikes, parse_output_filename
+
+logger = logging.getLogger(__name__)
+
+
+def longest_run_above(time, voltage, threshold):
+    """Return the duration of the longest period spent above a voltage threshold.
+
+    Args:
+        time (numpy.ndarray): time of the trace (ms)
+        voltage (numpy.ndarray): voltage of the trace (mV)
+        threshold (float): voltage threshold (mV)
+
+    Returns:
+        float: the duration of the longest period above threshold (ms)
+    """
+    time = np.asarray(time)
+    above = np.asarray(voltage) >= threshold
+    longest = 0.0
+    start = None
+    for idx, is_above in enumerate(above):
+        if is_above and start is None:
+            start = time[idx]
+        elif not is_above and start is not None:
+            longest = max(longest, time[idx] - start)
+            start = None
+    if start is not None:
+        longest = max(longest, time[-1] - start)
+    return longest
+
+
+def detect_depolarization_block(
+    time,
+    voltage,
+    stim_start=None,
+    stim_end=None,
+    spike_threshold=-20.0,
+    block_threshold=-50.0,
+    min_duration=50.0,
+):
+    """Detect whether a trace enters depolarization block.
+
+    A trace is considered to be in depolarization block when the cell has spiked
+    during the stimulus, and then stays depolarized above block_threshold
+    for at least min_duration without spiking.
+
+    Args:
+        time (numpy.ndarray): time of the trace (ms)
+        voltage (numpy.ndarray): voltage of the trace (mV)
+        stim_start (float): time at which the stimulus starts (ms).
+            The start of the trace is used if None.
+        stim_end (float): time at which the stimulus ends (ms).
+            The end of the trace is used if None.
+        spike_threshold (float): voltage threshold for spike detection (mV)
+        block_threshold (float): voltage above which the cell is considered depolarized (mV)
+        min_duration (float): minimum duration of the depolarization without spikes (ms)
+
+    Returns:
+        bool: True if the trace shows depolarization block
+    """
+    time = np.asarray(time)
+    voltage = np.asarray(voltage)
+    if stim_start is None:
+        stim_start = time[0]
+    if stim_end is None:
+        stim_end = time[-1]
+
+    in_stim = (time >= stim_start) & (time <= stim_end)
+    spikes = detect_spikes(time[in_stim], voltage[in_stim], spike_threshold)
+    if len(spikes) == 0:
+        return False
+
+    after_last_spike = in_stim & (time > spikes[-1])
+    # skip the repolarizing phase of the last spike
+    after_last_spike &= voltage < spike_threshold
+    if not np.any(after_last_spike):
+        return False
+    first_idx = np.argmax(after_last_spike)
+    last_idx = np.where(in_stim)[0][-1] + 1
+
+    return (
+        longest_run_above(
+            time[first_idx:last_idx], voltage[first_idx:last_idx], block_threshold
+        )
+        >= min_duration
+    )
+
+
+def first_spike_latency(time, voltage, stim_start=None, spike_threshold=-20.0):
+    """Return the latency of the first spike after the start of the stimulus.
+
+    Args:
+        time (numpy.ndarray): time of the trace (ms)
+        voltage (numpy.ndarray): voltage of the trace (mV)
+        stim_start (float): time at which the stimulus starts (ms).
+            The start of the trace is used if None.
+        spike_threshold (float): voltage threshold for spike detection (mV)
+
+    Returns:
+        float: latency of the first spike (ms), or None if there is no spike
+    """
+    time = np.asarray(time)
+    voltage = np.asarray(voltage)
+    if stim_start is None:
+        stim_start = time[0]
+
+    after_start = time >= stim_start
+    spikes = detect_spikes(time[after_start], voltage[after_start], spike_threshold)
+    if len(spikes) == 0:
+        return None
+    return spikes[0] - stim_start
+
+
+def get_latency_curve(
+    responses, step_amplitudes, stim_windows=None, spike_threshold=-20.0
+):
+    """Compute the first spike latency versus the step amplitude of the step protocols.
+
+    Args:
+        responses (dict): responses of the protocols
+        step_amplitudes (dict): step amplitude (nA) for each protocol name
+        stim_windows (dict): (start, end) of the stimulus (ms) for each protocol name
+        spike_threshold (float): voltage threshold for spike detection (mV)
+
+    Returns:
+        dict containing the 'protocol' names, the 'step_amplitude' (nA)
+        and the first spike 'latency' (ms, None if there is no spike),
+        sorted by step amplitude
+    """
+    if stim_windows is None:
+        stim_windows = {}
+
+    points = []
+    for key, resp in responses.items():
+        if not isinstance(resp, dict) or "voltage" not in resp:
+            continue
+        metadata = parse_output_filename(key)
+        if metadata["location"] != "soma" or metadata["variable"] != "v":
+            continue
+        protocol_name = metadata["protocol"]
+        if step_amplitudes.get(protocol_name) is None:
+            continue
+
+        stim_start = stim_windows.get(protocol_name, (None, None))[0]
+        latency = first_spike_latency(
+            resp["time"], resp["voltage"], stim_start, spike_threshold
+        )
+        points.append((step_amplitudes[protocol_name], protocol_name, latency))
+
+    points.sort(key=lambda point: point[0])
+    return {
+        "protocol": [point[1] for point in points],
+        "step_amplitude": [point[0] for point in points],
+        "latency": [point[2] for point in points],
+    }
+
+
+def get_trace_summary(key, response, stim_window=None, spike_threshold=-20.0):
+    """Summarize one voltage response.
+
+    Args:
+        key (str): name of the response
+        response (dict): response with 'time' and 'voltage' fields
+        stim_window (tuple): start and end of the stimulus (ms), or None
+        spike_threshold (float): voltage threshold for spike detection (mV)
+
+    Returns:
+        dict containing the number of spikes and the depolarization block flag
+    """
+    time = np.asarray(response["time"])
+    voltage = np.asarray(response["voltage"])
+    stim_start, stim_end = stim_window if stim_window is not None else (None, None)
+
+    depol_block = detect_depolarization_block(
+        time, voltage, stim_start, stim_end, spike_threshold=spike_threshold
+    )
+    if depol_block:
+        logger.warning("Depolarization block detected in %s", key)
+
+    return {
+        "n_spikes": len(detect_spikes(time, voltage, spike_threshold)),
+        "depolarization_block": depol_block,
+    }
+
+
+def get_run_summary(
+    responses, stim_windows=None, spike_threshold=-20.0, step_amplitudes=None
+):
+    """Summarize the somatic voltage responses of a run.
+
+    Args:
+        responses (dict): responses of the protocols
+        stim_windows (dict): (start, end) of the stimulus (ms) for each protocol name
+        spike_threshold (float): voltage threshold for spike detection (mV)
+        step_amplitudes (dict): step amplitude (nA) for each step protocol name.
+            If given, the first spike latency versus step amplitude curve is added.
+
+    Returns:
+        dict containing the summary of each voltage trace,
+        the list of the traces showing depolarization block,
+        and the latency curve if step_amplitudes is given
+    """
+    if stim_windows is None:
+        stim_windows = {}
+
+    traces = {}
+    for key, resp in responses.items():
+        if not isinstance(resp, dict) or "voltage" not in resp:
+            continue
+        metadata = parse_output_filename(key)
+        if metadata["kind"] != "trace" or metadata["variable"] != "v":
+            continue
+        traces[key] = get_trace_summary(
+            key, resp, stim_windows.get(metadata["protocol"]), spike_threshold
+        )
+
+    summary = {
+        "traces": traces,
+        "depolarization_block": [
+            key for key, trace in traces.items() if trace["depolarization_block"]
+        ],
+    }
+    if step_amplitudes is not None:
+        summary["latency_curve"] = get_latency_curve(
+            responses, step_amplitudes, stim_windows, spike_threshold
+        )
+
+    return summary
+
+
+def write_run_summary(summary, output_dir, filename="summary.json"):
+    """Write the run summary as json.
+
+    Args:
+        summary (dict): the run summary
+        output_dir (str): path to the output directory
+        filename (str): name of the summary file
+    """
+    output_path = Path(output_dir) / filename
+    with open(output_path, "w", encoding="utf-8") as out_file:
+        json.dump(summary, out_file, indent=4, cls=NpEncoder)
@@ -0,0 +1,303 @@
+"""Parameter sweeps running the combinations of config and protocol values."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import itertools
+import json
+import logging
+import multiprocessing
+from pathlib import Path
+
+import pandas as pd
+
+from emodelrunner.configuration import PackageType
+from emodelrunner.configuration.validator import to_config_value
+from emodelrunner.json_utilities import NpEncoder
+from emodelrunner.load import load_config
+from emodelrunner.logging_utilities import neuron_output_to_logger
+from emodelrunner.protocols.reader import ProtocolParser
+from emodelrunner.run import run
+from emodelrunner.summary import get_run_summary
+
+logger = logging.getLogger(__name__)
+
+# parameters starting with this prefix are set in the protocols file,
+# e.g. 'protocols:Step_150.stimuli.step.amp'
+PROTOCOL_PARAMETER_PREFIX = "protocols:"
+SWEEP_MODES = ["grid", "list"]
+
+
+def load_sweep_definition(sweep_path):
+    """Load the sweep mode and the values of each swept parameter.
+
+    Args:
+        sweep_path (str or Path): path to the json file defining the sweep, e.g.
+            {"mode": "grid", "parameters": {"Cell.celsius": [30, 34]}}
+
+    Raises:
+        ValueError: if the mode is not supported or if a parameter has no values
+
+    Returns:
+        dict: the sweep mode and the values of each parameter
+    """
+    with open(sweep_path, "r", encoding="utf-8") as sweep_file:
+        sweep_definition = json.load(sweep_file)
+
+    mode = sweep_definition.get("mode", "grid")
+    if mode not in SWEEP_MODES:
+        raise ValueError(
+            f"Unsupported sweep mode {mode}. Expected one of {SWEEP_MODES}."
+        )
+
+    parameters = sweep_definition.get("parameters")
+    if not isinstance(parameters, dict) or not parameters:
+        raise ValueError(f"No parameters to sweep are defined in {sweep_path}.")
+    for name, values in parameters.items():
+        if not isinstance(values, list) or not values:
+            raise ValueError(f"The values of {name} should be a non-empty list.")
+
+    return {"mode": mode, "parameters": parameters}
+
+
+def get_sweep_points(parameters, mode="grid"):
+    """Return the parameter values of each run of the sweep.
+
+    Args:
+        parameters (dict): list of values for each parameter name
+        mode (str): "grid" to run all the combinations of the values,
+            "list" to run the i-th values of all the parameters together
+
+    Raises:
+        ValueError: if the mode is not supported, or if the parameters
+            do not have the same number of values in list mode
+
+    Returns:
+        list of dict: the value of each parameter, for each run
+    """
+    names = list(parameters)
+    if mode == "grid":
+        combinations = itertools.product(*parameters.values())
+    elif mode == "list":
+        if len({len(values) for values in parameters.values()}) > 1:
+            raise ValueError(
+                "All the parameters should have the same number of values "
+                "in list mode."
+            )
+        combinations = zip(*parameters.values())
+    else:
+        raise ValueError(
+            f"Unsupported sweep mode {mode}. Expected one of {SWEEP_MODES}."
+        )
+
+    return [dict(zip(names, combination)) for combination in combinations]
+
+
+def set_protocol_value(protocol_definitions, path, value):
+    """Set a value of the protocol definitions.
+
+    Args:
+        protocol_definitions (dict): protocols as loaded from the protocols file
+        path (str): dot-separated keys leading to the value,
+            with the indices of the lists, e.g. 'Step_150.stimuli.step.0.amp'
+        value: the new value
+
+    Raises:
+        ValueError: if the path does not lead to an existing value
+    """
+    keys = path.split(".")
+    container = protocol_definitions
+    try:
+        for key in keys[:-1]:
+            container = container[int(key) if isinstance(container, list) else key]
+        last_key = int(keys[-1]) if isinstance(container, list) else keys[-1]
+        # only existing values can be swept, to catch the typos in the paths
+        _ = container[last_key]
+    except (KeyError, IndexError, ValueError, TypeError) as exc:
+        raise ValueError(f"{path} is not a value of the protocols file.") from exc
+
+    container[last_key] = value
+
+
+def get_point_overrides(config, point, run_dir):
+    """Return the config overrides of a run of the sweep.
+
+    The protocols file with the swept protocol values is written in the run directory.
+
+    Args:
+        config (configparser.ConfigParser): the base configuration of the sweep
+        point (dict): the value of each swept parameter for this run
+        run_dir (Path): the output directory of this run
+
+    Returns:
+        list of str: the config overrides, given as 'section.key=value'
+    """
+    config_overrides = [f"Paths.output_dir={run_dir}"]
+
+    protocol_values = {}
+    for name, value in point.items():
+        if name.startswith(PROTOCOL_PARAMETER_PREFIX):
+            protocol_values[name[len(PROTOCOL_PARAMETER_PREFIX) :]] = value
+        else:
+            config_overrides.append(f"{name}={to_config_value(value)}")
+
+    if protocol_values:
+        protocol_definitions = ProtocolParser.load_protocol_json(
+            config.get("Paths", "prot_path")
+        )
+        for path, value in protocol_values.items():
+            set_protocol_value(protocol_definitions, path, value)
+
+        prot_path = run_dir / "protocols.json"
+        with open(prot_path, "w", encoding="utf-8") as prot_file:
+            json.dump(protocol_definitions, prot_file, indent=4)
+        config_overrides.append(f"Paths.prot_path={prot_path}")
+
+    return config_overrides
+
+
+def get_run_results(responses, spike_threshold=-20.0):
+    """Return the scalar results of a run, i.e. one row of the sweep summary table.
+
+    Args:
+        responses (dict): responses of the protocols
+        spike_threshold (float): voltage threshold for spike detection (mV)
+
+    Returns:
+        dict: the scalar responses, e.g. the holding current,
+            and the number of spikes of each somatic voltage trace
+    """
+    results = {
+        key: response
+        for key, response in responses.items()
+        if isinstance(response, (int, float))
+    }
+
+    summary = get_run_summary(responses, spike_threshold=spike_threshold)
+    for key, trace in summary["traces"].items():
+        results[f"{key}.n_spikes"] = trace["n_spikes"]
+
+    return results
+
+
+def run_sweep_point(task):
+    """Run one point of the sweep. Used in the worker processes.
+
+    Args:
+        task (tuple): config path and config overrides of the run
+
+    Returns:
+        dict: status of the run, with the error message if it failed
+            and the scalar results if it succeeded
+    """
+    config_path, config_overrides = task
+    try:
+        config = load_config(config_path, config_overrides)
+        with neuron_output_to_logger():
+            responses = run(config, write_output=True)
+    except Exception as exc:  # pylint: disable=broad-except
+        logger.error("Sweep run with %s failed: %s", config_overrides, exc)
+        return {"status": "failed", "error": str(exc)}
+
+    return {"status": "done", "error": "", **get_run_results(responses)}
+
+
+def run_sweep(
+    config_path,
+    parameters,
+    output_dir,
+    mode="grid",
+    n_processes=None,
+    config_overrides=None,
+):
+    """Run a sscx, thalamus or hippocampus config for each combination of the swept values.
+
+    Each run is done in its own process, in parallel, and writes its outputs
+    in its own directory, e.g. output_dir/run_0000.
+    The index of the runs is written in output_dir/sweep.json,
+    and their parameters and scalar results in output_dir/sweep.csv.
+
+    Args:
+        config_path (str): path to the base config of the sweep
+        parameters (dict): list of values for each parameter name.
+            The names are either config values, e.g. 'Cell.celsius',
+            or protocol values, e.g. 'protocols:Step_150.stimuli.step.amp'
+        output_dir (str or Path): directory in which to write the outputs of the sweep
+        mode (str): "grid" to run all the combinations of the values,
+            "list" to run the i-th values of all the parameters together
+        n_processes (int): number of runs in parallel. The number of CPUs if None.
+        config_overrides (list of str): values overriding the ones of the base config,
+            given as 'section.key=value'
+
+    Raises:
+        ValueError: if the config is a synplas config
+
+    Returns:
+        pandas.DataFrame: the parameters, status and scalar results of each run
+    """
+    # pylint: disable=too-many-arguments, too-many-locals
+    config = load_config(config_path, config_overrides)
+    if config.package_type == PackageType.synplas:
+        raise ValueError("Only sscx, thalamus and hippocampus configs can be swept.")
+
+    output_dir = Path(output_dir)
+    points = get_sweep_points(parameters, mode)
+
+    runs = []
+    for run_id, point in enumerate(points):
+        run_dir = output_dir / f"run_{run_id:04d}"
+        run_dir.mkdir(parents=True, exist_ok=True)
+        runs.append(
+            {
+                "run_id": run_id,
+                "run_dir": str(run_dir),
+                "parameters": point,
+                "config_overrides": (config_overrides or [])
+                + get_point_overrides(config, point, run_dir),
+            }
+        )
+
+    sweep_index = {
+        "config_path": str(config_path),
+        "config_overrides": config_overrides or [],
+        "mode": mode,
+        "parameters": parameters,
+        "runs": runs,
+    }
+    with open(output_dir / "sweep.json", "w", encoding="utf-8") as index_file:
+        json.dump(sweep_index, index_file, indent=4, cls=NpEncoder)
+
+    logger.info("Running %d sweep points.", len(runs))
+    # a new process for each run, so that no NEURON state is shared between runs
+    with multiprocessing.Pool(processes=n_processes, maxtasksperchild=1) as pool:
+        results = pool.map(
+            run_sweep_point,
+            [(config_path, run_["config_overrides"]) for run_ in runs],
+            chunksize=1,
+        )
+
+    table = pd.DataFrame(
+        [
+            {"run_id": run_["run_id"], "run_dir": run_["run_dir"], **run_["parameters"]}
+            for run_ in runs
+        ]
+    )
+    table = pd.concat([table, pd.DataFrame(results)], axis=1)
+    table.to_csv(output_dir / "sweep.csv", index=False)
+
+    n_failed = int((table["status"] == "failed").sum())
+    if n_failed:
+        logger.warning("%d of %d sweep runs failed.", n_failed, len(runs))
+
+    return table
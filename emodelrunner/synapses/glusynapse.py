@@ -29,6 +29,8 @@ class GluSynapseCustom(SynapseMixin):
         delay (float): synapse delay
         weight (float): synapse weight
         pre_mtype (int): ID (but not gid) of the presynaptic cell
+        pre_cell_id (int): gid of the presynaptic cell
+        sid (int): id of the synapse
         start (int/None): force synapse to start firing at given value when using NetStim
         interval (int/None): force synapse to fire at given interval when using NetStim
         number (int/None): force synapse to fire N times when using NetStim
@@ -94,6 +96,8 @@ class GluSynapseCustom(SynapseMixin):
         self.weight = synapse["weight"]
 
         self.pre_mtype = synapse["pre_mtype"]
+        self.pre_cell_id = synapse["pre_cell_id"]
+        self.sid = synapse["sid"]
 
         # netstim params if given
         self.start = start
@@ -37,6 +37,13 @@ class NrnMODPointProcessMechanismCustom(ephys.mechanisms.Mechanism):
         use_glu_synapse (bool): if True, instantiate synapses to use GluSynapse
         syn_setup_params (dict): contains extra parameters to setup synapses
             when using GluSynapseCustom
+        sectionlist_ids (list of ints): activate only synapses on these section lists
+            (0: soma, 1: basal, 2: apical, 3: axon). If None, all are activated.
+        path_distance_range (list of floats): activate only synapses whose path
+            distance from the soma (um) is within [min, max].
+            If None, all are activated.
+        pre_cell_ids (list of ints): activate only synapses whose pre_cell_id
+            is in this list. If None, all are activated.
         rng (neuron Random): random number generator of the simulator
         pprocesses (list of SynapseCustom or GluSynapseCustom): list of the synapses
     """
@@ -53,6 +60,9 @@ class NrnMODPointProcessMechanismCustom(ephys.mechanisms.Mechanism):
         comment="",
         use_glu_synapse=False,
         syn_setup_params=None,
+        sectionlist_ids=None,
+        path_distance_range=None,
+        pre_cell_ids=None,
     ):
         """Constructor.
 
@@ -72,6 +82,13 @@ class NrnMODPointProcessMechanismCustom(ephys.mechanisms.Mechanism):
             use_glu_synapse (bool): if True, instantiate synapses to use GluSynapse
             syn_setup_params (dict): contains extra parameters to setup synapses
                 when using GluSynapseCustom
+            sectionlist_ids (list of ints): activate only synapses on these
+                section lists. If None, all are activated.
+            path_distance_range (list of floats): activate only synapses whose
+                path distance from the soma (um) is within [min, max].
+                If None, all are activated.
+            pre_cell_ids (list of ints): activate only synapses whose
+                pre_cell_id is in this list. If None, all are activated.
         """
         # pylint: disable=too-many-arguments
         super().__init__(name, comment)
@@ -83,6 +100,9 @@ class NrnMODPointProcessMechanismCustom(ephys.mechanisms.Mechanism):
         self.stim_params = stim_params
         self.use_glu_synapse = use_glu_synapse
         self.syn_setup_params = syn_setup_params
+        self.sectionlist_ids = sectionlist_ids
+        self.path_distance_range = path_distance_range
+        self.pre_cell_ids = pre_cell_ids
         self.rng = None
         self.pprocesses = None
 
@@ -108,6 +128,39 @@ class NrnMODPointProcessMechanismCustom(ephys.mechanisms.Mechanism):
 
         return section
 
+    def is_selected(self, synapse, section, sim, icell):
+        """Returns whether a synapse is activated.
+
+        Args:
+            synapse (dict): contains the synapse data
+            section (neuron section): section on which is the synapse
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            icell (neuron cell): cell instantiation in simulator
+
+        Returns:
+            bool: True if the synapse passes all the selection criteria
+        """
+        if self.pre_mtypes is not None and synapse["pre_mtype"] not in self.pre_mtypes:
+            return False
+        if (
+            self.pre_cell_ids is not None
+            and synapse["pre_cell_id"] not in self.pre_cell_ids
+        ):
+            return False
+        if (
+            self.sectionlist_ids is not None
+            and synapse["sectionlist_id"] not in self.sectionlist_ids
+        ):
+            return False
+        if self.path_distance_range is not None:
+            distance = sim.neuron.h.distance(
+                icell.soma[0](0.5), section(synapse["seg_x"])
+            )
+            min_distance, max_distance = self.path_distance_range
+            if not min_distance <= distance <= max_distance:
+                return False
+        return True
+
     def instantiate(self, sim=None, icell=None):
         """Instantiate the synapses.
 
@@ -123,22 +176,16 @@ class NrnMODPointProcessMechanismCustom(ephys.mechanisms.Mechanism):
 
         self.pprocesses = []
         for synapse in self.synapses_data:
-            if self.pre_mtypes is None or synapse["pre_mtype"] in self.pre_mtypes:
-                # get section
-                section = self.get_cell_section_for_synapse(synapse, icell)
-
+            # get section
+            section = self.get_cell_section_for_synapse(synapse, icell)
+            if self.is_selected(synapse, section, sim, icell):
                 if self.use_glu_synapse:
-                    synapse_obj = GluSynapseCustom(
-                        sim,
-                        icell,
-                        synapse,
-                        section,
-                        self.seed,
-                        self.rng_settings_mode,
-                        self.synconf_dict,
-                    )
-                elif self.stim_params is None:
-                    synapse_obj = SynapseCustom(
+                    synapse_class = GluSynapseCustom
+                else:
+                    synapse_class = SynapseCustom
+
+                if self.stim_params is None:
+                    synapse_obj = synapse_class(
                         sim,
                         icell,
                         synapse,
@@ -149,7 +196,7 @@ class NrnMODPointProcessMechanismCustom(ephys.mechanisms.Mechanism):
                     )
                 else:
                     stim_params = self.stim_params[synapse["pre_mtype"]]
-                    synapse_obj = SynapseCustom(
+                    synapse_obj = synapse_class(
                         sim,
                         icell,
                         synapse,
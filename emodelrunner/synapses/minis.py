@@ -14,12 +14,25 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
+import logging
+
+import numpy as np
 from bluepyopt import ephys
 
+from emodelrunner.synapses.create_locations import get_syn_locs
+from emodelrunner.synapses.recordings import get_synapse_mechanism
+
+logger = logging.getLogger(__name__)
+
 
 # adapted from bglibpy.cell.add_replay_minis
 class Minis(ephys.stimuli.Stimulus):
-    """Current stimulus based on stochastic current amplitude."""
+    """Spontaneous release of the synapses, as Poisson events of InhPoissonStim.
+
+    The rate of a synapse is its rate in spont_minis_rate, or else the rate of its
+    pre_mtype in pre_mtype_rates, or else the default rate.
+    The synapses without rate do not release spontaneously.
+    """
 
     def __init__(
         self,
@@ -32,8 +45,27 @@ class Minis(ephys.stimuli.Stimulus):
         syn_location=None,
         popids=None,
         spont_minis_rate=None,
+        rate=None,
+        pre_mtype_rates=None,
     ):
-        """Constructor."""
+        """Constructor.
+
+        Args:
+            gid (int): gid of the cell, used to seed the random number generators
+            locations (list of ephys.locations.NrnPointProcessLocation):
+                locations of the synapses
+            stop (float): time at which the stimulus stops (ms)
+            minis_seed (int): seed of the spontaneous release
+            base_seed (int): seed of the synapses, used in the Compatibility mode
+            weight_scalar (dict): factor of the weight of some synapse ids
+            syn_location (dict): position of some synapse ids along their section,
+                the one of the synapse point process if not given
+            popids (dict): (source, target) population ids of some synapse ids
+            spont_minis_rate (dict): spontaneous release rate of some synapse ids (Hz)
+            rate (float): default spontaneous release rate of the synapses (Hz)
+            pre_mtype_rates (dict): spontaneous release rate of the synapses
+                of some pre_mtype ids (Hz)
+        """
         # pylint: disable=too-many-arguments
         super().__init__()
         self.gid = gid
@@ -50,14 +82,40 @@ class Minis(ephys.stimuli.Stimulus):
         self.syn_location = syn_location
         self.popids = popids
         self.spont_minis_rate = spont_minis_rate
+        self.rate = rate
+        self.pre_mtype_rates = pre_mtype_rates
         self.persistent = []
         self.ips = {}
         self.syn_mini_netcons = {}
 
+    def get_rate(self, synapse, sid):
+        """Return the spontaneous release rate of a synapse.
+
+        Args:
+            synapse (SynapseCustom): the synapse
+            sid (int): synapse id
+
+        Returns:
+            float: spontaneous release rate (Hz), or None if the synapse has none
+        """
+        if self.spont_minis_rate is not None and sid in self.spont_minis_rate:
+            return self.spont_minis_rate[sid]
+        if (
+            self.pre_mtype_rates is not None
+            and synapse.pre_mtype in self.pre_mtype_rates
+        ):
+            return self.pre_mtype_rates[synapse.pre_mtype]
+        return self.rate
+
     def instantiate(self, sim=None, icell=None):
         """Run stimulus."""
         # pylint: disable=too-many-locals, too-many-branches, too-many-statements
         # pylint: disable=consider-using-f-string
+        if not hasattr(sim.neuron.h, "InhPoissonStim"):
+            raise RuntimeError(
+                "The InhPoissonStim mechanism needed by the minis is not compiled. "
+                "Add InhPoissonStim.mod to the mechanisms directory."
+            )
         if self.persistent is None:
             self.persistent = []
         if self.ips is None:
@@ -83,15 +141,16 @@ class Minis(ephys.stimuli.Stimulus):
                 else:
                     weight_scalar = 1.0
 
-                if self.spont_minis_rate is not None and sid in self.spont_minis_rate:
-                    spont_minis_rate = self.spont_minis_rate[sid]
-                else:
-                    spont_minis_rate = None
+                spont_minis_rate = self.get_rate(synapse, sid)
 
-                if spont_minis_rate is not None:
+                if spont_minis_rate:
+                    if self.syn_location is not None and sid in self.syn_location:
+                        syn_x = self.syn_location[sid]
+                    else:
+                        syn_x = synapse.hsynapse.get_segment().x
                     # add the *minis*: spontaneous synaptic events
                     self.ips[sid] = sim.neuron.h.InhPoissonStim(
-                        self.syn_location[sid], sec=synapse.section  # load this
+                        syn_x, sec=synapse.section
                     )
 
                     delay = 0.1
@@ -188,7 +247,80 @@ class Minis(ephys.stimuli.Stimulus):
         """String representation."""
         # pylint: disable=consider-using-f-string
         return (
-            "Minis at %s" % ",".join(location for location in self.locations)
+            "Minis at %s" % ",".join(str(location) for location in self.locations)
             if self.locations is not None
             else "Minis"
         )
+
+
+def load_minis_rates(rates_path):
+    """Load the spontaneous release rates of individual synapses.
+
+    Args:
+        rates_path (str): path to a text file with a synapse id and its rate (Hz)
+            on each line. The lines starting with '#' are skipped.
+
+    Raises:
+        ValueError: if the file has not 2 columns
+
+    Returns:
+        dict: spontaneous release rate (Hz) of each synapse id
+    """
+    rates = np.loadtxt(rates_path, comments="#", ndmin=2)
+    if rates.size and rates.shape[1] != 2:
+        raise ValueError(
+            f"{rates_path} should have 2 columns: synapse id and rate (Hz)."
+        )
+    return {int(sid): float(rate) for sid, rate in rates}
+
+
+def add_minis(ephys_protocols, cell, minis_args):
+    """Add the spontaneous minis of the configuration to the protocols.
+
+    Args:
+        ephys_protocols (bluepyopt.ephys.protocols.SequenceProtocol): the protocols
+        cell (CellModelCustom): the cell model, with its synapse mechanism
+        minis_args (dict): spontaneous minis configuration data
+            See load.get_minis_args for details
+
+    Raises:
+        ValueError: if a protocol does not exist or has no stimuli
+
+    Returns:
+        list of Minis: the added stimuli
+    """
+    stimuli = []
+    if not minis_args["protocols"]:
+        return stimuli
+
+    get_synapse_mechanism(cell)
+    spont_minis_rate = None
+    if minis_args["rates_path"]:
+        spont_minis_rate = load_minis_rates(minis_args["rates_path"])
+
+    subprotocols = ephys_protocols.subprotocols()
+    for protocol_name in minis_args["protocols"]:
+        if protocol_name not in subprotocols:
+            raise ValueError(
+                f"Protocol {protocol_name} not found. Choose from {list(subprotocols)}."
+            )
+        protocol = subprotocols[protocol_name]
+        if not hasattr(protocol, "stimuli"):
+            raise ValueError(
+                f"Protocol {protocol_name} has no stimuli. "
+                "Choose one of its subprotocols instead."
+            )
+        stimulus = Minis(
+            minis_args["gid"],
+            locations=get_syn_locs(cell),
+            stop=protocol.total_duration,
+            minis_seed=minis_args["seed"],
+            base_seed=minis_args["base_seed"],
+            spont_minis_rate=spont_minis_rate,
+            rate=minis_args["rate"],
+            pre_mtype_rates=minis_args["pre_mtype_rates"],
+        )
+        protocol.stimuli.append(stimulus)
+        stimuli.append(stimulus)
+        logger.debug("Spontaneous minis added to %s", protocol_name)
+    return stimuli
@@ -16,10 +16,21 @@
 
 import logging
 
+import numpy as np
 from bluepyopt import ephys
 
+from emodelrunner.extracellular import add_extracellular_recording
+from emodelrunner.recordings import (
+    check_sampling,
+    get_sampling_interval,
+    record_variable,
+)
+from emodelrunner.synapses.mechanism import NrnMODPointProcessMechanismCustom
+
 logger = logging.getLogger(__name__)
 
+SYNAPSE_RECORDING_SUFFIX = ".synapses"
+
 
 class SynapseRecordingCustom(ephys.recordings.Recording):
     """Recording in synaptic locations.
@@ -28,22 +39,33 @@ class SynapseRecordingCustom(ephys.recordings.Recording):
         name (str): name of this object
         location (Location): location in the model of the recording
         variable (str): which variable to record from (e.g. 'v')
+        record_dt (float): interval between the recorded samples (ms)
+        record_every (int): number of integration steps between the recorded samples
         varvectors (list of neuron Vector): vectors recording the variable
         tvector (neuron Vector): vector recording the time (ms)
         instantiated (bool): whether the object has been instantiated or not
     """
 
-    def __init__(self, name=None, location=None, variable="v"):
+    def __init__(
+        self, name=None, location=None, variable="v", record_dt=None, record_every=None
+    ):
         """Constructor.
 
         Args:
             name (str): name of this object
             location (Location): location in the model of the recording
             variable (str): which variable to record from (e.g. 'v')
+            record_dt (float): interval between the recorded samples (ms)
+            record_every (int): number of integration steps between the recorded
+                samples. The variable is recorded at every step if both are None.
         """
+        # pylint: disable=too-many-arguments
         super().__init__(name=name)
         self.location = location
         self.variable = variable
+        check_sampling(record_dt, record_every)
+        self.record_dt = record_dt
+        self.record_every = record_every
 
         self.varvectors = []
         self.tvector = None
@@ -81,14 +103,20 @@ class SynapseRecordingCustom(ephys.recordings.Recording):
             "Adding compartment recording of %s at %s", self.variable, self.location
         )
 
+        interval = get_sampling_interval(sim, self.record_dt, self.record_every)
         pprocesses = self.location.instantiate(sim=sim, icell=icell)
         for synapse in pprocesses:
-            varvector = sim.neuron.h.Vector()
-            varvector.record(getattr(synapse.hsynapse, f"_ref_{self.variable}"))
-            self.varvectors.append(varvector)
+            self.varvectors.append(
+                record_variable(
+                    sim,
+                    getattr(synapse.hsynapse, f"_ref_{self.variable}"),
+                    interval,
+                )
+            )
 
-        self.tvector = sim.neuron.h.Vector()
-        self.tvector.record(sim.neuron.h._ref_t)  # pylint: disable=W0212
+        self.tvector = record_variable(
+            sim, sim.neuron.h._ref_t, interval  # pylint: disable=W0212
+        )
 
         self.instantiated = True
 
@@ -110,3 +138,228 @@ class SynapseRecordingCustom(ephys.recordings.Recording):
             string representation
         """
         return f"{self.name}: {self.variable} at {self.location}"
+
+
+class SynapseVariablesRecording(ephys.recordings.Recording):
+    """Variables of the individual synapses of a synapse mechanism.
+
+    The synapses are the ones activated by the mechanism,
+    optionally restricted to some synapse ids or to some pre_mtypes.
+    The synapses without a variable, e.g. the plasticity variables
+    of the inhibitory synapses, get NaN values for this variable.
+
+    Attributes:
+        name (str): name of this object
+        mechanism (NrnMODPointProcessMechanismCustom): the synapse mechanism
+        variables (list of str): variables to record, e.g. ["g", "i"]
+        synapse_ids (list of int): ids of the recorded synapses.
+            If None, all the synapses are recorded.
+        pre_mtypes (list of int): pre_mtype ids of the recorded synapses.
+            If None, all the synapse groups are recorded.
+        record_dt (float): interval between the recorded samples (ms)
+        record_every (int): number of integration steps between the recorded samples
+        synapses (list of SynapseCustom or GluSynapseCustom): recorded synapses
+        varvectors (dict): for each variable, the neuron Vector recording it
+            at each synapse, or None if the synapse has no such variable
+        tvector (neuron Vector): vector recording the time (ms)
+        instantiated (bool): whether the object has been instantiated or not
+    """
+
+    def __init__(
+        self,
+        name=None,
+        mechanism=None,
+        variables=None,
+        synapse_ids=None,
+        pre_mtypes=None,
+        record_dt=None,
+        record_every=None,
+    ):
+        """Constructor.
+
+        Args:
+            name (str): name of this object
+            mechanism (NrnMODPointProcessMechanismCustom): the synapse mechanism
+            variables (list of str): variables to record, e.g. ["g", "i"]
+            synapse_ids (list of int): ids of the recorded synapses.
+                If None, all the synapses are recorded.
+            pre_mtypes (list of int): pre_mtype ids of the recorded synapses.
+                If None, all the synapse groups are recorded.
+            record_dt (float): interval between the recorded samples (ms)
+            record_every (int): number of integration steps between the recorded
+                samples. The variables are recorded at every step if both are None.
+        """
+        # pylint: disable=too-many-arguments
+        super().__init__(name=name)
+        self.mechanism = mechanism
+        self.variables = variables
+        self.synapse_ids = synapse_ids
+        self.pre_mtypes = pre_mtypes
+        check_sampling(record_dt, record_every)
+        self.record_dt = record_dt
+        self.record_every = record_every
+
+        self.synapses = None
+        self.varvectors = None
+        self.tvector = None
+        self.instantiated = False
+
+    def get_recorded_synapses(self):
+        """Return the instantiated synapses to record.
+
+        Returns:
+            list of SynapseCustom or GluSynapseCustom: the recorded synapses
+        """
+        return [
+            synapse
+            for synapse in self.mechanism.pprocesses
+            if (self.synapse_ids is None or synapse.sid in self.synapse_ids)
+            and (self.pre_mtypes is None or synapse.pre_mtype in self.pre_mtypes)
+        ]
+
+    def instantiate(self, sim=None, icell=None):
+        """Instantiate recording.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            icell (neuron cell): cell instantiation in simulator
+        """
+        # pylint: disable=unused-argument
+        self.synapses = self.get_recorded_synapses()
+        logger.debug(
+            "Adding recording of %s at %d synapses", self.variables, len(self.synapses)
+        )
+
+        interval = get_sampling_interval(sim, self.record_dt, self.record_every)
+        self.varvectors = {}
+        for variable in self.variables:
+            ref = f"_ref_{variable}"
+            self.varvectors[variable] = []
+            for synapse in self.synapses:
+                if hasattr(synapse.hsynapse, ref):
+                    varvector = record_variable(
+                        sim, getattr(synapse.hsynapse, ref), interval
+                    )
+                else:
+                    varvector = None
+                self.varvectors[variable].append(varvector)
+            if self.synapses and all(v is None for v in self.varvectors[variable]):
+                logger.warning("None of the recorded synapses has %s.", variable)
+
+        self.tvector = record_variable(
+            sim, sim.neuron.h._ref_t, interval  # pylint: disable=protected-access
+        )
+
+        self.instantiated = True
+
+    @property
+    def response(self):
+        """Return the variables of the synapses.
+
+        Returns:
+            dict containing the time (ms), the synapse ids, their pre_mtype ids
+            and the (n_synapses, n_times) values of each variable
+        """
+        if not self.instantiated:
+            return None
+
+        time = np.array(self.tvector)
+        response = {
+            "time": time,
+            "synapse_ids": np.array([synapse.sid for synapse in self.synapses]),
+            "pre_mtypes": np.array([synapse.pre_mtype for synapse in self.synapses]),
+        }
+        for variable, varvectors in self.varvectors.items():
+            response[variable] = np.array(
+                [
+                    np.full(len(time), np.nan) if vector is None else np.array(vector)
+                    for vector in varvectors
+                ]
+            ).reshape(len(varvectors), len(time))
+        return response
+
+    def destroy(self, sim=None):
+        """Destroy recording.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        """
+        # pylint: disable=unused-argument
+        self.synapses = None
+        self.varvectors = None
+        self.tvector = None
+        self.instantiated = False
+
+    def __str__(self):
+        """String representation.
+
+        Returns:
+            string representation
+        """
+        return f"{self.name}: {self.variables} at the synapses of {self.mechanism.name}"
+
+
+def get_synapse_mechanism(cell):
+    """Return the synapse mechanism of a cell.
+
+    Args:
+        cell (CellModelCustom): the cell model
+
+    Raises:
+        ValueError: if the cell has no synapses
+
+    Returns:
+        NrnMODPointProcessMechanismCustom: the synapse mechanism
+    """
+    for mechanism in cell.mechanisms:
+        if isinstance(mechanism, NrnMODPointProcessMechanismCustom):
+            return mechanism
+    raise ValueError(
+        "The cell has no synapses to record. Set add_synapses to True in the config."
+    )
+
+
+def add_synapse_recordings(ephys_protocols, cell, synapse_recording_args, prefix=""):
+    """Add the synapse recordings of the configuration to the protocols.
+
+    Args:
+        ephys_protocols (bluepyopt.ephys.protocols.SequenceProtocol): the protocols
+        cell (CellModelCustom): the cell model, with its synapse mechanism
+        synapse_recording_args (dict): synapse recording configuration data
+            See load.get_synapse_recording_args for details
+        prefix (str): prefix used in naming responses, features, recordings, etc.
+
+    Returns:
+        list of str: names of the added recordings
+    """
+    names = []
+    if not synapse_recording_args["protocols"]:
+        return names
+
+    mechanism = get_synapse_mechanism(cell)
+    for protocol_name in synapse_recording_args["protocols"]:
+        recording = SynapseVariablesRecording(
+            name=f"{prefix}.{protocol_name}{SYNAPSE_RECORDING_SUFFIX}",
+            mechanism=mechanism,
+            variables=synapse_recording_args["variables"],
+            synapse_ids=synapse_recording_args["synapse_ids"],
+            pre_mtypes=synapse_recording_args["pre_mtypes"],
+            record_dt=synapse_recording_args["record_dt"],
+            record_every=synapse_recording_args["record_every"],
+        )
+        add_extracellular_recording(ephys_protocols, protocol_name, recording)
+        names.append(recording.name)
+    return names
+
+
+def pop_synapse_responses(responses):
+    """Remove the synapse responses from the responses and return them.
+
+    Args:
+        responses (dict): responses of the protocols, keyed by recording name
+
+    Returns:
+        dict: synapse responses, keyed by recording name
+    """
+    names = [name for name in responses if name.endswith(SYNAPSE_RECORDING_SUFFIX)]
+    return {name: responses.pop(name) for name in names}
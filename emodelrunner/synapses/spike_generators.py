@@ -0,0 +1,166 @@
+"""Generators of presynaptic spike times: inhomogeneous Poisson and bursts."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import numpy as np
+
+# key of the generator used by the synapse groups without their own generator
+DEFAULT_GROUP = "default"
+
+
+def poisson_spike_times(rng, start, stop, rate=None, rate_times=None, rates=None):
+    """Return the spike times of a Poisson process.
+
+    The rate is either constant, or linearly interpolated between the rates
+    given at rate_times, and constant outside of them. The inhomogeneous process
+    is drawn by thinning a homogeneous one at the maximum rate.
+
+    Args:
+        rng (numpy.random.Generator): random number generator
+        start (float): start of the spike train (ms)
+        stop (float): end of the spike train (ms)
+        rate (float): constant rate (Hz)
+        rate_times (list of float): times of the rate profile (ms)
+        rates (list of float): rates of the rate profile (Hz)
+
+    Returns:
+        numpy.ndarray: sorted spike times (ms)
+    """
+    if rate is not None:
+        rate_times, rates = [start], [rate]
+    max_rate = max(rates)
+    if max_rate <= 0 or stop <= start:
+        return np.array([])
+
+    # rates are in Hz, times in ms
+    n_spikes = rng.poisson(max_rate * (stop - start) / 1000.0)
+    spike_times = np.sort(rng.uniform(start, stop, n_spikes))
+    keep = rng.uniform(0, max_rate, n_spikes) < np.interp(
+        spike_times, rate_times, rates
+    )
+    return spike_times[keep]
+
+
+def burst_spike_times(
+    rng, start, stop, burst_interval, n_spikes, spike_interval, noise=0.0
+):
+    """Return the spike times of regular or noisy bursts.
+
+    The intervals between the burst onsets are drawn as in NEURON NetStim:
+    (1 - noise) * burst_interval + noise * exponential(burst_interval).
+
+    Args:
+        rng (numpy.random.Generator): random number generator
+        start (float): onset of the first burst (ms)
+        stop (float): end of the spike train (ms)
+        burst_interval (float): mean interval between the burst onsets (ms)
+        n_spikes (int): number of spikes of each burst
+        spike_interval (float): interval between the spikes of a burst (ms)
+        noise (float): fraction of randomness of the burst onsets,
+            from 0 (regular) to 1 (Poisson)
+
+    Returns:
+        numpy.ndarray: sorted spike times (ms)
+    """
+    burst_onsets = []
+    onset = start
+    while onset < stop:
+        burst_onsets.append(onset)
+        onset += (1.0 - noise) * burst_interval + noise * rng.exponential(
+            burst_interval
+        )
+
+    spike_times = np.array(
+        [onset + i * spike_interval for onset in burst_onsets for i in range(n_spikes)]
+    )
+    return spike_times[spike_times < stop]
+
+
+def check_generator_definition(group, definition):
+    """Check the definition of the spike generator of a synapse group.
+
+    Args:
+        group (str): the synapse group, i.e. the pre_mtype id or 'default'
+        definition (dict): definition of the generator, e.g.
+            {"type": "poisson", "rate_times": [0, 1000], "rates": [5, 20]}
+
+    Raises:
+        ValueError: if the definition is not valid
+    """
+    generator_type = definition.get("type")
+    if generator_type == "poisson":
+        if "rate" in definition:
+            rates = [definition["rate"]]
+        else:
+            rates = definition.get("rates", [])
+            rate_times = definition.get("rate_times", [])
+            if not rates or len(rates) != len(rate_times):
+                raise ValueError(
+                    f"Set the rate, or the rate_times and rates of the same length, "
+                    f"of the group {group}."
+                )
+            if list(rate_times) != sorted(rate_times):
+                raise ValueError(f"The rate_times of the group {group} are not sorted.")
+        if min(rates) < 0:
+            raise ValueError(f"The rates of the group {group} should be positive.")
+    elif generator_type == "burst":
+        for key in ["burst_interval", "n_spikes", "spike_interval"]:
+            if key not in definition:
+                raise ValueError(f"Set the {key} of the group {group}.")
+        if not 0 <= definition.get("noise", 0.0) <= 1:
+            raise ValueError(f"The noise of the group {group} should be in [0, 1].")
+        if definition["burst_interval"] <= 0:
+            raise ValueError(
+                f"The burst_interval of the group {group} should be positive."
+            )
+    else:
+        raise ValueError(
+            f"Unsupported spike generator {generator_type} for the group {group}. "
+            "Expected 'poisson' or 'burst'."
+        )
+
+
+def generate_spike_times(definition, rng, start, stop):
+    """Return the spike times drawn by a spike generator.
+
+    Args:
+        definition (dict): definition of the generator, with its type
+            and its parameters
+        rng (numpy.random.Generator): random number generator
+        start (float): start of the spike train (ms)
+        stop (float): end of the spike train (ms)
+
+    Returns:
+        numpy.ndarray: sorted spike times (ms)
+    """
+    parameters = {key: value for key, value in definition.items() if key != "type"}
+    if definition["type"] == "poisson":
+        return poisson_spike_times(rng, start, stop, **parameters)
+    return burst_spike_times(rng, start, stop, **parameters)
+
+
+def get_group_generator(generators, pre_mtype):
+    """Return the spike generator of the group of a synapse.
+
+    Args:
+        generators (dict): generator of each synapse group, keyed by
+            pre_mtype id (as str) or 'default'
+        pre_mtype (int): pre_mtype id of the synapse
+
+    Returns:
+        dict: definition of the generator, or None if the synapse
+            receives no spike
+    """
+    return generators.get(str(pre_mtype), generators.get(DEFAULT_GROUP))
@@ -0,0 +1,111 @@
+"""Presynaptic spike trains read from files, to be replayed on the synapses."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+from pathlib import Path
+
+import h5py
+import numpy as np
+
+from emodelrunner.sonata import load_spike_trains as load_sonata_spike_trains
+
+
+def load_text_spike_trains(spikes_path):
+    """Load the spike trains of a text file, e.g. the out.dat file of neurodamus.
+
+    Each line contains the time (ms) and the gid of a spike.
+    The lines starting with '/' or '#' are skipped.
+
+    Args:
+        spikes_path (str or Path): path to the text file
+
+    Raises:
+        ValueError: if a line does not contain a time and a gid
+
+    Returns:
+        dict: sorted spike times of each presynaptic cell {gid: spike_times}
+    """
+    spike_trains = {}
+    with open(spikes_path, "r", encoding="utf-8") as spikes_file:
+        for line_number, line in enumerate(spikes_file, start=1):
+            if not line.strip() or line.startswith(("/", "#")):
+                continue
+            try:
+                timestamp, gid = line.split()
+                spike_trains.setdefault(int(gid), []).append(float(timestamp))
+            except ValueError as exc:
+                raise ValueError(
+                    f"Line {line_number} of {spikes_path} should contain "
+                    f"the time and the gid of a spike: {line.strip()}"
+                ) from exc
+
+    return {gid: sorted(spike_times) for gid, spike_times in spike_trains.items()}
+
+
+def load_nwb_spike_trains(spikes_path):
+    """Load the spike trains of the units table of a NWB file.
+
+    The gids are read from the 'gid' column of the units table if it exists.
+    Otherwise, the unit ids are used as node ids, and the gids are the ids + 1.
+
+    Args:
+        spikes_path (str or Path): path to the NWB file
+
+    Raises:
+        ValueError: if the NWB file has no units table
+
+    Returns:
+        dict: sorted spike times of each presynaptic cell {gid: spike_times}
+    """
+    with h5py.File(spikes_path, "r") as nwb_file:
+        if "units" not in nwb_file:
+            raise ValueError(f"The NWB file {spikes_path} has no units table.")
+        units = nwb_file["units"]
+        if "gid" in units:
+            gids = units["gid"][()]
+        else:
+            gids = units["id"][()] + 1
+        spike_times = units["spike_times"][()]
+        ends = units["spike_times_index"][()]
+
+    # the NWB spike times are in s
+    starts = np.concatenate([[0], ends[:-1]])
+    return {
+        int(gid): sorted(float(time) * 1000.0 for time in spike_times[start:end])
+        for gid, start, end in zip(gids, starts, ends)
+    }
+
+
+def load_spike_trains(spikes_path, spike_population=""):
+    """Load the spike trains of the presynaptic cells from a file.
+
+    The format is given by the extension: SONATA spikes (.h5), NWB units table (.nwb)
+    or text file with the time and the gid of a spike on each line.
+
+    Args:
+        spikes_path (str or Path): path to the spikes file
+        spike_population (str): name of the node population of a SONATA spikes file.
+            Can be empty if the file has only one population.
+
+    Returns:
+        dict: sorted spike times of each presynaptic cell {gid: spike_times},
+            the gids being the pre_cell_id of the synapses
+    """
+    suffix = Path(spikes_path).suffix
+    if suffix == ".h5":
+        return load_sonata_spike_trains(spikes_path, spike_population)
+    if suffix == ".nwb":
+        return load_nwb_spike_trains(spikes_path)
+    return load_text_spike_trains(spikes_path)
@@ -15,8 +15,14 @@
 # limitations under the License.
 
 import random
+import numpy as np
 from bluepyopt import ephys
 
+from emodelrunner.synapses.spike_generators import (
+    generate_spike_times,
+    get_group_generator,
+)
+
 
 class NrnNetStimStimulusCustom(ephys.stimuli.Stimulus):
     """Current stimulus based on current amplitude and time series.
@@ -29,6 +35,7 @@ class NrnNetStimStimulusCustom(ephys.stimuli.Stimulus):
         start (float): most likely start time of first spike (ms)
         noise (float): fractional randomness (0 deterministic,
                 1 negexp interval distrubtion)
+        seed (int): seed of the Random123 generators of the noisy intervals
         connections (dict): contains simulator NetCon and NetStim
             so that they are persistent
     """
@@ -41,6 +48,7 @@ class NrnNetStimStimulusCustom(ephys.stimuli.Stimulus):
         number=None,
         start=None,
         noise=0,
+        seed=None,
     ):
         """Constructor.
 
@@ -52,6 +60,8 @@ class NrnNetStimStimulusCustom(ephys.stimuli.Stimulus):
             start (float): most likely start time of first spike (ms)
             noise (float): fractional randomness (0 deterministic,
                    1 negexp interval distrubtion)
+            seed (int): seed of the Random123 generators of the noisy intervals,
+                one stream per synapse. The generator of NEURON is used if None.
         """
         super().__init__()
         if total_duration is None:
@@ -63,6 +73,7 @@ class NrnNetStimStimulusCustom(ephys.stimuli.Stimulus):
         self.number = number
         self.start = start
         self.noise = noise
+        self.seed = seed
         self.connections = {}
 
     def instantiate(self, sim=None, icell=None):
@@ -90,6 +101,8 @@ class NrnNetStimStimulusCustom(ephys.stimuli.Stimulus):
                 netstim.noise = (
                     synapse.noise if synapse.noise is not None else self.noise
                 )
+                if self.seed is not None:
+                    netstim.noiseFromRandom123(self.seed, synapse.sid, 0)
                 netcon = sim.neuron.h.NetCon(
                     netstim, synapse.hsynapse, -30, synapse.delay, synapse.weight
                 )
@@ -125,6 +138,9 @@ class NrnVecStimStimulusCustom(ephys.stimuli.Stimulus):
         seed (int): seed for random number generator
         vecstim_random (str): origin of the random nmb gener. can be "python" or "neuron"
         pre_spike_train (list): list of spike train. If None, will be generated by random numbers
+        pre_spike_trains (dict): spike train of each presynaptic cell
+            {gid: spike_times}. If given, each synapse receives the spikes
+            of its presynaptic cell.
         connections (dict): contains simulator NetCon and VecStim and time Vector
             so that they are persistent
     """
@@ -137,6 +153,7 @@ class NrnVecStimStimulusCustom(ephys.stimuli.Stimulus):
         seed=1,
         vecstim_random="python",
         pre_spike_train=None,
+        pre_spike_trains=None,
     ):
         """Constructor.
 
@@ -148,6 +165,9 @@ class NrnVecStimStimulusCustom(ephys.stimuli.Stimulus):
             vecstim_random (str): origin of the random nmb gener. can be "python" or "neuron"
             pre_spike_train (list): list of spike train.
                 If None, will be generated by random numbers
+            pre_spike_trains (dict): spike train of each presynaptic cell
+                {gid: spike_times}, e.g. read from a SONATA spikes file.
+                If given, each synapse receives the spikes of its presynaptic cell.
         """
         super().__init__()
         if stop is None:
@@ -160,6 +180,7 @@ class NrnVecStimStimulusCustom(ephys.stimuli.Stimulus):
         self.seed = seed
         self.vecstim_random = vecstim_random
         self.pre_spike_train = pre_spike_train
+        self.pre_spike_trains = pre_spike_trains
         self.connections = {}
 
     def instantiate(self, sim=None, icell=None):
@@ -172,7 +193,7 @@ class NrnVecStimStimulusCustom(ephys.stimuli.Stimulus):
         if self.connections is None:
             self.connections = {}
 
-        if self.pre_spike_train is None:
+        if self.pre_spike_train is None and self.pre_spike_trains is None:
             if self.vecstim_random == "python":
                 random.seed(self.seed)
             else:
@@ -183,7 +204,10 @@ class NrnVecStimStimulusCustom(ephys.stimuli.Stimulus):
             self.connections[location.name] = []
             for synapse in location.instantiate(sim=sim, icell=icell):
                 # create spike_train
-                if self.pre_spike_train is not None:
+                if self.pre_spike_trains is not None:
+                    # the presynaptic cells without spikes are silent
+                    spike_train = self.pre_spike_trains.get(synapse.pre_cell_id, [])
+                elif self.pre_spike_train is not None:
                     spike_train = self.pre_spike_train
                 else:
                     if self.vecstim_random == "python":
@@ -219,6 +243,109 @@ class NrnVecStimStimulusCustom(ephys.stimuli.Stimulus):
         )
 
 
+class NrnSpikeGeneratorStimulus(ephys.stimuli.Stimulus):
+    """Spike trains drawn for each synapse by the generator of its group.
+
+    Attributes:
+        total_duration (float): time after which no synapses are allowed to fire (ms)
+        locations (list): synapse point processes locations to connect to
+        start (float): start of the spike trains (ms)
+        generators (dict): generator of each synapse group, keyed by
+            pre_mtype id (as str) or 'default'.
+            See spike_generators.check_generator_definition.
+        seed (int): seed of the random number generators
+        connections (dict): contains simulator NetCon and VecStim and time Vector
+            so that they are persistent
+    """
+
+    def __init__(
+        self, locations=None, start=0.0, stop=None, generators=None, seed=None
+    ):
+        """Constructor.
+
+        Args:
+            locations (list): synapse point processes locations to connect to
+            start (float): start of the spike trains (ms)
+            stop (float): time after which no synapses are allowed to fire (ms)
+            generators (dict): generator of each synapse group
+            seed (int): seed of the random number generators.
+                A random seed is drawn if None, and kept in the seed attribute
+                so that the spike trains can be reproduced.
+        """
+        super().__init__()
+        if stop is None:
+            raise ValueError("NrnSpikeGeneratorStimulus: Need to specify a stop time")
+        # must be named total_duration because of ephys.protocols
+        self.total_duration = stop
+
+        self.locations = locations
+        self.start = start
+        self.generators = generators or {}
+        if seed is None:
+            seed = int(np.random.SeedSequence().generate_state(1)[0])
+        self.seed = int(seed)
+        self.connections = {}
+
+    def get_spike_train(self, synapse):
+        """Return the spike train of a synapse.
+
+        The train only depends on the seed and on the synapse id,
+        so that it is the same whatever the other synapses.
+
+        Args:
+            synapse (SynapseCustom or GluSynapseCustom): the synapse
+
+        Returns:
+            numpy.ndarray: spike times (ms). Empty if the group of the synapse
+                has no generator.
+        """
+        generator = get_group_generator(self.generators, synapse.pre_mtype)
+        if generator is None:
+            return np.array([])
+        rng = np.random.default_rng([self.seed, synapse.sid])
+        return generate_spike_times(generator, rng, self.start, self.total_duration)
+
+    def instantiate(self, sim=None, icell=None):
+        """Instantiate stimuli and connections.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+            icell (neuron cell): cell instantiation in simulator
+        """
+        if self.connections is None:
+            self.connections = {}
+
+        for location in self.locations:
+            self.connections[location.name] = []
+            for synapse in location.instantiate(sim=sim, icell=icell):
+                t_vec = sim.neuron.h.Vector(self.get_spike_train(synapse))
+                vecstim = sim.neuron.h.VecStim()
+                vecstim.play(t_vec, sim.dt)
+                netcon = sim.neuron.h.NetCon(
+                    vecstim, synapse.hsynapse, -30, synapse.delay, synapse.weight
+                )
+
+                self.connections[location.name].append((netcon, vecstim, t_vec))
+
+    def destroy(self, sim=None):
+        """Destroy stimulus.
+
+        Args:
+            sim (bluepyopt.ephys.NrnSimulator): neuron simulator
+        """
+        # pylint: disable=unused-argument
+        self.connections = None
+
+    def __str__(self):
+        """String representation."""
+        # pylint: disable=consider-using-f-string
+        return (
+            "Spike generators at %s" % ",".join(location for location in self.locations)
+            if self.locations is not None
+            else "Spike generators"
+        )
+
+
 class NetConSpikeDetector(ephys.stimuli.Stimulus):
     """Netcon linking output from pre-cell to post-cell's synapses.
 
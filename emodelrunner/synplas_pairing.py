@@ -0,0 +1,261 @@
+"""Build STDP pairing protocols and measure their EPSP change."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import logging
+
+import numpy as np
+
+from emodelrunner.json_utilities import NpEncoder
+from emodelrunner.synplas_analysis import Experiment
+
+logger = logging.getLogger(__name__)
+
+PAIRING_ORDERS = ["pre_post", "post_pre"]
+
+
+def check_pairing_args(pairing_args):
+    """Check the parameters of a pairing protocol.
+
+    Args:
+        pairing_args (dict): pairing configuration data.
+            See load.get_pairing_args for details
+
+    Raises:
+        ValueError: if the parameters are not consistent
+    """
+    if pairing_args["order"] not in PAIRING_ORDERS:
+        raise ValueError(
+            f"Unsupported pairing order {pairing_args['order']}. "
+            f"Choose from {PAIRING_ORDERS}."
+        )
+    for key in ["frequency", "n_pairings", "n_repetitions", "test_period"]:
+        if pairing_args[key] <= 0:
+            raise ValueError(f"The {key} of the pairing should be positive.")
+    if pairing_args["delta_t"] < 0:
+        raise ValueError(
+            "The delta_t of the pairing should be positive. "
+            "Use the order to put the post-synaptic spike first."
+        )
+    if (
+        pairing_args["n_repetitions"] > 1
+        and pairing_args["n_pairings"] / pairing_args["frequency"]
+        > pairing_args["repetition_period"]
+    ):
+        raise ValueError(
+            "The pairings of a repetition should last less than the repetition_period."
+        )
+
+
+def get_pairing_timeline(pairing_args):
+    """Return the start of the phases of a pairing protocol.
+
+    The protocol consists of a baseline connectivity test (C01),
+    the induction by the pairings, and a second connectivity test (C02).
+
+    Args:
+        pairing_args (dict): pairing configuration data.
+            See load.get_pairing_args for details
+
+    Returns:
+        dict: start of the induction and of the C02 test, and end of the protocol (ms)
+    """
+    # minutes and seconds to ms
+    induction_start = pairing_args["baseline_duration"] * 60 * 1000.0
+    induction_duration = pairing_args["n_repetitions"] * max(
+        pairing_args["repetition_period"] * 1000.0,
+        pairing_args["n_pairings"] / pairing_args["frequency"] * 1000.0,
+    )
+    c02_start = induction_start + induction_duration
+    return {
+        "induction_start": induction_start,
+        "c02_start": c02_start,
+        "tstop": c02_start + pairing_args["test_duration"] * 60 * 1000.0,
+    }
+
+
+def get_pairing_onsets(pairing_args):
+    """Return the onsets of the post-synaptic pulses of the pairings.
+
+    Args:
+        pairing_args (dict): pairing configuration data.
+            See load.get_pairing_args for details
+
+    Returns:
+        numpy.ndarray: sorted onsets of the pulses (ms)
+    """
+    start = get_pairing_timeline(pairing_args)["induction_start"]
+    start += pairing_args["test_start"]
+    pairing_interval = 1000.0 / pairing_args["frequency"]
+    return np.array(
+        [
+            start
+            + repetition * pairing_args["repetition_period"] * 1000.0
+            + pairing * pairing_interval
+            for repetition in range(pairing_args["n_repetitions"])
+            for pairing in range(pairing_args["n_pairings"])
+        ]
+    )
+
+
+def get_pairing_spike_train(pairing_args):
+    """Return the pre-synaptic spike train of a pairing protocol.
+
+    The pre-synaptic cell fires at each test period during the C01 and C02 tests,
+    and delta_t before (pre_post) or after (post_pre) the post-synaptic spike
+    of each pairing.
+
+    Args:
+        pairing_args (dict): pairing configuration data.
+            See load.get_pairing_args for details
+
+    Returns:
+        numpy.ndarray: sorted pre-synaptic spike times (ms)
+    """
+    timeline = get_pairing_timeline(pairing_args)
+    test_period = pairing_args["test_period"] * 1000.0
+    n_c01 = int(timeline["induction_start"] / test_period)
+    n_c02 = int((timeline["tstop"] - timeline["c02_start"]) / test_period)
+
+    c01_spikes = pairing_args["test_start"] + test_period * np.arange(n_c01)
+    c02_spikes = (
+        timeline["c02_start"]
+        + pairing_args["test_start"]
+        + test_period * np.arange(n_c02)
+    )
+
+    postspikes = get_pairing_onsets(pairing_args) + pairing_args["postspike_latency"]
+    if pairing_args["order"] == "pre_post":
+        induction_spikes = postspikes - pairing_args["delta_t"]
+    else:
+        induction_spikes = postspikes + pairing_args["delta_t"]
+
+    return np.sort(np.concatenate([c01_spikes, induction_spikes, c02_spikes]))
+
+
+def get_pairing_pulses(pairing_args):
+    """Return the post-synaptic pulses of a pairing protocol, as in a stimuli file.
+
+    There is one pulse stimulus for each pairing of a repetition,
+    repeated at each repetition period.
+
+    Args:
+        pairing_args (dict): pairing configuration data.
+            See load.get_pairing_args for details
+
+    Returns:
+        dict: pulse stimuli, as read by create_stimuli.load_pulses
+    """
+    onsets = get_pairing_onsets(pairing_args)[: pairing_args["n_pairings"]]
+    return {
+        f"pulse{i}": {
+            "Mode": "Current",
+            "Pattern": "Pulse",
+            "AmpStart": pairing_args["pulse_amplitude"],
+            "AmpEnd": pairing_args["pulse_amplitude"],
+            "Frequency": 1.0 / pairing_args["repetition_period"],
+            "Width": pairing_args["pulse_width"],
+            "Delay": onset,
+            "Duration": pairing_args["n_repetitions"]
+            * pairing_args["repetition_period"]
+            * 1000.0,
+        }
+        for i, onset in enumerate(onsets)
+    }
+
+
+def write_pairing_protocol(pairing_args, spiketrain_path, stimuli_path, precell_gid):
+    """Write the pre-synaptic spike train and the pulses of a pairing protocol.
+
+    Args:
+        pairing_args (dict): pairing configuration data.
+            See load.get_pairing_args for details
+        spiketrain_path (str or Path): path to the spike train file to write
+        stimuli_path (str or Path): path to the pulse stimuli file to write
+        precell_gid (int): gid of the pre-synaptic cell
+
+    Returns:
+        numpy.ndarray: sorted pre-synaptic spike times (ms)
+    """
+    spike_train = get_pairing_spike_train(pairing_args)
+    with open(spiketrain_path, "w", encoding="utf-8") as spiketrain_file:
+        spiketrain_file.write("/scatter\n")
+        for spike_time in spike_train:
+            spiketrain_file.write(f"{spike_time} {precell_gid}\n")
+
+    with open(stimuli_path, "w", encoding="utf-8") as stimuli_file:
+        json.dump(get_pairing_pulses(pairing_args), stimuli_file, indent=4)
+
+    return spike_train
+
+
+def compute_pairing_epsps(time, voltage, spike_train, pairing_args, method="amplitude"):
+    """Compute the EPSP before and after the pairings, and their ratio.
+
+    Args:
+        time (numpy.ndarray): time of the soma voltage trace (ms)
+        voltage (numpy.ndarray): soma voltage trace (mV)
+        spike_train (numpy.ndarray): pre-synaptic spike times (ms)
+        pairing_args (dict): pairing configuration data.
+            See load.get_pairing_args for details
+        method (str): method used to compute the EPSP (amplitude or slope)
+
+    Returns:
+        dict: mean and std of the EPSP of the C01 and C02 tests, and EPSP ratio
+    """
+    exp = Experiment(
+        data={"t": time, "v": voltage, "prespikes": spike_train},
+        c01duration=pairing_args["baseline_duration"],
+        c02duration=pairing_args["test_duration"],
+        period=pairing_args["test_period"],
+    )
+    # all the sweeps of the shortest test
+    n = min(len(exp.cxspikes["C01"]), len(exp.cxspikes["C02"]))
+    (
+        epsp_before,
+        epsp_after,
+        epsp_ratio,
+        epsp_before_std,
+        epsp_after_std,
+    ) = exp.compute_epsp_ratio(n=n, method=method, full=True)
+    logger.info("EPSP ratio of the pairing protocol: %s", epsp_ratio)
+
+    signed_delta_t = pairing_args["delta_t"]
+    if pairing_args["order"] == "post_pre":
+        signed_delta_t = -signed_delta_t
+    return {
+        "frequency": pairing_args["frequency"],
+        # post-synaptic minus pre-synaptic spike time, as in the STDP curves
+        "delta_t": signed_delta_t,
+        "method": method,
+        "n_sweeps": n,
+        "epsp_before": epsp_before,
+        "epsp_before_std": epsp_before_std,
+        "epsp_after": epsp_after,
+        "epsp_after_std": epsp_after_std,
+        "epsp_ratio": epsp_ratio,
+    }
+
+
+def write_pairing_epsps(epsps, output_path):
+    """Write the EPSP measurements of a pairing protocol as json.
+
+    Args:
+        epsps (dict): EPSP measurements, as returned by compute_pairing_epsps
+        output_path (str or Path): path to the output json file
+    """
+    with open(output_path, "w", encoding="utf-8") as out_file:
+        json.dump(epsps, out_file, indent=4, cls=NpEncoder)
@@ -0,0 +1,260 @@
+"""Parallel trials of stochastic configs, with the trial-averaged responses."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import json
+import logging
+import multiprocessing
+from pathlib import Path
+
+import numpy as np
+
+from emodelrunner.configuration import PackageType
+from emodelrunner.json_utilities import NpEncoder
+from emodelrunner.load import load_config
+from emodelrunner.logging_utilities import neuron_output_to_logger
+from emodelrunner.output import resample_response
+from emodelrunner.plotting import plot_trial_average
+from emodelrunner.run import run
+from emodelrunner.seeds import derive_seed, get_global_seed
+
+logger = logging.getLogger(__name__)
+
+# step of the time grid of the averaged traces (ms)
+DEFAULT_AVERAGE_DT = 0.1
+
+
+def get_trial_seeds(seed, n_trials):
+    """Return the global seed of each trial, derived from the seed of the trials.
+
+    Args:
+        seed (int): seed of the trials
+        n_trials (int): number of trials
+
+    Returns:
+        list of int: global seed of each trial
+    """
+    return [derive_seed(seed, f"trial_{trial_id}") for trial_id in range(n_trials)]
+
+
+def run_trial(task):
+    """Run one trial. Used in the worker processes.
+
+    Args:
+        task (tuple): config path, config overrides of the trial
+            and step of the time grid of the traces (ms)
+
+    Returns:
+        dict: status of the trial, with the error message if it failed,
+            and the traces resampled on the time grid and the scalar responses
+            if it succeeded
+    """
+    config_path, config_overrides, dt = task
+    try:
+        config = load_config(config_path, config_overrides)
+        with neuron_output_to_logger():
+            responses = run(config, write_output=True)
+    except Exception as exc:  # pylint: disable=broad-except
+        logger.error("Trial with %s failed: %s", config_overrides, exc)
+        return {"status": "failed", "error": str(exc), "responses": {}}
+
+    resampled = {}
+    for key, response in responses.items():
+        response = resample_response(response, dt)
+        if isinstance(response, dict) and "voltage" in response:
+            resampled[key] = {
+                "time": np.asarray(response["time"]),
+                "voltage": np.asarray(response["voltage"]),
+            }
+        elif isinstance(response, (int, float)):
+            resampled[key] = float(response)
+    return {"status": "done", "error": "", "responses": resampled}
+
+
+def average_responses(trial_responses):
+    """Return the mean and standard deviation over the trials of each response.
+
+    Only the responses of all the trials are averaged. The traces have to be
+    on the same time grid, and are truncated to the shortest one.
+
+    Args:
+        trial_responses (list of dict): responses of each trial, with the traces
+            as dicts of time and voltage, and the scalar responses as floats
+
+    Returns:
+        dict: time, mean and std of each trace, and mean and std of each scalar
+    """
+    if not trial_responses:
+        return {}
+    keys = set(trial_responses[0])
+    for responses in trial_responses[1:]:
+        keys &= set(responses)
+
+    average = {}
+    for key in sorted(keys):
+        responses = [trial[key] for trial in trial_responses]
+        if isinstance(responses[0], dict):
+            n_samples = min(len(response["time"]) for response in responses)
+            values = np.array(
+                [response["voltage"][:n_samples] for response in responses]
+            )
+            average[key] = {
+                "time": responses[0]["time"][:n_samples],
+                "mean": values.mean(axis=0),
+                "std": values.std(axis=0),
+            }
+        else:
+            average[key] = {
+                "mean": float(np.mean(responses)),
+                "std": float(np.std(responses)),
+            }
+    return average
+
+
+def write_average(average, output_dir, plot=True, file_format="png"):
+    """Write the trial-averaged responses.
+
+    Each trace is written in {key}.dat, with the time, mean and standard deviation
+    columns, and the scalars in scalars.json. With plot, the mean of each trace
+    is plotted with its standard deviation band in the plots directory.
+
+    Args:
+        average (dict): averaged responses, as returned by average_responses
+        output_dir (Path): directory in which to write the averaged responses
+        plot (bool): whether to plot the averaged traces
+        file_format (str): format of the plots ('png' or 'svg')
+    """
+    output_dir.mkdir(parents=True, exist_ok=True)
+    scalars = {}
+    for key, response in average.items():
+        if "time" not in response:
+            scalars[key] = response
+            continue
+        np.savetxt(
+            output_dir / f"{key}.dat",
+            np.transpose([response["time"], response["mean"], response["std"]]),
+            header="time mean std",
+        )
+        if plot:
+            (output_dir / "plots").mkdir(exist_ok=True)
+            fig = plot_trial_average(key, response)
+            plot_path = output_dir / "plots" / f"{key}.{file_format}"
+            fig.savefig(plot_path, format=file_format)
+
+    with open(output_dir / "scalars.json", "w", encoding="utf-8") as scalars_file:
+        json.dump(scalars, scalars_file, indent=4, cls=NpEncoder)
+
+
+def run_trials(
+    config_path,
+    n_trials,
+    output_dir,
+    seed=None,
+    n_processes=None,
+    config_overrides=None,
+    dt=DEFAULT_AVERAGE_DT,
+    plot=True,
+):
+    """Run trials of a stochastic config in parallel, and average their responses.
+
+    Each trial is a run of the config with its own global seed, in its own process,
+    so that the noise stimuli, the spike trains of the synapses
+    and the stochastic channels differ between trials. The outputs of each trial
+    are written in its own directory, e.g. output_dir/trial_0000, and the traces
+    of all the trials, resampled with the time step dt, are averaged
+    in output_dir/average. The index of the trials is written in output_dir/trials.json.
+
+    Args:
+        config_path (str): path to the config
+        n_trials (int): number of trials
+        output_dir (str or Path): directory in which to write the outputs of the trials
+        seed (int): seed from which the seeds of the trials are derived.
+            The global seed of the config, or 0, is used if None.
+        n_processes (int): number of trials in parallel. The number of CPUs if None.
+        config_overrides (list of str): values overriding the ones of the config,
+            given as 'section.key=value'
+        dt (float): step of the time grid of the averaged traces (ms)
+        plot (bool): whether to plot the averaged traces
+
+    Raises:
+        ValueError: if the config is a synplas config, or if n_trials is not positive
+        RuntimeError: if all the trials failed
+
+    Returns:
+        dict: averaged responses, see average_responses
+    """
+    # pylint: disable=too-many-arguments, too-many-locals
+    config = load_config(config_path, config_overrides)
+    if config.package_type == PackageType.synplas:
+        raise ValueError("Only sscx, thalamus and hippocampus configs can be trialled.")
+    if n_trials < 1:
+        raise ValueError(f"The number of trials has to be positive, not {n_trials}.")
+    if seed is None:
+        seed = get_global_seed(config) or 0
+
+    output_dir = Path(output_dir)
+    trials = []
+    for trial_id, trial_seed in enumerate(get_trial_seeds(seed, n_trials)):
+        trial_dir = output_dir / f"trial_{trial_id:04d}"
+        trial_dir.mkdir(parents=True, exist_ok=True)
+        trials.append(
+            {
+                "trial_id": trial_id,
+                "trial_dir": str(trial_dir),
+                "seed": trial_seed,
+                "config_overrides": (config_overrides or [])
+                + [f"Paths.output_dir={trial_dir}", f"Sim.seed={trial_seed}"],
+            }
+        )
+
+    logger.info("Running %d trials.", n_trials)
+    # a new process for each trial, so that no NEURON state is shared between trials
+    with multiprocessing.Pool(processes=n_processes, maxtasksperchild=1) as pool:
+        results = pool.map(
+            run_trial,
+            [(config_path, trial["config_overrides"], dt) for trial in trials],
+            chunksize=1,
+        )
+
+    for trial, result in zip(trials, results):
+        trial["status"] = result["status"]
+        trial["error"] = result["error"]
+    with open(output_dir / "trials.json", "w", encoding="utf-8") as index_file:
+        json.dump(
+            {
+                "config_path": str(config_path),
+                "config_overrides": config_overrides or [],
+                "seed": seed,
+                "dt": dt,
+                "trials": trials,
+            },
+            index_file,
+            indent=4,
+        )
+
+    trial_responses = [
+        result["responses"] for result in results if result["status"] == "done"
+    ]
+    if not trial_responses:
+        raise RuntimeError(f"All the {n_trials} trials failed.")
+    if len(trial_responses) < n_trials:
+        logger.warning(
+            "%d of %d trials failed.", n_trials - len(trial_responses), n_trials
+        )
+
+    average = average_responses(trial_responses)
+    write_average(average, output_dir / "average", plot=plot)
+    return average
@@ -0,0 +1,220 @@
+"""Optional unit-aware quantities, using pint."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import functools
+
+import numpy as np
+
+# units used by emodelrunner and NEURON for each kind of value
+UNITS = {
+    "time": "ms",
+    "voltage": "mV",
+    "current": "nA",
+    "potential": "mV",
+    "positions": "um",
+    "conductivity": "S/m",
+}
+
+# units of the scalar responses, matched on the response name without its prefix
+SCALAR_RESPONSE_UNITS = {
+    "bpo_holding_current": "nA",
+    "bpo_holding_current_dep": "nA",
+    "bpo_holding_current_hyp": "nA",
+    "bpo_threshold_current": "nA",
+    "bpo_threshold_current_dep": "nA",
+    "bpo_threshold_current_hyp": "nA",
+}
+
+# NEURON units of the recorded range variables, besides the ion variables
+RECORDED_VARIABLE_UNITS = {
+    "v": "mV",
+    "i": "nA",
+    "g": "uS",
+    "ihcn": "mA/cm**2",
+}
+# ions whose concentrations (e.g. cai), currents (ica)
+# and reversal potentials (eca) have NEURON units
+IONS = ["ca", "na", "k", "cl"]
+
+# nonspecific currents of the density mechanisms, e.g. i_pas, ihcn_Ih or il_hh
+NONSPECIFIC_CURRENTS = ["i", "ihcn", "il"]
+
+
+def get_variable_unit(variable):
+    """Return the NEURON unit of a recorded range variable.
+
+    Args:
+        variable (str): NEURON name of the variable, e.g. 'v', 'cai' or 'm_NaTg'
+
+    Returns:
+        str: unit of the variable, or None if unknown, e.g. for mechanism variables
+    """
+    if variable in RECORDED_VARIABLE_UNITS:
+        return RECORDED_VARIABLE_UNITS[variable]
+    for ion in IONS:
+        if variable in [f"{ion}i", f"{ion}o"]:
+            return "mM"
+        if variable == f"i{ion}":
+            return "mA/cm**2"
+        if variable == f"e{ion}":
+            return "mV"
+    # the currents of the density mechanisms, e.g. ina_NaTg, i_pas or i_cap
+    current, _, mechanism = variable.partition("_")
+    if mechanism and (
+        current in NONSPECIFIC_CURRENTS or current in [f"i{ion}" for ion in IONS]
+    ):
+        return "mA/cm**2"
+    return None
+
+
+def get_response_units(name):
+    """Return the units of the values of a response.
+
+    The values of the recordings 'prefix.protocol.location.variable' are stored
+    as 'voltage' whatever the variable, and have the unit of the variable.
+
+    Args:
+        name (str): name of the response
+
+    Returns:
+        dict: unit of each kind of value, None if unknown
+    """
+    units = dict(UNITS)
+    items = name.split(".")
+    if len(items) >= 4 and not name.startswith("current_"):
+        units["voltage"] = get_variable_unit(items[-1])
+    return units
+
+
+@functools.lru_cache(maxsize=None)
+def get_unit_registry():
+    """Return the pint unit registry shared by all the quantities of emodelrunner.
+
+    Quantities from different registries cannot be combined,
+    so the same registry is always returned.
+
+    Returns:
+        pint.UnitRegistry: the unit registry
+    """
+    # pylint: disable=import-outside-toplevel
+    import pint
+
+    return pint.UnitRegistry()
+
+
+def is_quantity(value):
+    """Return True if the value is a pint quantity.
+
+    Does not need pint to be installed.
+
+    Args:
+        value: any value
+
+    Returns:
+        bool: whether the value has a magnitude and units
+    """
+    return hasattr(value, "magnitude") and hasattr(value, "units")
+
+
+def quantity(value, unit):
+    """Attach a unit to a value.
+
+    Args:
+        value (float or numpy.ndarray): value, in the given unit
+        unit (str): unit of the value, e.g. "mV"
+
+    Returns:
+        pint.Quantity: the value with its unit
+    """
+    return get_unit_registry().Quantity(value, unit)
+
+
+def to_magnitude(value, unit):
+    """Return the magnitude of a value in the given unit.
+
+    Plain numbers are assumed to already be in the given unit and are returned as is,
+    so that the functions accepting quantities also accept plain numbers.
+
+    Args:
+        value (float, numpy.ndarray or pint.Quantity): value to convert
+        unit (str): unit expected by emodelrunner, e.g. "nA"
+
+    Raises:
+        ValueError: if the quantity cannot be converted to the unit, e.g. V to nA
+
+    Returns:
+        float or numpy.ndarray: the magnitude of the value in the given unit
+    """
+    if not is_quantity(value):
+        return value
+
+    # pylint: disable=import-outside-toplevel
+    import pint
+
+    try:
+        return value.to(unit).magnitude
+    except pint.DimensionalityError as exc:
+        raise ValueError(f"Cannot convert {value} to {unit}: {exc}") from exc
+
+
+def response_with_units(name, response):
+    """Attach units to a response.
+
+    Args:
+        name (str): name of the response
+        response: a TimeVoltageResponse, a dict of arrays keyed by kind of value
+            (e.g. {"time": ..., "current": ...}) or a scalar response
+
+    Returns:
+        the response as a dict of quantities, or the scalar response as a quantity.
+        Values with unknown units are returned unchanged, e.g. the values
+        of the mechanism variables.
+    """
+    units = get_response_units(name)
+    # TimeVoltageResponse and the like store their data in a DataFrame
+    if hasattr(response, "response") and hasattr(response.response, "columns"):
+        return {
+            column: (
+                quantity(np.array(response.response[column]), units[column])
+                if units.get(column)
+                else np.array(response.response[column])
+            )
+            for column in response.response.columns
+        }
+    if isinstance(response, dict):
+        return {
+            key: quantity(np.asarray(value), units[key]) if units.get(key) else value
+            for key, value in response.items()
+        }
+    unit = SCALAR_RESPONSE_UNITS.get(name.split(".")[-1])
+    if unit is not None and response is not None:
+        return quantity(response, unit)
+    return response
+
+
+def responses_with_units(responses):
+    """Attach units to responses, e.g. the responses of the protocols or the currents.
+
+    Args:
+        responses (dict): responses keyed by name
+
+    Returns:
+        dict: responses with units, keyed by name. See response_with_units.
+    """
+    return {
+        name: response_with_units(name, response)
+        for name, response in responses.items()
+    }
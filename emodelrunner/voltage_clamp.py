@@ -0,0 +1,167 @@
+"""Activation and inactivation curves of the voltage clamp protocols."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import logging
+
+import numpy as np
+
+logger = logging.getLogger(__name__)
+
+
+def get_peak_current(time, current, start, end):
+    """Return the current of largest amplitude in a time window.
+
+    Args:
+        time (numpy.ndarray): time of the trace (ms)
+        current (numpy.ndarray): clamp current (nA)
+        start (float): start of the window (ms)
+        end (float): end of the window (ms)
+
+    Returns:
+        float: the peak current (nA), with its sign, or None if the window is empty
+    """
+    time = np.asarray(time)
+    current = np.asarray(current)
+    window = (time >= start) & (time < end)
+    if not np.any(window):
+        return None
+    return float(current[window][np.argmax(np.abs(current[window]))])
+
+
+def fit_boltzmann(voltages, values, inactivation=False):
+    """Fit a Boltzmann function to a normalized activation or inactivation curve.
+
+    The activation curve is 1 / (1 + exp((v_half - v) / slope))
+    and the inactivation curve is 1 / (1 + exp((v - v_half) / slope)).
+    The function is linearized, so that only the values strictly between
+    0.01 and 0.99 are fitted.
+
+    Args:
+        voltages (list): command voltages (mV)
+        values (list): normalized conductances or currents
+        inactivation (bool): whether to fit an inactivation curve
+
+    Returns:
+        dict containing the half-activation or half-inactivation voltage 'v_half'
+        (mV), the 'slope' factor (mV) and the number of fitted points.
+        The values are None if less than 2 points can be fitted.
+    """
+    voltages = np.asarray(voltages, dtype=float)
+    values = np.asarray(values, dtype=float)
+    fitted = (values > 0.01) & (values < 0.99)
+
+    fit = {"v_half": None, "slope": None, "n_points": int(np.sum(fitted))}
+    if fit["n_points"] < 2 or np.ptp(voltages[fitted]) == 0:
+        return fit
+
+    # log(1 / y - 1) is linear in v, with a slope of -1 / slope for the activation
+    slope, intercept = np.polyfit(
+        voltages[fitted], np.log(1.0 / values[fitted] - 1.0), 1
+    )
+    if slope == 0:
+        return fit
+    fit["v_half"] = float(-intercept / slope)
+    fit["slope"] = float(1.0 / slope if inactivation else -1.0 / slope)
+    return fit
+
+
+def get_voltage_clamp_curve(responses, vclamp_protocol):
+    """Compute the activation or inactivation curve of a voltage clamp protocol.
+
+    The activation curve is the peak conductance, i.e. the peak current divided
+    by the driving force, versus the command voltage, and the inactivation curve
+    is the peak current versus the command voltage of the prepulse.
+    Both are normalized by their value of largest amplitude.
+
+    Args:
+        responses (dict): responses of the protocols
+        vclamp_protocol (dict): type of curve, reversal potential, window and sweeps
+            of the protocol, see ProtocolBuilder.get_voltage_clamp_protocols
+
+    Returns:
+        dict containing the 'command_voltage' (mV), the 'peak_current' (nA),
+        the 'conductance' (uS, activation only) and the 'normalized' values,
+        sorted by command voltage, and the Boltzmann 'fit' of the normalized values
+    """
+    activation = vclamp_protocol["type"] == "activation"
+    start, end = vclamp_protocol["window"]
+
+    points = []
+    for sweep in vclamp_protocol["sweeps"]:
+        resp = responses.get(sweep["current_key"])
+        if not isinstance(resp, dict) or "voltage" not in resp:
+            continue
+        peak_current = get_peak_current(resp["time"], resp["voltage"], start, end)
+        if peak_current is None:
+            continue
+        value = peak_current
+        if activation:
+            driving_force = sweep["step_voltage"] - vclamp_protocol["reversal"]
+            if driving_force == 0:
+                continue
+            value = peak_current / driving_force
+        points.append((sweep["command_voltage"], peak_current, value))
+
+    points.sort(key=lambda point: point[0])
+    values = np.array([point[2] for point in points], dtype=float)
+    normalized = np.zeros(values.shape)
+    if len(values) > 0 and np.max(np.abs(values)) > 0:
+        normalized = values / values[np.argmax(np.abs(values))]
+
+    curve = {
+        "command_voltage": [point[0] for point in points],
+        "peak_current": [point[1] for point in points],
+    }
+    if activation:
+        curve["conductance"] = values.tolist()
+    curve["normalized"] = normalized.tolist()
+    curve["fit"] = fit_boltzmann(
+        curve["command_voltage"], normalized, inactivation=not activation
+    )
+    return curve
+
+
+def compute_voltage_clamp_curves(responses, vclamp_protocols):
+    """Compute the curve of each analysed voltage clamp protocol.
+
+    Args:
+        responses (dict): responses of the protocols
+        vclamp_protocols (dict): what the analysis needs to know of each protocol,
+            see ProtocolBuilder.get_voltage_clamp_protocols
+
+    Returns:
+        dict: the curve of each voltage clamp protocol name,
+            see get_voltage_clamp_curve
+    """
+    curves = {}
+    for name, vclamp_protocol in vclamp_protocols.items():
+        curves[name] = get_voltage_clamp_curve(responses, vclamp_protocol)
+        fit = curves[name]["fit"]
+        if fit["v_half"] is None:
+            logger.warning(
+                "%s: the %s curve could not be fitted.", name, vclamp_protocol["type"]
+            )
+        else:
+            logger.info(
+                "%s: %s v_half %.4g mV, slope %.4g mV",
+                name,
+                vclamp_protocol["type"],
+                fit["v_half"],
+                fit["slope"],
+            )
+
+    return curves
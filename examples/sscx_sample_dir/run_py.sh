@@ -6,4 +6,4 @@ if [ ! -f "x86_64/special" ]; then
     nrnivmodl mechanisms
 fi
 
-python -m emodelrunner.run --config_path $1
+emodelrunner run --config_path $1
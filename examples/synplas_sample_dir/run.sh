@@ -5,4 +5,4 @@ if [ ! -f "x86_64/special" ]; then
     nrnivmodl mechanisms
 fi
 
-python -m emodelrunner.run_synplas --config_path $1
+emodelrunner run --config_path $1
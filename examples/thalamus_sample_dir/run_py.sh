@@ -3,4 +3,4 @@
 
 ./compile_mechanisms.sh
 
-python -m emodelrunner.run --config_path $1
+emodelrunner run --config_path $1
@@ -45,15 +45,32 @@ setup(
         "numpy",
         "bluepyopt",
         "neurom>=3.1.0",
+        "morphio>=3.3",
         "h5py",
+        "pandas",
         "matplotlib",
         "schema",
         "Pebble>=4.3.10",
         "importlib_metadata; python_version<'3.8'",
     ],
     packages=find_packages(),
+    entry_points={
+        "console_scripts": ["emodelrunner=emodelrunner.cli:main"],
+    },
     python_requires=">=3.7",
-    extras_require={"docs": ["sphinx", "sphinx-bluebrain-theme"]},
+    extras_require={
+        "docs": ["sphinx", "sphinx-bluebrain-theme"],
+        "notebook": ["ipywidgets"],
+        "sonata": ["libsonata"],
+        "lfpy": ["LFPy>=2.2"],
+        "units": ["pint"],
+        "nwb": ["pynwb>=2.0"],
+        "neo": ["neo>=0.10"],
+        "yaml": ["pyyaml"],
+        "mpi": ["mpi4py"],
+        "arbor": ["arbor>=0.9,<0.10"],
+        "bluepyemodel": ["bluepyemodel"],
+    },
     classifiers=[
         "Development Status :: 4 - Beta",
         "Intended Audience :: Education",
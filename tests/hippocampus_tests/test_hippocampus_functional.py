@@ -0,0 +1,94 @@
+"""Functional tests for hippocampus packages."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+from pathlib import Path
+import json
+
+import numpy as np
+
+from emodelrunner.cli import main
+from emodelrunner.run import main as run_emodel
+from tests.utils import cwd
+
+
+example_dir = Path("examples") / "hippocampus_sample_dir"
+config_path = "config/config_steps.ini"
+
+
+class TestHippocampusPackage:
+    """Tests for the run and the factsheets of the CA1 example package."""
+
+    @classmethod
+    def setup_class(cls):
+        """Run the step protocols, then write the factsheets of the IV_-40 protocol."""
+        cls.output_dir = example_dir / "python_recordings"
+        cls.factsheets_dir = example_dir / "factsheets"
+
+        with cwd(example_dir):
+            run_emodel(config_path=config_path)
+            cls.exit_code = main(
+                [
+                    "factsheet",
+                    "--config_path",
+                    config_path,
+                    "--protocol_key",
+                    "IV_-40",
+                    "--output_dir",
+                    "factsheets",
+                ]
+            )
+
+    def test_step_responses(self):
+        """Test that the steps move the voltage away from the initial voltage."""
+        for protocol_name, sign in [("IV_-40", -1), ("Step_200", 1)]:
+            data = np.loadtxt(self.output_dir / f"SP_PC.{protocol_name}.soma.v.dat")
+            current = np.loadtxt(self.output_dir / f"current_SP_PC.{protocol_name}.dat")
+            assert data.shape == current.shape
+
+            before_step = data[data[:, 0] < 100, 1]
+            during_step = data[(data[:, 0] > 200) & (data[:, 0] < 500), 1]
+            assert sign * (np.mean(during_step) - np.mean(before_step)) > 0
+
+    def test_metype_factsheet(self):
+        """Test the anatomy of the hippocampus me-type factsheet."""
+        assert self.exit_code == 0
+        with open(
+            self.factsheets_dir / "me_type_factsheet.json", "r", encoding="utf-8"
+        ) as factsheet_file:
+            anatomy, physiology, morphology = json.load(factsheet_file)[:3]
+
+        names = [feature["name"] for feature in anatomy["values"]]
+        for neurite in ["all", "axon", "apical", "basal"]:
+            assert f"total {neurite} width" in names
+            assert f"number of {neurite} sections" in names
+        assert all(feature["value"] >= 0 for feature in anatomy["values"])
+
+        physiology_names = [feature["name"] for feature in physiology["values"]]
+        assert "input resistance" in physiology_names
+        assert morphology["value"] == "CA1_SP_PC_toy"
+
+    def test_emodel_factsheet(self):
+        """Test that the e-model factsheet has the features and the mechanisms."""
+        with open(
+            self.factsheets_dir / "e_model_factsheet.json", "r", encoding="utf-8"
+        ) as factsheet_file:
+            factsheet = json.load(factsheet_file)
+
+        assert factsheet[0]["name"] == "Experimental features"
+        assert factsheet[1]["name"] == "Channel mechanisms"
+        location_map = factsheet[1]["values"][0]["location_map"]
+        assert "somatic" in location_map
+        assert "apical" in location_map
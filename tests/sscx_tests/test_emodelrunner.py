@@ -18,19 +18,26 @@
 # pylint: disable=wrong-import-order
 # pylint: disable=import-error
 import os
+from pathlib import Path
 import numpy as np
 import subprocess
 
 import pytest
 
 from bluepyopt import ephys
-from emodelrunner.create_hoc import get_hoc, write_hocs, copy_features_hoc
+from emodelrunner.create_hoc import (
+    copy_features_hoc,
+    export_hoc_package,
+    get_hoc,
+    write_hocs,
+)
 from emodelrunner.load import (
     load_config,
     get_hoc_paths_args,
 )
 from emodelrunner.protocols import sscx_protocols
 from emodelrunner.run import main as run_emodel
+from emodelrunner.run import run as run_emodel_from_config
 from tests.utils import compile_mechanisms, cwd
 
 data_dir = os.path.join("tests", "data")
@@ -77,6 +84,74 @@ def test_voltages():
         compare_hoc_and_py(filename, threshold)
 
 
+def test_export_hoc_package(tmp_path):
+    """Test that the hoc package only refers to its own files."""
+    output_dir = tmp_path / "hoc_package"
+
+    with cwd(example_dir):
+        config = load_config(config_path="config/config_synapses_short.ini")
+        export_hoc_package(config, output_dir)
+        n_mod_files = len(list(Path("mechanisms").glob("*.mod")))
+
+        with pytest.raises(FileExistsError):
+            export_hoc_package(config, output_dir)
+
+    morph_path = next((output_dir / "morphology").iterdir())
+    simul_hoc = (output_dir / "createsimulation.hoc").read_text()
+    assert f'"morphology/{morph_path.name}"' in simul_hoc
+    assert 'load_file("synapses/synapses.hoc")' in simul_hoc
+    assert (output_dir / "synapses" / "synapses.tsv").is_file()
+    assert len(list((output_dir / "mechanisms").glob("*.mod"))) == n_mod_files
+    for filename in ["cell.hoc", "run.hoc", "run_hoc.sh", "parameters.json"]:
+        assert (output_dir / filename).is_file()
+
+
+def test_responses_without_output():
+    """Test that the responses returned without writing output are the written ones."""
+    config_path = "config/config_singlestep.ini"
+
+    with cwd(example_dir):
+        run_emodel(config_path=config_path)
+        config = load_config(config_path=config_path)
+        responses = run_emodel_from_config(config, write_output=False)
+
+    py_v = np.loadtxt(
+        os.path.join(example_dir, "python_recordings", "_.Step_150.soma.v.dat")
+    )
+    response = responses["_.Step_150.soma.v"]
+    np.testing.assert_allclose(response["time"], py_v[:, 0])
+    np.testing.assert_allclose(response["voltage"], py_v[:, 1])
+
+
+hook_calls = []
+
+
+def record_hook_call(protocol_name, protocol_responses, output_dir):
+    """Hook keeping the protocols it is called with."""
+    # pylint: disable=unused-argument
+    hook_calls.append(protocol_name)
+
+
+def test_hooks_of_consecutive_runs():
+    """Test that the hooks of a config are not run by the runs of the next configs."""
+    config_path = "config/config_singlestep.ini"
+    hook_calls.clear()
+
+    with cwd(example_dir):
+        config = load_config(config_path=config_path)
+        config.set(
+            "Analysis",
+            "hooks",
+            '["tests.sscx_tests.test_emodelrunner:record_hook_call"]',
+        )
+        run_emodel_from_config(config)
+        assert hook_calls == ["Step_150"]
+
+        # another config, without hooks
+        run_emodel_from_config(load_config(config_path=config_path))
+        assert hook_calls == ["Step_150"]
+
+
 def test_synapses(config_path="config/config_synapses.ini"):
     """Test to compare the output of cell with synapses between our run.py and bglibpy.
 
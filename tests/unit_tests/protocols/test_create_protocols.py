@@ -77,6 +77,8 @@ class TestProtocolBuilder:
                 "RinHoldCurrent",
                 "IDRest",
             }
+            # the recipe protocols have no noise stimulus
+            assert protocols.get_noise_seeds() == {}
 
     def test_using_sscx_protocols_none_cell_exception(self):
         """Test building sscx protocols with a None cell to raise exception."""
@@ -14,14 +14,23 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
+import json
 from pathlib import Path
+from types import SimpleNamespace
+
+import numpy as np
+import pytest
+from bluepyopt import ephys
 
 from emodelrunner.create_cells import create_cell_using_config
 from emodelrunner.load import (
     load_config,
     get_prot_args,
 )
+from emodelrunner.protocols import sscx_protocols
+from emodelrunner.protocols.protocols_func import parse_relative_amplitude
 from emodelrunner.protocols.reader import ProtocolParser
+from emodelrunner.stimuli import Chirp
 from emodelrunner.synapses.create_locations import get_syn_locs
 
 from tests.utils import cwd
@@ -78,3 +87,795 @@ class TestProtocolParser:
 
             assert set(protocols_dict.keys()) == thalamus_recipe_protocol_keys
             assert all(x is not None for x in protocols_dict)
+
+
+def test_read_sampled_current_protocols(tmp_path):
+    """Test the parsing of the sinusoid, chirp and noise protocols."""
+    protocol_definitions = {
+        "Sinusoid_5Hz": {
+            "type": "SinusoidProtocol",
+            "stimuli": {
+                "sinusoid": {
+                    "delay": 100.0,
+                    "duration": 1000.0,
+                    "amp": 0.1,
+                    "frequency": 5.0,
+                    "totduration": 1200.0,
+                },
+                "holding": {
+                    "delay": 0.0,
+                    "amp": -0.05,
+                    "duration": 1200.0,
+                    "totduration": 1200.0,
+                },
+            },
+        },
+        "Noise": {
+            "type": "NoiseProtocol",
+            "stimuli": {
+                "noise": {
+                    "delay": 100.0,
+                    "duration": 1000.0,
+                    "mean": 0.1,
+                    "sigma": 0.05,
+                    "tau": 5.0,
+                    "seed": 7,
+                    "totduration": 1200.0,
+                },
+            },
+        },
+        "ZAP": {
+            "type": "ChirpProtocol",
+            "stimuli": {
+                "chirp": {
+                    "delay": 100.0,
+                    "duration": 10000.0,
+                    "amp": 0.05,
+                    "offset": 0.02,
+                    "freq_start": 0.5,
+                    "freq_end": 20.0,
+                    "totduration": 10200.0,
+                },
+            },
+        },
+    }
+    protocols_path = tmp_path / "protocols.json"
+    with open(protocols_path, "w", encoding="utf-8") as protocols_file:
+        json.dump(protocol_definitions, protocols_file)
+
+    protocols_dict = ProtocolParser().parse_sscx_protocols(
+        protocols_filepath=protocols_path, prefix="L5_TPC"
+    )
+
+    sinusoid = protocols_dict["Sinusoid_5Hz"]
+    assert isinstance(sinusoid, sscx_protocols.SinusoidProtocol)
+    assert sinusoid.stim_start == 100.0
+    assert sinusoid.stim_end == 1100.0
+    currents = sinusoid.generate_current(dt=0.1)
+    current = currents["current_L5_TPC.Sinusoid_5Hz"]["current"]
+    assert current[0] == pytest.approx(-0.05)
+
+    chirp = protocols_dict["ZAP"]
+    assert isinstance(chirp.sampled_stimulus, Chirp)
+    assert chirp.sampled_stimulus.offset == 0.02
+    assert chirp.holding_stimulus is None
+
+    noise = protocols_dict["Noise"]
+    assert isinstance(noise, sscx_protocols.NoiseProtocol)
+    assert noise.seed == 7
+
+
+def test_read_waveform_protocol(tmp_path):
+    """Test the parsing of the waveform protocol from csv and npy files."""
+    np.savetxt(tmp_path / "waveform.csv", [[50.0, 0.1], [60.0, 0.3]], delimiter=",")
+    np.save(tmp_path / "waveform.npy", [100.0, 200.0, 300.0])
+    protocol_definitions = {
+        "ReplayCSV": {
+            "type": "WaveformProtocol",
+            "stimuli": {
+                "waveform": {
+                    "path": str(tmp_path / "waveform.csv"),
+                    "delay": 100.0,
+                    "totduration": 300.0,
+                },
+                "holding": {
+                    "delay": 0.0,
+                    "amp": -0.05,
+                    "duration": 300.0,
+                    "totduration": 300.0,
+                },
+            },
+        },
+        "ReplayNPY": {
+            "type": "WaveformProtocol",
+            "stimuli": {
+                "waveform": {
+                    "path": str(tmp_path / "waveform.npy"),
+                    "delay": 100.0,
+                    "totduration": 300.0,
+                    "dt": 20.0,
+                    "scale": 0.001,
+                },
+            },
+        },
+    }
+    protocols_path = tmp_path / "protocols.json"
+    with open(protocols_path, "w", encoding="utf-8") as protocols_file:
+        json.dump(protocol_definitions, protocols_file)
+
+    protocols_dict = ProtocolParser().parse_sscx_protocols(
+        protocols_filepath=protocols_path, prefix="L5_TPC"
+    )
+
+    replay_csv = protocols_dict["ReplayCSV"]
+    assert isinstance(replay_csv, sscx_protocols.WaveformProtocol)
+    assert replay_csv.stim_start == 100.0
+    assert replay_csv.stim_end == 110.0
+    np.testing.assert_allclose(replay_csv.sampled_stimulus.time, [0.0, 10.0])
+    currents = replay_csv.generate_current(dt=5.0)
+    current = currents["current_L5_TPC.ReplayCSV"]["current"]
+    np.testing.assert_allclose(current[19:23], [-0.05, 0.05, 0.15, -0.05])
+
+    replay_npy = protocols_dict["ReplayNPY"]
+    assert replay_npy.stim_end == 140.0
+    np.testing.assert_allclose(replay_npy.sampled_stimulus.current, [0.1, 0.2, 0.3])
+
+    # a file with only the current needs a time step
+    del protocol_definitions["ReplayNPY"]["stimuli"]["waveform"]["dt"]
+    with open(protocols_path, "w", encoding="utf-8") as protocols_file:
+        json.dump(protocol_definitions, protocols_file)
+    with pytest.raises(ValueError):
+        ProtocolParser().parse_sscx_protocols(protocols_filepath=protocols_path)
+
+
+def test_read_conductance_clamp_protocol(tmp_path):
+    """Test the parsing of the conductance clamp protocol."""
+    np.savetxt(tmp_path / "inh.dat", [0.02, 0.03])
+    timing = {"delay": 100.0, "duration": 1000.0, "totduration": 1200.0}
+    protocol_definitions = {
+        "Bombardment": {
+            "type": "ConductanceClampProtocol",
+            "stimuli": {
+                "conductance": {
+                    **timing,
+                    "exc": {
+                        "reversal": 0.0,
+                        "mean": 0.01,
+                        "sigma": 0.003,
+                        "tau": 2.7,
+                        "seed": 3,
+                    },
+                    "inh": {
+                        "reversal": -80.0,
+                        "path": str(tmp_path / "inh.dat"),
+                        "dt": 500.0,
+                    },
+                },
+            },
+        },
+    }
+    protocols_path = tmp_path / "protocols.json"
+    with open(protocols_path, "w", encoding="utf-8") as protocols_file:
+        json.dump(protocol_definitions, protocols_file)
+
+    protocols_dict = ProtocolParser().parse_sscx_protocols(
+        protocols_filepath=protocols_path, prefix="L5_TPC"
+    )
+
+    protocol = protocols_dict["Bombardment"]
+    assert isinstance(protocol, sscx_protocols.ConductanceClampProtocol)
+    assert protocol.stim_start == 100.0
+    assert protocol.stim_end == 1100.0
+    assert protocol.seeds == {"exc": 3}
+    assert protocol.holding_stimulus is None
+    inhibition = protocol.conductance_stimuli["inh"]
+    assert inhibition.reversal_potential == -80.0
+    np.testing.assert_allclose(inhibition.conductance.values, [0.02, 0.03])
+
+    # a conductance needs values or Ornstein-Uhlenbeck parameters
+    protocol_definitions["Bombardment"]["stimuli"]["conductance"]["inh"] = {
+        "reversal": -80.0,
+        "mean": 0.02,
+    }
+    with open(protocols_path, "w", encoding="utf-8") as protocols_file:
+        json.dump(protocol_definitions, protocols_file)
+    with pytest.raises(ValueError):
+        ProtocolParser().parse_sscx_protocols(protocols_filepath=protocols_path)
+
+    # at least one conductance is needed
+    protocol_definitions["Bombardment"]["stimuli"]["conductance"] = timing
+    with open(protocols_path, "w", encoding="utf-8") as protocols_file:
+        json.dump(protocol_definitions, protocols_file)
+    with pytest.raises(ValueError):
+        ProtocolParser().parse_sscx_protocols(protocols_filepath=protocols_path)
+
+
+def test_read_voltage_clamp_protocol():
+    """Test the parsing of the voltage clamp sweeps and of their analysis."""
+    protocol_definitions = {
+        "NaInactivation": {
+            "type": "VoltageClampProtocol",
+            "stimuli": {
+                "vclamp": {
+                    "steps": [
+                        {"voltage": -100.0, "duration": 100.0},
+                        {"voltage": [-90.0, -60.0, -30.0], "duration": 50.0},
+                        {"voltage": -10.0, "duration": 20.0},
+                    ],
+                    "series_resistance": 0.1,
+                }
+            },
+            "analysis": {"type": "inactivation"},
+        },
+    }
+    protocols_dict = ProtocolParser().parse_sscx_protocol_definitions(
+        protocol_definitions, prefix="L5_TPC"
+    )
+
+    protocol = protocols_dict["NaInactivation"]
+    assert isinstance(protocol, sscx_protocols.VoltageClampProtocol)
+    assert protocol.command_voltages == [-90.0, -60.0, -30.0]
+    assert protocol.analysis == {
+        "type": "inactivation",
+        "step": 2,
+        "reversal": None,
+        "transient": 0.5,
+    }
+    sweep = protocol.sweep_protocols[1]
+    assert sweep.name == "NaInactivation_1"
+    assert sweep.vclamp_stimulus.voltages == [-100.0, -60.0, -10.0]
+    assert sweep.vclamp_stimulus.series_resistance == 0.1
+    assert [recording.name for recording in sweep.recordings] == [
+        "L5_TPC.NaInactivation_1.soma.v",
+        "L5_TPC.NaInactivation_1.soma.i",
+    ]
+    assert protocol.generate_current() == {}
+
+    # the activation curve needs the reversal potential
+    protocol_definitions["NaInactivation"]["analysis"] = {"type": "activation"}
+    with pytest.raises(ValueError):
+        ProtocolParser().parse_sscx_protocol_definitions(protocol_definitions)
+
+    # a single step can change between sweeps
+    steps = protocol_definitions["NaInactivation"]["stimuli"]["vclamp"]["steps"]
+    steps[2]["voltage"] = {"start": -10.0, "stop": 10.0, "step": 10.0}
+    del protocol_definitions["NaInactivation"]["analysis"]
+    with pytest.raises(ValueError):
+        ProtocolParser().parse_sscx_protocol_definitions(protocol_definitions)
+
+
+def test_read_current_search_protocol(tmp_path):
+    """Test the parsing of the current search and threshold-based protocols."""
+    protocol_definitions = {
+        "Step_150": {
+            "type": "StepThresholdProtocol",
+            "stimuli": {
+                "step": {
+                    "delay": 700.0,
+                    "thresh_perc": 150.0,
+                    "duration": 2000.0,
+                    "totduration": 3000.0,
+                },
+                "holding": {
+                    "delay": 0.0,
+                    "amp": None,
+                    "duration": 3000.0,
+                    "totduration": 3000.0,
+                },
+            },
+        },
+        "Search": {
+            "type": "CurrentSearchProtocol",
+            "holding_voltage": -83.0,
+            "threshold_precision": 0.005,
+            "stimuli": {
+                "step": {"delay": 700.0, "duration": 2000.0, "totduration": 3000.0},
+            },
+        },
+    }
+    protocols_path = tmp_path / "protocols.json"
+    with open(protocols_path, "w", encoding="utf-8") as protocols_file:
+        json.dump(protocol_definitions, protocols_file)
+
+    # the threshold-based protocols are allowed without Main protocol
+    protocols_dict = ProtocolParser().parse_sscx_protocols(
+        protocols_filepath=protocols_path, prefix="L5_TPC"
+    )
+
+    search = protocols_dict["Search"]
+    assert isinstance(search, sscx_protocols.CurrentSearchProtocol)
+    assert search.holding_voltage == -83.0
+    assert search.threshold_precision == 0.005
+    assert search.holding_precision == 0.1
+    assert search.prefix == "L5_TPC."
+    assert search.step_protocol_template.stim_start == 700.0
+    assert search.step_protocol_template.stim_end == 2700.0
+    assert search.generate_current() == {}
+
+
+def test_read_fi_curve_protocol():
+    """Test the parsing of the f-I curve protocol into one step per amplitude."""
+    protocol_definitions = {
+        "FICurve": {
+            "type": "FICurveProtocol",
+            "amplitudes": {"start": 0.0, "stop": 0.3, "step": 0.1},
+            "spike_threshold": -30.0,
+            "stimuli": {
+                "step": {"delay": 700.0, "duration": 2000.0, "totduration": 3000.0},
+                "holding": {
+                    "delay": 0.0,
+                    "amp": -0.05,
+                    "duration": 3000.0,
+                    "totduration": 3000.0,
+                },
+            },
+        },
+    }
+
+    protocols_dict = ProtocolParser().parse_sscx_protocol_definitions(
+        protocol_definitions, prefix="L5_TPC"
+    )
+    fi_curve = protocols_dict["FICurve"]
+    assert isinstance(fi_curve, sscx_protocols.FICurveProtocol)
+    assert fi_curve.spike_threshold == -30.0
+    assert fi_curve.step_amplitudes == [0.0, 0.1, 0.2, 0.3]
+    assert list(fi_curve.subprotocols()) == [
+        "FICurve",
+        "FICurve_0",
+        "FICurve_1",
+        "FICurve_2",
+        "FICurve_3",
+    ]
+
+    step = fi_curve.step_protocols[2]
+    assert step.recordings[0].name == "L5_TPC.FICurve_2.soma.v"
+    assert step.holding_stimulus.step_amplitude == -0.05
+    assert (step.stim_start, step.stim_end) == (700.0, 2700.0)
+
+    currents = fi_curve.generate_current(dt=0.1)
+    assert len(currents) == 4
+    current = currents["current_L5_TPC.FICurve_2"]["current"]
+    assert current[8000] == pytest.approx(0.15)
+
+    # relative amplitudes need a stored or searched threshold current
+    protocol_definitions["FICurve"]["amplitudes"] = ["100%thresh", "200%thresh"]
+    with pytest.raises(Exception):
+        ProtocolParser().parse_sscx_protocol_definitions(
+            protocol_definitions, prefix="L5_TPC"
+        )
+    protocols_dict = ProtocolParser().parse_sscx_protocol_definitions(
+        protocol_definitions, prefix="L5_TPC", threshold_current=0.2
+    )
+    assert protocols_dict["FICurve"].step_amplitudes == pytest.approx([0.2, 0.4])
+
+
+def test_read_subthreshold_protocol():
+    """Test the parsing of the subthreshold protocol into one step per amplitude."""
+    protocol_definitions = {
+        "Subthreshold": {
+            "type": "SubthresholdProtocol",
+            "amplitudes": [-0.1, -0.05],
+            "stimuli": {
+                "step": {"delay": 700.0, "duration": 1000.0, "totduration": 2000.0},
+            },
+        },
+    }
+
+    protocols_dict = ProtocolParser().parse_sscx_protocol_definitions(
+        protocol_definitions, prefix="L5_TPC"
+    )
+    subthreshold = protocols_dict["Subthreshold"]
+    assert isinstance(subthreshold, sscx_protocols.SubthresholdProtocol)
+    assert subthreshold.spike_threshold == -20.0
+    assert subthreshold.step_amplitudes == [-0.1, -0.05]
+    assert subthreshold.step_protocols[1].name == "Subthreshold_1"
+    assert subthreshold.step_protocols[1].holding_stimulus is None
+
+
+def test_read_attenuation_protocols():
+    """Test the parsing of the bAP and EPSP attenuation protocols."""
+    protocol_definitions = {
+        "bAP": {
+            "type": "BAPProtocol",
+            "distances": [100, 200.5],
+            "stimuli": {
+                "step": {
+                    "amp": 2.0,
+                    "delay": 700.0,
+                    "duration": 5.0,
+                    "totduration": 1000.0,
+                },
+            },
+        },
+        "EPSP": {
+            "type": "EPSPAttenuationProtocol",
+            "distances": [50, 150],
+            "seclist_name": "basal",
+            "stimuli": {
+                "epsp": {
+                    "amp": 0.05,
+                    "tau_rise": 0.5,
+                    "tau_decay": 5.0,
+                    "delay": 700.0,
+                    "duration": 100.0,
+                    "totduration": 1000.0,
+                },
+            },
+        },
+    }
+
+    protocols_dict = ProtocolParser().parse_sscx_protocol_definitions(
+        protocol_definitions, prefix="L5_TPC", apical_point_isec=12
+    )
+    bap = protocols_dict["bAP"]
+    assert isinstance(bap, sscx_protocols.BAPProtocol)
+    assert [recording.name for recording in bap.recordings] == [
+        "L5_TPC.bAP.soma.v",
+        "L5_TPC.bAP.apical100um.v",
+        "L5_TPC.bAP.apical200p5um.v",
+    ]
+    assert bap.recording_distances == {
+        "L5_TPC.bAP.apical100um.v": 100,
+        "L5_TPC.bAP.apical200p5um.v": 200.5,
+    }
+    # on the apical trunk, since the apical point is known
+    location = bap.recordings[1].location
+    assert isinstance(location, ephys.locations.NrnSecSomaDistanceCompLocation)
+    assert location.sec_index == 12
+    assert bap.step_stimuli[0].step_amplitude == 2.0
+
+    epsp = protocols_dict["EPSP"]
+    assert isinstance(epsp, sscx_protocols.EPSPAttenuationProtocol)
+    assert epsp.distances == [50, 150]
+    assert list(epsp.subprotocols()) == ["EPSP", "EPSP_0", "EPSP_1"]
+    site = epsp.site_protocols[1]
+    assert [recording.name for recording in site.recordings] == [
+        "L5_TPC.EPSP_1.soma.v",
+        "L5_TPC.EPSP_1.basal150um.v",
+    ]
+    assert isinstance(
+        site.sampled_stimulus.location, ephys.locations.NrnSomaDistanceCompLocation
+    )
+    assert site.sampled_stimulus.location.seclist_name == "basal"
+    assert site.stim_start == 700.0
+    currents = epsp.generate_current(dt=0.025)
+    assert list(currents) == ["current_L5_TPC.EPSP_0", "current_L5_TPC.EPSP_1"]
+    assert currents["current_L5_TPC.EPSP_1"]["current"].max() == pytest.approx(
+        0.05, rel=1e-3
+    )
+
+    # the bAP step cannot be relative to the threshold current
+    protocol_definitions["bAP"]["stimuli"]["step"]["amp"] = "200%thresh"
+    with pytest.raises(ValueError):
+        ProtocolParser().parse_sscx_protocol_definitions(
+            protocol_definitions, prefix="L5_TPC", threshold_current=0.2
+        )
+
+
+def test_parse_relative_amplitude():
+    """Test the parsing of the amplitudes relative to the threshold current."""
+    assert parse_relative_amplitude(0.2) is None
+    assert parse_relative_amplitude(None) is None
+    assert parse_relative_amplitude("150%thresh") == 150.0
+    assert parse_relative_amplitude(" -20.5 % threshold") == -20.5
+
+    with pytest.raises(ValueError):
+        parse_relative_amplitude("150%")
+
+
+def test_read_relative_step_protocol():
+    """Test the parsing of the step protocols with relative amplitudes."""
+    protocol_definitions = {
+        "Step_150": {
+            "type": "StepProtocol",
+            "stimuli": {
+                "step": [
+                    {
+                        "delay": 700.0,
+                        "amp": "150%thresh",
+                        "duration": 1000.0,
+                        "totduration": 3000.0,
+                    },
+                    {
+                        "delay": 1700.0,
+                        "amp": 0.1,
+                        "duration": 1000.0,
+                        "totduration": 3000.0,
+                    },
+                ],
+                "holding": {
+                    "delay": 0.0,
+                    "amp": -0.05,
+                    "duration": 3000.0,
+                    "totduration": 3000.0,
+                },
+            },
+        },
+    }
+
+    protocols_dict = ProtocolParser().parse_sscx_protocol_definitions(
+        protocol_definitions, prefix="L5_TPC", threshold_current=0.2
+    )
+    step = protocols_dict["Step_150"]
+    assert isinstance(step, sscx_protocols.RelativeStepProtocol)
+    assert step.thresh_percs == [150.0, None]
+    assert step.step_stimuli[0].step_amplitude == pytest.approx(0.3)
+    assert step.step_stimuli[1].step_amplitude == 0.1
+    assert step.holding_stimulus.step_amplitude == -0.05
+
+    # without stored threshold current, a current search is needed
+    with pytest.raises(Exception):
+        ProtocolParser().parse_sscx_protocol_definitions(
+            protocol_definitions, prefix="L5_TPC"
+        )
+
+    protocol_definitions["Search"] = {
+        "type": "CurrentSearchProtocol",
+        "stimuli": {
+            "step": {"delay": 700.0, "duration": 2000.0, "totduration": 3000.0},
+        },
+    }
+    protocols_dict = ProtocolParser().parse_sscx_protocol_definitions(
+        protocol_definitions, prefix="L5_TPC"
+    )
+    step = protocols_dict["Step_150"]
+    assert step.threshold_current is None
+    assert step.step_amplitude is None
+
+    currents = step.generate_current(threshold_current=0.2, dt=0.1)
+    current = currents["current_L5_TPC.Step_150"]["current"]
+    assert current[8000] == pytest.approx(0.25)
+    assert current[20000] == pytest.approx(0.05)
+
+
+def test_read_protocol_globals():
+    """Test the temperature and the initial voltage set per protocol."""
+    protocol_definitions = {
+        "Step_30C": {
+            "type": "StepProtocol",
+            "celsius": 30,
+            "v_init": -70.0,
+            "stimuli": {
+                "step": {
+                    "delay": 700.0,
+                    "amp": 0.3,
+                    "duration": 1000.0,
+                    "totduration": 3000.0,
+                },
+            },
+        },
+        "FICurve": {
+            "type": "FICurveProtocol",
+            "amplitudes": [0.1, 0.2],
+            "celsius": 30.0,
+            "stimuli": {
+                "step": {"delay": 700.0, "duration": 2000.0, "totduration": 3000.0},
+            },
+        },
+    }
+
+    protocols_dict = ProtocolParser().parse_sscx_protocol_definitions(
+        protocol_definitions, prefix="L5_TPC"
+    )
+    step = protocols_dict["Step_30C"]
+    assert step.get_neuron_globals() == {"celsius": 30.0, "v_init": -70.0}
+    for step in protocols_dict["FICurve"].step_protocols:
+        assert step.get_neuron_globals() == {"celsius": 30.0}
+
+    # the values of the cell are set back when the protocol is destroyed
+    sim = SimpleNamespace(neuron=SimpleNamespace(h=SimpleNamespace()))
+    sim.neuron.h.celsius = 34.0
+    sim.neuron.h.v_init = -80.0
+    step = protocols_dict["Step_30C"]
+    step.set_neuron_globals(sim)
+    assert (sim.neuron.h.celsius, sim.neuron.h.v_init) == (30.0, -70.0)
+    step.reset_neuron_globals(sim)
+    assert (sim.neuron.h.celsius, sim.neuron.h.v_init) == (34.0, -80.0)
+
+    # the protocols without type cannot have their own temperature
+    protocol_definitions = {
+        "Step": {
+            "celsius": 30.0,
+            "stimuli": [
+                {"delay": 700.0, "amp": 0.3, "duration": 1000.0, "totduration": 3000.0}
+            ],
+        },
+    }
+    with pytest.raises(ValueError):
+        ProtocolParser().parse_sscx_protocol_definitions(
+            protocol_definitions, prefix="L5_TPC"
+        )
+
+
+def test_read_recording_sampling():
+    """Test the sampling of the recordings set in the protocols."""
+    step_stimuli = {
+        "step": {"delay": 700.0, "amp": 0.3, "duration": 1000.0, "totduration": 3000.0}
+    }
+    protocol_definitions = {
+        "Step_default": {"type": "StepProtocol", "stimuli": step_stimuli},
+        "Step_sampled": {
+            "type": "StepProtocol",
+            "record_every": 40,
+            "stimuli": step_stimuli,
+            "extra_recordings": [
+                {
+                    "type": "section",
+                    "name": "dend3",
+                    "sec_name": "dend",
+                    "sec_index": 3,
+                    "comp_x": 0.5,
+                    "var": "v",
+                },
+                {
+                    "type": "section",
+                    "name": "dend4",
+                    "sec_name": "dend",
+                    "sec_index": 4,
+                    "comp_x": 0.5,
+                    "var": "cai",
+                    "record_dt": 0.5,
+                },
+            ],
+        },
+    }
+
+    protocols_dict = ProtocolParser().parse_sscx_protocol_definitions(
+        protocol_definitions, prefix="L5_TPC"
+    )
+    soma_recording = protocols_dict["Step_default"].recordings[0]
+    assert (soma_recording.record_dt, soma_recording.record_every) == (0.1, None)
+    # the sampling of the protocol is the default one of its extra recordings
+    soma, dend3, dend4 = protocols_dict["Step_sampled"].recordings
+    assert (soma.record_dt, soma.record_every) == (None, 40)
+    assert (dend3.record_dt, dend3.record_every) == (None, 40)
+    assert (dend4.record_dt, dend4.record_every) == (0.5, None)
+
+    protocol_definitions["Step_sampled"]["record_dt"] = 1.0
+    with pytest.raises(ValueError):
+        ProtocolParser().parse_sscx_protocol_definitions(
+            protocol_definitions, prefix="L5_TPC"
+        )
+
+
+def test_read_conditioned_synapse_protocol(monkeypatch):
+    """Test the holding current and the conditioning of the synapse protocols."""
+    protocol_definitions = {
+        "Synapses_Netstim": {
+            "type": "Netstim",
+            "stimuli": {
+                "syn_start": 200.0,
+                "syn_stop": 500.0,
+                "syn_nmb_of_spikes": 5,
+                "syn_interval": 10.0,
+                "syn_noise": 0,
+                "holding_current": -0.05,
+                "conditioning": {"type": "current", "amp": 0.1, "duration": 150.0},
+            },
+        },
+    }
+    protocols_dict = ProtocolParser().parse_sscx_protocol_definitions(
+        protocol_definitions, prefix="L5_TPC"
+    )
+    protocol = protocols_dict["Synapses_Netstim"]
+    assert isinstance(protocol, sscx_protocols.ConditionedSynapseProtocol)
+    assert protocol.total_duration == 500.0
+    assert protocol.conditioning_duration == 150.0
+    assert not protocol.exclude_conditioning
+
+    currents = protocol.generate_current(dt=1.0)
+    current = currents["current_L5_TPC.Synapses_Netstim"]["current"]
+    np.testing.assert_allclose(current[:150], 0.05)
+    np.testing.assert_allclose(current[150:], -0.05)
+
+    # the excluded conditioning is removed from the traces and currents
+    stimuli = protocol_definitions["Synapses_Netstim"]["stimuli"]
+    stimuli["conditioning"] = {
+        "type": "voltage",
+        "voltage": -70.0,
+        "duration": 150.0,
+        "exclude": True,
+    }
+    protocol = ProtocolParser().parse_sscx_protocol_definitions(
+        protocol_definitions, prefix="L5_TPC"
+    )["Synapses_Netstim"]
+    assert protocol.conditioning_stimulus.voltages == [-70.0]
+    currents = protocol.generate_current(dt=1.0)
+    time = currents["current_L5_TPC.Synapses_Netstim"]["time"]
+    assert time[0] == 150.0
+
+    response = ephys.responses.TimeVoltageResponse(
+        "L5_TPC.Synapses_Netstim.soma.v",
+        np.arange(0.0, 500.0, 1.0),
+        np.zeros(500),
+    )
+    monkeypatch.setattr(
+        ephys.protocols.SweepProtocol,
+        "run",
+        lambda *args, **kwargs: {response.name: response},
+    )
+    responses = protocol.run(None, {})
+    assert responses[response.name]["time"].iloc[0] == 150.0
+    assert len(responses[response.name]["time"]) == 350
+
+    # the conditioning ends before the synapses are activated
+    stimuli["conditioning"]["duration"] = 250.0
+    with pytest.raises(ValueError):
+        ProtocolParser().parse_sscx_protocol_definitions(protocol_definitions)
+    stimuli["conditioning"] = {"type": "ramp", "duration": 100.0}
+    with pytest.raises(ValueError):
+        ProtocolParser().parse_sscx_protocol_definitions(protocol_definitions)
+
+    # without holding current nor conditioning, the protocol is unchanged
+    del stimuli["conditioning"], stimuli["holding_current"]
+    protocol = ProtocolParser().parse_sscx_protocol_definitions(
+        protocol_definitions
+    )["Synapses_Netstim"]
+    assert type(protocol) is sscx_protocols.SweepProtocolCustom
+
+
+def test_read_chain_protocol():
+    """Test the parsing of the protocols chained on the same cell."""
+    protocol_definitions = {
+        "Plasticity": {
+            "type": "ChainProtocol",
+            "celsius": 30.0,
+            "phases": {
+                "Induction": {
+                    "type": "StepProtocol",
+                    "stimuli": {
+                        "step": {
+                            "delay": 100.0,
+                            "amp": 0.5,
+                            "duration": 200.0,
+                            "totduration": 400.0,
+                        },
+                    },
+                },
+                "Test": {
+                    "type": "StepProtocol",
+                    "stimuli": {
+                        "step": {
+                            "delay": 50.0,
+                            "amp": 0.1,
+                            "duration": 100.0,
+                            "totduration": 200.0,
+                        },
+                    },
+                },
+            },
+        },
+    }
+    protocols_dict = ProtocolParser().parse_sscx_protocol_definitions(
+        protocol_definitions, prefix="L5_TPC"
+    )
+    protocol = protocols_dict["Plasticity"]
+    assert isinstance(protocol, sscx_protocols.ChainProtocol)
+    assert list(protocol.subprotocols()) == [
+        "Plasticity",
+        "Plasticity_Induction",
+        "Plasticity_Test",
+    ]
+    assert protocol.total_duration == 600.0
+    induction, test = protocol.phase_protocols
+    assert induction.recordings[0].name == "L5_TPC.Plasticity_Induction.soma.v"
+    assert test.get_neuron_globals() == {"celsius": 30.0}
+    assert set(protocol.generate_current()) == {
+        "current_L5_TPC.Plasticity_Induction",
+        "current_L5_TPC.Plasticity_Test",
+    }
+
+    # the phases are single sweeps
+    protocol_definitions["Plasticity"]["phases"]["Test"] = {
+        "type": "FICurveProtocol",
+        "amplitudes": [0.1, 0.2],
+        "stimuli": {
+            "step": {"delay": 700.0, "duration": 2000.0, "totduration": 3000.0},
+        },
+    }
+    with pytest.raises(ValueError):
+        ProtocolParser().parse_sscx_protocol_definitions(protocol_definitions)
+
+    protocol_definitions["Plasticity"]["phases"] = {}
+    with pytest.raises(ValueError):
+        ProtocolParser().parse_sscx_protocol_definitions(protocol_definitions)
@@ -0,0 +1,152 @@
+"""Unit tests for the protocols.sscx_protocols module."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+from types import SimpleNamespace
+
+import pytest
+
+from emodelrunner.protocols import sscx_protocols
+from emodelrunner.protocols.sscx_protocols import ChainProtocol, CurrentSearchProtocol
+
+
+class FakeCell:
+    """Cell model whose currents are set by the search."""
+
+    holding_current = None
+    threshold_current = None
+
+
+def create_search_protocol(rheobase, **kwargs):
+    """Return a search protocol of a linear cell spiking above the rheobase.
+
+    The voltage is -80 mV without current, and increases by 20 mV per nA.
+    """
+    protocol = CurrentSearchProtocol("Search", prefix="L5_TPC", **kwargs)
+    protocol.voltage_base = lambda current, *args: -80.0 + 20.0 * current
+    protocol.detect_spike = lambda step_current, *args: step_current >= rheobase
+    return protocol
+
+
+def test_current_search():
+    """Test the bisection search of the holding current and of the rheobase."""
+    protocol = create_search_protocol(
+        0.37, holding_voltage=-85.0, threshold_precision=0.001, max_depth=20
+    )
+    cell = FakeCell()
+    responses = protocol.run(cell, {})
+
+    assert cell.holding_current == pytest.approx(-0.25, abs=0.1 / 20)
+    assert cell.threshold_current == pytest.approx(0.37, abs=0.001)
+    assert cell.threshold_current >= 0.37
+    assert responses == {
+        "L5_TPC.bpo_holding_current": cell.holding_current,
+        "L5_TPC.bpo_threshold_current": cell.threshold_current,
+    }
+
+    # without holding voltage, there is no holding current
+    protocol = create_search_protocol(0.37)
+    protocol.run(cell, {})
+    assert cell.holding_current == 0.0
+    assert protocol.holding_current == 0.0
+
+
+def test_current_search_failure():
+    """Test that the search fails when the currents are too large."""
+    protocol = create_search_protocol(5.0, max_threshold_current=2.0)
+    with pytest.raises(RuntimeError):
+        protocol.run(FakeCell(), {})
+
+    protocol = create_search_protocol(
+        0.37, holding_voltage=-10.0, max_holding_current=2.0
+    )
+    with pytest.raises(RuntimeError):
+        protocol.run(FakeCell(), {})
+
+
+class FakePhase:
+    """Phase protocol with a single recording."""
+
+    def __init__(self, name, total_duration):
+        self.name = name
+        self.total_duration = total_duration
+        self.recordings = [SimpleNamespace(name=f"{name}.soma.v", response=name)]
+        self.instantiated = False
+
+    def subprotocols(self):
+        return {self.name: self}
+
+    def instantiate(self, sim=None, icell=None):
+        self.instantiated = True
+
+    def destroy(self, sim=None):
+        self.instantiated = False
+
+
+class FakeChainCell:
+    """Cell model instantiated once for the whole chain."""
+
+    icell = None
+    mechanisms = []
+
+    def __init__(self):
+        self.n_instantiations = 0
+
+    def freeze(self, param_values):
+        pass
+
+    def unfreeze(self, param_names):
+        pass
+
+    def instantiate(self, sim=None):
+        self.n_instantiations += 1
+
+    def destroy(self, sim=None):
+        pass
+
+
+def test_chain_protocol(monkeypatch):
+    """Test that the state of the cell is carried over from one phase to the next."""
+    phases = [FakePhase("Induction", 100.0), FakePhase("Test", 50.0)]
+    protocol = ChainProtocol("Plasticity", phases)
+    assert list(protocol.subprotocols()) == ["Plasticity", "Induction", "Test"]
+    assert protocol.total_duration == 150.0
+
+    runs = []
+    monkeypatch.setattr(
+        ChainProtocol,
+        "run_phase",
+        lambda self, phase, sim, state=None: runs.append((phase.name, state)),
+    )
+    monkeypatch.setattr(
+        sscx_protocols,
+        "get_cell_state",
+        lambda sim, cell_model: f"state after {runs[-1][0]}",
+    )
+    cell = FakeChainCell()
+    responses = protocol.run(cell, {})
+    assert cell.n_instantiations == 1
+    assert runs == [("Induction", None), ("Test", "state after Induction")]
+    assert responses == {"Induction.soma.v": "Induction", "Test.soma.v": "Test"}
+    assert not any(phase.instantiated for phase in phases)
+
+    # the phases after a failed one are not run
+    def failing_run_phase(self, phase, sim, state=None):
+        raise RuntimeError("simulation failed")
+
+    monkeypatch.setattr(ChainProtocol, "run_phase", failing_run_phase)
+    responses = protocol.run(FakeChainCell(), {})
+    assert responses == {"Induction.soma.v": None, "Test.soma.v": None}
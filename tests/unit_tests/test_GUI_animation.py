@@ -0,0 +1,26 @@
+"""Unit tests for the functions of the GUI animation module."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+from emodelrunner.GUI_utils.animation import get_frame_step
+
+
+def test_get_frame_step():
+    """Test get_frame_step function."""
+    # 100 ms per second at 10 fps -> 10 ms per frame -> 20 samples of 0.5 ms
+    assert get_frame_step(0.5, 100, 10) == 20
+    # at least one sample per frame
+    assert get_frame_step(0.5, 1, 15) == 1
@@ -15,7 +15,11 @@
 # limitations under the License.
 
 
-from emodelrunner.GUI_utils.frames import positive_int_callback, float_callback
+from emodelrunner.GUI_utils.frames import (
+    positive_int_callback,
+    float_callback,
+    FrameFactsheet,
+)
 
 
 def test_positive_int_callback():
@@ -34,3 +38,14 @@ def test_float_callback():
     assert float_callback("-10")
     assert float_callback("3.14")
     assert not float_callback("not an int")
+
+
+def test_factsheet_format_value():
+    """Test the formatting of the factsheet features."""
+    assert FrameFactsheet.format_value("spike_count", 3) == "3"
+    assert FrameFactsheet.format_value("input_resistance", None) == "-"
+    assert FrameFactsheet.format_value("AP_half_width", 0.84321) == "0.843"
+    assert FrameFactsheet.format_value("rheobase", (0.1, 0.2)) == "0.1 - 0.2"
+    assert FrameFactsheet.format_value("rheobase", (None, 0.2)) == "<= 0.2"
+    assert FrameFactsheet.format_value("rheobase", (0.1, None)) == "> 0.1"
+    assert FrameFactsheet.format_value("rheobase", (None, None)) == "-"
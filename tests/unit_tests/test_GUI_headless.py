@@ -0,0 +1,62 @@
+"""Unit tests for the functions of the GUI headless module."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import os
+import subprocess
+import sys
+
+from emodelrunner.GUI_utils.headless import read_session, run_headless
+from emodelrunner.GUI_utils.simulator import NeuronSimulation
+from tests.utils import cwd
+
+example_dir = os.path.join("examples", "sscx_sample_dir")
+
+
+def test_run_headless(tmp_path):
+    """Test saving the figure panels of a short simulation."""
+    config_path = "config/config_singlestep.ini"
+    with cwd(example_dir):
+        # shorten the default protocol with a session
+        session = NeuronSimulation(config_path).get_state()
+        session["stimuli"]["total_duration"] = 20
+        session["synapses"] = {"0": [5, 5, 2, 0]}
+
+        paths = run_headless(config_path, tmp_path, session=session)
+
+    assert [path.name for path in paths] == [
+        "morphology_voltage.png",
+        "morphology_synapses.png",
+        "voltage.png",
+    ]
+    for path in paths:
+        assert path.stat().st_size > 0
+
+
+def test_headless_without_tkinter():
+    """Test that the GUI script does not import tkinter for the screenshots."""
+    code = (
+        "import sys; import emodelrunner.GUI; "
+        "assert 'tkinter' not in sys.modules, 'tkinter imported'"
+    )
+    subprocess.run([sys.executable, "-c", code], check=True)
+
+
+def test_read_session(tmp_path):
+    """Test reading a session file without the GUI."""
+    session_path = tmp_path / "session.json"
+    session_path.write_text('{"config_path": "config/config_singlestep.ini"}')
+    assert read_session(session_path)["config_path"] == "config/config_singlestep.ini"
@@ -0,0 +1,258 @@
+"""Unit tests for the GUI class of the GUI interface, with a fake simulation."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+from types import SimpleNamespace
+
+import numpy as np
+import pytest
+
+from emodelrunner.GUI_utils.interface import GUI
+
+
+class FakeSimulation:
+    """Fake NeuronSimulation advancing by steps of 10 ms."""
+
+    def __init__(self, tstop=100.0):
+        """Constructor."""
+        self.sim = SimpleNamespace(
+            neuron=SimpleNamespace(h=SimpleNamespace(t=0.0, tstop=tstop, dt=0.025))
+        )
+        self.cell = SimpleNamespace(icell=None)
+        self.step_stim = 0.5
+        self.hypamp = -0.1
+        self.pre_mtypes = []
+        self.conductance_scales = {}
+        # called after each step, e.g. to click on a button during the run
+        self.on_step = None
+        self.calls = []
+
+    def instantiate(self):
+        """Instantiate the cell and initialise the simulation."""
+        self.calls.append("instantiate")
+        self.cell.icell = object()
+        self.sim.neuron.h.t = 0.0
+
+    def destroy(self):
+        """Destroy the cell."""
+        self.calls.append("destroy")
+        self.cell.icell = None
+
+    def is_finished(self):
+        """Return True if the simulation has reached its end."""
+        h = self.sim.neuron.h
+        return h.t >= h.tstop - h.dt / 2
+
+    def advance(self, callback=None, callback_dt=None):
+        """Run the simulation, calling callback after each step."""
+        # pylint: disable=unused-argument
+        h = self.sim.neuron.h
+        while not self.is_finished():
+            h.t += 10.0
+            if self.on_step is not None:
+                self.on_step()
+            if callback is not None and callback() is False:
+                break
+        return self.is_finished()
+
+    def get_voltage(self):
+        """Return the time and the voltage of the last run."""
+        return np.array([0.0, self.sim.neuron.h.t]), np.array([-80.0, -70.0])
+
+    def get_step_features(self):
+        """Return the factsheet features of the last run."""
+        return {"spike_count": 0}
+
+    @staticmethod
+    def get_rheobase_condition():
+        """Return the condition of the rheobase search."""
+        return "default"
+
+
+class FakeFrame:
+    """Fake frame keeping the state of the buttons and the displayed data."""
+
+    def __init__(self):
+        """Constructor."""
+        self.state = None
+        self.progress = []
+        self.overlaid_traces = None
+        self.features = None
+
+    def simul_running(self):
+        """Set the buttons of a running simulation."""
+        self.state = "running"
+
+    def simul_on_pause(self):
+        """Set the buttons of a paused simulation."""
+        self.state = "paused"
+
+    def simul_ended(self):
+        """Set the buttons of an ended simulation."""
+        self.state = "ended"
+
+    def set_progress(self, progress):
+        """Keep the displayed progress."""
+        self.progress.append(progress)
+
+    def display(self, *args):
+        """Keep the displayed features of the factsheet frame."""
+        if len(args) == 1:
+            self.features = args[0]
+
+    @staticmethod
+    def check_change(root, simulation):
+        """No significant voltage change."""
+        # pylint: disable=unused-argument
+        return False
+
+    def restart_volt(self, kept_traces):
+        """Keep the traces overlaid on the cleared voltage figure."""
+        self.overlaid_traces = list(kept_traces)
+
+    def clear_kept_traces(self):
+        """Remove the overlaid traces."""
+        self.overlaid_traces = []
+
+
+def get_fake_gui():
+    """Return a GUI with a fake simulation and fake frames, without Tk window."""
+    gui = GUI.__new__(GUI)
+    gui.simulation = FakeSimulation()
+    gui.frames = {"FrameMain": FakeFrame(), "FrameFactsheet": FakeFrame()}
+    gui.root = SimpleNamespace(update=lambda: None)
+    gui.play = False
+    gui.reload = False
+    gui.cancel_requested = False
+    # update the figures after each step
+    gui.refresh_display_dt = -1.0
+    gui.last_refresh_time = 0.0
+    gui.last_check_t = 0.0
+    gui.keep_traces = False
+    gui.kept_traces = []
+    gui.run_label = gui.get_run_label()
+    gui.rheobase_steps = {}
+    return gui
+
+
+def test_get_refresh_from_fps():
+    """Test that the figures are refreshed only at a strictly positive rate."""
+    assert GUI.get_refresh_from_fps(20) == 0.05
+    for fps in [0, -5]:
+        with pytest.raises(ValueError):
+            GUI.get_refresh_from_fps(fps)
+
+
+def test_keep_current_trace():
+    """Test that the traces of the previous runs are overlaid on the next runs."""
+    gui = get_fake_gui()
+    gui.keep_traces = True
+
+    # nothing to keep before the first run
+    gui.start()
+    assert gui.kept_traces == []
+    assert gui.frames["FrameMain"].state == "ended"
+
+    gui.simulation.step_stim = 0.7
+    gui.start()
+    assert [trace["label"] for trace in gui.kept_traces] == [
+        "run 1: step 0.5 nA, holding -0.1 nA"
+    ]
+    np.testing.assert_allclose(gui.kept_traces[0]["time"], [0.0, 100.0])
+    # the voltage figure is cleared, with the kept trace overlaid
+    assert gui.frames["FrameMain"].overlaid_traces == gui.kept_traces
+    assert gui.run_label == "step 0.7 nA, holding -0.1 nA"
+
+    gui.start()
+    assert len(gui.kept_traces) == 2
+    assert gui.kept_traces[1]["label"] == "run 2: step 0.7 nA, holding -0.1 nA"
+
+    # the traces are only kept when asked
+    gui.keep_traces = False
+    gui.start()
+    assert len(gui.kept_traces) == 2
+
+    gui.clear_traces()
+    assert gui.kept_traces == []
+    assert gui.frames["FrameMain"].overlaid_traces == []
+
+
+def test_get_progress():
+    """Test the progress of the simulation."""
+    gui = get_fake_gui()
+    h = gui.simulation.sim.neuron.h
+
+    h.t = 25.0
+    assert gui.get_progress() == 25.0
+    h.t = 120.0
+    assert gui.get_progress() == 100.0
+    h.tstop = 0.0
+    assert gui.get_progress() == 0.0
+
+
+def test_cancel_during_run():
+    """Test that a run cancelled from the display updates is aborted and reset."""
+    gui = get_fake_gui()
+    h = gui.simulation.sim.neuron.h
+
+    def click_cancel():
+        """Click on the cancel button at 50 ms."""
+        if h.t >= 50.0:
+            gui.cancel()
+
+    gui.simulation.on_step = click_cancel
+    gui.start()
+
+    # stopped at the step of the click, then reset to the initial state
+    assert h.t == 0.0
+    assert gui.simulation.calls == ["instantiate", "destroy", "instantiate"]
+    assert not gui.play
+    assert not gui.cancel_requested
+    assert gui.frames["FrameMain"].progress[-1] == 0
+    assert 50.0 in gui.frames["FrameMain"].progress
+    assert 100.0 not in gui.frames["FrameMain"].progress
+    # the buttons are back to the stopped state, without factsheet of the run
+    assert gui.frames["FrameMain"].state == "ended"
+    assert gui.frames["FrameFactsheet"].features is None
+
+    # the next run goes to the end
+    gui.simulation.on_step = None
+    gui.start()
+    assert gui.get_progress() == 100.0
+    assert gui.frames["FrameMain"].state == "ended"
+    assert gui.frames["FrameFactsheet"].features is not None
+
+
+def test_cancel_on_pause():
+    """Test that a paused run is aborted at once when cancelled."""
+    gui = get_fake_gui()
+    h = gui.simulation.sim.neuron.h
+
+    def click_pause():
+        """Click on the pause button at 30 ms."""
+        if h.t >= 30.0 and gui.play:
+            gui.pause()
+
+    gui.simulation.on_step = click_pause
+    gui.start()
+    assert h.t == 30.0
+    assert gui.frames["FrameMain"].state == "paused"
+
+    gui.cancel()
+    assert h.t == 0.0
+    assert not gui.cancel_requested
+    assert gui.frames["FrameMain"].progress[-1] == 0
+    assert gui.frames["FrameMain"].state == "ended"
@@ -0,0 +1,45 @@
+"""Unit tests for the GUI notebook module."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import os
+
+import pytest
+
+from tests.utils import cwd
+
+pytest.importorskip("ipywidgets")
+from emodelrunner.GUI_utils.notebook import NotebookGUI
+
+example_dir = os.path.join("examples", "sscx_sample_dir")
+
+
+def test_notebook_gui():
+    """Test loading a step protocol and running a short simulation."""
+    with cwd(example_dir):
+        gui = NotebookGUI(config_path="config/config_singlestep.ini")
+
+        gui.protocol_widget.value = "Step_150"
+        assert gui.stim_widgets["step_stim"].value == 0.20915625
+
+        gui.stim_widgets["total_duration"].value = 20
+        gui.run()
+
+    assert gui.simulation.protocol.total_duration == 20
+    assert gui.progress.value == pytest.approx(100, abs=1)
+    assert len(gui.volt_line.get_xdata()) > 0
+    assert len(gui.volt_image.value) > 0
+    assert not gui.run_button.disabled
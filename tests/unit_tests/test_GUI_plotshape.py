@@ -14,9 +14,12 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
+from types import SimpleNamespace
+
 from matplotlib import cm
+import pytest
 
-from emodelrunner.GUI_utils.plotshape import get_color_from_cmap
+from emodelrunner.GUI_utils.plotshape import get_color_from_cmap, get_synapse_colors
 
 
 def test_get_color_from_cmap():
@@ -25,3 +28,26 @@ def test_get_color_from_cmap():
     assert get_color_from_cmap(0, 0, 0, cmap) == "black"
     assert get_color_from_cmap(0, 10, 0, cmap) == "black"
     assert get_color_from_cmap(-50, -70, 30, cmap) == (0.8, 0.8, 0.8, 1.0)
+
+
+def test_get_synapse_colors():
+    """Test get_synapse_colors function."""
+    simulation = SimpleNamespace(
+        available_pre_mtypes={0: "L1_DAC", 3: "L23_PC"},
+        syn_display_data={0: [[0, 0, 0, 0]], 3: [[0, 0, 0, 1], [1, 1, 1, 1]]},
+        syn_weights={0: [1.0], 3: [2.0, 3.0]},
+        is_stimulated=lambda mtype: mtype == 3,
+    )
+
+    assert get_synapse_colors(simulation, 3) == ["red", "red"]
+    assert get_synapse_colors(simulation, 0) == ["orange"]
+    assert get_synapse_colors(simulation, 3, "mtype") == [cm.tab20(1)] * 2
+    assert get_synapse_colors(simulation, 3, "weight", cm.binary) == [
+        cm.binary(0.5),
+        cm.binary(1.0),
+    ]
+    assert get_synapse_colors(simulation, 0, "activation") == ["grey"]
+    assert get_synapse_colors(simulation, 3, "activation") == ["red", "red"]
+
+    with pytest.raises(ValueError):
+        get_synapse_colors(simulation, 3, "size")
@@ -14,13 +14,19 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
+import json
 import os
 
+import numpy as np
+import pytest
+
 from emodelrunner.GUI_utils.simulator import NeuronSimulation
 from tests.utils import cwd
 
 
 example_dir = os.path.join("examples", "sscx_sample_dir")
+thalamus_example_dir = os.path.join("examples", "thalamus_sample_dir")
+synplas_example_dir = os.path.join("examples", "synplas_sample_dir")
 
 
 class TestNeuronSimulation(object):
@@ -74,6 +80,52 @@ class TestNeuronSimulation(object):
         assert netstim.total_duration == self.simulator.total_duration == 300.0
         assert len(netstim.locations) == 1
 
+    def test_get_pre_mtype_groups(self):
+        """Test get_pre_mtype_groups method."""
+        with cwd(example_dir):
+            self.simulator.cell = self.simulator.create_cell_custom()
+        groups = self.simulator.get_pre_mtype_groups()
+
+        assert len(groups["excitatory"]) + len(groups["inhibitory"]) == 29
+        assert 0 in groups["excitatory"]
+        assert 10 in groups["inhibitory"]
+
+    def test_conductance_scales(self):
+        """Test the scaling of the channel conductances."""
+        with cwd(example_dir):
+            self.simulator.load_cell_sim()
+
+        names = self.simulator.get_conductance_names()
+        assert "gNaTgbar_NaTg" in names
+        assert "gIhbar_Ih" in names
+        assert "g_pas" not in names
+
+        self.simulator.set_conductance_scale("gNaTgbar_NaTg", 0.5)
+        release_params = self.simulator.release_params
+        scaled_params = self.simulator.get_scaled_release_params()
+        assert scaled_params["gNaTgbar_NaTg.axonal"] == pytest.approx(
+            0.5 * release_params["gNaTgbar_NaTg.axonal"]
+        )
+        assert scaled_params["gIhbar_Ih.somadend"] == pytest.approx(
+            release_params["gIhbar_Ih.somadend"]
+        )
+
+        with pytest.raises(ValueError):
+            self.simulator.set_conductance_scale("gNaTgbar_NaTg", -1)
+        with pytest.raises(ValueError):
+            self.simulator.set_conductance_scale("gUnknownbar_Unknown", 1)
+
+    def test_get_rheobase_condition(self):
+        """Test get_rheobase_condition method."""
+        condition = self.simulator.get_rheobase_condition()
+
+        # the step amplitude does not change the condition
+        self.simulator.step_stim = 0.5
+        assert self.simulator.get_rheobase_condition() == condition
+
+        self.simulator.hypamp = -0.1
+        assert self.simulator.get_rheobase_condition() != condition
+
     def test_load_protocol(self):
         """Test load_protocol method."""
         prot_name = "test_protocol"
@@ -111,7 +163,228 @@ class TestNeuronSimulation(object):
             -29.566504944378146,
             1,
         ] in self.simulator.syn_display_data[0]
+        assert len(self.simulator.syn_weights[0]) == len(
+            self.simulator.syn_display_data[0]
+        )
+
+        # destroy cell
+        self.simulator.cell.destroy(sim=self.simulator.sim)
+        self.simulator.cell.unfreeze(self.simulator.release_params.keys())
+
+    def test_load_recording_display_data(self):
+        """Test load_recording_display_data method."""
+        # instantiate cell
+        with cwd(example_dir):
+            self.simulator.load_cell_sim()
+            self.simulator.load_protocol()
+            self.simulator.sim.mechanisms_directory = "./"
+            self.simulator.cell.freeze(self.simulator.release_params)
+            self.simulator.cell.instantiate(sim=self.simulator.sim)
+
+        assert self.simulator.rec_display_data == []
+        self.simulator.load_recording_display_data()
+        # only the soma is recorded
+        assert len(self.simulator.rec_display_data) == 1
+        assert len(self.simulator.rec_display_data[0]) == 3
 
         # destroy cell
         self.simulator.cell.destroy(sim=self.simulator.sim)
         self.simulator.cell.unfreeze(self.simulator.release_params.keys())
+
+    def test_recording_sites(self, tmp_path):
+        """Test the recording sites and the stimulus location picked on the morphology."""
+        with cwd(example_dir):
+            self.simulator.load_cell_sim()
+            self.simulator.load_protocol()
+            self.simulator.sim.mechanisms_directory = "./"
+            self.simulator.instantiate()
+
+            # the first segment of the morphology plot is in the soma
+            assert self.simulator.get_segment_location(0) == "soma[0](0.5)"
+
+            assert self.simulator.toggle_recording_location("dend[3](0.5)")
+            self.simulator.stimulus_location = "dend[0](0.5)"
+            self.simulator.destroy()
+            self.simulator.load_protocol()
+            self.simulator.instantiate()
+            self.simulator.load_recording_display_data()
+
+            config_path = tmp_path / "config.ini"
+            self.simulator.save_sites_to_config(config_path)
+            # the paths of the config are relative to the package directory
+            saved_simulator = NeuronSimulation(str(config_path))
+
+        assert [rec.name for rec in self.simulator.protocol.recordings] == [
+            "protocol",
+            "protocol.dend3_x0p5",
+        ]
+        assert len(self.simulator.rec_display_data) == 2
+        assert len(self.simulator.stim_display_data) == 3
+
+        assert NeuronSimulation.get_section_location("").name == "soma"
+
+        assert saved_simulator.recording_locations == ["dend[3](0.5)"]
+        assert saved_simulator.stimulus_location == "dend[0](0.5)"
+
+        assert not self.simulator.toggle_recording_location("dend[3](0.5)")
+        assert self.simulator.recording_locations == []
+
+        self.simulator.destroy()
+
+    def test_morphology_recordings(self):
+        """Test the recording of the voltage of all the segments."""
+        with pytest.raises(ValueError):
+            self.simulator.get_morphology_voltage()
+
+        with cwd(example_dir):
+            self.simulator.load_cell_sim()
+            self.simulator.load_protocol()
+            self.simulator.sim.mechanisms_directory = "./"
+            self.simulator.record_morphology = True
+            self.simulator.instantiate()
+
+        h = self.simulator.sim.neuron.h
+        h.tstop = 2
+        while h.t < h.tstop - h.dt / 2:
+            h.fadvance()
+
+        time, voltage = self.simulator.get_morphology_voltage()
+        n_segments = sum(sec.nseg for sec in h.allsec())
+        assert voltage.shape == (len(time), n_segments)
+        assert time[-1] == pytest.approx(2.0)
+
+        self.simulator.destroy()
+        assert self.simulator.morph_recordings is None
+
+    def test_advance(self):
+        """Test the run of the simulation with intermediate callbacks."""
+        with cwd(example_dir):
+            self.simulator.load_cell_sim()
+            self.simulator.load_protocol()
+            self.simulator.sim.mechanisms_directory = "./"
+            self.simulator.instantiate()
+
+        h = self.simulator.sim.neuron.h
+        h.tstop = 2
+        callback_times = []
+
+        def callback():
+            callback_times.append(h.t)
+            return h.t < 1
+
+        # stop when the callback returns False
+        assert not self.simulator.advance(callback, callback_dt=0.5)
+        # the callback is called by the events scheduled every callback_dt
+        assert callback_times == pytest.approx([0.5, 1.0], abs=h.dt)
+        assert len(self.simulator.get_voltage()[0]) > 1
+
+        # continue until the end
+        assert self.simulator.advance()
+        assert self.simulator.is_finished()
+
+        self.simulator.destroy()
+
+    def test_save_voltage_csv(self, monkeypatch):
+        """Test save_voltage_csv method."""
+        output_path = os.path.join("tests", "output", "GUI_voltage.csv")
+        os.makedirs(os.path.dirname(output_path), exist_ok=True)
+        monkeypatch.setattr(
+            self.simulator,
+            "get_voltage",
+            lambda: (np.array([0.0, 0.1]), np.array([-80.0, -79.0])),
+        )
+
+        self.simulator.save_voltage_csv(output_path)
+
+        with open(output_path, "r", encoding="utf-8") as csv_file:
+            assert csv_file.readline().strip() == "t [ms],v [mV]"
+        data = np.loadtxt(output_path, delimiter=",", skiprows=1)
+        assert np.allclose(data, [[0.0, -80.0], [0.1, -79.0]])
+
+    def test_apply_step_protocol(self):
+        """Test apply_step_protocol method."""
+        assert list(self.simulator.step_protocols.keys()) == ["Step_150"]
+
+        self.simulator.apply_step_protocol("Step_150")
+
+        assert self.simulator.step_stim == 0.20915625
+        assert self.simulator.step_delay == 70.0
+        assert self.simulator.step_duration == 200.0
+        assert self.simulator.total_duration == 300.0
+        assert self.simulator.hypamp == -0.0896244038173676
+        assert self.simulator.hold_step_delay == 0.0
+        assert self.simulator.hold_step_duration == 300.0
+
+    def test_save_step_protocol(self):
+        """Test save_step_protocol method."""
+        prot_path = os.path.join("tests", "output", "GUI_protocols.json")
+        os.makedirs(os.path.dirname(prot_path), exist_ok=True)
+        with open(prot_path, "w", encoding="utf-8") as protocol_file:
+            json.dump({}, protocol_file)
+
+        self.simulator.step_stim = 0.5
+        self.simulator.save_step_protocol("Step_custom", prot_path)
+
+        with open(prot_path, "r", encoding="utf-8") as protocol_file:
+            protocol_data = json.load(protocol_file)
+        assert protocol_data["Step_custom"]["type"] == "StepProtocol"
+        assert protocol_data["Step_custom"]["stimuli"]["step"]["amp"] == 0.5
+        assert "Step_custom" in self.simulator.step_protocols
+
+    def test_get_set_state(self):
+        """Test get_state and set_state methods."""
+        self.simulator.step_stim = 0.5
+        self.simulator.pre_mtypes = [10]
+        self.simulator.netstim_params = {10: [100, 50, 5, 0]}
+        # state should survive a json round trip
+        state = json.loads(json.dumps(self.simulator.get_state()))
+        assert state["stimuli"]["step_stim"] == 0.5
+        assert state["synapses"] == {"10": [100, 50, 5, 0]}
+
+        self.simulator.step_stim = 0
+        self.simulator.pre_mtypes = []
+        self.simulator.netstim_params = {}
+        self.simulator.set_state(state)
+        assert self.simulator.step_stim == 0.5
+        assert self.simulator.pre_mtypes == [10]
+        assert self.simulator.netstim_params == {10: [100, 50, 5, 0]}
+        assert self.simulator.conductance_scales == {}
+        assert self.simulator.is_stimulated(10)
+        assert not self.simulator.is_stimulated(0)
+
+        state["synapses"] = {"1000": [0, 0, 0, 0]}
+        with pytest.raises(ValueError):
+            self.simulator.set_state(state)
+
+
+def test_thalamus_simulation():
+    """Test NeuronSimulation with a thalamus package without synapses."""
+    with cwd(thalamus_example_dir):
+        simulator = NeuronSimulation("config/config_recipe_prots_short.ini")
+        cell = simulator.create_cell_custom()
+
+    assert simulator.available_pre_mtypes == {}
+    assert set(simulator.step_protocols.keys()) == {"RMP", "Rin_dep", "Rin_hyp"}
+    assert cell.name == "dNAD_ltb"
+    assert not any(hasattr(mech, "synapses_data") for mech in cell.mechanisms)
+
+
+def test_synplas_simulation():
+    """Test NeuronSimulation with a synapse plasticity package."""
+    with cwd(synplas_example_dir):
+        simulator = NeuronSimulation("config/config_1Hz_10ms.ini")
+        simulator.syn_setup_params = simulator.get_syn_setup_params()
+        cell = simulator.create_cell_custom()
+
+    assert simulator.step_protocols == {}
+    assert simulator.available_pre_mtypes[0] == "L4_SSC"
+    assert simulator.syn_setup_params["postgid"] == 111376
+    assert cell.name == "cADpyr_L4UPC"
+    assert cell.fixhp is True
+    syn_mechs = [mech for mech in cell.mechanisms if hasattr(mech, "synapses_data")]
+    assert len(syn_mechs) == 1
+    assert syn_mechs[0].use_glu_synapse is True
+
+    # there is no protocols file to save protocols into
+    with pytest.raises(ValueError):
+        simulator.save_step_protocol("Step_custom")
@@ -23,6 +23,9 @@ from emodelrunner.GUI_utils.simulator import (
     get_pos_and_color,
     get_step_data,
     get_holding_data,
+    frequency_to_interval,
+    interval_to_frequency,
+    get_conductance_name,
 )
 
 sim = NrnSimulator()
@@ -146,3 +149,20 @@ def test_get_holding_data():
     }
     delay, duration = get_holding_data(holdings, stim_data, tot_dur, default_hold)
     assert holdings == [0.2]
+
+
+def test_frequency_interval_conversion():
+    """Test frequency_to_interval and interval_to_frequency functions."""
+    assert frequency_to_interval(20) == 50.0
+    assert frequency_to_interval(0) == 0
+    assert interval_to_frequency(50) == 20
+    assert interval_to_frequency(30) == 33
+    assert interval_to_frequency(0) == 0
+
+
+def test_get_conductance_name():
+    """Test get_conductance_name function."""
+    assert get_conductance_name("gNaTgbar_NaTg.axonal") == "gNaTgbar_NaTg"
+    assert get_conductance_name("gIhbar_Ih.somadend") == "gIhbar_Ih"
+    assert get_conductance_name("g_pas.all") is None
+    assert get_conductance_name("decay_CaDynamics_DC0.somatic") is None
@@ -0,0 +1,68 @@
+"""Unit tests for the functions of the GUI style module."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+from unittest.mock import Mock
+
+import matplotlib as mpl
+import pytest
+
+from emodelrunner.GUI_utils.style import (
+    get_scaling_factor,
+    get_style_cst,
+    set_matplotlib_style,
+)
+
+
+def test_get_style_cst():
+    """Test the colors of the light and dark themes."""
+    light = get_style_cst("light")
+    dark = get_style_cst("dark")
+    assert light["background"] == light["white"]
+    assert light["foreground"] == light["deep_blue"]
+    assert dark["background"] != light["background"]
+    assert dark["foreground"] != light["foreground"]
+
+    assert get_style_cst(scaling=2)["padding"] == 2 * light["padding"]
+
+    with pytest.raises(ValueError):
+        get_style_cst("unknown")
+
+
+def test_get_scaling_factor():
+    """Test the scaling factor computed from the screen resolution."""
+    root = Mock()
+    root.winfo_fpixels.return_value = 192.0
+    assert get_scaling_factor(root) == 2.0
+
+    # never shrink the GUI on low resolution screens
+    root.winfo_fpixels.return_value = 72.0
+    assert get_scaling_factor(root) == 1.0
+
+    # user-defined scaling
+    assert get_scaling_factor(root, 1.5) == 1.5
+    with pytest.raises(ValueError):
+        get_scaling_factor(root, 0)
+
+
+def test_set_matplotlib_style():
+    """Test the matplotlib colors and resolution."""
+    with mpl.rc_context():
+        set_matplotlib_style("dark", 2)
+        dark = get_style_cst("dark")
+        assert mpl.rcParams["figure.dpi"] == 200
+        assert mpl.rcParams["axes.facecolor"] == dark["background"]
+        assert mpl.rcParams["text.color"] == dark["foreground"]
@@ -0,0 +1,63 @@
+"""Unit tests for arbor_backend.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import pytest
+
+from emodelrunner.arbor_backend import get_step_stimuli
+
+
+def test_get_step_stimuli():
+    """Test the current clamps of the step protocols."""
+    definition = {
+        "type": "StepProtocol",
+        "stimuli": {
+            "step": {
+                "delay": 70.0,
+                "amp": 0.2,
+                "duration": 200.0,
+                "totduration": 300.0,
+            },
+            "holding": {
+                "delay": 0.0,
+                "amp": -0.09,
+                "duration": 300.0,
+                "totduration": 300.0,
+            },
+        },
+    }
+    clamps, tstop = get_step_stimuli(definition)
+    assert clamps == [(70.0, 200.0, 0.2), (0.0, 300.0, -0.09)]
+    assert tstop == 300.0
+
+    definition = {
+        "type": "StepProtocol",
+        "stimuli": {
+            "step": [
+                {"delay": 70.0, "amp": 0.2, "duration": 200.0, "totduration": 300.0},
+                {"delay": 140.0, "amp": 0.4, "duration": 60.0, "totduration": 400.0},
+            ]
+        },
+    }
+    clamps, tstop = get_step_stimuli(definition)
+    assert clamps == [(70.0, 200.0, 0.2), (140.0, 60.0, 0.4)]
+    assert tstop == 400.0
+
+
+def test_get_step_stimuli_unsupported():
+    """Test that only the step protocols with absolute amplitudes are supported."""
+    with pytest.raises(ValueError, match="StepThresholdProtocol"):
+        get_step_stimuli({"type": "StepThresholdProtocol", "stimuli": {}})
@@ -0,0 +1,126 @@
+"""Unit tests for attenuation.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import numpy as np
+import pytest
+
+from emodelrunner.attenuation import (
+    compute_attenuations,
+    get_bap_attenuation,
+    get_epsp_attenuation,
+    get_peak,
+)
+
+time = np.arange(0.0, 200.0, 0.025)
+
+
+def make_trace(amplitude, peak_time, width=1.0, base=-70.0):
+    """Return a voltage trace with a gaussian peak."""
+    voltage = base + amplitude * np.exp(-(((time - peak_time) / width) ** 2))
+    return {"time": time, "voltage": voltage}
+
+
+def test_get_peak():
+    """Test the amplitude and the time of the peak from the voltage base."""
+    trace = make_trace(20.0, 120.0)
+    amplitude, peak_time = get_peak(trace["time"], trace["voltage"], 100.0, 150.0)
+    assert amplitude == pytest.approx(20.0)
+    assert peak_time == pytest.approx(120.0)
+    assert get_peak(trace["time"], trace["voltage"], 300.0, 400.0) == (None, None)
+
+
+def test_get_bap_attenuation():
+    """Test the attenuation and the latency of the bAP, sorted by distance."""
+    responses = {
+        "soma": make_trace(100.0, 110.0),
+        "dend200": make_trace(40.0, 111.0),
+        "dend100": make_trace(80.0, 110.5),
+    }
+    attenuation = get_bap_attenuation(
+        responses, "soma", {"dend200": 200, "dend100": 100}, 100.0
+    )
+    assert attenuation["soma_amplitude"] == pytest.approx(100.0)
+    assert attenuation["distances"] == [100, 200]
+    assert attenuation["amplitudes"] == pytest.approx([80.0, 40.0])
+    assert attenuation["attenuation"] == pytest.approx([0.8, 0.4])
+    assert attenuation["latencies"] == pytest.approx([0.5, 1.0])
+
+    # no somatic spike, no bAP
+    responses["soma"] = make_trace(10.0, 110.0)
+    assert get_bap_attenuation(responses, "soma", {"dend100": 100}, 100.0) is None
+
+
+def test_get_epsp_attenuation():
+    """Test the attenuation of the EPSPs from each site to the soma."""
+    responses = {
+        "soma_0": make_trace(2.0, 105.0, width=5.0),
+        "site_0": make_trace(4.0, 102.0, width=2.0),
+        "soma_1": make_trace(1.0, 106.0, width=5.0),
+        "site_1": make_trace(5.0, 102.0, width=2.0),
+    }
+    sites = [
+        {"distance": 100, "soma_key": "soma_0", "local_key": "site_0"},
+        {"distance": 200, "soma_key": "soma_1", "local_key": "site_1"},
+        {"distance": 300, "soma_key": "soma_2", "local_key": "site_2"},
+    ]
+    attenuation = get_epsp_attenuation(responses, sites, 80.0)
+    assert attenuation["distances"] == [100, 200]
+    assert attenuation["local_amplitudes"] == pytest.approx([4.0, 5.0])
+    assert attenuation["soma_amplitudes"] == pytest.approx([2.0, 1.0])
+    assert attenuation["attenuation"] == pytest.approx([0.5, 0.2])
+
+
+def test_compute_attenuations():
+    """Test the attenuation of the bAP and EPSP protocols."""
+    responses = {
+        "bAP.soma.v": make_trace(100.0, 110.0),
+        "bAP.apical100um.v": make_trace(50.0, 111.0),
+        "EPSP_0.soma.v": make_trace(1.0, 105.0, width=5.0),
+        "EPSP_0.apical100um.v": make_trace(4.0, 102.0, width=2.0),
+        "noAP.soma.v": make_trace(10.0, 110.0),
+    }
+    attenuation_protocols = {
+        "bAP": {
+            "type": "bAP",
+            "stim_start": 100.0,
+            "soma_key": "bAP.soma.v",
+            "dendrite_keys": {"bAP.apical100um.v": 100},
+        },
+        "EPSP": {
+            "type": "EPSP",
+            "stim_start": 80.0,
+            "sites": [
+                {
+                    "distance": 100,
+                    "soma_key": "EPSP_0.soma.v",
+                    "local_key": "EPSP_0.apical100um.v",
+                }
+            ],
+        },
+        "noAP": {
+            "type": "bAP",
+            "stim_start": 100.0,
+            "soma_key": "noAP.soma.v",
+            "dendrite_keys": {},
+        },
+    }
+
+    attenuations = compute_attenuations(responses, attenuation_protocols)
+    assert list(attenuations) == ["bAP", "EPSP"]
+    assert attenuations["bAP"]["type"] == "bAP"
+    assert attenuations["bAP"]["attenuation"] == pytest.approx([0.5])
+    assert attenuations["EPSP"]["type"] == "EPSP"
+    assert attenuations["EPSP"]["attenuation"] == pytest.approx([0.25])
@@ -0,0 +1,131 @@
+"""Unit tests for batch.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+from pathlib import Path
+
+import pytest
+
+from emodelrunner.batch import (
+    get_batch_jobs,
+    get_batch_report,
+    get_job_command,
+    load_batch_definition,
+    run_batch,
+)
+
+examples_dir = Path("examples").resolve()
+
+
+def test_load_batch_definition(tmp_path):
+    """Test that the invalid batch files are rejected."""
+    batch_path = tmp_path / "batch.json"
+
+    batch_path.write_text(json.dumps({"cells": []}))
+    with pytest.raises(ValueError):
+        load_batch_definition(batch_path)
+
+    batch_path.write_text(json.dumps({"cells": [{"package_dir": "sscx_sample_dir"}]}))
+    with pytest.raises(ValueError):
+        load_batch_definition(batch_path)
+
+    cell = {"package_dir": "sscx_sample_dir", "config_path": "config/config.ini"}
+    batch_path.write_text(json.dumps({"cells": [cell, cell]}))
+    with pytest.raises(ValueError):
+        load_batch_definition(batch_path)
+
+    batch_path.write_text(json.dumps({"cells": [cell, dict(cell, name="sscx_2")]}))
+    assert load_batch_definition(batch_path)["protocols"] == []
+
+
+def test_get_batch_jobs():
+    """Test that there is one job per cell and protocols file."""
+    batch_definition = {
+        "cells": [
+            {"package_dir": "sscx_sample_dir", "config_path": "config/config.ini"},
+            {
+                "name": "sscx_36",
+                "package_dir": "sscx_sample_dir",
+                "config_path": "config/config.ini",
+                "config_overrides": ["Cell.celsius=36"],
+            },
+        ],
+        "protocols": [
+            "config/protocols/singlestep.json",
+            "config/protocols/RmpRiTau.json",
+        ],
+    }
+
+    jobs = get_batch_jobs(batch_definition, "examples")
+    assert [job["name"] for job in jobs] == [
+        "sscx_sample_dir.singlestep",
+        "sscx_sample_dir.RmpRiTau",
+        "sscx_36.singlestep",
+        "sscx_36.RmpRiTau",
+    ]
+    assert jobs[0]["package_dir"] == str(Path("examples") / "sscx_sample_dir")
+
+    command = get_job_command(jobs[2], Path("/batch/sscx_36.singlestep"))
+    assert command[-8:] == [
+        "--config_path",
+        "config/config.ini",
+        "--set",
+        "Cell.celsius=36",
+        "--set",
+        "Paths.output_dir=/batch/sscx_36.singlestep",
+        "--set",
+        "Paths.prot_path=config/protocols/singlestep.json",
+    ]
+
+    batch_definition["protocols"] = []
+    assert [job["name"] for job in get_batch_jobs(batch_definition)] == [
+        "sscx_sample_dir",
+        "sscx_36",
+    ]
+
+
+def test_get_batch_report():
+    """Test the counts and the failures of the report."""
+    results = [
+        {"name": "b", "status": "failed", "error": "ValueError"},
+        {"name": "a", "status": "done", "error": ""},
+        {"name": "c", "status": "timeout", "error": "The job did not finish"},
+    ]
+
+    report = get_batch_report(results, n_ranks=2)
+    assert report["n_ranks"] == 2
+    assert report["n_jobs"] == 3
+    assert report["n_done"] == report["n_failed"] == report["n_timeout"] == 1
+    assert [job["name"] for job in report["failures"]] == ["b", "c"]
+    assert [job["name"] for job in report["jobs"]] == ["a", "b", "c"]
+
+
+def test_run_batch_failure(tmp_path):
+    """Test that a failed job is reported with the end of its log."""
+    batch_path = tmp_path / "batch.json"
+    cell = {
+        "package_dir": str(examples_dir / "sscx_sample_dir"),
+        "config_path": "config/missing.ini",
+    }
+    batch_path.write_text(json.dumps({"cells": [cell]}))
+
+    report = run_batch(batch_path, tmp_path / "batch")
+
+    assert report["n_jobs"] == report["n_failed"] == 1
+    assert report["failures"][0]["returncode"] == 1
+    assert "missing.ini" in report["failures"][0]["error"]
+    assert (tmp_path / "batch" / "batch_report.json").is_file()
+    assert (tmp_path / "batch" / "sscx_sample_dir" / "run.log").is_file()
@@ -0,0 +1,166 @@
+"""Unit tests for bluepyemodel_adapter.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import json
+from pathlib import Path
+from types import SimpleNamespace
+
+import pytest
+
+from emodelrunner.bluepyemodel_adapter import (
+    convert_model_configuration,
+    get_access_point,
+    write_access_point_package,
+)
+from emodelrunner.load import get_morph_path, load_config
+from tests.utils import cwd
+
+sscx_sample_dir = Path("examples") / "sscx_sample_dir"
+
+
+def get_model_configuration(morph_path):
+    """Return a BluePyEModel model configuration."""
+    return {
+        "mechanisms": [
+            {"name": "pas", "location": "all", "stochastic": False},
+            {"name": "Ih", "location": "somadend", "stochastic": False},
+            {"name": "StochKv", "location": "somatic", "stochastic": True},
+        ],
+        "distributions": [
+            {"name": "uniform", "function": None, "parameters": []},
+            {
+                "name": "decay",
+                "function": "math.exp({distance}*{constant})*{value}",
+                "parameters": ["constant"],
+            },
+        ],
+        "parameters": [
+            {"name": "v_init", "value": -80, "location": "global"},
+            {
+                "name": "constant",
+                "value": [-0.1, 0.0],
+                "location": "distribution_decay",
+            },
+            {"name": "g_pas", "value": [1e-05, 6e-05], "location": "all"},
+            {
+                "name": "gIhbar_Ih",
+                "value": [0, 0.0002],
+                "location": "somadend",
+                "distribution": "decay",
+                "mechanism": "Ih",
+            },
+        ],
+        "morphology": {"name": "L4UPC", "path": str(morph_path)},
+    }
+
+
+def get_access_point_mock(tmp_path):
+    """Return an object mocking a BluePyEModel access point."""
+    morph_path = next((sscx_sample_dir / "morphology").glob("*.asc")).resolve()
+    fitness_calculator_configuration = {
+        "protocols": [
+            {
+                "name": "IDrest_0.3",
+                "stimuli": [
+                    {
+                        "delay": 700.0,
+                        "amp": 0.3,
+                        "duration": 2000.0,
+                        "totduration": 3000.0,
+                    }
+                ],
+                "recordings": [],
+                "protocol_type": "Protocol",
+            }
+        ],
+        "efeatures": [
+            {
+                "efel_feature_name": "Spikecount",
+                "protocol_name": "IDrest_0.3",
+                "recording_name": "soma.v",
+                "mean": 12.0,
+                "original_std": 1.0,
+            }
+        ],
+    }
+    return SimpleNamespace(
+        emodel_metadata=SimpleNamespace(emodel="cADpyr_L4UPC"),
+        emodel_dir=tmp_path / "emodel",
+        get_model_configuration=lambda: SimpleNamespace(
+            as_dict=lambda: get_model_configuration(morph_path)
+        ),
+        get_emodel=lambda: SimpleNamespace(
+            parameters={"g_pas.all": 3e-05, "gIhbar_Ih.somadend": 1e-04}
+        ),
+        get_fitness_calculator_configuration=lambda: SimpleNamespace(
+            as_dict=lambda: fitness_calculator_configuration
+        ),
+    )
+
+
+def test_convert_model_configuration():
+    """Test the conversion of a model configuration to unoptimized parameters."""
+    params = convert_model_configuration(get_model_configuration("morph.asc"))
+
+    assert params["mechanisms"] == {
+        "all": {"mech": ["pas"]},
+        "somadend": {"mech": ["Ih"]},
+        "somatic": {"mech": ["StochKv"]},
+    }
+    assert params["distributions"] == {
+        "decay": {
+            "fun": "math.exp({distance}*{constant})*{value}",
+            "parameters": ["constant"],
+        }
+    }
+    assert params["parameters"]["global"] == [{"name": "v_init", "val": -80}]
+    assert params["parameters"]["distribution_decay"] == [
+        {"name": "constant", "val": [-0.1, 0.0]}
+    ]
+    assert params["parameters"]["all"] == [{"name": "g_pas", "val": [1e-05, 6e-05]}]
+    assert params["parameters"]["somadend"] == [
+        {"name": "gIhbar_Ih", "val": [0, 0.0002], "dist": "decay"}
+    ]
+
+
+def test_write_access_point_package(tmp_path):
+    """Test that the package written from an access point has a valid config."""
+    output_dir = tmp_path / "package"
+    config_path = write_access_point_package(
+        get_access_point_mock(tmp_path),
+        output_dir,
+        template_dir=sscx_sample_dir.resolve(),
+    )
+
+    assert config_path == output_dir / "config" / "config_cADpyr_L4UPC.ini"
+    with cwd(output_dir):
+        config = load_config(config_path=config_path)
+        assert config.get("Cell", "emodel") == "cADpyr_L4UPC"
+        assert config.get("Morphology", "mtype") == "L4UPC"
+        assert Path(get_morph_path(config)).parent.resolve() == (
+            output_dir / "morphology"
+        ).resolve()
+        final = json.loads(Path(config.get("Paths", "params_path")).read_text())
+        assert final["cADpyr_L4UPC"]["params"]["g_pas.all"] == 3e-05
+        protocols = json.loads(Path(config.get("Paths", "prot_path")).read_text())
+        assert protocols["IDrest_0.3"]["type"] == "StepProtocol"
+
+
+def test_get_access_point_type():
+    """Test that only the local and nexus access points are supported."""
+    with pytest.raises(ValueError, match="not supported"):
+        get_access_point("remote", "cADpyr_L4UPC")
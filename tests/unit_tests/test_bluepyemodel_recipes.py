@@ -0,0 +1,239 @@
+"""Unit tests for bluepyemodel_recipes.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import shutil
+from pathlib import Path
+
+import pytest
+
+from emodelrunner.bluepyemodel_recipes import (
+    convert_fitness_calculator_configuration,
+    convert_recipe,
+    write_recipe_package,
+)
+from emodelrunner.configuration import PackageType
+from emodelrunner.load import get_morph_path, load_config
+from emodelrunner.protocols.create_protocols import create_protocols_object
+from tests.utils import cwd
+
+sscx_sample_dir = Path("examples") / "sscx_sample_dir"
+
+
+def get_efeature(protocol_name, feature_name, mean, std=0.1):
+    """Return an efeature of a fitness calculator configuration."""
+    return {
+        "efel_feature_name": feature_name,
+        "protocol_name": protocol_name,
+        "recording_name": "soma.v",
+        "mean": mean,
+        "original_std": std,
+        "efel_settings": {"strict_stiminterval": True, "Threshold": -20.0},
+    }
+
+
+def get_fitness_calculator_configuration(protocol_type="ThresholdBasedProtocol"):
+    """Return a fitness calculator configuration with a step protocol."""
+    return {
+        "protocols": [
+            {
+                "name": "IDrest_150",
+                "stimuli": [
+                    {
+                        "delay": 700.0,
+                        "amp": 0.3,
+                        "thresh_perc": 150.0,
+                        "duration": 2000.0,
+                        "totduration": 3000.0,
+                        "holding_current": -0.09,
+                    }
+                ],
+                "recordings": [
+                    {
+                        "type": "CompRecording",
+                        "location": "soma",
+                        "name": "IDrest_150.soma.v",
+                        "variable": "v",
+                    },
+                    {
+                        "type": "somadistanceapic",
+                        "somadistance": 200,
+                        "seclist_name": "apical",
+                        "name": "dend1",
+                        "variable": "v",
+                    },
+                ],
+                "protocol_type": protocol_type,
+                "stochasticity": False,
+            }
+        ],
+        "efeatures": [
+            get_efeature("IDrest_150", "Spikecount", 12.0),
+            get_efeature("RMPProtocol", "voltage_base", -75.0),
+            get_efeature("RinProtocol", "ohmic_input_resistance_vb_ssse", 150.0),
+            get_efeature("SearchHoldingCurrent", "bpo_holding_current", -0.09),
+            get_efeature("SearchHoldingCurrent", "steady_state_voltage_stimint", -83.0),
+            get_efeature("SearchThresholdCurrent", "bpo_threshold_current", 0.2),
+            get_efeature("Unknown", "Spikecount", 1.0),
+        ],
+    }
+
+
+def test_convert_threshold_based_protocols(tmp_path):
+    """Test that threshold based protocols are run by a Main protocol."""
+    protocols, features = convert_fitness_calculator_configuration(
+        get_fitness_calculator_configuration()
+    )
+
+    assert protocols["Main"]["other_protocols"] == ["IDrest_150"]
+    assert protocols["IDrest_150"]["type"] == "StepThresholdProtocol"
+    assert protocols["IDrest_150"]["stimuli"]["step"]["thresh_perc"] == 150.0
+    assert protocols["IDrest_150"]["stimuli"]["step"]["stochkv_det"] is True
+    assert protocols["IDrest_150"]["extra_recordings"] == [
+        {
+            "type": "somadistanceapic",
+            "somadistance": 200,
+            "seclist_name": "apical",
+            "name": "dend1",
+            "var": "v",
+        }
+    ]
+
+    assert "Unknown" not in features
+    assert features["IDrest_150"]["soma.v"] == [
+        {
+            "feature": "Spikecount",
+            "val": [12.0, 0.1],
+            "strict_stim": True,
+            "threshold": -20.0,
+        }
+    ]
+    # the holding voltage is the voltage base of Rin
+    assert [feature["feature"] for feature in features["RinHoldCurrent"]["soma.v"]] == [
+        "bpo_holding_current"
+    ]
+    assert features["Rin"]["soma.v"][-1]["feature"] == "voltage_base"
+    assert features["Rin"]["soma.v"][-1]["val"][0] == -83.0
+
+    # the converted files are read by EModelRunner
+    protocols_path = tmp_path / "protocols.json"
+    features_path = tmp_path / "features.json"
+    protocols_path.write_text(json.dumps(protocols))
+    features_path.write_text(json.dumps(features))
+    sequence_protocol = create_protocols_object(
+        -1, protocols_path, PackageType.sscx, features_path, mtype="test"
+    )
+    assert "IDrest_150" in sequence_protocol.protocols[0].subprotocols()
+
+
+def test_convert_absolute_protocols():
+    """Test that protocols with absolute amplitudes do not need a Main protocol."""
+    protocols, features = convert_fitness_calculator_configuration(
+        get_fitness_calculator_configuration(protocol_type="Protocol")
+    )
+
+    assert list(protocols) == ["IDrest_150"]
+    assert protocols["IDrest_150"]["type"] == "StepProtocol"
+    assert protocols["IDrest_150"]["stimuli"]["step"]["amp"] == 0.3
+    assert protocols["IDrest_150"]["stimuli"]["holding"]["amp"] == -0.09
+    assert list(features) == ["IDrest_150"]
+
+
+def test_convert_errors():
+    """Test the errors on unsupported stimuli and missing Main protocol features."""
+    fitness_calculator_configuration = get_fitness_calculator_configuration()
+    fitness_calculator_configuration["protocols"][0]["stimuli"][0].pop("duration")
+    with pytest.raises(ValueError):
+        convert_fitness_calculator_configuration(fitness_calculator_configuration)
+    protocols, features = convert_fitness_calculator_configuration(
+        fitness_calculator_configuration, skip_unsupported=True
+    )
+    assert protocols == {}
+    assert features == {}
+
+    fitness_calculator_configuration = get_fitness_calculator_configuration()
+    fitness_calculator_configuration["efeatures"].pop(1)
+    with pytest.raises(ValueError, match="voltage_base feature of RMP"):
+        convert_fitness_calculator_configuration(fitness_calculator_configuration)
+
+
+def test_convert_recipe(tmp_path):
+    """Test that the files of a recipe are written in the EModelRunner directories."""
+    (tmp_path / "config").mkdir()
+    (tmp_path / "config" / "fcc.json").write_text(
+        json.dumps(get_fitness_calculator_configuration())
+    )
+    recipes_path = tmp_path / "config" / "recipes.json"
+    recipes_path.write_text(json.dumps({"L5PC": {"features": "config/fcc.json"}}))
+
+    protocols_path, features_path = convert_recipe(
+        recipes_path, "L5PC", tmp_path / "output", emodel_dir=tmp_path
+    )
+
+    assert protocols_path == tmp_path / "output" / "protocols" / "L5PC.json"
+    assert features_path == tmp_path / "output" / "features" / "L5PC.json"
+    assert "Main" in json.loads(protocols_path.read_text())
+
+    with pytest.raises(ValueError):
+        convert_recipe(recipes_path, "L6PC", tmp_path / "output", emodel_dir=tmp_path)
+
+
+def test_write_recipe_package(tmp_path):
+    """Test that the package written from a recipe has a valid config."""
+    emodel_dir = tmp_path / "emodel"
+    (emodel_dir / "config").mkdir(parents=True)
+    (emodel_dir / "config" / "fcc.json").write_text(
+        json.dumps(get_fitness_calculator_configuration())
+    )
+    shutil.copytree(sscx_sample_dir / "morphology", emodel_dir / "morphologies")
+    morph_name = next((emodel_dir / "morphologies").glob("*.asc")).name
+    shutil.copy(sscx_sample_dir / "config" / "params" / "pyr.json", emodel_dir)
+    shutil.copy(sscx_sample_dir / "config" / "params" / "final.json", emodel_dir)
+    recipes_path = emodel_dir / "recipes.json"
+    recipes_path.write_text(
+        json.dumps(
+            {
+                "cADpyr_L4UPC": {
+                    "morph_path": "morphologies",
+                    "morphology": [["L4UPC", morph_name]],
+                    "params": "pyr.json",
+                    "features": "config/fcc.json",
+                }
+            }
+        )
+    )
+
+    output_dir = tmp_path / "package"
+    config_path = write_recipe_package(
+        recipes_path,
+        "cADpyr_L4UPC",
+        output_dir,
+        emodel_dir=emodel_dir,
+        template_dir=sscx_sample_dir.resolve(),
+    )
+
+    assert config_path == output_dir / "config" / "config_cADpyr_L4UPC.ini"
+    with cwd(output_dir):
+        config = load_config(config_path=config_path)
+        assert config.get("Cell", "emodel") == "cADpyr_L4UPC"
+        assert config.get("Morphology", "mtype") == "L4UPC"
+        assert Path(get_morph_path(config)).name == morph_name
+        assert "Main" in json.loads(Path(config.get("Paths", "prot_path")).read_text())
+
+    # the morphology of the recipe is needed
+    recipes_path.write_text(json.dumps({"cADpyr_L4UPC": {"params": "pyr.json"}}))
+    with pytest.raises(ValueError, match="no morphology"):
+        write_recipe_package(recipes_path, "cADpyr_L4UPC", output_dir, emodel_dir)
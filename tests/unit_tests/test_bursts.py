@@ -0,0 +1,87 @@
+"""Unit tests for bursts.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import numpy as np
+import pytest
+
+from emodelrunner.bursts import compute_burst_features, get_burst_features
+
+time = np.arange(0, 900, 0.1)
+
+
+def make_trace(spike_times):
+    """Return a trace hyperpolarized from 100 to 600 ms, with 1 ms spikes."""
+    voltage = np.full(time.shape, -70.0)
+    voltage[(time >= 100) & (time < 600)] = -90.0
+    for spike_time in spike_times:
+        voltage[(time >= spike_time) & (time < spike_time + 1.0)] = 20.0
+    return voltage
+
+
+def test_get_burst_features():
+    """Test the features of a burst followed by a tonic spike."""
+    voltage = make_trace([610.0, 614.0, 618.0, 622.0, 660.0])
+    features = get_burst_features(time, voltage, 100.0, 600.0, 700.0)
+    assert features["hyperpolarized_voltage"] == pytest.approx(-90.0)
+    assert features["n_spikes"] == 5
+    assert features["burst_latency"] == pytest.approx(10.0)
+    assert features["burst_n_spikes"] == 4
+    assert features["burst_duration"] == pytest.approx(12.0)
+    assert features["intraburst_frequency"] == pytest.approx(250.0)
+
+    # single spike and spikes outside of the window
+    features = get_burst_features(time, make_trace([50.0, 650.0]), 100.0, 600.0, 700.0)
+    assert features["n_spikes"] == 1
+    assert features["burst_n_spikes"] == 1
+    assert features["burst_duration"] is None
+    assert features["intraburst_frequency"] is None
+
+    features = get_burst_features(time, make_trace([]), 100.0, 600.0, 700.0)
+    assert features["n_spikes"] == 0
+    assert features["burst_latency"] is None
+    assert features["burst_n_spikes"] is None
+
+
+def test_compute_burst_features():
+    """Test the burst features of each burst protocol."""
+    responses = {
+        "VPL_TC.LTB.soma.v": {
+            "time": time,
+            "voltage": make_trace([605.0, 608.0, 611.0]),
+        },
+    }
+    burst_protocols = {
+        "LTB": {
+            "voltage_key": "VPL_TC.LTB.soma.v",
+            "type": "low_threshold",
+            "hyperpolarization_start": 100.0,
+            "release_time": 600.0,
+            "window_end": 700.0,
+        },
+        "Rebound": {
+            "voltage_key": "VPL_TC.Rebound.soma.v",
+            "type": "rebound",
+            "hyperpolarization_start": 100.0,
+            "release_time": 600.0,
+            "window_end": 700.0,
+        },
+    }
+    bursts = compute_burst_features(responses, burst_protocols)
+    assert list(bursts) == ["LTB"]
+    assert bursts["LTB"]["type"] == "low_threshold"
+    assert bursts["LTB"]["burst_n_spikes"] == 3
+    assert bursts["LTB"]["burst_latency"] == pytest.approx(5.0)
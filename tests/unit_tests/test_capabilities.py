@@ -0,0 +1,108 @@
+"""Unit tests for capabilities.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+from pathlib import Path
+
+import pytest
+
+from emodelrunner.capabilities import (
+    get_capabilities,
+    get_package_types,
+    get_protocol_types,
+    get_recording_types,
+    get_stimulus_types,
+)
+from emodelrunner.protocols.reader import ProtocolParser
+
+protocol_files = {
+    "sscx": list(Path("examples/sscx_sample_dir/config/protocols").glob("*.json")),
+    "thalamus": list(
+        Path("examples/thalamus_sample_dir/config/protocols").glob("*.json")
+    ),
+}
+
+
+def check_required_parameters(definition, parameters):
+    """Check that a definition contains the required parameters."""
+    for name, parameter in parameters.items():
+        if parameter["required"]:
+            assert name in definition
+
+
+def test_get_package_types():
+    """Test that the package types are listed."""
+    assert get_package_types() == ["sscx", "thalamus", "synplas", "hippocampus"]
+
+    with pytest.raises(ValueError):
+        get_protocol_types("unknown")
+
+
+@pytest.mark.parametrize("package_type", ["sscx", "thalamus"])
+def test_example_protocols(package_type):
+    """Test that the protocols of the examples match the described types."""
+    protocol_types = get_protocol_types(package_type)
+    stimulus_types = get_stimulus_types()
+    recording_types = get_recording_types(package_type)
+
+    for protocol_file in protocol_files[package_type]:
+        protocol_definitions = ProtocolParser.load_protocol_json(protocol_file)
+        for protocol_definition in protocol_definitions.values():
+            if "type" not in protocol_definition:
+                continue
+            protocol_type = protocol_types[protocol_definition["type"]]
+            check_required_parameters(
+                protocol_definition, protocol_type["parameters"]
+            )
+
+            for entry in protocol_type["stimuli"]:
+                if entry["key"] is None:
+                    stimulus_definitions = protocol_definition["stimuli"]
+                else:
+                    stimulus_definitions = protocol_definition["stimuli"].get(
+                        entry["key"]
+                    )
+                if stimulus_definitions is None:
+                    assert not entry["required"]
+                    continue
+                if not isinstance(stimulus_definitions, list):
+                    stimulus_definitions = [stimulus_definitions]
+                for stimulus_definition in stimulus_definitions:
+                    check_required_parameters(
+                        stimulus_definition,
+                        stimulus_types[entry["stimulus_type"]]["parameters"],
+                    )
+
+            for recording_definition in protocol_definition.get(
+                "extra_recordings", []
+            ):
+                check_required_parameters(
+                    recording_definition,
+                    recording_types[recording_definition["type"]]["parameters"],
+                )
+
+
+def test_get_capabilities():
+    """Test that the capabilities can be dumped to json and are not modified by users."""
+    capabilities = get_capabilities()
+    assert json.loads(json.dumps(capabilities)) == capabilities
+
+    assert "RampProtocol" not in get_protocol_types("thalamus")
+    assert set(capabilities["config_schemas"]) == set(get_package_types())
+    assert get_recording_types("thalamus") == {}
+
+    capabilities["stimulus_types"]["step"]["parameters"].clear()
+    assert get_stimulus_types()["step"]["parameters"]
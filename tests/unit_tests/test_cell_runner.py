@@ -0,0 +1,89 @@
+"""Unit tests for cell_runner.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import os
+
+import numpy as np
+import pytest
+
+from emodelrunner.cell_runner import (
+    CellRunner,
+    ChirpProtocol,
+    NoiseProtocol,
+    RampProtocol,
+    StepProtocol,
+    find_single_file,
+)
+
+example_dir = os.path.join("examples", "sscx_sample_dir")
+
+
+def test_protocol_definitions():
+    """Test that the python protocols give definitions of the protocols file."""
+    step = StepProtocol(amp=0.2, delay=100, duration=500, holding=-0.1)
+    assert step.definition() == {
+        "type": "StepProtocol",
+        "stimuli": {
+            "step": {"delay": 100, "duration": 500, "totduration": 800, "amp": 0.2},
+            "holding": {"amp": -0.1, "delay": 0.0, "duration": 800, "totduration": 800},
+        },
+    }
+
+    definition = RampProtocol(0.1, 0.5, delay=50, duration=1000).definition()
+    assert definition["stimuli"]["ramp"] == {
+        "ramp_delay": 50,
+        "ramp_duration": 1000,
+        "totduration": 1250,
+        "ramp_amplitude_start": 0.1,
+        "ramp_amplitude_end": 0.5,
+    }
+    assert "holding" not in definition["stimuli"]
+
+    definition = ChirpProtocol(0.05, 0.5, 20, delay=0, duration=10000).definition()
+    assert definition["stimuli"]["chirp"]["freq_end"] == 20
+
+    definition = NoiseProtocol(0.1, 0.05, 5, delay=0, duration=100).definition()
+    assert "seed" not in definition["stimuli"]["noise"]
+
+
+def test_find_single_file(tmp_path):
+    """Test that the only file with a suffix is found."""
+    (tmp_path / "final.json").touch()
+    (tmp_path / "pyr.json").touch()
+    assert find_single_file(tmp_path, [".json"], exclude=["final.json"]).name == (
+        "pyr.json"
+    )
+    with pytest.raises(ValueError):
+        find_single_file(tmp_path, [".json"])
+
+
+def test_cell_runner():
+    """Test a step protocol run without config file."""
+    runner = CellRunner(example_dir, mtype="L4_UPC")
+    assert runner.emodel == "cADpyr_L4UPC"
+
+    recordings = runner.run(
+        StepProtocol(amp=0.5, delay=20, duration=100, total_duration=150)
+    )
+
+    voltage = recordings["L4_UPC.Step.soma.v"]
+    assert isinstance(voltage["time"], np.ndarray)
+    assert voltage["time"][-1] == pytest.approx(150)
+    # the cell spikes during the step
+    assert np.max(voltage["voltage"]) > 0
+
+    current = recordings["current_L4_UPC.Step"]
+    assert np.max(current["current"]) == pytest.approx(0.5)
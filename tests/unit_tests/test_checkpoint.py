@@ -0,0 +1,64 @@
+"""Unit tests for checkpoint.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+from types import SimpleNamespace
+
+from emodelrunner.checkpoint import (
+    CHECKPOINT_INFO_FILE,
+    get_checkpoint_times,
+    get_recording_vectors,
+    load_checkpoint_info,
+    remove_checkpoint,
+)
+
+
+def test_get_checkpoint_times():
+    """Test that the checkpoints are saved before the end of the run only."""
+    assert get_checkpoint_times(1000.0, 300.0) == [300.0, 600.0, 900.0]
+    assert get_checkpoint_times(900.0, 300.0) == [300.0, 600.0]
+    assert get_checkpoint_times(1000.0, 0) == []
+
+
+def test_get_recording_vectors():
+    """Test that each vector of the recordings has its own key."""
+    recordings = [
+        SimpleNamespace(tvector="t0", varvector="v0"),
+        SimpleNamespace(tvector="t1", varvectors=["s0", "s1"]),
+        SimpleNamespace(tvector=None, varvector=None),
+    ]
+    assert get_recording_vectors(recordings) == {
+        "0.tvector": "t0",
+        "0.varvector": "v0",
+        "1.tvector": "t1",
+        "1.varvectors.0": "s0",
+        "1.varvectors.1": "s1",
+    }
+
+
+def test_load_checkpoint_info(tmp_path):
+    """Test that there is no info without checkpoint."""
+    checkpoint_dir = tmp_path / "checkpoint"
+    assert load_checkpoint_info(checkpoint_dir) is None
+
+    checkpoint_dir.mkdir()
+    info = {"time": 300.0, "fastforwarded": False}
+    (checkpoint_dir / CHECKPOINT_INFO_FILE).write_text(json.dumps(info))
+    assert load_checkpoint_info(checkpoint_dir) == info
+
+    remove_checkpoint(checkpoint_dir)
+    assert not checkpoint_dir.exists()
+    remove_checkpoint(checkpoint_dir)
@@ -0,0 +1,146 @@
+"""Unit tests for cli.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import os
+
+import pytest
+
+from emodelrunner.cli import main
+from tests.utils import cwd
+
+example_dir = os.path.join("examples", "sscx_sample_dir")
+config_path = "config/config_allsteps.ini"
+
+
+def test_invalid_arguments():
+    """Test that argparse exits with code 2 on unknown subcommands and missing arguments."""
+    with pytest.raises(SystemExit) as exc_info:
+        main(["unknown-command"])
+    assert exc_info.value.code == 2
+
+    with pytest.raises(SystemExit) as exc_info:
+        main(["run"])
+    assert exc_info.value.code == 2
+
+
+def test_validate_config(capsys):
+    """Test the validate-config subcommand."""
+    with cwd(example_dir):
+        assert main(["validate-config", "--config_path", config_path]) == 0
+        assert main(["validate-config", "--config_path", "config/missing.ini"]) == 1
+
+    assert "config/config_allsteps.ini is valid." in capsys.readouterr().out
+
+    with cwd(example_dir):
+        args = ["validate-config", "--config_path", config_path]
+        assert main(args + ["--set", "Cell.celsius=36", "--set", "Sim.dt=0.1"]) == 0
+        assert main(args + ["--set", "Sim.dt=small"]) == 1
+        assert main(args + ["--set", "dt=0.1"]) == 1
+
+
+def test_validate(capsys, tmp_path):
+    """Test the validate subcommand."""
+    with cwd(example_dir):
+        assert main(["validate", "--config_path", config_path]) == 0
+        args = ["validate", "--config_path", config_path, "--mechanisms_dir"]
+        assert main(args + [str(tmp_path)]) == 1
+
+    assert "config/config_allsteps.ini is valid." in capsys.readouterr().out
+
+
+def test_list_protocols(capsys):
+    """Test the list-protocols subcommand."""
+    with cwd(example_dir):
+        assert main(["list-protocols", "--config_path", config_path]) == 0
+
+    assert capsys.readouterr().out.splitlines() == [
+        "Step_150\tStepProtocol",
+        "Step_200\tStepProtocol",
+        "Step_250\tStepProtocol",
+    ]
+
+
+def test_package_type_errors():
+    """Test that the subcommands fail with exit code 1 on the wrong package type."""
+    thalamus_config_path = "config/config_recipe_prots_short.ini"
+    with cwd(os.path.join("examples", "thalamus_sample_dir")):
+        assert main(["run-pairsim", "--config_path", thalamus_config_path]) == 1
+
+    synplas_config_path = "config/config_1Hz_10ms.ini"
+    with cwd(os.path.join("examples", "synplas_sample_dir")):
+        assert main(["run-arbor", "--config_path", synplas_config_path]) == 1
+
+
+def test_sweep_errors(tmp_path):
+    """Test that the sweep subcommand fails with exit code 1 on invalid sweep files."""
+    sweep_path = tmp_path / "sweep.json"
+    sweep_path.write_text('{"mode": "random", "parameters": {"Cell.celsius": [30]}}')
+    args = ["sweep", "--config_path", config_path, "--output_dir", str(tmp_path)]
+
+    with cwd(example_dir):
+        assert main(args + ["--sweep_path", "missing.json"]) == 1
+        assert main(args + ["--sweep_path", str(sweep_path)]) == 1
+
+
+def test_factsheet_without_run(tmp_path):
+    """Test that the factsheet subcommand fails if the protocol has not been run."""
+    with cwd(example_dir):
+        exit_code = main(
+            [
+                "factsheet",
+                "--config_path",
+                "config/config_factsheets.ini",
+                "--protocol_key",
+                "NotRun",
+                "--output_dir",
+                str(tmp_path),
+            ]
+        )
+
+    assert exit_code == 1
+    assert not list(tmp_path.iterdir())
+
+
+def test_plot_without_outputs(tmp_path):
+    """Test that the plot subcommand fails if there is no output to plot."""
+    assert main(["plot", str(tmp_path)]) == 1
+    assert main(["plot", str(tmp_path / "missing")]) == 1
+
+
+def test_capabilities(capsys):
+    """Test that the capabilities subcommand prints json."""
+    assert main(["capabilities"]) == 0
+
+    capabilities = json.loads(capsys.readouterr().out)
+    assert "StepProtocol" in capabilities["protocol_types"]
+
+
+def test_regression_without_reference(tmp_path):
+    """Test that the regression fails with code 1 if there is no reference output."""
+    with cwd(example_dir):
+        assert (
+            main(
+                [
+                    "regression",
+                    "--config_path",
+                    config_path,
+                    "--reference_dir",
+                    str(tmp_path),
+                ]
+            )
+            == 1
+        )
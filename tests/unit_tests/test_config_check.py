@@ -0,0 +1,144 @@
+"""Unit tests for config_check.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import json
+import os
+
+import pytest
+
+from emodelrunner.config_check import (
+    check_emodel_params,
+    check_mechanisms,
+    check_protocols,
+    check_synapses,
+    validate_config,
+)
+from emodelrunner.configuration import InvalidConfigError
+from emodelrunner.load import load_config
+from tests.utils import cwd
+
+example_dir = os.path.join("examples", "sscx_sample_dir")
+config_path = "config/config_allsteps.ini"
+
+
+def test_validate_example_configs():
+    """Test that the files of the example configs are consistent."""
+    with cwd(example_dir):
+        validate_config(config_path)
+        validate_config("config/config_synapses.ini")
+        validate_config("config/config_multiprotocols.ini")
+
+    with cwd(os.path.join("examples", "thalamus_sample_dir")):
+        validate_config("config/config_recipe_prots_short.ini")
+
+    with cwd(os.path.join("examples", "synplas_sample_dir")):
+        validate_config("config/config_1Hz_10ms.ini")
+
+
+def test_check_emodel_params():
+    """Test that a missing e-model is reported with the available ones."""
+    with cwd(example_dir):
+        config = load_config(config_path, ["Cell.emodel=missing"])
+    errors = check_emodel_params(config)
+    assert len(errors) == 1
+    assert "Cell.emodel: no parameters for 'missing'" in errors[0]
+    assert "Available e-models: cADpyr_L4UPC" in errors[0]
+
+
+def test_check_mechanisms(tmp_path):
+    """Test that the mechanisms missing from the mod files are reported."""
+    with cwd(example_dir):
+        config = load_config(config_path)
+        assert check_mechanisms(config, "mechanisms") == []
+
+        errors = check_mechanisms(config, tmp_path)
+        assert len(errors) == 1
+        assert "No mod file found" in errors[0]
+
+        (tmp_path / "NaTg.mod").write_text("NEURON {\n    SUFFIX NaTg\n}\n")
+        errors = check_mechanisms(config, tmp_path)
+
+    assert (
+        "Paths.unoptimized_params_path: mechanism 'Ih' of somadend "
+        f"is not defined in the mod files of {tmp_path}"
+    ) in errors
+    assert not any("'NaTg'" in error or "'pas'" in error for error in errors)
+
+
+def test_check_protocols(tmp_path):
+    """Test that the unsupported types and unknown Main protocols are reported."""
+    prot_path = tmp_path / "protocols.json"
+    with open(prot_path, "w", encoding="utf-8") as prot_file:
+        json.dump(
+            {
+                "Main": {
+                    "type": "RatSSCxMainProtocol",
+                    "pre_protocols": [],
+                    "other_protocols": ["Step_150", "missing"],
+                },
+                "Step_150": {"type": "StepThresholdProtocol"},
+                "Pulse": {"type": "UnknownProtocol"},
+            },
+            prot_file,
+        )
+
+    with cwd(example_dir):
+        config = load_config(config_path, [f"Paths.prot_path={prot_path}"])
+    errors = check_protocols(config)
+
+    assert len(errors) == 2
+    assert "type 'UnknownProtocol' is not supported by sscx packages" in errors[0]
+    assert "other_protocols refers to 'missing'" in errors[1]
+
+    with open(prot_path, "w", encoding="utf-8") as prot_file:
+        json.dump({"Step_150": {"type": "StepThresholdProtocol"}}, prot_file)
+    errors = check_protocols(config)
+    assert len(errors) == 1
+    assert "needs a protocol named Main" in errors[0]
+
+
+def test_check_synapses():
+    """Test that the missing synapse files are only reported with synapses."""
+    with cwd(example_dir):
+        config = load_config(
+            "config/config_synapses.ini", ["Paths.syn_conf_file=missing.txt"]
+        )
+        errors = check_synapses(config)
+        assert len(errors) == 1
+        assert errors[0].startswith("Paths.syn_conf_file: ")
+
+        config = load_config(config_path, ["Paths.syn_conf_file=missing.txt"])
+        assert check_synapses(config) == []
+
+
+def test_validate_config_lists_all_errors(tmp_path):
+    """Test that all the problems are listed in the error."""
+    with cwd(example_dir):
+        with pytest.raises(InvalidConfigError) as exc_info:
+            validate_config(
+                config_path, ["Cell.emodel=missing"], mechanisms_dir=tmp_path
+            )
+
+    assert len(exc_info.value.config_errors) == 2
+    assert "No mod file found" in str(exc_info.value)
+
+
+def test_validate_config_instantiate():
+    """Test the instantiation of the cell without running it."""
+    with cwd(example_dir):
+        config = validate_config(config_path, instantiate=True)
+    assert config.get("Cell", "emodel") == "cADpyr_L4UPC"
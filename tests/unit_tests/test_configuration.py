@@ -14,6 +14,8 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
+import configparser
+import json
 from pathlib import Path
 import pytest
 from schema import SchemaError
@@ -21,12 +23,16 @@ from schema import SchemaError
 from tests.utils import cwd
 from emodelrunner.configuration import (
     ConfigValidator,
+    HippocampusConfigValidator,
+    InvalidConfigError,
     SSCXConfigValidator,
     SynplasConfigValidator,
     ThalamusConfigValidator,
     PackageType,
+    get_config_schema,
     get_validated_config,
 )
+from emodelrunner.configuration.validator import parse_config_overrides
 
 sscx_sample_dir = Path("examples") / "sscx_sample_dir"
 synplas_sample_dir = Path("examples") / "synplas_sample_dir"
@@ -50,6 +56,14 @@ def test_int_expression():
     assert not ConfigValidator.int_expression("10.1")
 
 
+def test_positive_int_expression():
+    """Test to check strictly positive integer literals evaluate correctly."""
+    assert ConfigValidator.positive_int_expression("1")
+    assert ConfigValidator.positive_int_expression("10")
+    assert not ConfigValidator.positive_int_expression("0")
+    assert not ConfigValidator.positive_int_expression("2.0")
+
+
 def test_float_expression():
     """Test to check float literals evaluate correctly."""
     assert ConfigValidator.float_or_int_expression("0.0")
@@ -65,6 +79,76 @@ def test_list_of_nonempty_str():
     assert not ConfigValidator.list_of_nonempty_str('[""]')
 
 
+def test_section_location():
+    """Test to check section locations evaluate correctly."""
+    assert ConfigValidator.section_location("dend[3](0.5)")
+    assert ConfigValidator.section_location("soma[0](0.5)")
+    assert not ConfigValidator.section_location("dend[3]")
+    assert not ConfigValidator.section_location("")
+
+
+def test_list_of_section_locations():
+    """Test to check lists of section locations evaluate correctly."""
+    assert ConfigValidator.list_of_section_locations('["dend[3](0.5)", "axon[0](1)"]')
+    assert ConfigValidator.list_of_section_locations("[]")
+    assert not ConfigValidator.list_of_section_locations('["dend[3]"]')
+    assert not ConfigValidator.list_of_section_locations('["dend"]')
+
+
+def test_recording_variables():
+    """Test to check the recorded range variables evaluate correctly."""
+    assert ConfigValidator.list_of_recording_variables('["v", "cai", "NaTg.m"]')
+    assert ConfigValidator.list_of_recording_variables('["m_NaTg"]')
+    assert not ConfigValidator.list_of_recording_variables('["NaTg.m.h"]')
+    assert not ConfigValidator.list_of_recording_variables('["ca i"]')
+    assert ConfigValidator.recording_variables_by_location(
+        '{"dend[3](0.5)": ["cai", "CaDynamics_DC0.gamma"], "soma[0](0.5)": ["nai"]}'
+    )
+    assert ConfigValidator.recording_variables_by_location("{}")
+    assert not ConfigValidator.recording_variables_by_location('{"dend": ["cai"]}')
+    assert not ConfigValidator.recording_variables_by_location(
+        '{"dend[3](0.5)": "cai"}'
+    )
+
+
+def test_list_of_positions():
+    """Test to check lists of positions evaluate correctly."""
+    assert ConfigValidator.list_of_positions("[[0, 50, 0], [10.5, 0, -20]]")
+    assert ConfigValidator.list_of_positions("[]")
+    assert not ConfigValidator.list_of_positions("[[0, 50]]")
+    assert not ConfigValidator.list_of_positions("[0, 50, 0]")
+
+
+def test_synapse_selection_rules():
+    """Test the rules of the synapse selection."""
+    assert ConfigValidator.list_of_ints("[1, 3]")
+    assert not ConfigValidator.list_of_ints('["L5_TPC"]')
+    assert ConfigValidator.list_of_section_types('["apical_dendrite", "soma"]')
+    assert not ConfigValidator.list_of_section_types('["apic"]')
+    assert ConfigValidator.distance_range("[]")
+    assert ConfigValidator.distance_range("[100, 250.5]")
+    assert not ConfigValidator.distance_range("[250, 100]")
+    assert not ConfigValidator.distance_range("[100]")
+
+
+def test_dict_of_numbers():
+    """Test that the dicts of numbers are parsed as json, as when running."""
+    assert ConfigValidator.dict_of_numbers('{"v": 0.1, "cai": 1e-3}')
+    assert ConfigValidator.dict_of_numbers("{}")
+    assert not ConfigValidator.dict_of_numbers('{"v": "0.1"}')
+    # not json
+    assert not ConfigValidator.dict_of_numbers("{'v': 0.1}")
+
+
+def test_channel_blocks():
+    """Test to check channel blocks evaluate correctly."""
+    assert ConfigValidator.channel_blocks("")
+    assert ConfigValidator.channel_blocks("gSKv3_1bar=0, gNaTgbar*0.5")
+    assert ConfigValidator.channel_blocks("gNaTgbar_NaTg.axonal = 1e-3")
+    assert not ConfigValidator.channel_blocks("gNaTgbar")
+    assert not ConfigValidator.channel_blocks("gNaTgbar/2")
+
+
 def test_missing_config():
     """Test the config loader."""
     config_path = Path("config") / "config_that_does_not_exist.ini"
@@ -120,6 +204,21 @@ def test_valid_thalamus_config():
             assert conf_obj.package_type == PackageType.thalamus
 
 
+def test_valid_hippocampus_config():
+    """Test that the hippocampus configs have the keys of the thalamus configs."""
+    with cwd(thalamus_sample_dir):
+        config_path = Path("config") / "config_recipe_prots_short.ini"
+        conf_obj = HippocampusConfigValidator().validate_from_file(
+            config_path, ["Package.type=hippocampus"]
+        )
+        assert conf_obj.package_type == PackageType.hippocampus
+
+    # the thalamus defaults are not modified
+    assert HippocampusConfigValidator.default_values["Cell"]["v_init"] == "-65"
+    assert ThalamusConfigValidator.default_values["Package"]["type"] == "thalamus"
+    assert ThalamusConfigValidator.default_values["Cell"]["v_init"] == "-80"
+
+
 def test_get_validated_config():
     """Test the get_validated_config function."""
     with cwd(sscx_sample_dir):
@@ -130,3 +229,134 @@ def test_get_validated_config():
     invalid_conf = Path("tests") / "static_files" / "invalid_config.ini"
     with pytest.raises(ValueError):
         get_validated_config(invalid_conf)
+
+
+def load_ini_as_dict(config_path):
+    """Return the sections of a .ini config as a dict, without interpolation."""
+    config = configparser.ConfigParser(interpolation=None)
+    config.read(config_path)
+    return {section: dict(config.items(section)) for section in config.sections()}
+
+
+def get_native_config_dict():
+    """Return the sscx singlestep config, with values that are not strings."""
+    config_dict = load_ini_as_dict(Path("config") / "config_singlestep.ini")
+    # the json and yaml values do not have to be strings
+    config_dict["Cell"]["celsius"] = 34
+    config_dict["Sim"]["cvode_active"] = False
+    config_dict["Recordings"] = {"locations": ["dend[3](0.5)"]}
+    return config_dict
+
+
+def check_native_config(config_path):
+    """Check the config written from get_native_config_dict."""
+    config = get_validated_config(config_path)
+    assert config.package_type == PackageType.sscx
+    assert config.getfloat("Cell", "celsius") == 34.0
+    assert not config.getboolean("Sim", "cvode_active")
+    assert json.loads(config.get("Recordings", "locations")) == ["dend[3](0.5)"]
+    assert config.get("Paths", "output_dir") == "./python_recordings"
+
+
+def test_json_config(tmp_path):
+    """Test that the json configs are validated like the .ini configs."""
+    with cwd(sscx_sample_dir):
+        config_path = tmp_path / "config.json"
+        with open(config_path, "w", encoding="utf-8") as config_file:
+            json.dump(get_native_config_dict(), config_file)
+
+        check_native_config(config_path)
+
+
+def test_yaml_config(tmp_path):
+    """Test that the yaml configs are validated like the .ini configs."""
+    yaml = pytest.importorskip("yaml")
+    with cwd(sscx_sample_dir):
+        config_path = tmp_path / "config.yaml"
+        with open(config_path, "w", encoding="utf-8") as config_file:
+            yaml.safe_dump(get_native_config_dict(), config_file)
+
+        check_native_config(config_path)
+
+
+def test_config_errors(tmp_path):
+    """Test that all the problems of a config are reported."""
+    with cwd(sscx_sample_dir):
+        config_dict = load_ini_as_dict(Path("config") / "config_singlestep.ini")
+        config_dict["Sim"]["dt"] = "small"
+        config_dict["Sim"]["unknown_key"] = "1"
+        config_dict["Unknown"] = {"key": "value"}
+        del config_dict["Cell"]["emodel"]
+
+        config_path = tmp_path / "config.json"
+        with open(config_path, "w", encoding="utf-8") as config_file:
+            json.dump(config_dict, config_file)
+
+        with pytest.raises(InvalidConfigError) as excinfo:
+            get_validated_config(config_path)
+
+    config_errors = excinfo.value.config_errors
+    assert len(config_errors) == 4
+    assert any("unknown section [Unknown]" in error for error in config_errors)
+    assert any("unknown key 'unknown_key'" in error for error in config_errors)
+    assert any("missing required key 'emodel'" in error for error in config_errors)
+    assert any(
+        "invalid value 'small' for key 'dt' in section [Sim], expected a number"
+        in error
+        for error in config_errors
+    )
+    assert str(config_path) in str(excinfo.value)
+
+    # the schema errors are still SchemaError
+    assert isinstance(excinfo.value, SchemaError)
+
+
+def test_get_config_schema():
+    """Test the description of the config sections."""
+    schema = get_config_schema("sscx")
+    assert json.loads(json.dumps(schema)) == schema
+
+    assert schema["Sim"]["dt"] == {
+        "expected": "a number",
+        "required": False,
+        "default": "0.025",
+    }
+    assert schema["Sim"]["simulator"]["expected"] == "one of 'neuron', 'coreneuron'"
+    assert schema["Cell"]["emodel"]["required"]
+    assert schema["Paths"]["morph_path"]["expected"] == "an existing path"
+
+    with pytest.raises(ValueError):
+        get_config_schema("unknown")
+
+
+def test_parse_config_overrides():
+    """Test the parsing of the config overrides."""
+    assert parse_config_overrides(None) == {}
+    assert parse_config_overrides(
+        ["Cell.celsius=36", "Sim.dt = 0.1", "Paths.output_dir=out=1"]
+    ) == {
+        "Cell": {"celsius": "36"},
+        "Sim": {"dt": "0.1"},
+        "Paths": {"output_dir": "out=1"},
+    }
+
+    for config_override in ["Cell.celsius", "celsius=36", ".celsius=36"]:
+        with pytest.raises(ValueError):
+            parse_config_overrides([config_override])
+
+
+def test_config_overrides():
+    """Test that the overrides replace the values of the config file."""
+    with cwd(sscx_sample_dir):
+        config_path = Path("config") / "config_allsteps.ini"
+        config = get_validated_config(
+            config_path, ["Cell.celsius=36", "Sim.cvode_active=True"]
+        )
+        assert config.getfloat("Cell", "celsius") == 36.0
+        assert config.getboolean("Sim", "cvode_active")
+
+        # the overrides are validated
+        with pytest.raises(InvalidConfigError):
+            get_validated_config(config_path, ["Cell.celsius=hot"])
+        with pytest.raises(InvalidConfigError):
+            get_validated_config(config_path, ["Cell.temperature=36"])
@@ -0,0 +1,86 @@
+"""Unit tests for environment.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+from pathlib import Path
+import shutil
+
+import pytest
+
+from emodelrunner.environment import (
+    get_cache_dir,
+    get_mechanism_names,
+    get_mechanisms_hash,
+    get_mod_files,
+    setup_environment,
+)
+
+mechanisms_dir = Path("examples") / "sscx_sample_dir" / "mechanisms"
+
+
+def test_get_cache_dir(monkeypatch, tmp_path):
+    """Test the priority of the cache directory settings."""
+    monkeypatch.setenv("XDG_CACHE_HOME", str(tmp_path / "xdg"))
+    monkeypatch.delenv("EMODELRUNNER_CACHE", raising=False)
+    assert get_cache_dir() == tmp_path / "xdg" / "emodelrunner"
+
+    monkeypatch.setenv("EMODELRUNNER_CACHE", str(tmp_path / "env"))
+    assert get_cache_dir() == tmp_path / "env"
+
+    assert get_cache_dir(tmp_path / "arg") == tmp_path / "arg"
+
+
+def test_get_mechanism_names():
+    """Test that the density mechanisms, point processes and artificial cells are found."""
+    names = get_mechanism_names(get_mod_files(mechanisms_dir))
+
+    assert "NaTg" in names
+    assert "ProbAMPANMDA_EMS" in names
+    assert "VecStim" in names
+
+    with pytest.raises(FileNotFoundError):
+        get_mod_files(mechanisms_dir.parent / "config")
+
+
+def test_get_mechanisms_hash(tmp_path):
+    """Test that the hash depends on the mod files and the NEURON version."""
+    mod_file = tmp_path / "test.mod"
+    mod_file.write_text("NEURON { SUFFIX test }")
+    mod_hash = get_mechanisms_hash([mod_file], "8.0")
+
+    assert get_mechanisms_hash([mod_file], "8.0") == mod_hash
+    assert get_mechanisms_hash([mod_file], "8.1") != mod_hash
+    assert get_mechanisms_hash([mod_file], "8.0", coreneuron=True) != mod_hash
+
+    mod_file.write_text("NEURON { SUFFIX test2 }")
+    assert get_mechanisms_hash([mod_file], "8.0") != mod_hash
+
+
+def test_setup_environment(tmp_path):
+    """Test that the mechanisms are compiled once and linked in each package."""
+    cache_dir = tmp_path / "cache"
+    package_dirs = [tmp_path / "package1", tmp_path / "package2"]
+    for package_dir in package_dirs:
+        (package_dir / "mechanisms").mkdir(parents=True)
+        shutil.copy(mechanisms_dir / "NaTg.mod", package_dir / "mechanisms")
+
+    links = [
+        setup_environment(package_dir, cache_dir=cache_dir)
+        for package_dir in package_dirs
+    ]
+
+    assert all(link.is_symlink() for link in links)
+    assert links[0].resolve() == links[1].resolve()
+    assert len(list((cache_dir / "mechanisms").iterdir())) == 1
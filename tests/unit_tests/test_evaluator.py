@@ -0,0 +1,57 @@
+"""Unit tests for evaluator.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import os
+
+from emodelrunner.evaluator import create_evaluator
+from emodelrunner.load import get_release_params, load_config
+from tests.utils import cwd
+
+example_dir = os.path.join("examples", "sscx_sample_dir")
+
+
+def test_create_evaluator():
+    """Test that the evaluator uses the main protocol and the optimized parameters."""
+    with cwd(example_dir):
+        config = load_config(config_path="config/config_recipe_protocols.ini")
+        evaluator = create_evaluator(config)
+
+        assert list(evaluator.fitness_protocols.keys()) == ["Main"]
+        assert sorted(evaluator.param_names) == sorted(get_release_params(config))
+
+    objective_names = [
+        objective.name for objective in evaluator.fitness_calculator.objectives
+    ]
+    assert "_.Step_200.soma.v.mean_frequency" in objective_names
+    assert "_.RMP.soma.v.voltage_base" in objective_names
+
+
+def test_create_evaluator_skip_missing_features():
+    """Test that only the features of the configured protocols are used."""
+    with cwd(example_dir):
+        config = load_config(config_path="config/config_allsteps.ini")
+        evaluator = create_evaluator(config)
+
+    assert sorted(evaluator.fitness_protocols.keys()) == [
+        "Step_150",
+        "Step_200",
+        "Step_250",
+    ]
+    protocol_names = {
+        objective.name.split(".")[1]
+        for objective in evaluator.fitness_calculator.objectives
+    }
+    assert protocol_names == {"Step_200"}
@@ -0,0 +1,108 @@
+"""Unit tests for extracellular.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import numpy as np
+import pytest
+
+from emodelrunner.extracellular import (
+    ExtracellularRecording,
+    interpolate_segment_points,
+    line_source_matrix,
+    point_source_matrix,
+    pop_extracellular_responses,
+)
+
+
+def test_interpolate_segment_points():
+    """Test that the segments split the section in equal lengths."""
+    points = np.array([[0.0, 0.0, 0.0], [10.0, 0.0, 0.0], [10.0, 20.0, 0.0]])
+    arc_lengths = np.array([0.0, 10.0, 30.0])
+
+    starts, ends = interpolate_segment_points(points, arc_lengths, 3)
+    np.testing.assert_allclose(starts, [[0, 0, 0], [10, 0, 0], [10, 10, 0]])
+    np.testing.assert_allclose(ends, [[10, 0, 0], [10, 10, 0], [10, 20, 0]])
+
+
+def test_source_matrices():
+    """Test the point-source and line-source transformation matrices."""
+    sigma = 0.3
+    starts = np.array([[-5.0, 0.0, 0.0]])
+    ends = np.array([[5.0, 0.0, 0.0]])
+    min_distances = np.array([1.0])
+
+    electrode_positions = np.array([[0.0, 100.0, 0.0]])
+    point_source = point_source_matrix(
+        electrode_positions, starts, ends, sigma, min_distances
+    )
+    np.testing.assert_allclose(point_source, [[1 / (4 * np.pi * sigma * 100)]])
+
+    # far from the segment, the line source is close to the point source
+    line_source = line_source_matrix(
+        electrode_positions, starts, ends, sigma, min_distances
+    )
+    np.testing.assert_allclose(line_source, point_source, rtol=1e-3)
+
+    # close to the middle of the segment
+    electrode_positions = np.array([[0.0, 2.0, 0.0]])
+    line_source = line_source_matrix(
+        electrode_positions, starts, ends, sigma, min_distances
+    )
+    expected = 2 * np.arcsinh(5 / 2) / (4 * np.pi * sigma * 10)
+    np.testing.assert_allclose(line_source, [[expected]])
+
+    # on the axis of the segment, the distance is the minimal distance
+    electrode_positions = np.array([[0.0, 0.0, 0.0]])
+    line_source = line_source_matrix(
+        electrode_positions, starts, ends, sigma, min_distances
+    )
+    expected = 2 * np.arcsinh(5) / (4 * np.pi * sigma * 10)
+    np.testing.assert_allclose(line_source, [[expected]])
+
+    # a segment of zero length is a point source
+    line_source = line_source_matrix(
+        np.array([[0.0, 10.0, 0.0]]), starts, starts, sigma, min_distances
+    )
+    expected = 1 / (4 * np.pi * sigma * np.hypot(5, 10))
+    np.testing.assert_allclose(line_source, [[expected]])
+
+
+def test_extracellular_recording():
+    """Test the construction of the extracellular recording."""
+    recording = ExtracellularRecording(
+        name="_.Step.extracellular", electrode_positions=[[0, 0, 50]]
+    )
+    assert recording.method == "linesource"
+    assert recording.response is None
+
+    with pytest.raises(ValueError):
+        ExtracellularRecording(
+            name="_.Step.extracellular",
+            electrode_positions=[[0, 0, 50]],
+            method="root_as_point",
+        )
+
+
+def test_pop_extracellular_responses():
+    """Test that the extracellular responses are removed from the responses."""
+    responses = {
+        "_.Step.soma.v": {"time": [0.0], "voltage": [-80.0]},
+        "_.Step.extracellular": {"time": [0.0], "potential": [[0.0]]},
+        "_.bpo_holding_current": 0.1,
+    }
+    extracellular = pop_extracellular_responses(responses)
+    assert list(extracellular) == ["_.Step.extracellular"]
+    assert list(responses) == ["_.Step.soma.v", "_.bpo_holding_current"]
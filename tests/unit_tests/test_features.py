@@ -0,0 +1,111 @@
+"""Unit tests for features.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+from pathlib import Path
+import numpy as np
+
+import pytest
+
+from emodelrunner.features import (
+    add_config_efeatures,
+    extract_protocols_efeatures,
+    get_protocols_efeatures,
+    load_stored_current,
+)
+from emodelrunner.protocols.reader import ProtocolParser
+
+prot_path = (
+    Path("examples") / "sscx_sample_dir" / "config" / "protocols" / "allsteps.json"
+)
+features_path = (
+    Path("examples") / "sscx_sample_dir" / "config" / "features" / "cADpyr_L4PC.json"
+)
+
+
+def test_get_protocols_efeatures():
+    """Test that the efeatures are read from the protocol definitions."""
+    protocol_definitions = ProtocolParser.load_protocol_json(prot_path)
+    protocols_efeatures = get_protocols_efeatures(protocol_definitions)
+
+    assert protocols_efeatures == {
+        "Step_150": ["Spikecount", "mean_frequency", "time_to_first_spike"]
+    }
+
+    with pytest.raises(ValueError):
+        get_protocols_efeatures({"Step": {"efeatures": "Spikecount"}})
+
+
+def test_load_stored_current():
+    """Test that the threshold and holding currents are read from the features file."""
+    assert load_stored_current(features_path) == 0.0887
+    assert load_stored_current(features_path, "bpo_holding_current") == -0.0538
+    assert load_stored_current(features_path, "unknown_current") is None
+
+
+def test_add_config_efeatures():
+    """Test that the features of the config are added to every somatic response."""
+    responses = {
+        "_.Step_150.soma.v": {},
+        "_.Step_200.soma.v": {},
+        "_.Step_200.dend3_x0p5.v": {},
+        "_.Step_250.soma.v": None,
+        "_.bpo_holding_current": -0.1,
+    }
+    protocols_efeatures = {"Step_150": ["Spikecount", "mean_frequency"]}
+
+    assert add_config_efeatures(
+        protocols_efeatures, ["Spikecount", "ISI_CV"], responses, prefix="_"
+    ) == {
+        "Step_150": ["Spikecount", "mean_frequency", "ISI_CV"],
+        "Step_200": ["Spikecount", "ISI_CV"],
+    }
+    assert protocols_efeatures == {"Step_150": ["Spikecount", "mean_frequency"]}
+    assert add_config_efeatures({}, [], responses, prefix="_") == {}
+
+
+def test_extract_protocols_efeatures():
+    """Test that only the features attached to a protocol are extracted."""
+    time = np.arange(0, 300, 0.1)
+    voltage = np.full_like(time, -80.0)
+    for spike_time in [100, 150, 200]:
+        voltage[(time >= spike_time) & (time < spike_time + 1)] = 20.0
+    responses = {
+        "_.Step_150.soma.v": {"time": time, "voltage": voltage},
+        "_.Step_200.soma.v": {"time": time, "voltage": voltage},
+    }
+
+    efeatures = extract_protocols_efeatures(
+        responses,
+        {"Step_150": ["Spikecount"], "Step_250": ["Spikecount"]},
+        {"Step_150": (70.0, 270.0)},
+        prefix="_",
+    )
+
+    assert list(efeatures.keys()) == ["Step_150"]
+    assert efeatures["Step_150"] == {"Spikecount": [3]}
+
+    # input resistance from the stimulus current
+    voltage = np.where((time >= 70) & (time < 270), -90.0, -80.0)
+    efeatures = extract_protocols_efeatures(
+        {"_.Step_m10.soma.v": {"time": time, "voltage": voltage}},
+        {"Step_m10": ["ohmic_input_resistance_vb_ssse"]},
+        {"Step_m10": (70.0, 270.0)},
+        prefix="_",
+        stim_amplitudes={"Step_m10": -0.1},
+    )
+    assert efeatures["Step_m10"]["ohmic_input_resistance_vb_ssse"] == pytest.approx(
+        [100.0]
+    )
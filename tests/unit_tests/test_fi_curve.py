@@ -0,0 +1,107 @@
+"""Unit tests for fi_curve.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+
+import numpy as np
+import pytest
+
+from emodelrunner.fi_curve import (
+    compute_fi_curves,
+    fit_fi_curve,
+    get_firing_rate,
+    get_fi_curve,
+    write_fi_curves,
+)
+
+
+def make_trace(n_spikes):
+    """Return a trace spiking regularly n_spikes times during the 800 ms stimulus."""
+    time = np.arange(0, 1000, 0.1)
+    voltage = np.full(time.shape, -80.0)
+    for spike_time in np.linspace(100, 900, n_spikes, endpoint=False):
+        voltage[(time >= spike_time + 5) & (time < spike_time + 6)] = 20.0
+    return {"time": time, "voltage": voltage}
+
+
+step_amplitudes = {
+    "FICurve_0": 0.0,
+    "FICurve_1": 0.1,
+    "FICurve_2": 0.2,
+    "FICurve_3": 0.3,
+    "FICurve_4": 0.4,
+}
+responses = {
+    f"L5_TPC.FICurve_{i}.soma.v": make_trace(n_spikes)
+    for i, n_spikes in enumerate([0, 0, 8, 16, 24])
+}
+responses["L5_TPC.FICurve_2.dend1.v"] = make_trace(0)
+stim_windows = {name: (100.0, 900.0) for name in step_amplitudes}
+
+
+def test_get_firing_rate():
+    """Test that only the spikes during the stimulus are counted."""
+    trace = make_trace(8)
+    assert get_firing_rate(trace["time"], trace["voltage"], 100, 900) == (8, 10.0)
+    assert get_firing_rate(trace["time"], trace["voltage"], 100, 300) == (2, 10.0)
+
+    # the whole trace is used without stimulus window
+    spike_count, firing_rate = get_firing_rate(trace["time"], trace["voltage"])
+    assert spike_count == 8
+    assert firing_rate == pytest.approx(8000.0 / 999.9)
+
+
+def test_fit_fi_curve():
+    """Test that the line is fitted to the suprathreshold steps only."""
+    fit = fit_fi_curve([0.0, 0.1, 0.2, 0.3, 0.4], [0.0, 0.0, 10.0, 20.0, 30.0])
+    assert fit["gain"] == pytest.approx(100.0)
+    assert fit["intercept"] == pytest.approx(-10.0)
+    assert fit["rheobase"] == pytest.approx(0.1)
+    assert fit["r_squared"] == pytest.approx(1.0)
+    assert fit["n_points"] == 3
+
+    fit = fit_fi_curve([0.0, 0.1, 0.2], [0.0, 0.0, 10.0])
+    assert fit["gain"] is None
+    assert fit["rheobase"] is None
+    assert fit["n_points"] == 1
+
+
+def test_get_fi_curve():
+    """Test the f-I curve of the soma voltage, sorted by step amplitude."""
+    fi_curve = get_fi_curve(responses, step_amplitudes, stim_windows)
+
+    assert fi_curve["protocol"] == [f"FICurve_{i}" for i in range(5)]
+    assert fi_curve["step_amplitude"] == [0.0, 0.1, 0.2, 0.3, 0.4]
+    assert fi_curve["spike_count"] == [0, 0, 8, 16, 24]
+    assert fi_curve["firing_rate"] == pytest.approx([0.0, 0.0, 10.0, 20.0, 30.0])
+    assert fi_curve["fit"]["gain"] == pytest.approx(100.0)
+
+
+def test_compute_and_write_fi_curves(tmp_path):
+    """Test that the f-I curve of each protocol is written as json."""
+    fi_curve_protocols = {
+        "FICurve": {"step_amplitudes": step_amplitudes, "spike_threshold": -20.0},
+        "NoSpikes": {"step_amplitudes": step_amplitudes, "spike_threshold": 30.0},
+    }
+    fi_curves = compute_fi_curves(responses, fi_curve_protocols, stim_windows)
+    assert fi_curves["FICurve"]["fit"]["rheobase"] == pytest.approx(0.1)
+    assert fi_curves["NoSpikes"]["spike_count"] == [0, 0, 0, 0, 0]
+    assert fi_curves["NoSpikes"]["fit"]["gain"] is None
+
+    write_fi_curves(fi_curves, tmp_path)
+    with open(tmp_path / "fi_curve.json", "r", encoding="utf-8") as fi_curve_file:
+        written = json.load(fi_curve_file)
+    assert written["FICurve"]["spike_count"] == [0, 0, 8, 16, 24]
@@ -0,0 +1,126 @@
+"""Unit tests for hooks.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import pytest
+
+from emodelrunner.hooks import (
+    clear_hooks,
+    get_hooks,
+    get_protocol_responses,
+    load_hook,
+    load_hooks,
+    register_hook,
+    register_hooks_from_paths,
+    run_hooks,
+    unregister_hook,
+)
+
+responses = {
+    "_.Step_150.soma.v": {"time": [0.0, 1.0], "voltage": [-80.0, -80.0]},
+    "_.Step_150.dend1.v": {"time": [0.0, 1.0], "voltage": [-80.0, -80.0]},
+    "_.Step_200.soma.v": {"time": [0.0, 1.0], "voltage": [-80.0, -80.0]},
+    "_.bpo_holding_current": 0.1,
+}
+
+
+@pytest.fixture(autouse=True)
+def run_before_and_after_tests():
+    """Fixture to start and end each test without any registered hook."""
+    clear_hooks()
+    yield
+    clear_hooks()
+
+
+def test_register_hook():
+    """Test hook registration and unregistration."""
+
+    @register_hook
+    def hook(protocol_name, protocol_responses, output_dir):
+        pass
+
+    assert get_hooks() == [hook]
+    register_hook(hook)
+    assert get_hooks() == [hook]
+
+    unregister_hook(hook)
+    assert get_hooks() == []
+
+    with pytest.raises(TypeError):
+        register_hook("not callable")
+
+
+def test_load_hook():
+    """Test importing a hook from its path."""
+    assert load_hook("emodelrunner.hooks:clear_hooks") is clear_hooks
+
+    register_hooks_from_paths(["emodelrunner.hooks:clear_hooks"])
+    assert get_hooks() == [clear_hooks]
+
+    with pytest.raises(ValueError):
+        load_hook("emodelrunner.hooks.clear_hooks")
+
+    clear_hooks()
+    assert load_hooks(["emodelrunner.hooks:clear_hooks"]) == [clear_hooks]
+    # loaded, but not registered
+    assert get_hooks() == []
+
+
+def test_get_protocol_responses():
+    """Test that the responses are grouped by protocol."""
+    protocol_responses = get_protocol_responses(responses)
+
+    assert set(protocol_responses.keys()) == {"Step_150", "Step_200"}
+    assert set(protocol_responses["Step_150"].keys()) == {
+        "_.Step_150.soma.v",
+        "_.Step_150.dend1.v",
+    }
+
+
+def test_run_hooks():
+    """Test that each hook is called once per protocol."""
+    calls = []
+
+    def hook(protocol_name, protocol_responses, output_dir):
+        calls.append((protocol_name, sorted(protocol_responses), output_dir))
+
+    register_hook(hook)
+    run_hooks(responses, "output")
+
+    assert calls == [
+        ("Step_150", ["_.Step_150.dend1.v", "_.Step_150.soma.v"], "output"),
+        ("Step_200", ["_.Step_200.soma.v"], "output"),
+    ]
+
+
+def test_run_given_hooks():
+    """Test that the given hooks are run once, without being registered."""
+    calls = []
+
+    def hook(protocol_name, protocol_responses, output_dir):
+        calls.append(protocol_name)
+
+    register_hook(hook)
+    run_hooks(responses, "output", hooks=[hook])
+    assert calls == ["Step_150", "Step_200"]
+
+    unregister_hook(hook)
+    run_hooks(responses, "output", hooks=[hook])
+    assert calls == ["Step_150", "Step_200"] * 2
+    assert get_hooks() == []
+
+    # e.g. the next run, without the hooks of the previous config
+    run_hooks(responses, "output")
+    assert len(calls) == 4
@@ -0,0 +1,114 @@
+"""Unit tests for impedance.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import numpy as np
+import pytest
+
+from emodelrunner.impedance import (
+    compute_zap_analyses,
+    get_resonance,
+    get_zap_analysis,
+)
+from emodelrunner.locations import SOMA_LOC
+from emodelrunner.stimuli import Chirp
+
+stim_window = (100.0, 10100.0)
+freq_band = (0.5, 20.0)
+
+
+def transfer(frequencies):
+    """Return a 100 MOhm impedance with a resonance at 5 Hz."""
+    return 100.0 * (1.0 + 0.5 * np.exp(-(((frequencies - 5.0) / 1.0) ** 2)))
+
+
+def make_chirp_response():
+    """Return the chirp current and the voltage response through the transfer."""
+    stimulus = Chirp(
+        SOMA_LOC,
+        delay=stim_window[0],
+        duration=stim_window[1] - stim_window[0],
+        amp=0.05,
+        freq_start=freq_band[0],
+        freq_end=freq_band[1],
+        total_duration=10200.0,
+    )
+    time, current = stimulus.generate(0.1)
+
+    voltage = np.full(time.shape, -70.0)
+    during = (time >= stim_window[0]) & (time < stim_window[1])
+    stim_current = current[during] - np.mean(current[during])
+    frequencies = np.fft.rfftfreq(len(stim_current), 0.1 / 1000.0)
+    voltage[during] += np.fft.irfft(
+        np.fft.rfft(stim_current) * transfer(frequencies), len(stim_current)
+    )
+    return {"time": time, "current": current}, {"time": time, "voltage": voltage}
+
+
+def test_get_resonance():
+    """Test the resonance frequency and the Q-factor of a profile."""
+    resonance = get_resonance([1.0, 2.0, 3.0], [100.0, 150.0, 120.0])
+    assert resonance["resonance_frequency"] == 2.0
+    assert resonance["max_impedance"] == 150.0
+    assert resonance["low_frequency_impedance"] == 100.0
+    assert resonance["Q_factor"] == pytest.approx(1.5)
+
+    assert get_resonance([], [])["Q_factor"] is None
+
+
+def test_get_zap_analysis():
+    """Test that the impedance profile of the transfer function is recovered."""
+    current, response = make_chirp_response()
+    analysis = get_zap_analysis(response, current, stim_window, freq_band[::-1])
+
+    frequencies = np.array(analysis["profile"]["frequency"])
+    assert frequencies[0] >= 0.5
+    assert frequencies[-1] <= 20.0
+    assert analysis["profile"]["impedance"] == pytest.approx(
+        transfer(frequencies), rel=1e-6
+    )
+    assert analysis["profile"]["phase"] == pytest.approx(
+        np.zeros(frequencies.shape), abs=1e-6
+    )
+    assert analysis["resonance_frequency"] == pytest.approx(5.0, abs=0.01)
+    assert analysis["max_impedance"] == pytest.approx(150.0)
+    assert analysis["Q_factor"] == pytest.approx(1.5, rel=1e-3)
+
+
+def test_compute_zap_analyses():
+    """Test that the chirp protocols without response are skipped."""
+    current, response = make_chirp_response()
+    chirp_protocols = {
+        "ZAP": {
+            "voltage_key": "L5_TPC.ZAP.soma.v",
+            "current_key": "current_L5_TPC.ZAP",
+            "stim_window": stim_window,
+            "freq_band": freq_band,
+        },
+        "Missing": {
+            "voltage_key": "L5_TPC.Missing.soma.v",
+            "current_key": "current_L5_TPC.Missing",
+            "stim_window": stim_window,
+            "freq_band": freq_band,
+        },
+    }
+    analyses = compute_zap_analyses(
+        {"L5_TPC.ZAP.soma.v": response},
+        {"current_L5_TPC.ZAP": current},
+        chirp_protocols,
+    )
+
+    assert list(analyses) == ["ZAP"]
+    assert analyses["ZAP"]["resonance_frequency"] == pytest.approx(5.0, abs=0.01)
@@ -0,0 +1,85 @@
+"""Unit tests for lfpy_adapter.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import numpy as np
+import pytest
+from bluepyopt import ephys
+
+from emodelrunner.lfpy_adapter import (
+    ExtracellularRecording,
+    add_extracellular_recording,
+    check_electrode_positions,
+    compute_extracellular_potential,
+)
+
+
+def test_check_electrode_positions():
+    """Test that the electrode positions are converted to a (n, 3) array."""
+    positions = check_electrode_positions([[0, 0, 50], [10, 20, 30]])
+    assert positions.shape == (2, 3)
+
+    for wrong_positions in [[], [0, 0, 50], [[0, 50]]]:
+        with pytest.raises(ValueError):
+            check_electrode_positions(wrong_positions)
+
+
+def test_compute_extracellular_potential():
+    """Test the product of the transformation matrix and the membrane currents."""
+    transformation_matrix = np.array([[1.0, 2.0, 0.0], [0.0, 0.5, 1.0]])
+    membrane_currents = np.array([[1.0, -1.0], [0.5, 0.0], [-2.0, 1.0]])
+
+    potential = compute_extracellular_potential(
+        transformation_matrix, membrane_currents
+    )
+    np.testing.assert_allclose(potential, [[2.0, -1.0], [-1.75, 1.0]])
+
+    with pytest.raises(ValueError):
+        compute_extracellular_potential(transformation_matrix, membrane_currents[:2])
+
+
+def test_extracellular_recording():
+    """Test the construction of the extracellular recording."""
+    recording = ExtracellularRecording(
+        name="Step.extracellular", electrode_positions=[[0, 0, 50]]
+    )
+    assert recording.method == "linesource"
+    assert recording.response is None
+
+    with pytest.raises(ValueError):
+        ExtracellularRecording(
+            name="Step.extracellular",
+            electrode_positions=[[0, 0, 50]],
+            method="unknown",
+        )
+
+
+def test_add_extracellular_recording():
+    """Test that the recording is added to the chosen protocol only."""
+    step = ephys.protocols.SweepProtocol(name="Step", stimuli=[], recordings=[])
+    other = ephys.protocols.SweepProtocol(name="Other", stimuli=[], recordings=[])
+    protocols = ephys.protocols.SequenceProtocol("all", protocols=[step, other])
+    recording = ExtracellularRecording(
+        name="Step.extracellular", electrode_positions=[[0, 0, 50]]
+    )
+
+    add_extracellular_recording(protocols, "Step", recording)
+    assert step.recordings == [recording]
+    assert other.recordings == []
+
+    with pytest.raises(ValueError):
+        add_extracellular_recording(protocols, "Missing", recording)
+    with pytest.raises(ValueError):
+        add_extracellular_recording(protocols, "all", recording)
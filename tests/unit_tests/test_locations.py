@@ -14,7 +14,14 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
-from emodelrunner.locations import multi_locations
+import pytest
+
+from emodelrunner.locations import (
+    get_section_location_name,
+    multi_locations,
+    parse_section_location,
+    parse_section_location_name,
+)
 
 
 def test_multi_locations():
@@ -45,3 +52,25 @@ def test_multi_locations():
     assert len(locs) == 1
     assert locs[0].name == "custom"
     assert locs[0].seclist_name == "custom"
+
+
+def test_section_locations():
+    """Test the parsing and the naming of the section locations."""
+    assert parse_section_location("dend[3](0.5)") == {
+        "sec_name": "dend",
+        "sec_index": 3,
+        "comp_x": 0.5,
+    }
+    assert parse_section_location("axon[0](1)")["comp_x"] == 1.0
+
+    for location in ["dend3", "dend[3]", "dend[3](1.5)", "[3](0.5)"]:
+        with pytest.raises(ValueError):
+            parse_section_location(location)
+
+    name = get_section_location_name("apic", 10, 0.25)
+    assert name == "apic10_x0p25"
+    assert parse_section_location_name(name) == {
+        "section": "apic[10]",
+        "position": 0.25,
+    }
+    assert parse_section_location_name("soma") is None
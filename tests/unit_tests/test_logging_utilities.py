@@ -0,0 +1,35 @@
+"""Unit tests for logging_utilities.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import logging
+import os
+
+from emodelrunner.logging_utilities import neuron_output_to_logger
+
+
+def test_neuron_output_to_logger(caplog, capfd):
+    """Test that the output written to the stdout file descriptor is logged."""
+    with caplog.at_level(logging.INFO, logger="neuron"):
+        with neuron_output_to_logger():
+            os.write(1, b"NEURON -- VERSION\n\nloading mechanisms\n")
+        os.write(1, b"not logged\n")
+
+    assert [record.message for record in caplog.records] == [
+        "NEURON -- VERSION",
+        "loading mechanisms",
+    ]
+    assert all(record.name == "neuron" for record in caplog.records)
+    assert capfd.readouterr().out == "not logged\n"
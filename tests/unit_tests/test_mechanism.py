@@ -0,0 +1,56 @@
+"""Unit tests for synapses/mechanism.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+from types import SimpleNamespace
+
+from emodelrunner.synapses.mechanism import NrnMODPointProcessMechanismCustom
+
+
+def test_is_selected():
+    """Test the selection of the synapses by mtype, gid, section type and distance."""
+    # the path distance is given by the position on the section in this fake cell
+    sim = SimpleNamespace(
+        neuron=SimpleNamespace(h=SimpleNamespace(distance=lambda _, seg: seg))
+    )
+    icell = SimpleNamespace(soma=[lambda x: x])
+
+    def section(seg_x):
+        return seg_x * 1000.0
+
+    synapses = [
+        {"pre_mtype": 1, "pre_cell_id": 10, "sectionlist_id": 1, "seg_x": 0.1},
+        {"pre_mtype": 1, "pre_cell_id": 11, "sectionlist_id": 2, "seg_x": 0.3},
+        {"pre_mtype": 2, "pre_cell_id": 10, "sectionlist_id": 2, "seg_x": 0.5},
+    ]
+
+    def get_selected(**selection):
+        mechanism = NrnMODPointProcessMechanismCustom(
+            "synapses", synapses, {}, 1, "Random123", **selection
+        )
+        return [
+            synapse
+            for synapse in synapses
+            if mechanism.is_selected(synapse, section, sim, icell)
+        ]
+
+    assert get_selected() == synapses
+    assert get_selected(pre_mtypes=[1]) == synapses[:2]
+    assert get_selected(pre_cell_ids=[10]) == [synapses[0], synapses[2]]
+    assert get_selected(sectionlist_ids=[2]) == synapses[1:]
+    assert get_selected(path_distance_range=[200, 600]) == synapses[1:]
+    assert get_selected(pre_mtypes=[1], path_distance_range=[200, 600]) == [
+        synapses[1]
+    ]
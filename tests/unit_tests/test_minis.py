@@ -0,0 +1,94 @@
+"""Unit tests for synapses/minis.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+from types import SimpleNamespace
+
+import pytest
+
+from emodelrunner.load import get_minis_args, load_config
+from emodelrunner.synapses.minis import Minis, add_minis, load_minis_rates
+from tests.utils import cwd
+
+example_dir = "examples/sscx_sample_dir"
+config_path = "config/config_allsteps.ini"
+
+
+def test_get_minis_args():
+    """Test the spontaneous minis configuration data."""
+    with cwd(example_dir):
+        config = load_config(config_path)
+        minis_args = get_minis_args(config)
+        assert minis_args["protocols"] == []
+        assert minis_args["rate"] == 0.01
+        assert minis_args["pre_mtype_rates"] == {}
+
+        config = load_config(
+            config_path,
+            [
+                'Minis.protocols=["Step_150"]',
+                'Minis.pre_mtype_rates={"1": 0.02}',
+                "Minis.seed=3",
+            ],
+        )
+        minis_args = get_minis_args(config)
+
+    assert minis_args["protocols"] == ["Step_150"]
+    assert minis_args["pre_mtype_rates"] == {1: 0.02}
+    assert minis_args["seed"] == 3
+    assert minis_args["base_seed"] == 846515
+    assert minis_args["gid"] == 2571167
+
+
+def test_get_rate():
+    """Test that the synapse rates override the pre_mtype and default rates."""
+    stimulus = Minis(
+        1,
+        stop=100.0,
+        spont_minis_rate={0: 0.5},
+        rate=0.01,
+        pre_mtype_rates={2: 0.1},
+    )
+    assert stimulus.get_rate(SimpleNamespace(pre_mtype=2), 0) == 0.5
+    assert stimulus.get_rate(SimpleNamespace(pre_mtype=2), 1) == 0.1
+    assert stimulus.get_rate(SimpleNamespace(pre_mtype=3), 1) == 0.01
+
+    stimulus = Minis(1, stop=100.0, spont_minis_rate={0: 0.5})
+    assert stimulus.get_rate(SimpleNamespace(pre_mtype=3), 1) is None
+
+    with pytest.raises(ValueError):
+        Minis(1)
+
+
+def test_load_minis_rates(tmp_path):
+    """Test the reading of the rates of individual synapses."""
+    rates_path = tmp_path / "minis_rates.txt"
+    rates_path.write_text("# synapse_id rate\n0 0.01\n12 0.5\n")
+    assert load_minis_rates(rates_path) == {0: 0.01, 12: 0.5}
+
+    rates_path.write_text("0 0.01 1\n")
+    with pytest.raises(ValueError):
+        load_minis_rates(rates_path)
+
+
+def test_add_minis_errors():
+    """Test that the minis need protocols and a cell with synapses."""
+    minis_args = {"protocols": []}
+    assert add_minis(None, SimpleNamespace(mechanisms=[]), minis_args) == []
+
+    minis_args = {"protocols": ["Step_150"]}
+    with pytest.raises(ValueError, match="add_synapses"):
+        add_minis(None, SimpleNamespace(mechanisms=[]), minis_args)
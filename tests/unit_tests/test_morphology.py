@@ -14,17 +14,34 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
+import json
 from pathlib import Path
 
-from pytest import raises
+import h5py
+from bluepyopt import ephys
+from pytest import approx, raises
 
 
-from emodelrunner.load import load_config, get_morph_args
+from emodelrunner.create_cells import create_cell_using_config
+from emodelrunner.load import load_config, get_morph_args, get_morph_path
 from emodelrunner.morphology import (
+    clear_morph_modifiers,
     create_morphology,
+    register_morph_modifier,
+    unregister_morph_modifier,
     SSCXNrnFileMorphology,
     ThalamusNrnFileMorphology,
 )
+from emodelrunner.morphology.formats import (
+    convert_morphology,
+    get_neuron_morphology_path,
+    is_collection,
+    load_morphology,
+)
+from emodelrunner.morphology.modifiers import (
+    get_morph_modifiers,
+    get_registered_morph_modifiers,
+)
 from emodelrunner.configuration import PackageType
 from tests.utils import cwd
 
@@ -42,6 +59,161 @@ def test_create_morphology():
         assert isinstance(sscx_morph, SSCXNrnFileMorphology)
         thal_morph = create_morphology(get_morph_args(config), PackageType.thalamus)
         assert isinstance(thal_morph, ThalamusNrnFileMorphology)
+        hippo_morph = create_morphology(
+            get_morph_args(config), PackageType.hippocampus
+        )
+        assert type(hippo_morph) is ephys.morphologies.NrnFileMorphology
 
         with raises(ValueError):
             create_morphology(get_morph_args(config), "unknown_package_type")
+
+
+def test_register_morph_modifier():
+    """Test the registration of the morphology modifiers."""
+
+    def modifier(sim=None, icell=None):
+        """Do nothing."""
+
+    clear_morph_modifiers()
+    assert register_morph_modifier(modifier, hoc="proc modifier() {}") is modifier
+    assert get_registered_morph_modifiers() == {modifier: "proc modifier() {}"}
+    with raises(TypeError):
+        register_morph_modifier("not callable")
+
+    modifiers, modifiers_hoc = get_morph_modifiers({})
+    assert modifiers == [modifier]
+    assert modifiers_hoc == ["proc modifier() {}"]
+
+    # the modifiers of the configuration have no hoc code
+    modifiers, modifiers_hoc = get_morph_modifiers(
+        {"dendrite_diameter_scale": 2.0, "prune_distance": 300.0}
+    )
+    assert len(modifiers) == 3
+    assert modifiers[-1] is modifier
+    assert modifiers_hoc is None
+
+    unregister_morph_modifier(modifier)
+    assert get_registered_morph_modifiers() == {}
+    assert get_morph_modifiers({"dendrite_diameter_scale": 1.0}) == ([], None)
+    with raises(ValueError):
+        get_morph_modifiers({"axon_type": "ais", "ais_path": ""})
+
+
+def test_create_morphology_axon_type(tmp_path):
+    """Test that the axon type overrides do_replace_axon."""
+    ais_path = tmp_path / "ais.json"
+    with open(ais_path, "w", encoding="utf-8") as ais_file:
+        json.dump({"ais": {"length": 30.0, "diameters": [2.0, 1.5, 1.0]}}, ais_file)
+
+    sscx_dir = Path("examples") / "sscx_sample_dir"
+    with cwd(sscx_dir):
+        config = load_config(config_path=Path("config") / "config_allsteps.ini")
+        morph_args = get_morph_args(config)
+        assert morph_args["axon_type"] == ""
+        assert morph_args["prune_distance"] is None
+        morph = create_morphology(morph_args, PackageType.sscx)
+        assert morph.do_replace_axon
+        assert morph.morph_modifiers is None
+
+        morph_args["axon_type"] = "full"
+        assert not create_morphology(morph_args, PackageType.sscx).do_replace_axon
+
+        morph_args["axon_type"] = "ais"
+        morph_args["ais_path"] = str(ais_path)
+        morph = create_morphology(morph_args, PackageType.sscx)
+        assert not morph.do_replace_axon
+        assert len(morph.morph_modifiers) == 1
+        assert morph.morph_modifiers_hoc is None
+
+
+def test_morph_modifiers_instantiate(tmp_path):
+    """Test the AIS, the scaling and the pruning of the instantiated morphology."""
+    ais_path = tmp_path / "ais.json"
+    with open(ais_path, "w", encoding="utf-8") as ais_file:
+        json.dump(
+            {
+                "ais": {"length": 30.0, "diameters": [2.0, 1.5, 1.0]},
+                "myelin": {"length": 500.0, "diameter": 0.8},
+            },
+            ais_file,
+        )
+
+    sim = ephys.simulators.NrnSimulator()
+    sscx_dir = Path("examples") / "sscx_sample_dir"
+    with cwd(sscx_dir):
+        config = load_config(config_path=Path("config") / "config_singlestep.ini")
+        config.set("Cell", "instantiation", "python")
+        cell = create_cell_using_config(config)
+        sim.mechanisms_directory = "./"
+        cell.instantiate(sim=sim)
+        basal_diams = [seg.diam for sec in cell.icell.basal for seg in sec]
+        cell.destroy(sim=sim)
+
+        config.set("Morphology", "axon_type", "ais")
+        config.set("Paths", "ais_path", str(ais_path))
+        config.set("Morphology", "dendrite_diameter_scale", "2.0")
+        config.set("Morphology", "prune_distance", "100")
+        cell = create_cell_using_config(config)
+        cell.instantiate(sim=sim)
+    icell = cell.icell
+
+    assert len(icell.axon) == 1
+    assert icell.axon[0].L == 30.0
+    assert [seg.diam for seg in icell.axon[0]] == [2.0, 1.5, 1.0]
+    assert len(icell.myelin) == 1
+    assert icell.myelin[0].diam == 0.8
+
+    soma_center = icell.soma[0](0.5)
+    for seclist_name in ["basal", "apical"]:
+        for section in getattr(icell, seclist_name):
+            assert sim.neuron.h.distance(soma_center, section(0.0)) <= 100.0
+    # the first basal section starts at the soma, and is kept
+    first_basal = next(iter(icell.basal))
+    assert [seg.diam for seg in first_basal] == approx(
+        [2 * diam for diam in basal_diams[: first_basal.nseg]]
+    )
+    assert len([seg for sec in icell.basal for seg in sec]) < len(basal_diams)
+
+    cell.destroy(sim=sim)
+
+
+def test_morphology_formats(tmp_path):
+    """Test the conversion of the h5 morphologies and of the h5 containers."""
+    asc_path = next((Path("examples") / "sscx_sample_dir" / "morphology").glob("*.asc"))
+    n_sections = len(load_morphology(asc_path).sections)
+    converted_dir = tmp_path / "converted"
+    assert get_neuron_morphology_path(asc_path, converted_dir) == asc_path
+
+    h5_path = convert_morphology(asc_path, tmp_path / "cell.h5")
+    assert not is_collection(h5_path)
+    neuron_path = get_neuron_morphology_path(h5_path, converted_dir)
+    assert neuron_path == converted_dir / "cell.asc"
+    assert len(load_morphology(neuron_path).sections) == n_sections
+
+    # a container with one group per morphology, as in circuits
+    container_path = tmp_path / "morphologies.h5"
+    with h5py.File(h5_path, "r") as h5_file:
+        with h5py.File(container_path, "w") as container:
+            for name in h5_file:
+                h5_file.copy(name, container.require_group("cell"))
+    assert is_collection(container_path)
+    assert is_collection(tmp_path)
+    with raises(ValueError):
+        load_morphology(container_path)
+    neuron_path = get_neuron_morphology_path(container_path, converted_dir, "cell")
+    assert neuron_path == converted_dir / "cell.asc"
+    assert len(load_morphology(neuron_path).sections) == n_sections
+
+
+def test_get_morph_path(tmp_path):
+    """Test that the morphologies NEURON cannot load are converted."""
+    sscx_dir = Path("examples") / "sscx_sample_dir"
+    with cwd(sscx_dir):
+        config = load_config(config_path=Path("config") / "config_allsteps.ini")
+        asc_path = config.get("Paths", "morph_path")
+        assert get_morph_path(config) == asc_path
+
+        h5_path = convert_morphology(asc_path, tmp_path / "cell.h5")
+        config.set("Paths", "morph_path", str(h5_path))
+        config.set("Paths", "converted_morph_dir", str(tmp_path / "converted"))
+        assert get_morph_path(config) == str(tmp_path / "converted" / "cell.asc")
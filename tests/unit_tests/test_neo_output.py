@@ -0,0 +1,79 @@
+"""Unit tests for neo_output.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import numpy as np
+import pytest
+
+from emodelrunner.neo_output import get_variable_units, is_scalar, responses_to_neo
+
+
+def test_get_variable_units():
+    """Test the units of the recorded variables."""
+    assert get_variable_units("v") == "mV"
+    assert get_variable_units("ina") == "mA/cm**2"
+    assert get_variable_units("unknown") == "dimensionless"
+
+
+def test_is_scalar():
+    """Test that the scalar responses are told apart from the traces."""
+    assert is_scalar(0.1)
+    assert is_scalar(np.float64(0.1))
+    assert not is_scalar({"time": [0.0], "voltage": [-80.0]})
+    assert not is_scalar([{"time": [0.0], "voltage": [0.0]}])
+
+
+def test_responses_to_neo():
+    """Test that the responses are grouped by protocol, with their spike trains."""
+    neo = pytest.importorskip("neo")
+
+    time = np.arange(0.0, 10.0, 0.1)
+    voltage = np.full(time.size, -80.0)
+    voltage[20:25] = 20.0
+    responses = {
+        "_.Step_150.soma.v": {"time": time, "voltage": voltage},
+        "_.Step_150.soma.ina": {"time": [0.0, 0.1, 0.3], "voltage": [0.0, 1.0, 0.0]},
+        "_.Step_200.soma.v": {"time": time, "voltage": np.full(time.size, -80.0)},
+        "_.bpo_holding_current": -0.1,
+        "_.bpo_threshold_current": None,
+    }
+    currents = {"current__.Step_150": {"time": time, "current": np.ones(time.size)}}
+
+    block = responses_to_neo(responses, currents, name="cADpyr_L5TPC")
+
+    assert block.name == "cADpyr_L5TPC"
+    assert block.annotations["_.bpo_holding_current"] == -0.1
+    assert [segment.name for segment in block.segments] == ["Step_150", "Step_200"]
+
+    segment = block.segments[0]
+    assert [signal.name for signal in segment.analogsignals] == [
+        "_.Step_150.soma.v",
+        "current__.Step_150",
+    ]
+    voltage_signal = segment.analogsignals[0]
+    assert isinstance(voltage_signal, neo.AnalogSignal)
+    assert voltage_signal.sampling_rate.rescale("kHz").magnitude == pytest.approx(10)
+    assert voltage_signal.annotations["location"] == "soma"
+
+    # irregular time steps
+    assert segment.irregularlysampledsignals[0].annotations["variable"] == "ina"
+
+    np.testing.assert_allclose(segment.spiketrains[0].rescale("ms").magnitude, [2.0])
+    assert len(block.segments[1].spiketrains[0]) == 0
+
+    # currents returned with the recordings
+    responses["current__.Step_150"] = currents["current__.Step_150"]
+    block = responses_to_neo(responses)
+    assert len(block.segments[0].analogsignals) == 2
@@ -0,0 +1,57 @@
+"""Unit tests for netpyne_export.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import os
+
+from emodelrunner.netpyne_export import compress_values, export_netpyne_cell_rule
+from tests.utils import cwd
+
+example_dir = os.path.join("examples", "sscx_sample_dir")
+
+
+def test_compress_values():
+    """Test that uniform values are replaced by a single value."""
+    assert compress_values([1, 1.0, 1]) == 1.0
+    assert compress_values([1, 2]) == [1.0, 2.0]
+
+
+def test_export_netpyne_cell_rule(tmp_path):
+    """Test the export of the sscx example cell."""
+    output_path = tmp_path / "netpyne_cell_rule.json"
+    with cwd(example_dir):
+        cell_rule = export_netpyne_cell_rule(
+            "config/config_singlestep.ini", output_path
+        )
+
+    with open(output_path, "r", encoding="utf-8") as rule_file:
+        assert json.load(rule_file) == cell_rule
+
+    assert cell_rule["conds"] == {"cellType": "cADpyr_L4UPC"}
+    assert cell_rule["globals"] == {"celsius": 34, "v_init": -80}
+
+    secs = cell_rule["secs"]
+    assert sorted(secs) == sorted(cell_rule["secLists"]["all"])
+    assert secs["soma_0"]["topol"] == {}
+    assert secs["axon_0"]["topol"]["parentSec"] == "soma_0"
+    assert "pt3d" in secs["soma_0"]["geom"]
+    # the replaced axon has no 3d points
+    assert "diam" in secs["axon_0"]["geom"]
+
+    # only the parameters of the mechanisms are exported
+    assert "gNaTgbar" in secs["soma_0"]["mechs"]["NaTg"]
+    assert "m" not in secs["soma_0"]["mechs"]["NaTg"]
+    assert "e" in secs["soma_0"]["ions"]["na"]
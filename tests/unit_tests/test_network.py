@@ -0,0 +1,76 @@
+"""Unit tests for network.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import numpy as np
+import pytest
+
+from emodelrunner.network import (
+    get_network_node_ids,
+    get_network_spikes,
+    split_synapses,
+    write_spikes,
+)
+from emodelrunner.synapses.spike_trains import load_text_spike_trains
+
+
+def test_get_network_node_ids():
+    """Test that the node ids are sorted and unique."""
+    assert get_network_node_ids(None, "neurons", node_ids=[12, 3, 12]) == [3, 12]
+
+    with pytest.raises(ValueError):
+        get_network_node_ids(None, "neurons")
+    with pytest.raises(ValueError):
+        get_network_node_ids(None, "neurons", node_set="Slice", node_ids=[3])
+
+
+def test_split_synapses():
+    """Test that the synapses from the cells of the network are internal."""
+    synapses = [
+        {"sid": 0, "pre_cell_id": 4, "pre_population": "neurons"},
+        {"sid": 1, "pre_cell_id": 8, "pre_population": "neurons"},
+        {"sid": 2, "pre_cell_id": 4, "pre_population": "thalamus"},
+    ]
+
+    internal, external = split_synapses(synapses, "neurons", [3, 5])
+    assert internal == synapses[:1]
+    assert external == synapses[1:]
+
+    internal, external = split_synapses(
+        synapses, "neurons", [3, 5], input_population="thalamus"
+    )
+    assert internal == synapses[:1]
+    assert external == synapses[2:]
+
+
+def test_network_spikes(tmp_path):
+    """Test that the spikes are detected and written in the out.dat format."""
+    time = np.arange(0, 100, 0.5)
+    voltage = np.full_like(time, -70.0)
+    voltage[(time >= 20) & (time < 22)] = 20.0
+    voltage[(time >= 60) & (time < 62)] = 20.0
+    responses = {
+        "neurons.3.soma.v": {"time": time, "voltage": voltage},
+        "neurons.5.soma.v": {"time": time, "voltage": np.full_like(time, -70.0)},
+    }
+    cells_data = {3: {"gid": 4}, 5: {"gid": 6}}
+
+    spikes = get_network_spikes(responses, cells_data, prefix="neurons")
+    assert list(spikes[4]) == [20.0, 60.0]
+    assert len(spikes[6]) == 0
+
+    write_spikes(spikes, tmp_path / "out.dat")
+    assert load_text_spike_trains(tmp_path / "out.dat") == {4: [20.0, 60.0]}
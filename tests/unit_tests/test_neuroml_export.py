@@ -0,0 +1,107 @@
+"""Unit tests for neuroml_export.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import json
+import os
+import xml.etree.ElementTree as ET
+
+from emodelrunner.neuroml_export import (
+    NEUROML_NAMESPACE,
+    export_neuroml_cell,
+    get_neuroml_id,
+    get_parent_segment,
+    group_sections,
+)
+from tests.utils import cwd
+
+example_dir = os.path.join("examples", "sscx_sample_dir")
+
+NATG_CHANNEL = f"""<neuroml xmlns="{NEUROML_NAMESPACE}" id="NaTg">
+    <ionChannelHH id="NaTg" conductance="10pS" species="na"/>
+</neuroml>
+"""
+
+
+def test_get_neuroml_id():
+    """Test that the invalid characters of the NeuroML ids are replaced."""
+    assert get_neuroml_id("cADpyr_L4UPC") == "cADpyr_L4UPC"
+    assert get_neuroml_id("L5.TPC-1") == "L5_TPC_1"
+    assert get_neuroml_id("5TPC") == "_5TPC"
+
+
+def test_get_parent_segment():
+    """Test the segment and the position along it of a position along a section."""
+    segments = [(3, 0.0, 0.5), (4, 0.5, 1.0)]
+    assert get_parent_segment(segments, 1.0) == (4, 1.0)
+    assert get_parent_segment(segments, 0.25) == (3, 0.5)
+    assert get_parent_segment(segments, 0.0) == (3, 0.0)
+
+
+def test_group_sections():
+    """Test that the sections with the same values are grouped."""
+    groups = group_sections({"soma_0": 1.0, "dend_0": 2.0, "dend_1": 1.0})
+    assert groups == [(1.0, ["soma_0", "dend_1"]), (2.0, ["dend_0"])]
+
+
+def test_export_neuroml_cell(tmp_path):
+    """Test the export of the sscx example cell."""
+    channels_dir = tmp_path / "channels"
+    channels_dir.mkdir()
+    (channels_dir / "NaTg.channel.nml").write_text(NATG_CHANNEL)
+    output_dir = tmp_path / "neuroml"
+    step = {"amp": 0.3, "delay": 700.0, "duration": 2000.0, "totduration": 3000.0}
+
+    with cwd(example_dir):
+        report = export_neuroml_cell(
+            "config/config_singlestep.ini", output_dir, channels_dir, step
+        )
+
+    with open(output_dir / "neuroml_report.json", "r", encoding="utf-8") as file_:
+        assert json.load(file_) == report
+    assert report["converted_mechanisms"] == ["NaTg", "pas"]
+    assert "CaDynamics_DC0" in report["not_converted_mechanisms"]
+    assert "Ih" in report["not_converted_mechanisms"]
+    assert (output_dir / "NaTg.channel.nml").is_file()
+    assert (output_dir / "LEMS_cADpyr_L4UPC.xml").is_file()
+
+    namespace = {"nml": NEUROML_NAMESPACE}
+    neuroml = ET.parse(output_dir / "cADpyr_L4UPC.cell.nml").getroot()
+    cell = neuroml.find("nml:cell", namespace)
+    assert cell.get("id") == "cADpyr_L4UPC"
+    segments = cell.findall("nml:morphology/nml:segment", namespace)
+    # only the root segment has no parent
+    roots = [
+        segment for segment in segments if segment.find("nml:parent", namespace) is None
+    ]
+    assert len(roots) == 1
+    group_ids = [
+        group.get("id")
+        for group in cell.findall("nml:morphology/nml:segmentGroup", namespace)
+    ]
+    for group_id in ["soma_0", "axon_0", "somatic", "soma_group", "dendrite_group"]:
+        assert group_id in group_ids
+
+    channel_densities = cell.findall(
+        "nml:biophysicalProperties/nml:membraneProperties/nml:channelDensity",
+        namespace,
+    )
+    natg_densities = [
+        density for density in channel_densities if density.get("ionChannel") == "NaTg"
+    ]
+    assert natg_densities
+    assert all(density.get("ion") == "na" for density in natg_densities)
+    assert not any(density.get("ionChannel") == "Ih" for density in channel_densities)
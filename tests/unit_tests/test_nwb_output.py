@@ -0,0 +1,70 @@
+"""Unit tests for nwb_output.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import numpy as np
+import pytest
+
+from emodelrunner.nwb_output import get_sampling, write_nwb
+
+
+def test_get_sampling():
+    """Test the sampling arguments of regular and irregular time series."""
+    sampling = get_sampling(np.arange(10.0, 20.0, 0.1))
+    assert sampling["starting_time"] == pytest.approx(0.01)
+    assert sampling["rate"] == pytest.approx(10000)
+
+    sampling = get_sampling([0.0, 0.1, 0.3])
+    np.testing.assert_allclose(sampling["timestamps"], [0.0, 0.0001, 0.0003])
+
+
+def test_write_nwb(tmp_path):
+    """Test that the traces are written with their metadata in a NWB file."""
+    pynwb = pytest.importorskip("pynwb")
+
+    time = np.arange(0.0, 10.0, 0.1)
+    responses = {
+        "_.Step_150.soma.v": {"time": time, "voltage": np.full(time.size, -80.0)},
+        "_.Step_150.soma.ina": {"time": time, "voltage": np.zeros(time.size)},
+        "_.bpo_holding_current": -0.1,
+        "_.bpo_threshold_current": None,
+    }
+    currents = {"current__.Step_150": {"time": time, "current": np.ones(time.size)}}
+    output_path = tmp_path / "cADpyr_L5TPC.nwb"
+
+    write_nwb(
+        output_path,
+        responses,
+        currents,
+        emodel="cADpyr_L5TPC",
+        cell_id=3,
+        mtype="L5_TPC",
+        provenance={"emodelrunner_version": "test"},
+    )
+
+    with pynwb.NWBHDF5IO(str(output_path), "r") as io:
+        nwbfile = io.read()
+        voltage = nwbfile.acquisition["_.Step_150.soma.v"]
+        assert voltage.stimulus_description == "Step_150"
+        assert voltage.rate == pytest.approx(10000)
+        np.testing.assert_allclose(voltage.data[:] * voltage.conversion, -0.08)
+        assert voltage.electrode.name == "soma"
+        assert nwbfile.acquisition["_.Step_150.soma.ina"].unit == "mA/cm2"
+        assert "current__.Step_150" in nwbfile.stimulus
+        assert nwbfile.scratch["_.bpo_holding_current"].data[0] == -0.1
+        assert "_.bpo_threshold_current" not in nwbfile.scratch
+        assert nwbfile.subject.subject_id == "3"
+        assert "L5_TPC" in nwbfile.subject.description
+        assert "emodelrunner_version" in nwbfile.notes
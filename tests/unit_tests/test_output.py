@@ -21,6 +21,11 @@ import numpy as np
 import pytest
 
 from emodelrunner.output import (
+    get_h5_path,
+    get_regular_time,
+    get_trace_data,
+    resample_responses,
+    write_h5_output,
     write_responses,
     write_current,
     write_synplas_output,
@@ -62,6 +67,58 @@ def test_write_responses():
     )
 
 
+def test_resample_responses():
+    """Test that the variable time step traces are resampled on a regular grid."""
+    responses = {
+        "scalar": 0.12,
+        "soma.v": {"time": [0.0, 0.3, 1.0], "voltage": [-80.0, -77.0, -70.0]},
+        "extracellular": {
+            "time": [0.0, 0.3, 1.0],
+            "positions": [[0, 0, 0], [1, 1, 1]],
+            "potential": [[0.0, 3.0, 10.0], [0.0, -3.0, -10.0]],
+        },
+    }
+
+    resampled = resample_responses(responses, 0.25)
+    assert resampled["scalar"] == 0.12
+    np.testing.assert_allclose(resampled["soma.v"]["time"], [0, 0.25, 0.5, 0.75, 1])
+    np.testing.assert_allclose(
+        resampled["soma.v"]["voltage"], [-80, -77.5, -75, -72.5, -70]
+    )
+    np.testing.assert_allclose(
+        resampled["extracellular"]["potential"][1], [0, -2.5, -5, -7.5, -10]
+    )
+    assert resampled["extracellular"]["positions"] == [[0, 0, 0], [1, 1, 1]]
+
+    # the injected currents are on the same grid as the voltage
+    currents = {"current_soma": {"time": [0.0, 0.5, 1.0], "current": [0, 0.2, 0.2]}}
+    resampled = resample_responses(currents, 0.25)["current_soma"]
+    np.testing.assert_allclose(resampled["time"], [0, 0.25, 0.5, 0.75, 1])
+    np.testing.assert_allclose(resampled["current"], [0, 0.1, 0.2, 0.2, 0.2])
+
+    # rounding errors on the last step
+    assert len(get_regular_time(np.array([0.0, 0.99999999]), 0.25)) == 5
+
+
+def test_get_trace_data():
+    """Test the grouping of the responses written by the nwb and neo outputs."""
+    response = {"time": [0.0, 1.0], "voltage": [-80.0, -70.0]}
+    assert get_trace_data("_.Step_150.soma.cai", response, "cai") == (
+        "cai",
+        [0.0, 1.0],
+        [-80.0, -70.0],
+    )
+    # the soma voltage of the synapse plasticity protocols has no variable
+    assert get_trace_data("pulse", response)[0] == "v"
+
+    # synapse recordings, with one column per synapse
+    responses = [response, {"time": [0.0, 1.0], "voltage": [-60.0, -50.0]}]
+    variable, time, data = get_trace_data("pulse.g", responses)
+    assert variable == "pulse.g"
+    assert time == [0.0, 1.0]
+    np.testing.assert_allclose(data, [[-80, -60], [-70, -50]])
+
+
 def test_write_current():
     """Test write_current function."""
     currents = {
@@ -76,6 +133,36 @@ def test_write_current():
     )
 
 
+def test_write_h5_output():
+    """Test that the traces are grouped by protocol in a compressed h5 file."""
+    responses = {
+        "_.Step_150.soma.v": {"time": [0.0, 1.0, 2.0], "voltage": [-80, -70, -80]},
+        "_.Step_150.dend3_x0p5.cai": {"time": [0.0, 1.0], "voltage": [1e-4, 2e-4]},
+        "_.bpo_holding_current": 0.1,
+        "_.bpo_threshold_current": None,
+    }
+    currents = {"current__.Step_150": {"time": [0.0, 1.0], "current": [0.0, 0.5]}}
+    assert get_h5_path("_.Step_150.soma.v") == "Step_150/soma.v"
+    assert get_h5_path("current__.Step_150") == "Step_150/current"
+    assert get_h5_path("_.bpo_holding_current") == "scalars/_.bpo_holding_current"
+
+    output_path = output_dir / "test_responses.h5"
+    write_h5_output(responses, currents, output_path, provenance={"seed": 1})
+
+    with h5py.File(output_path, "r") as h5file:
+        assert set(h5file["Step_150"]) == {"soma.v", "dend3_x0p5.cai", "current"}
+        assert h5file["Step_150/soma.v"].attrs["key"] == "_.Step_150.soma.v"
+        assert h5file["Step_150/soma.v/values"].compression == "gzip"
+        np.testing.assert_allclose(h5file["Step_150/current/values"], [0.0, 0.5])
+        assert h5file["scalars/_.bpo_holding_current"][()] == 0.1
+        assert "_.bpo_threshold_current" not in h5file["scalars"]
+        assert h5file.attrs["provenance"] == '{"seed": 1}'
+
+    write_h5_output(responses, currents, output_path, compression_level=0)
+    with h5py.File(output_path, "r") as h5file:
+        assert h5file["Step_150/soma.v/values"].compression is None
+
+
 def test_write_synplas_output():
     """Test write_synplas_output function."""
     pre_spike_train = [10.0, 20.0, 30.0]
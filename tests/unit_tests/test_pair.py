@@ -0,0 +1,78 @@
+"""Unit tests for pair.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import json
+from pathlib import Path
+
+import numpy as np
+import pytest
+
+from emodelrunner.load import get_pair_args, load_config
+from emodelrunner.pair import compute_epsps, write_epsps
+from tests.utils import cwd
+
+sscx_sample_dir = Path("examples") / "sscx_sample_dir"
+config_path = "config/config_allsteps.ini"
+
+
+def test_get_pair_args():
+    """Test that the precell defaults to the cell of the config."""
+    with cwd(sscx_sample_dir):
+        config = load_config(config_path=config_path)
+        pair_args = get_pair_args(config)
+        assert pair_args["precell_emodel"] == config.get("Cell", "emodel")
+        assert pair_args["precell_unoptimized_params_path"] == config.get(
+            "Paths", "unoptimized_params_path"
+        )
+        assert pair_args["precell_gid"] is None
+        assert pair_args["spike_times"] == [100.0]
+
+        config = load_config(
+            config_path=config_path,
+            config_overrides=["Cell.precell_gid=12", "Pair.spike_times=[10, 35.5]"],
+        )
+        pair_args = get_pair_args(config)
+        assert pair_args["precell_gid"] == 12
+        assert pair_args["spike_times"] == [10, 35.5]
+
+
+def test_compute_epsps(tmp_path):
+    """Test the amplitude, latency and paired-pulse ratio of the EPSPs."""
+    time = np.arange(0, 100, 0.1)
+    voltage = np.full_like(time, -70.0)
+    # EPSPs of 2 mV and 1 mV peaking 5 ms after the presynaptic spikes
+    voltage[(time > 22) & (time < 28)] = -69.0
+    voltage[np.argmin(np.abs(time - 25))] = -68.0
+    voltage[(time > 52) & (time < 58)] = -69.5
+    voltage[np.argmin(np.abs(time - 55))] = -69.0
+
+    epsps = compute_epsps(time, voltage, [20.0, 50.0, 99.95], window=20.0)
+
+    assert epsps["pre_spike_times"] == [20.0, 50.0, 99.95]
+    assert epsps["baselines"][:2] == pytest.approx([-70.0, -70.0])
+    assert epsps["amplitudes"][:2] == pytest.approx([2.0, 1.0])
+    assert epsps["latencies"][:2] == pytest.approx([5.0, 5.0])
+    assert epsps["paired_pulse_ratios"][:2] == pytest.approx([1.0, 0.5])
+    # no sample after the last spike
+    assert epsps["amplitudes"][2] is None
+    assert epsps["paired_pulse_ratios"][2] is None
+
+    write_epsps(epsps, tmp_path, provenance={"emodelrunner": "test"})
+    with open(tmp_path / "pair.json", "r", encoding="utf-8") as pair_file:
+        written = json.load(pair_file)
+    assert written["amplitudes"][:2] == pytest.approx([2.0, 1.0])
+    assert written["provenance"] == {"emodelrunner": "test"}
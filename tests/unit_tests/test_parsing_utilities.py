@@ -18,7 +18,16 @@ import logging
 from unittest.mock import patch
 import sys
 
-from emodelrunner.parsing_utilities import get_parser_args, set_verbosity
+import pytest
+
+from emodelrunner.parsing_utilities import (
+    LOG_FORMAT,
+    get_gui_parser_args,
+    get_netpyne_parser_args,
+    get_neuroml_parser_args,
+    get_parser_args,
+    set_verbosity,
+)
 
 
 def test_get_parser_args():
@@ -47,6 +56,89 @@ def test_get_parser_args():
     args = get_parser_args()
 
     assert args.verbosity == 2
+    assert args.config_overrides is None
+
+    # config overrides case
+    sys.argv = (
+        "run.py --config_path mock/config/path --set Cell.celsius=36 --set Sim.dt=0.1"
+    ).split()
+    args = get_parser_args()
+
+    assert args.config_overrides == ["Cell.celsius=36", "Sim.dt=0.1"]
+
+
+def test_get_gui_parser_args():
+    """Test get_gui_parser_args function."""
+    sys.argv = "GUI.py --config_path mock/config/path".split()
+    args = get_gui_parser_args()
+
+    assert args.config_path == "mock/config/path"
+    assert args.fps == 15
+    assert args.theme == "light"
+    assert args.scaling is None
+    assert args.session is None
+
+    sys.argv = "GUI.py --config_path mock/config/path --fps 5".split()
+    args = get_gui_parser_args()
+
+    assert args.fps == 5
+
+    # the figures cannot be updated zero or a negative number of times per second
+    for fps in ["0", "-5", "fast"]:
+        sys.argv = f"GUI.py --config_path mock/config/path --fps {fps}".split()
+        with pytest.raises(SystemExit) as exc_info:
+            get_gui_parser_args()
+        assert exc_info.value.code == 2
+
+    sys.argv = "GUI.py --config_path mock/config/path --theme dark --scaling 2".split()
+    args = get_gui_parser_args()
+
+    assert args.theme == "dark"
+    assert args.scaling == 2
+
+    sys.argv = "GUI.py --session mock/session.json".split()
+    args = get_gui_parser_args()
+
+    assert args.session == "mock/session.json"
+    assert args.screenshot is None
+    assert args.image_format == "png"
+
+    sys.argv = "GUI.py --screenshot mock/figures --image_format svg".split()
+    args = get_gui_parser_args()
+
+    assert args.screenshot == "mock/figures"
+    assert args.image_format == "svg"
+
+
+def test_get_netpyne_parser_args():
+    """Test get_netpyne_parser_args function."""
+    sys.argv = "netpyne_export.py --config_path mock/config/path".split()
+    args = get_netpyne_parser_args()
+
+    assert args.config_path == "mock/config/path"
+    assert args.output_path == "netpyne_cell_rule.json"
+
+    sys.argv = "netpyne_export.py --output_path mock/rule.json".split()
+    args = get_netpyne_parser_args()
+
+    assert args.output_path == "mock/rule.json"
+
+
+def test_get_neuroml_parser_args():
+    """Test get_neuroml_parser_args function."""
+    sys.argv = "neuroml_export.py --config_path mock/config/path".split()
+    args = get_neuroml_parser_args()
+
+    assert args.output_dir == "neuroml"
+    assert args.channels_dir is None
+    assert args.step_amplitude is None
+
+    sys.argv = "neuroml_export.py --step_amplitude 0.3 --step_delay 100".split()
+    args = get_neuroml_parser_args()
+
+    assert args.step_amplitude == 0.3
+    assert args.step_delay == 100.0
+    assert args.total_duration == 3000.0
 
 
 @patch("logging.basicConfig")
@@ -55,10 +147,42 @@ def test_set_verbosity(patch_basicConfig):
     with patch("logging.StreamHandler", return_value="mock_val") as stream_handler:
         set_verbosity(-1)
         patch_basicConfig.assert_called_with(
-            level=logging.WARNING, handlers=["mock_val"]
+            level=logging.WARNING, format=LOG_FORMAT, handlers=["mock_val"]
         )
         set_verbosity(1)
-        patch_basicConfig.assert_called_with(level=logging.INFO, handlers=["mock_val"])
+        patch_basicConfig.assert_called_with(
+            level=logging.INFO, format=LOG_FORMAT, handlers=["mock_val"]
+        )
         set_verbosity(3)
-        patch_basicConfig.assert_called_with(level=logging.DEBUG, handlers=["mock_val"])
+        patch_basicConfig.assert_called_with(
+            level=logging.DEBUG, format=LOG_FORMAT, handlers=["mock_val"]
+        )
         assert stream_handler.call_count == 3
+
+        # the log level overrides the verbosity
+        set_verbosity(2, log_level="ERROR")
+        patch_basicConfig.assert_called_with(
+            level=logging.ERROR, format=LOG_FORMAT, handlers=["mock_val"]
+        )
+
+    with patch("logging.FileHandler", return_value="mock_file") as file_handler:
+        set_verbosity(0, log_file="mock.log")
+        file_handler.assert_called_with("mock.log")
+        patch_basicConfig.assert_called_with(
+            level=logging.WARNING, format=LOG_FORMAT, handlers=["mock_file"]
+        )
+
+
+def test_logging_arguments():
+    """Test the log level and log file arguments."""
+    sys.argv = "run.py --config_path mock/config/path".split()
+    args = get_parser_args()
+
+    assert args.log_level is None
+    assert args.log_file is None
+
+    sys.argv = "run.py --log-level DEBUG --log-file mock/run.log".split()
+    args = get_parser_args()
+
+    assert args.log_level == "DEBUG"
+    assert args.log_file == "mock/run.log"
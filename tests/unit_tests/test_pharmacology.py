@@ -0,0 +1,97 @@
+"""Unit tests for pharmacology.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+from types import SimpleNamespace
+
+import pytest
+from bluepyopt import ephys
+
+from emodelrunner.pharmacology import apply_channel_blocks, parse_channel_blocks
+
+
+def get_fake_cell():
+    """Return a cell with a frozen and a free conductance in two section lists."""
+    params = [
+        ephys.parameters.NrnRangeParameter(
+            name="gSKv3_1bar_SKv3_1.somatic",
+            param_name="gSKv3_1bar_SKv3_1",
+            value=0.2,
+            frozen=True,
+        ),
+        ephys.parameters.NrnRangeParameter(
+            name="gNaTgbar_NaTg.somatic",
+            param_name="gNaTgbar_NaTg",
+            bounds=[0.1, 1.0],
+        ),
+        ephys.parameters.NrnRangeParameter(
+            name="gNaTgbar_NaTg.axonal",
+            param_name="gNaTgbar_NaTg",
+            bounds=[0.1, 4.0],
+        ),
+    ]
+    return SimpleNamespace(params={param.name: param for param in params})
+
+
+def test_parse_channel_blocks():
+    """Test the parsing of the channel blocks of the config."""
+    assert parse_channel_blocks("") == []
+    assert parse_channel_blocks("gSKv3_1bar=0, gNaTgbar*0.5") == [
+        {"name": "gSKv3_1bar", "operation": "set", "value": 0.0},
+        {"name": "gNaTgbar", "operation": "scale", "value": 0.5},
+    ]
+    assert parse_channel_blocks(" gNaTgbar_NaTg.axonal = -1e-3 ") == [
+        {"name": "gNaTgbar_NaTg.axonal", "operation": "set", "value": -0.001},
+    ]
+
+    with pytest.raises(ValueError):
+        parse_channel_blocks("gNaTgbar")
+    with pytest.raises(ValueError):
+        parse_channel_blocks("gNaTgbar*half")
+
+
+def test_apply_channel_blocks():
+    """Test that the blocked parameters are frozen to their new value."""
+    cell = get_fake_cell()
+    param_values = {"gNaTgbar_NaTg.somatic": 0.4, "gNaTgbar_NaTg.axonal": 3.0}
+
+    param_values, perturbations = apply_channel_blocks(
+        cell,
+        param_values,
+        parse_channel_blocks("gSKv3_1bar=0, gNaTgbar*0.5, gNaTgbar_NaTg.axonal*0.1"),
+    )
+    assert param_values == {}
+    assert perturbations == {
+        "gSKv3_1bar_SKv3_1.somatic": {"original_value": 0.2, "value": 0.0},
+        "gNaTgbar_NaTg.somatic": {"original_value": 0.4, "value": 0.2},
+        "gNaTgbar_NaTg.axonal": {
+            "original_value": 3.0,
+            "value": pytest.approx(0.15),
+        },
+    }
+    # the new value can be out of the bounds of the parameter
+    assert cell.params["gNaTgbar_NaTg.axonal"].frozen
+    assert cell.params["gNaTgbar_NaTg.axonal"].value == pytest.approx(0.15)
+
+    # wildcards, and blocks matching no parameter
+    cell = get_fake_cell()
+    param_values, perturbations = apply_channel_blocks(
+        cell, {"gNaTgbar_NaTg.somatic": 0.4}, parse_channel_blocks("*.somatic*2")
+    )
+    assert param_values == {}
+    assert list(perturbations) == ["gSKv3_1bar_SKv3_1.somatic", "gNaTgbar_NaTg.somatic"]
+    with pytest.raises(ValueError):
+        apply_channel_blocks(cell, {}, parse_channel_blocks("gKbar=0"))
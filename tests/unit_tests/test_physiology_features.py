@@ -0,0 +1,122 @@
+"""Unit tests for factsheets/physiology_features.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import numpy as np
+import pytest
+
+from emodelrunner.factsheets.physiology_features import (
+    burst_factsheet_info,
+    estimate_rheobase,
+    extract_step_features,
+    impedance_factsheet_info,
+    subthreshold_factsheet_info,
+)
+
+
+def test_extract_step_features():
+    """Test extract_step_features function with a passive response."""
+    time = np.arange(0, 1000, 0.1)
+    voltage = np.full(time.shape, -80.0)
+    # 10 mV depolarization with a 0.1 nA step -> 100 MOhm
+    voltage[(time >= 200) & (time < 800)] = -70.0
+
+    features = extract_step_features(time, voltage, 0.1, 200, 600)
+    assert features["spike_count"] == 0
+    assert features["input_resistance"] == pytest.approx(100.0)
+    assert features["AP_half_width"] is None
+
+    # no input resistance without step
+    features = extract_step_features(time, voltage, 0, 200, 600)
+    assert features["input_resistance"] is None
+
+
+def test_estimate_rheobase():
+    """Test estimate_rheobase function."""
+    assert estimate_rheobase([], []) == (None, None)
+    assert estimate_rheobase([0.3, 0.2], []) == (None, 0.2)
+    assert estimate_rheobase([], [-0.1, 0.1]) == (0.1, None)
+    assert estimate_rheobase([0.3, 0.2], [-0.1, 0.1]) == (0.1, 0.2)
+
+
+def test_subthreshold_factsheet_info():
+    """Test that the mean subthreshold features are given with their units."""
+    subthreshold = {
+        "step_amplitude": [-0.1, -0.05],
+        "mean": {"input_resistance": 100.0, "time_constant": 20.0, "sag_ratio": 0.1},
+    }
+    info = subthreshold_factsheet_info("Subthreshold", subthreshold)
+
+    assert info["protocol"] == "Subthreshold"
+    assert info["step_amplitudes"] == [-0.1, -0.05]
+    assert [value["name"] for value in info["values"]] == [
+        "input resistance",
+        "membrane time constant",
+        "sag ratio",
+    ]
+    assert info["values"][0] == {
+        "name": "input resistance",
+        "value": 100.0,
+        "unit": "MOhm",
+    }
+
+
+def test_impedance_factsheet_info():
+    """Test that the resonance is given with the impedance profile."""
+    impedance = {
+        "resonance_frequency": 5.0,
+        "max_impedance": 150.0,
+        "low_frequency_impedance": 100.0,
+        "Q_factor": 1.5,
+        "profile": {
+            "frequency": [0.5, 5.0],
+            "impedance": [100.0, 150.0],
+            "phase": [0.0, 0.0],
+        },
+    }
+    info = impedance_factsheet_info("ZAP", impedance)
+
+    assert info["protocol"] == "ZAP"
+    assert info["profile"]["impedance"] == [100.0, 150.0]
+    assert "phase" not in info["profile"]
+    assert [value["value"] for value in info["values"]] == [5.0, 150.0, 1.5]
+
+
+def test_burst_factsheet_info():
+    """Test that the burst features are given with their units."""
+    burst = {
+        "type": "rebound",
+        "hyperpolarized_voltage": -90.0,
+        "n_spikes": 3,
+        "burst_latency": 10.0,
+        "burst_n_spikes": 3,
+        "burst_duration": 8.0,
+        "intraburst_frequency": 250.0,
+    }
+    info = burst_factsheet_info("Rebound", burst)
+
+    assert info["name"] == "Rebound burst"
+    assert info["protocol"] == "Rebound"
+    assert [value["value"] for value in info["values"]] == [
+        -90.0,
+        10.0,
+        3,
+        8.0,
+        250.0,
+    ]
+    assert info["values"][4]["unit"] == "Hz"
+
+    burst["type"] = "low_threshold"
+    assert burst_factsheet_info("LTB", burst)["name"] == "Low-threshold burst"
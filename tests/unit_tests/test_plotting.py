@@ -0,0 +1,132 @@
+"""Unit tests for plotting.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+from pathlib import Path
+
+import numpy as np
+import pytest
+
+from emodelrunner.output import (
+    write_current,
+    write_responses,
+    write_synapse_recordings,
+    write_synplas_output,
+)
+from emodelrunner.plotting import (
+    get_protocol_current,
+    plot_fi_curves,
+    plot_output_dir,
+    plot_responses,
+)
+
+output_dir = Path("tests/output/plotting")
+
+time = np.arange(0, 100, 0.1)
+responses = {
+    "_.Step_150.soma.v": {"time": time, "voltage": np.full(time.shape, -80.0)},
+    "_.Step_150.dend1.v": {"time": time, "voltage": np.full(time.shape, -75.0)},
+    "_.Step_200.soma.v": {"time": time, "voltage": np.full(time.shape, -70.0)},
+    "_.bpo_holding_current": -0.1,
+}
+currents = {
+    "current__.Step_150": {"time": time, "current": np.zeros(time.shape)},
+}
+
+
+def test_get_protocol_current():
+    """Test that the current of a protocol is found by its name."""
+    assert get_protocol_current(currents, "Step_150") is currents["current__.Step_150"]
+    assert get_protocol_current(currents, "Step_200") is None
+
+
+def test_plot_responses():
+    """Test that one plot is written for each protocol."""
+    paths = plot_responses(responses, currents, output_dir, file_format="svg")
+
+    assert sorted(path.name for path in paths) == ["Step_150.svg", "Step_200.svg"]
+    for path in paths:
+        assert path.is_file()
+
+
+def test_plot_fi_curves():
+    """Test that one f-I curve plot is written for each f-I curve protocol."""
+    fi_curves = {
+        "FICurve": {
+            "step_amplitude": [0.0, 0.1, 0.2, 0.3],
+            "firing_rate": [0.0, 0.0, 10.0, 20.0],
+            "fit": {
+                "gain": 100.0,
+                "intercept": -10.0,
+                "rheobase": 0.1,
+                "r_squared": 1.0,
+            },
+        },
+        "NotFitted": {
+            "step_amplitude": [0.0, 0.1],
+            "firing_rate": [0.0, 0.0],
+            "fit": {"gain": None, "intercept": None, "rheobase": None},
+        },
+    }
+    paths = plot_fi_curves(fi_curves, output_dir, file_format="svg")
+
+    assert [path.name for path in paths] == [
+        "FICurve_fi_curve.svg",
+        "NotFitted_fi_curve.svg",
+    ]
+    for path in paths:
+        assert path.is_file()
+
+
+def test_plot_output_dir(tmp_path):
+    """Test that the standard figures are written from the outputs of a run."""
+    write_responses(responses, tmp_path)
+    write_current(currents, tmp_path)
+    synapse_responses = {
+        "_.Step_150.synapses": {
+            "time": time,
+            "synapse_ids": np.array([0, 1]),
+            "pre_mtypes": np.array([3, 3]),
+            "i": np.zeros((2, len(time))),
+        }
+    }
+    write_synapse_recordings(synapse_responses, tmp_path)
+    synplas_responses = {
+        "soma.v": {"time": time, "voltage": np.full(time.shape, -80.0)},
+        "rho_GB": [{"voltage": np.ones(time.shape)}, {"voltage": np.zeros(time.shape)}],
+    }
+    write_synplas_output(
+        synplas_responses, [10.0, 20.0], str(tmp_path / "output_1Hz.h5")
+    )
+
+    paths = plot_output_dir(tmp_path, file_format="svg")
+
+    assert [path.name for path in paths] == [
+        "Step_150.svg",
+        "Step_200.svg",
+        "step_overlay.svg",
+        "_.Step_150.synapses_i.svg",
+        "output_1Hz_plasticity.svg",
+    ]
+    for path in paths:
+        assert path.is_file()
+
+
+def test_plot_output_dir_errors(tmp_path):
+    """Test that an empty or missing output directory raises an error."""
+    with pytest.raises(FileNotFoundError):
+        plot_output_dir(tmp_path / "missing")
+    with pytest.raises(ValueError):
+        plot_output_dir(tmp_path)
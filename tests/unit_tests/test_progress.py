@@ -0,0 +1,111 @@
+"""Unit tests for progress.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import json
+import os
+from types import SimpleNamespace
+
+import pytest
+
+from emodelrunner.load import load_config
+from emodelrunner.progress import (
+    ProgressReporter,
+    finish_progress_report,
+    set_progress_reporter,
+)
+from tests.utils import cwd
+
+example_dir = os.path.join("examples", "sscx_sample_dir")
+
+
+def get_fake_sim():
+    """Return a simulator recording the events scheduled in NEURON."""
+    events = []
+    h = SimpleNamespace(
+        t=0.0,
+        tstop=100.0,
+        FInitializeHandler=lambda callback: callback,
+        cvode=SimpleNamespace(event=lambda t, callback: events.append(t)),
+    )
+    return SimpleNamespace(neuron=SimpleNamespace(h=h)), events
+
+
+def test_progress_reporter(tmp_path):
+    """Test the progress and the status file written by the reporter."""
+    sim, events = get_fake_sim()
+    status_path = tmp_path / "status" / "status.json"
+    reporter = ProgressReporter(sim, 10.0, str(status_path))
+
+    reporter.start_run()
+    assert reporter.n_runs == 1
+    assert events == [10.0]
+
+    progress = reporter.get_progress(25.0, 100.0, now=reporter.run_start_time + 5.0)
+    assert progress["run"] == 1
+    assert progress["percent"] == pytest.approx(25.0)
+    assert progress["speed"] == pytest.approx(5.0)
+    assert progress["eta"] == pytest.approx(15.0)
+    assert progress["simulated_time"] == pytest.approx(25.0)
+    # a run continued after tstop is complete
+    assert reporter.get_progress(120.0, 100.0)["percent"] == 100.0
+
+    sim.neuron.h.t = 10.0
+    reporter.report()
+    assert events == [10.0, 20.0]
+    with open(status_path, "r", encoding="utf-8") as status_file:
+        status = json.load(status_file)
+    assert status["status"] == "running"
+    assert status["t"] == 10.0
+    assert status["tstop"] == 100.0
+    assert status["percent"] == pytest.approx(10.0)
+
+    # the simulated time of the previous runs is kept
+    reporter.start_run()
+    assert reporter.n_runs == 2
+    assert reporter.get_progress(5.0, 100.0)["simulated_time"] == pytest.approx(15.0)
+
+    finish_progress_report(SimpleNamespace(progress_reporter=reporter))
+    with open(status_path, "r", encoding="utf-8") as status_file:
+        status = json.load(status_file)
+    assert status["status"] == "done"
+    assert status["n_runs"] == 2
+    assert status["simulated_time"] == pytest.approx(10.0)
+
+    with pytest.raises(ValueError):
+        ProgressReporter(sim, 0.0)
+
+
+def test_set_progress_reporter(tmp_path):
+    """Test that the progress is only reported when set in the config."""
+    with cwd(example_dir):
+        config = load_config(config_path="config/config_allsteps.ini")
+
+    sim, _ = get_fake_sim()
+    assert set_progress_reporter(sim, config) is None
+    assert not hasattr(sim, "progress_reporter")
+    # no reporter, nothing to do
+    finish_progress_report(sim)
+
+    config.set("Sim", "progress_interval", "50")
+    config.set("Paths", "status_path", str(tmp_path / "status.json"))
+    reporter = set_progress_reporter(sim, config)
+    assert sim.progress_reporter is reporter
+    assert reporter.interval == 50.0
+    assert reporter.status_path == str(tmp_path / "status.json")
+
+    config.set("Sim", "simulator", "coreneuron")
+    assert set_progress_reporter(get_fake_sim()[0], config) is None
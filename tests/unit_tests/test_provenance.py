@@ -0,0 +1,95 @@
+"""Unit tests for provenance.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import os
+
+from emodelrunner.load import load_config
+from emodelrunner.provenance import (
+    get_config_hash,
+    get_mod_file_hashes,
+    get_provenance,
+    write_provenance,
+)
+from emodelrunner.seeds import CONFIG_SEED_KEYS, derive_seed
+from tests.utils import cwd
+
+example_dir = os.path.join("examples", "sscx_sample_dir")
+
+
+def test_get_mod_file_hashes(tmp_path):
+    """Test that the hashes depend on the contents of the mod files."""
+    mod_file = tmp_path / "test.mod"
+    mod_file.write_text("NEURON { SUFFIX test }")
+    mod_hash = get_mod_file_hashes(tmp_path)["test.mod"]
+
+    mod_file.write_text("NEURON { SUFFIX test2 }")
+    assert get_mod_file_hashes(tmp_path)["test.mod"] != mod_hash
+    assert get_mod_file_hashes(tmp_path / "missing") == {}
+
+
+def test_get_provenance():
+    """Test that the provenance contains the mod files and the normalized config."""
+    with cwd(example_dir):
+        config = load_config("config/config_allsteps.ini")
+        provenance = get_provenance(config)
+
+    assert "NaTg.mod" in provenance["mod_files"]
+    morph_hashes = list(provenance["morphology_files"].values())
+    assert len(morph_hashes) == 1 and len(morph_hashes[0]) == 64
+    assert provenance["seeds"]["Synapses.seed"] == 846515
+    assert provenance["seeds"]["Sim.channel_seed"] == 0
+    assert provenance["seeds"]["Minis.seed"] == 0
+    assert provenance["hostname"]
+    # default values are included
+    assert "memodel_dir" in provenance["config"]["Paths"]
+    assert provenance["emodelrunner_version"] is not None
+    # the provenance is stored as json
+    assert json.loads(json.dumps(provenance)) == provenance
+
+
+def test_derived_seeds_provenance():
+    """Test that all the seeds derived from the global seed are in the provenance."""
+    with cwd(example_dir):
+        config = load_config("config/config_allsteps.ini", ["Sim.seed=7"])
+        seeds = get_provenance(config)["seeds"]
+
+    assert seeds["Sim.seed"] == 7
+    for section, key in CONFIG_SEED_KEYS:
+        if config.has_option(section, key):
+            stream = f"{section}.{key}"
+            assert seeds[stream] == derive_seed(7, stream)
+    assert seeds["Minis.seed"] == derive_seed(7, "Minis.seed")
+
+
+def test_get_config_hash():
+    """Test that the config hash only depends on the config values."""
+    with cwd(example_dir):
+        config = load_config("config/config_allsteps.ini")
+        config_hash = get_config_hash(config)
+        assert get_config_hash(load_config("config/config_allsteps.ini")) == config_hash
+
+        config = load_config("config/config_allsteps.ini", ["Cell.celsius=36"])
+        assert get_config_hash(config) != config_hash
+
+
+def test_write_provenance(tmp_path):
+    """Test that the provenance is written with the wall time."""
+    output_path = tmp_path / "run" / "provenance.json"
+    write_provenance({"hostname": "node"}, output_path, wall_time=1.5)
+
+    with open(output_path, "r", encoding="utf-8") as provenance_file:
+        assert json.load(provenance_file) == {"hostname": "node", "wall_time": 1.5}
@@ -0,0 +1,74 @@
+"""Unit tests for python_cell.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import os
+
+from bluepyopt import ephys
+
+from emodelrunner.create_cells import create_cell_using_config
+from emodelrunner.load import get_release_params, load_config
+from emodelrunner.python_cell import SECLIST_NAMES, PythonCellModel
+from tests.utils import cwd
+
+example_dir = os.path.join("examples", "sscx_sample_dir")
+
+
+def count_sections(icell):
+    """Return the number of sections in each section list of a cell."""
+    return {
+        seclist_name: len(list(getattr(icell, seclist_name)))
+        for seclist_name in SECLIST_NAMES
+    }
+
+
+def test_python_cell():
+    """Test that the python cell has the same sections as the hoc cell."""
+    sim = ephys.simulators.NrnSimulator()
+    with cwd(example_dir):
+        config = load_config(config_path="config/config_singlestep.ini")
+        release_params = get_release_params(config)
+        hoc_cell = create_cell_using_config(config)
+        config.set("Cell", "instantiation", "python")
+        python_cell = create_cell_using_config(config)
+
+    assert not isinstance(hoc_cell, PythonCellModel)
+    assert isinstance(python_cell, PythonCellModel)
+
+    with cwd(example_dir):
+        sim.mechanisms_directory = "./"
+        hoc_cell.freeze(release_params)
+        hoc_cell.instantiate(sim=sim)
+        hoc_sections = count_sections(hoc_cell.icell)
+        hoc_soma_gnat = hoc_cell.icell.soma[0](0.5).gNaTgbar_NaTg
+        hoc_cell.destroy(sim=sim)
+
+        python_cell.freeze(release_params)
+        python_cell.instantiate(sim=sim)
+    icell = python_cell.icell
+
+    assert count_sections(icell) == hoc_sections
+    # the axon is replaced by the stub axon and the myelin
+    assert len(icell.axon) == 2
+    assert len(icell.myelin) == 1
+    assert icell.axon[0].parentseg().sec == icell.soma[0]
+    assert icell.getCell() is icell
+    assert icell.soma[0].name().endswith("soma[0]")
+    # the mechanisms and parameters are instantiated
+    assert icell.soma[0](0.5).gNaTgbar_NaTg == hoc_soma_gnat
+
+    python_cell.destroy(sim=sim)
+    python_cell.unfreeze(release_params.keys())
+    assert python_cell.icell is None
@@ -0,0 +1,337 @@
+"""Unit tests for recordings.py and synapses/recordings.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import os
+from types import SimpleNamespace
+
+import h5py
+import numpy as np
+import pytest
+
+from emodelrunner.load import (
+    get_section_recording_definitions,
+    get_synapse_recording_args,
+    load_config,
+)
+from emodelrunner.output import write_synapse_recordings
+from emodelrunner.protocols.protocols_func import get_extra_recordings
+from emodelrunner.recordings import (
+    ClampCurrentRecording,
+    CurrentsRecording,
+    RecordingCustom,
+    check_sampling,
+    expand_current_responses,
+    get_segment_currents,
+    get_variable_name,
+    get_variable_ref,
+)
+from emodelrunner.synapses.recordings import (
+    SynapseVariablesRecording,
+    get_synapse_mechanism,
+    pop_synapse_responses,
+)
+from tests.utils import cwd
+
+example_dir = os.path.join("examples", "sscx_sample_dir")
+
+
+class Vector(list):
+    """Fake neuron Vector, copying the values of the recorded reference."""
+
+    interval = None
+
+    def record(self, ref, interval=None):
+        """Record the values of a reference, keeping the sampling interval."""
+        self.extend(ref)
+        self.interval = interval
+
+
+def test_synapse_variables_recording(tmp_path):
+    """Test that the variables are recorded at the selected synapses."""
+    sim = SimpleNamespace(
+        neuron=SimpleNamespace(h=SimpleNamespace(Vector=Vector, _ref_t=[0.0, 0.1]))
+    )
+    hsynapses = [
+        SimpleNamespace(_ref_g=[0, 1], _ref_i=[2, 3]),
+        SimpleNamespace(_ref_g=[4, 5]),
+        SimpleNamespace(_ref_g=[6, 7]),
+    ]
+    mechanism = SimpleNamespace(
+        name="synapses",
+        pprocesses=[
+            SimpleNamespace(sid=sid, pre_mtype=pre_mtype, hsynapse=hsynapse)
+            for sid, pre_mtype, hsynapse in zip([0, 1, 2], [1, 2, 1], hsynapses)
+        ],
+    )
+
+    recording = SynapseVariablesRecording(
+        "L5_TPC.Step_200.synapses", mechanism, ["g", "i"], pre_mtypes=[1]
+    )
+    assert recording.response is None
+    recording.instantiate(sim=sim)
+    response = recording.response
+
+    np.testing.assert_allclose(response["time"], [0.0, 0.1])
+    np.testing.assert_array_equal(response["synapse_ids"], [0, 2])
+    np.testing.assert_allclose(response["g"], [[0, 1], [6, 7]])
+    # the synapse 2 has no i
+    np.testing.assert_allclose(response["i"], [[2, 3], [np.nan, np.nan]])
+
+    recording = SynapseVariablesRecording("sid", mechanism, ["g"], synapse_ids=[1])
+    recording.instantiate(sim=sim)
+    np.testing.assert_allclose(recording.response["g"], [[4, 5]])
+
+    write_synapse_recordings({recording.name: recording.response}, tmp_path)
+    with h5py.File(tmp_path / "synapses.h5", "r") as h5file:
+        np.testing.assert_allclose(h5file["sid"]["g"][()], [[4, 5]])
+        np.testing.assert_array_equal(h5file["sid"]["pre_mtypes"][()], [2])
+
+
+def test_get_synapse_mechanism():
+    """Test that an error is raised when the cell has no synapses."""
+    with pytest.raises(ValueError):
+        get_synapse_mechanism(SimpleNamespace(mechanisms=[]))
+
+
+def test_pop_synapse_responses():
+    """Test that the synapse responses are removed from the responses."""
+    responses = {"_.Step_200.soma.v": None, "_.Step_200.synapses": {"time": []}}
+
+    synapse_responses = pop_synapse_responses(responses)
+    assert list(synapse_responses) == ["_.Step_200.synapses"]
+    assert list(responses) == ["_.Step_200.soma.v"]
+
+
+def test_check_sampling():
+    """Test that the sampling of a recording is checked."""
+    check_sampling()
+    check_sampling(record_dt=0.5)
+    check_sampling(record_every=4)
+    with pytest.raises(ValueError):
+        check_sampling(record_dt=0.5, record_every=4)
+    with pytest.raises(ValueError):
+        check_sampling(record_dt=0)
+    with pytest.raises(ValueError):
+        check_sampling(record_every=0.5)
+
+
+def test_recording_sampling():
+    """Test that the recordings sample their variable at their own interval."""
+    sim = SimpleNamespace(
+        dt=0.025,
+        neuron=SimpleNamespace(h=SimpleNamespace(Vector=Vector, _ref_t=[0.0, 0.1])),
+    )
+    location = SimpleNamespace(
+        instantiate=lambda sim, icell: SimpleNamespace(_ref_v=[-80.0, -79.0])
+    )
+
+    recording = RecordingCustom(name="soma.v", location=location)
+    recording.instantiate(sim=sim)
+    assert recording.varvector.interval == 0.1
+    assert recording.tvector.interval == 0.1
+
+    recording = RecordingCustom(name="soma.v", location=location, record_dt=1.0)
+    recording.instantiate(sim=sim)
+    assert recording.varvector.interval == 1.0
+
+    # record_every is a number of steps of the simulator
+    recording = RecordingCustom(name="soma.v", location=location, record_every=4)
+    recording.instantiate(sim=sim)
+    assert recording.varvector.interval == pytest.approx(0.1)
+    assert recording.tvector.interval == pytest.approx(0.1)
+
+    stimulus = SimpleNamespace(location=location, seclamp=SimpleNamespace(_ref_i=[0]))
+    recording = ClampCurrentRecording(name="soma.i", stimulus=stimulus, record_dt=0.5)
+    recording.instantiate(sim=sim)
+    assert recording.varvector.interval == 0.5
+
+    mechanism = SimpleNamespace(
+        name="synapses",
+        pprocesses=[
+            SimpleNamespace(sid=0, pre_mtype=1, hsynapse=SimpleNamespace(_ref_g=[0, 1]))
+        ],
+    )
+    recording = SynapseVariablesRecording("synapses", mechanism, ["g"])
+    recording.instantiate(sim=sim)
+    # the synapse variables are recorded at every step by default
+    assert recording.tvector.interval is None
+    recording = SynapseVariablesRecording("synapses", mechanism, ["g"], record_dt=2.0)
+    recording.instantiate(sim=sim)
+    assert recording.varvectors["g"][0].interval == 2.0
+    assert recording.tvector.interval == 2.0
+
+    with pytest.raises(ValueError):
+        RecordingCustom(name="soma.v", location=location, record_dt=1.0, record_every=4)
+
+
+def test_config_recording_sampling():
+    """Test the sampling of the recordings of the config."""
+    with cwd(example_dir):
+        config = load_config(config_path="config/config_allsteps.ini")
+    config.set("Recordings", "locations", '["dend[3](0.5)"]')
+    assert "record_dt" not in get_section_recording_definitions(config)[0]
+    assert get_synapse_recording_args(config)["record_dt"] is None
+
+    config.set("Recordings", "record_every", "4")
+    config.set("SynapseRecordings", "record_dt", "0.5")
+    definition = get_section_recording_definitions(config)[0]
+    assert definition["record_every"] == 4
+    assert "record_dt" not in definition
+    synapse_recording_args = get_synapse_recording_args(config)
+    assert synapse_recording_args["record_dt"] == 0.5
+    assert synapse_recording_args["record_every"] is None
+
+
+def test_recording_variables():
+    """Test the recording of ion concentrations and mechanism variables."""
+    assert get_variable_name("cai") == "cai"
+    assert get_variable_name("m_NaTg") == "m_NaTg"
+    assert get_variable_name("NaTg.m") == "m_NaTg"
+    with pytest.raises(ValueError):
+        get_variable_name("NaTg.m.h")
+
+    seg = SimpleNamespace(_ref_v=[-80.0], _ref_cai=[5e-5], _ref_m_NaTg=[0.1])
+    assert get_variable_ref(seg, "cai") == [5e-5]
+    assert get_variable_ref(seg, "NaTg.m") == [0.1]
+    # e.g. no mechanism using sodium at this location
+    with pytest.raises(ValueError):
+        get_variable_ref(seg, "nai")
+
+
+def test_config_recording_variables():
+    """Test the variables recorded at the locations of the config."""
+    with cwd(example_dir):
+        config = load_config(config_path="config/config_allsteps.ini")
+    config.set("Recordings", "locations", '["dend[3](0.5)"]')
+    config.set("Recordings", "variables", '["v", "cai"]')
+    config.set(
+        "Recordings",
+        "location_variables",
+        '{"dend[3](0.5)": ["cai", "NaTg.m"], "soma[0](0.5)": ["nai"]}',
+    )
+
+    definitions = get_section_recording_definitions(config)
+    # each variable is recorded once at each location
+    assert [
+        (definition["sec_name"], definition["var"]) for definition in definitions
+    ] == [("dend", "v"), ("dend", "cai"), ("dend", "NaTg.m"), ("soma", "nai")]
+
+
+class MechanismStandard:
+    """Fake neuron MechanismStandard, with the ASSIGNED variables of a mechanism."""
+
+    assigned = {
+        "NaTg": ["ina_NaTg", "gNaTg_NaTg", "mInf_NaTg"],
+        "Ih": ["ihcn_Ih", "gIh_Ih"],
+        "SKv3_1": ["ik_SKv3_1", "gSKv3_1_SKv3_1"],
+        "pas": ["i_pas"],
+        "CaDynamics_DC0": ["gamma_CaDynamics_DC0"],
+        "na_ion": ["ina", "ena"],
+    }
+
+    def __init__(self, mechanism_name, vartype):
+        """Keep the ASSIGNED variables of the mechanism."""
+        assert vartype == 2
+        self.names = self.assigned[mechanism_name]
+
+    def count(self):
+        """Return the number of variables."""
+        return len(self.names)
+
+    def name(self, name, i):
+        """Set the name of the variable i."""
+        name[0] = self.names[i]
+
+
+def test_current_recordings():
+    """Test that the ionic currents of a location are recorded."""
+    h = SimpleNamespace(
+        Vector=Vector,
+        _ref_t=[0.0, 0.1],
+        MechanismStandard=MechanismStandard,
+        ref=lambda value: [value],
+        ismembrane=lambda name, sec: name in ["na_ion", "k_ion"],
+    )
+    sim = SimpleNamespace(dt=0.025, neuron=SimpleNamespace(h=h))
+
+    class Segment(SimpleNamespace):
+        """Fake neuron Segment, iterating over its mechanisms."""
+
+        def __iter__(self):
+            """Iterate over the mechanisms of the segment."""
+            for mechanism_name in MechanismStandard.assigned:
+                yield SimpleNamespace(name=lambda name=mechanism_name: name)
+
+    seg = Segment(
+        sec=None,
+        _ref_ina=[-1.0, -2.0],
+        _ref_ina_NaTg=[-1.0, -2.0],
+        _ref_ihcn_Ih=[-0.1, -0.1],
+        _ref_ik=[0.5, 1.0],
+        _ref_ik_SKv3_1=[0.5, 1.0],
+        _ref_i_pas=[0.2, 0.3],
+        _ref_i_cap=[0.9, 1.8],
+    )
+    assert get_segment_currents(sim, seg) == [
+        "ina",
+        "ik",
+        "ina_NaTg",
+        "ihcn_Ih",
+        "ik_SKv3_1",
+        "i_pas",
+        "i_cap",
+    ]
+
+    location = SimpleNamespace(name="soma0_x0p5", instantiate=lambda sim, icell: seg)
+    recording = CurrentsRecording(
+        name="_.Step_150.soma0_x0p5.currents", location=location, record_dt=0.5
+    )
+    assert recording.response is None
+    recording.instantiate(sim=sim)
+    assert recording.tvector.interval == 0.5
+    currents = recording.response["currents"]
+    np.testing.assert_allclose(currents["ina_NaTg"], [-1.0, -2.0])
+
+    responses = expand_current_responses(
+        {"_.Step_150.soma.v": None, recording.name: recording.response}
+    )
+    assert list(responses) == [
+        "_.Step_150.soma.v",
+        "_.Step_150.soma0_x0p5.ina",
+        "_.Step_150.soma0_x0p5.ik",
+        "_.Step_150.soma0_x0p5.ina_NaTg",
+        "_.Step_150.soma0_x0p5.ihcn_Ih",
+        "_.Step_150.soma0_x0p5.ik_SKv3_1",
+        "_.Step_150.soma0_x0p5.i_pas",
+        "_.Step_150.soma0_x0p5.i_cap",
+    ]
+    i_pas = responses["_.Step_150.soma0_x0p5.i_pas"]
+    np.testing.assert_allclose(i_pas["time"], [0.0, 0.1])
+    np.testing.assert_allclose(i_pas["voltage"], [0.2, 0.3])
+
+
+def test_extra_current_recordings():
+    """Test that the currents variable of a recording definition records currents."""
+    location = {"type": "section", "sec_name": "soma", "sec_index": 0, "comp_x": 0.5}
+    definitions = [dict(location, var="v"), dict(location, var="currents")]
+
+    recordings = get_extra_recordings(
+        "Step_150", definitions, "_", default_sampling={"record_dt": 0.5}
+    )
+    assert not isinstance(recordings[0], CurrentsRecording)
+    assert isinstance(recordings[1], CurrentsRecording)
+    assert recordings[1].name == "_.Step_150.soma0_x0p5.currents"
+    assert recordings[1].record_dt == 0.5
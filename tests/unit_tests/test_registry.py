@@ -0,0 +1,122 @@
+"""Unit tests for registry.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import configparser
+from pathlib import Path
+import zipfile
+
+import pytest
+
+from emodelrunner.registry import fetch_emodel, get_archive_url
+
+example_dir = Path("examples") / "sscx_sample_dir"
+morph_name = "dend-C231296A-P4B2_axon-C200897C-P2_-_Scale_x1.000_y0.975_z1.000.asc"
+
+
+def write_archive(archive_path, files, root="emodel"):
+    """Write a zip archive of files of the example package."""
+    with zipfile.ZipFile(archive_path, "w") as archive:
+        for file_path in files:
+            archive.write(example_dir / file_path, f"{root}/{file_path}")
+
+
+def test_get_archive_url():
+    """Test the url of the archives in https and Nexus registries."""
+    assert (
+        get_archive_url("https://registry.org/emodels/", "cADpyr_L4UPC")
+        == "https://registry.org/emodels/cADpyr_L4UPC.zip"
+    )
+    assert (
+        get_archive_url(
+            "https://host/nexus/v1/files/org/project",
+            "https://host/data/1234",
+            registry_type="nexus",
+        )
+        == "https://host/nexus/v1/files/org/project/https%3A%2F%2Fhost%2Fdata%2F1234"
+    )
+    with pytest.raises(ValueError):
+        get_archive_url("https://registry.org", "cADpyr_L4UPC", registry_type="ftp")
+
+
+def test_fetch_emodel_with_template(tmp_path):
+    """Test that the config of a package without config is created from the template."""
+    registry_dir = tmp_path / "registry"
+    registry_dir.mkdir()
+    write_archive(
+        registry_dir / "cADpyr_L4UPC.zip",
+        [
+            "config/params/final.json",
+            f"morphology/{morph_name}",
+            "mechanisms/NaTg.mod",
+        ],
+    )
+
+    config_paths = fetch_emodel(
+        "cADpyr_L4UPC",
+        registry_dir.as_uri(),
+        tmp_path / "packages",
+        template_dir=example_dir,
+    )
+
+    package_dir = tmp_path / "packages" / "cADpyr_L4UPC"
+    assert config_paths == [package_dir / "config" / "config_allsteps.ini"]
+    config = configparser.ConfigParser(interpolation=None)
+    config.read(config_paths[0])
+    assert config.get("Cell", "emodel") == "cADpyr_L4UPC"
+    assert config.get("Paths", "morph_path") == f"morphology/{morph_name}"
+
+    assert (package_dir / "config" / "protocols" / "allsteps.json").is_file()
+    assert (package_dir / "templates" / "run_hoc.jinja2").is_file()
+    assert not (package_dir / "LICENSE.txt").exists()
+    assert not (package_dir / "config" / "config_synapses.ini").exists()
+
+    # the package is not overwritten
+    with pytest.raises(ValueError):
+        fetch_emodel("cADpyr_L4UPC", registry_dir.as_uri(), tmp_path / "packages")
+
+
+def test_fetch_emodel_errors(tmp_path):
+    """Test the errors on missing archives, unsafe archives and missing files."""
+    registry_dir = tmp_path / "registry"
+    registry_dir.mkdir()
+
+    with pytest.raises(RuntimeError):
+        fetch_emodel("missing", registry_dir.as_uri(), tmp_path / "packages")
+
+    with zipfile.ZipFile(registry_dir / "unsafe.zip", "w") as archive:
+        archive.writestr("../outside.txt", "")
+    with pytest.raises(ValueError):
+        fetch_emodel("unsafe", registry_dir.as_uri(), tmp_path / "packages")
+    assert not (tmp_path / "outside.txt").exists()
+
+    write_archive(
+        registry_dir / "nomorph.zip",
+        ["config/params/final.json", "mechanisms/NaTg.mod"],
+    )
+    with pytest.raises(FileNotFoundError):
+        fetch_emodel("nomorph", registry_dir.as_uri(), tmp_path / "packages")
+    assert not (tmp_path / "packages" / "nomorph").exists()
+
+    write_archive(
+        registry_dir / "noconfig.zip",
+        [
+            "config/params/final.json",
+            f"morphology/{morph_name}",
+            "mechanisms/NaTg.mod",
+        ],
+    )
+    with pytest.raises(ValueError):
+        fetch_emodel("noconfig", registry_dir.as_uri(), tmp_path / "packages")
@@ -0,0 +1,124 @@
+"""Unit tests for regression.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+
+import numpy as np
+import pytest
+
+from emodelrunner.output import write_current, write_responses
+from emodelrunner.regression import (
+    compare_responses,
+    get_voltage_rms,
+    load_reference,
+    write_report,
+)
+
+
+def spiking_trace(spike_times, time):
+    """Return a voltage trace at -80 mV with 1 ms long spikes at 20 mV."""
+    voltage = np.full_like(time, -80.0)
+    for spike_time in spike_times:
+        # tolerance for the rounding errors of the time steps
+        voltage[(time > spike_time - 1e-6) & (time < spike_time + 1)] = 20.0
+    return voltage
+
+
+@pytest.fixture
+def reference():
+    """Reference responses with a spiking trace and the holding current."""
+    time = np.arange(0, 100, 0.1)
+    return {
+        "L5TPC.Step_150.soma.v": {
+            "time": time,
+            "voltage": spiking_trace([20, 50], time),
+        },
+        "L5TPC.bpo_holding_current": -0.1,
+    }
+
+
+def test_load_reference(tmp_path, reference):
+    """Test that the traces and scalar outputs are loaded, but not the currents."""
+    write_responses(reference, tmp_path)
+    current = {"time": [0, 1], "current": [0, 0.5]}
+    write_current({"current_L5TPC.Step_150": current}, tmp_path)
+
+    loaded = load_reference(tmp_path)
+    assert sorted(loaded) == sorted(reference)
+    np.testing.assert_allclose(
+        loaded["L5TPC.Step_150.soma.v"]["voltage"],
+        reference["L5TPC.Step_150.soma.v"]["voltage"],
+    )
+    assert loaded["L5TPC.bpo_holding_current"] == pytest.approx(-0.1)
+
+    with pytest.raises(FileNotFoundError):
+        load_reference(tmp_path / "missing")
+
+
+def test_get_voltage_rms():
+    """Test that traces with different time steps are compared."""
+    time = np.arange(0, 10, 0.1)
+    ref_time = np.arange(0, 10, 0.025)
+    assert get_voltage_rms(time, time, ref_time, ref_time) == pytest.approx(0)
+    assert get_voltage_rms(time, time + 2, ref_time, ref_time) == pytest.approx(2)
+
+
+def test_compare_responses(tmp_path, reference):
+    """Test the comparison with the reference and the tolerances."""
+    time = np.arange(0, 100, 0.025)
+    responses = {
+        "L5TPC.Step_150.soma.v": {
+            "time": time,
+            "voltage": spiking_trace([20, 50], time),
+        },
+        "L5TPC.bpo_holding_current": -0.10001,
+        "L5TPC.Step_200.soma.v": None,
+    }
+    report = compare_responses(responses, reference)
+    assert report["passed"]
+    assert report["responses"]["L5TPC.Step_150.soma.v"]["spike_count"] == 2
+    assert report["responses"]["L5TPC.Step_150.soma.v"][
+        "spike_time_shift"
+    ] == pytest.approx(0, abs=1e-6)
+    # the responses without reference are not compared
+    assert "L5TPC.Step_200.soma.v" not in report["responses"]
+
+    # shifted spike
+    responses["L5TPC.Step_150.soma.v"]["voltage"] = spiking_trace([20, 50.5], time)
+    report = compare_responses(responses, reference, {"voltage_rms": 100})
+    comparison = report["responses"]["L5TPC.Step_150.soma.v"]
+    assert not report["passed"]
+    assert comparison["failures"] == ["spike_time_shift"]
+    assert comparison["spike_time_shift"] == pytest.approx(0.5)
+    report = compare_responses(
+        responses, reference, {"voltage_rms": 100, "spike_time_shift": 1}
+    )
+    assert report["passed"]
+
+    # missing spike and response
+    responses["L5TPC.Step_150.soma.v"]["voltage"] = spiking_trace([20], time)
+    del responses["L5TPC.bpo_holding_current"]
+    report = compare_responses(responses, reference)
+    assert report["responses"]["L5TPC.Step_150.soma.v"]["failures"] == [
+        "voltage_rms",
+        "spike_count",
+    ]
+    assert report["responses"]["L5TPC.bpo_holding_current"]["failures"] == ["missing"]
+
+    report_path = tmp_path / "report.json"
+    write_report(report, report_path)
+    with open(report_path, "r", encoding="utf-8") as report_file:
+        assert not json.load(report_file)["passed"]
@@ -0,0 +1,237 @@
+"""Unit tests for results.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+from pathlib import Path
+import numpy as np
+
+import pytest
+
+from emodelrunner.output import (
+    write_binary_output,
+    write_current,
+    write_h5_output,
+    write_responses,
+)
+from emodelrunner.results import (
+    BinaryOutput,
+    detect_spikes,
+    load_binary_output,
+    load_features,
+    load_h5_output,
+    load_results,
+    load_spikes,
+    load_traces,
+    parse_output_filename,
+)
+
+output_dir = Path("tests/output/results")
+
+
+@pytest.fixture(autouse=True)
+def run_before_and_after_tests():
+    """Fixture to execute asserts before and after a test is run"""
+    output_dir.mkdir(parents=True, exist_ok=True)
+    for path in output_dir.iterdir():
+        if path.is_file():
+            path.unlink()
+    yield
+
+
+def test_parse_output_filename():
+    """Test the metadata extraction from output filenames."""
+    metadata = parse_output_filename("L5TPC.Step_150.soma.v.dat")
+    assert metadata["kind"] == "trace"
+    assert metadata["prefix"] == "L5TPC"
+    assert metadata["protocol"] == "Step_150"
+    assert metadata["location"] == "soma"
+    assert metadata["variable"] == "v"
+    assert metadata["section"] is None
+
+    metadata = parse_output_filename("L5TPC.Step_150.dend3_x0p5.cai.dat")
+    assert metadata["location"] == "dend3_x0p5"
+    assert metadata["section"] == "dend[3]"
+    assert metadata["position"] == 0.5
+    assert metadata["variable"] == "cai"
+
+    metadata = parse_output_filename("current_L5TPC.Step_150.dat")
+    assert metadata["kind"] == "current"
+    assert metadata["protocol"] == "Step_150"
+
+    metadata = parse_output_filename("L5TPC.bpo_holding_current.dat")
+    assert metadata["kind"] == "scalar"
+    assert metadata["variable"] == "bpo_holding_current"
+
+
+def test_detect_spikes():
+    """Test the threshold crossing spike detection."""
+    time = np.arange(0, 10, 1.0)
+    voltage = np.array([-80, -80, 20, -80, -80, 10, 15, -70, -80, -80])
+    assert np.array_equal(detect_spikes(time, voltage, threshold=0), [2.0, 5.0])
+
+
+def test_load_results():
+    """Test loading traces, features and spikes into DataFrames."""
+    responses = {
+        "_.Step_150.soma.v": {
+            "time": [0.0, 1.0, 2.0, 3.0],
+            "voltage": [-80.0, 10.0, -80.0, -80.0],
+        },
+        "_.bpo_holding_current": 0.1,
+    }
+    currents = {
+        "current__.Step_150": {"time": [0.0, 1.0, 2.0, 3.0], "current": [0, 1, 1, 0]}
+    }
+    write_responses(responses, output_dir)
+    write_current(currents, output_dir)
+
+    traces = load_traces(output_dir)
+    assert len(traces) == 8
+    assert set(traces["variable"]) == {"v", "current"}
+    assert set(traces["protocol"]) == {"Step_150"}
+
+    assert len(load_traces(output_dir, include_currents=False)) == 4
+
+    features = load_features(output_dir)
+    assert features["feature"].tolist() == ["bpo_holding_current"]
+    assert features["value"].tolist() == [0.1]
+
+    spikes = load_spikes(output_dir)
+    assert spikes["time"].tolist() == [1.0]
+
+    results = load_results(output_dir)
+    assert set(results.keys()) == {"traces", "features", "spikes"}
+
+
+def test_load_h5_output():
+    """Test that the h5 output is loaded back into the responses of the run."""
+    responses = {
+        "_.Step_150.soma.v": {"time": [0.0, 1.0], "voltage": [-80.0, 10.0]},
+        "_.bpo_holding_current": 0.1,
+    }
+    currents = {"current__.Step_150": {"time": [0.0, 1.0], "current": [0, 1]}}
+    output_path = output_dir / "responses.h5"
+    write_h5_output(responses, currents, output_path)
+
+    loaded = load_h5_output(output_path)
+    assert set(loaded) == {
+        "_.Step_150.soma.v",
+        "_.bpo_holding_current",
+        "current__.Step_150",
+    }
+    np.testing.assert_allclose(loaded["_.Step_150.soma.v"]["voltage"], [-80, 10])
+    np.testing.assert_allclose(loaded["current__.Step_150"]["current"], [0, 1])
+    assert loaded["_.bpo_holding_current"] == 0.1
+
+
+def test_load_results_from_h5_output():
+    """Test that the results of a run with the h5 output format are loaded."""
+    responses = {
+        "_.Step_150.soma.v": {
+            "time": [0.0, 1.0, 2.0, 3.0],
+            "voltage": [-80.0, 10.0, -80.0, -80.0],
+        },
+        "_.bpo_holding_current": 0.1,
+    }
+    currents = {
+        "current__.Step_150": {"time": [0.0, 1.0, 2.0, 3.0], "current": [0, 1, 1, 0]}
+    }
+    write_h5_output(responses, currents, output_dir / "cADpyr_L4UPC.h5")
+
+    results = load_results(output_dir)
+    assert len(results["traces"]) == 8
+    assert set(results["traces"]["variable"]) == {"v", "current"}
+    assert set(results["traces"]["protocol"]) == {"Step_150"}
+    assert results["features"]["feature"].tolist() == ["bpo_holding_current"]
+    assert results["features"]["value"].tolist() == [0.1]
+    assert results["spikes"]["time"].tolist() == [1.0]
+
+
+def test_load_results_from_binary_output():
+    """Test that the results of a run with the npy output format are loaded."""
+    responses = {
+        "_.Step_150.soma.v": {
+            "time": [0.0, 1.0, 2.0, 3.0],
+            "voltage": [-80.0, 10.0, -80.0, -80.0],
+        },
+        "_.bpo_holding_current": 0.1,
+    }
+    currents = {
+        "current__.Step_150": {"time": [0.0, 1.0, 2.0, 3.0], "current": [0, 1, 1, 0]}
+    }
+    output_path = output_dir / "cADpyr_L4UPC.npy"
+    write_binary_output(responses, currents, output_path)
+
+    results = load_results(output_dir)
+    assert len(results["traces"]) == 8
+    assert set(results["traces"]["variable"]) == {"v", "current"}
+    assert results["features"]["feature"].tolist() == ["bpo_holding_current"]
+    assert results["features"]["value"].tolist() == [0.1]
+    assert results["spikes"]["time"].tolist() == [1.0]
+
+    # the binary output cannot be read without its index
+    (output_dir / "cADpyr_L4UPC.index.json").unlink()
+    with pytest.raises(ValueError, match="cannot be loaded"):
+        load_results(output_dir)
+
+
+def test_load_results_unreadable_format():
+    """Test that the outputs that cannot be loaded raise instead of being skipped."""
+    (output_dir / "cADpyr_L4UPC.nwb").touch()
+    with pytest.raises(ValueError, match="cannot be loaded"):
+        load_results(output_dir)
+
+
+def test_binary_output():
+    """Test that the binary output is read lazily and loaded back into the responses."""
+    responses = {
+        "_.Step_150.soma.v": {"time": [0, 1.0, 2.0], "voltage": [-80.0, 10.0, -70]},
+        "_.Step_150.dend3_x0p5.cai": {"time": [0.0, 2.0], "voltage": [1e-4, 2e-4]},
+        "_.bpo_holding_current": 0.1,
+        "_.bpo_threshold_current": None,
+    }
+    currents = {"current__.Step_150": {"time": [0.0, 1.0], "current": [0, 1]}}
+    output_path = output_dir / "responses.npy"
+    write_binary_output(responses, currents, output_path, provenance={"seed": 1})
+    assert (output_dir / "responses.index.json").is_file()
+
+    output = BinaryOutput(output_path)
+    assert len(output) == 4
+    assert set(output) == {
+        "_.Step_150.soma.v",
+        "_.Step_150.dend3_x0p5.cai",
+        "_.bpo_holding_current",
+        "current__.Step_150",
+    }
+    assert "_.bpo_threshold_current" not in output
+    assert output.provenance == {"seed": 1}
+    trace = output["_.Step_150.soma.v"]
+    assert isinstance(trace["time"], np.memmap)
+    np.testing.assert_allclose(trace["time"], [0, 1, 2])
+    np.testing.assert_allclose(trace["voltage"], [-80, 10, -70])
+    np.testing.assert_allclose(output["current__.Step_150"]["current"], [0, 1])
+    assert output["_.bpo_holding_current"] == 0.1
+    assert output.trace_metadata()["_.Step_150.dend3_x0p5.cai"]["section"] == "dend[3]"
+
+    loaded = load_binary_output(output_path)
+    assert set(loaded) == set(output)
+    np.testing.assert_allclose(
+        loaded["_.Step_150.dend3_x0p5.cai"]["voltage"], [1e-4, 2e-4]
+    )
+    assert not isinstance(loaded["_.Step_150.soma.v"]["time"], np.memmap)
+
+    # an output without traces
+    write_binary_output({"_.bpo_holding_current": 0.1}, {}, output_path)
+    assert dict(BinaryOutput(output_path)) == {"_.bpo_holding_current": 0.1}
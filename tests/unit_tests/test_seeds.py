@@ -0,0 +1,90 @@
+"""Unit tests for seeds.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import os
+
+from emodelrunner.load import get_prot_args, load_config
+from emodelrunner.seeds import (
+    MAX_SEED,
+    derive_seed,
+    get_global_seed,
+    seed_protocol_definitions,
+)
+from tests.utils import cwd
+
+example_dir = os.path.join("examples", "sscx_sample_dir")
+config_path = "config/config_allsteps.ini"
+
+
+def test_derive_seed():
+    """Test that the derived seeds only depend on the global seed and the stream."""
+    seed = derive_seed(42, "Synapses.seed")
+    assert seed == derive_seed(42, "Synapses.seed")
+    assert 0 <= seed < MAX_SEED
+    assert seed != derive_seed(42, "Sim.channel_seed")
+    assert seed != derive_seed(43, "Synapses.seed")
+
+
+def test_apply_global_seed():
+    """Test that the seeds of the config are derived from the global seed."""
+    with cwd(example_dir):
+        config = load_config(config_path)
+        assert get_global_seed(config) is None
+        assert config.getint("Synapses", "seed") == 846515
+        assert get_prot_args(config)["seed"] is None
+
+        config = load_config(config_path, ["Sim.seed=42"])
+
+    assert get_global_seed(config) == 42
+    assert config.getint("Synapses", "seed") == derive_seed(42, "Synapses.seed")
+    assert config.getint("Sim", "channel_seed") == derive_seed(42, "Sim.channel_seed")
+    assert config.getint("Minis", "seed") == derive_seed(42, "Minis.seed")
+    assert get_prot_args(config)["seed"] == 42
+
+
+def test_seed_protocol_definitions():
+    """Test that the seeds of the random stimuli are derived from the global seed."""
+    definitions = {
+        "Noise": {"type": "NoiseProtocol", "stimuli": {"noise": {"seed": 1}}},
+        "Conductance": {
+            "type": "ConductanceClampProtocol",
+            "stimuli": {"conductance": {"delay": 10, "exc": {"mean": 0.01}}},
+        },
+        "Netstim": {"type": "Netstim", "stimuli": {"syn_noise": 1}},
+        "Step": {"type": "StepProtocol", "stimuli": {"step": {"amp": 0.1}}},
+        "Chain": {
+            "type": "ChainProtocol",
+            "phases": {"Test": {"type": "Netstim", "stimuli": {"syn_noise": 1}}},
+        },
+    }
+    assert seed_protocol_definitions(definitions, None) is definitions
+    assert definitions["Noise"]["stimuli"]["noise"]["seed"] == 1
+
+    seed_protocol_definitions(definitions, 42)
+
+    assert definitions["Noise"]["stimuli"]["noise"]["seed"] == derive_seed(
+        42, "Noise.noise"
+    )
+    conductance = definitions["Conductance"]["stimuli"]["conductance"]
+    assert conductance["exc"]["seed"] == derive_seed(42, "Conductance.exc")
+    assert conductance["delay"] == 10
+    assert definitions["Netstim"]["stimuli"]["syn_stim_seed"] == derive_seed(
+        42, "Netstim.syn_stim"
+    )
+    assert definitions["Step"]["stimuli"] == {"step": {"amp": 0.1}}
+    phase_stimuli = definitions["Chain"]["phases"]["Test"]["stimuli"]
+    assert phase_stimuli["syn_stim_seed"] == derive_seed(42, "Chain_Test.syn_stim")
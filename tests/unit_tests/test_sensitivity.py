@@ -0,0 +1,136 @@
+"""Unit tests for sensitivity.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import json
+
+import numpy as np
+import pytest
+
+from emodelrunner.sensitivity import (
+    compute_sensitivities,
+    get_channel_block,
+    get_perturbation_points,
+    load_sensitivity_definition,
+    rank_parameters,
+    read_run_efeatures,
+)
+
+
+def test_get_perturbation_points():
+    """Test the one-at-a-time and Latin hypercube perturbations."""
+    points = get_perturbation_points(["gNaTgbar", "gSKv3_1bar"], "oat", [-10, 10])
+    assert points == [
+        {"gNaTgbar": -10},
+        {"gNaTgbar": 10},
+        {"gSKv3_1bar": -10},
+        {"gSKv3_1bar": 10},
+    ]
+
+    points = get_perturbation_points(
+        ["gNaTgbar", "gSKv3_1bar"], "lhs", sample_range=20, n_samples=10, seed=1
+    )
+    assert len(points) == 10
+    values = np.array([point["gNaTgbar"] for point in points])
+    assert np.all(np.abs(values) <= 20)
+    # one sample in each stratum of 4%
+    assert sorted(((values + 20) // 4).astype(int)) == list(range(10))
+    assert points == get_perturbation_points(
+        ["gNaTgbar", "gSKv3_1bar"], "lhs", sample_range=20, n_samples=10, seed=1
+    )
+
+    with pytest.raises(ValueError):
+        get_perturbation_points(["gNaTgbar"], "sobol")
+
+
+def test_get_channel_block():
+    """Test the channel blocks scaling the perturbed parameters."""
+    assert get_channel_block({"gNaTgbar": 10}) == "gNaTgbar*1.1"
+    assert (
+        get_channel_block({"gNaTgbar": -50}, "gSKv3_1bar=0")
+        == "gSKv3_1bar=0, gNaTgbar*0.5"
+    )
+
+
+def test_load_sensitivity_definition(tmp_path):
+    """Test the defaults and the rejection of the invalid sensitivity files."""
+    sensitivity_path = tmp_path / "sensitivity.json"
+
+    sensitivity_path.write_text(json.dumps({"parameters": ["gNaTgbar"]}))
+    definition = load_sensitivity_definition(sensitivity_path)
+    assert definition["method"] == "oat"
+    assert definition["perturbations"] == [-10, 10]
+
+    for invalid_definition in [
+        {"method": "sobol", "parameters": ["gNaTgbar"]},
+        {"parameters": []},
+        {"parameters": ["gNaTgbar"], "perturbations": [0, 10]},
+        {"parameters": ["gNaTgbar"], "method": "lhs", "range": 100},
+    ]:
+        sensitivity_path.write_text(json.dumps(invalid_definition))
+        with pytest.raises(ValueError):
+            load_sensitivity_definition(sensitivity_path)
+
+
+def test_read_run_efeatures(tmp_path):
+    """Test that the features are averaged over their values."""
+    assert read_run_efeatures(tmp_path) == {}
+
+    (tmp_path / "efeatures.json").write_text(
+        json.dumps({"Step_150": {"Spikecount": [5], "AP_amplitude": [70, 80]}})
+    )
+    efeatures = read_run_efeatures(tmp_path)
+    assert efeatures == {"Step_150.Spikecount": 5.0, "Step_150.AP_amplitude": 75.0}
+
+
+def test_compute_sensitivities():
+    """Test the sensitivities of features proportional to the parameters."""
+    baseline = {"Step.Spikecount": 10.0, "Step.AP_amplitude": 80.0, "Step.zero": 0.0}
+    points = [{"gNaTgbar": -10}, {"gNaTgbar": 10}, {"gSKv3_1bar": 10}]
+    # the spike count is proportional to gNaTgbar and inversely to gSKv3_1bar
+    features = [
+        {"Step.Spikecount": 9.0, "Step.AP_amplitude": 80.0, "Step.zero": 0.0},
+        {"Step.Spikecount": 11.0, "Step.AP_amplitude": 80.0, "Step.zero": 0.0},
+        {"Step.Spikecount": 9.0, "Step.AP_amplitude": 80.0, "Step.zero": 0.0},
+    ]
+
+    sensitivities = compute_sensitivities(baseline, features, points, "oat")
+    sensitivities = sensitivities.set_index(["parameter", "feature"])["sensitivity"]
+    assert sensitivities["gNaTgbar", "Step.Spikecount"] == pytest.approx(1.0)
+    assert sensitivities["gSKv3_1bar", "Step.Spikecount"] == pytest.approx(-1.0)
+    assert sensitivities["gNaTgbar", "Step.AP_amplitude"] == 0.0
+    assert np.isnan(sensitivities["gNaTgbar", "Step.zero"])
+
+    # linear regression on the parameters perturbed together
+    points = get_perturbation_points(
+        ["gNaTgbar", "gSKv3_1bar"], "lhs", sample_range=10, n_samples=10
+    )
+    features = [
+        {
+            "Step.Spikecount": 10.0
+            * (1 + 0.02 * point["gNaTgbar"] - 0.005 * point["gSKv3_1bar"])
+        }
+        for point in points
+    ]
+    sensitivities = compute_sensitivities(
+        {"Step.Spikecount": 10.0}, features, points, "lhs"
+    )
+    assert sensitivities["sensitivity"].tolist() == pytest.approx([2.0, -0.5])
+
+    ranking = rank_parameters(sensitivities)
+    assert ranking["parameter"].tolist() == ["gNaTgbar", "gSKv3_1bar"]
+    assert ranking["rank"].tolist() == [1, 2]
+    assert ranking["most_sensitive_feature"].tolist() == ["Step.Spikecount"] * 2
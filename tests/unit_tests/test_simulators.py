@@ -0,0 +1,62 @@
+"""Unit tests for simulators.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import logging
+import os
+
+import pytest
+
+from emodelrunner.load import load_config
+from emodelrunner.simulators import CoreNeuronSimulator, create_simulator
+from tests.utils import cwd
+
+example_dir = os.path.join("examples", "sscx_sample_dir")
+
+
+def test_create_simulator(caplog):
+    """Test that the simulator of the config is created."""
+    with cwd(example_dir):
+        config = load_config(config_path="config/config_singlestep.ini")
+
+    sim = create_simulator(config, cvode_active=True)
+    assert not isinstance(sim, CoreNeuronSimulator)
+    assert sim.cvode_active
+
+    config.set("Sim", "cvode_atol", "0.0001")
+    config.set("Sim", "cvode_atolscale", '{"v": 0.1}')
+    cvode = create_simulator(config, cvode_active=True).neuron.h.CVode()
+    assert cvode.atol() == pytest.approx(0.0001)
+    assert cvode.atolscale("v") == pytest.approx(0.1)
+
+    config.set("Sim", "simulator", "coreneuron")
+    config.set("Sim", "coreneuron_gpu", "True")
+    sim = create_simulator(config, cvode_active=True)
+    assert isinstance(sim, CoreNeuronSimulator)
+    # CoreNEURON only supports the fixed time step
+    assert not sim.cvode_active
+    assert sim.dt == 0.025
+    assert sim.gpu
+    assert create_simulator(config, cvode_active=False, dt=0.1).dt == 0.1
+
+    config.set("Sim", "progress_interval", "100")
+    with caplog.at_level(logging.WARNING):
+        sim = create_simulator(config, cvode_active=False)
+    assert not hasattr(sim, "progress_reporter")
+    assert "progress cannot be reported" in caplog.text
+
+    config.set("Sim", "simulator", "unknown")
+    with pytest.raises(ValueError):
+        create_simulator(config, cvode_active=False)
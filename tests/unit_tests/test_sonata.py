@@ -0,0 +1,195 @@
+"""Unit tests for sonata.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import configparser
+from types import SimpleNamespace
+
+import pytest
+
+from emodelrunner.load import load_synapses_data, load_synapses_tsv_data
+from emodelrunner.sonata import (
+    append_edge_synapses,
+    get_emodel_name,
+    get_morphology_path,
+    get_section_locations,
+    get_spike_trains,
+    update_config,
+    write_mtype_map,
+    write_synapses_tsv,
+)
+
+
+def test_get_emodel_name():
+    """Test get_emodel_name function."""
+    assert get_emodel_name("hoc:cADpyr_L5TPC") == "cADpyr_L5TPC"
+    assert get_emodel_name("cADpyr_L5TPC") == "cADpyr_L5TPC"
+
+
+def test_get_section_locations():
+    """Test get_section_locations function."""
+    locations = get_section_locations(
+        ["axon", "basal_dendrite", "basal_dendrite", "apical_dendrite", "axon"]
+    )
+    assert locations == [(0, 0), (3, 0), (1, 0), (1, 1), (2, 0), (3, 1)]
+
+
+def test_get_morphology_path():
+    """Test get_morphology_path function."""
+    properties = SimpleNamespace(
+        morphologies_dir="/circuit/swc",
+        alternate_morphology_formats={"neurolucida-asc": "/circuit/asc"},
+    )
+    assert str(get_morphology_path(properties, "morph")) == "/circuit/asc/morph.asc"
+
+    properties.alternate_morphology_formats = {}
+    assert str(get_morphology_path(properties, "morph")) == "/circuit/swc/morph.swc"
+
+
+def test_write_synapses(tmp_path):
+    """Test that the written synapses can be loaded back."""
+    synapse = {
+        "sid": 0,
+        "pre_cell_id": 14454,
+        "sectionlist_id": 1,
+        "sectionlist_index": 7,
+        "seg_x": 0.967,
+        "synapse_type": 114,
+        "dep": 666.0,
+        "fac": 24.0,
+        "use": 0.5,
+        "tau_d": 1.76,
+        "delay": 1.375,
+        "weight": 0.9,
+        "Nrrp": 1.0,
+        "pre_mtype": 0,
+    }
+    tsv_path = tmp_path / "synapses.tsv"
+    write_synapses_tsv(tsv_path, [synapse])
+    assert load_synapses_tsv_data(tsv_path) == [synapse]
+
+    mtype_path = tmp_path / "mtype_map.tsv"
+    write_mtype_map(mtype_path, {1: "L23_BTC", 0: "L3_TPC:A"})
+    assert mtype_path.read_text() == "0 L3_TPC:A\n1 L23_BTC\n"
+
+
+def test_update_config():
+    """Test update_config function."""
+    config = configparser.ConfigParser(interpolation=None)
+    config.read_dict({"Cell": {"emodel": "old", "gid": "1"}})
+
+    update_config(config, "cADpyr_L5TPC", 42, "morphology/morph.asc", "L5_TPC:A")
+
+    assert config.get("Cell", "emodel") == "cADpyr_L5TPC"
+    assert config.getint("Cell", "gid") == 42
+    assert config.get("Paths", "morph_path") == "morphology/morph.asc"
+    assert config.get("Morphology", "mtype") == "L5_TPC:A"
+    assert config.getboolean("Synapses", "add_synapses")
+    assert config.getint("Protocol", "apical_point_isec") == -1
+
+
+class EdgePopulation:
+    """Edge population with the attributes of 3 synapses, the last one on the axon."""
+
+    name = "neurons__neurons__chemical"
+    source = "neurons"
+    attribute_names = {
+        "afferent_section_id",
+        "afferent_section_pos",
+        "syn_type_id",
+        "depression_time",
+        "facilitation_time",
+        "u_syn",
+        "decay_time",
+        "delay",
+        "conductance",
+    }
+
+    def __init__(self):
+        """Constructor."""
+        self.attributes = {
+            "afferent_section_id": [0, 1, 2],
+            "afferent_section_pos": [0.5, 0.25, 0.75],
+            "syn_type_id": [114, 2, 114],
+            "depression_time": [666.0, 600.0, 666.0],
+            "facilitation_time": [24.0, 20.0, 24.0],
+            "u_syn": [0.5, 0.3, 0.5],
+            "decay_time": [1.76, 8.3, 1.76],
+            "delay": [1.375, 0.9, 1.375],
+            "conductance": [0.9, 1.2, 0.9],
+        }
+
+    @staticmethod
+    def source_nodes(selection):
+        """Return the source node id of each edge."""
+        # pylint: disable=unused-argument
+        return [10, 11, 10]
+
+    def get_attribute(self, name, selection):
+        """Return the values of an attribute."""
+        # pylint: disable=unused-argument
+        return self.attributes[name]
+
+
+def test_append_edge_synapses():
+    """Test that the edges are converted to synapses, without the axon ones."""
+    synapses = []
+    mtype_ids = {}
+    section_locations = get_section_locations(["basal_dendrite", "axon"])
+
+    append_edge_synapses(
+        synapses,
+        mtype_ids,
+        EdgePopulation(),
+        SimpleNamespace(flat_size=3),
+        {10: "L5_TPC:A", 11: "L23_BTC"},
+        section_locations,
+    )
+
+    assert len(synapses) == 2
+    assert mtype_ids == {"L5_TPC:A": 0, "L23_BTC": 1}
+    assert synapses[0]["sectionlist_id"] == 0
+    assert synapses[0]["pre_cell_id"] == 11
+    assert synapses[0]["pre_population"] == "neurons"
+    assert synapses[0]["pre_mtype"] == 0
+    assert synapses[0]["Nrrp"] == 1
+    assert synapses[1]["sid"] == 1
+    assert synapses[1]["sectionlist_id"] == 1
+    assert synapses[1]["synapse_type"] == 2
+    assert synapses[1]["pre_mtype"] == 1
+
+    population = EdgePopulation()
+    population.attribute_names = population.attribute_names - {"conductance"}
+    with pytest.raises(ValueError):
+        append_edge_synapses(
+            [],
+            {},
+            population,
+            SimpleNamespace(flat_size=3),
+            {10: "L5_TPC:A", 11: "L23_BTC"},
+            section_locations,
+        )
+
+
+def test_get_spike_trains():
+    """Test that the spikes are grouped by pre-synaptic gid and sorted."""
+    spike_trains = get_spike_trains([3, 0, 3, 0], [20.0, 15.0, 10.0, 5.0])
+    assert spike_trains == {4: [10.0, 20.0], 1: [5.0, 15.0]}
+
+
+def test_load_synapses_data_without_node():
+    """Test that a SONATA edges file needs the node id and morphology."""
+    with pytest.raises(ValueError):
+        load_synapses_data("edges.h5")
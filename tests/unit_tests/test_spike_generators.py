@@ -0,0 +1,117 @@
+"""Unit tests for synapses/spike_generators.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+from types import SimpleNamespace
+
+import numpy as np
+import pytest
+
+from emodelrunner.synapses.spike_generators import (
+    burst_spike_times,
+    check_generator_definition,
+    get_group_generator,
+    poisson_spike_times,
+)
+from emodelrunner.synapses.stimuli import NrnSpikeGeneratorStimulus
+
+
+def test_poisson_spike_times():
+    """Test the number of spikes of constant and time-varying rates."""
+    rng = np.random.default_rng(1)
+    spike_times = poisson_spike_times(rng, 0.0, 100000.0, rate=20.0)
+    assert np.all(np.diff(spike_times) >= 0)
+    assert abs(len(spike_times) - 2000) < 200
+
+    # no spike where the rate is 0
+    spike_times = poisson_spike_times(
+        rng, 0.0, 20000.0, rate_times=[0, 9999, 10000], rates=[0, 0, 50]
+    )
+    assert np.all(spike_times > 9999)
+    assert abs(len(spike_times) - 500) < 100
+
+    assert len(poisson_spike_times(rng, 0.0, 1000.0, rate=0.0)) == 0
+
+
+def test_burst_spike_times():
+    """Test the regular bursts."""
+    rng = np.random.default_rng(1)
+    spike_times = burst_spike_times(
+        rng, 10.0, 115.0, burst_interval=50.0, n_spikes=3, spike_interval=5.0
+    )
+    np.testing.assert_allclose(spike_times, [10, 15, 20, 60, 65, 70, 110])
+
+    spike_times = burst_spike_times(
+        rng, 0.0, 10000.0, burst_interval=100.0, n_spikes=2, spike_interval=2.0, noise=1
+    )
+    assert abs(len(spike_times) - 200) < 50
+
+
+def test_check_generator_definition():
+    """Test that the invalid generators are rejected."""
+    check_generator_definition("default", {"type": "poisson", "rate": 5.0})
+    check_generator_definition(
+        "1", {"type": "poisson", "rate_times": [0, 100], "rates": [5.0, 10.0]}
+    )
+    check_generator_definition(
+        "2",
+        {"type": "burst", "burst_interval": 100, "n_spikes": 3, "spike_interval": 5},
+    )
+
+    invalid_definitions = [
+        {"type": "gamma", "rate": 5.0},
+        {"type": "poisson"},
+        {"type": "poisson", "rate": -1.0},
+        {"type": "poisson", "rate_times": [0, 100], "rates": [5.0]},
+        {"type": "poisson", "rate_times": [100, 0], "rates": [5.0, 1.0]},
+        {"type": "burst", "burst_interval": 100, "n_spikes": 3},
+        {
+            "type": "burst",
+            "burst_interval": 100,
+            "n_spikes": 3,
+            "spike_interval": 5,
+            "noise": 2,
+        },
+    ]
+    for definition in invalid_definitions:
+        with pytest.raises(ValueError):
+            check_generator_definition("default", definition)
+
+
+def test_group_spike_trains():
+    """Test that each synapse gets the reproducible spike train of its group."""
+    generators = {
+        "default": {"type": "poisson", "rate": 50.0},
+        "1": {
+            "type": "burst",
+            "burst_interval": 100,
+            "n_spikes": 2,
+            "spike_interval": 5,
+        },
+    }
+    assert get_group_generator(generators, 1)["type"] == "burst"
+    assert get_group_generator(generators, 3)["type"] == "poisson"
+    assert get_group_generator({"1": generators["1"]}, 3) is None
+
+    stimulus = NrnSpikeGeneratorStimulus([], 0.0, 1000.0, generators, seed=3)
+    synapse = SimpleNamespace(sid=4, pre_mtype=0)
+    spike_train = stimulus.get_spike_train(synapse)
+    np.testing.assert_allclose(stimulus.get_spike_train(synapse), spike_train)
+    assert not np.array_equal(
+        stimulus.get_spike_train(SimpleNamespace(sid=5, pre_mtype=0)), spike_train
+    )
+    assert len(stimulus.get_spike_train(SimpleNamespace(sid=6, pre_mtype=1))) == 20
+
+    assert isinstance(NrnSpikeGeneratorStimulus([], 0.0, 1000.0, generators).seed, int)
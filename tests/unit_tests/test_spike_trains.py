@@ -0,0 +1,63 @@
+"""Unit tests for synapses/spike_trains.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import h5py
+import numpy as np
+import pytest
+
+from emodelrunner.synapses.spike_trains import (
+    load_nwb_spike_trains,
+    load_spike_trains,
+    load_text_spike_trains,
+)
+
+
+def test_load_text_spike_trains(tmp_path):
+    """Test that the spikes of a neurodamus out.dat file are grouped by gid."""
+    spikes_path = tmp_path / "out.dat"
+    spikes_path.write_text("/scatter\n12.5 3\n4.0 1\n\n7.25 3\n")
+
+    spike_trains = load_text_spike_trains(spikes_path)
+    assert spike_trains == {3: [7.25, 12.5], 1: [4.0]}
+    assert load_spike_trains(spikes_path) == spike_trains
+
+    spikes_path.write_text("12.5 3\n4.0\n")
+    with pytest.raises(ValueError, match="Line 2"):
+        load_text_spike_trains(spikes_path)
+
+
+def test_load_nwb_spike_trains(tmp_path):
+    """Test that the spike times of the NWB units are converted to ms."""
+    spikes_path = tmp_path / "spikes.nwb"
+    with h5py.File(spikes_path, "w") as nwb_file:
+        units = nwb_file.create_group("units")
+        units.create_dataset("id", data=np.array([0, 4]))
+        units.create_dataset("spike_times", data=np.array([0.02, 0.01, 0.005]))
+        units.create_dataset("spike_times_index", data=np.array([2, 3]))
+
+    spike_trains = load_spike_trains(spikes_path)
+    assert list(spike_trains) == [1, 5]
+    np.testing.assert_allclose(spike_trains[1], [10.0, 20.0])
+    np.testing.assert_allclose(spike_trains[5], [5.0])
+
+    with h5py.File(spikes_path, "a") as nwb_file:
+        nwb_file["units"].create_dataset("gid", data=np.array([100, 200]))
+    assert list(load_nwb_spike_trains(spikes_path)) == [100, 200]
+
+    with h5py.File(spikes_path, "w") as nwb_file:
+        nwb_file.create_group("acquisition")
+    with pytest.raises(ValueError):
+        load_nwb_spike_trains(spikes_path)
@@ -0,0 +1,98 @@
+"""Unit tests for stdp.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+from pathlib import Path
+
+import numpy as np
+import pytest
+
+from emodelrunner.stdp import (
+    compare_stdp_curves,
+    load_bundled_stdp_references,
+    load_stdp_reference,
+    parse_synplas_output_name,
+    stdp_rms_deviation,
+)
+
+output_dir = Path("tests/output/stdp")
+
+
+def test_parse_synplas_output_name():
+    """Test that the frequency and delay are read from the output name."""
+    assert parse_synplas_output_name("output_10Hz_-10ms.h5") == (10.0, -10.0)
+    assert parse_synplas_output_name("path/to/output_1Hz_10ms.h5") == (1.0, 10.0)
+    assert parse_synplas_output_name("output_0.5Hz_2.5ms.h5") == (0.5, 2.5)
+
+    with pytest.raises(ValueError):
+        parse_synplas_output_name("output.h5")
+
+
+def test_stdp_rms_deviation():
+    """Test the RMS deviation between a model and a reference curve."""
+    curve = {"delta_t": np.array([-10.0, 10.0]), "epsp_ratio": np.array([0.8, 1.2])}
+
+    reference = {"delta_t": [-10.0, 0.0, 10.0], "epsp_ratio": [0.8, 1.0, 1.2]}
+    assert stdp_rms_deviation(curve, reference) == pytest.approx(0)
+
+    # the reference point at 50 ms is out of the model range and is ignored
+    reference = {"delta_t": [-10.0, 10.0, 50.0], "epsp_ratio": [0.7, 1.3, 2.0]}
+    assert stdp_rms_deviation(curve, reference) == pytest.approx(0.1)
+
+    reference = {"delta_t": [50.0], "epsp_ratio": [2.0]}
+    assert stdp_rms_deviation(curve, reference) is None
+
+
+def test_compare_stdp_curves():
+    """Test loading a reference and comparing it with the model curves."""
+    output_dir.mkdir(parents=True, exist_ok=True)
+    reference_path = output_dir / "reference.json"
+    with open(reference_path, "w", encoding="utf-8") as reference_file:
+        json.dump(
+            {"frequency": 10, "delta_t": [-10, 10], "epsp_ratio": [0.7, 1.3]},
+            reference_file,
+        )
+    reference = load_stdp_reference(reference_path)
+    assert reference["name"] == "reference"
+
+    curves = {
+        10.0: {"delta_t": np.array([-10.0, 10.0]), "epsp_ratio": np.array([0.8, 1.2])}
+    }
+    deviations = compare_stdp_curves(curves, [reference])
+    assert deviations["reference"] == pytest.approx(0.1)
+
+    reference["frequency"] = 1.0
+    assert compare_stdp_curves(curves, [reference]) == {"reference": None}
+
+
+def test_bundled_stdp_references():
+    """Test that the experimental curves shipped with the package can be loaded."""
+    references = load_bundled_stdp_references()
+    names = [reference["name"] for reference in references]
+    assert "markram1997_10Hz" in names
+    assert len(set(names)) == len(names)
+
+    reference = references[names.index("markram1997_10Hz")]
+    assert reference["frequency"] == 10.0
+    np.testing.assert_allclose(reference["delta_t"], [-10.0, 10.0])
+    # depression before, and potentiation after the post-synaptic spike
+    assert reference["epsp_ratio"][0] < 1 < reference["epsp_ratio"][1]
+
+    curves = {
+        10.0: {"delta_t": np.array([-10.0, 10.0]), "epsp_ratio": np.array([1.0, 1.0])}
+    }
+    deviations = compare_stdp_curves(curves, references)
+    assert deviations["markram1997_10Hz"] == pytest.approx(0.24)
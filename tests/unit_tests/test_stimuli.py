@@ -0,0 +1,201 @@
+"""Unit tests for stimuli.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import numpy as np
+import pytest
+
+from emodelrunner.locations import SOMA_LOC
+from emodelrunner.stimuli import (
+    Chirp,
+    ConductanceClamp,
+    DoubleExponential,
+    OrnsteinUhlenbeck,
+    SampledTrace,
+    Sinusoid,
+    VoltageClamp,
+    Waveform,
+)
+
+
+def test_sinusoid():
+    """Test the current of the sinusoid stimulus."""
+    stimulus = Sinusoid(
+        SOMA_LOC,
+        delay=100.0,
+        duration=1000.0,
+        amp=0.2,
+        frequency=5.0,
+        total_duration=1200.0,
+        offset=0.1,
+    )
+    t, current = stimulus.generate()
+
+    assert t[-1] == pytest.approx(1199.975)
+    assert np.all(current[t < 100.0] == 0)
+    assert np.all(current[t >= 1100.0] == 0)
+    assert current[t >= 100.0][0] == pytest.approx(0.1, abs=1e-3)
+    # peak a quarter of period (50 ms) after the start
+    assert current[np.argmin(np.abs(t - 150.0))] == pytest.approx(0.3, abs=1e-6)
+    assert current.max() == pytest.approx(0.3, abs=1e-6)
+    assert current.min() == pytest.approx(-0.1, abs=1e-6)
+
+
+def test_chirp():
+    """Test that the frequency of the chirp increases linearly."""
+    stimulus = Chirp(
+        SOMA_LOC,
+        delay=0.0,
+        duration=10000.0,
+        amp=0.1,
+        freq_start=1.0,
+        freq_end=21.0,
+        total_duration=10000.0,
+    )
+    t, current = stimulus.generate(dt=0.1)
+
+    # the upward zero crossings give the mean frequency of each period,
+    # that is the frequency at the middle of the period for a linear sweep
+    crossings = t[1:][(current[:-1] < 0) & (current[1:] >= 0)]
+    frequencies = 1000.0 / np.diff(crossings)
+    times = (crossings[1:] + crossings[:-1]) / 2.0
+    expected = 1.0 + 20.0 * times / 10000.0
+    np.testing.assert_allclose(frequencies, expected, rtol=0.01)
+    assert np.abs(current).max() == pytest.approx(0.1, rel=1e-3)
+
+
+def test_double_exponential():
+    """Test the peak and the decay of the EPSP-like current."""
+    stimulus = DoubleExponential(
+        SOMA_LOC,
+        delay=100.0,
+        duration=100.0,
+        amp=0.05,
+        tau_rise=0.5,
+        tau_decay=5.0,
+        total_duration=300.0,
+    )
+    t, current = stimulus.generate()
+
+    assert stimulus.time_to_peak == pytest.approx(0.5 * 5.0 / 4.5 * np.log(10.0))
+    assert np.all(current[t < 100.0] == 0)
+    assert np.all(current[t >= 200.0] == 0)
+    assert current.max() == pytest.approx(0.05, rel=1e-4)
+    assert t[np.argmax(current)] == pytest.approx(
+        100.0 + stimulus.time_to_peak, abs=0.025
+    )
+    # exponential decay, once the rise is over
+    after = np.argmin(np.abs(t - 150.0))
+    later = np.argmin(np.abs(t - 155.0))
+    assert current[later] / current[after] == pytest.approx(np.exp(-1.0), rel=1e-4)
+
+    with pytest.raises(ValueError):
+        DoubleExponential(SOMA_LOC, 0.0, 10.0, 0.05, 5.0, 0.5, 20.0)
+
+
+def test_ornstein_uhlenbeck():
+    """Test the statistics and the reproducibility of the noise stimulus."""
+    kwargs = {
+        "location": SOMA_LOC,
+        "delay": 100.0,
+        "duration": 20000.0,
+        "mean": 0.1,
+        "sigma": 0.05,
+        "tau": 5.0,
+        "total_duration": 20200.0,
+    }
+    stimulus = OrnsteinUhlenbeck(seed=42, **kwargs)
+    t, current = stimulus.generate()
+
+    assert np.all(current[t < 100.0] == 0)
+    noise = current[(t >= 100.0) & (t < 20100.0)]
+    assert noise[0] == pytest.approx(0.1)
+    assert np.mean(noise) == pytest.approx(0.1, abs=0.01)
+    assert np.std(noise) == pytest.approx(0.05, rel=0.1)
+
+    # same seed, same realization, also when generated at another time step
+    np.testing.assert_array_equal(
+        OrnsteinUhlenbeck(seed=42, **kwargs).generate()[1], current
+    )
+    t_coarse, current_coarse = stimulus.generate(dt=0.1)
+    np.testing.assert_allclose(current_coarse, np.interp(t_coarse, t, current))
+    other_current = OrnsteinUhlenbeck(seed=1, **kwargs).generate()[1]
+    assert not np.array_equal(other_current, current)
+
+    # a seed is drawn and kept when not given
+    assert isinstance(OrnsteinUhlenbeck(**kwargs).seed, int)
+
+    with pytest.raises(ValueError):
+        OrnsteinUhlenbeck(seed=42, **{**kwargs, "tau": 0.0})
+
+
+def test_sampled_trace():
+    """Test the interpolation of the sampled waveform."""
+    trace = SampledTrace(SOMA_LOC, 10.0, 20.0, [0.0, 1.0, 3.0], 40.0, dt=5.0)
+    t, values = trace.generate()
+
+    np.testing.assert_allclose(t, np.arange(0.0, 40.0, 5.0))
+    # the last value is held until the end of the waveform
+    np.testing.assert_allclose(values, [0.0, 0.0, 0.0, 1.0, 3.0, 3.0, 0.0, 0.0])
+
+    t_fine, values_fine = trace.generate(dt=2.5)
+    np.testing.assert_allclose(values_fine, np.interp(t_fine, t, values))
+
+    with pytest.raises(ValueError):
+        SampledTrace(SOMA_LOC, 10.0, 20.0, [], 40.0)
+
+
+def test_conductance_clamp():
+    """Test that the conductance is positive and has the timing of its waveform."""
+    conductance = SampledTrace(SOMA_LOC, 10.0, 20.0, [0.01, -0.02, 0.03], 40.0, dt=5.0)
+    clamp = ConductanceClamp(SOMA_LOC, conductance, reversal_potential=-80.0)
+
+    assert clamp.delay == 10.0
+    assert clamp.duration == 20.0
+    assert clamp.total_duration == 40.0
+    _, values = clamp.generate()
+    assert np.all(values >= 0)
+    np.testing.assert_allclose(values[2:6], [0.01, 0.0, 0.03, 0.03])
+
+
+def test_waveform():
+    """Test the timing and the interpolation of the waveform."""
+    waveform = Waveform(SOMA_LOC, 10.0, [5.0, 10.0, 15.0], [0.0, 1.0, -1.0], 40.0, 2.5)
+    assert waveform.duration == 10.0
+
+    t, current = waveform.generate()
+    assert np.all(current[(t < 10.0) | (t >= 20.0)] == 0)
+    np.testing.assert_allclose(current[(t >= 10.0) & (t < 20.0)], [0.0, 0.5, 1.0, 0.0])
+
+    with pytest.raises(ValueError):
+        Waveform(SOMA_LOC, 10.0, [0.0, 1.0], [0.0, 1.0, 2.0], 40.0)
+    with pytest.raises(ValueError):
+        Waveform(SOMA_LOC, 10.0, [0.0, 2.0, 1.0], [0.0, 1.0, 2.0], 40.0)
+
+
+def test_voltage_clamp():
+    """Test the command voltage of the voltage clamp."""
+    vclamp = VoltageClamp(SOMA_LOC, [-80.0, -10.0, -80.0], [10.0, 5.0, 5.0])
+    assert vclamp.total_duration == 20.0
+    assert vclamp.step_starts == [0.0, 10.0, 15.0]
+
+    t, voltage = vclamp.generate(dt=2.5)
+    np.testing.assert_allclose(t, np.arange(0.0, 20.0, 2.5))
+    np.testing.assert_allclose(voltage, [-80.0] * 4 + [-10.0] * 2 + [-80.0] * 2)
+
+    with pytest.raises(ValueError):
+        VoltageClamp(SOMA_LOC, [-80.0, -10.0], [10.0])
+    with pytest.raises(ValueError):
+        VoltageClamp(SOMA_LOC, [-80.0], [10.0], series_resistance=0.0)
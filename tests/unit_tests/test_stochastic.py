@@ -0,0 +1,102 @@
+"""Unit tests for stochastic.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import json
+from types import SimpleNamespace
+
+import pytest
+
+from emodelrunner.load import load_mechanisms
+from emodelrunner.stochastic import (
+    NrnMODMechanismCustom,
+    get_trial_responses,
+    set_channel_seed,
+)
+
+
+class FakeSection(list):
+    """Section with segments, accepting the mechanism range variables."""
+
+
+def get_fake_sim():
+    """Return a simulator recording the seeds of the stochastic channels."""
+    seeds = []
+    hoc = SimpleNamespace(
+        secname=lambda sec: "cADpyr_L5TPC[0].soma[0]",
+        setdata_StochKv3=lambda x, sec: None,
+        setRNG_StochKv3=lambda seed_id1, seed_id2: seeds.append((seed_id1, seed_id2)),
+    )
+    return SimpleNamespace(neuron=SimpleNamespace(h=hoc)), seeds
+
+
+def test_instantiate_determinism():
+    """Test that each segment of the stochastic channels is seeded."""
+    mechanism = NrnMODMechanismCustom(
+        "StochKv3.somatic", "StochKv3", [], deterministic=False, seed=5
+    )
+    isec = FakeSection([SimpleNamespace(x=0.25), SimpleNamespace(x=0.75)])
+    sim, seeds = get_fake_sim()
+
+    mechanism.instantiate_determinism(False, SimpleNamespace(gid=10), isec, sim)
+    assert isec.deterministic_StochKv3 == 0
+    assert [seed_id1 for seed_id1, _ in seeds] == [15, 15]
+    assert seeds[0][1] == mechanism.hash_py("soma[0].0.25")
+    assert seeds[0][1] != seeds[1][1]
+
+    # the deterministic channels are not seeded
+    seeds.clear()
+    mechanism.instantiate_determinism(True, SimpleNamespace(gid=10), isec, sim)
+    assert isec.deterministic_StochKv3 == 1
+    assert not seeds
+
+    mechanism = NrnMODMechanismCustom("NaTg.somatic", "NaTg", [])
+    with pytest.raises(TypeError):
+        mechanism.instantiate_determinism(False, SimpleNamespace(gid=10), isec, sim)
+
+
+def test_load_stochastic_mechanisms(tmp_path):
+    """Test that only the stochastic channels are made stochastic."""
+    unopt_params_path = tmp_path / "params.json"
+    unopt_params_path.write_text(
+        json.dumps({"mechanisms": {"somatic": {"mech": ["StochKv3", "NaTg"]}}})
+    )
+
+    mechanisms = load_mechanisms(unopt_params_path, stochastic=True, channel_seed=3)
+    assert [mechanism.deterministic for mechanism in mechanisms] == [False, True]
+    assert {mechanism.seed for mechanism in mechanisms} == {3}
+
+    cell = SimpleNamespace(mechanisms=mechanisms)
+    set_channel_seed(cell, 4)
+    assert {mechanism.seed for mechanism in mechanisms} == {4}
+
+    mechanisms = load_mechanisms(unopt_params_path)
+    assert all(mechanism.deterministic for mechanism in mechanisms)
+
+
+def test_get_trial_responses():
+    """Test that the traces of a trial are named after the trial."""
+    trace = {"time": [0.0, 0.1], "voltage": [-80.0, -79.0]}
+    responses = {
+        "L5TPC.Step_150.soma.v": trace,
+        "L5TPC.Step_150.dend3_x0p5.cai": trace,
+        "L5TPC.bpo_holding_current": -0.1,
+    }
+
+    assert get_trial_responses(responses, 2) == {
+        "L5TPC.Step_150.trial2.soma.v": trace,
+        "L5TPC.Step_150.trial2.dend3_x0p5.cai": trace,
+    }
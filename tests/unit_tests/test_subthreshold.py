@@ -0,0 +1,94 @@
+"""Unit tests for subthreshold.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import numpy as np
+import pytest
+
+from emodelrunner.subthreshold import (
+    compute_subthreshold_properties,
+    get_subthreshold_features,
+    get_subthreshold_properties,
+)
+
+time = np.arange(0, 1000, 0.1)
+
+
+def make_trace(step_amplitude, tau=20.0, sag=0.0):
+    """Return the response of a 100 MOhm membrane to a step from 100 to 900 ms.
+
+    The sag is a fraction of the deflection recovered with a slower time constant.
+    """
+    voltage = np.full(time.shape, -80.0)
+    during = (time >= 100) & (time < 900)
+    elapsed = time[during] - 100
+    deflection = 100.0 * step_amplitude / (1 - sag)
+    voltage[during] += deflection * (1 - np.exp(-elapsed / tau))
+    voltage[during] -= sag * deflection * (1 - np.exp(-elapsed / (10 * tau)))
+    return voltage
+
+
+def test_get_subthreshold_features():
+    """Test the features of a passive response, with and without sag."""
+    features = get_subthreshold_features(time, make_trace(-0.05), -0.05, 100, 900)
+    assert features["voltage_base"] == pytest.approx(-80.0)
+    assert features["steady_state_voltage"] == pytest.approx(-85.0)
+    assert features["input_resistance"] == pytest.approx(100.0)
+    assert features["time_constant"] == pytest.approx(20.0, rel=0.01)
+    assert features["sag_ratio"] == pytest.approx(0.0, abs=1e-6)
+
+    voltage = make_trace(-0.05, sag=0.2)
+    features = get_subthreshold_features(time, voltage, -0.05, 100, 900)
+    peak_voltage = np.min(voltage)
+    assert features["input_resistance"] == pytest.approx(100.0, rel=0.01)
+    assert features["sag_ratio"] == pytest.approx(
+        (features["steady_state_voltage"] - peak_voltage) / (-80.0 - peak_voltage)
+    )
+    assert features["sag_ratio"] > 0.1
+
+    # no features without step or with spikes
+    features = get_subthreshold_features(time, make_trace(0.0), 0.0, 100, 900)
+    assert features["input_resistance"] is None
+    voltage = make_trace(0.5)
+    features = get_subthreshold_features(time, voltage, 0.5, 100, 900, -60.0)
+    assert features["input_resistance"] is None
+    assert features["time_constant"] is None
+
+
+def test_get_subthreshold_properties():
+    """Test the features of the steps of a protocol and their mean."""
+    step_amplitudes = {"Sub_0": -0.1, "Sub_1": -0.05}
+    responses = {
+        f"L5_TPC.{name}.soma.v": {"time": time, "voltage": make_trace(amplitude)}
+        for name, amplitude in step_amplitudes.items()
+    }
+    responses["L5_TPC.Sub_0.dend1.v"] = {"time": time, "voltage": make_trace(-0.2)}
+    stim_windows = {name: (100.0, 900.0) for name in step_amplitudes}
+
+    properties = get_subthreshold_properties(responses, step_amplitudes, stim_windows)
+    assert properties["protocol"] == ["Sub_0", "Sub_1"]
+    assert properties["step_amplitude"] == [-0.1, -0.05]
+    assert properties["steady_state_voltage"] == pytest.approx([-90.0, -85.0])
+    assert properties["mean"]["input_resistance"] == pytest.approx(100.0)
+    assert properties["mean"]["time_constant"] == pytest.approx(20.0, rel=0.01)
+
+    subthreshold = compute_subthreshold_properties(
+        responses,
+        {"Sub": {"step_amplitudes": step_amplitudes, "spike_threshold": -82.0}},
+        stim_windows,
+    )
+    # the return to rest after each step crosses the spike threshold:
+    # no step is kept
+    assert subthreshold["Sub"]["mean"]["input_resistance"] is None
@@ -0,0 +1,104 @@
+"""Unit tests for summary.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import numpy as np
+import pytest
+
+from emodelrunner.summary import (
+    detect_depolarization_block,
+    first_spike_latency,
+    get_latency_curve,
+    get_run_summary,
+    longest_run_above,
+)
+
+
+def make_trace(block):
+    """Return a trace spiking 3 times, then either blocked or back to rest."""
+    time = np.arange(0, 1000, 0.1)
+    voltage = np.full(time.shape, -80.0)
+    stim = (time >= 100) & (time < 900)
+    voltage[stim] = -60.0
+    for spike_time in [150, 200, 250]:
+        voltage[(time >= spike_time) & (time < spike_time + 1)] = 20.0
+    if block:
+        voltage[(time > 300) & (time < 900)] = -35.0
+    return time, voltage
+
+
+def test_longest_run_above():
+    """Test longest_run_above function."""
+    time = np.arange(0, 10, 1.0)
+    voltage = np.array([-80, -40, -40, -80, -40, -40, -40, -40, -80, -80])
+    assert longest_run_above(time, voltage, -50) == 4.0
+
+
+def test_detect_depolarization_block():
+    """Test the detection of depolarization block."""
+    time, voltage = make_trace(block=True)
+    assert detect_depolarization_block(time, voltage, 100, 900)
+
+    time, voltage = make_trace(block=False)
+    assert not detect_depolarization_block(time, voltage, 100, 900)
+
+    # no spike: cannot be a depolarization block
+    time = np.arange(0, 1000, 0.1)
+    voltage = np.full(time.shape, -40.0)
+    assert not detect_depolarization_block(time, voltage, 100, 900)
+
+
+def test_get_run_summary():
+    """Test the run summary."""
+    time, voltage = make_trace(block=True)
+    responses = {
+        "_.Step_300.soma.v": {"time": time, "voltage": voltage},
+        "_.bpo_holding_current": -0.1,
+    }
+    summary = get_run_summary(responses, {"Step_300": (100, 900)})
+
+    assert summary["depolarization_block"] == ["_.Step_300.soma.v"]
+    assert summary["traces"]["_.Step_300.soma.v"]["n_spikes"] == 3
+
+
+def test_first_spike_latency():
+    """Test the first spike latency."""
+    time, voltage = make_trace(block=False)
+    assert first_spike_latency(time, voltage, 100) == pytest.approx(50)
+    assert first_spike_latency(time, voltage, 260) is None
+
+
+def test_get_latency_curve():
+    """Test that the latency curve is sorted by step amplitude."""
+    time, voltage = make_trace(block=False)
+    responses = {
+        "_.Step_300.soma.v": {"time": time, "voltage": voltage},
+        "_.Step_200.soma.v": {"time": time, "voltage": np.full(time.shape, -80.0)},
+        "_.Step_200.dend1.v": {"time": time, "voltage": voltage},
+        "_.bpo_holding_current": -0.1,
+    }
+    step_amplitudes = {"Step_200": 0.2, "Step_300": 0.3, "Step_400": 0.4}
+
+    curve = get_latency_curve(
+        responses, step_amplitudes, {"Step_200": (100, 900), "Step_300": (100, 900)}
+    )
+
+    assert curve["protocol"] == ["Step_200", "Step_300"]
+    assert curve["step_amplitude"] == [0.2, 0.3]
+    assert curve["latency"][0] is None
+    assert curve["latency"][1] == pytest.approx(50)
+
+    summary = get_run_summary(responses, step_amplitudes=step_amplitudes)
+    assert summary["latency_curve"]["protocol"] == ["Step_200", "Step_300"]
@@ -0,0 +1,108 @@
+"""Unit tests for sweep.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+from pathlib import Path
+
+import pytest
+
+from tests.utils import cwd
+from emodelrunner.load import load_config
+from emodelrunner.sweep import (
+    get_point_overrides,
+    get_sweep_points,
+    load_sweep_definition,
+    set_protocol_value,
+)
+
+sscx_sample_dir = Path("examples") / "sscx_sample_dir"
+
+
+def test_get_sweep_points():
+    """Test the grid and list combinations of the swept values."""
+    parameters = {"Cell.celsius": [30, 34], "Synapses.weight_scale": [0.5, 1.0]}
+
+    points = get_sweep_points(parameters, "grid")
+    assert len(points) == 4
+    assert points[0] == {"Cell.celsius": 30, "Synapses.weight_scale": 0.5}
+    assert points[3] == {"Cell.celsius": 34, "Synapses.weight_scale": 1.0}
+
+    points = get_sweep_points(parameters, "list")
+    assert points == [
+        {"Cell.celsius": 30, "Synapses.weight_scale": 0.5},
+        {"Cell.celsius": 34, "Synapses.weight_scale": 1.0},
+    ]
+
+    with pytest.raises(ValueError):
+        get_sweep_points({"Cell.celsius": [30, 34], "Sim.dt": [0.025]}, "list")
+    with pytest.raises(ValueError):
+        get_sweep_points(parameters, "random")
+
+
+def test_set_protocol_value():
+    """Test that the protocol values are set from their paths."""
+    protocol_definitions = {
+        "Step_150": {"stimuli": {"step": {"amp": 0.2}}},
+        "Main": {"other_protocols": ["Step_150", "Step_200"]},
+    }
+
+    set_protocol_value(protocol_definitions, "Step_150.stimuli.step.amp", 0.3)
+    set_protocol_value(protocol_definitions, "Main.other_protocols.1", "Step_250")
+    assert protocol_definitions["Step_150"]["stimuli"]["step"]["amp"] == 0.3
+    assert protocol_definitions["Main"]["other_protocols"] == ["Step_150", "Step_250"]
+
+    with pytest.raises(ValueError):
+        set_protocol_value(protocol_definitions, "Step_150.stimuli.step.ampl", 0.3)
+    with pytest.raises(ValueError):
+        set_protocol_value(protocol_definitions, "Main.other_protocols.2", "Step_300")
+
+
+def test_get_point_overrides(tmp_path):
+    """Test the config overrides and the protocols file of a sweep run."""
+    point = {"Cell.celsius": 30, "protocols:Step_150.stimuli.step.amp": 0.3}
+
+    with cwd(sscx_sample_dir):
+        config = load_config(config_path="config/config_singlestep.ini")
+        config_overrides = get_point_overrides(config, point, tmp_path)
+
+    prot_path = tmp_path / "protocols.json"
+    assert config_overrides == [
+        f"Paths.output_dir={tmp_path}",
+        "Cell.celsius=30",
+        f"Paths.prot_path={prot_path}",
+    ]
+    with open(prot_path, "r", encoding="utf-8") as prot_file:
+        protocol_definitions = json.load(prot_file)
+    assert protocol_definitions["Step_150"]["stimuli"]["step"]["amp"] == 0.3
+
+
+def test_load_sweep_definition(tmp_path):
+    """Test that the invalid sweep files are rejected."""
+    sweep_path = tmp_path / "sweep.json"
+
+    sweep_path.write_text(json.dumps({"parameters": {"Cell.celsius": [30, 34]}}))
+    assert load_sweep_definition(sweep_path) == {
+        "mode": "grid",
+        "parameters": {"Cell.celsius": [30, 34]},
+    }
+
+    sweep_path.write_text(json.dumps({"mode": "random", "parameters": {}}))
+    with pytest.raises(ValueError):
+        load_sweep_definition(sweep_path)
+
+    sweep_path.write_text(json.dumps({"parameters": {"Cell.celsius": 30}}))
+    with pytest.raises(ValueError):
+        load_sweep_definition(sweep_path)
@@ -0,0 +1,122 @@
+"""Unit tests for synplas_pairing.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+from pathlib import Path
+
+import numpy as np
+import pytest
+
+from emodelrunner.synplas_pairing import (
+    check_pairing_args,
+    compute_pairing_epsps,
+    get_pairing_pulses,
+    get_pairing_spike_train,
+    get_pairing_timeline,
+    write_pairing_protocol,
+)
+
+synplas_sample_dir = Path("examples") / "synplas_sample_dir"
+
+# parameters of the 1Hz_10ms pairing protocol of the synplas sample
+pairing_args = {
+    "enabled": True,
+    "frequency": 1.0,
+    "n_pairings": 5,
+    "n_repetitions": 10,
+    "repetition_period": 10.0,
+    "delta_t": 10.0,
+    "order": "pre_post",
+    "postspike_latency": 3.83,
+    "pulse_amplitude": 0.6,
+    "pulse_width": 3.0,
+    "baseline_duration": 5.0,
+    "test_duration": 5.0,
+    "test_period": 5.0,
+    "test_start": 1000.0,
+}
+
+
+def test_pairing_protocol(tmp_path):
+    """Test that the pairing protocol of the synplas sample is built."""
+    timeline = get_pairing_timeline(pairing_args)
+    assert timeline["tstop"] == 700000.0
+    assert timeline["c02_start"] == 400000.0
+
+    with open(
+        synplas_sample_dir / "protocols" / "stimuli_1Hz.json", "r", encoding="utf-8"
+    ) as f:
+        assert get_pairing_pulses(pairing_args) == json.load(f)
+
+    spike_train_path = tmp_path / "spiketrain.dat"
+    spike_train = write_pairing_protocol(
+        pairing_args, spike_train_path, tmp_path / "stimuli.json", 111202
+    )
+    expected = np.loadtxt(
+        synplas_sample_dir / "protocols" / "spiketrain_1Hz_10ms.dat", skiprows=1
+    )
+    np.testing.assert_allclose(spike_train, expected[:, 0], atol=0.01)
+    np.testing.assert_allclose(np.loadtxt(spike_train_path, skiprows=1), expected)
+
+    post_pre_args = dict(pairing_args, order="post_pre")
+    np.testing.assert_allclose(
+        get_pairing_spike_train(post_pre_args)[60:110], expected[60:110, 0] + 20.0
+    )
+
+
+def test_check_pairing_args():
+    """Test that the inconsistent pairing parameters are rejected."""
+    check_pairing_args(pairing_args)
+    for invalid_args in [
+        {"order": "pre"},
+        {"frequency": 0.0},
+        {"delta_t": -10.0},
+        {"n_pairings": 20},
+    ]:
+        with pytest.raises(ValueError):
+            check_pairing_args(dict(pairing_args, **invalid_args))
+
+
+def test_compute_pairing_epsps():
+    """Test that the EPSP ratio is measured on the connectivity tests."""
+    args = dict(
+        pairing_args,
+        n_pairings=2,
+        n_repetitions=1,
+        repetition_period=2.0,
+        baseline_duration=0.1,
+        test_duration=0.1,
+        test_period=1.0,
+        test_start=100.0,
+    )
+    spike_train = get_pairing_spike_train(args)
+    assert len(spike_train) == 14
+
+    time = np.arange(0.0, get_pairing_timeline(args)["tstop"], 1.0)
+    voltage = np.full(len(time), -70.0)
+    # EPSP of 1 mV before the pairings and 2 mV after
+    for i, spike_time in enumerate(spike_train):
+        if i < 6:
+            voltage[int(spike_time) + 5] += 1.0
+        elif i >= 8:
+            voltage[int(spike_time) + 5] += 2.0
+
+    epsps = compute_pairing_epsps(time, voltage, spike_train, args)
+    assert epsps["n_sweeps"] == 6
+    assert epsps["delta_t"] == 10.0
+    assert epsps["epsp_before"] == pytest.approx(1.0)
+    assert epsps["epsp_after"] == pytest.approx(2.0)
+    assert epsps["epsp_ratio"] == pytest.approx(2.0)
@@ -0,0 +1,84 @@
+"""Unit tests for factsheets/target_comparison.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+from types import SimpleNamespace
+
+import pytest
+
+from emodelrunner.factsheets.target_comparison import (
+    compare_to_targets,
+    get_zscore,
+    write_target_comparison,
+)
+
+
+def get_fake_feature(protocol, efel_feature_name, exp_mean, exp_std, value):
+    """Return an object behaving as an eFELFeature computing the given value."""
+    return SimpleNamespace(
+        recording_names={"": f"L4PC.{protocol}.soma.v"},
+        efel_feature_name=efel_feature_name,
+        exp_mean=exp_mean,
+        exp_std=exp_std,
+        calculate_feature=lambda responses: value,
+    )
+
+
+def test_get_zscore():
+    """Test the signed z-scores."""
+    assert get_zscore(12.0, 10.0, 0.5) == pytest.approx(4.0)
+    assert get_zscore(8.0, 10.0, 2.0) == pytest.approx(-1.0)
+    assert get_zscore(None, 10.0, 2.0) is None
+    assert get_zscore(10.0, 10.0, 0.0) is None
+
+
+def test_compare_to_targets(tmp_path):
+    """Test the z-score table and the pass/fail summary."""
+    efeatures = {
+        "a": get_fake_feature("Step_150", "Spikecount", 10.0, 2.0, 13.0),
+        "b": get_fake_feature("Step_150", "AP_amplitude", 80.0, 5.0, 60.0),
+        "c": get_fake_feature("bAP", "Spikecount", 1.0, 0.0, 1.0),
+        "d": get_fake_feature("Step_200", "ISI_CV", 0.1, 0.05, None),
+    }
+
+    comparison = compare_to_targets(efeatures, {}, max_zscore=3.0)
+
+    features = comparison["features"]
+    assert features[0] == {
+        "protocol": "Step_150",
+        "location": "soma.v",
+        "feature": "Spikecount",
+        "mean": 10.0,
+        "std": 2.0,
+        "model value": 13.0,
+        "z-score": 1.5,
+        "passed": True,
+    }
+    assert features[1]["z-score"] == pytest.approx(-4.0)
+    assert [feature["passed"] for feature in features] == [True, False, True, False]
+
+    summary = comparison["summary"]
+    assert summary["n_features"] == 4
+    assert summary["n_passed"] == summary["n_failed"] == 2
+    assert summary["max_abs_zscore"] == pytest.approx(4.0)
+    assert summary["failed"] == [
+        "Step_150.soma.v.AP_amplitude",
+        "Step_200.soma.v.ISI_CV",
+    ]
+
+    write_target_comparison(comparison, tmp_path)
+    with open(tmp_path / "target_comparison.json", "r", encoding="utf-8") as file_:
+        assert json.load(file_)["summary"] == summary
@@ -0,0 +1,95 @@
+"""Unit tests for trials.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import json
+from pathlib import Path
+
+import numpy as np
+import pytest
+
+from emodelrunner.trials import (
+    average_responses,
+    get_trial_seeds,
+    run_trials,
+    write_average,
+)
+from tests.utils import cwd
+
+sscx_sample_dir = Path("examples") / "sscx_sample_dir"
+
+
+def test_get_trial_seeds():
+    """Test that the trials have different and reproducible seeds."""
+    seeds = get_trial_seeds(42, 3)
+    assert len(set(seeds)) == 3
+    assert seeds == get_trial_seeds(42, 3)
+    assert seeds[:2] == get_trial_seeds(42, 2)
+    assert seeds != get_trial_seeds(43, 3)
+
+
+def test_average_responses():
+    """Test the mean and std of the responses of all the trials."""
+    time = np.arange(4) * 0.1
+    trial_responses = [
+        {
+            "Step.soma.v": {"time": time, "voltage": np.full(4, -70.0)},
+            "bpo_holding_current": -0.1,
+            "Noise.soma.v": {"time": time, "voltage": np.zeros(4)},
+        },
+        {
+            "Step.soma.v": {"time": time[:3], "voltage": np.full(3, -60.0)},
+            "bpo_holding_current": -0.3,
+        },
+    ]
+
+    average = average_responses(trial_responses)
+
+    assert sorted(average) == ["Step.soma.v", "bpo_holding_current"]
+    np.testing.assert_allclose(average["Step.soma.v"]["time"], time[:3])
+    np.testing.assert_allclose(average["Step.soma.v"]["mean"], -65.0)
+    np.testing.assert_allclose(average["Step.soma.v"]["std"], 5.0)
+    assert average["bpo_holding_current"]["mean"] == pytest.approx(-0.2)
+    assert average["bpo_holding_current"]["std"] == pytest.approx(0.1)
+    assert average_responses([]) == {}
+
+
+def test_write_average(tmp_path):
+    """Test that the averaged traces and scalars are written."""
+    average = {
+        "Step.soma.v": {
+            "time": np.arange(3) * 0.1,
+            "mean": np.full(3, -65.0),
+            "std": np.full(3, 5.0),
+        },
+        "bpo_holding_current": {"mean": -0.2, "std": 0.1},
+    }
+    write_average(average, tmp_path, plot=False)
+
+    data = np.loadtxt(tmp_path / "Step.soma.v.dat")
+    np.testing.assert_allclose(data[:, 1], -65.0)
+    np.testing.assert_allclose(data[:, 2], 5.0)
+    with open(tmp_path / "scalars.json", "r", encoding="utf-8") as scalars_file:
+        assert json.load(scalars_file) == {
+            "bpo_holding_current": {"mean": -0.2, "std": 0.1}
+        }
+
+
+def test_run_trials_errors(tmp_path):
+    """Test that the number of trials has to be positive."""
+    with cwd(sscx_sample_dir):
+        with pytest.raises(ValueError, match="number of trials"):
+            run_trials("config/config_allsteps.ini", 0, tmp_path)
@@ -0,0 +1,99 @@
+"""Unit tests for units.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import numpy as np
+import pytest
+from bluepyopt import ephys
+
+from emodelrunner.units import (
+    get_variable_unit,
+    is_quantity,
+    quantity,
+    responses_with_units,
+    to_magnitude,
+)
+
+
+def test_to_magnitude_without_units():
+    """Test that plain numbers are returned unchanged."""
+    assert not is_quantity(1.5)
+    assert to_magnitude(1.5, "nA") == 1.5
+
+
+def test_to_magnitude():
+    """Test the conversion of quantities to the units of emodelrunner."""
+    pytest.importorskip("pint")
+
+    assert is_quantity(quantity(1.0, "mV"))
+    assert to_magnitude(quantity(200, "pA"), "nA") == pytest.approx(0.2)
+    assert to_magnitude(quantity(-0.07, "V"), "mV") == pytest.approx(-70)
+
+    with pytest.raises(ValueError):
+        to_magnitude(quantity(1.0, "V"), "nA")
+
+
+def test_responses_with_units():
+    """Test that units are attached to the responses."""
+    pytest.importorskip("pint")
+
+    responses = {
+        "_.Step_150.soma.v": ephys.responses.TimeVoltageResponse(
+            "_.Step_150.soma.v", time=[0.0, 0.1], voltage=[-80.0, -79.0]
+        ),
+        "_.bpo_holding_current": -0.1,
+        "_.bpo_threshold_current": None,
+        "Step_150": {"time": [0.0, 0.1], "current": [0.0, 0.5]},
+        "other": "value",
+    }
+    responses = responses_with_units(responses)
+
+    voltage = responses["_.Step_150.soma.v"]["voltage"]
+    np.testing.assert_allclose(voltage.to("V").magnitude, [-0.08, -0.079])
+    assert str(responses["_.Step_150.soma.v"]["time"].units) == "millisecond"
+    assert responses["_.bpo_holding_current"].to("pA").magnitude == pytest.approx(
+        -100
+    )
+    assert responses["_.bpo_threshold_current"] is None
+    assert str(responses["Step_150"]["current"].units) == "nanoampere"
+    assert responses["other"] == "value"
+
+
+def test_recorded_variable_units():
+    """Test that the recordings get the units of their variable."""
+    pytest.importorskip("pint")
+
+    assert get_variable_unit("cai") == "mM"
+    assert get_variable_unit("ina") == "mA/cm**2"
+    assert get_variable_unit("m_NaTg") is None
+    # the currents of the mechanisms, but not their conductances
+    assert get_variable_unit("ina_NaTg") == "mA/cm**2"
+    assert get_variable_unit("ihcn_Ih") == "mA/cm**2"
+    assert get_variable_unit("i_pas") == "mA/cm**2"
+    assert get_variable_unit("g_pas") is None
+
+    responses = responses_with_units(
+        {
+            "_.Step_150.dend3_x0p5.cai": ephys.responses.TimeVoltageResponse(
+                "_.Step_150.dend3_x0p5.cai", time=[0.0, 0.1], voltage=[5e-5, 6e-5]
+            ),
+            "_.Step_150.soma.m_NaTg": {"time": [0.0, 0.1], "voltage": [0.1, 0.2]},
+        }
+    )
+    cai = responses["_.Step_150.dend3_x0p5.cai"]["voltage"]
+    np.testing.assert_allclose(cai.to("uM").magnitude, [0.05, 0.06])
+    # the mechanism variables have no unit
+    assert responses["_.Step_150.soma.m_NaTg"]["voltage"] == [0.1, 0.2]
+    assert str(responses["_.Step_150.soma.m_NaTg"]["time"].units) == "millisecond"
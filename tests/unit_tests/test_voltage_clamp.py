@@ -0,0 +1,100 @@
+"""Unit tests for voltage_clamp.py."""
+
+# Copyright 2020-2022 Blue Brain Project / EPFL
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#     http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+
+import numpy as np
+import pytest
+
+from emodelrunner.voltage_clamp import (
+    compute_voltage_clamp_curves,
+    fit_boltzmann,
+    get_peak_current,
+    get_voltage_clamp_curve,
+)
+
+voltages = list(np.arange(-80.0, 25.0, 5.0))
+
+
+def boltzmann(voltage, v_half, slope):
+    """Return the Boltzmann activation at the given voltage."""
+    return 1.0 / (1.0 + np.exp((v_half - np.asarray(voltage)) / slope))
+
+
+def make_clamp_current(peak_current):
+    """Return a clamp current reaching its peak in the 100-120 ms step."""
+    time = np.arange(0, 140, 0.1)
+    current = np.zeros(time.shape)
+    step = (time >= 100) & (time < 120)
+    current[step] = peak_current * np.exp(-(time[step] - 101.0) ** 2)
+    # capacitive transient at the start of the step
+    current[(time >= 100) & (time < 100.3)] = -100.0
+    return {"time": time, "voltage": current}
+
+
+def test_get_peak_current():
+    """Test that the peak current keeps its sign and is found in the window."""
+    trace = make_clamp_current(-2.0)
+    peak_current = get_peak_current(trace["time"], trace["voltage"], 100.5, 120)
+    assert peak_current == pytest.approx(-2.0)
+    assert get_peak_current(trace["time"], trace["voltage"], 90, 120) == -100.0
+    assert get_peak_current(trace["time"], trace["voltage"], 200, 300) is None
+
+
+def test_fit_boltzmann():
+    """Test that the parameters of the activation and inactivation are recovered."""
+    fit = fit_boltzmann(voltages, boltzmann(voltages, -30.0, 6.0))
+    assert fit["v_half"] == pytest.approx(-30.0)
+    assert fit["slope"] == pytest.approx(6.0)
+
+    fit = fit_boltzmann(voltages, boltzmann(voltages, -60.0, -7.0), inactivation=True)
+    assert fit["v_half"] == pytest.approx(-60.0)
+    assert fit["slope"] == pytest.approx(7.0)
+
+    fit = fit_boltzmann([-80.0, -70.0], [0.0, 1.0])
+    assert fit == {"v_half": None, "slope": None, "n_points": 0}
+
+
+def test_get_voltage_clamp_curve():
+    """Test the activation curve from the peak currents of the sweeps."""
+    conductances = 0.1 * boltzmann(voltages, -30.0, 6.0)
+    responses = {}
+    sweeps = []
+    for i, (voltage, conductance) in enumerate(zip(voltages, conductances)):
+        key = f"L5_TPC.NaActivation_{i}.soma.i"
+        responses[key] = make_clamp_current(conductance * (voltage - 50.0))
+        sweeps.append(
+            {"current_key": key, "command_voltage": voltage, "step_voltage": voltage}
+        )
+    vclamp_protocol = {
+        "type": "activation",
+        "reversal": 50.0,
+        "window": (100.5, 120.0),
+        "sweeps": sweeps[::-1],
+    }
+
+    curve = get_voltage_clamp_curve(responses, vclamp_protocol)
+    assert curve["command_voltage"] == voltages
+    np.testing.assert_allclose(curve["conductance"], conductances, rtol=1e-3)
+    assert curve["normalized"][-1] == pytest.approx(1.0)
+    assert curve["fit"]["v_half"] == pytest.approx(-30.0, abs=0.1)
+    assert curve["fit"]["slope"] == pytest.approx(6.0, abs=0.1)
+
+    # the inactivation curve is the normalized peak current
+    vclamp_protocol["type"] = "inactivation"
+    curves = compute_voltage_clamp_curves(responses, {"NaActivation": vclamp_protocol})
+    curve = curves["NaActivation"]
+    assert "conductance" not in curve
+    assert max(np.abs(curve["normalized"])) == pytest.approx(1.0)